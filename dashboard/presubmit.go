@@ -452,7 +452,7 @@ func (p params) generateTestData(jirix *jiri.X, n, path string) (*testData, erro
 outer:
 	for _, ts := range s.Suites {
 		if ts.Name == p.testSuite {
-			for _, tc := range ts.Cases {
+			for _, tc := range ts.AllCases() {
 				if tc.Name == p.testCase && tc.Classname == p.testClass {
 					test = tc
 					if test.Classname == "" {
@@ -555,7 +555,7 @@ func parseFailedTests(jirix *jiri.X, jobDir string, partIndex int) ([]failedTest
 		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(suitesBytes), err)
 	}
 	for _, ts := range s.Suites {
-		for _, tc := range ts.Cases {
+		for _, tc := range ts.AllCases() {
 			if len(tc.Failures) > 0 || len(tc.Errors) > 0 {
 				failedTests = append(failedTests, failedTest{
 					Suite:     ts.Name,
@@ -0,0 +1,131 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// depAnnotation describes the weight of a listed package.
+type depAnnotation struct {
+	// Deps is the number of transitive dependencies the package brings
+	// in, not counting the package itself.
+	Deps int `json:"deps"`
+	// LOC is the number of lines of the non-test Go files of the
+	// package.
+	LOC        int  `json:"loc"`
+	Goroot     bool `json:"goroot"`
+	ThirdParty bool `json:"thirdParty"`
+}
+
+// depInfo describes a listed package, along with its annotation if
+// requested.
+type depInfo struct {
+	Path string `json:"path"`
+	*depAnnotation
+}
+
+// annotatePackage returns the annotation of pkg.  The transitive
+// dependencies of pkg are counted, and include $GOROOT packages iff
+// opts.IncludeGoroot is set.
+func annotatePackage(pkg *build.Package, opts depOpts) (*depAnnotation, error) {
+	deps := make(map[string]*build.Package)
+	if err := (depOpts{IncludeGoroot: opts.IncludeGoroot}).Deps(pkg, deps); err != nil {
+		return nil, err
+	}
+	loc, err := countLines(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &depAnnotation{
+		Deps:       len(deps),
+		LOC:        loc,
+		Goroot:     pkg.Goroot,
+		ThirdParty: isThirdParty(pkg),
+	}, nil
+}
+
+// countLines returns the number of lines of the non-test Go files of pkg.
+func countLines(pkg *build.Package) (int, error) {
+	if isPseudoPackage(pkg) {
+		return 0, nil
+	}
+	lines := 0
+	for _, files := range [][]string{pkg.GoFiles, pkg.CgoFiles} {
+		for _, file := range files {
+			path := filepath.Join(pkg.Dir, file)
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return 0, fmt.Errorf("ReadFile(%v) failed: %v", path, err)
+			}
+			lines += bytes.Count(data, []byte("\n"))
+		}
+	}
+	return lines, nil
+}
+
+// isThirdParty returns true iff pkg is located in a third_party directory.
+func isThirdParty(pkg *build.Package) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(pkg.Dir), "/") {
+		if dir == "third_party" {
+			return true
+		}
+	}
+	return false
+}
+
+// printPackageList prints the given packages to w in the given format,
+// annotating them if requested.
+func printPackageList(w io.Writer, format string, annotate bool, pkgs []*build.Package, opts depOpts) error {
+	infos := []depInfo{}
+	for _, pkg := range pkgs {
+		info := depInfo{Path: pkg.ImportPath}
+		if annotate {
+			var err error
+			if info.depAnnotation, err = annotatePackage(pkg, opts); err != nil {
+				return err
+			}
+		}
+		infos = append(infos, info)
+	}
+	switch format {
+	case formatText:
+		for _, info := range infos {
+			fmt.Fprintln(w, info)
+		}
+		return nil
+	case formatJSON:
+		bytes, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent() failed: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", bytes)
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// String returns the import path of the package, followed by its
+// annotation, if any.
+func (d depInfo) String() string {
+	if d.depAnnotation == nil {
+		return d.Path
+	}
+	s := fmt.Sprintf("%s deps=%d loc=%d", d.Path, d.Deps, d.LOC)
+	if d.Goroot {
+		s += " goroot"
+	}
+	if d.ThirdParty {
+		s += " third_party"
+	}
+	return s
+}
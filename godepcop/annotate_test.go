@@ -0,0 +1,86 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"testing"
+)
+
+func TestPrintPackageList(t *testing.T) {
+	const v = "v.io/x/devtools/godepcop/testdata/"
+	var pkgs []*build.Package
+	for _, path := range []string{v + "test-b", v + "test-c"} {
+		pkg, err := importPackage(path)
+		if err != nil {
+			t.Fatalf("importPackage(%q) failed: %v", path, err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	tests := []struct {
+		format   string
+		annotate bool
+		want     string
+	}{
+		{formatText, false, v + "test-b\n" + v + "test-c\n"},
+		{formatText, true, v + "test-b deps=2 loc=15\n" + v + "test-c deps=1 loc=11\n"},
+		{formatJSON, false, `[
+  {
+    "path": "v.io/x/devtools/godepcop/testdata/test-b"
+  },
+  {
+    "path": "v.io/x/devtools/godepcop/testdata/test-c"
+  }
+]
+`},
+		{formatJSON, true, `[
+  {
+    "path": "v.io/x/devtools/godepcop/testdata/test-b",
+    "deps": 2,
+    "loc": 15,
+    "goroot": false,
+    "thirdParty": false
+  },
+  {
+    "path": "v.io/x/devtools/godepcop/testdata/test-c",
+    "deps": 1,
+    "loc": 11,
+    "goroot": false,
+    "thirdParty": false
+  }
+]
+`},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := printPackageList(&buf, test.format, test.annotate, pkgs, depOpts{}); err != nil {
+			t.Errorf("%v failed: %v", test, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%v got %q, want %q", test, got, test.want)
+		}
+	}
+	if err := printPackageList(&bytes.Buffer{}, "xml", false, pkgs, depOpts{}); err == nil {
+		t.Errorf("printing with an unknown format did not fail")
+	}
+}
+
+func TestIsThirdParty(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"/jiri/third_party/go/src/github.com/golang/protobuf/proto", true},
+		{"/jiri/release/go/src/v.io/x/devtools/godepcop", false},
+		{"/jiri/release/go/src/v.io/x/third_party_tools", false},
+	}
+	for _, test := range tests {
+		if got := isThirdParty(&build.Package{Dir: test.dir}); got != test.want {
+			t.Errorf("%q: got %v, want %v", test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// limitMode is the mode of the baseline entries that record violations
+// of the limit on the number of transitive dependencies.
+const limitMode = "maxtransitivedeps"
+
+// baselineEntry identifies a violation recorded in a baseline file.
+// The location of the import and the rule that denied it are omitted,
+// so that recorded violations stay suppressed when the code or the
+// configuration files are edited. Violations of the limit on the number
+// of transitive dependencies are recorded with the limit and the number
+// of dependencies instead of the import they are blamed on.
+type baselineEntry struct {
+	Package string `json:"package"`
+	Import  string `json:"import,omitempty"`
+	Mode    string `json:"mode"`
+	Limit   int    `json:"limit,omitempty"`
+	Deps    int    `json:"deps,omitempty"`
+}
+
+func newBaselineEntry(d decision) baselineEntry {
+	if d.Limit != nil {
+		return baselineEntry{
+			Package: d.Src.ImportPath,
+			Mode:    limitMode,
+			Limit:   d.Limit.Max,
+			Deps:    d.Limit.Deps,
+		}
+	}
+	return baselineEntry{
+		Package: d.Src.ImportPath,
+		Import:  d.Dst.ImportPath,
+		Mode:    d.Mode.String(),
+	}
+}
+
+// baseline is the set of violations recorded in a baseline file.
+type baseline map[baselineEntry]bool
+
+// loadBaseline loads the baseline file at the given path.
+func loadBaseline(path string) (baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []baselineEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", path, err)
+	}
+	b := baseline{}
+	for _, e := range entries {
+		b[e] = true
+	}
+	return b, nil
+}
+
+// writeBaseline writes the given violations to the baseline file at the
+// given path.
+func writeBaseline(path string, violations []decision) error {
+	entries, seen := []baselineEntry{}, baseline{}
+	for _, d := range violations {
+		if e := newBaselineEntry(d); !seen[e] {
+			seen[e] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.Sort(baselineEntries(entries))
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent() failed: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), os.FileMode(0644)); err != nil {
+		return fmt.Errorf("WriteFile(%v) failed: %v", path, err)
+	}
+	return nil
+}
+
+// filter returns the given violations that are not recorded in the
+// baseline, along with the number of suppressed violations.
+func (b baseline) filter(violations []decision) ([]decision, int) {
+	var remaining []decision
+	suppressed := 0
+	for _, d := range violations {
+		if b.suppresses(d) {
+			suppressed++
+			continue
+		}
+		remaining = append(remaining, d)
+	}
+	return remaining, suppressed
+}
+
+// suppresses returns whether the given violation is recorded in the
+// baseline. A violation of the limit on the number of transitive
+// dependencies is only suppressed if the limit did not change and the
+// number of dependencies did not grow.
+func (b baseline) suppresses(d decision) bool {
+	e := newBaselineEntry(d)
+	if d.Limit == nil {
+		return b[e]
+	}
+	for known := range b {
+		if known.Package == e.Package && known.Mode == limitMode && known.Limit == e.Limit && known.Deps >= e.Deps {
+			return true
+		}
+	}
+	return false
+}
+
+type baselineEntries []baselineEntry
+
+func (e baselineEntries) Len() int      { return len(e) }
+func (e baselineEntries) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e baselineEntries) Less(i, j int) bool {
+	if e[i].Package != e[j].Package {
+		return e[i].Package < e[j].Package
+	}
+	if e[i].Import != e[j].Import {
+		return e[i].Import < e[j].Import
+	}
+	return e[i].Mode < e[j].Mode
+}
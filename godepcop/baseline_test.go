@@ -0,0 +1,155 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func violation(src, dst string, mode checkMode) decision {
+	return decision{Src: pkg(src), Dst: pkg(dst), Mode: mode, Err: errors.New("violation")}
+}
+
+func TestBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "violations.json")
+
+	// Record the existing violations.
+	existing := []decision{
+		violation("b", "c", modePkg),
+		violation("a", "c", modeTest),
+		violation("a", "c", modePkg),
+		violation("a", "c", modePkg),
+	}
+	if err := writeBaseline(path, existing); err != nil {
+		t.Fatalf("writeBaseline failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	want := `[
+  {
+    "package": "a",
+    "import": "c",
+    "mode": "pkg"
+  },
+  {
+    "package": "a",
+    "import": "c",
+    "mode": "test"
+  },
+  {
+    "package": "b",
+    "import": "c",
+    "mode": "pkg"
+  }
+]
+`
+	if got := string(data); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Check that only new violations remain after filtering.
+	b, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline failed: %v", err)
+	}
+	current := []decision{
+		violation("a", "c", modePkg),
+		violation("a", "c", modeXTest),
+		violation("a", "d", modePkg),
+		violation("b", "c", modePkg),
+	}
+	remaining, suppressed := b.filter(current)
+	if got, want := remaining, []decision{current[1], current[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := suppressed, 2; got != want {
+		t.Errorf("got %d suppressed violations, want %d", got, want)
+	}
+
+	// An empty baseline suppresses nothing.
+	var none baseline
+	if remaining, suppressed := none.filter(current); len(remaining) != len(current) || suppressed != 0 {
+		t.Errorf("got %v, %d, want all violations", remaining, suppressed)
+	}
+}
+
+func TestBaselineDepLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "violations.json")
+
+	limitViolation := func(src, dst string, max, deps int) decision {
+		d := violation(src, dst, modePkg)
+		d.Limit = &depLimit{Max: max, Deps: deps}
+		return d
+	}
+	if err := writeBaseline(path, []decision{limitViolation("a", "c", 10, 12)}); err != nil {
+		t.Fatalf("writeBaseline failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	want := `[
+  {
+    "package": "a",
+    "mode": "maxtransitivedeps",
+    "limit": 10,
+    "deps": 12
+  }
+]
+`
+	if got := string(data); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	b, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline failed: %v", err)
+	}
+	tests := []struct {
+		d    decision
+		want bool
+	}{
+		{limitViolation("a", "c", 10, 12), true},
+		{limitViolation("a", "d", 10, 11), true},
+		{limitViolation("a", "c", 10, 13), false},
+		{limitViolation("a", "c", 8, 12), false},
+		{limitViolation("b", "c", 10, 12), false},
+		// A baselined limit violation does not hide a rule violation
+		// of the same import.
+		{violation("a", "c", modePkg), false},
+	}
+	for _, test := range tests {
+		if got := b.suppresses(test.d); got != test.want {
+			t.Errorf("suppresses(%v, %v): got %v, want %v", test.d.Src.ImportPath, test.d.Limit, got, test.want)
+		}
+	}
+	// A baselined rule violation does not hide a limit violation blamed
+	// on the same import.
+	if err := writeBaseline(path, []decision{violation("a", "c", modePkg)}); err != nil {
+		t.Fatalf("writeBaseline failed: %v", err)
+	}
+	if b, err = loadBaseline(path); err != nil {
+		t.Fatalf("loadBaseline failed: %v", err)
+	}
+	if b.suppresses(limitViolation("a", "c", 10, 12)) {
+		t.Errorf("rule violation suppressed limit violation")
+	}
+}
@@ -17,11 +17,17 @@ import (
 )
 
 var (
+	flagBaseline      string
 	flagStyle         string
 	flagDirect        bool
 	flagGoroot        bool
 	flagTest          bool
 	flagXTest         bool
+	flagFormat        string
+	flagListFormat    string
+	flagAnnotate      bool
+	flagVerbose       bool
+	flagWriteBaseline bool
 	mergePoliciesFlag profilesreader.MergePolicies
 )
 
@@ -34,9 +40,25 @@ const (
 	descGoroot = "Show $GOROOT packages."
 	descTest   = "Show imports from test files in the same package."
 	descXTest  = "Show imports from test files in the same package or in the *_test package."
+
+	descListFormat = `
+Print packages with the given format:
+   text - As one line per package.
+   json - As a JSON array of packages.
+`
+	descAnnotate = "Annotate each package with the number of transitive dependencies it brings in, its number of lines of code, and whether it is in $GOROOT or in a third_party directory."
 )
 
 func init() {
+	cmdCheck.Flags.StringVar(&flagBaseline, "baseline", "", "Path to a baseline file listing known violations, which are not reported.")
+	cmdCheck.Flags.StringVar(&flagFormat, "format", formatText, `
+Print decisions with the given format:
+   text  - As one line per decision.
+   json  - As a JSON array of decisions.
+   sarif - As a SARIF 2.1.0 log (http://sarifweb.azurewebsites.net).
+`)
+	cmdCheck.Flags.BoolVar(&flagVerbose, "v", false, "Also print allowed dependencies, along with the rule that allowed them.")
+	cmdCheck.Flags.BoolVar(&flagWriteBaseline, "write-baseline", false, "Write the current violations to the file given by -baseline instead of reporting them.")
 	cmdList.Flags.StringVar(&flagStyle, "style", styleSet, `
 List dependencies with the given style:
    set    - As a sorted set of unique packages.
@@ -51,6 +73,10 @@ List dependencies with the given style:
 	cmdListImporters.Flags.BoolVar(&flagGoroot, "goroot", false, descGoroot)
 	cmdListImporters.Flags.BoolVar(&flagTest, "test", false, descTest)
 	cmdListImporters.Flags.BoolVar(&flagXTest, "xtest", false, descXTest)
+	for _, cmd := range []*cmdline.Command{cmdList, cmdListImporters} {
+		cmd.Flags.StringVar(&flagListFormat, "format", formatText, descListFormat)
+		cmd.Flags.BoolVar(&flagAnnotate, "annotate", false, descAnnotate)
+	}
 
 	mergePoliciesFlag = profilesreader.JiriMergePolicies()
 	profilescmdline.RegisterMergePoliciesFlag(&cmdList.Flags, &mergePoliciesFlag)
@@ -114,6 +140,15 @@ on the given pattern.  Patterns that end with "/..." are special: "foo/..."
 means that foo and all its subpackages match the rule.  The special-case pattern
 "..."  means that all packages in GOPATH, but not GOROOT, match the rule.
 
+An allow rule may further restrict the packages it matches.  Set denycgo="true"
+to deny matching packages that use cgo, and set denytags to a comma-separated
+list of build tags to deny matching packages that have files constrained by any
+of these tags:
+
+  <godepcop>
+    <pkg allow="..." denycgo="true" denytags="sqlite,leveldb"/>
+  </godepcop>
+
 There are three groups of rules:
   pkg   - Rules applied to all imports from the package.
   test  - Extra rules for imports from all test files.
@@ -125,6 +160,33 @@ package P:
   P.Imports                              - check pkg rules
   P.Imports+P.TestImports                - check test and pkg rules
   P.Imports+P.TestImports+P.XTestImports - check xtest, test and pkg rules
+
+A .godepcop file may also limit the number of transitive dependencies outside
+GOROOT of the packages it applies to, to stop binaries from growing unnoticed:
+
+  <godepcop maxtransitivedeps="150">
+    <pkg allow="..."/>
+  </godepcop>
+
+The limit is taken from the deepmost .godepcop file that sets one, and applies
+to the transitive closure of P.Imports.  A package that exceeds the limit is
+reported as a violation of its heaviest direct import, along with the direct
+imports that bring in the most dependencies.
+
+Each violation is reported along with the file and line of the offending
+import, the direct import through which the denied package is reached, and the
+.godepcop file, rule group, rule index and pattern of the rule that denied it.
+Set the -v flag to also report allowed dependencies and the rules that allowed
+them, and the -format flag to select machine-readable output.
+
+To enable a new rule in a tree that already violates it, record the existing
+violations in a baseline file with -write-baseline and pass the file to
+subsequent checks with -baseline.  Violations listed in the baseline, identified
+by the importing package, the imported package and the rule group, are not
+reported, so that only new violations fail the check.  Violations of the
+maxtransitivedeps limit are recorded with the limit and the number of
+dependencies, and stay suppressed only as long as the limit is unchanged and the
+number of dependencies does not grow.
 `}
 
 func runCheck(env *cmdline.Env, args []string) error {
@@ -141,19 +203,46 @@ func runCheck(env *cmdline.Env, args []string) error {
 		}
 		pkgs = append(pkgs, pkg)
 	}
+	if flagWriteBaseline && flagBaseline == "" {
+		return env.UsageErrorf("-write-baseline requires -baseline")
+	}
+	var known baseline
+	if flagBaseline != "" && !flagWriteBaseline {
+		if known, err = loadBaseline(flagBaseline); err != nil {
+			return err
+		}
+	}
 	// Check each package.
-	var violations []violation
+	var decisions, allViolations []decision
+	numViolations, numSuppressed := 0, 0
 	for _, pkg := range pkgs {
-		v, err := checkDeps(pkg)
+		violations, approvals, err := checkDeps(pkg)
 		if err != nil {
 			return err
 		}
-		violations = append(violations, v...)
+		allViolations = append(allViolations, violations...)
+		violations, suppressed := known.filter(violations)
+		decisions = append(decisions, violations...)
+		if flagVerbose {
+			decisions = append(decisions, approvals...)
+		}
+		numViolations += len(violations)
+		numSuppressed += suppressed
+	}
+	if flagWriteBaseline {
+		if err := writeBaseline(flagBaseline, allViolations); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Stderr, "wrote %d violations to %s\n", len(allViolations), flagBaseline)
+		return nil
 	}
-	for _, v := range violations {
-		fmt.Fprintf(env.Stdout, "%q not allowed to import %q (%v)\n", v.Src.ImportPath, v.Dst.ImportPath, v.Err)
+	if numSuppressed > 0 {
+		fmt.Fprintf(env.Stderr, "%d violations suppressed by %s\n", numSuppressed, flagBaseline)
+	}
+	if err := printDecisions(env.Stdout, flagFormat, decisions); err != nil {
+		return err
 	}
-	if len(violations) > 0 {
+	if numViolations > 0 {
 		return fmt.Errorf("dependency violation")
 	}
 	return nil
@@ -177,9 +266,19 @@ if -goroot were set to true.
 
 Lists each imported package exactly once when using the default -style=set.  See
 the -style flag for alternate output styles.
+
+With -style=set, the -format flag selects machine-readable output, and the
+-annotate flag adds the weight of each package, so that dependency bloat can be
+tracked over time.
 `}
 
 func runList(env *cmdline.Env, args []string) error {
+	if flagListFormat != formatText && flagListFormat != formatJSON {
+		return env.UsageErrorf("unknown format %q", flagListFormat)
+	}
+	if (flagListFormat != formatText || flagAnnotate) && flagStyle != styleSet {
+		return env.UsageErrorf("-format and -annotate require -style=%s", styleSet)
+	}
 	// Gather packages specified in args.
 	paths, err := listPackagePaths(env, args...)
 	if err != nil {
@@ -218,9 +317,7 @@ func runList(env *cmdline.Env, args []string) error {
 				return err
 			}
 		}
-		for _, dep := range sortPackages(deps) {
-			fmt.Fprintln(env.Stdout, dep.ImportPath)
-		}
+		return printPackageList(env.Stdout, flagListFormat, flagAnnotate, sortPackages(deps), opts)
 	}
 	return nil
 }
@@ -242,10 +339,14 @@ Elides $GOROOT packages by default; set the -goroot flag to include importers in
 $GOROOT.  If any of the given <packages> are $GOROOT packages, list-importers
 behaves as if -goroot were set to true.
 
-Lists each importer package exactly once.
+Lists each importer package exactly once.  The -format and -annotate flags work
+as for "list".
 `}
 
 func runListImporters(env *cmdline.Env, args []string) error {
+	if flagListFormat != formatText && flagListFormat != formatJSON {
+		return env.UsageErrorf("unknown format %q", flagListFormat)
+	}
 	// Gather target packages specified in args.
 	targetPaths, err := listPackagePaths(env, args...)
 	if err != nil {
@@ -264,14 +365,17 @@ func runListImporters(env *cmdline.Env, args []string) error {
 		}
 		targets[path] = pkg
 	}
-	// Gather all known packages.
-	allPaths, err := listPackagePaths(env, "all")
+	// Gather the known packages that import any of the targets, either
+	// directly or through their tests, which includes all importers. The
+	// targets themselves can import each other.
+	candidatePaths, err := listReverseDeps(env, targetPaths)
 	if err != nil {
 		return err
 	}
+	candidatePaths = append(candidatePaths, targetPaths...)
 	// Print every package that has dependencies that overlap with the targets.
 	matches := make(map[string]*build.Package)
-	for _, path := range allPaths {
+	for _, path := range candidatePaths {
 		pkg, err := importPackage(path)
 		if err != nil {
 			return err
@@ -284,8 +388,5 @@ func runListImporters(env *cmdline.Env, args []string) error {
 			matches[path] = pkg
 		}
 	}
-	for _, pkg := range sortPackages(matches) {
-		fmt.Fprintln(env.Stdout, pkg.ImportPath)
-	}
-	return nil
+	return printPackageList(env.Stdout, flagListFormat, flagAnnotate, sortPackages(matches), opts)
 }
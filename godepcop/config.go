@@ -17,17 +17,26 @@ import (
 )
 
 type config struct {
-	XMLName    struct{} `xml:"godepcop"`
-	PkgRules   []rule   `xml:"pkg"`
-	TestRules  []rule   `xml:"test"`
-	XTestRules []rule   `xml:"xtest"`
-	Path       string   `xml:"-"`
+	XMLName struct{} `xml:"godepcop"`
+	// MaxTransitiveDeps limits the number of transitive non-GOROOT
+	// dependencies of the packages the config applies to; zero means no
+	// limit.
+	MaxTransitiveDeps int    `xml:"maxtransitivedeps,attr,omitempty"`
+	PkgRules          []rule `xml:"pkg"`
+	TestRules         []rule `xml:"test"`
+	XTestRules        []rule `xml:"xtest"`
+	Path              string `xml:"-"`
 }
 
 type rule struct {
 	// The fields are pointers so that we can distinguish empty from unset values.
 	Allow *string `xml:"allow,attr,omitempty"`
 	Deny  *string `xml:"deny,attr,omitempty"`
+	// DenyCgo and DenyTags restrict allow rules: packages matched by the
+	// rule are still denied if they use cgo or have files constrained
+	// by one of the given comma-separated build tags.
+	DenyCgo  bool   `xml:"denycgo,attr,omitempty"`
+	DenyTags string `xml:"denytags,attr,omitempty"`
 }
 
 func (r rule) IsDeny() bool {
@@ -44,12 +53,41 @@ func (r rule) Pattern() string {
 	return ""
 }
 
+// DeniedTags returns the build tags denied by the rule.
+func (r rule) DeniedTags() []string {
+	var tags []string
+	for _, tag := range strings.Split(r.DenyTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Violation returns a description of the restriction of the rule that
+// the given package violates, or the empty string if there is none.
+func (r rule) Violation(pkg *build.Package) string {
+	if r.DenyCgo && len(pkg.CgoFiles) > 0 {
+		return "uses cgo"
+	}
+	for _, tag := range r.DeniedTags() {
+		for _, pkgTag := range pkg.AllTags {
+			if tag == pkgTag {
+				return fmt.Sprintf("uses build tag %q", tag)
+			}
+		}
+	}
+	return ""
+}
+
 func (r rule) Validate() error {
 	switch {
 	case r.Allow == nil && r.Deny == nil:
 		return errNeitherAllowDeny
 	case r.Allow != nil && r.Deny != nil:
 		return errBothAllowDeny
+	case r.Deny != nil && (r.DenyCgo || r.DenyTags != ""):
+		return errDenyRestrictions
 	case r.Allow != nil && *r.Allow != "":
 		return nil
 	case r.Deny != nil && *r.Deny != "":
@@ -84,7 +122,9 @@ var (
 	errBothAllowDeny    = errors.New("both allow and deny are specified")
 	errNeitherAllowDeny = errors.New("neither allow nor deny is specified")
 	errEmptyRule        = errors.New("empty rule")
-	errNoRules          = errors.New("at least one rule must be specified")
+	errDenyRestrictions = errors.New("denycgo and denytags are only allowed on allow rules")
+	errNoRules          = errors.New("at least one rule or maxtransitivedeps must be specified")
+	errNegativeMaxDeps  = errors.New("maxtransitivedeps must not be negative")
 )
 
 func parseConfig(data []byte) (*config, error) {
@@ -92,9 +132,12 @@ func parseConfig(data []byte) (*config, error) {
 	if err := xml.Unmarshal(data, c); err != nil {
 		return nil, err
 	}
-	if len(c.PkgRules) == 0 && len(c.TestRules) == 0 && len(c.XTestRules) == 0 {
+	if len(c.PkgRules) == 0 && len(c.TestRules) == 0 && len(c.XTestRules) == 0 && c.MaxTransitiveDeps == 0 {
 		return nil, errNoRules
 	}
+	if c.MaxTransitiveDeps < 0 {
+		return nil, errNegativeMaxDeps
+	}
 	for _, r := range c.PkgRules {
 		if err := r.Validate(); err != nil {
 			return nil, fmt.Errorf("pkg: %v", err)
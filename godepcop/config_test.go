@@ -79,6 +79,18 @@ func TestParseConfig(t *testing.T) {
 			`<godepcop><pkg allow="abc"/><pkg deny="..."/></godepcop>`,
 			&config{PkgRules: []rule{{Allow: &abc}, {Deny: &dots}}},
 		},
+		{
+			`<godepcop><pkg allow="..." denycgo="true" denytags="sqlite,leveldb"/></godepcop>`,
+			&config{PkgRules: []rule{{Allow: &dots, DenyCgo: true, DenyTags: "sqlite,leveldb"}}},
+		},
+		{
+			`<godepcop maxtransitivedeps="150"/>`,
+			&config{MaxTransitiveDeps: 150},
+		},
+		{
+			`<godepcop maxtransitivedeps="150"><pkg allow="..."/></godepcop>`,
+			&config{MaxTransitiveDeps: 150, PkgRules: []rule{{Allow: &dots}}},
+		},
 		{
 			testConfigXML,
 			testConfig,
@@ -106,11 +118,11 @@ func TestParseConfigError(t *testing.T) {
 		// No rules
 		{
 			`<godepcop/>`,
-			"at least one rule must be specified",
+			"at least one rule or maxtransitivedeps must be specified",
 		},
 		{
 			`<godepcop></godepcop>`,
-			"at least one rule must be specified",
+			"at least one rule or maxtransitivedeps must be specified",
 		},
 		// Pkg rules
 		{
@@ -133,6 +145,19 @@ func TestParseConfigError(t *testing.T) {
 			`<godepcop><pkg allow="x" deny="y"/></godepcop>`,
 			"pkg: both allow and deny are specified",
 		},
+		{
+			`<godepcop><pkg deny="x" denycgo="true"/></godepcop>`,
+			"pkg: denycgo and denytags are only allowed on allow rules",
+		},
+		{
+			`<godepcop><pkg deny="x" denytags="sqlite"/></godepcop>`,
+			"pkg: denycgo and denytags are only allowed on allow rules",
+		},
+		// Limits
+		{
+			`<godepcop maxtransitivedeps="-1"><pkg allow="..."/></godepcop>`,
+			"maxtransitivedeps must not be negative",
+		},
 		// Test rules
 		{
 			`<godepcop><test/></godepcop>`,
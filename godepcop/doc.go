@@ -51,6 +51,15 @@ on the given pattern.  Patterns that end with "/..." are special: "foo/..."
 means that foo and all its subpackages match the rule.  The special-case pattern
 "..."  means that all packages in GOPATH, but not GOROOT, match the rule.
 
+An allow rule may further restrict the packages it matches.  Set denycgo="true"
+to deny matching packages that use cgo, and set denytags to a comma-separated
+list of build tags to deny matching packages that have files constrained by any
+of these tags:
+
+  <godepcop>
+    <pkg allow="..." denycgo="true" denytags="sqlite,leveldb"/>
+  </godepcop>
+
 There are three groups of rules:
   pkg   - Rules applied to all imports from the package.
   test  - Extra rules for imports from all test files.
@@ -63,11 +72,52 @@ package P:
   P.Imports+P.TestImports                - check test and pkg rules
   P.Imports+P.TestImports+P.XTestImports - check xtest, test and pkg rules
 
+A .godepcop file may also limit the number of transitive dependencies outside
+GOROOT of the packages it applies to, to stop binaries from growing unnoticed:
+
+  <godepcop maxtransitivedeps="150">
+    <pkg allow="..."/>
+  </godepcop>
+
+The limit is taken from the deepmost .godepcop file that sets one, and applies
+to the transitive closure of P.Imports.  A package that exceeds the limit is
+reported as a violation of its heaviest direct import, along with the direct
+imports that bring in the most dependencies.
+
+Each violation is reported along with the file and line of the offending
+import, the direct import through which the denied package is reached, and the
+.godepcop file, rule group, rule index and pattern of the rule that denied it.
+Set the -v flag to also report allowed dependencies and the rules that allowed
+them, and the -format flag to select machine-readable output.
+
+To enable a new rule in a tree that already violates it, record the existing
+violations in a baseline file with -write-baseline and pass the file to
+subsequent checks with -baseline.  Violations listed in the baseline, identified
+by the importing package, the imported package and the rule group, are not
+reported, so that only new violations fail the check.  Violations of the
+maxtransitivedeps limit are recorded with the limit and the number of
+dependencies, and stay suppressed only as long as the limit is unchanged and the
+number of dependencies does not grow.
+
 Usage:
    godepcop check [flags] <packages>
 
 <packages> is a list of packages to check
 
+The godepcop check flags are:
+ -baseline=
+   Path to a baseline file listing known violations, which are not reported.
+ -format=text
+   Print decisions with the given format:
+      text  - As one line per decision.
+      json  - As a JSON array of decisions.
+      sarif - As a SARIF 2.1.0 log (http://sarifweb.azurewebsites.net).
+ -v=false
+   Also print allowed dependencies, along with the rule that allowed them.
+ -write-baseline=false
+   Write the current violations to the file given by -baseline instead of
+   reporting them.
+
 Godepcop list - List packages imported by the given packages
 
 List packages imported by the given <packages>.
@@ -82,14 +132,26 @@ if -goroot were set to true.
 Lists each imported package exactly once when using the default -style=set.  See
 the -style flag for alternate output styles.
 
+With -style=set, the -format flag selects machine-readable output, and the
+-annotate flag adds the weight of each package, so that dependency bloat can be
+tracked over time.
+
 Usage:
    godepcop list [flags] <packages>
 
 <packages> is a list of packages
 
 The godepcop list flags are:
+ -annotate=false
+   Annotate each package with the number of transitive dependencies it brings
+   in, its number of lines of code, and whether it is in $GOROOT or in a
+   third_party directory.
  -direct=false
    Only show direct dependencies, rather than showing transitive dependencies.
+ -format=text
+   Print packages with the given format:
+      text - As one line per package.
+      json - As a JSON array of packages.
  -goroot=false
    Show $GOROOT packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -116,7 +178,8 @@ Elides $GOROOT packages by default; set the -goroot flag to include importers in
 $GOROOT.  If any of the given <packages> are $GOROOT packages, list-importers
 behaves as if -goroot were set to true.
 
-Lists each importer package exactly once.
+Lists each importer package exactly once.  The -format and -annotate flags work
+as for "list".
 
 Usage:
    godepcop list-importers [flags] <packages>
@@ -124,8 +187,16 @@ Usage:
 <packages> is a list of packages
 
 The godepcop list-importers flags are:
+ -annotate=false
+   Annotate each package with the number of transitive dependencies it brings
+   in, its number of lines of code, and whether it is in $GOROOT or in a
+   third_party directory.
  -direct=false
    Only show direct dependencies, rather than showing transitive dependencies.
+ -format=text
+   Print packages with the given format:
+      text - As one line per package.
+      json - As a JSON array of packages.
  -goroot=false
    Show $GOROOT packages.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"go/build"
+	"go/token"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -24,9 +26,51 @@ func (r result) String() string {
 	return []string{"undecided", "approved", "rejected"}[int(r)]
 }
 
-type violation struct {
+// decision records the outcome of checking the dependency of Src on Dst,
+// along with the provenance of the outcome.
+type decision struct {
 	Src, Dst *build.Package
-	Err      error
+	// Err describes the violation if the dependency is rejected.
+	Err error
+	// Mode identifies the group of imports of Src that was checked.
+	Mode checkMode
+	// Rule identifies the rule that produced the decision; it is nil if
+	// no rule matched or if the decision was made by the Go 1.5 internal
+	// package rule.
+	Rule *ruleRef
+	// Via identifies the direct import of Src through which Dst is
+	// imported, and Pos identifies the location of that import.
+	Via string
+	Pos token.Position
+	// Limit is set if the decision is a violation of the limit on the
+	// number of transitive dependencies of Src.
+	Limit *depLimit
+}
+
+// depLimit describes a violation of the limit on the number of
+// transitive dependencies of a package.
+type depLimit struct {
+	// Max is the limit and Deps the number of transitive dependencies.
+	Max, Deps int
+}
+
+// ruleRef identifies a rule within a .godepcop file.
+type ruleRef struct {
+	// Config is the path to the .godepcop file.
+	Config string
+	// Group is the group of rules ("pkg", "test" or "xtest") and Index
+	// is the (1-based) index of the rule within the group.
+	Group checkMode
+	Index int
+	rule
+}
+
+func (r *ruleRef) String() string {
+	kind := "allow"
+	if r.IsDeny() {
+		kind = "deny"
+	}
+	return fmt.Sprintf("%s %s rule #%d %q in %s", r.Group, kind, r.Index, r.Pattern(), r.Config)
 }
 
 func enforceRule(r rule, pkg *build.Package) (result, error) {
@@ -34,7 +78,7 @@ func enforceRule(r rule, pkg *build.Package) (result, error) {
 		switch {
 		case pkg.Goroot:
 			return resultUndecided, nil
-		case r.IsDeny():
+		case r.IsDeny(), r.Violation(pkg) != "":
 			return resultRejected, nil
 		}
 		return resultApproved, nil
@@ -52,6 +96,8 @@ func enforceRule(r rule, pkg *build.Package) (result, error) {
 		return resultUndecided, nil
 	case r.IsDeny():
 		return resultRejected, nil
+	case r.Violation(pkg) != "":
+		return resultRejected, nil
 	}
 	return resultApproved, nil
 }
@@ -82,54 +128,231 @@ func verifyGo15InternalRule(src, dst string) bool {
 
 var errGo15Internal = errors.New("violates Go 1.5 internal package rule")
 
-func checkDep(pkg, dep *build.Package, mode checkMode) (*violation, error) {
-	it := newConfigIter(pkg)
-	for it.Advance() {
-		// Collect the ordered rules from this config for the given mode.
-		cfg := it.Value()
+// rulesForMode returns the ordered rules from the given config for the
+// given mode.
+func rulesForMode(cfg *config, mode checkMode) []*ruleRef {
+	var groups []checkMode
+	switch mode {
+	case modePkg:
+		groups = []checkMode{modePkg}
+	case modeTest:
+		groups = []checkMode{modeTest, modePkg}
+	case modeXTest:
+		groups = []checkMode{modeXTest, modeTest, modePkg}
+	}
+	var refs []*ruleRef
+	for _, group := range groups {
 		var rules []rule
-		switch mode {
+		switch group {
 		case modePkg:
 			rules = cfg.PkgRules
 		case modeTest:
-			rules = append(cfg.TestRules, cfg.PkgRules...)
+			rules = cfg.TestRules
 		case modeXTest:
-			rules = append(cfg.XTestRules, cfg.TestRules...)
-			rules = append(rules, cfg.PkgRules...)
+			rules = cfg.XTestRules
+		}
+		for i, r := range rules {
+			refs = append(refs, &ruleRef{Config: cfg.Path, Group: group, Index: i + 1, rule: r})
 		}
-		// Enforce each rule in order.
-		for _, rule := range rules {
-			switch result, err := enforceRule(rule, dep); {
+	}
+	return refs
+}
+
+func checkDep(pkg, dep *build.Package, mode checkMode) (decision, error) {
+	d := decision{Src: pkg, Dst: dep, Mode: mode}
+	it := newConfigIter(pkg)
+	for it.Advance() {
+		// Enforce each rule from this config in order.
+		for _, ref := range rulesForMode(it.Value(), mode) {
+			switch result, err := enforceRule(ref.rule, dep); {
 			case err != nil:
-				return nil, err
+				return decision{}, err
 			case result == resultApproved:
-				return nil, nil
+				d.Rule = ref
+				return d, nil
 			case result == resultRejected:
-				err := fmt.Errorf(`violates %s deny rule %q in %s`, mode, rule.Pattern(), cfg.Path)
-				return &violation{pkg, dep, err}, nil
+				d.Rule = ref
+				d.Err = fmt.Errorf("violates %v", ref)
+				if violation := ref.Violation(dep); violation != "" {
+					d.Err = fmt.Errorf("violates %v: %s", ref, violation)
+				}
+				return d, nil
 			}
 		}
 	}
 	if err := it.Err(); err != nil {
-		return nil, err
+		return decision{}, err
 	}
 	// All config files have been checked without an approved or rejected result;
 	// treat this as an approved result.  This also handles the case where no
 	// config files have been specified.
-	return nil, nil
+	return d, nil
 }
 
-func checkDeps(pkg *build.Package) ([]violation, error) {
-	var violations []violation
+// importPos returns the position of the import of the given path in the
+// given package, considering the imports of the given mode.
+func importPos(pkg *build.Package, path string, mode checkMode) token.Position {
+	posMaps := []map[string][]token.Position{pkg.ImportPos}
+	if mode == modeTest || mode == modeXTest {
+		posMaps = append(posMaps, pkg.TestImportPos)
+	}
+	if mode == modeXTest {
+		posMaps = append(posMaps, pkg.XTestImportPos)
+	}
+	for _, posMap := range posMaps {
+		if positions := posMap[path]; len(positions) > 0 {
+			return positions[0]
+		}
+	}
+	return token.Position{}
+}
+
+// importVia returns a map from the dependencies of pkg to the direct
+// imports of pkg through which they are (first) reached.
+func importVia(pkg *build.Package, opts depOpts) (map[string]string, error) {
+	via := map[string]string{}
+	queue := []string{}
+	for _, path := range opts.Paths(pkg) {
+		if _, ok := via[path]; !ok {
+			via[path] = path
+			queue = append(queue, path)
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		dep, err := importPackage(path)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.IncludeGoroot && dep.Goroot {
+			continue
+		}
+		for _, imp := range dep.Imports {
+			if _, ok := via[imp]; !ok {
+				via[imp] = via[path]
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return via, nil
+}
+
+// maxTransitiveDeps returns the limit on the number of transitive
+// dependencies of the given package set by the deepmost .godepcop file
+// that sets one, along with the path of that file.  The limit is zero if
+// no file sets one.
+func maxTransitiveDeps(pkg *build.Package) (int, string, error) {
+	it := newConfigIter(pkg)
+	for it.Advance() {
+		if cfg := it.Value(); cfg.MaxTransitiveDeps > 0 {
+			return cfg.MaxTransitiveDeps, cfg.Path, nil
+		}
+	}
+	return 0, "", it.Err()
+}
+
+// maxListedImports is the number of heaviest imports listed when a package
+// exceeds its transitive dependency limit.
+const maxListedImports = 5
+
+// importWeight records the number of transitive non-GOROOT dependencies
+// a direct import brings in, including the import itself.
+type importWeight struct {
+	Path string
+	Deps int
+}
+
+// heaviestImports returns the direct non-GOROOT imports of pkg ordered by
+// decreasing number of transitive dependencies.
+func heaviestImports(pkg *build.Package) ([]importWeight, error) {
+	var weights []importWeight
+	for _, path := range pkg.Imports {
+		deps := make(map[string]*build.Package)
+		if err := (depOpts{}).depsHelper([]string{path}, deps); err != nil {
+			return nil, err
+		}
+		if len(deps) > 0 {
+			weights = append(weights, importWeight{path, len(deps)})
+		}
+	}
+	sort.Sort(importWeights(weights))
+	return weights, nil
+}
+
+type importWeights []importWeight
+
+func (w importWeights) Len() int      { return len(w) }
+func (w importWeights) Swap(i, j int) { w[i], w[j] = w[j], w[i] }
+func (w importWeights) Less(i, j int) bool {
+	if w[i].Deps != w[j].Deps {
+		return w[i].Deps > w[j].Deps
+	}
+	return w[i].Path < w[j].Path
+}
+
+// checkDepLimit checks the number of transitive non-GOROOT dependencies of
+// the given package against the limit set in the .godepcop files.  If the
+// limit is exceeded, it returns a violation that blames the heaviest
+// direct import and lists the other heavy imports.
+func checkDepLimit(pkg *build.Package) (*decision, error) {
+	limit, path, err := maxTransitiveDeps(pkg)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+	deps := make(map[string]*build.Package)
+	if err := (depOpts{}).Deps(pkg, deps); err != nil {
+		return nil, err
+	}
+	if len(deps) <= limit {
+		return nil, nil
+	}
+	weights, err := heaviestImports(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if len(weights) > maxListedImports {
+		weights = weights[:maxListedImports]
+	}
+	var heaviest []string
+	for _, w := range weights {
+		heaviest = append(heaviest, fmt.Sprintf("%q (%d)", w.Path, w.Deps))
+	}
+	dst, err := importPackage(weights[0].Path)
+	if err != nil {
+		return nil, err
+	}
+	return &decision{
+		Src:   pkg,
+		Dst:   dst,
+		Err:   fmt.Errorf("has %d transitive dependencies, exceeding maxtransitivedeps %d in %s; heaviest imports: %s", len(deps), limit, path, strings.Join(heaviest, ", ")),
+		Mode:  modePkg,
+		Via:   dst.ImportPath,
+		Pos:   importPos(pkg, dst.ImportPath, modePkg),
+		Limit: &depLimit{Max: limit, Deps: len(deps)},
+	}, nil
+}
+
+// checkDeps checks the dependencies of the given package, returning the
+// rejected and the approved decisions.
+func checkDeps(pkg *build.Package) ([]decision, []decision, error) {
+	var violations, approvals []decision
 	// First check direct dependencies against the Go 1.5 internal package rule.
 	optsDirect := depOpts{DirectOnly: true, IncludeGoroot: true, IncludeTest: true, IncludeXTest: true}
 	depsDirect := make(map[string]*build.Package)
 	if err := optsDirect.Deps(pkg, depsDirect); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, dep := range sortPackages(depsDirect) {
 		if !verifyGo15InternalRule(pkg.ImportPath, dep.ImportPath) {
-			violations = append(violations, violation{pkg, dep, errGo15Internal})
+			violations = append(violations, decision{
+				Src:  pkg,
+				Dst:  dep,
+				Err:  errGo15Internal,
+				Mode: modeXTest,
+				Via:  dep.ImportPath,
+				Pos:  importPos(pkg, dep.ImportPath, modeXTest),
+			})
 		}
 	}
 	// Now check transitive dependencies against the rules in .godepcop files.
@@ -146,19 +369,36 @@ func checkDeps(pkg *build.Package) ([]violation, error) {
 		}
 		deps := make(map[string]*build.Package)
 		if err := opts.Deps(pkg, deps); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		via, err := importVia(pkg, opts)
+		if err != nil {
+			return nil, nil, err
 		}
 		for _, dep := range sortPackages(deps) {
-			v, err := checkDep(pkg, dep, mode)
+			d, err := checkDep(pkg, dep, mode)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			if v != nil {
-				violations = append(violations, *v)
+			d.Via = via[dep.ImportPath]
+			d.Pos = importPos(pkg, d.Via, mode)
+			if d.Err != nil {
+				violations = append(violations, d)
+			} else {
+				approvals = append(approvals, d)
 			}
 		}
 	}
-	return violations, nil
+	// Finally check the number of transitive dependencies against the limit
+	// in .godepcop files.
+	d, err := checkDepLimit(pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d != nil {
+		violations = append(violations, *d)
+	}
+	return violations, approvals, nil
 }
 
 type checkMode int
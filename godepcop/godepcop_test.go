@@ -6,6 +6,9 @@ package main
 
 import (
 	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -17,6 +20,20 @@ func pkgGoroot(path string) *build.Package {
 	p.Goroot = true
 	return p
 }
+func pkgCgo(path string) *build.Package {
+	p := pkg(path)
+	p.CgoFiles = []string{"cgo.go"}
+	return p
+}
+func pkgTags(path string, tags ...string) *build.Package {
+	p := pkg(path)
+	p.AllTags = tags
+	return p
+}
+func restrict(r rule, cgo bool, tags string) rule {
+	r.DenyCgo, r.DenyTags = cgo, tags
+	return r
+}
 
 func TestEnforceRule(t *testing.T) {
 	tests := []struct {
@@ -46,6 +63,15 @@ func TestEnforceRule(t *testing.T) {
 		{allow("foo/..."), pkg("foo/a/b/c"), resultApproved},
 		{allow("foo/..."), pkg("bar"), resultUndecided},
 		{allow("foo/..."), pkg("bar/foo"), resultUndecided},
+
+		{restrict(allow("..."), true, ""), pkg("foo"), resultApproved},
+		{restrict(allow("..."), true, ""), pkgCgo("foo"), resultRejected},
+		{restrict(allow("..."), true, ""), pkgGoroot("net"), resultUndecided},
+		{restrict(allow("foo/..."), true, ""), pkgCgo("foo/a"), resultRejected},
+		{restrict(allow("foo/..."), true, ""), pkgCgo("bar"), resultUndecided},
+		{restrict(allow("foo"), false, "sqlite, leveldb"), pkgTags("foo", "linux", "leveldb"), resultRejected},
+		{restrict(allow("foo"), false, "sqlite,leveldb"), pkgTags("foo", "linux"), resultApproved},
+		{restrict(allow("foo"), false, "sqlite"), pkgCgo("foo"), resultApproved},
 	}
 	for _, test := range tests {
 		result, err := enforceRule(test.rule, test.pkg)
@@ -157,7 +183,7 @@ func TestCheckDeps(t *testing.T) {
 			t.Errorf("%s error loading package: %v", test.name, err)
 			continue
 		}
-		v, err := checkDeps(p)
+		v, _, err := checkDeps(p)
 		if err != nil {
 			t.Errorf("%s failed: %v", test.name, err)
 			continue
@@ -167,3 +193,94 @@ func TestCheckDeps(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckDepsProvenance(t *testing.T) {
+	p, err := importPackage("v.io/x/devtools/godepcop/testdata/test-b")
+	if err != nil {
+		t.Fatalf("error loading package: %v", err)
+	}
+	violations, _, err := checkDeps(p)
+	if err != nil {
+		t.Fatalf("checkDeps failed: %v", err)
+	}
+	var got *decision
+	for i, v := range violations {
+		if v.Dst.ImportPath == "fmt" && v.Mode == modePkg {
+			got = &violations[i]
+		}
+	}
+	if got == nil {
+		t.Fatalf("no pkg violation for fmt in %v", violations)
+	}
+	if got.Rule == nil {
+		t.Fatalf("violation has no rule: %v", got)
+	}
+	if got, want := got.Rule.Config, filepath.Join(p.Dir, ".godepcop"); got != want {
+		t.Errorf("got config %q, want %q", got, want)
+	}
+	if got, want := got.Rule.Index, 1; got != want {
+		t.Errorf("got rule index %d, want %d", got, want)
+	}
+	if got, want := got.Rule.Pattern(), "fmt"; got != want {
+		t.Errorf("got pattern %q, want %q", got, want)
+	}
+	if got, want := got.Via, "fmt"; got != want {
+		t.Errorf("got via %q, want %q", got, want)
+	}
+	if !got.Pos.IsValid() || filepath.Dir(got.Pos.Filename) != p.Dir {
+		t.Errorf("got position %v, want a position in %s", got.Pos, p.Dir)
+	}
+}
+
+func TestCheckDepLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godepcop")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configs := map[string]string{
+		"limit":    `<godepcop maxtransitivedeps="2"><pkg allow="..."/></godepcop>`,
+		"limit/ok": `<godepcop maxtransitivedeps="3"/>`,
+	}
+	for path, data := range configs {
+		if err := os.MkdirAll(filepath.Join(dir, path), os.ModePerm); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, path, configFileName), []byte(data), os.ModePerm); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	// Populate the package cache with a fake dependency graph, where
+	// limit/a brings in two dependencies and limit/b brings in one.
+	for _, p := range []*build.Package{
+		pkgGoroot("limit-fmt"),
+		{ImportPath: "limit/a", Imports: []string{"limit-fmt", "limit/c"}},
+		{ImportPath: "limit/b"},
+		{ImportPath: "limit/c"},
+	} {
+		pkgCache[p.ImportPath] = p
+		defer delete(pkgCache, p.ImportPath)
+	}
+	imports := []string{"limit-fmt", "limit/a", "limit/b"}
+	top := &build.Package{ImportPath: "limit/top", Dir: filepath.Join(dir, "limit", "top"), Imports: imports}
+	ok := &build.Package{ImportPath: "limit/ok", Dir: filepath.Join(dir, "limit", "ok"), Imports: imports}
+
+	d, err := checkDepLimit(top)
+	if err != nil {
+		t.Fatalf("checkDepLimit failed: %v", err)
+	}
+	if d == nil {
+		t.Fatalf("checkDepLimit didn't report a violation")
+	}
+	if got, want := d.Dst.ImportPath, "limit/a"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := `has 3 transitive dependencies, exceeding maxtransitivedeps 2 in ` + filepath.Join(dir, "limit", configFileName) + `; heaviest imports: "limit/a" (2), "limit/b" (1)`
+	if got := d.Err.Error(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The deepmost limit applies.
+	if d, err := checkDepLimit(ok); err != nil || d != nil {
+		t.Errorf("got (%v, %v), want (nil, nil)", d, err)
+	}
+}
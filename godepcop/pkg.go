@@ -35,6 +35,17 @@ func listPackagePaths(env *cmdline.Env, args ...string) ([]string, error) {
 	return goutil.List(jirix, []string{"--merge-policies=" + mergePoliciesFlag.String()}, args...)
 }
 
+// listReverseDeps returns the packages among all known packages that
+// transitively import any of the given packages, either directly or
+// through their tests.
+func listReverseDeps(env *cmdline.Env, pkgs []string) ([]string, error) {
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return nil, err
+	}
+	return goutil.ReverseDeps(jirix, []string{"--merge-policies=" + mergePoliciesFlag.String()}, pkgs, []string{"all"})
+}
+
 // importPackage loads and returns the package with the given package path.
 func importPackage(path string) (*build.Package, error) {
 	if p, ok := pkgCache[path]; ok {
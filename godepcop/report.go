@@ -0,0 +1,235 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatSARIF = "sarif"
+)
+
+// printDecisions prints the given decisions to w in the given format.
+func printDecisions(w io.Writer, format string, decisions []decision) error {
+	switch format {
+	case formatText:
+		printText(w, decisions)
+		return nil
+	case formatJSON:
+		return printJSON(w, decisions)
+	case formatSARIF:
+		return printSARIF(w, decisions)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// location returns the "file:line" location of the import that produced the
+// decision, or the source package directory if the location is unknown.
+func (d decision) location() string {
+	if !d.Pos.IsValid() {
+		return d.Src.Dir
+	}
+	return fmt.Sprintf("%s:%d", d.Pos.Filename, d.Pos.Line)
+}
+
+// String returns a human-readable description of the decision.
+func (d decision) String() string {
+	verb := "allowed"
+	if d.Err != nil {
+		verb = "not allowed"
+	}
+	s := fmt.Sprintf("%s: %q %s to import %q", d.location(), d.Src.ImportPath, verb, d.Dst.ImportPath)
+	if d.Via != "" && d.Via != d.Dst.ImportPath {
+		s += fmt.Sprintf(" via %q", d.Via)
+	}
+	switch {
+	case d.Err != nil:
+		s += fmt.Sprintf(" (%v)", d.Err)
+	case d.Rule != nil:
+		s += fmt.Sprintf(" (matches %v)", d.Rule)
+	default:
+		s += " (no matching rule)"
+	}
+	return s
+}
+
+func printText(w io.Writer, decisions []decision) {
+	for _, d := range decisions {
+		fmt.Fprintln(w, d)
+	}
+}
+
+// jsonDecision is the JSON encoding of a decision.
+type jsonDecision struct {
+	Package string    `json:"package"`
+	Import  string    `json:"import"`
+	Via     string    `json:"via,omitempty"`
+	Mode    string    `json:"mode"`
+	Allowed bool      `json:"allowed"`
+	Error   string    `json:"error,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Rule    *jsonRule `json:"rule,omitempty"`
+}
+
+// jsonRule is the JSON encoding of a ruleRef.
+type jsonRule struct {
+	Config   string   `json:"config"`
+	Group    string   `json:"group"`
+	Index    int      `json:"index"`
+	Kind     string   `json:"kind"`
+	Pattern  string   `json:"pattern"`
+	DenyCgo  bool     `json:"denyCgo,omitempty"`
+	DenyTags []string `json:"denyTags,omitempty"`
+}
+
+func newJSONDecision(d decision) jsonDecision {
+	jd := jsonDecision{
+		Package: d.Src.ImportPath,
+		Import:  d.Dst.ImportPath,
+		Via:     d.Via,
+		Mode:    d.Mode.String(),
+		Allowed: d.Err == nil,
+		File:    d.Pos.Filename,
+		Line:    d.Pos.Line,
+		Column:  d.Pos.Column,
+	}
+	if d.Err != nil {
+		jd.Error = d.Err.Error()
+	}
+	if r := d.Rule; r != nil {
+		kind := "allow"
+		if r.IsDeny() {
+			kind = "deny"
+		}
+		jd.Rule = &jsonRule{
+			Config:   r.Config,
+			Group:    r.Group.String(),
+			Index:    r.Index,
+			Kind:     kind,
+			Pattern:  r.Pattern(),
+			DenyCgo:  r.DenyCgo,
+			DenyTags: r.DeniedTags(),
+		}
+	}
+	return jd
+}
+
+func printJSON(w io.Writer, decisions []decision) error {
+	out := []jsonDecision{}
+	for _, d := range decisions {
+		out = append(out, newJSONDecision(d))
+	}
+	bytes, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent() failed: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", bytes)
+	return err
+}
+
+// The following types describe the subset of the SARIF 2.1.0 format
+// (https://sarifweb.azurewebsites.net) produced by godepcop.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties jsonDecision    `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func newSARIFResult(d decision) sarifResult {
+	res := sarifResult{
+		RuleID:     "godepcop/" + d.Mode.String(),
+		Level:      "note",
+		Message:    sarifMessage{d.String()},
+		Properties: newJSONDecision(d),
+	}
+	if d.Err != nil {
+		res.Level = "error"
+	}
+	if d.Err == errGo15Internal {
+		res.RuleID = "godepcop/internal"
+	}
+	if d.Pos.IsValid() {
+		res.Locations = []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.Pos.Filename)},
+				Region:           &sarifRegion{StartLine: d.Pos.Line, StartColumn: d.Pos.Column},
+			},
+		}}
+	}
+	return res
+}
+
+func printSARIF(w io.Writer, decisions []decision) error {
+	run := sarifRun{
+		Tool:    sarifTool{sarifDriver{Name: "godepcop"}},
+		Results: []sarifResult{},
+	}
+	for _, d := range decisions {
+		run.Results = append(run.Results, newSARIFResult(d))
+	}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+	bytes, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent() failed: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", bytes)
+	return err
+}
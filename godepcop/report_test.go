@@ -0,0 +1,100 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func testDecisions() []decision {
+	return []decision{
+		{
+			Src:  pkg("a"),
+			Dst:  pkg("c"),
+			Err:  errors.New(`violates pkg deny rule #2 "c" in a/.godepcop`),
+			Mode: modePkg,
+			Rule: &ruleRef{Config: "a/.godepcop", Group: modePkg, Index: 2, rule: deny("c")},
+			Via:  "b",
+			Pos:  token.Position{Filename: "a/a.go", Line: 7, Column: 2},
+		},
+		{
+			Src:  pkg("a"),
+			Dst:  pkg("b"),
+			Mode: modePkg,
+			Rule: &ruleRef{Config: "a/.godepcop", Group: modePkg, Index: 1, rule: allow("b")},
+			Via:  "b",
+			Pos:  token.Position{Filename: "a/a.go", Line: 7, Column: 2},
+		},
+	}
+}
+
+func TestPrintText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printDecisions(&buf, formatText, testDecisions()); err != nil {
+		t.Fatalf("printDecisions failed: %v", err)
+	}
+	want := `a/a.go:7: "a" not allowed to import "c" via "b" (violates pkg deny rule #2 "c" in a/.godepcop)
+a/a.go:7: "a" allowed to import "b" (matches pkg allow rule #1 "b" in a/.godepcop)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printDecisions(&buf, formatJSON, testDecisions()); err != nil {
+		t.Fatalf("printDecisions failed: %v", err)
+	}
+	var got []jsonDecision
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d decisions, want 2", len(got))
+	}
+	want := jsonRule{Config: "a/.godepcop", Group: "pkg", Index: 2, Kind: "deny", Pattern: "c"}
+	if got[0].Allowed || got[0].Rule == nil || !reflect.DeepEqual(*got[0].Rule, want) {
+		t.Errorf("got %+v, want rejected decision with rule %+v", got[0], want)
+	}
+	if got, want := got[0].Line, 7; got != want {
+		t.Errorf("got line %d, want %d", got, want)
+	}
+	if !got[1].Allowed {
+		t.Errorf("got %+v, want allowed decision", got[1])
+	}
+}
+
+func TestPrintSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printDecisions(&buf, formatSARIF, testDecisions()[:1]); err != nil {
+		t.Fatalf("printDecisions failed: %v", err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(got.Runs) != 1 || len(got.Runs[0].Results) != 1 {
+		t.Fatalf("got %+v, want a single run with a single result", got)
+	}
+	res := got.Runs[0].Results[0]
+	if got, want := res.Level, "error"; got != want {
+		t.Errorf("got level %q, want %q", got, want)
+	}
+	if len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.ArtifactLocation.URI != "a/a.go" {
+		t.Errorf("got locations %+v, want a/a.go", res.Locations)
+	}
+}
+
+func TestPrintUnknownFormat(t *testing.T) {
+	if err := printDecisions(&bytes.Buffer{}, "xml", nil); err == nil {
+		t.Errorf("printDecisions with unknown format didn't fail")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"v.io/jiri"
+	"v.io/x/lib/cmdline"
+)
+
+var jsonFlag bool
+
+func init() {
+	cmdAudit.Flags.StringVar(&interfacesFlag, "interface", "", "Comma-separated list of interface packages (required).")
+	cmdAudit.Flags.StringVar(&injectCallFlag, "call", apilogCall, "The function call to be checked for as defer <pkg>.<call>()() and defer <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.")
+	cmdAudit.Flags.StringVar(&injectCallImportFlag, "import", apilogImport, "Import path for the injected call.")
+	cmdAudit.Flags.BoolVar(&jsonFlag, "json", false, "Print the suppressions in the JSON format.")
+}
+
+// cmdAudit represents the 'audit' command of the gologcop tool.
+var cmdAudit = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runAudit),
+	Name:   "audit",
+	Short:  "List the log statement suppressions",
+	Long: `
+List the "nologcall" comments that suppress the checking and injection of log
+statements in <packages>.
+
+For each suppression, audit prints its position, the enclosing method and
+whether the method implements an exported method of the interfaces declared in
+the packages passed to the -interface flag, which is when it would otherwise
+require a log statement. Suppressions in methods that do not require a log
+statement, or that have one anyway, are reported as stale.
+`,
+	ArgsName: "<packages>",
+	ArgsLong: "<packages> is the list of packages to be audited.",
+}
+
+// suppression describes a "nologcall" comment.
+type suppression struct {
+	Package         string `json:"package"`
+	File            string `json:"file"`
+	Line            int    `json:"line"`
+	Method          string `json:"method"`
+	RequiresLogCall bool   `json:"requiresLogCall"`
+	HasLogCall      bool   `json:"hasLogCall"`
+	Stale           bool   `json:"stale"`
+}
+
+// String returns a human-readable description of the suppression.
+func (s suppression) String() string {
+	status := "suppresses a required log call"
+	switch {
+	case !s.RequiresLogCall:
+		status = "stale, the method does not require a log call"
+	case s.HasLogCall:
+		status = "stale, the method has a log call"
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", s.File, s.Line, s.Method, status)
+}
+
+// runAudit handles the "audit" command.
+func runAudit(jirix *jiri.X, args []string) error {
+	interfaceList := splitCommaSeparatedValues(interfacesFlag)
+	if len(interfaceList) == 0 {
+		return jirix.UsageErrorf("no interface packages listed")
+	}
+	if len(args) == 0 {
+		return jirix.UsageErrorf("no implementation package listed")
+	}
+	suppressions, err := runAuditor(jirix, nil, interfaceList, args)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		data, err := json.MarshalIndent(suppressions, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent() failed: %v", err)
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+		return nil
+	}
+	stale := 0
+	for _, s := range suppressions {
+		fmt.Fprintf(jirix.Stdout(), "%v\n", s)
+		if s.Stale {
+			stale++
+		}
+	}
+	fmt.Fprintf(jirix.Stdout(), "%d suppressions, %d stale\n", len(suppressions), stale)
+	return nil
+}
+
+// runAuditor returns the suppressions in the given implementation
+// packages, given the interface packages whose implementations require
+// log calls.
+func runAuditor(jirix *jiri.X, goFlags, interfaceList, implementationList []string) ([]suppression, error) {
+	if err := initInjectorFlags(); err != nil {
+		return nil, err
+	}
+	ifcs, err := importPkgs(jirix, goFlags, interfaceList)
+	if err != nil {
+		return nil, err
+	}
+	impls, err := importPkgs(jirix, goFlags, implementationList)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := newState(jirix)
+	printHeader(jirix.Stdout(), "Parsing and Type Checking Interface Packages")
+	ifcPkgs := []*types.Package{}
+	for _, ifc := range ifcs {
+		_, tpkg, err := ps.parseAndTypeCheckPackage(ifc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse+type check: %s: %s", ifc.ImportPath, err)
+		}
+		ifcPkgs = append(ifcPkgs, tpkg)
+	}
+	publicInterfaces := findPublicInterfaces(jirix, ifcPkgs)
+
+	suppressions := []suppression{}
+	for _, impl := range impls {
+		printHeader(jirix.Stdout(), "Parsing and Type Checking Implementation Packages")
+		asts, tpkg, err := ps.parseAndTypeCheckPackage(impl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse+type check: %s: %s", impl.ImportPath, err)
+		}
+		required := findMethodsImplementing(jirix, ps.fset, tpkg, publicInterfaces)
+		suppressions = append(suppressions, findSuppressions(ps.fset, impl.ImportPath, asts, required)...)
+	}
+	return suppressions, nil
+}
+
+// findSuppressions returns the suppressions in the functions declared
+// in the given files of package pkg, given the positions of the names
+// of the methods that require log calls.
+func findSuppressions(fset *token.FileSet, pkg string, files []*ast.File, required map[token.Pos]struct{}) []suppression {
+	suppressions := []suppression{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Body == nil {
+				continue
+			}
+			ref := funcDeclRef{Decl: decl, File: file}
+			comment := noLogComment(ref)
+			if comment == nil {
+				continue
+			}
+			pos := fset.Position(comment.Pos())
+			_, requiresLogCall := required[decl.Name.Pos()]
+			hasLogCall := validateLogStatement(decl, injectPackage, injectCall) == nil
+			suppressions = append(suppressions, suppression{
+				Package:         pkg,
+				File:            pos.Filename,
+				Line:            pos.Line,
+				Method:          funcDeclName(decl),
+				RequiresLogCall: requiresLogCall,
+				HasLogCall:      hasLogCall,
+				Stale:           !requiresLogCall || hasLogCall,
+			})
+		}
+	}
+	return suppressions
+}
+
+// funcDeclName returns the name of the given function declaration,
+// qualified by its receiver type if it is a method, as in "(*T).M".
+func funcDeclName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	switch recv := decl.Recv.List[0].Type.(type) {
+	case *ast.StarExpr:
+		if ident, ok := recv.X.(*ast.Ident); ok {
+			return fmt.Sprintf("(*%s).%s", ident.Name, decl.Name.Name)
+		}
+	case *ast.Ident:
+		return fmt.Sprintf("%s.%s", recv.Name, decl.Name.Name)
+	}
+	return decl.Name.Name
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestFindSuppressions(t *testing.T) {
+	savedPackage, savedCall, savedContextFlag := injectPackage, injectCall, useContextFlag
+	defer func() {
+		injectPackage, injectCall, useContextFlag = savedPackage, savedCall, savedContextFlag
+	}()
+	injectPackage, injectCall, useContextFlag = "apilog", "LogCall", false
+
+	src := `package p
+
+type T struct{}
+
+func (T) Required() {
+	// nologcall
+}
+
+func (*T) Logged() {
+	//nologcall
+	defer apilog.LogCall()()
+}
+
+func (*T) NotRequired() {
+	/* nologcall */
+	println()
+}
+
+func (T) Misplaced() {
+	println()
+	//nologcall
+}
+
+func Func() {
+	// Not logged.
+	//nologcall
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	required := map[token.Pos]struct{}{}
+	for _, decl := range file.Decls {
+		if decl, ok := decl.(*ast.FuncDecl); ok && (decl.Name.Name == "Required" || decl.Name.Name == "Logged") {
+			required[decl.Name.Pos()] = exists
+		}
+	}
+	got := findSuppressions(fset, "p", []*ast.File{file}, required)
+	want := []suppression{
+		{Package: "p", File: "p.go", Line: 6, Method: "T.Required", RequiresLogCall: true},
+		{Package: "p", File: "p.go", Line: 10, Method: "(*T).Logged", RequiresLogCall: true, HasLogCall: true, Stale: true},
+		{Package: "p", File: "p.go", Line: 15, Method: "(*T).NotRequired", Stale: true},
+		{Package: "p", File: "p.go", Line: 26, Method: "Func", Stale: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := want[0].String(), "p.go:6: T.Required: suppresses a required log call"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/project"
+)
+
+// changedFiles returns the list of changed files and packages to
+// restrict checking to. The list comes from the -changed flag or, if
+// the flag is not set, from the files that the jiri projects change
+// relative to the -changed-base revision.
+func changedFiles(jirix *jiri.X) ([]string, error) {
+	if changed := splitCommaSeparatedValues(changedFlag); len(changed) > 0 {
+		return changed, nil
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, p := range projects {
+		var out bytes.Buffer
+		if err := jirix.NewSeq().Dir(p.Path).Capture(&out, jirix.Stderr()).Last("git", "diff", "--name-only", changedBaseFlag); err != nil {
+			return nil, err
+		}
+		for _, file := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if file != "" {
+				files = append(files, filepath.Join(p.Path, file))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// changedPackages returns the import paths of the given packages that
+// are changed according to the given list, whose elements are either
+// import paths of packages or paths of files in their directories.
+func changedPackages(changed []string, bpkgs []*build.Package) map[string]bool {
+	paths, dirs := map[string]bool{}, map[string]bool{}
+	for _, c := range changed {
+		paths[c] = true
+		if abs, err := filepath.Abs(c); err == nil {
+			dirs[filepath.Dir(abs)] = true
+		}
+	}
+	pkgs := map[string]bool{}
+	for _, bpkg := range bpkgs {
+		if paths[bpkg.ImportPath] || dirs[bpkg.Dir] {
+			pkgs[bpkg.ImportPath] = true
+		}
+	}
+	return pkgs
+}
+
+// changedImplementations returns the implementation packages that need
+// to be checked when the given packages change: the implementation
+// packages that changed themselves, and those that depend on a changed
+// interface package, as the set of methods that need logging statements
+// may have changed for them. The given function is used to import the
+// dependencies of the implementation packages.
+func changedImplementations(ifcs, impls []*build.Package, changed map[string]bool, importer func(string) (*build.Package, error)) ([]*build.Package, error) {
+	changedIfcs := map[string]bool{}
+	for _, ifc := range ifcs {
+		if changed[ifc.ImportPath] {
+			changedIfcs[ifc.ImportPath] = true
+		}
+	}
+	memo := map[string]bool{}
+	// dependsOnChangedIfcs returns whether the given package
+	// transitively imports one of the changed interface packages.
+	var dependsOnChangedIfcs func(bpkg *build.Package) (bool, error)
+	dependsOnChangedIfcs = func(bpkg *build.Package) (bool, error) {
+		for _, path := range bpkg.Imports {
+			if changedIfcs[path] {
+				return true, nil
+			}
+			depends, ok := memo[path]
+			if !ok {
+				if path == "C" || path == "unsafe" {
+					continue
+				}
+				dep, err := importer(path)
+				if err != nil {
+					return false, err
+				}
+				if !dep.Goroot {
+					if depends, err = dependsOnChangedIfcs(dep); err != nil {
+						return false, err
+					}
+				}
+				memo[path] = depends
+			}
+			if depends {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	result := []*build.Package{}
+	for _, impl := range impls {
+		if changed[impl.ImportPath] {
+			result = append(result, impl)
+			continue
+		}
+		if len(changedIfcs) == 0 {
+			continue
+		}
+		depends, err := dependsOnChangedIfcs(impl)
+		if err != nil {
+			return nil, err
+		}
+		if depends {
+			result = append(result, impl)
+		}
+	}
+	return result, nil
+}
+
+// importDependency imports the given dependency of an implementation
+// package.
+func importDependency(path string) (*build.Package, error) {
+	return build.Default.Import(path, ".", build.ImportMode(build.ImportComment))
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"reflect"
+	"testing"
+)
+
+func TestChangedImplementations(t *testing.T) {
+	pkgs := map[string]*build.Package{
+		"v.io/v23/rpc":       {ImportPath: "v.io/v23/rpc", Dir: "/src/v.io/v23/rpc", Imports: []string{"fmt"}},
+		"v.io/v23/security":  {ImportPath: "v.io/v23/security", Dir: "/src/v.io/v23/security"},
+		"v.io/x/ref/lib/aux": {ImportPath: "v.io/x/ref/lib/aux", Dir: "/src/v.io/x/ref/lib/aux", Imports: []string{"v.io/v23/rpc"}},
+		"v.io/x/ref/rpc":     {ImportPath: "v.io/x/ref/rpc", Dir: "/src/v.io/x/ref/rpc", Imports: []string{"fmt", "v.io/x/ref/lib/aux"}},
+		"v.io/x/ref/sec":     {ImportPath: "v.io/x/ref/sec", Dir: "/src/v.io/x/ref/sec", Imports: []string{"v.io/v23/security"}},
+		"fmt":                {ImportPath: "fmt", Dir: "/goroot/src/fmt", Goroot: true},
+	}
+	importer := func(path string) (*build.Package, error) {
+		if pkg, ok := pkgs[path]; ok {
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("package %q not found", path)
+	}
+	ifcs := []*build.Package{pkgs["v.io/v23/rpc"], pkgs["v.io/v23/security"]}
+	impls := []*build.Package{pkgs["v.io/x/ref/rpc"], pkgs["v.io/x/ref/sec"]}
+	all := append(append([]*build.Package{}, ifcs...), impls...)
+
+	tests := []struct {
+		changed []string
+		want    []string
+	}{
+		{[]string{"/src/v.io/x/ref/sec/sec.go"}, []string{"v.io/x/ref/sec"}},
+		{[]string{"v.io/x/ref/rpc"}, []string{"v.io/x/ref/rpc"}},
+		{[]string{"/src/v.io/v23/rpc/model.go"}, []string{"v.io/x/ref/rpc"}},
+		{[]string{"v.io/v23/security", "v.io/v23/rpc"}, []string{"v.io/x/ref/rpc", "v.io/x/ref/sec"}},
+		{[]string{"/src/v.io/x/other/other.go"}, []string{}},
+	}
+	for _, test := range tests {
+		result, err := changedImplementations(ifcs, impls, changedPackages(test.changed, all), importer)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		got := []string{}
+		for _, pkg := range result {
+			got = append(got, pkg.ImportPath)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: want %v, got %v", test.changed, test.want, got)
+		}
+	}
+
+	pkgs["v.io/x/ref/rpc"].Imports = append(pkgs["v.io/x/ref/rpc"].Imports, "v.io/x/missing")
+	if _, err := changedImplementations(ifcs, impls, map[string]bool{"v.io/v23/security": true}, importer); err == nil {
+		t.Errorf("failure to import a dependency was not reported")
+	}
+}
@@ -23,10 +23,14 @@ func main() {
 
 var (
 	interfacesFlag       string
+	changedOnlyFlag      bool
+	changedFlag          string
+	changedBaseFlag      string
 	progressFlag         bool
 	gofmtFlag            bool
 	diffOnlyFlag         bool
 	useContextFlag       bool
+	logArgsFlag          bool
 	removeCallFlag       string
 	injectCallFlag       string
 	injectCallImportFlag string
@@ -44,12 +48,16 @@ func init() {
 
 	cmdCheck.Flags.StringVar(&injectCallFlag, "call", apilogCall, "The function call to be checked for as defer <pkg>.<call>()() and defer <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.")
 	cmdCheck.Flags.StringVar(&injectCallImportFlag, "import", apilogImport, "Import path for the injected call.")
+	cmdCheck.Flags.BoolVar(&changedOnlyFlag, "changed-only", false, "Only check the changed implementation packages and the implementation packages that depend on changed interface packages.")
+	cmdCheck.Flags.StringVar(&changedFlag, "changed", "", "Comma-separated list of changed files or packages used by -changed-only. If not set, the files changed relative to -changed-base in the jiri projects are used.")
+	cmdCheck.Flags.StringVar(&changedBaseFlag, "changed-base", "origin/master", "The git revision that changes are identified relative to if -changed is not set.")
 
 	cmdInject.Flags.StringVar(&interfacesFlag, "interface", "", "Comma-separated list of interface packages (required).")
 	cmdInject.Flags.BoolVar(&gofmtFlag, "gofmt", true, "Automatically run gofmt on the modified files.")
 	cmdInject.Flags.BoolVar(&diffOnlyFlag, "diff-only", false, "Show changes that would be made without actually making them.")
 	cmdInject.Flags.StringVar(&injectCallFlag, "call", apilogCall, "The function call to be injected as defer <pkg>.<call>()() and defer <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.")
 	cmdInject.Flags.StringVar(&injectCallImportFlag, "import", apilogImport, "Import path for the injected call.")
+	cmdInject.Flags.BoolVar(&logArgsFlag, "log-args", false, "Name the unnamed results of the methods, so that the injected calls log the results as well as the parameters. The remove command does not undo the naming.")
 
 	cmdRemove.Flags.BoolVar(&gofmtFlag, "gofmt", true, "Automatically run gofmt on the modified files.")
 	cmdRemove.Flags.BoolVar(&diffOnlyFlag, "diff-only", false, "Show changes that would be made without actually making them.")
@@ -77,20 +85,29 @@ appropriate logging construct.
 When injecting or removing, it modifies the source code to inject or remove
 such logging constructs.
 
+When auditing, it lists the comments that suppress such logging constructs.
+
 LIMITATIONS:
 
 Removal will not automatically remove the package import for the call to
 be removed.
 `,
-	Children: []*cmdline.Command{cmdCheck, cmdInject, cmdRemove},
+	Children: []*cmdline.Command{cmdCheck, cmdInject, cmdRemove, cmdAudit},
 }
 
 // cmdCheck represents the 'check' command of the gologcop tool.
 var cmdCheck = &cmdline.Command{
-	Runner:   jiri.RunnerFunc(runCheck),
-	Name:     "check",
-	Short:    "Check for log statements in public API implementations",
-	Long:     "Check for log statements in public API implementations.",
+	Runner: jiri.RunnerFunc(runCheck),
+	Name:   "check",
+	Short:  "Check for log statements in public API implementations",
+	Long: `
+Check for log statements in public API implementations.
+
+With -changed-only, checking is restricted to the implementation packages that
+are changed and, if interface packages are changed, to the implementation
+packages that depend on them. This avoids type checking all of <packages> when
+only a few of them are affected by a change.
+`,
 	ArgsName: "<packages>",
 	ArgsLong: "<packages> is the list of packages to be checked.",
 }
@@ -15,6 +15,8 @@ appropriate logging construct.
 When injecting or removing, it modifies the source code to inject or remove such
 logging constructs.
 
+When auditing, it lists the comments that suppress such logging constructs.
+
 LIMITATIONS:
 
 Removal will not automatically remove the package import for the call to be
@@ -27,6 +29,7 @@ The gologcop commands are:
    check       Check for log statements in public API implementations
    inject      Inject log statements in public API implementations
    remove      Remove log statements
+   audit       List the log statement suppressions
    help        Display help for commands or topics
 
 The gologcop flags are:
@@ -52,6 +55,11 @@ Gologcop check - Check for log statements in public API implementations
 
 Check for log statements in public API implementations.
 
+With -changed-only, checking is restricted to the implementation packages that
+are changed and, if interface packages are changed, to the implementation
+packages that depend on them. This avoids type checking all of <packages> when
+only a few of them are affected by a change.
+
 Usage:
    gologcop check [flags] <packages>
 
@@ -61,6 +69,16 @@ The gologcop check flags are:
  -call=LogCall
    The function call to be checked for as defer <pkg>.<call>()() and defer
    <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.
+ -changed=
+   Comma-separated list of changed files or packages used by -changed-only. If
+   not set, the files changed relative to -changed-base in the jiri projects are
+   used.
+ -changed-base=origin/master
+   The git revision that changes are identified relative to if -changed is not
+   set.
+ -changed-only=false
+   Only check the changed implementation packages and the implementation
+   packages that depend on changed interface packages.
  -import=v.io/x/ref/lib/apilog
    Import path for the injected call.
  -interface=
@@ -101,6 +119,10 @@ The gologcop inject flags are:
    Import path for the injected call.
  -interface=
    Comma-separated list of interface packages (required).
+ -log-args=false
+   Name the unnamed results of the methods, so that the injected calls log the
+   results as well as the parameters. The remove command does not undo the
+   naming.
 
  -color=true
    Use color to format output.
@@ -147,6 +169,45 @@ The gologcop remove flags are:
  -v=false
    Print verbose output.
 
+Gologcop audit - List the log statement suppressions
+
+List the "nologcall" comments that suppress the checking and injection of log
+statements in <packages>.
+
+For each suppression, audit prints its position, the enclosing method and
+whether the method implements an exported method of the interfaces declared in
+the packages passed to the -interface flag, which is when it would otherwise
+require a log statement. Suppressions in methods that do not require a log
+statement, or that have one anyway, are reported as stale.
+
+Usage:
+   gologcop audit [flags] <packages>
+
+<packages> is the list of packages to be audited.
+
+The gologcop audit flags are:
+ -call=LogCall
+   The function call to be checked for as defer <pkg>.<call>()() and defer
+   <pkg>.<call>f(...)(...). The value of <pkg> is determined from --import.
+ -import=v.io/x/ref/lib/apilog
+   Import path for the injected call.
+ -interface=
+   Comma-separated list of interface packages (required).
+ -json=false
+   Print the suppressions in the JSON format.
+
+ -color=true
+   Use color to format output.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -progress=false
+   Print verbose progress information.
+ -use-v23-context=true
+   Pass a context.T argument (which must be of type v.io/v23/context.T), if
+   available, to the injected call as its first parameter.
+ -v=false
+   Print verbose output.
+
 Gologcop help - Display help for commands or topics
 
 Help with no args displays the usage of the parent command.
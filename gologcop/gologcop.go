@@ -227,6 +227,23 @@ func runInjector(jirix *jiri.X, goFlags, interfaceList, implementationList []str
 	progressMsg(jirix.Stdout(), "%v expands to %d interface packages\n", interfaceList, len(ifcs))
 	progressMsg(jirix.Stdout(), "%v expands to %d implementation packages\n", implementationList, len(impls))
 
+	if checkOnly && changedOnlyFlag {
+		changed, err := changedFiles(jirix)
+		if err != nil {
+			return err
+		}
+		impls, err = changedImplementations(ifcs, impls, changedPackages(changed, append(ifcs, impls...)), importDependency)
+		if err != nil {
+			return err
+		}
+		progressMsg(jirix.Stdout(), "%d implementation packages are affected by the changes\n", len(impls))
+		if len(impls) == 0 {
+			// There is nothing to check, so do not spend time on parsing
+			// and type checking the interface packages.
+			return nil
+		}
+	}
+
 	ps := newState(jirix)
 	checkFailed := []string{}
 
@@ -338,23 +355,42 @@ type funcDeclRef struct {
 	LogCall string
 }
 
-// methodSetVisibleThroughInterfaces returns intersection of all
-// exported method names implemented by t and the union of all method
-// names declared by interfaces.
-func methodSetVisibleThroughInterfaces(t types.Type, interfaces []*types.Interface) map[string]struct{} {
-	set := map[string]struct{}{}
+// methodsImplementingInterfaces returns the methods of t that satisfy the
+// exported methods of the given interfaces implemented by t or *t.  The
+// methods include those declared with pointer receivers and those
+// promoted from embedded fields.  Since only the interfaces that *t
+// satisfies according to types.MissingMethod are considered, methods
+// whose signatures differ from those of the interface methods are never
+// returned.
+func methodsImplementingInterfaces(t types.Type, interfaces []*types.Interface) []*types.Func {
+	// The method set of *t includes the methods of t.
+	ptr := t
+	if _, ok := t.Underlying().(*types.Pointer); !ok {
+		ptr = types.NewPointer(t)
+	}
+	methodSet := types.NewMethodSet(ptr)
+	seen := map[*types.Func]bool{}
+	methods := []*types.Func{}
 	for _, ifc := range interfaces {
-		if types.Implements(t, ifc) || types.Implements(types.NewPointer(t), ifc) {
-			// t implements ifc, so add all the public
-			// method names of ifc to set.
-			for i := 0; i < ifc.NumMethods(); i++ {
-				if name := ifc.Method(i).Name(); ast.IsExported(name) {
-					set[name] = exists
-				}
+		if missing, _ := types.MissingMethod(ptr, ifc, true); missing != nil {
+			continue
+		}
+		for i := 0; i < ifc.NumMethods(); i++ {
+			m := ifc.Method(i)
+			if !m.Exported() {
+				continue
+			}
+			sel := methodSet.Lookup(m.Pkg(), m.Name())
+			if sel == nil {
+				continue
+			}
+			if fn := sel.Obj().(*types.Func); !seen[fn] {
+				seen[fn] = true
+				methods = append(methods, fn)
 			}
 		}
 	}
-	return set
+	return methods
 }
 
 func hasV23Context(info *types.Info, parameters *ast.FieldList) (*ast.FieldList, string) {
@@ -500,6 +536,73 @@ func genCall(info *types.Info, params, results *ast.FieldList) (string, error) {
 	return fmt.Sprintf("\n\tdefer %s.%sf(%s%s)(%s%s) %s", injectPackage, injectCall, contextParArg, pars, contextParRes, res, logCallComment), nil
 }
 
+// resultNames returns the names to give to the results of the given
+// function so that the injected call can log them on deferral, or nil if
+// the results are already named or -log-args is not set.  The names are
+// chosen not to clash with any identifier used by the function.
+func resultNames(decl *ast.FuncDecl) []string {
+	results := decl.Type.Results
+	if !logArgsFlag || results == nil || len(results.List) == 0 {
+		return nil
+	}
+	for _, field := range results.List {
+		if len(field.Names) > 0 {
+			return nil
+		}
+	}
+	used := map[string]bool{}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	for prefix := "r"; ; prefix = "_" + prefix {
+		names, clash := []string{}, false
+		for i := range results.List {
+			name := prefix + strconv.Itoa(i)
+			clash = clash || used[name]
+			names = append(names, name)
+		}
+		if !clash {
+			return names
+		}
+	}
+}
+
+// namedResults returns a copy of the results of the given function with
+// the given names.
+func namedResults(decl *ast.FuncDecl, names []string) *ast.FieldList {
+	if names == nil {
+		return decl.Type.Results
+	}
+	named := *decl.Type.Results
+	named.List = nil
+	for i, field := range decl.Type.Results.List {
+		named.List = append(named.List, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(names[i])},
+			Type:  field.Type,
+		})
+	}
+	return &named
+}
+
+// resultNamePatches returns the patches that name the results of the
+// given function with the given names.
+func resultNamePatches(fset *token.FileSet, decl *ast.FuncDecl, names []string) []patch {
+	results := decl.Type.Results
+	patches := []patch{}
+	for i, field := range results.List {
+		patches = append(patches, insertAt(fset.Position(field.Type.Pos()).Offset, names[i]+" "))
+	}
+	// A single unnamed result may not be parenthesized.
+	if !results.Opening.IsValid() {
+		patches[0].Text = "(" + patches[0].Text
+		patches = append(patches, insertAt(fset.Position(results.List[0].Type.End()).Offset, ")"))
+	}
+	return patches
+}
+
 // functionDeclarationsAtPositions returns references to function
 // declarations in packages where the position of the identifier token
 // representing the name of the function is in positions.
@@ -508,7 +611,7 @@ func functionDeclarationsAtPositions(fset *token.FileSet, files []*ast.File, inf
 	for _, file := range files {
 		for _, decl := range file.Decls {
 			if decl, ok := decl.(*ast.FuncDecl); ok {
-				call, err := genCall(info, decl.Type.Params, decl.Type.Results)
+				call, err := genCall(info, decl.Type.Params, namedResults(decl, resultNames(decl)))
 				if err != nil {
 					pos := fset.Position(decl.Pos())
 					return nil, fmt.Errorf("%s:%d: %v", pos.Filename, pos.Line, err)
@@ -545,39 +648,29 @@ func findMethodsImplementing(jirix *jiri.X, fset *token.FileSet, tpkg *types.Pac
 
 	scope := tpkg.Scope()
 	for _, child := range scope.Names() {
-		object := scope.Lookup(child)
+		object, ok := scope.Lookup(child).(*types.TypeName)
+		if !ok {
+			continue
+		}
 		typ := object.Type()
 		// ignore interfaces as they have no method implementations
 		if types.IsInterface(typ) {
 			continue
 		}
 
-		// for each non-interface type t declared in packages:
-		apiMethodSet := methodSetVisibleThroughInterfaces(typ, interfaces)
-
-		// optimization: if t implements no non-empty interfaces that
-		// we care about, we can just ignore it.
-		if len(apiMethodSet) > 0 {
-			// find all the methods explicitly declared or implicitly
-			// inherited through embedding on type t or *t.
-			methodSet := types.NewMethodSet(typ)
-			if methodSet.Len() == 0 {
-				methodSet = types.NewMethodSet(types.NewPointer(typ))
-			}
-			for i := 0; i < methodSet.Len(); i++ {
-				method := methodSet.At(i)
-				fn := method.Obj().(*types.Func)
-				// t may have a method that is not declared in any of
-				// the interfaces we care about. No need to log that.
-				if _, ok := apiMethodSet[fn.Name()]; ok {
-					if fn.Pos() == 0 {
-						// Embedded functions show up with a zero pos.
-						continue
-					}
-					progressMsg(jirix.Stdout(), "%s.%s: %s\n", tpkg.Path(), fn.Name(), fset.Position(fn.Pos()))
-					positions[fn.Pos()] = exists
-				}
+		// for each non-interface type t declared in packages, find
+		// the methods explicitly declared or implicitly inherited
+		// through embedding on type t or *t that implement the
+		// interfaces we care about.
+		for _, fn := range methodsImplementingInterfaces(typ, interfaces) {
+			// Methods promoted from types declared in other
+			// packages have no declaration in this package
+			// that could be logged.
+			if fn.Pkg() != tpkg || fn.Pos() == token.NoPos {
+				continue
 			}
+			progressMsg(jirix.Stdout(), "%s.%s: %s\n", tpkg.Path(), fn.Name(), fset.Position(fn.Pos()))
+			positions[fn.Pos()] = exists
 		}
 	}
 	return positions
@@ -716,6 +809,12 @@ func ensureImportLogPackage(fset *token.FileSet, file *ast.File) (patch, bool) {
 // methodBeginsWithNoLogComment returns true if method has a
 // "nologcall" comment before any non-whitespace or non-comment token.
 func methodBeginsWithNoLogComment(m funcDeclRef) bool {
+	return noLogComment(m) != nil
+}
+
+// noLogComment returns the "nologcall" comment that method has before
+// any non-whitespace or non-comment token, or nil if there is none.
+func noLogComment(m funcDeclRef) *ast.Comment {
 	method := m.Decl
 	lbound := method.Body.Lbrace
 	ubound := method.Body.Rbrace
@@ -726,16 +825,19 @@ func methodBeginsWithNoLogComment(m funcDeclRef) bool {
 
 	for _, cmt := range m.File.Comments {
 		if lbound <= cmt.Pos() && cmt.End() <= ubound {
-			for _, line := range strings.Split(cmt.Text(), "\n") {
-				line := strings.TrimSpace(line)
-				if line == nologComment {
-					return true
+			for _, c := range cmt.List {
+				group := &ast.CommentGroup{List: []*ast.Comment{c}}
+				for _, line := range strings.Split(group.Text(), "\n") {
+					line := strings.TrimSpace(line)
+					if line == nologComment {
+						return c
+					}
 				}
 			}
 		}
 	}
 
-	return false
+	return nil
 }
 
 func findRemovals(methods []funcDeclRef) map[funcDeclRef]error {
@@ -914,6 +1016,10 @@ func inject(jirix *jiri.X, fset *token.FileSet, methods map[funcDeclRef]error) e
 		delta := insertAt(fset.Position(m.Decl.Body.Lbrace).Offset+1, text)
 		file := m.File
 		files[file] = append(files[file], delta)
+		// Name the results so that the injected call can log them.
+		if names := resultNames(m.Decl); names != nil {
+			files[file] = append(files[file], resultNamePatches(fset, m.Decl, names)...)
+		}
 	}
 
 	for file, deltas := range files {
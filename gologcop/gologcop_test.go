@@ -8,12 +8,15 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"go/ast"
 	"go/build"
+	"go/parser"
 	"go/token"
 	"go/types"
 	"io/ioutil"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -31,7 +34,7 @@ const (
 	failingPrefix               = "failschecks"
 	withArgsPrefix              = "withargs"
 	withCommandLinePrefix       = "commandline"
-	failingPackageCount         = 8
+	failingPackageCount         = 9
 	withArgsPackageCount        = 2
 	withCommandLinePackageCount = 2
 	testPackagePrefix           = "v.io/x/devtools/gologcop/testdata"
@@ -104,7 +107,7 @@ func TestInject(t *testing.T) {
 		useContextFlag = savedContextFlag
 	}()
 	useContextFlag = false
-	testInject(t, "iface", failingPrefix, failingPackageCount)
+	testInject(t, "iface", failingPrefix, ".diff", failingPackageCount)
 }
 
 func TestInjectWithArgs(t *testing.T) {
@@ -113,7 +116,16 @@ func TestInjectWithArgs(t *testing.T) {
 		useContextFlag = savedContextFlag
 	}()
 	useContextFlag = false
-	testInject(t, "iface2", withArgsPrefix, withArgsPackageCount)
+	testInject(t, "iface2", withArgsPrefix, ".diff", withArgsPackageCount)
+}
+
+func TestInjectWithNamedResults(t *testing.T) {
+	savedContextFlag, savedLogArgsFlag := useContextFlag, logArgsFlag
+	defer func() {
+		useContextFlag, logArgsFlag = savedContextFlag, savedLogArgsFlag
+	}()
+	useContextFlag, logArgsFlag = false, true
+	testInject(t, "iface2", withArgsPrefix, ".named.diff", withArgsPackageCount)
 }
 
 func TestCommandLineArgs(t *testing.T) {
@@ -171,10 +183,47 @@ func TestInjectCommandLine(t *testing.T) {
 	injectCallFlag = "Bar"
 	injectCallImportFlag = "bar 	\"foo.com/x/baz\""
 	useContextFlag = true
-	testInject(t, "iface3", withCommandLinePrefix, withCommandLinePackageCount)
+	testInject(t, "iface3", withCommandLinePrefix, ".diff", withCommandLinePackageCount)
+}
+
+func TestResultNames(t *testing.T) {
+	savedLogArgsFlag := logArgsFlag
+	defer func() {
+		logArgsFlag = savedLogArgsFlag
+	}()
+	src := `package p
+func NoResults() {}
+func Named() (x int) { return 1 }
+func Unnamed(a int) (int, error) { return a, nil }
+func Clash(r0 int) int { return r0 }
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"NoResults": nil,
+		"Named":     nil,
+		"Unnamed":   []string{"r0", "r1"},
+		"Clash":     []string{"_r0"},
+	}
+	logArgsFlag = true
+	for _, decl := range file.Decls {
+		fn := decl.(*ast.FuncDecl)
+		if got, want := resultNames(fn), want[fn.Name.Name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", fn.Name.Name, got, want)
+		}
+	}
+	logArgsFlag = false
+	for _, decl := range file.Decls {
+		fn := decl.(*ast.FuncDecl)
+		if got := resultNames(fn); got != nil {
+			t.Errorf("%s: got %v, want nil", fn.Name.Name, got)
+		}
+	}
 }
 
-func testInject(t *testing.T, iface, prefix string, testPackageCount int) {
+func testInject(t *testing.T, iface, prefix, diffExt string, testPackageCount int) {
 	fake, cleanup := jiritest.NewFakeJiriRoot(t)
 	defer cleanup()
 
@@ -205,7 +254,7 @@ func testInject(t *testing.T, iface, prefix string, testPackageCount int) {
 			}
 			diffs = append(diffs, text)
 		}
-		diffFilename := filepath.Join("testdata", prefix, testPkg+".diff")
+		diffFilename := filepath.Join("testdata", prefix, testPkg+diffExt)
 		want := ""
 		if buf, err := ioutil.ReadFile(diffFilename); err != nil {
 			t.Fatal(err)
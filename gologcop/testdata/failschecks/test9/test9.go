@@ -0,0 +1,36 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// test9 should fail the log check because Type1 implements Interface1
+// through a promoted method and a pointer method that do not log.
+package test9
+
+import (
+	"fmt"
+)
+
+type embedded struct{}
+
+func (embedded) Method1() {
+	fmt.Println("test")
+}
+
+type Type1 struct {
+	embedded
+}
+
+func (*Type1) Method2(int) {
+	fmt.Println("test")
+}
+
+// Type2 does not implement Interface1 since the signature of Method1
+// differs, so its methods must be left alone.
+type Type2 struct{}
+
+func (Type2) Method1(int) {
+	fmt.Println("test")
+}
+func (Type2) Method2(int) {
+	fmt.Println("test")
+}
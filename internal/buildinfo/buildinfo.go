@@ -7,6 +7,7 @@
 package buildinfo
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -25,12 +26,24 @@ type T struct {
 	// Pristine records whether the build was executed using pristine master
 	// branches of Vanadium projects (or not).
 	Pristine bool
+	// Projects records the revisions of the Vanadium projects used for the
+	// build, indexed by project name.
+	Projects map[string]ProjectRevision
 	// Time records the time of the build.
 	Time time.Time
 	// User records the name of user who executed the build.
 	User string
 }
 
+// ProjectRevision describes the revision of a project used for a build.
+type ProjectRevision struct {
+	// Revision records the commit hash of the HEAD of the project.
+	Revision string
+	// Dirty records whether the project had uncommitted or untracked
+	// changes.
+	Dirty bool
+}
+
 // ToMetaData encodes build info t into metadata md.
 func (t T) ToMetaData() (*metadata.T, error) {
 	md := new(metadata.T)
@@ -41,6 +54,13 @@ func (t T) ToMetaData() (*metadata.T, error) {
 	md.Insert("build.Manifest", string(manifest))
 	md.Insert("build.Platform", t.Platform)
 	md.Insert("build.Pristine", strconv.FormatBool(t.Pristine))
+	if len(t.Projects) > 0 {
+		projects, err := json.Marshal(t.Projects)
+		if err != nil {
+			return nil, fmt.Errorf("Marshal(%v) failed: %v", t.Projects, err)
+		}
+		md.Insert("build.Projects", string(projects))
+	}
 	md.Insert("build.Time", t.Time.UTC().Format(time.RFC3339))
 	md.Insert("build.User", t.User)
 	return md, nil
@@ -63,6 +83,11 @@ func FromMetaData(md *metadata.T) (T, error) {
 			return T{}, fmt.Errorf("ParseBool(%q) failed: %v", pristine, err)
 		}
 	}
+	if projects := md.Lookup("build.Projects"); projects != "" {
+		if err := json.Unmarshal([]byte(projects), &t.Projects); err != nil {
+			return T{}, fmt.Errorf("Unmarshal(%q) failed: %v", projects, err)
+		}
+	}
 	if buildtime := md.Lookup("build.Time"); buildtime != "" {
 		if t.Time, err = time.Parse(time.RFC3339, buildtime); err != nil {
 			return T{}, fmt.Errorf("Parse(%q) failed: %v", buildtime, err)
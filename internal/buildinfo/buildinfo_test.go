@@ -40,14 +40,19 @@ var (
 				Manifest: project.Manifest{SnapshotPath: "bar"},
 				Platform: "amd64unknown-linux-unknown",
 				Pristine: false,
-				Time:     time.Unix(0, 0).UTC(),
-				User:     "Vanadium Vamoose",
+				Projects: map[string]ProjectRevision{
+					"release.go.v23":   {Revision: "4f5e7a1", Dirty: true},
+					"release.go.x.ref": {Revision: "a8c1f20"},
+				},
+				Time: time.Unix(0, 0).UTC(),
+				User: "Vanadium Vamoose",
 			},
 			MetaData: metadata.FromMap(map[string]string{
 				"build.Manifest": `<manifest snapshotpath="bar">
 </manifest>`,
 				"build.Platform": "amd64unknown-linux-unknown",
 				"build.Pristine": "false",
+				"build.Projects": `{"release.go.v23":{"Revision":"4f5e7a1","Dirty":true},"release.go.x.ref":{"Revision":"a8c1f20","Dirty":false}}`,
 				"build.Time":     "1970-01-01T00:00:00Z",
 				"build.User":     "Vanadium Vamoose",
 			}),
@@ -51,6 +51,33 @@ var goEnvVars = map[string]bool{
 	"GO15VENDOREXPERIMENT": true,
 }
 
+// PrepareGoOpt is an interface for the optional arguments of PrepareGo.
+type PrepareGoOpt interface {
+	PrepareGoOpt()
+}
+
+// FastOpt is an option that skips the report of outdated branches and
+// the VDL generation, for use when the workspace is known to be
+// up-to-date or the projects are not accessible. The build information
+// of the binaries then lists the projects cached by a previous
+// invocation, without checking them for local changes.
+type FastOpt bool
+
+func (FastOpt) PrepareGoOpt() {}
+
+// SkipMissingVDLOpt is an option that causes the VDL generation to be
+// skipped with a warning, instead of failing, if the vdl tool cannot be
+// found.
+type SkipMissingVDLOpt bool
+
+func (SkipMissingVDLOpt) PrepareGoOpt() {}
+
+// ForceVDLOpt is an option that causes the VDL compiler to be run even if
+// the VDL generation cache indicates that its inputs did not change.
+type ForceVDLOpt bool
+
+func (ForceVDLOpt) PrepareGoOpt() {}
+
 // PrepareGo runs recommended checks on the environment and related commands
 // before execution of the Go toolchain. The Go toolchain should use the
 // returned args. PrepareGo for the 'env' strips any enviornment variables
@@ -58,8 +85,20 @@ var goEnvVars = map[string]bool{
 //
 // For example, it ensures that all Go files generated by the VDL compiler are
 // up-to-date. It also generates flags so that build information can be embedded
-// in resulting binaries.
-func PrepareGo(jirix *jiri.X, env map[string]string, args []string, extraLDFlags, installSuffix string) ([]string, error) {
+// in resulting binaries. The VDL compiler is only run if the VDL generation
+// cache indicates that its inputs changed, unless ForceVDLOpt is set.
+func PrepareGo(jirix *jiri.X, env map[string]string, args []string, extraLDFlags, installSuffix string, opts ...PrepareGoOpt) ([]string, error) {
+	fast, forceVDL, skipMissingVDL := false, false, false
+	for _, opt := range opts {
+		switch typedOpt := opt.(type) {
+		case FastOpt:
+			fast = bool(typedOpt)
+		case ForceVDLOpt:
+			forceVDL = bool(typedOpt)
+		case SkipMissingVDLOpt:
+			skipMissingVDL = bool(typedOpt)
+		}
+	}
 	switch args[0] {
 	case "env":
 		rargs := []string{"env"}
@@ -76,11 +115,14 @@ func PrepareGo(jirix *jiri.X, env map[string]string, args []string, extraLDFlags
 		// binary. Any manual specification of ldflags already in the args
 		// will override this.
 		var err error
-		if args, err = setBuildInfoFlags(jirix, args, env, extraLDFlags, installSuffix); err != nil {
+		if args, err = setBuildInfoFlags(jirix, args, env, extraLDFlags, installSuffix, fast); err != nil {
 			return nil, err
 		}
 		fallthrough
 	case "generate", "run", "test":
+		if fast {
+			break
+		}
 		// Check that all non-master branches have been merged with the
 		// master branch to make sure the vdl tool is not run against
 		// out-of-date code base.
@@ -89,7 +131,7 @@ func PrepareGo(jirix *jiri.X, env map[string]string, args []string, extraLDFlags
 		}
 
 		// Generate vdl files, if necessary.
-		if err := generateVDL(jirix, env, args[0], args[1:]); err != nil {
+		if err := generateVDL(jirix, env, args[0], args[1:], forceVDL, skipMissingVDL); err != nil {
 			return nil, err
 		}
 	}
@@ -119,8 +161,10 @@ func getPlatform(jirix *jiri.X, env map[string]string) (string, error) {
 
 // setBuildInfoFlags augments the list of arguments with flags for the
 // go compiler that encoded the build information expected by the
-// v.io/x/lib/metadata package.
-func setBuildInfoFlags(jirix *jiri.X, args []string, env map[string]string, extraLDFlags, installSuffix string) ([]string, error) {
+// v.io/x/lib/metadata package. In fast mode, the projects are taken from
+// the list cached by a previous invocation and are not checked for local
+// changes, so the build is not considered pristine.
+func setBuildInfoFlags(jirix *jiri.X, args []string, env map[string]string, extraLDFlags, installSuffix string, fast bool) ([]string, error) {
 	info := buildinfo.T{Time: time.Now()}
 	// Compute the "platform" value.
 	platform, err := getPlatform(jirix, env)
@@ -143,16 +187,31 @@ tool builds. To fix this problem, please run "jiri update".
 	}
 
 	info.Manifest = *manifest
-	// Compute the "pristine" value.
-	states, err := project.GetProjectStates(jirix, true)
-	if err != nil {
-		return nil, err
-	}
-	info.Pristine = true
-	for _, state := range states {
-		if state.CurrentBranch != "master" || state.HasUncommitted || state.HasUntracked {
-			info.Pristine = false
-			break
+	if fast {
+		// Compute the "projects" value.
+		projects, err := cachedLocalProjects(jirix, true)
+		if err != nil {
+			return nil, err
+		}
+		if info.Projects, err = cachedProjectRevisions(jirix, projects); err != nil {
+			return nil, err
+		}
+	} else {
+		// Compute the "pristine" value.
+		states, err := project.GetProjectStates(jirix, true)
+		if err != nil {
+			return nil, err
+		}
+		info.Pristine = true
+		for _, state := range states {
+			if state.CurrentBranch != "master" || state.HasUncommitted || state.HasUntracked {
+				info.Pristine = false
+				break
+			}
+		}
+		// Compute the "projects" value.
+		if info.Projects, err = projectRevisions(jirix, states); err != nil {
+			return nil, err
 		}
 	}
 	// Compute the "user" value.
@@ -175,6 +234,44 @@ tool builds. To fix this problem, please run "jiri update".
 	return args, nil
 }
 
+// projectRevisions returns the revisions of the git projects with the
+// given states, indexed by project name.
+func projectRevisions(jirix *jiri.X, states map[project.ProjectKey]*project.ProjectState) (map[string]buildinfo.ProjectRevision, error) {
+	revisions := map[string]buildinfo.ProjectRevision{}
+	for _, state := range states {
+		if state.Project.Protocol != "git" {
+			continue
+		}
+		var out bytes.Buffer
+		if err := jirix.NewSeq().Capture(&out, nil).Last("git", "-C", state.Project.Path, "rev-parse", "HEAD"); err != nil {
+			return nil, err
+		}
+		revisions[state.Project.Name] = buildinfo.ProjectRevision{
+			Revision: strings.TrimSpace(out.String()),
+			Dirty:    state.HasUncommitted || state.HasUntracked,
+		}
+	}
+	return revisions, nil
+}
+
+// cachedProjectRevisions returns the revisions of the given cached git
+// projects, indexed by project name. The projects are not checked for
+// local changes.
+func cachedProjectRevisions(jirix *jiri.X, projects []cachedProject) (map[string]buildinfo.ProjectRevision, error) {
+	revisions := map[string]buildinfo.ProjectRevision{}
+	for _, p := range projects {
+		if p.Protocol != "git" {
+			continue
+		}
+		var out bytes.Buffer
+		if err := jirix.NewSeq().Capture(&out, nil).Last("git", "-C", p.Path, "rev-parse", "HEAD"); err != nil {
+			return nil, err
+		}
+		revisions[p.Name] = buildinfo.ProjectRevision{Revision: strings.TrimSpace(out.String())}
+	}
+	return revisions, nil
+}
+
 // generateVDL generates VDL for the transitive Go package dependencies.
 //
 // Note that the vdl tool takes VDL packages as input, but we're supplying Go
@@ -187,9 +284,21 @@ tool builds. To fix this problem, please run "jiri update".
 // packages; the vdl tool will compute the transitive closure of VDL package
 // dependencies, as usual.
 //
+// If the vdl tool cannot be found and skipMissing is set, a warning is
+// printed and the generation is skipped, so that plain Go code can be
+// built in workspaces without the vdl tool.
+//
 // TODO(toddw): Change the vdl tool to return vdl packages given the full Go
 // dependencies, after vdl config files are implemented.
-func generateVDL(jirix *jiri.X, env map[string]string, cmd string, args []string) error {
+func generateVDL(jirix *jiri.X, env map[string]string, cmd string, args []string, force, skipMissing bool) error {
+	vdlBin, err := lookpath.Look(env, "vdl")
+	if err != nil {
+		if skipMissing {
+			fmt.Fprintf(jirix.Stderr(), "WARNING: vdl tool not found in PATH, skipping VDL generation (use -require-vdl to fail instead)\n")
+			return nil
+		}
+		return err
+	}
 	// Compute which VDL-based Go packages might need to be regenerated.
 	goPkgs, goFiles, goTags := processGoCmdAndArgs(cmd, args)
 	goDeps, err := computeGoDeps(jirix, env, append(goPkgs, goFiles...), goTags, cmd == "test")
@@ -197,19 +306,46 @@ func generateVDL(jirix *jiri.X, env map[string]string, cmd string, args []string
 		return err
 	}
 
+	// Skip the generation if neither the vdl tool nor the VDL and
+	// generated Go files of the packages changed since the last
+	// generation. Any change causes all packages to be regenerated, as
+	// the generated code of a package may depend on the VDL files of
+	// the packages it imports.
+	toolKey, err := vdlToolKey(vdlBin)
+	if err != nil {
+		return err
+	}
+	cache := &vdlCache{Packages: map[string]string{}}
+	if !force {
+		if cache, err = loadVDLCache(jirix); err != nil {
+			return err
+		}
+		hashes, err := vdlPackageHashes(env, goDeps)
+		if err != nil {
+			return err
+		}
+		if cache.upToDate(toolKey, goDeps, hashes) {
+			return nil
+		}
+	}
+
 	// Regenerate the VDL-based Go packages.
 	// -ignore_unknown:  Silently ignore unknown package paths.
 	vdlArgs := []string{"-ignore_unknown", "generate", "-lang=go"}
 	vdlArgs = append(vdlArgs, goDeps...)
-	vdlBin, err := lookpath.Look(env, "vdl")
-	if err != nil {
-		return err
-	}
 	var out bytes.Buffer
 	if err := jirix.NewSeq().Env(env).Capture(&out, &out).Last(vdlBin, vdlArgs...); err != nil {
 		return fmt.Errorf("failed to generate vdl: %v\n%s", err, out.String())
 	}
-	return nil
+
+	// Record the hashes of the packages, which now include the
+	// regenerated Go files.
+	hashes, err := vdlPackageHashes(env, goDeps)
+	if err != nil {
+		return err
+	}
+	cache.update(toolKey, goDeps, hashes)
+	return cache.save(jirix)
 }
 
 // reportOutdatedProjects checks if the currently checked out branches
@@ -221,7 +357,7 @@ func reportOutdatedBranches(jirix *jiri.X) (e error) {
 		return err
 	}
 	defer collect.Error(func() error { return jirix.NewSeq().Chdir(cwd).Done() }, &e)
-	projects, err := project.LocalProjects(jirix, false)
+	projects, err := cachedLocalProjects(jirix, false)
 	if err != nil {
 		return err
 	}
@@ -355,6 +491,121 @@ func processGoCmdAndArgs(cmd string, args []string) ([]string, []string, string)
 	}
 }
 
+// AddBuildTags returns the given go tool command and arguments with the given
+// build tags added to the -tags flag. If the arguments already specify the
+// -tags flag, the given tags are merged into its value; otherwise the flag is
+// inserted right after the command. Commands that do not accept build flags
+// are returned unchanged.
+func AddBuildTags(args []string, tags []string) []string {
+	if len(args) == 0 || len(tags) == 0 {
+		return args
+	}
+	var nonBool map[string]bool
+	switch args[0] {
+	case "build":
+		nonBool = nonBoolGoBuild
+	case "install":
+		nonBool = nonBoolGoInstall
+	case "run":
+		nonBool = nonBoolGoRun
+	case "test":
+		nonBool = nonBoolGoTest
+	default:
+		return args
+	}
+	result := append([]string{}, args...)
+	// Look for an existing -tags flag among the flags that precede
+	// PACKAGES or GOFILES; see processGoCmdAndArgs for details.
+	for i := 1; i < len(result); i++ {
+		if result[i] == "--" {
+			break
+		}
+		match := goFlagRE.FindStringSubmatch(result[i])
+		if match == nil {
+			break
+		}
+		hasValue := nonBool[match[1]] && match[2] == ""
+		if match[1] == "tags" {
+			if hasValue {
+				if i+1 < len(result) {
+					result[i+1] = mergeTags(result[i+1], tags)
+				}
+			} else {
+				result[i] = result[i][:len(result[i])-len(match[3])] + mergeTags(match[3], tags)
+			}
+			return result
+		}
+		if hasValue {
+			i++
+		}
+	}
+	return append([]string{args[0], "-tags=" + strings.Join(tags, " ")}, args[1:]...)
+}
+
+// mergeTags returns the space-separated list of tags in value, followed by
+// those of the given tags that are not already in value.
+func mergeTags(value string, tags []string) string {
+	merged := strings.Fields(value)
+	existing := set.String.FromSlice(merged)
+	for _, tag := range tags {
+		if _, ok := existing[tag]; !ok {
+			merged = append(merged, tag)
+			existing[tag] = struct{}{}
+		}
+	}
+	return strings.Join(merged, " ")
+}
+
+// variantNameRE matches the characters of build tags that are replaced in
+// the names of build variants.
+var variantNameRE = regexp.MustCompile(`[^A-Za-z0-9_.]`)
+
+// BuildVariant returns the name of the build variant selected by the flags
+// among the given arguments of a go tool command, which do not include the
+// command itself. The name is empty for regular builds, and identifies the
+// instrumentation and build tags otherwise, e.g. "race" for builds with the
+// -race flag, "tags-foo" for builds with -tags=foo and "race-tags-bar-foo" for
+// builds with -race -tags="foo bar". Binaries of different variants should be
+// placed in different directories, which the name can be used for.
+func BuildVariant(args []string) string {
+	var instrumentation, tags []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		match := goFlagRE.FindStringSubmatch(args[i])
+		if match == nil {
+			break
+		}
+		value := match[3]
+		if nonBoolGoTest[match[1]] && match[2] == "" {
+			i++
+			if i < len(args) {
+				value = args[i]
+			}
+		}
+		switch match[1] {
+		case "msan", "race":
+			if match[2] == "" || value == "true" {
+				instrumentation = append(instrumentation, match[1])
+			}
+		case "tags":
+			tags = strings.FieldsFunc(value, func(r rune) bool {
+				return r == ' ' || r == ','
+			})
+		}
+	}
+	parts := instrumentation
+	if len(tags) > 0 {
+		sort.Strings(tags)
+		parts = append(parts, "tags")
+		for _, tag := range tags {
+			parts = append(parts, variantNameRE.ReplaceAllString(tag, "_"))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
 var (
 	goFlagRE     = regexp.MustCompile(`^--?([^=]+)(=?)(.*)`)
 	nonBoolBuild = []string{
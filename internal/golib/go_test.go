@@ -5,6 +5,7 @@
 package golib
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 
 	"v.io/jiri/jiritest"
 	"v.io/jiri/runutil"
+	"v.io/jiri/tool"
 	"v.io/x/devtools/internal/buildinfo"
 	_ "v.io/x/devtools/internal/golib/testdata/basedep"
 	"v.io/x/lib/metadata"
@@ -70,6 +72,18 @@ func TestGoVDLGeneration(t *testing.T) {
 	} else {
 		t.Fatalf("file %v exists and it should not.", outFile)
 	}
+	// Check that the 'build' go command does not generate the test VDL
+	// file in fast mode.
+	if _, err := PrepareGo(fake.X, env, []string{"build", "testpkg"}, "", "", FastOpt(true)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := s.Stat(outFile); err != nil {
+		if !runutil.IsNotExist(err) {
+			t.Fatalf("%v", err)
+		}
+	} else {
+		t.Fatalf("file %v exists and it should not.", outFile)
+	}
 	// Check that the 'build' go command generates the test VDL file.
 	if _, err := PrepareGo(fake.X, env, []string{"build", "testpkg"}, "", ""); err != nil {
 		t.Fatalf("%v", err)
@@ -77,6 +91,38 @@ func TestGoVDLGeneration(t *testing.T) {
 	if _, err := s.Stat(outFile); err != nil {
 		t.Fatalf("%v", err)
 	}
+	// Check that the generated VDL file is regenerated after it is
+	// removed, even though the VDL file did not change.
+	if err := s.RemoveAll(outFile).Done(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := PrepareGo(fake.X, env, []string{"build", "testpkg"}, "", ""); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := s.Stat(outFile); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// TestGoVDLGenerationWithoutTool checks that the VDL generation is
+// skipped with a warning if the vdl tool cannot be found, unless the
+// tool is required.
+func TestGoVDLGenerationWithoutTool(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	var stderr bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stderr: &stderr})
+
+	env := map[string]string{"PATH": fake.X.Root}
+	if err := generateVDL(fake.X, env, "build", []string{"testpkg"}, false, false); err == nil {
+		t.Fatalf("VDL generation without the vdl tool did not fail")
+	}
+	if err := generateVDL(fake.X, env, "build", []string{"testpkg"}, false, true); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := stderr.String(), "WARNING: vdl tool not found in PATH, skipping VDL generation (use -require-vdl to fail instead)\n"; got != want {
+		t.Fatalf("want warning %q, got %q", want, got)
+	}
 }
 
 // TestProcessGoCmdAndArgs tests the internal function that parses and filters
@@ -300,6 +346,51 @@ func TestComputeGoDeps(t *testing.T) {
 	}
 }
 
+func TestAddBuildTags(t *testing.T) {
+	tests := []struct {
+		Args, Tags, Want []string
+	}{
+		{nil, []string{"foo"}, nil},
+		{[]string{"build", "pkg"}, nil, []string{"build", "pkg"}},
+		{[]string{"env", "GOPATH"}, []string{"foo"}, []string{"env", "GOPATH"}},
+		{[]string{"generate", "pkg"}, []string{"foo"}, []string{"generate", "pkg"}},
+		{[]string{"build", "pkg"}, []string{"foo"}, []string{"build", "-tags=foo", "pkg"}},
+		{[]string{"test", "-v", "pkg"}, []string{"foo", "bar"}, []string{"test", "-tags=foo bar", "-v", "pkg"}},
+		{[]string{"install", "-tags=foo", "pkg"}, []string{"bar"}, []string{"install", "-tags=foo bar", "pkg"}},
+		{[]string{"install", "--tags=foo", "pkg"}, []string{"foo", "bar"}, []string{"install", "--tags=foo bar", "pkg"}},
+		{[]string{"build", "-o", "out", "-tags", "foo", "pkg"}, []string{"bar"}, []string{"build", "-o", "out", "-tags", "foo bar", "pkg"}},
+		// A -tags argument after PACKAGES is a testbin flag.
+		{[]string{"test", "pkg", "-tags=baz"}, []string{"foo"}, []string{"test", "-tags=foo", "pkg", "-tags=baz"}},
+	}
+	for _, test := range tests {
+		if got, want := AddBuildTags(test.Args, test.Tags), test.Want; !reflect.DeepEqual(got, want) {
+			t.Errorf("AddBuildTags(%q, %q) got %q, want %q", test.Args, test.Tags, got, want)
+		}
+	}
+}
+
+func TestBuildVariant(t *testing.T) {
+	tests := []struct {
+		Args []string
+		Want string
+	}{
+		{nil, ""},
+		{[]string{"-v", "pkg"}, ""},
+		{[]string{"-race", "pkg"}, "race"},
+		{[]string{"-race=false", "pkg"}, ""},
+		{[]string{"-tags=foo", "pkg"}, "tags-foo"},
+		{[]string{"-race", "-tags", "foo bar", "pkg"}, "race-tags-bar-foo"},
+		{[]string{"-o", "out", "-msan", "--tags=a/b,c", "pkg"}, "msan-tags-a_b-c"},
+		// Flags after PACKAGES are testbin flags.
+		{[]string{"pkg", "-race"}, ""},
+	}
+	for _, test := range tests {
+		if got, want := BuildVariant(test.Args), test.Want; got != want {
+			t.Errorf("BuildVariant(%q) got %q, want %q", test.Args, got, want)
+		}
+	}
+}
+
 func containsStrings(super, sub []string) bool {
 	subSet := set.String.FromSlice(sub)
 	set.String.Difference(subSet, set.String.FromSlice(super))
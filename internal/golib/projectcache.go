@@ -0,0 +1,100 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/project"
+	"v.io/jiri/runutil"
+)
+
+const (
+	// projectCacheFileName is the name of the file, in the jiri root
+	// metadata directory, that holds the cached list of local projects.
+	projectCacheFileName = "go_projects.json"
+	// projectCacheTTL is how long the cached list of local projects is
+	// used in fast mode before the projects are scanned again.
+	projectCacheTTL = time.Hour
+)
+
+// cachedProject holds the information about a local project that is
+// needed to report outdated branches and to record build information.
+type cachedProject struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Protocol string `json:"protocol"`
+}
+
+// projectCache is the cached list of local projects.
+type projectCache struct {
+	Time     time.Time       `json:"time"`
+	Projects []cachedProject `json:"projects"`
+}
+
+// cachedLocalProjects returns the local projects. If reuse is set, the
+// list cached by a previous invocation is used if it is recent enough
+// and all of its projects still exist. Otherwise, the projects are
+// scanned and the cached list is updated, so that the invocations in
+// fast mode see the projects scanned by the other ones.
+func cachedLocalProjects(jirix *jiri.X, reuse bool) ([]cachedProject, error) {
+	path := filepath.Join(jirix.RootMetaDir(), projectCacheFileName)
+	if reuse {
+		data, err := jirix.NewSeq().ReadFile(path)
+		switch {
+		case err == nil:
+			var cache projectCache
+			if err := json.Unmarshal(data, &cache); err == nil && time.Since(cache.Time) < projectCacheTTL && projectsExist(cache.Projects) {
+				return cache.Projects, nil
+			}
+		case !runutil.IsNotExist(err):
+			return nil, err
+		}
+	}
+	projects, err := project.LocalProjects(jirix, false)
+	if err != nil {
+		return nil, err
+	}
+	cache := projectCache{Time: time.Now()}
+	for _, p := range projects {
+		cache.Projects = append(cache.Projects, cachedProject{
+			Name:     p.Name,
+			Path:     p.Path,
+			Protocol: p.Protocol,
+		})
+	}
+	sort.Sort(cachedProjectsByPath(cache.Projects))
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("MarshalIndent(%v) failed: %v", cache, err)
+	}
+	if err := writeFileAtomically(jirix, path, data); err != nil {
+		return nil, err
+	}
+	return cache.Projects, nil
+}
+
+// projectsExist returns whether the directories of all given projects
+// exist.
+func projectsExist(projects []cachedProject) bool {
+	for _, p := range projects {
+		if _, err := os.Stat(p.Path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+type cachedProjectsByPath []cachedProject
+
+func (p cachedProjectsByPath) Len() int           { return len(p) }
+func (p cachedProjectsByPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p cachedProjectsByPath) Less(i, j int) bool { return p[i].Path < p[j].Path }
@@ -0,0 +1,214 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golib
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/runutil"
+)
+
+// ForceVDLFlagDescription describes the -force-vdl flag, to be added to
+// any tool that generates VDL files through PrepareGo.
+const ForceVDLFlagDescription = `Regenerate the VDL files even if the VDL generation cache indicates that they are up-to-date.`
+
+// RequireVDLFlagDescription describes the -require-vdl flag, to be added
+// to any tool that skips the VDL generation through PrepareGo when the
+// vdl tool cannot be found.
+const RequireVDLFlagDescription = `Fail instead of skipping the VDL generation with a warning if the vdl tool cannot be found in PATH. Can also be enabled by setting the ` + RequireVDLEnv + ` environment variable to 1.`
+
+// RequireVDLEnv is the environment variable that, when set to 1, has
+// the same effect as the -require-vdl flag. It is set for the tests run
+// by jiri-test, so that CI builds never use stale VDL files.
+const RequireVDLEnv = "JIRI_GO_REQUIRE_VDL"
+
+// vdlCacheFileName is the name of the file, in the jiri root metadata
+// directory, that holds the VDL generation cache.
+const vdlCacheFileName = "vdl_cache.json"
+
+// vdlCache records the inputs of the last VDL generation, so that the
+// generation can be skipped when none of them changed.
+type vdlCache struct {
+	// Tool identifies the vdl tool used for the generation.
+	Tool string `json:"tool"`
+	// Packages maps the import paths of the VDL packages to the hash
+	// of their VDL and generated Go files.
+	Packages map[string]string `json:"packages"`
+}
+
+func vdlCacheFile(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), vdlCacheFileName)
+}
+
+// VDLGenerationTime returns the time of the last VDL generation, which
+// is when the VDL generation cache was last written, or the zero time if
+// there is no cache. Callers can use it to detect that generated files
+// may have changed.
+func VDLGenerationTime(jirix *jiri.X) time.Time {
+	fileInfo, err := jirix.NewSeq().Stat(vdlCacheFile(jirix))
+	if err != nil {
+		return time.Time{}
+	}
+	return fileInfo.ModTime()
+}
+
+// loadVDLCache loads the VDL generation cache, returning an empty cache
+// if there is none.
+func loadVDLCache(jirix *jiri.X) (*vdlCache, error) {
+	cache := &vdlCache{Packages: map[string]string{}}
+	path := vdlCacheFile(jirix)
+	data, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", path, err)
+	}
+	if cache.Packages == nil {
+		cache.Packages = map[string]string{}
+	}
+	return cache, nil
+}
+
+// save writes the VDL generation cache.
+func (c *vdlCache) save(jirix *jiri.X) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", c, err)
+	}
+	return writeFileAtomically(jirix, vdlCacheFile(jirix), data)
+}
+
+// writeFileAtomically writes the given data to the given path through a
+// uniquely named temporary file in the same directory, so that concurrent
+// invocations never write to the same temporary file.
+func writeFileAtomically(jirix *jiri.X, path string, data []byte) (e error) {
+	dir := filepath.Dir(path)
+	if err := jirix.NewSeq().MkdirAll(dir, os.FileMode(0755)).Done(); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".")
+	if err != nil {
+		return fmt.Errorf("TempFile(%v) failed: %v", dir, err)
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if e != nil {
+			jirix.NewSeq().RemoveAll(tmpPath).Done()
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("Write(%v) failed: %v", tmpPath, err)
+	}
+	if err := f.Chmod(os.FileMode(0644)); err != nil {
+		f.Close()
+		return fmt.Errorf("Chmod(%v) failed: %v", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Close(%v) failed: %v", tmpPath, err)
+	}
+	return jirix.NewSeq().Rename(tmpPath, path).Done()
+}
+
+// upToDate returns whether the given vdl tool and hashes of the given
+// packages match the cache.
+func (c *vdlCache) upToDate(tool string, pkgs []string, hashes map[string]string) bool {
+	if c.Tool != tool {
+		return false
+	}
+	for _, pkg := range pkgs {
+		if c.Packages[pkg] != hashes[pkg] {
+			return false
+		}
+	}
+	return true
+}
+
+// update records the given vdl tool and hashes of the given packages.
+func (c *vdlCache) update(tool string, pkgs []string, hashes map[string]string) {
+	if c.Tool != tool {
+		c.Tool, c.Packages = tool, map[string]string{}
+	}
+	for _, pkg := range pkgs {
+		if hash, ok := hashes[pkg]; ok {
+			c.Packages[pkg] = hash
+		} else {
+			delete(c.Packages, pkg)
+		}
+	}
+}
+
+// vdlToolKey returns a string that changes whenever the given vdl
+// binary is rebuilt.
+func vdlToolKey(vdlBin string) (string, error) {
+	fi, err := os.Stat(vdlBin)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %d", vdlBin, fi.Size(), fi.ModTime().UnixNano()), nil
+}
+
+// isVDLCacheInput returns whether the file with the given name affects
+// the VDL generation of the package that contains it.
+func isVDLCacheInput(name string) bool {
+	return strings.HasSuffix(name, ".vdl") || strings.HasSuffix(name, ".vdl.go") || name == "vdl.config"
+}
+
+// vdlPackageHashes returns the hashes of the VDL and generated Go files
+// of the given packages, looked up in the VDLPATH of the given
+// environment. Packages without such files are omitted.
+func vdlPackageHashes(env map[string]string, pkgs []string) (map[string]string, error) {
+	roots := filepath.SplitList(env["VDLPATH"])
+	hashes := map[string]string{}
+	for _, pkg := range pkgs {
+		for _, root := range roots {
+			dir := filepath.Join(root, filepath.FromSlash(pkg))
+			fileInfos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, fmt.Errorf("ReadDir(%v) failed: %v", dir, err)
+			}
+			names := []string{}
+			for _, fi := range fileInfos {
+				if !fi.IsDir() && isVDLCacheInput(fi.Name()) {
+					names = append(names, fi.Name())
+				}
+			}
+			if len(names) > 0 {
+				sort.Strings(names)
+				h := sha1.New()
+				for _, name := range names {
+					path := filepath.Join(dir, name)
+					data, err := ioutil.ReadFile(path)
+					if err != nil {
+						return nil, fmt.Errorf("ReadFile(%v) failed: %v", path, err)
+					}
+					fmt.Fprintf(h, "%s %d\n", name, len(data))
+					h.Write(data)
+				}
+				hashes[pkg] = fmt.Sprintf("%x", h.Sum(nil))
+			}
+			// Like the vdl tool, only use the first root that
+			// contains the package.
+			break
+		}
+	}
+	return hashes, nil
+}
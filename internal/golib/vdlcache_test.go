@@ -0,0 +1,118 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package golib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"v.io/jiri/jiritest"
+)
+
+func TestVDLPackageHashes(t *testing.T) {
+	root, err := ioutil.TempDir("", "test_vdlcache")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(root)
+	for path, content := range map[string]string{
+		"a/a.vdl":    "package a\n",
+		"a/a.vdl.go": "package a\n",
+		"a/doc.go":   "package a\n",
+		"b/doc.go":   "package b\n",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), os.FileMode(0644)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	env := map[string]string{"VDLPATH": root}
+	pkgs := []string{"a", "b", "c"}
+	hashes, err := vdlPackageHashes(env, pkgs)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(hashes), 1; got != want {
+		t.Fatalf("want %v hashes, got %v: %v", want, got, hashes)
+	}
+
+	// The cache is up-to-date only after it is updated.
+	cache := &vdlCache{Packages: map[string]string{}}
+	if cache.upToDate("vdl", pkgs, hashes) {
+		t.Errorf("empty cache is up-to-date")
+	}
+	cache.update("vdl", pkgs, hashes)
+	if !cache.upToDate("vdl", pkgs, hashes) {
+		t.Errorf("updated cache is not up-to-date")
+	}
+	if cache.upToDate("vdl2", pkgs, hashes) {
+		t.Errorf("cache is up-to-date for a different vdl tool")
+	}
+
+	// Changes to the generated Go files, but not to other Go files,
+	// invalidate the cache.
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "doc.go"), []byte("package a // changed\n"), os.FileMode(0644)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hashes, err = vdlPackageHashes(env, pkgs); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !cache.upToDate("vdl", pkgs, hashes) {
+		t.Errorf("cache is not up-to-date after a non-VDL change")
+	}
+	if err := os.Remove(filepath.Join(root, "a", "a.vdl.go")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hashes, err = vdlPackageHashes(env, pkgs); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if cache.upToDate("vdl", pkgs, hashes) {
+		t.Errorf("cache is up-to-date after a generated file was removed")
+	}
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	root, err := ioutil.TempDir("", "test_vdlcache")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(root)
+
+	// Concurrent writers each use their own temporary file, so the file
+	// always holds the data of one of them and no temporary files remain.
+	path := filepath.Join(root, "dir", "file")
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- writeFileAtomically(fake.X, path, []byte("data"))
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := string(data), "data"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	fileInfos, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(fileInfos), 1; got != want {
+		t.Errorf("got %v files, want %v: %v", got, want, fileInfos)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/golib"
+)
+
+// DepGraph is the import graph of a set of Go packages, their tests and
+// all packages they transitively depend on. The test variants of
+// packages are merged into the packages themselves, so that a package
+// imports the packages imported by its tests as well.
+type DepGraph struct {
+	// imports maps the import paths of the packages of the graph to
+	// the sets of packages they import.
+	imports map[string]map[string]bool
+}
+
+// cachedDepGraph is a graph loaded by LoadDepGraph, along with the time
+// of the VDL generation it reflects.
+type cachedDepGraph struct {
+	graph   *DepGraph
+	vdlTime time.Time
+}
+
+var (
+	graphCacheMu sync.Mutex
+	// graphCache caches the graphs loaded by LoadDepGraph, keyed by
+	// the arguments they were loaded with.
+	graphCache = map[string]cachedDepGraph{}
+)
+
+// LoadDepGraph inputs a list of Go package expressions and returns the
+// import graph of the matching packages. The implementation invokes
+// 'go list -deps -test -json' internally with jiriArgs as arguments to
+// the jiri-go subcommand, once for each combination of arguments until
+// VDL files are generated again, which can change the imports of the
+// packages. Callers must not expect the graph to reflect other changes
+// made to the sources after it has been loaded.
+func LoadDepGraph(jirix *jiri.X, jiriArgs []string, pkgs ...string) (*DepGraph, error) {
+	key := strings.Join(jiriArgs, " ") + "\x00" + strings.Join(pkgs, " ")
+	graphCacheMu.Lock()
+	defer graphCacheMu.Unlock()
+	if cached, ok := graphCache[key]; ok && cached.vdlTime.Equal(golib.VDLGenerationTime(jirix)) {
+		return cached.graph, nil
+	}
+	args := append([]string{"go"}, jiriArgs...)
+	args = append(args, "list", "-deps", "-test", "-json")
+	args = append(args, pkgs...)
+	var stdout, stderr bytes.Buffer
+	if err := jirix.NewSeq().Capture(&stdout, &stderr).Last("jiri", args...); err != nil {
+		fmt.Fprintln(jirix.Stderr(), stderr.String())
+		return nil, err
+	}
+	g, err := parseDepGraph(&stdout)
+	if err != nil {
+		return nil, err
+	}
+	// The time is taken after running the go tool, as "jiri go" can
+	// generate the VDL files before listing the packages.
+	graphCache[key] = cachedDepGraph{graph: g, vdlTime: golib.VDLGenerationTime(jirix)}
+	return g, nil
+}
+
+// basePackage returns the import path of the package that the package
+// with the given import path in the output of 'go list -test' is a
+// variant of, such as "p" for "p [p.test]". It returns an empty string
+// for the generated test mains.
+func basePackage(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+	if strings.HasSuffix(importPath, ".test") {
+		return ""
+	}
+	return importPath
+}
+
+// parseDepGraph parses the given output of 'go list -deps -test -json'
+// into an import graph.
+func parseDepGraph(r io.Reader) (*DepGraph, error) {
+	g := &DepGraph{imports: map[string]map[string]bool{}}
+	decoder := json.NewDecoder(r)
+	for {
+		var pkg struct {
+			ImportPath string
+			Imports    []string
+		}
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Decode() failed: %v", err)
+		}
+		path := basePackage(pkg.ImportPath)
+		if path == "" {
+			continue
+		}
+		imports, ok := g.imports[path]
+		if !ok {
+			imports = map[string]bool{}
+			g.imports[path] = imports
+		}
+		for _, imp := range pkg.Imports {
+			// The external test package of p imports p itself.
+			if imp = basePackage(imp); imp != "" && imp != path {
+				imports[imp] = true
+			}
+		}
+	}
+	return g, nil
+}
+
+// Packages returns the sorted import paths of the packages of the graph.
+func (g *DepGraph) Packages() []string {
+	pkgs := []string{}
+	for pkg := range g.imports {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// ReverseDeps returns the sorted import paths of the packages of the
+// graph that transitively import any of the given packages, other than
+// the given packages themselves.
+func (g *DepGraph) ReverseDeps(pkgs []string) []string {
+	importers := map[string][]string{}
+	for pkg, imports := range g.imports {
+		for imp := range imports {
+			importers[imp] = append(importers[imp], pkg)
+		}
+	}
+	targets, seen := map[string]bool{}, map[string]bool{}
+	queue := []string{}
+	for _, pkg := range pkgs {
+		targets[pkg] = true
+		queue = append(queue, pkg)
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, importer := range importers[pkg] {
+			if !seen[importer] {
+				seen[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+	result := []string{}
+	for pkg := range seen {
+		if !targets[pkg] {
+			result = append(result, pkg)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// WhyPath returns the shortest chain of imports that leads from the
+// given package to the other given package, starting with the former
+// and ending with the latter. Among the shortest chains, the first one
+// in lexicographic order is returned. It returns nil if the former does
+// not depend on the latter.
+func (g *DepGraph) WhyPath(from, to string) []string {
+	if _, ok := g.imports[from]; !ok {
+		return nil
+	}
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if pkg == to {
+			path := []string{}
+			for ; pkg != ""; pkg = prev[pkg] {
+				path = append([]string{pkg}, path...)
+			}
+			return path
+		}
+		imports := []string{}
+		for imp := range g.imports[pkg] {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		for _, imp := range imports {
+			if _, ok := prev[imp]; !ok {
+				prev[imp] = pkg
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+// ReverseDeps returns the sorted list of the Go packages, among the
+// packages that match the given universe of Go package expressions and
+// their dependencies, that transitively import any of the given
+// packages, either directly or through their tests. The implementation
+// relies on LoadDepGraph with jiriArgs as arguments to the jiri-go
+// subcommand.
+func ReverseDeps(jirix *jiri.X, jiriArgs, pkgs, universe []string) ([]string, error) {
+	g, err := LoadDepGraph(jirix, jiriArgs, universe...)
+	if err != nil {
+		return nil, err
+	}
+	return g.ReverseDeps(pkgs), nil
+}
+
+// WhyPath returns the shortest chain of imports, starting with the
+// given package and ending with the other given package, that makes
+// the former, or its tests, depend on the latter. It returns nil if the
+// former does not depend on the latter. The implementation relies on
+// LoadDepGraph with jiriArgs as arguments to the jiri-go subcommand.
+func WhyPath(jirix *jiri.X, jiriArgs []string, from, to string) ([]string, error) {
+	g, err := LoadDepGraph(jirix, jiriArgs, from)
+	if err != nil {
+		return nil, err
+	}
+	return g.WhyPath(from, to), nil
+}
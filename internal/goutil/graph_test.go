@@ -0,0 +1,101 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// goListOutput is the output of 'go list -deps -test -json v.io/x/a' in
+// a workspace where v.io/x/a imports v.io/x/b, the internal test of
+// v.io/x/a imports v.io/x/c, and the external test of v.io/x/a imports
+// v.io/x/d, which imports v.io/x/b.
+const goListOutput = `{
+	"ImportPath": "fmt",
+	"Goroot": true,
+	"Standard": true,
+	"DepOnly": true
+}
+{
+	"ImportPath": "v.io/x/b",
+	"Imports": ["fmt"],
+	"DepOnly": true
+}
+{
+	"ImportPath": "v.io/x/a",
+	"Imports": ["v.io/x/b"]
+}
+{
+	"ImportPath": "v.io/x/c",
+	"Imports": ["fmt"],
+	"DepOnly": true
+}
+{
+	"ImportPath": "v.io/x/a [v.io/x/a.test]",
+	"Imports": ["v.io/x/b", "v.io/x/c"],
+	"ForTest": "v.io/x/a"
+}
+{
+	"ImportPath": "v.io/x/d",
+	"Imports": ["v.io/x/b"],
+	"DepOnly": true
+}
+{
+	"ImportPath": "v.io/x/a_test [v.io/x/a.test]",
+	"Imports": ["v.io/x/a [v.io/x/a.test]", "v.io/x/d"],
+	"ForTest": "v.io/x/a"
+}
+{
+	"ImportPath": "v.io/x/a.test",
+	"Imports": ["v.io/x/a [v.io/x/a.test]", "v.io/x/a_test [v.io/x/a.test]"]
+}
+`
+
+func TestDepGraph(t *testing.T) {
+	g, err := parseDepGraph(strings.NewReader(goListOutput))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := g.Packages(), []string{"fmt", "v.io/x/a", "v.io/x/a_test", "v.io/x/b", "v.io/x/c", "v.io/x/d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	reverseDeps := []struct {
+		pkgs, want []string
+	}{
+		{[]string{"v.io/x/b"}, []string{"v.io/x/a", "v.io/x/a_test", "v.io/x/d"}},
+		{[]string{"v.io/x/c"}, []string{"v.io/x/a", "v.io/x/a_test"}},
+		{[]string{"v.io/x/d", "v.io/x/a"}, []string{"v.io/x/a_test"}},
+		{[]string{"v.io/x/a_test"}, []string{}},
+		{[]string{"v.io/x/unknown"}, []string{}},
+	}
+	for _, test := range reverseDeps {
+		if got := g.ReverseDeps(test.pkgs); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ReverseDeps(%v): want %v, got %v", test.pkgs, test.want, got)
+		}
+	}
+
+	whyPaths := []struct {
+		from, to string
+		want     []string
+	}{
+		{"v.io/x/a", "fmt", []string{"v.io/x/a", "v.io/x/b", "fmt"}},
+		{"v.io/x/a_test", "v.io/x/b", []string{"v.io/x/a_test", "v.io/x/a", "v.io/x/b"}},
+		{"v.io/x/a", "v.io/x/a", []string{"v.io/x/a"}},
+		{"v.io/x/b", "v.io/x/a", nil},
+		{"v.io/x/unknown", "fmt", nil},
+	}
+	for _, test := range whyPaths {
+		if got := g.WhyPath(test.from, test.to); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WhyPath(%v, %v): want %v, got %v", test.from, test.to, test.want, got)
+		}
+	}
+
+	if _, err := parseDepGraph(strings.NewReader(`{"ImportPath": `)); err == nil {
+		t.Errorf("parsing invalid output did not fail")
+	}
+}
@@ -9,6 +9,7 @@ package goutil
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"v.io/jiri"
@@ -19,20 +20,35 @@ import (
 // expressions. The implementation invokes 'go list' internally with
 // jiriArgs as arguments to the jiri-go subcommand.
 func List(jirix *jiri.X, jiriArgs []string, pkgs ...string) ([]string, error) {
-	return list(jirix, jiriArgs, "{{.ImportPath}}", pkgs...)
+	return list(jirix, jiriArgs, nil, "{{.ImportPath}}", pkgs...)
 }
 
 // ListDirs inputs a list of Go package expressions and returns a list of
 // directories that match the expressions.  The implementation invokes 'go list'
 // internally with jiriArgs as arguments to the jiri-go subcommand.
 func ListDirs(jirix *jiri.X, jiriArgs []string, pkgs ...string) ([]string, error) {
-	return list(jirix, jiriArgs, "{{.Dir}}", pkgs...)
+	return list(jirix, jiriArgs, nil, "{{.Dir}}", pkgs...)
 }
 
-func list(jirix *jiri.X, jiriArgs []string, format string, pkgs ...string) ([]string, error) {
+// ListDeps inputs a list of Go package expressions and returns a sorted
+// list of the Go packages that match any of the expressions, together
+// with all packages they or their tests transitively depend on. The
+// implementation relies on LoadDepGraph with jiriArgs as arguments to
+// the jiri-go subcommand.
+func ListDeps(jirix *jiri.X, jiriArgs []string, pkgs ...string) ([]string, error) {
+	g, err := LoadDepGraph(jirix, jiriArgs, pkgs...)
+	if err != nil {
+		return nil, err
+	}
+	return g.Packages(), nil
+}
+
+func list(jirix *jiri.X, jiriArgs, listArgs []string, format string, pkgs ...string) ([]string, error) {
 	s := jirix.NewSeq()
 	args := append([]string{"go"}, jiriArgs...)
-	args = append(args, "list", "-f="+format)
+	args = append(args, "list")
+	args = append(args, listArgs...)
+	args = append(args, "-f="+format)
 	args = append(args, pkgs...)
 	var out bytes.Buffer
 	if err := s.Capture(&out, &out).Last("jiri", args...); err != nil {
@@ -45,3 +61,46 @@ func list(jirix *jiri.X, jiriArgs []string, format string, pkgs ...string) ([]st
 	}
 	return strings.Split(cleanOut, "\n"), nil
 }
+
+// ChangedPackages returns the import paths of the Go packages that
+// contain the given changed files, which are either absolute or
+// relative to the given root. Files in testdata directories are
+// attributed to the package that contains the directory. The boolean
+// result reports whether all of the files are inside of the given Go
+// workspaces; files outside of them, e.g. profiles, manifests or
+// scripts, are not attributed to any package.
+func ChangedPackages(root string, workspaces, files []string) ([]string, bool) {
+	pkgs, seen, inWorkspaces := []string{}, map[string]bool{}, true
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		file = filepath.Clean(file)
+		found := false
+		for _, workspace := range workspaces {
+			src := filepath.Join(root, workspace, "src") + string(filepath.Separator)
+			if !strings.HasPrefix(file, src) {
+				continue
+			}
+			parts := strings.Split(filepath.ToSlash(filepath.Dir(strings.TrimPrefix(file, src))), "/")
+			for i, part := range parts {
+				if part == "testdata" {
+					parts = parts[:i]
+					break
+				}
+			}
+			if pkg := strings.Join(parts, "/"); pkg != "" && pkg != "." {
+				found = true
+				if !seen[pkg] {
+					seen[pkg] = true
+					pkgs = append(pkgs, pkg)
+				}
+			}
+			break
+		}
+		if !found {
+			inWorkspaces = false
+		}
+	}
+	return pkgs, inWorkspaces
+}
@@ -0,0 +1,50 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedPackages(t *testing.T) {
+	root := "/jiri"
+	workspaces := []string{"release/go", "release/projects/madb"}
+	tests := []struct {
+		files []string
+		pkgs  []string
+		ok    bool
+	}{
+		{nil, []string{}, true},
+		{
+			[]string{
+				"release/go/src/v.io/x/ref/lib/flags/flags.go",
+				"/jiri/release/go/src/v.io/x/ref/lib/flags/listen.go",
+				"release/go/src/v.io/x/ref/services/device/testdata/config/a.json",
+				"release/go/src/v.io/v23/README.md",
+				"release/projects/madb/src/madb/main.go",
+			},
+			[]string{"v.io/x/ref/lib/flags", "v.io/x/ref/services/device", "v.io/v23", "madb"},
+			true,
+		},
+		{
+			[]string{
+				"release/go/src/v.io/x/ref/lib/flags/flags.go",
+				"release/go/src/toplevel.go",
+				"website/content/index.md",
+				"/elsewhere/go/src/v.io/x/foo/foo.go",
+			},
+			[]string{"v.io/x/ref/lib/flags"},
+			false,
+		},
+		{[]string{"docs/README.md"}, []string{}, false},
+	}
+	for _, test := range tests {
+		pkgs, ok := ChangedPackages(root, workspaces, test.files)
+		if ok != test.ok || !reflect.DeepEqual(pkgs, test.pkgs) {
+			t.Errorf("%v: want %v %v, got %v %v", test.files, test.pkgs, test.ok, pkgs, ok)
+		}
+	}
+}
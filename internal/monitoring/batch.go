@@ -0,0 +1,205 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	cloudmonitoring "google.golang.org/api/monitoring/v3"
+)
+
+const (
+	// MaxTimeSeriesPerRequest is the maximum number of timeseries GCM
+	// accepts in a single write request.
+	MaxTimeSeriesPerRequest = 200
+
+	numWriteRetries     = 3
+	initialWriteBackoff = time.Second
+)
+
+// TimeSeriesCreator is the interface for writing timeseries to GCM. It
+// is implemented by NewGCMCreator and can be faked in tests.
+type TimeSeriesCreator interface {
+	CreateTimeSeries(project string, series []*cloudmonitoring.TimeSeries) error
+}
+
+type gcmCreator struct {
+	s *cloudmonitoring.Service
+}
+
+// NewGCMCreator returns a TimeSeriesCreator that writes timeseries using
+// the given GCM service.
+func NewGCMCreator(s *cloudmonitoring.Service) TimeSeriesCreator {
+	return &gcmCreator{s}
+}
+
+func (c *gcmCreator) CreateTimeSeries(project string, series []*cloudmonitoring.TimeSeries) error {
+	_, err := c.s.Projects.TimeSeries.Create(fmt.Sprintf("projects/%s", project), &cloudmonitoring.CreateTimeSeriesRequest{
+		TimeSeries: series,
+	}).Do()
+	return err
+}
+
+// BatchWriter buffers timeseries and writes them to GCM in batches of
+// up to MaxTimeSeriesPerRequest timeseries. Buffered timeseries are
+// written when the buffer is full, when Flush is called, and, if a flush
+// interval is given, periodically. Writes that fail with transient
+// errors are retried with exponential backoff.
+//
+// GCM rejects requests that contain more than one point of the same
+// timeseries, so a batch is also written before a second point of a
+// timeseries is buffered.
+type BatchWriter struct {
+	c       TimeSeriesCreator
+	project string
+	sleep   func(time.Duration)
+
+	mu     sync.Mutex
+	buf    []*cloudmonitoring.TimeSeries
+	keys   map[string]bool
+	errs   []error
+	stop   chan struct{}
+	stopWG sync.WaitGroup
+}
+
+// NewBatchWriter returns a BatchWriter that writes timeseries to the
+// given project using the given creator. If interval is positive, the
+// buffered timeseries are also written every interval; errors of such
+// writes are returned by the next call to Flush or Close.
+func NewBatchWriter(c TimeSeriesCreator, project string, interval time.Duration) *BatchWriter {
+	w := &BatchWriter{
+		c:       c,
+		project: project,
+		sleep:   time.Sleep,
+		keys:    map[string]bool{},
+		stop:    make(chan struct{}),
+	}
+	if interval > 0 {
+		w.stopWG.Add(1)
+		go w.flushLoop(interval)
+	}
+	return w
+}
+
+// Write buffers the given timeseries, writing the buffered timeseries
+// first if necessary.
+func (w *BatchWriter) Write(ts *cloudmonitoring.TimeSeries) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := timeSeriesKey(ts)
+	if w.keys[key] || len(w.buf) == MaxTimeSeriesPerRequest {
+		if err := w.flushLocked(); err != nil {
+			return err
+		}
+	}
+	w.buf = append(w.buf, ts)
+	w.keys[key] = true
+	return nil
+}
+
+// Flush writes the buffered timeseries. It returns the errors of any
+// periodic writes since the last call to Flush.
+func (w *BatchWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flushLocked()
+	if err == nil && len(w.errs) > 0 {
+		err = fmt.Errorf("%v", w.errs)
+	}
+	w.errs = nil
+	return err
+}
+
+// Close stops the periodic writes, if any, and writes the buffered
+// timeseries.
+func (w *BatchWriter) Close() error {
+	close(w.stop)
+	w.stopWG.Wait()
+	return w.Flush()
+}
+
+func (w *BatchWriter) flushLoop(interval time.Duration) {
+	defer w.stopWG.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			if err := w.flushLocked(); err != nil {
+				w.errs = append(w.errs, err)
+			}
+			w.mu.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// flushLocked writes the buffered timeseries, retrying transient
+// failures. The buffer is emptied even if the write fails, so that a
+// bad timeseries does not prevent later writes. The caller must hold
+// w.mu.
+func (w *BatchWriter) flushLocked() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	series := w.buf
+	w.buf, w.keys = nil, map[string]bool{}
+	backoff := initialWriteBackoff
+	var err error
+	for i := 0; ; i++ {
+		if err = w.c.CreateTimeSeries(w.project, series); err == nil || i == numWriteRetries || !isTransient(err) {
+			break
+		}
+		w.sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("writing %d timeseries failed: %v", len(series), err)
+	}
+	return nil
+}
+
+// isTransient returns whether the given write error may go away if the
+// write is retried.
+func isTransient(err error) bool {
+	if e, ok := err.(*googleapi.Error); ok {
+		return e.Code == 429 || e.Code >= 500
+	}
+	// Errors that do not come from the API are network errors.
+	return true
+}
+
+// timeSeriesKey returns a string identifying the timeseries that the
+// given timeseries writes points to.
+func timeSeriesKey(ts *cloudmonitoring.TimeSeries) string {
+	parts := []string{}
+	if ts.Metric != nil {
+		parts = appendKeyParts(parts, ts.Metric.Type, ts.Metric.Labels)
+	}
+	if ts.Resource != nil {
+		parts = appendKeyParts(parts, ts.Resource.Type, ts.Resource.Labels)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func appendKeyParts(parts []string, typ string, labels map[string]string) []string {
+	parts = append(parts, typ)
+	keys := []string{}
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, key+"="+labels[key])
+	}
+	return parts
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package monitoring
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	cloudmonitoring "google.golang.org/api/monitoring/v3"
+)
+
+// fakeCreator is a TimeSeriesCreator that records the sizes of the
+// batches it is called with and fails with the given errors first.
+type fakeCreator struct {
+	batches []int
+	errs    []error
+}
+
+func (f *fakeCreator) CreateTimeSeries(project string, series []*cloudmonitoring.TimeSeries) error {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	f.batches = append(f.batches, len(series))
+	return nil
+}
+
+func newBatchWriter(c TimeSeriesCreator) (*BatchWriter, *[]time.Duration) {
+	w := NewBatchWriter(c, "vanadium-production", 0)
+	sleeps := []time.Duration{}
+	w.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return w, &sleeps
+}
+
+func TestBatchWriter(t *testing.T) {
+	c := &fakeCreator{}
+	w, _ := newBatchWriter(c)
+	for i := 0; i < MaxTimeSeriesPerRequest+10; i++ {
+		ts := newTimeSeries(map[string]string{"metric_name": fmt.Sprintf("m%d", i)}, nil)
+		if err := w.Write(ts); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	// A second point of a buffered timeseries starts a new batch.
+	if err := w.Write(newTimeSeries(map[string]string{"metric_name": "m205"}, nil)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := c.batches, []int{MaxTimeSeriesPerRequest, 10, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestBatchWriterRetries(t *testing.T) {
+	transient := &googleapi.Error{Code: 503}
+	c := &fakeCreator{errs: []error{transient, transient}}
+	w, sleeps := newBatchWriter(c)
+	if err := w.Write(newTimeSeries(nil, nil)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := *sleeps, []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// Permanent errors are not retried.
+	c = &fakeCreator{errs: []error{&googleapi.Error{Code: 400}}}
+	w, sleeps = newBatchWriter(c)
+	if err := w.Write(newTimeSeries(nil, nil)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := w.Flush(); err == nil {
+		t.Fatalf("flushing did not fail")
+	}
+	if got := len(*sleeps); got != 0 {
+		t.Fatalf("want no retries, got %v", got)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cloudmonitoring "google.golang.org/api/monitoring/v3"
+)
+
+// TimeSeriesLister is the interface for reading a page of timeseries
+// from GCM. It is implemented by NewGCMLister and can be faked in tests.
+type TimeSeriesLister interface {
+	ListTimeSeries(project, filter string, start, end time.Time, pageToken string) (*cloudmonitoring.ListTimeSeriesResponse, error)
+}
+
+type gcmLister struct {
+	s *cloudmonitoring.Service
+}
+
+// NewGCMLister returns a TimeSeriesLister that reads timeseries using
+// the given GCM service.
+func NewGCMLister(s *cloudmonitoring.Service) TimeSeriesLister {
+	return &gcmLister{s}
+}
+
+func (l *gcmLister) ListTimeSeries(project, filter string, start, end time.Time, pageToken string) (*cloudmonitoring.ListTimeSeriesResponse, error) {
+	return l.s.Projects.TimeSeries.List(fmt.Sprintf("projects/%s", project)).
+		IntervalStartTime(start.UTC().Format(time.RFC3339)).
+		IntervalEndTime(end.UTC().Format(time.RFC3339)).
+		Filter(filter).
+		PageToken(pageToken).Do()
+}
+
+// Query identifies the timeseries of a metric within a time window.
+type Query struct {
+	// Project is the GCM project to read from.
+	Project string
+	// MetricType is the type of the metric, e.g.
+	// "custom.googleapis.com/vanadium/service/latency".
+	MetricType string
+	// Labels restricts the timeseries to those whose metric labels
+	// have the given values.
+	Labels map[string]string
+	// Start and End identify the time window.
+	Start, End time.Time
+}
+
+// Filter returns the GCM filter expression for the query.
+func (q Query) Filter() string {
+	filters := []string{fmt.Sprintf("metric.type=%q", q.MetricType)}
+	keys := []string{}
+	for key := range q.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		filters = append(filters, fmt.Sprintf("metric.label.%s=%q", key, q.Labels[key]))
+	}
+	return strings.Join(filters, " AND ")
+}
+
+// ListTimeSeries returns all the timeseries matching the given query,
+// reading all pages of the response.
+func ListTimeSeries(l TimeSeriesLister, q Query) ([]*cloudmonitoring.TimeSeries, error) {
+	result := []*cloudmonitoring.TimeSeries{}
+	filter := q.Filter()
+	pageToken := ""
+	for {
+		resp, err := l.ListTimeSeries(q.Project, filter, q.Start, q.End, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("List(%q) failed: %v", filter, err)
+		}
+		result = append(result, resp.TimeSeries...)
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Point is a single timestamped value of a timeseries.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// LabeledPoint is a point along with the labels of its timeseries.
+type LabeledPoint struct {
+	Labels map[string]string
+	Point
+}
+
+// Points returns the points of the given timeseries sorted by time.
+// The time of each point is the end time of its interval.
+func Points(ts *cloudmonitoring.TimeSeries) ([]Point, error) {
+	points := []Point{}
+	for _, pt := range ts.Points {
+		t, err := time.Parse(time.RFC3339, pt.Interval.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("Parse(%s) failed: %v", pt.Interval.EndTime, err)
+		}
+		value := pt.Value.DoubleValue
+		if pt.Value.Int64Value != 0 {
+			value = float64(pt.Value.Int64Value)
+		}
+		points = append(points, Point{Time: t, Value: value})
+	}
+	sort.Sort(pointsByTime(points))
+	return points, nil
+}
+
+// AllPoints returns the points of all the given timeseries sorted by
+// time, e.g. those of a query whose timeseries GCM returns in several
+// parts or that matches several timeseries.
+func AllPoints(series []*cloudmonitoring.TimeSeries) ([]Point, error) {
+	points := []Point{}
+	for _, ts := range series {
+		tsPoints, err := Points(ts)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, tsPoints...)
+	}
+	sort.Sort(pointsByTime(points))
+	return points, nil
+}
+
+// LatestPoints returns the latest point of each timeseries matching
+// the given query, along with the labels that identify the timeseries.
+// Timeseries without points are skipped.
+func LatestPoints(l TimeSeriesLister, q Query) ([]LabeledPoint, error) {
+	series, err := ListTimeSeries(l, q)
+	if err != nil {
+		return nil, err
+	}
+	result := []LabeledPoint{}
+	for _, ts := range series {
+		points, err := Points(ts)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) == 0 {
+			continue
+		}
+		labels := map[string]string{}
+		if ts.Metric != nil {
+			labels = ts.Metric.Labels
+		}
+		result = append(result, LabeledPoint{
+			Labels: labels,
+			Point:  points[len(points)-1],
+		})
+	}
+	return result, nil
+}
+
+type pointsByTime []Point
+
+func (p pointsByTime) Len() int           { return len(p) }
+func (p pointsByTime) Less(i, j int) bool { return p[i].Time.Before(p[j].Time) }
+func (p pointsByTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
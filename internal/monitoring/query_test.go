@@ -0,0 +1,184 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package monitoring
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	cloudmonitoring "google.golang.org/api/monitoring/v3"
+)
+
+// fakeLister is a TimeSeriesLister that returns pages of pageSize
+// timeseries, or one timeseries if pageSize is zero, and records the
+// filters it was called with.
+type fakeLister struct {
+	series   []*cloudmonitoring.TimeSeries
+	pageSize int
+	filters  []string
+	err      error
+}
+
+func (f *fakeLister) ListTimeSeries(project, filter string, start, end time.Time, pageToken string) (*cloudmonitoring.ListTimeSeriesResponse, error) {
+	f.filters = append(f.filters, filter)
+	if f.err != nil {
+		return nil, f.err
+	}
+	index := 0
+	if pageToken != "" {
+		fmt.Sscanf(pageToken, "%d", &index)
+	}
+	size := f.pageSize
+	if size == 0 {
+		size = 1
+	}
+	last := index + size
+	if last > len(f.series) {
+		last = len(f.series)
+	}
+	resp := &cloudmonitoring.ListTimeSeriesResponse{}
+	if index < last {
+		resp.TimeSeries = f.series[index:last]
+	}
+	if last < len(f.series) {
+		resp.NextPageToken = fmt.Sprintf("%d", last)
+	}
+	return resp, nil
+}
+
+func newTimeSeries(labels map[string]string, values map[string]float64) *cloudmonitoring.TimeSeries {
+	ts := &cloudmonitoring.TimeSeries{
+		Metric: &cloudmonitoring.Metric{Labels: labels},
+	}
+	for endTime, value := range values {
+		ts.Points = append(ts.Points, &cloudmonitoring.Point{
+			Interval: &cloudmonitoring.TimeInterval{EndTime: endTime},
+			Value:    &cloudmonitoring.TypedValue{DoubleValue: value},
+		})
+	}
+	return ts
+}
+
+func TestQueryFilter(t *testing.T) {
+	q := Query{
+		MetricType: "custom.googleapis.com/vanadium/service/latency",
+		Labels: map[string]string{
+			"metric_name":  "mounttable",
+			"gce_instance": "vanadium-mounttable-1",
+		},
+	}
+	want := `metric.type="custom.googleapis.com/vanadium/service/latency" AND metric.label.gce_instance="vanadium-mounttable-1" AND metric.label.metric_name="mounttable"`
+	if got := q.Filter(); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestListTimeSeries(t *testing.T) {
+	l := &fakeLister{
+		series: []*cloudmonitoring.TimeSeries{
+			newTimeSeries(map[string]string{"gce_zone": "us-central1-c"}, nil),
+			newTimeSeries(map[string]string{"gce_zone": "us-central1-f"}, nil),
+			newTimeSeries(map[string]string{"gce_zone": "us-east1-b"}, nil),
+		},
+	}
+	got, err := ListTimeSeries(l, Query{MetricType: "m"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, l.series) {
+		t.Fatalf("got %v, want %v", got, l.series)
+	}
+	if got, want := len(l.filters), 3; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+
+	l = &fakeLister{series: l.series, pageSize: 2}
+	if got, err := ListTimeSeries(l, Query{MetricType: "m"}); err != nil || !reflect.DeepEqual(got, l.series) {
+		t.Fatalf("got %v, %v, want %v", got, err, l.series)
+	}
+	if got, want := len(l.filters), 2; got != want {
+		t.Fatalf("got %d requests, want %d", got, want)
+	}
+
+	l = &fakeLister{err: fmt.Errorf("unavailable")}
+	if _, err := ListTimeSeries(l, Query{MetricType: "m"}); err == nil {
+		t.Fatalf("ListTimeSeries() did not fail")
+	}
+}
+
+func TestAllPoints(t *testing.T) {
+	labels := map[string]string{"gce_instance": "instance-1"}
+	series := []*cloudmonitoring.TimeSeries{
+		newTimeSeries(labels, map[string]float64{
+			"2016-03-01T10:10:00Z": 3,
+			"2016-03-01T10:00:00Z": 1,
+		}),
+		newTimeSeries(labels, map[string]float64{
+			"2016-03-01T10:05:00Z": 2,
+		}),
+		newTimeSeries(labels, nil),
+	}
+	got, err := AllPoints(series)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []Point{
+		{Time: time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC), Value: 1},
+		{Time: time.Date(2016, 3, 1, 10, 5, 0, 0, time.UTC), Value: 2},
+		{Time: time.Date(2016, 3, 1, 10, 10, 0, 0, time.UTC), Value: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, err := AllPoints([]*cloudmonitoring.TimeSeries{newTimeSeries(labels, map[string]float64{"yesterday": 1})}); err == nil {
+		t.Fatalf("AllPoints() did not fail for an invalid timestamp")
+	}
+}
+
+func TestLatestPoints(t *testing.T) {
+	labels1 := map[string]string{"gce_instance": "instance-1"}
+	labels2 := map[string]string{"gce_instance": "instance-2"}
+	l := &fakeLister{
+		series: []*cloudmonitoring.TimeSeries{
+			newTimeSeries(labels1, map[string]float64{
+				"2016-03-01T10:00:00Z": 1,
+				"2016-03-01T10:10:00Z": 3,
+				"2016-03-01T10:05:00Z": 2,
+			}),
+			newTimeSeries(labels2, map[string]float64{
+				"2016-03-01T09:00:00Z": 10,
+			}),
+			newTimeSeries(map[string]string{"gce_instance": "instance-3"}, nil),
+		},
+	}
+	got, err := LatestPoints(l, Query{MetricType: "m"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []LabeledPoint{
+		{
+			Labels: labels1,
+			Point:  Point{Time: time.Date(2016, 3, 1, 10, 10, 0, 0, time.UTC), Value: 3},
+		},
+		{
+			Labels: labels2,
+			Point:  Point{Time: time.Date(2016, 3, 1, 9, 0, 0, 0, time.UTC), Value: 10},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	l = &fakeLister{
+		series: []*cloudmonitoring.TimeSeries{
+			newTimeSeries(labels1, map[string]float64{"yesterday": 1}),
+		},
+	}
+	if _, err := LatestPoints(l, Query{MetricType: "m"}); err == nil {
+		t.Fatalf("LatestPoints() did not fail for an invalid timestamp")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package revisions implements encoding and decoding of the revision
+// ranges of the changes tested by postsubmit builds, which "postsubmit
+// poll" passes to the builds in their REVISIONS parameter.
+package revisions
+
+import (
+	"fmt"
+	"strings"
+
+	"v.io/jiri/jenkins"
+)
+
+// Parameter is the name of the build parameter that holds the revision
+// ranges.
+const Parameter = "REVISIONS"
+
+// Range identifies the changes of a project tested by a build.
+type Range struct {
+	Project string
+	// Old is the revision of the project tested by the previous
+	// build, or empty if the project is new.
+	Old string
+	// New is the revision of the project tested by the build.
+	New string
+}
+
+// String returns the <project>=<old>..<new> representation of the
+// range.
+func (r Range) String() string {
+	return fmt.Sprintf("%s=%s..%s", r.Project, r.Old, r.New)
+}
+
+// Format returns the ':'-separated representation of the given ranges.
+func Format(ranges []Range) string {
+	strs := []string{}
+	for _, r := range ranges {
+		strs = append(strs, r.String())
+	}
+	return strings.Join(strs, ":")
+}
+
+// Parse parses the given ':'-separated <project>=<old>..<new> revision
+// ranges, ignoring malformed ones.
+func Parse(revisions string) []Range {
+	ranges := []Range{}
+	for _, r := range strings.Split(revisions, ":") {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		revs := strings.SplitN(parts[1], "..", 2)
+		if len(revs) != 2 {
+			continue
+		}
+		ranges = append(ranges, Range{Project: parts[0], Old: revs[0], New: revs[1]})
+	}
+	return ranges
+}
+
+// FromBuild returns the value of the REVISIONS parameter of the given
+// build, or an empty string if it has none.
+func FromBuild(info *jenkins.BuildInfo) string {
+	for _, action := range info.Actions {
+		for _, param := range action.Parameters {
+			if param.Name == Parameter {
+				return param.Value
+			}
+		}
+	}
+	return ""
+}
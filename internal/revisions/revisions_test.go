@@ -0,0 +1,44 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package revisions
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/jiri/jenkins"
+)
+
+func TestParse(t *testing.T) {
+	revisions := "release.go.core=abc..def:release.js.core=..123"
+	want := []Range{
+		{Project: "release.go.core", Old: "abc", New: "def"},
+		{Project: "release.js.core", New: "123"},
+	}
+	if got := Parse(revisions + ":malformed:release.go.x=abc"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got := Format(want); got != revisions {
+		t.Errorf("want %q, got %q", revisions, got)
+	}
+}
+
+func TestFromBuild(t *testing.T) {
+	info := &jenkins.BuildInfo{
+		Actions: []jenkins.BuildInfoAction{
+			{},
+			{Parameters: []jenkins.BuildInfoParameter{
+				{Name: "PROJECTS", Value: "release.go.core"},
+				{Name: "REVISIONS", Value: "release.go.core=abc..def"},
+			}},
+		},
+	}
+	if got, want := FromBuild(info), "release.go.core=abc..def"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := FromBuild(&jenkins.BuildInfo{}); got != "" {
+		t.Errorf("want empty revisions, got %q", got)
+	}
+}
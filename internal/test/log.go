@@ -0,0 +1,182 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"v.io/jiri/tool"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Set implements flag.Value.
+func (l *Level) Set(value string) error {
+	for level := LevelDebug; level <= LevelError; level++ {
+		if level.String() == value {
+			*l = level
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q", value)
+}
+
+var (
+	// logJSON records whether log messages are printed as JSON objects,
+	// one per line, instead of text.
+	logJSON bool
+	// logLevel is the minimum level of the printed log messages.
+	logLevel = LevelInfo
+	// logToolName is the name of the tool recorded in JSON log messages.
+	logToolName string
+	// logNow returns the time recorded in JSON log messages.
+	logNow = time.Now
+)
+
+// InitializeLogFlags registers the flags that control the log messages
+// printed by the given tool, including the messages printed by Pass,
+// Fail and Warn, with the given flag set.
+func InitializeLogFlags(flags *flag.FlagSet, toolName string) {
+	logToolName = toolName
+	flags.BoolVar(&logJSON, "log-json", false, "Print log messages as JSON objects, one per line.")
+	flags.Var(&logLevel, "log-level", "Minimum level of the log messages to print, one of debug, info, warn or error.")
+}
+
+// LogJSON returns whether log messages are printed as JSON objects.
+func LogJSON() bool {
+	return logJSON
+}
+
+// LogFlags returns the flags registered by InitializeLogFlags that make
+// another tool print its log messages the same way as this one, for use
+// when the tool is run by this one.
+func LogFlags() []string {
+	flags := []string{"-log-level=" + logLevel.String()}
+	if logJSON {
+		flags = append(flags, "-log-json")
+	}
+	return flags
+}
+
+// Logger prints log messages of a tool, or of a test run by a tool, to
+// the standard output and error of a context. Debug and info messages go
+// to the standard output, while warnings and errors go to the standard
+// error.
+type Logger struct {
+	ctx  *tool.Context
+	test string
+}
+
+// NewLogger returns a logger that prints to the given context.
+func NewLogger(ctx *tool.Context) *Logger {
+	return &Logger{ctx: ctx}
+}
+
+// WithTest returns a logger that prefixes its messages with the name of
+// the given test.
+func (l *Logger) WithTest(test string) *Logger {
+	return &Logger{ctx: l.ctx, test: test}
+}
+
+// Debugf prints a debug message, which is only printed in verbose mode
+// or with -log-level=debug.
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.logf(LevelDebug, "debug", White, format, a...)
+}
+
+// Infof prints an informational message.
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.logf(LevelInfo, "info", Blue, format, a...)
+}
+
+// Warnf prints a warning.
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.logf(LevelWarn, "warn", Yellow, format, a...)
+}
+
+// Errorf prints an error message.
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.logf(LevelError, "error", Red, format, a...)
+}
+
+// logRecord is the JSON representation of a log message.
+type logRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Tool    string `json:"tool,omitempty"`
+	Test    string `json:"test,omitempty"`
+	Message string `json:"message"`
+}
+
+// logf prints a message of the given level. In text mode, the message
+// is preceded by the given tag, which is colored with the given color if
+// the context uses colors.
+func (l *Logger) logf(level Level, tag string, color Color, format string, a ...interface{}) {
+	if level < logLevel && !(level == LevelDebug && l.ctx.Verbose()) {
+		return
+	}
+	var w io.Writer = l.ctx.Stdout()
+	if level >= LevelWarn {
+		w = l.ctx.Stderr()
+	}
+	msg := fmt.Sprintf(format, a...)
+	if logJSON {
+		bytes, err := json.Marshal(logRecord{
+			Time:    logNow().UTC().Format(time.RFC3339),
+			Level:   level.String(),
+			Tool:    logToolName,
+			Test:    l.test,
+			Message: strings.TrimSuffix(msg, "\n"),
+		})
+		if err != nil {
+			fmt.Fprintf(l.ctx.Stderr(), "Marshal() failed: %v\n", err)
+			return
+		}
+		fmt.Fprintf(w, "%s\n", bytes)
+		return
+	}
+	// Short tags are padded, so that the messages of Pass, Fail and Warn
+	// line up.
+	padding := " "
+	if len(tag) < 4 {
+		padding += strings.Repeat(" ", 4-len(tag))
+	}
+	if l.ctx.Color() {
+		tag = ColorString(tag, color)
+	}
+	prefix := ""
+	if l.test != "" {
+		prefix = l.test + ": "
+	}
+	fmt.Fprintf(w, "%s%s%s%s", tag, padding, prefix, msg)
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/jiri/tool"
+)
+
+func newLogContext(stdout, stderr *bytes.Buffer) *tool.Context {
+	color, verbose := false, false
+	return tool.NewContext(tool.ContextOpts{
+		Color:   &color,
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Verbose: &verbose,
+	})
+}
+
+func TestLoggerText(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	ctx := newLogContext(&stdout, &stderr)
+	Pass(ctx, "%s\n", "check")
+	Fail(ctx, "%s\n", "check")
+	logger := NewLogger(ctx).WithTest("vanadium-go-test")
+	logger.Debugf("hidden\n")
+	logger.Infof("running\n")
+	logger.Errorf("failed\n")
+	if got, want := stdout.String(), "ok   check\ninfo vanadium-go-test: running\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := stderr.String(), "fail check\nerror vanadium-go-test: failed\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	defer func(json bool, level Level, toolName string, now func() time.Time) {
+		logJSON, logLevel, logToolName, logNow = json, level, toolName, now
+	}(logJSON, logLevel, logToolName, logNow)
+	logJSON, logToolName = true, "vmon"
+	logNow = func() time.Time { return time.Unix(1460000000, 0) }
+	if err := logLevel.Set("debug"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	ctx := newLogContext(&stdout, &stderr)
+	NewLogger(ctx).WithTest("cert-expiry").Debugf("line 1\nline 2\n")
+	Warn(ctx, "slow\n")
+	if got, want := stdout.String(), `{"time":"2016-04-07T03:33:20Z","level":"debug","tool":"vmon","test":"cert-expiry","message":"line 1\nline 2"}`+"\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := stderr.String(), `{"time":"2016-04-07T03:33:20Z","level":"warn","tool":"vmon","message":"slow"}`+"\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := LogFlags(), []string{"-log-level=debug", "-log-json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if err := logLevel.Set("verbose"); err == nil {
+		t.Errorf("setting an unknown level did not fail")
+	}
+}
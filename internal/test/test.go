@@ -5,7 +5,6 @@
 package test
 
 import (
-	"fmt"
 	"time"
 
 	"v.io/jiri/tool"
@@ -25,9 +24,14 @@ type Result struct {
 	Status               Status
 	TimeoutValue         time.Duration       // Used when Status == TimedOut
 	MergeConflictCL      string              // Used when Status == MergeConflict
+	MergeConflictFiles   []string            // Used when Status == MergeConflict, lists the conflicting files of MergeConflictCL
+	AutoRebasedCLs       []string            // CLs that were tested after a clean automatic rebase
 	ToolsBuildFailureMsg string              // Used when Status == ToolsBuildFailure
+	DependencyCLs        []string            // CLs that were tested because the tested CLs depend on them
+	DependencyFailureMsg string              // Used when Status == DependencyFailure
 	ExcludedTests        map[string][]string // Tests that are excluded within packages keyed by package name
 	SkippedTests         map[string][]string // Tests that are skipped within packages keyed by package name
+	Unaffected           bool                // Used when Status == Skipped, set if the test does not depend on the changed files
 }
 
 const (
@@ -38,6 +42,7 @@ const (
 	MergeConflict
 	ToolsBuildFailure
 	TimedOut
+	DependencyFailure
 )
 
 func (s Status) String() string {
@@ -57,29 +62,19 @@ func (s Status) String() string {
 	}
 }
 
+// Pass prints a message that reports a successful check. It is logged
+// at the info level.
 func Pass(ctx *tool.Context, format string, a ...interface{}) {
-	strOK := "ok"
-	if ctx.Color() {
-		strOK = ColorString("ok", Green)
-	}
-	fmt.Fprintf(ctx.Stdout(), "%s   ", strOK)
-	fmt.Fprintf(ctx.Stdout(), format, a...)
+	NewLogger(ctx).logf(LevelInfo, "ok", Green, format, a...)
 }
 
+// Fail prints a message that reports a failed check. It is logged at
+// the error level.
 func Fail(ctx *tool.Context, format string, a ...interface{}) {
-	strFail := "fail"
-	if ctx.Color() {
-		strFail = ColorString("fail", Red)
-	}
-	fmt.Fprintf(ctx.Stderr(), "%s ", strFail)
-	fmt.Fprintf(ctx.Stderr(), format, a...)
+	NewLogger(ctx).logf(LevelError, "fail", Red, format, a...)
 }
 
+// Warn prints a warning. It is logged at the warn level.
 func Warn(ctx *tool.Context, format string, a ...interface{}) {
-	strWarn := "warn"
-	if ctx.Color() {
-		strWarn = ColorString("warn", Yellow)
-	}
-	fmt.Fprintf(ctx.Stderr(), "%s ", strWarn)
-	fmt.Fprintf(ctx.Stderr(), format, a...)
+	NewLogger(ctx).logf(LevelWarn, "warn", Yellow, format, a...)
 }
@@ -0,0 +1,123 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/x/lib/envvar"
+)
+
+// bigQueryBatchSize is the maximum number of rows streamed into
+// BigQuery by a single "bq insert" invocation.
+const bigQueryBatchSize = 500
+
+// bigQueryRow is a row of the BigQuery table that test results are
+// exported to. Each row describes a test case of an xUnit report.
+type bigQueryRow struct {
+	// Test is the name of the jiri test that produced the report.
+	Test    string `json:"test"`
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	// Status is one of "passed", "failed", "error" and "skipped".
+	Status string `json:"status"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration"`
+	// Label holds the labels of the Jenkins node the test ran on.
+	Label       string `json:"label,omitempty"`
+	BuildNumber int    `json:"build_number,omitempty"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// BigQueryExporter returns a report hook that streams a row for each
+// test case of the reports into the given BigQuery table, which is
+// identified as <project>:<dataset>.<table>, using the "bq" tool. If
+// the given key file is not empty, the service account whose key it
+// holds is activated for each export in a private gcloud configuration
+// directory, so that the account of the user or host is not changed.
+func BigQueryExporter(table, keyFile string) ReportHook {
+	return func(jirix *jiri.X, testName string, suites []TestSuite) (e error) {
+		s := jirix.NewSeq()
+		env := jirix.Env()
+		if keyFile != "" {
+			configDir, err := s.TempDir("", "bigquery-gcloud")
+			if err != nil {
+				return err
+			}
+			defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(configDir).Done() }, &e)
+			env = envvar.MergeMaps(env, map[string]string{"CLOUDSDK_CONFIG": configDir})
+			var out bytes.Buffer
+			if err := s.Env(env).Capture(&out, &out).Last("gcloud", "auth", "activate-service-account", "--key-file="+keyFile); err != nil {
+				return fmt.Errorf("activating the service account of %v failed: %v\n%v", keyFile, err, out.String())
+			}
+		}
+		buildNumber, _ := strconv.Atoi(os.Getenv("BUILD_NUMBER"))
+		rows := bigQueryRows(testName, suites, buildNumber, time.Now())
+		for len(rows) > 0 {
+			n := len(rows)
+			if n > bigQueryBatchSize {
+				n = bigQueryBatchSize
+			}
+			var in, out bytes.Buffer
+			encoder := json.NewEncoder(&in)
+			for _, row := range rows[:n] {
+				if err := encoder.Encode(row); err != nil {
+					return fmt.Errorf("Encode(%v) failed: %v", row, err)
+				}
+			}
+			if err := s.Env(env).Read(&in).Capture(&out, &out).Last("bq", "insert", table); err != nil {
+				return fmt.Errorf("exporting the results of %v to %v failed: %v\n%v", testName, table, err, out.String())
+			}
+			rows = rows[n:]
+		}
+		return nil
+	}
+}
+
+// bigQueryRows returns the BigQuery rows that describe the test cases
+// of the given test suites, which were produced by the given test and
+// Jenkins build at the given time.
+func bigQueryRows(testName string, suites []TestSuite, buildNumber int, now time.Time) []bigQueryRow {
+	timestamp := now.UTC().Format("2006-01-02 15:04:05 UTC")
+	rows := []bigQueryRow{}
+	for _, suite := range suites {
+		label := ""
+		for _, p := range suite.Properties {
+			if p.Name == "jenkins.labels" {
+				label = p.Value
+			}
+		}
+		for _, c := range suite.AllCases() {
+			status := "passed"
+			switch {
+			case len(c.Errors) > 0:
+				status = "error"
+			case len(c.Failures) > 0:
+				status = "failed"
+			case len(c.Skipped) > 0:
+				status = "skipped"
+			}
+			duration, _ := strconv.ParseFloat(c.Time, 64)
+			rows = append(rows, bigQueryRow{
+				Test:        testName,
+				Name:        c.Name,
+				Package:     c.Classname,
+				Status:      status,
+				Duration:    duration,
+				Label:       label,
+				BuildNumber: buildNumber,
+				Timestamp:   timestamp,
+			})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBigQueryRows(t *testing.T) {
+	slow := newCase(passed, "v.io/x/foo", "TestSlow")
+	slow.Time = "12.50"
+	suites := []TestSuite{
+		TestSuite{
+			Name:       "v.io/x/foo",
+			Properties: []Property{{Name: "jenkins.labels", Value: "linux-amd64"}},
+			Cases:      []TestCase{slow, newCase(failed, "v.io/x/foo", "TestA"), newCase(errored, "v.io/x/foo", "TestPanic")},
+			Suites: []TestSuite{
+				TestSuite{
+					Name:  "TestB",
+					Cases: []TestCase{newCase(skipped, "v.io/x/foo", "TestB/small")},
+				},
+			},
+		},
+		TestSuite{
+			Name:  "v.io/x/bar",
+			Cases: []TestCase{newCase(passed, "v.io/x/bar", "TestC")},
+		},
+	}
+	now := time.Date(2016, 3, 4, 5, 6, 7, 0, time.FixedZone("PST", -8*60*60))
+	got := bigQueryRows("vanadium-go-test", suites, 42, now)
+	row := func(name, pkg, status string, duration float64, label string) bigQueryRow {
+		return bigQueryRow{
+			Test:        "vanadium-go-test",
+			Name:        name,
+			Package:     pkg,
+			Status:      status,
+			Duration:    duration,
+			Label:       label,
+			BuildNumber: 42,
+			Timestamp:   "2016-03-04 13:06:07 UTC",
+		}
+	}
+	want := []bigQueryRow{
+		row("TestSlow", "v.io/x/foo", "passed", 12.5, "linux-amd64"),
+		row("TestA", "v.io/x/foo", "failed", 0, "linux-amd64"),
+		row("TestPanic", "v.io/x/foo", "error", 0, "linux-amd64"),
+		row("TestB/small", "v.io/x/foo", "skipped", 0, "linux-amd64"),
+		row("TestC", "v.io/x/bar", "passed", 0, ""),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	goTestResultRE    = regexp.MustCompile(`^(\s*)--- (PASS|FAIL|SKIP): (\S+) \((\d+(?:\.\d+)?)(?: seconds|s)\)$`)
+	goTestPackageRE   = regexp.MustCompile(`^(?:ok|FAIL)\s+(\S+)\s`)
+	goTestLogPrefixRE = regexp.MustCompile(`^\S+\.go:\d+: `)
+)
+
+// goTestResult is the result of a test or subtest reported by
+// "go test -v".
+type goTestResult struct {
+	// pkg is the package of the test, or empty if the output does
+	// not identify it.
+	pkg    string
+	name   string
+	status string
+	time   string
+	// output holds the lines logged by the test, without their
+	// indentation.
+	output []string
+}
+
+// parseGoTestResults returns the results of the tests and subtests
+// reported in the given output of "go test -v", in the order in which
+// they are reported. The lines logged by a test follow its result and
+// are indented more deeply than the result. The package of a test is
+// identified by the "ok" or "FAIL" line that follows the results of
+// the package.
+func parseGoTestResults(output string) []goTestResult {
+	results := []goTestResult{}
+	var cur *goTestResult
+	indent, pkgStart := "", 0
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if matches := goTestPackageRE.FindStringSubmatch(line); matches != nil {
+			for i := pkgStart; i < len(results); i++ {
+				results[i].pkg = matches[1]
+			}
+			cur, pkgStart = nil, len(results)
+			continue
+		}
+		if matches := goTestResultRE.FindStringSubmatch(line); matches != nil {
+			results = append(results, goTestResult{name: matches[3], status: matches[2], time: matches[4]})
+			cur, indent = &results[len(results)-1], matches[1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, indent) || !isIndented(line[len(indent):]) {
+			cur = nil
+			continue
+		}
+		cur.output = append(cur.output, strings.TrimSpace(line))
+	}
+	return results
+}
+
+// isIndented returns whether the given line starts with whitespace.
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// skipReason returns the reason a skipped test gave for skipping,
+// i.e. the lines it logged without their file and line prefixes.
+func (r goTestResult) skipReason() string {
+	lines := make([]string, len(r.output))
+	for i, line := range r.output {
+		lines[i] = goTestLogPrefixRE.ReplaceAllString(line, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addGoTestDetails adds the details that go2xunit drops to the given
+// suites, which it generated from the given output of "go test -v".
+// The reasons of skipped tests are recorded in their skipped elements,
+// and the results of subtests, which are named "<parent>/<name>", are
+// added as test cases of the suite and class of their parent tests.
+// The failures of subtests are not counted in the failures of the
+// suites, as their parent tests fail as well.
+func addGoTestDetails(suites []*TestSuite, output string) {
+	type location struct {
+		suite *TestSuite
+		index int
+	}
+	cases := map[string]location{}
+	// The output of a single test binary does not identify its
+	// package, which is unambiguous if all the test cases are of the
+	// same class.
+	onlyClassname, ambiguous := "", false
+	for _, s := range suites {
+		for i, c := range s.Cases {
+			cases[c.Classname+"\x00"+c.Name] = location{s, i}
+			if onlyClassname == "" {
+				onlyClassname = c.Classname
+			} else if c.Classname != onlyClassname {
+				ambiguous = true
+			}
+		}
+	}
+	for _, r := range parseGoTestResults(output) {
+		classname := r.pkg
+		if classname == "" {
+			if ambiguous {
+				continue
+			}
+			classname = onlyClassname
+		}
+		if loc, ok := cases[classname+"\x00"+r.name]; ok {
+			c := &loc.suite.Cases[loc.index]
+			if r.status == "SKIP" && len(c.Skipped) > 0 && strings.TrimSpace(strings.Join(c.Skipped, "")) == "" {
+				c.Skipped = []string{r.skipReason()}
+			}
+			continue
+		}
+		i := strings.LastIndex(r.name, "/")
+		if i == -1 {
+			continue
+		}
+		parent, ok := cases[classname+"\x00"+r.name[:i]]
+		if !ok {
+			continue
+		}
+		c := TestCase{Classname: classname, Name: r.name, Time: r.time}
+		switch r.status {
+		case "FAIL":
+			c.Failures = []Failure{{Message: "error", Data: strings.Join(r.output, "\n")}}
+		case "SKIP":
+			c.Skipped = []string{r.skipReason()}
+		}
+		s := parent.suite
+		s.Cases = append(s.Cases, c)
+		s.Tests++
+		if len(c.Skipped) > 0 {
+			s.Skip++
+		}
+		cases[classname+"\x00"+r.name] = location{s, len(s.Cases) - 1}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddGoTestDetails(t *testing.T) {
+	output := `=== RUN   TestA
+=== RUN   TestA/ipv4
+=== RUN   TestA/ipv6
+--- FAIL: TestA (0.01s)
+    --- PASS: TestA/ipv4 (0.00s)
+    --- FAIL: TestA/ipv6 (0.01s)
+        --- SKIP: TestA/ipv6/loopback (0.00s)
+        	a_test.go:20: requires IPv6
+        --- FAIL: TestA/ipv6/remote (0.01s)
+        	a_test.go:25: dial failed
+=== RUN   TestB
+--- SKIP: TestB (0.00s)
+	b_test.go:10: skipping in short mode
+	b_test.go:11: see v.io/i/123
+=== RUN   TestC
+--- PASS: TestC (0.02s)
+FAIL
+exit status 1
+FAIL	v.io/x/foo	0.030s
+`
+	// go2xunit reports the top-level tests only, without skip reasons.
+	suite := &TestSuite{
+		Name: "v.io/x/foo",
+		Cases: []TestCase{
+			newCase(failed, "v.io/x/foo", "TestA"),
+			newCase(skipped, "v.io/x/foo", "TestB"),
+			newCase(passed, "v.io/x/foo", "TestC"),
+		},
+		Tests:    3,
+		Failures: 1,
+		Skip:     1,
+	}
+	addGoTestDetails([]*TestSuite{suite}, output)
+	want := &TestSuite{
+		Name: "v.io/x/foo",
+		Cases: []TestCase{
+			newCase(failed, "v.io/x/foo", "TestA"),
+			newCase(TestCase{Skipped: []string{"skipping in short mode\nsee v.io/i/123"}}, "v.io/x/foo", "TestB"),
+			newCase(passed, "v.io/x/foo", "TestC"),
+			newCase(TestCase{Time: "0.00"}, "v.io/x/foo", "TestA/ipv4"),
+			newCase(TestCase{Time: "0.01", Failures: []Failure{{Message: "error"}}}, "v.io/x/foo", "TestA/ipv6"),
+			newCase(TestCase{Time: "0.00", Skipped: []string{"requires IPv6"}}, "v.io/x/foo", "TestA/ipv6/loopback"),
+			newCase(TestCase{Time: "0.01", Failures: []Failure{{Message: "error", Data: "a_test.go:25: dial failed"}}}, "v.io/x/foo", "TestA/ipv6/remote"),
+		},
+		Tests:    7,
+		Failures: 1,
+		Skip:     2,
+	}
+	if !reflect.DeepEqual(suite, want) {
+		t.Errorf("got %#v, want %#v", suite, want)
+	}
+}
+
+func TestAddGoTestDetailsPackages(t *testing.T) {
+	output := `=== RUN   TestA
+=== RUN   TestA/sub
+--- PASS: TestA (0.00s)
+    --- PASS: TestA/sub (0.00s)
+PASS
+ok  	v.io/x/bar	0.010s
+=== RUN   TestA
+=== RUN   TestA/sub
+--- FAIL: TestA (0.00s)
+    --- FAIL: TestA/sub (0.00s)
+    	a_test.go:10: failed
+FAIL
+exit status 1
+FAIL	v.io/x/foo	0.010s
+`
+	bar := &TestSuite{Name: "v.io/x/bar", Cases: []TestCase{newCase(passed, "v.io/x/bar", "TestA")}, Tests: 1}
+	foo := &TestSuite{Name: "v.io/x/foo", Cases: []TestCase{newCase(failed, "v.io/x/foo", "TestA")}, Tests: 1, Failures: 1}
+	addGoTestDetails([]*TestSuite{bar, foo}, output)
+	wantBar := &TestSuite{
+		Name: "v.io/x/bar",
+		Cases: []TestCase{
+			newCase(passed, "v.io/x/bar", "TestA"),
+			newCase(TestCase{Time: "0.00"}, "v.io/x/bar", "TestA/sub"),
+		},
+		Tests: 2,
+	}
+	wantFoo := &TestSuite{
+		Name: "v.io/x/foo",
+		Cases: []TestCase{
+			newCase(failed, "v.io/x/foo", "TestA"),
+			newCase(TestCase{Time: "0.00", Failures: []Failure{{Message: "error", Data: "a_test.go:10: failed"}}}, "v.io/x/foo", "TestA/sub"),
+		},
+		Tests:    2,
+		Failures: 1,
+	}
+	if !reflect.DeepEqual(bar, wantBar) {
+		t.Errorf("got %#v, want %#v", bar, wantBar)
+	}
+	if !reflect.DeepEqual(foo, wantFoo) {
+		t.Errorf("got %#v, want %#v", foo, wantFoo)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/project"
+	"v.io/x/lib/metadata"
+)
+
+const (
+	// devtoolsProjectName identifies the jiri project that contains
+	// the developer tools.
+	devtoolsProjectName = "release.go.x.devtools"
+
+	// RunIDEnv is the environment variable used to correlate the
+	// test suites produced by the same run. If it is not set, the
+	// Jenkins BUILD_TAG variable is used instead and, if that is not
+	// set either, a run ID is generated for the current process.
+	RunIDEnv = "V23_RUN_ID"
+)
+
+// PropertiesHook returns properties to be added to each test suite of
+// the xUnit reports created via CreateReport.
+type PropertiesHook func(jirix *jiri.X) []Property
+
+var (
+	hooksMu sync.Mutex
+	hooks   = []PropertiesHook{hostProperties}
+
+	runIDOnce sync.Once
+	runID     string
+
+	goVersionOnce   sync.Once
+	cachedGoVersion string
+)
+
+// RegisterPropertiesHook registers the given hook. The properties
+// returned by hooks registered later take precedence over properties
+// with the same name returned by hooks registered earlier.
+func RegisterPropertiesHook(hook PropertiesHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// addProperties returns a copy of the given test suites with the
+// properties returned by the registered hooks added to each of them.
+// Properties already present in a test suite are preserved.
+func addProperties(jirix *jiri.X, suites []TestSuite) []TestSuite {
+	hooksMu.Lock()
+	curHooks := append([]PropertiesHook{}, hooks...)
+	hooksMu.Unlock()
+
+	// Collect the properties, letting later hooks override earlier
+	// ones while preserving the order in which they were first seen.
+	names, values := []string{}, map[string]string{}
+	for _, hook := range curHooks {
+		for _, p := range hook(jirix) {
+			if _, ok := values[p.Name]; !ok {
+				names = append(names, p.Name)
+			}
+			values[p.Name] = p.Value
+		}
+	}
+
+	result := make([]TestSuite, len(suites))
+	for i, suite := range suites {
+		existing := map[string]struct{}{}
+		for _, p := range suite.Properties {
+			existing[p.Name] = struct{}{}
+		}
+		properties := append([]Property{}, suite.Properties...)
+		for _, name := range names {
+			if _, ok := existing[name]; !ok {
+				properties = append(properties, Property{Name: name, Value: values[name]})
+			}
+		}
+		suite.Properties = properties
+		result[i] = suite
+	}
+	return result
+}
+
+// hostProperties returns properties that describe the environment the
+// tests run in.
+func hostProperties(jirix *jiri.X) []Property {
+	properties := []Property{
+		{Name: "goos", Value: runtime.GOOS},
+		{Name: "goarch", Value: runtime.GOARCH},
+		{Name: "go.version", Value: goVersion(jirix)},
+		{Name: "devtools.revision", Value: devtoolsRevision()},
+		{Name: "run.id", Value: getRunID()},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		properties = append(properties, Property{Name: "hostname", Value: hostname})
+	}
+	// Describe the Jenkins node and the commit under test, if known.
+	for _, p := range jenkinsProperties {
+		if value := os.Getenv(p.env); value != "" {
+			properties = append(properties, Property{Name: p.name, Value: value})
+		}
+	}
+	return properties
+}
+
+// jenkinsProperties maps the environment variables set by Jenkins to the
+// names of the properties that record them.
+var jenkinsProperties = []struct{ env, name string }{
+	{"NODE_NAME", "jenkins.node"},
+	{"NODE_LABELS", "jenkins.labels"},
+	{"GIT_COMMIT", "git.commit"},
+	{"GIT_BRANCH", "git.branch"},
+}
+
+// devtoolsRevision returns the revision of the developer tools project
+// recorded in the build metadata of the running binary, or an empty
+// string if the revision is not available.
+func devtoolsRevision() string {
+	data := metadata.Lookup("build.Manifest")
+	if data == "" {
+		return ""
+	}
+	manifest, err := project.ManifestFromBytes([]byte(data))
+	if err != nil {
+		return ""
+	}
+	for _, p := range manifest.Projects {
+		if p.Name == devtoolsProjectName {
+			return p.Revision
+		}
+	}
+	return ""
+}
+
+// goVersion returns the version of the Go toolchain that "jiri go"
+// runs the tests with, or an empty string if the version cannot be
+// determined. The version is looked up once per process.
+func goVersion(jirix *jiri.X) string {
+	goVersionOnce.Do(func() {
+		var stdout, stderr bytes.Buffer
+		if err := jirix.NewSeq().Capture(&stdout, &stderr).Last("jiri", "go", "version"); err != nil {
+			return
+		}
+		// The output has the form "go version <version> <os>/<arch>".
+		if fields := strings.Fields(stdout.String()); len(fields) >= 3 {
+			cachedGoVersion = fields[2]
+		}
+	})
+	return cachedGoVersion
+}
+
+// getRunID returns the ID that correlates the test suites produced by
+// the current run.
+func getRunID() string {
+	runIDOnce.Do(func() {
+		if runID = os.Getenv(RunIDEnv); runID != "" {
+			return
+		}
+		if runID = os.Getenv("BUILD_TAG"); runID != "" {
+			return
+		}
+		hostname, _ := os.Hostname()
+		runID = fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+	})
+	return runID
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"v.io/jiri"
+	"v.io/jiri/jiritest"
+)
+
+func TestAddProperties(t *testing.T) {
+	savedHooks := hooks
+	defer func() { hooks = savedHooks }()
+	hooks = []PropertiesHook{
+		func(*jiri.X) []Property {
+			return []Property{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+		},
+	}
+	RegisterPropertiesHook(func(*jiri.X) []Property {
+		return []Property{{Name: "b", Value: "3"}, {Name: "c", Value: "4"}}
+	})
+
+	suites := []TestSuite{
+		TestSuite{Name: "s1"},
+		TestSuite{Name: "s2", Properties: []Property{{Name: "c", Value: "5"}}},
+	}
+	got := addProperties(nil, suites)
+	want := []TestSuite{
+		TestSuite{
+			Name:       "s1",
+			Properties: []Property{{Name: "a", Value: "1"}, {Name: "b", Value: "3"}, {Name: "c", Value: "4"}},
+		},
+		TestSuite{
+			Name:       "s2",
+			Properties: []Property{{Name: "c", Value: "5"}, {Name: "a", Value: "1"}, {Name: "b", Value: "3"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// The given suites must not be modified.
+	if suites[0].Properties != nil || len(suites[1].Properties) != 1 {
+		t.Fatalf("unexpected modification of the input: %v", suites)
+	}
+}
+
+func TestHostProperties(t *testing.T) {
+	savedLabels := os.Getenv("NODE_LABELS")
+	defer os.Setenv("NODE_LABELS", savedLabels)
+	os.Setenv("NODE_LABELS", "linux-amd64 presubmit")
+	jirix, cleanup := jiritest.NewX(t)
+	defer cleanup()
+	values := map[string]string{}
+	for _, p := range hostProperties(jirix) {
+		values[p.Name] = p.Value
+	}
+	for _, name := range []string{"goos", "goarch", "go.version", "devtools.revision", "run.id", "jenkins.labels"} {
+		if _, ok := values[name]; !ok {
+			t.Fatalf("property %q not found in %v", name, values)
+		}
+	}
+	if got, want := values["jenkins.labels"], "linux-amd64 presubmit"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
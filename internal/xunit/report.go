@@ -0,0 +1,205 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+
+	"v.io/jiri"
+)
+
+// CaseID identifies a test case across xUnit reports.
+type CaseID struct {
+	Suite     string
+	Classname string
+	Name      string
+}
+
+func (id CaseID) String() string {
+	if id.Classname == "" {
+		return fmt.Sprintf("%s.%s", id.Suite, id.Name)
+	}
+	return fmt.Sprintf("%s.%s", id.Classname, id.Name)
+}
+
+// caseStatus represents the outcome of a test case.
+type caseStatus int
+
+const (
+	casePassed caseStatus = iota
+	caseFailed
+	caseSkipped
+)
+
+func statusOf(c TestCase) caseStatus {
+	switch {
+	case len(c.Failures) > 0 || len(c.Errors) > 0:
+		return caseFailed
+	case len(c.Skipped) > 0:
+		return caseSkipped
+	default:
+		return casePassed
+	}
+}
+
+// ReadReport reads the xUnit report stored in the given file. Reports
+// whose root element is a single <testsuite> are supported as well.
+func ReadReport(jirix *jiri.X, path string) (*TestSuites, error) {
+	bytes, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var suites TestSuites
+	if err := xml.Unmarshal(bytes, &suites); err != nil {
+		var suite TestSuite
+		if xml.Unmarshal(bytes, &suite) != nil {
+			return nil, fmt.Errorf("Unmarshal(%v) failed: %v", path, err)
+		}
+		suites.Suites = []TestSuite{suite}
+	}
+	return &suites, nil
+}
+
+// MergeReports merges the given xUnit reports into a single report.
+// Test suites with the same name are merged into a single test suite
+// and, if a test case appears in multiple reports, the occurrence from
+// the report that comes last wins. The counters of the merged test
+// suites are recomputed from their test cases. Nested test suites are
+// flattened into the test suites that contain them.
+func MergeReports(reports ...*TestSuites) *TestSuites {
+	names, suites := []string{}, map[string]*TestSuite{}
+	caseIndex := map[CaseID]int{}
+	for _, report := range reports {
+		for _, suite := range report.Suites {
+			merged, ok := suites[suite.Name]
+			if !ok {
+				merged = &TestSuite{Name: suite.Name}
+				suites[suite.Name] = merged
+				names = append(names, suite.Name)
+			}
+			merged.Properties = mergeProperties(merged.Properties, suite.Properties)
+			for _, c := range suite.AllCases() {
+				id := CaseID{Suite: suite.Name, Classname: c.Classname, Name: c.Name}
+				if i, ok := caseIndex[id]; ok {
+					merged.Cases[i] = c
+					continue
+				}
+				caseIndex[id] = len(merged.Cases)
+				merged.Cases = append(merged.Cases, c)
+			}
+		}
+	}
+	result := &TestSuites{}
+	for _, name := range names {
+		suite := suites[name]
+		suite.Tests, suite.Failures, suite.Errors, suite.Skip = len(suite.Cases), 0, 0, 0
+		for _, c := range suite.Cases {
+			// A test case that has both failures and errors is
+			// counted as failed only.
+			if len(c.Failures) > 0 {
+				suite.Failures++
+			} else if len(c.Errors) > 0 {
+				suite.Errors++
+			}
+			if len(c.Skipped) > 0 {
+				suite.Skip++
+			}
+		}
+		result.Suites = append(result.Suites, *suite)
+	}
+	return result
+}
+
+// mergeProperties adds the properties that are not present in dst to
+// it.
+func mergeProperties(dst, src []Property) []Property {
+	existing := map[string]struct{}{}
+	for _, p := range dst {
+		existing[p.Name] = struct{}{}
+	}
+	for _, p := range src {
+		if _, ok := existing[p.Name]; !ok {
+			dst = append(dst, p)
+			existing[p.Name] = struct{}{}
+		}
+	}
+	return dst
+}
+
+// ReportDiff records the differences between two xUnit reports.
+type ReportDiff struct {
+	// NewFailures identifies the test cases that fail in the new
+	// report but did not fail (or did not exist) in the old one.
+	NewFailures []CaseID
+	// NewPasses identifies the test cases that pass in the new report
+	// but did not pass in the old one.
+	NewPasses []CaseID
+	// NewSkips identifies the test cases that are skipped in the new
+	// report but were not skipped in the old one.
+	NewSkips []CaseID
+}
+
+// Empty checks whether the diff contains no differences.
+func (d ReportDiff) Empty() bool {
+	return len(d.NewFailures) == 0 && len(d.NewPasses) == 0 && len(d.NewSkips) == 0
+}
+
+// DiffReports compares the given xUnit reports and returns the test
+// cases whose status changed from the old report to the new one.
+func DiffReports(oldReport, newReport *TestSuites) ReportDiff {
+	oldStatuses := caseStatuses(oldReport)
+	newStatuses := caseStatuses(newReport)
+	var diff ReportDiff
+	for id, newStatus := range newStatuses {
+		oldStatus, existed := oldStatuses[id]
+		if existed && oldStatus == newStatus {
+			continue
+		}
+		switch newStatus {
+		case caseFailed:
+			diff.NewFailures = append(diff.NewFailures, id)
+		case casePassed:
+			if existed {
+				diff.NewPasses = append(diff.NewPasses, id)
+			}
+		case caseSkipped:
+			diff.NewSkips = append(diff.NewSkips, id)
+		}
+	}
+	sortCaseIDs(diff.NewFailures)
+	sortCaseIDs(diff.NewPasses)
+	sortCaseIDs(diff.NewSkips)
+	return diff
+}
+
+func caseStatuses(report *TestSuites) map[CaseID]caseStatus {
+	result := map[CaseID]caseStatus{}
+	for _, suite := range report.Suites {
+		for _, c := range suite.AllCases() {
+			result[CaseID{Suite: suite.Name, Classname: c.Classname, Name: c.Name}] = statusOf(c)
+		}
+	}
+	return result
+}
+
+func sortCaseIDs(ids []CaseID) {
+	sort.Sort(caseIDs(ids))
+}
+
+type caseIDs []CaseID
+
+func (ids caseIDs) Len() int      { return len(ids) }
+func (ids caseIDs) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+func (ids caseIDs) Less(i, j int) bool {
+	if ids[i].Suite != ids[j].Suite {
+		return ids[i].Suite < ids[j].Suite
+	}
+	if ids[i].Classname != ids[j].Classname {
+		return ids[i].Classname < ids[j].Classname
+	}
+	return ids[i].Name < ids[j].Name
+}
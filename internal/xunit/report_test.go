@@ -0,0 +1,119 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	passed  = TestCase{}
+	failed  = TestCase{Failures: []Failure{{Message: "failed"}}}
+	errored = TestCase{Errors: []Error{{Message: "error"}}}
+	skipped = TestCase{Skipped: []string{""}}
+)
+
+func newCase(c TestCase, classname, name string) TestCase {
+	c.Classname, c.Name = classname, name
+	return c
+}
+
+func TestMergeReports(t *testing.T) {
+	r1 := &TestSuites{Suites: []TestSuite{
+		TestSuite{
+			Name:       "s1",
+			Properties: []Property{{Name: "goos", Value: "linux"}},
+			Cases: []TestCase{
+				newCase(passed, "c", "t1"),
+				newCase(failed, "c", "t2"),
+			},
+			Tests:    2,
+			Failures: 1,
+		},
+	}}
+	both := newCase(failed, "d", "t2")
+	both.Errors = []Error{{Message: "error"}}
+	r2 := &TestSuites{Suites: []TestSuite{
+		TestSuite{
+			Name:       "s1",
+			Properties: []Property{{Name: "goos", Value: "darwin"}, {Name: "goarch", Value: "amd64"}},
+			Cases: []TestCase{
+				newCase(passed, "c", "t2"),
+				newCase(skipped, "c", "t3"),
+			},
+		},
+		TestSuite{
+			Name:  "s2",
+			Cases: []TestCase{newCase(errored, "d", "t1"), both},
+		},
+	}}
+	got := MergeReports(r1, r2)
+	want := &TestSuites{Suites: []TestSuite{
+		TestSuite{
+			Name:       "s1",
+			Properties: []Property{{Name: "goos", Value: "linux"}, {Name: "goarch", Value: "amd64"}},
+			Cases: []TestCase{
+				newCase(passed, "c", "t1"),
+				newCase(passed, "c", "t2"),
+				newCase(skipped, "c", "t3"),
+			},
+			Tests: 3,
+			Skip:  1,
+		},
+		TestSuite{
+			Name:     "s2",
+			Cases:    []TestCase{newCase(errored, "d", "t1"), both},
+			Tests:    2,
+			Errors:   1,
+			Failures: 1,
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	oldReport := &TestSuites{Suites: []TestSuite{
+		TestSuite{
+			Name: "s",
+			Cases: []TestCase{
+				newCase(passed, "c", "stillPassing"),
+				newCase(passed, "c", "nowFailing"),
+				newCase(failed, "c", "nowPassing"),
+				newCase(failed, "c", "stillFailing"),
+				newCase(passed, "c", "nowSkipped"),
+				newCase(passed, "c", "removed"),
+			},
+		},
+	}}
+	newReport := &TestSuites{Suites: []TestSuite{
+		TestSuite{
+			Name: "s",
+			Cases: []TestCase{
+				newCase(passed, "c", "stillPassing"),
+				newCase(errored, "c", "nowFailing"),
+				newCase(passed, "c", "nowPassing"),
+				newCase(failed, "c", "stillFailing"),
+				newCase(skipped, "c", "nowSkipped"),
+				newCase(failed, "c", "added"),
+				newCase(passed, "c", "addedPassing"),
+			},
+		},
+	}}
+	got := DiffReports(oldReport, newReport)
+	want := ReportDiff{
+		NewFailures: []CaseID{{"s", "c", "added"}, {"s", "c", "nowFailing"}},
+		NewPasses:   []CaseID{{"s", "c", "nowPassing"}},
+		NewSkips:    []CaseID{{"s", "c", "nowSkipped"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if DiffReports(oldReport, oldReport).Empty() != true {
+		t.Fatalf("diff of a report with itself is not empty")
+	}
+}
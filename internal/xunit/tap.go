@@ -0,0 +1,99 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tapPlanRE   = regexp.MustCompile(`^1\.\.(\d+)`)
+	tapResultRE = regexp.MustCompile(`^(not )?ok\b\s*(\d+)?\s*(?:-\s*)?([^#]*?)\s*(?:#\s*(\S+)\s*(.*))?$`)
+)
+
+// TestSuiteFromTAP converts the given output in the Test Anything
+// Protocol (TAP) format to a test suite with the given name. Test
+// points marked with a SKIP directive are reported as skipped and
+// failed test points marked with a TODO directive are reported as
+// passed. The diagnostic lines that follow a failed test point are
+// reported as the data of its failure. Missing test points and bail
+// outs are reported as failures of additional test cases.
+func TestSuiteFromTAP(name string, output io.Reader) (*TestSuite, error) {
+	s := &TestSuite{Name: name}
+	planned, ran := -1, 0
+	var failure *Failure
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if failure != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(trimmed, "#")) {
+			failure.Data += strings.TrimPrefix(trimmed, "# ") + "\n"
+			continue
+		}
+		failure = nil
+		if matches := tapPlanRE.FindStringSubmatch(trimmed); matches != nil {
+			planned, _ = strconv.Atoi(matches[1])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Bail out!") {
+			reason := strings.TrimSpace(strings.TrimPrefix(trimmed, "Bail out!"))
+			addCase(s, TestCase{Classname: name, Name: "Bail out", Failures: []Failure{{Message: reason}}})
+			continue
+		}
+		matches := tapResultRE.FindStringSubmatch(trimmed)
+		if matches == nil {
+			continue
+		}
+		ran++
+		number := ran
+		if matches[2] != "" {
+			number, _ = strconv.Atoi(matches[2])
+		}
+		c := TestCase{Classname: name, Name: matches[3], Time: "0.00"}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("test %d", number)
+		}
+		directive, reason := strings.ToUpper(matches[4]), matches[5]
+		switch {
+		case strings.HasPrefix(directive, "SKIP"):
+			c.Skipped = []string{reason}
+		case matches[1] != "" && !strings.HasPrefix(directive, "TODO"):
+			c.Failures = []Failure{{Message: "test failed"}}
+		}
+		addCase(s, c)
+		if len(c.Failures) > 0 {
+			failure = &s.Cases[len(s.Cases)-1].Failures[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Scan() failed: %v", err)
+	}
+	if planned > ran {
+		addCase(s, TestCase{
+			Classname: name,
+			Name:      "Plan",
+			Failures:  []Failure{{Message: fmt.Sprintf("planned %d tests, ran %d", planned, ran)}},
+		})
+	}
+	return s, nil
+}
+
+// addCase adds the given test case to the given suite and updates
+// the counters of the suite.
+func addCase(s *TestSuite, c TestCase) {
+	s.Cases = append(s.Cases, c)
+	s.Tests++
+	if len(c.Failures) > 0 {
+		s.Failures++
+	}
+	if len(c.Skipped) > 0 {
+		s.Skip++
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTestSuiteFromTAP(t *testing.T) {
+	output := `TAP version 13
+1..6
+ok 1 - parses the input
+not ok 2 - formats the output
+  ---
+  expected: 1
+  actual: 2
+  ...
+# a diagnostic that is not part of a failure
+ok 3 # SKIP not supported on this platform
+not ok 4 - handles unicode # TODO not implemented yet
+ok
+`
+	got, err := TestSuiteFromTAP("projects/foo", strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("TestSuiteFromTAP() failed: %v", err)
+	}
+	want := &TestSuite{
+		Name: "projects/foo",
+		Cases: []TestCase{
+			{Classname: "projects/foo", Name: "parses the input", Time: "0.00"},
+			{
+				Classname: "projects/foo",
+				Name:      "formats the output",
+				Time:      "0.00",
+				Failures: []Failure{{
+					Message: "test failed",
+					Data:    "---\nexpected: 1\nactual: 2\n...\na diagnostic that is not part of a failure\n",
+				}},
+			},
+			{Classname: "projects/foo", Name: "test 3", Time: "0.00", Skipped: []string{"not supported on this platform"}},
+			{Classname: "projects/foo", Name: "handles unicode", Time: "0.00"},
+			{Classname: "projects/foo", Name: "test 5", Time: "0.00"},
+			{
+				Classname: "projects/foo",
+				Name:      "Plan",
+				Failures:  []Failure{{Message: "planned 6 tests, ran 5"}},
+			},
+		},
+		Tests:    6,
+		Failures: 2,
+		Skip:     1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	// Check that bail outs are reported as failures.
+	got, err = TestSuiteFromTAP("projects/foo", strings.NewReader("1..2\nok 1\nBail out! database unavailable\n"))
+	if err != nil {
+		t.Fatalf("TestSuiteFromTAP() failed: %v", err)
+	}
+	if got, want := got.Failures, 2; got != want {
+		t.Fatalf("got %d failures, want %d", got, want)
+	}
+	if got, want := got.Cases[1].Failures[0].Message, "database unavailable"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
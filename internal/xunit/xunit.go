@@ -14,9 +14,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/jiri/runutil"
 	"v.io/x/devtools/tooldata"
 )
 
@@ -26,12 +30,76 @@ type TestSuites struct {
 }
 
 type TestSuite struct {
-	Name     string     `xml:"name,attr"`
-	Cases    []TestCase `xml:"testcase"`
-	Errors   int        `xml:"errors,attr"`
-	Failures int        `xml:"failures,attr"`
-	Skip     int        `xml:"skip,attr"`
-	Tests    int        `xml:"tests,attr"`
+	Name       string     `xml:"name,attr"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Cases      []TestCase `xml:"testcase"`
+	// Suites holds nested test suites, whose cases are included in the
+	// counters of the enclosing suite.
+	Suites   []TestSuite `xml:"testsuite,omitempty"`
+	Errors   int         `xml:"errors,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skip     int         `xml:"skip,attr"`
+	Tests    int         `xml:"tests,attr"`
+}
+
+// AllCases returns the test cases of the suite, including the test cases
+// of its nested suites.
+func (s TestSuite) AllCases() []TestCase {
+	cases := append([]TestCase{}, s.Cases...)
+	for _, nested := range s.Suites {
+		cases = append(cases, nested.AllCases()...)
+	}
+	return cases
+}
+
+// GroupSubtests returns a copy of the given suite in which the test
+// cases of subtests and sub-benchmarks, whose names have the form
+// "<parent>/<name>", are moved to a nested suite named after the parent
+// test, so that they are grouped together in the Jenkins UI. The test
+// cases keep their full names so that they can be identified across
+// reports, and the counters of the suite are not changed.
+func GroupSubtests(suite TestSuite) TestSuite {
+	parents, nested := []string{}, map[string]*TestSuite{}
+	cases := []TestCase{}
+	for _, c := range suite.Cases {
+		i := strings.Index(c.Name, "/")
+		if i == -1 {
+			cases = append(cases, c)
+			continue
+		}
+		parent := c.Name[:i]
+		s, ok := nested[parent]
+		if !ok {
+			s = &TestSuite{Name: parent}
+			nested[parent] = s
+			parents = append(parents, parent)
+		}
+		s.Cases = append(s.Cases, c)
+		s.Tests++
+		if len(c.Failures) > 0 {
+			s.Failures++
+		}
+		if len(c.Errors) > 0 {
+			s.Errors++
+		}
+		if len(c.Skipped) > 0 {
+			s.Skip++
+		}
+	}
+	if len(parents) == 0 {
+		return suite
+	}
+	suite.Cases = cases
+	suite.Suites = append([]TestSuite{}, suite.Suites...)
+	for _, parent := range parents {
+		suite.Suites = append(suite.Suites, *nested[parent])
+	}
+	return suite
+}
+
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
 }
 
 type TestCase struct {
@@ -41,6 +109,7 @@ type TestCase struct {
 	Failures  []Failure `xml:"failure"`
 	Time      string    `xml:"time,attr"`
 	Skipped   []string  `xml:"skipped"`
+	SystemOut string    `xml:"system-out,omitempty"`
 }
 
 type Error struct {
@@ -50,22 +119,138 @@ type Error struct {
 
 type Failure struct {
 	Message string `xml:"message,attr"`
-	Data    string `xml:",chardata"`
+	// Type classifies the failure, e.g. as a failure caused by
+	// exceeding a resource limit rather than by the test itself.
+	Type string `xml:"type,attr,omitempty"`
+	Data string `xml:",chardata"`
+}
+
+// ResourceLimitFailure is the type of failures caused by exceeding a
+// resource limit.
+const ResourceLimitFailure = "ResourceLimitExceeded"
+
+// ReportHook is invoked with the test suites of each xUnit report
+// created via CreateReport, for example to export the test results.
+type ReportHook func(jirix *jiri.X, testName string, suites []TestSuite) error
+
+var (
+	reportHooksMu sync.Mutex
+	reportHooks   = []ReportHook{}
+)
+
+// RegisterReportHook registers the given hook.
+func RegisterReportHook(hook ReportHook) {
+	reportHooksMu.Lock()
+	defer reportHooksMu.Unlock()
+	reportHooks = append(reportHooks, hook)
 }
 
 // CreateReport generates an xUnit report using the given test suites.
-func CreateReport(jirix *jiri.X, testName string, suites []TestSuite) error {
-	result := TestSuites{Suites: suites}
-	bytes, err := xml.MarshalIndent(result, "", "  ")
+// The properties returned by the registered property hooks are added to
+// each test suite of the report, which is then passed to the registered
+// report hooks. Failures of the report hooks are reported, but do not
+// cause CreateReport to fail.
+//
+// The report is locked while it is written, so that it does not
+// interleave with the updates of AppendReport.
+func CreateReport(jirix *jiri.X, testName string, suites []TestSuite) (e error) {
+	path := ReportPath(testName)
+	unlock, err := lockReport(jirix, path)
 	if err != nil {
-		return fmt.Errorf("MarshalIndent(%v) failed: %v", result, err)
+		return err
 	}
-	if err := jirix.NewSeq().WriteFile(ReportPath(testName), bytes, os.FileMode(0644)).Done(); err != nil {
-		return fmt.Errorf("WriteFile(%v) failed: %v", ReportPath(testName), err)
+	defer collect.Error(unlock, &e)
+	result := TestSuites{Suites: addProperties(jirix, suites)}
+	if err := writeReport(jirix, path, &result); err != nil {
+		return err
 	}
+	runReportHooks(jirix, testName, result.Suites)
 	return nil
 }
 
+// AppendReport is like CreateReport, but merges the given test suites
+// into the existing xUnit report of the test, if any, instead of
+// overwriting it. The report is merged as described by MergeReports,
+// and is locked while it is updated, so that concurrent invocations of
+// the test on the same host do not lose each other's results. Only the
+// given test suites, with the properties added, are passed to the
+// registered report hooks.
+func AppendReport(jirix *jiri.X, testName string, suites []TestSuite) (e error) {
+	path := ReportPath(testName)
+	unlock, err := lockReport(jirix, path)
+	if err != nil {
+		return err
+	}
+	defer collect.Error(unlock, &e)
+	added := addProperties(jirix, suites)
+	result := &TestSuites{Suites: added}
+	if _, err := jirix.NewSeq().Stat(path); err == nil {
+		existing, err := ReadReport(jirix, path)
+		if err != nil {
+			return err
+		}
+		result = MergeReports(existing, result)
+	} else if !runutil.IsNotExist(err) {
+		return err
+	}
+	if err := writeReport(jirix, path, result); err != nil {
+		return err
+	}
+	runReportHooks(jirix, testName, added)
+	return nil
+}
+
+// lockReport acquires an exclusive lock on the xUnit report stored at
+// the given path, and returns a function that releases it. The lock is
+// held on a separate lock file, which outlives the report updates.
+func lockReport(jirix *jiri.X, path string) (func() error, error) {
+	lockPath := path + ".lock"
+	s := jirix.NewSeq()
+	if err := s.MkdirAll(filepath.Dir(lockPath), os.FileMode(0755)).Done(); err != nil {
+		return nil, err
+	}
+	file, err := s.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, os.FileMode(0644))
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Flock(%v) failed: %v", lockPath, err)
+	}
+	return func() error {
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN); err != nil {
+			file.Close()
+			return fmt.Errorf("Flock(%v) failed: %v", lockPath, err)
+		}
+		return file.Close()
+	}, nil
+}
+
+// writeReport writes the given xUnit report to the given path.
+func writeReport(jirix *jiri.X, path string, report *TestSuites) error {
+	bytes, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", report, err)
+	}
+	if err := jirix.NewSeq().WriteFile(path, bytes, os.FileMode(0644)).Done(); err != nil {
+		return fmt.Errorf("WriteFile(%v) failed: %v", path, err)
+	}
+	return nil
+}
+
+// runReportHooks passes the given test suites of the given test to the
+// registered report hooks, reporting their failures.
+func runReportHooks(jirix *jiri.X, testName string, suites []TestSuite) {
+	reportHooksMu.Lock()
+	curHooks := append([]ReportHook{}, reportHooks...)
+	reportHooksMu.Unlock()
+	for _, hook := range curHooks {
+		if err := hook(jirix, testName, suites); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
+	}
+}
+
 // CreateTestSuiteWithFailure encodes the given information as a test
 // suite with a single failure.
 func CreateTestSuiteWithFailure(pkgName, testName, failureMessage, failureOutput string, duration time.Duration) *TestSuite {
@@ -95,12 +280,38 @@ func CreateFailureReport(jirix *jiri.X, testName, pkgName, testCaseName, failure
 	return nil
 }
 
+// reportSuffix is the suffix added to the names of the xUnit files.
+var reportSuffix string
+
+// SetReportSuffix sets the suffix that is added to the names of the
+// xUnit files of this process, so that the reports created by multiple
+// invocations of the same test on the same host do not overwrite each
+// other. No suffix is added if it is empty, which is the default.
+func SetReportSuffix(suffix string) {
+	reportSuffix = suffix
+}
+
+// ReportSuffix returns the suffix set by SetReportSuffix.
+func ReportSuffix() string {
+	return reportSuffix
+}
+
+// UniqueReportSuffix returns a report suffix that identifies the current
+// invocation.
+func UniqueReportSuffix() string {
+	return fmt.Sprintf("%d_%d", time.Now().Unix(), os.Getpid())
+}
+
 // ReportPath returns the path to the xUnit file.
 //
 // TODO(jsimsa): Once all Jenkins shell test scripts are ported to Go,
 // change the filename to xunit_report_<testName>.xml.
 func ReportPath(testName string) string {
-	workspace, fileName := os.Getenv("WORKSPACE"), fmt.Sprintf("tests_%s.xml", strings.Replace(testName, "-", "_", -1))
+	name := strings.Replace(testName, "-", "_", -1)
+	if reportSuffix != "" {
+		name += "_" + reportSuffix
+	}
+	workspace, fileName := os.Getenv("WORKSPACE"), fmt.Sprintf("tests_%s.xml", name)
 	if workspace == "" {
 		return filepath.Join(os.Getenv("HOME"), "tmp", testName, fileName)
 	} else {
@@ -108,24 +319,53 @@ func ReportPath(testName string) string {
 	}
 }
 
+// AttachmentsDir returns the path to the directory that holds the files
+// attached to the xUnit report of the given test.
+func AttachmentsDir(testName string) string {
+	return filepath.Join(filepath.Dir(ReportPath(testName)), fmt.Sprintf("attachments_%s", strings.Replace(testName, "-", "_", -1)))
+}
+
+// Attach attaches the files identified by the given paths to the given
+// test case. The files are referenced from the standard output of the
+// test case in the format recognized by the Jenkins JUnit Attachments
+// plugin, so they must outlive the test run.
+func Attach(c *TestCase, paths ...string) {
+	for _, path := range paths {
+		c.SystemOut += fmt.Sprintf("[[ATTACHMENT|%s]]\n", path)
+	}
+}
+
 // TestSuitesFromGoTestOutput reads data from the given input, assuming
 // it contains test results generated by "go test -v", and returns it
-// as an in-memory data structure.
+// as an in-memory data structure. The reasons of skipped tests and the
+// results of subtests, which go2xunit drops, are added to the suites.
 func TestSuitesFromGoTestOutput(jirix *jiri.X, testOutput io.Reader) ([]*TestSuite, error) {
-	bin, err := tooldata.ThirdPartyBinPath(jirix, "go2xunit")
+	suites, output, err := testSuitesFromGo2xunit(jirix, testOutput)
 	if err != nil {
 		return nil, err
 	}
-	var out bytes.Buffer
-	if err := jirix.NewSeq().Read(testOutput).Capture(&out, nil).Last(bin); err != nil {
-		return nil, err
+	addGoTestDetails(suites, output)
+	return suites, nil
+}
+
+// testSuitesFromGo2xunit converts the given output of "go test -v"
+// using go2xunit, and returns the resulting test suites along with the
+// output.
+func testSuitesFromGo2xunit(jirix *jiri.X, testOutput io.Reader) ([]*TestSuite, string, error) {
+	bin, err := tooldata.ThirdPartyBinPath(jirix, "go2xunit")
+	if err != nil {
+		return nil, "", err
+	}
+	var in, out bytes.Buffer
+	if err := jirix.NewSeq().Read(io.TeeReader(testOutput, &in)).Capture(&out, nil).Last(bin); err != nil {
+		return nil, "", err
 	}
 	var suite TestSuite
 	if err := xml.Unmarshal(out.Bytes(), &suite); err != nil {
-		return nil, fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
+		return nil, "", fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
 	}
 	if suite.Tests > 0 {
-		return []*TestSuite{&suite}, nil
+		return []*TestSuite{&suite}, in.String(), nil
 	}
 	// go2xunit has most likely output multiple testsuites i.e.
 	// <testsuites>
@@ -139,15 +379,15 @@ func TestSuitesFromGoTestOutput(jirix *jiri.X, testOutput io.Reader) ([]*TestSui
 	var suites TestSuites
 	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
 		if !strings.Contains(err.Error(), "expected element type <testsuites> but have <testsuite>") {
-			return nil, fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
+			return nil, "", fmt.Errorf("Unmarshal() failed: %v\n%v", err, out.String())
 		}
 	}
 	if len(suites.Suites) == 0 {
-		return []*TestSuite{&suite}, nil
+		return []*TestSuite{&suite}, in.String(), nil
 	}
 	rc := make([]*TestSuite, len(suites.Suites), len(suites.Suites))
 	for i, _ := range suites.Suites {
 		rc[i] = &suites.Suites[i]
 	}
-	return rc, nil
+	return rc, in.String(), nil
 }
@@ -0,0 +1,169 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xunit
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"v.io/jiri"
+	"v.io/jiri/jiritest"
+)
+
+func TestGroupSubtests(t *testing.T) {
+	suite := TestSuite{
+		Name: "v.io/x/foo",
+		Cases: []TestCase{
+			newCase(failed, "v.io/x/foo", "TestA"),
+			newCase(passed, "v.io/x/foo", "TestA/small"),
+			newCase(failed, "v.io/x/foo", "TestA/large"),
+			newCase(passed, "v.io/x/foo", "TestB"),
+			newCase(skipped, "v.io/x/foo", "BenchmarkC/size=10"),
+		},
+		Tests:    5,
+		Failures: 2,
+		Skip:     1,
+	}
+	got := GroupSubtests(suite)
+	want := TestSuite{
+		Name: "v.io/x/foo",
+		Cases: []TestCase{
+			newCase(failed, "v.io/x/foo", "TestA"),
+			newCase(passed, "v.io/x/foo", "TestB"),
+		},
+		Suites: []TestSuite{
+			TestSuite{
+				Name: "TestA",
+				Cases: []TestCase{
+					newCase(passed, "v.io/x/foo", "TestA/small"),
+					newCase(failed, "v.io/x/foo", "TestA/large"),
+				},
+				Tests:    2,
+				Failures: 1,
+			},
+			TestSuite{
+				Name:  "BenchmarkC",
+				Cases: []TestCase{newCase(skipped, "v.io/x/foo", "BenchmarkC/size=10")},
+				Tests: 1,
+				Skip:  1,
+			},
+		},
+		Tests:    5,
+		Failures: 2,
+		Skip:     1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	if got, want := len(got.AllCases()), len(suite.Cases); got != want {
+		t.Fatalf("got %v cases, want %v", got, want)
+	}
+	// The given suite must not be modified.
+	if len(suite.Cases) != 5 || suite.Suites != nil {
+		t.Fatalf("unexpected modification of the input: %v", suite)
+	}
+
+	// The nested suites survive a round trip through XML.
+	bytes, err := xml.Marshal(TestSuites{Suites: []TestSuite{got}})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	if !strings.Contains(string(bytes), `<testsuite name="TestA"`) {
+		t.Fatalf("nested suite not found in %s", bytes)
+	}
+	var report TestSuites
+	if err := xml.Unmarshal(bytes, &report); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if got, want := len(report.Suites[0].AllCases()), len(suite.Cases); got != want {
+		t.Fatalf("got %v cases, want %v", got, want)
+	}
+
+	// Suites without subtests are left alone.
+	flat := TestSuite{Name: "v.io/x/bar", Cases: []TestCase{newCase(passed, "v.io/x/bar", "TestD")}, Tests: 1}
+	if got := GroupSubtests(flat); !reflect.DeepEqual(got, flat) {
+		t.Fatalf("got %#v, want %#v", got, flat)
+	}
+}
+
+func TestAppendReport(t *testing.T) {
+	jirix, cleanup := jiritest.NewX(t)
+	defer cleanup()
+	workspaceDir, err := ioutil.TempDir("", "xunit-test")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(workspaceDir)
+	defer os.Setenv("WORKSPACE", os.Getenv("WORKSPACE"))
+	if err := os.Setenv("WORKSPACE", workspaceDir); err != nil {
+		t.Fatalf("Setenv() failed: %v", err)
+	}
+	defer SetReportSuffix("")
+	SetReportSuffix("1")
+	if got, want := ReportPath("vanadium-go-test"), filepath.Join(workspaceDir, "tests_vanadium_go_test_1.xml"); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// The report hooks get the appended suites with their properties.
+	var hookSuites []TestSuite
+	savedHooks := reportHooks
+	defer func() { reportHooks = savedHooks }()
+	RegisterReportHook(func(_ *jiri.X, _ string, suites []TestSuite) error {
+		hookSuites = suites
+		return nil
+	})
+
+	suite := func(name string, cases ...TestCase) TestSuite {
+		return TestSuite{Name: name, Cases: cases, Tests: len(cases)}
+	}
+	if err := AppendReport(jirix, "vanadium-go-test", []TestSuite{
+		suite("v.io/x/foo", newCase(passed, "v.io/x/foo", "TestA"), newCase(failed, "v.io/x/foo", "TestB")),
+	}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := AppendReport(jirix, "vanadium-go-test", []TestSuite{
+		suite("v.io/x/foo", newCase(passed, "v.io/x/foo", "TestB")),
+		suite("v.io/x/bar", newCase(skipped, "v.io/x/bar", "TestC")),
+	}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(hookSuites), 2; got != want {
+		t.Fatalf("got %d suites passed to the hook, want %d", got, want)
+	}
+	for _, suite := range hookSuites {
+		if len(suite.Properties) == 0 {
+			t.Errorf("suite %v passed to the hook without properties", suite.Name)
+		}
+	}
+	report, err := ReadReport(jirix, ReportPath("vanadium-go-test"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The host properties are tested separately.
+	for i := range report.Suites {
+		report.Suites[i].Properties = nil
+	}
+	want := []TestSuite{
+		{
+			Name:  "v.io/x/foo",
+			Cases: []TestCase{newCase(passed, "v.io/x/foo", "TestA"), newCase(passed, "v.io/x/foo", "TestB")},
+			Tests: 2,
+		},
+		{
+			Name:  "v.io/x/bar",
+			Cases: []TestCase{newCase(skipped, "v.io/x/bar", "TestC")},
+			Tests: 1,
+			Skip:  1,
+		},
+	}
+	if !reflect.DeepEqual(report.Suites, want) {
+		t.Fatalf("got %#v, want %#v", report.Suites, want)
+	}
+}
@@ -67,6 +67,9 @@ The global flags are:
    ldflags.  Note that if your go command line specifies -ldflags explicitly, it
    will override both the automatically generated ldflags as well as the
    extra-ldflags.
+ -force-vdl=false
+   Regenerate the VDL files even if the VDL generation cache indicates that they
+   are up-to-date.
  -image=
    Name of the docker image to use. If empty, the tool will automatically select
    an image based on the environment variables, possibly edited by the profile
@@ -69,6 +69,7 @@ such as invoking the VDL compiler on packages to generate up-to-date .go files.
 var (
 	imageFlag    string
 	extraLDFlags string
+	forceVDLFlag bool
 	readerFlags  profilescmdline.ReaderFlagValues
 )
 
@@ -79,6 +80,7 @@ func init() {
 	profilescmdline.RegisterReaderFlags(&cmd.Flags, &readerFlags, "v23:base", jiri.ProfilesDBDir)
 	flag.StringVar(&imageFlag, "image", "", "Name of the docker image to use. If empty, the tool will automatically select an image based on the environment variables, possibly edited by the profile")
 	flag.StringVar(&extraLDFlags, "extra-ldflags", "", golib.ExtraLDFlagsFlagDescription)
+	flag.BoolVar(&forceVDLFlag, "force-vdl", false, golib.ForceVDLFlagDescription)
 }
 
 func runGo(jirix *jiri.X, args []string) error {
@@ -129,7 +131,7 @@ func runGo(jirix *jiri.X, args []string) error {
 	if readerFlags.Target.OS() == "fnl" {
 		installSuffix = "musl"
 	}
-	if args, err = golib.PrepareGo(jirix, envMap, args, extraLDFlags, installSuffix); err != nil {
+	if args, err = golib.PrepareGo(jirix, envMap, args, extraLDFlags, installSuffix, golib.ForceVDLOpt(forceVDLFlag)); err != nil {
 		return err
 	}
 	if len(args) == 1 && args[0] == "env" {
@@ -0,0 +1,135 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/buildinfo"
+	"v.io/x/lib/metadata"
+)
+
+// runBuildInfo implements "jiri go buildinfo <binary>", which prints the
+// build metadata of the given binary.
+func runBuildInfo(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("buildinfo expects exactly one binary, got %d", len(args))
+	}
+	// The metadata is read from the binary file rather than by running
+	// the binary, so that binaries for other platforms can be inspected.
+	data, err := jirix.NewSeq().ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	md, err := metadataFromBinary(data)
+	if err != nil {
+		return fmt.Errorf("%v: %v", args[0], err)
+	}
+	info, err := buildinfo.FromMetaData(md)
+	if err != nil {
+		return err
+	}
+	return printBuildInfo(jirix.Stdout(), info)
+}
+
+// gzipBase64Prefix is the base64 encoding of the gzip magic number and
+// compression method, which starts the metadata embedded by the linker.
+var gzipBase64Prefix = []byte("H4sI")
+
+// metadataFromBinary returns the build metadata embedded in the given
+// binary. The linker embeds the metadata as the gzipped, base64-encoded
+// XML string of metadata.LDFlag, which is found by its gzip header.
+func metadataFromBinary(data []byte) (*metadata.T, error) {
+	for i := 0; i < len(data); {
+		j := bytes.Index(data[i:], gzipBase64Prefix)
+		if j < 0 {
+			break
+		}
+		start, end := i+j, i+j
+		for end < len(data) && isBase64Char(data[end]) {
+			end++
+		}
+		for end < len(data) && data[end] == '=' {
+			end++
+		}
+		for _, enc := range []*base64.Encoding{base64.URLEncoding, base64.StdEncoding} {
+			if md, err := decodeMetadata(enc, data[start:end]); err == nil {
+				return md, nil
+			}
+		}
+		i = start + 1
+	}
+	return nil, fmt.Errorf("no build metadata found")
+}
+
+// isBase64Char returns whether the given byte belongs to the standard or
+// the URL base64 alphabet.
+func isBase64Char(c byte) bool {
+	switch {
+	case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		return true
+	}
+	return c == '+' || c == '/' || c == '-' || c == '_'
+}
+
+// decodeMetadata decodes the metadata from the given gzipped,
+// base64-encoded XML string. The string may be followed by other data,
+// which is ignored.
+func decodeMetadata(enc *base64.Encoding, data []byte) (*metadata.T, error) {
+	r, err := gzip.NewReader(base64.NewDecoder(enc, bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	r.Multistream(false)
+	xml, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.FromXML(xml)
+}
+
+// printBuildInfo prints the given build metadata in a human-readable
+// format.
+func printBuildInfo(w io.Writer, info buildinfo.T) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Time:\t%v\n", info.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(tw, "User:\t%v\n", info.User)
+	fmt.Fprintf(tw, "Platform:\t%v\n", info.Platform)
+	fmt.Fprintf(tw, "Pristine:\t%v\n", info.Pristine)
+	if info.Manifest.SnapshotPath != "" {
+		fmt.Fprintf(tw, "Snapshot:\t%v\n", info.Manifest.SnapshotPath)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(info.Projects) == 0 {
+		return nil
+	}
+	names := []string{}
+	for name := range info.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Projects:\n")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		rev := info.Projects[name]
+		dirty := ""
+		if rev.Dirty {
+			dirty = "\t(dirty)"
+		}
+		fmt.Fprintf(tw, "  %v\t%v%v\n", name, rev.Revision, dirty)
+	}
+	return tw.Flush()
+}
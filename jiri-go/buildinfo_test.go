@@ -0,0 +1,75 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"v.io/x/devtools/internal/buildinfo"
+	"v.io/x/lib/metadata"
+)
+
+func TestPrintBuildInfo(t *testing.T) {
+	info := buildinfo.T{
+		Platform: "amd64unknown-linux-unknown",
+		Projects: map[string]buildinfo.ProjectRevision{
+			"release.go.x.ref": {Revision: "a8c1f20"},
+			"release.go.v23":   {Revision: "4f5e7a1", Dirty: true},
+		},
+		Time: time.Date(2016, time.May, 3, 3, 15, 0, 0, time.UTC),
+		User: "Vanadium Vamoose",
+	}
+	var out bytes.Buffer
+	if err := printBuildInfo(&out, info); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := `Time:      2016-05-03T03:15:00Z
+User:      Vanadium Vamoose
+Platform:  amd64unknown-linux-unknown
+Pristine:  false
+Projects:
+  release.go.v23    4f5e7a1  (dirty)
+  release.go.x.ref  a8c1f20
+`
+	if got := out.String(); got != want {
+		t.Errorf("want\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestMetadataFromBinary(t *testing.T) {
+	info := buildinfo.T{
+		Platform: "amd64unknown-linux-unknown",
+		Projects: map[string]buildinfo.ProjectRevision{
+			"release.go.v23": {Revision: "4f5e7a1", Dirty: true},
+		},
+		Time: time.Date(2016, time.May, 3, 3, 15, 0, 0, time.UTC),
+		User: "Vanadium Vamoose",
+	}
+	md, err := info.ToMetaData()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The embedded string is surrounded by other data, which may start
+	// with base64 characters too.
+	value := strings.SplitN(metadata.LDFlag(md), "=", 2)[1]
+	data := []byte("\x7fELF H4sIjunk\x00" + value + "abcdef0123\x00more")
+	if md, err = metadataFromBinary(data); err != nil {
+		t.Fatalf("metadataFromBinary failed: %v", err)
+	}
+	got, err := buildinfo.FromMetaData(md)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, info) {
+		t.Errorf("got %#v, want %#v", got, info)
+	}
+	if _, err := metadataFromBinary([]byte("\x7fELF H4sIjunk")); err == nil {
+		t.Errorf("metadataFromBinary did not fail for a binary without metadata")
+	}
+}
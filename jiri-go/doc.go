@@ -9,7 +9,41 @@
 Wrapper around the 'go' tool that can be used for compilation of vanadium Go
 sources. It takes care of vanadium-specific setup, such as setting up the Go
 specific environment variables or making sure that VDL generated files are
-regenerated before compilation.
+regenerated before compilation. VDL generation is skipped if neither the vdl
+tool nor the VDL files changed since the last generation; use the -force-vdl
+flag to regenerate the files regardless. If the vdl tool cannot be found in
+PATH, VDL generation is skipped with a warning, so that plain Go code can be
+built without the full profile setup; use the -require-vdl flag to fail instead.
+
+The build tags that the requested profiles record for the target in the
+GO_BUILD_TAGS variable of the profiles database are automatically added to
+build, install, run and test commands; use the -no-auto-tags flag to disable
+this.
+
+The binaries built by 'jiri go build' and 'jiri go install' embed metadata about
+the build, including the revisions of the projects used. 'jiri go buildinfo
+<binary>' prints the metadata of the given binary, which is read from the binary
+file without running it, so that binaries built for other platforms can be
+inspected too.
+
+The -platforms flag runs the go tool for several platforms in parallel, using
+the environment that the profiles provide for each platform. The go tool is run
+in a per-platform <platforms-dir>/<os>-<arch> directory, so that 'go build'
+leaves the binaries of each platform in their own directory; 'go install' places
+cross-compiled binaries in per-platform directories of the Go workspace. The
+output file of a relative -o flag is placed in the directory of each platform as
+well, whereas an absolute one is rejected for several platforms.
+
+The -bin-dir flag places the binaries built by 'jiri go build' and 'jiri go
+install' in the given directory, such as the one identified by V23_BIN_DIR.
+Binaries built with the -race, -msan or -tags flags are placed in a subdirectory
+named after the build variant, e.g. <bin-dir>/race or <bin-dir>/tags-foo, so
+that they do not overwrite the regular binaries. The build tags include those
+added automatically for the requested profiles.
+
+The -test-json-metadata flag adds a "Vanadium" field, which holds the profiles
+and target used, to each of the JSON events emitted by 'go test -json', so that
+tools consuming these events can tell runs for different configurations apart.
 
 Usage:
    jiri go [flags] <arg ...>
@@ -35,18 +69,43 @@ The jiri go flags are:
    Print verbose output.
 
 The global flags are:
+ -bin-dir=
+   directory in which 'go build' and 'go install' place binaries, in a
+   subdirectory named after the build variant for builds with -race, -msan or
+   -tags
  -extra-ldflags=
    This tool sets some ldflags automatically, e.g. to set binary metadata.  The
    extra-ldflags are appended to the end of those automatically generated
    ldflags.  Note that if your go command line specifies -ldflags explicitly, it
    will override both the automatically generated ldflags as well as the
    extra-ldflags.
+ -fast=false
+   skip the report of outdated branches and the VDL generation; can also be
+   enabled by setting the JIRI_GO_FAST environment variable to 1
+ -force-vdl=false
+   Regenerate the VDL files even if the VDL generation cache indicates that they
+   are up-to-date.
  -metadata=<just specify -metadata to activate>
    Displays metadata for the program and exits.
+ -no-auto-tags=false
+   do not add the build tags that the requested profiles record for the target
+   in the profiles database
+ -platforms=
+   comma-separated list of <os>-<arch> platforms, such as
+   linux-amd64,darwin-amd64,linux-arm, to run the go tool for in parallel
+ -platforms-dir=.
+   directory in which the go tool is run for each of the -platforms, in a
+   <os>-<arch> subdirectory
  -print-run-env=false
    print detailed info on environment variables and the command line used
+ -require-vdl=false
+   Fail instead of skipping the VDL generation with a warning if the vdl tool
+   cannot be found in PATH. Can also be enabled by setting the
+   JIRI_GO_REQUIRE_VDL environment variable to 1.
  -system-go=false
    use the version of go found in $PATH rather than that built by the go profile
+ -test-json-metadata=false
+   add the profiles and target to each event of 'go test -json'
  -time=false
    Dump timing information to stderr before exiting the program.
 */
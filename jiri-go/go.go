@@ -8,12 +8,18 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"v.io/jiri"
+	"v.io/jiri/profiles"
 	"v.io/jiri/profiles/profilescmdline"
 	"v.io/jiri/profiles/profilesreader"
 	"v.io/jiri/runutil"
@@ -21,6 +27,7 @@ import (
 	"v.io/x/devtools/internal/golib"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
+	"v.io/x/lib/envvar"
 	"v.io/x/lib/lookpath"
 )
 
@@ -34,23 +41,80 @@ Wrapper around the 'go' tool that can be used for compilation of
 vanadium Go sources. It takes care of vanadium-specific setup, such as
 setting up the Go specific environment variables or making sure that
 VDL generated files are regenerated before compilation.
+VDL generation is skipped if neither the vdl tool nor the VDL files
+changed since the last generation; use the -force-vdl flag to
+regenerate the files regardless. If the vdl tool cannot be found in
+PATH, VDL generation is skipped with a warning, so that plain Go code
+can be built without the full profile setup; use the -require-vdl flag
+to fail instead.
+
+The build tags that the requested profiles record for the target in
+the GO_BUILD_TAGS variable of the profiles database are automatically
+added to build, install, run and test commands; use the -no-auto-tags
+flag to disable this.
+
+The binaries built by 'jiri go build' and 'jiri go install' embed
+metadata about the build, including the revisions of the projects used.
+'jiri go buildinfo <binary>' prints the metadata of the given binary,
+which is read from the binary file without running it, so that binaries
+built for other platforms can be inspected too.
+
+The -platforms flag runs the go tool for several platforms in parallel,
+using the environment that the profiles provide for each platform. The
+go tool is run in a per-platform <platforms-dir>/<os>-<arch> directory,
+so that 'go build' leaves the binaries of each platform in their own
+directory; 'go install' places cross-compiled binaries in per-platform
+directories of the Go workspace. The output file of a relative -o flag
+is placed in the directory of each platform as well, whereas an absolute
+one is rejected for several platforms.
+
+The -bin-dir flag places the binaries built by 'jiri go build' and 'jiri go
+install' in the given directory, such as the one identified by V23_BIN_DIR.
+Binaries built with the -race, -msan or -tags flags are placed in a
+subdirectory named after the build variant, e.g. <bin-dir>/race or
+<bin-dir>/tags-foo, so that they do not overwrite the regular binaries. The
+build tags include those added automatically for the requested profiles.
+
+The -test-json-metadata flag adds a "Vanadium" field, which holds the
+profiles and target used, to each of the JSON events emitted by 'go test
+-json', so that tools consuming these events can tell runs for different
+configurations apart.
 `,
 	ArgsName: "<arg ...>",
 	ArgsLong: "<arg ...> is a list of arguments for the go tool.",
 }
 
+// fastEnv is the environment variable that enables the -fast flag.
+const fastEnv = "JIRI_GO_FAST"
+
 var (
-	extraLDFlags string
-	systemGoFlag bool
-	envFlag      bool
-	readerFlags  profilescmdline.ReaderFlagValues
+	binDirFlag       string
+	extraLDFlags     string
+	systemGoFlag     bool
+	envFlag          bool
+	forceVDLFlag     bool
+	fastFlag         bool
+	noAutoTags       bool
+	platformsFlag    string
+	platformsDirFlag string
+	requireVDLFlag   bool
+	testJSONFlag     bool
+	readerFlags      profilescmdline.ReaderFlagValues
 )
 
 func init() {
 	profilescmdline.RegisterReaderFlags(&cmdGo.Flags, &readerFlags, "v23:base", jiri.ProfilesDBDir)
+	flag.StringVar(&binDirFlag, "bin-dir", "", "directory in which 'go build' and 'go install' place binaries, in a subdirectory named after the build variant for builds with -race, -msan or -tags")
 	flag.BoolVar(&systemGoFlag, "system-go", false, "use the version of go found in $PATH rather than that built by the go profile")
 	flag.StringVar(&extraLDFlags, "extra-ldflags", "", golib.ExtraLDFlagsFlagDescription)
+	flag.BoolVar(&forceVDLFlag, "force-vdl", false, golib.ForceVDLFlagDescription)
+	flag.BoolVar(&fastFlag, "fast", false, "skip the report of outdated branches and the VDL generation; can also be enabled by setting the "+fastEnv+" environment variable to 1")
 	flag.BoolVar(&envFlag, "print-run-env", false, "print detailed info on environment variables and the command line used")
+	flag.BoolVar(&noAutoTags, "no-auto-tags", false, "do not add the build tags that the requested profiles record for the target in the profiles database")
+	flag.StringVar(&platformsFlag, "platforms", "", "comma-separated list of <os>-<arch> platforms, such as linux-amd64,darwin-amd64,linux-arm, to run the go tool for in parallel")
+	flag.StringVar(&platformsDirFlag, "platforms-dir", ".", "directory in which the go tool is run for each of the -platforms, in a <os>-<arch> subdirectory")
+	flag.BoolVar(&requireVDLFlag, "require-vdl", false, golib.RequireVDLFlagDescription)
+	flag.BoolVar(&testJSONFlag, "test-json-metadata", false, "add the profiles and target to each event of 'go test -json'")
 	tool.InitializeRunFlags(&cmdGo.Flags)
 }
 
@@ -58,19 +122,109 @@ func runGo(jirix *jiri.X, args []string) error {
 	if len(args) == 0 {
 		return jirix.UsageErrorf("not enough arguments")
 	}
+	if args[0] == "buildinfo" {
+		return runBuildInfo(jirix, args[1:])
+	}
 	config, err := tooldata.LoadConfig(jirix)
 	if err != nil {
 		return err
 	}
+	if platformsFlag != "" {
+		if binDirFlag != "" {
+			return jirix.UsageErrorf("the -bin-dir and -platforms flags cannot be used together")
+		}
+		return runGoPlatforms(jirix, config, args)
+	}
+	cwd := ""
+	if binDirFlag != "" && (args[0] == "build" || args[0] == "install") {
+		if cwd, err = os.Getwd(); err != nil {
+			return err
+		}
+		args = absPathArgs(cwd, args)
+	}
+	inv, err := prepareGo(jirix, config, readerFlags.Target, args)
+	if err != nil || inv == nil {
+		return err
+	}
+	dir := ""
+	if cwd != "" {
+		// The variant is taken from the arguments that include the
+		// automatic build tags.
+		if dir, err = variantBinDir(jirix, cwd, binDirFlag, inv.variant); err != nil {
+			return err
+		}
+		if args[0] == "install" {
+			// 'go install' places binaries in GOBIN regardless of the
+			// directory it runs in.
+			inv.env["GOBIN"], dir = dir, ""
+		}
+	}
+	return runutil.TranslateExitCode(inv.run(jirix, dir, jirix.Stdout(), jirix.Stderr()))
+}
+
+// variantBinDir returns the directory in which the binaries of the given
+// build variant are placed, creating it if needed. This is the given bin
+// directory for regular builds, or its subdirectory named after the build
+// variant otherwise. A relative bin directory is interpreted with respect
+// to the given working directory.
+func variantBinDir(jirix *jiri.X, cwd, binDir, variant string) (string, error) {
+	if !filepath.IsAbs(binDir) {
+		binDir = filepath.Join(cwd, binDir)
+	}
+	dir := filepath.Join(binDir, variant)
+	if err := jirix.NewSeq().MkdirAll(dir, os.FileMode(0755)).Done(); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// goInvocation records how to invoke the go tool.
+type goInvocation struct {
+	env   map[string]string
+	goBin string
+	args  []string
+	// variant is the build variant selected by the arguments, including
+	// the automatic build tags, as returned by golib.BuildVariant.
+	variant  string
+	metadata testEventMetadata
+}
+
+// run runs the go tool in the given directory, or the current directory
+// if dir is empty. If the -test-json-metadata flag is set and the go tool
+// emits test events, the events are augmented with the metadata of the
+// invocation.
+func (inv *goInvocation) run(jirix *jiri.X, dir string, stdout, stderr io.Writer) error {
+	var events *testEventWriter
+	if testJSONFlag && isTestJSON(inv.args) {
+		events = newTestEventWriter(stdout, inv.metadata)
+		stdout = events
+	}
+	s := jirix.NewSeq().Env(inv.env)
+	if dir != "" {
+		s = s.Dir(dir)
+	}
+	err := s.Capture(stdout, stderr).Last(inv.goBin, inv.args...)
+	if events != nil {
+		if flushErr := events.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// prepareGo sets up the environment for running the go tool with the
+// given arguments for the given target. It returns nil if there is no
+// need to run the go tool.
+func prepareGo(jirix *jiri.X, config *tooldata.Config, target profiles.Target, args []string) (*goInvocation, error) {
 	rd, err := profilesreader.NewReader(jirix, readerFlags.ProfilesMode, readerFlags.DBFilename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	profileNames := strings.Split(readerFlags.Profiles, ",")
-	if err := rd.ValidateRequestedProfilesAndTarget(profileNames, readerFlags.Target); err != nil {
-		return err
+	if err := rd.ValidateRequestedProfilesAndTarget(profileNames, target); err != nil {
+		return nil, err
 	}
-	rd.MergeEnvFromProfiles(readerFlags.MergePolicies, readerFlags.Target, profileNames...)
+	rd.MergeEnvFromProfiles(readerFlags.MergePolicies, target, profileNames...)
 	mp := profilesreader.MergePolicies{
 		"GOPATH":  profilesreader.PrependPath,
 		"VDLPATH": profilesreader.PrependPath,
@@ -88,29 +242,215 @@ func runGo(jirix *jiri.X, args []string) error {
 	}
 	envMap := rd.ToMap()
 	var installSuffix string
-	if readerFlags.Target.OS() == "fnl" {
+	if target.OS() == "fnl" {
 		installSuffix = "musl"
 	}
-	newArgs, err := golib.PrepareGo(jirix, envMap, args, extraLDFlags, installSuffix)
+	if !noAutoTags && readerFlags.ProfilesMode != profilesreader.SkipProfiles {
+		if tags := profileBuildTags(rd.LookupProfileTarget, profileNames, target); len(tags) > 0 {
+			if envFlag || jirix.Verbose() {
+				fmt.Fprintf(jirix.Stdout(), "Automatic build tags: %v\n", strings.Join(tags, " "))
+			}
+			args = golib.AddBuildTags(args, tags)
+		}
+	}
+	variant := golib.BuildVariant(args[1:])
+	newArgs, err := golib.PrepareGo(jirix, envMap, args, extraLDFlags, installSuffix,
+		golib.FastOpt(fastFlag || os.Getenv(fastEnv) == "1"),
+		golib.ForceVDLOpt(forceVDLFlag),
+		golib.SkipMissingVDLOpt(!requireVDLFlag && os.Getenv(golib.RequireVDLEnv) != "1"))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Don't run go env if PrepareGo stripped off the environment
 	// variables that the go tool doesn't understand - e.g. VDLPATH,
 	// unless the original command was just 'go env'.
 	if len(newArgs) == 1 && newArgs[0] == "env" && !(len(args) == 1 && args[0] == "env") {
-		return nil
+		return nil, nil
 	}
-	// Run the go tool.
 	goBin, err := lookpath.Look(envMap, "go")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if envFlag {
 		fmt.Fprintf(jirix.Stdout(), "\n%v %s\n", goBin, strings.Join(newArgs, " "))
 	}
-	err = jirix.NewSeq().Env(envMap).Capture(jirix.Stdout(), jirix.Stderr()).Last(goBin, newArgs...)
-	return runutil.TranslateExitCode(err)
+	return &goInvocation{
+		env:     envMap,
+		goBin:   goBin,
+		args:    newArgs,
+		variant: variant,
+		metadata: testEventMetadata{
+			Profiles: profileNames,
+			Target:   fmt.Sprintf("%s-%s", target.Arch(), target.OS()),
+		},
+	}, nil
+}
+
+// buildTagsEnv is the variable in which profiles record the build tags
+// that Go code built for their targets requires, separated by spaces.
+const buildTagsEnv = "GO_BUILD_TAGS"
+
+// profileBuildTags returns the sorted build tags that the given profiles
+// record for the given target, as looked up by the given function.
+func profileBuildTags(lookup func(installer, profile string, target profiles.Target) *profiles.Target, profileNames []string, target profiles.Target) []string {
+	set := map[string]bool{}
+	for _, name := range profileNames {
+		installer, profile := "", name
+		if i := strings.Index(name, ":"); i != -1 {
+			installer, profile = name[:i], name[i+1:]
+		}
+		t := lookup(installer, profile, target)
+		if t == nil {
+			continue
+		}
+		for _, tag := range strings.Fields(envvar.VarsFromSlice(t.Env.Vars).Get(buildTagsEnv)) {
+			set[tag] = true
+		}
+	}
+	tags := []string{}
+	for tag := range set {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// goPlatform identifies a platform to build for.
+type goPlatform struct {
+	os, arch string
+}
+
+func (p goPlatform) String() string {
+	return p.os + "-" + p.arch
+}
+
+// parsePlatforms parses the value of the -platforms flag, which is a
+// comma-separated list of <os>-<arch> pairs.
+func parsePlatforms(value string) ([]goPlatform, error) {
+	platforms, seen := []goPlatform{}, map[goPlatform]bool{}
+	for _, s := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(s), "-")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform %q, expected <os>-<arch>", s)
+		}
+		p := goPlatform{os: parts[0], arch: parts[1]}
+		if !seen[p] {
+			platforms = append(platforms, p)
+			seen[p] = true
+		}
+	}
+	return platforms, nil
+}
+
+// absPathArgs returns a copy of the given arguments in which the
+// arguments that are relative paths, such as "./..." or "../foo", are
+// made absolute with respect to the given directory. The output file
+// given by the -o flag is left alone, so that a relative output file
+// is placed in the directory the go tool runs in, which is separate for
+// each of the -platforms.
+func absPathArgs(dir string, args []string) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		isOutput := i > 0 && args[i-1] == "-o"
+		if !isOutput && (arg == "." || arg == ".." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../")) {
+			abs := filepath.Join(dir, arg)
+			if strings.HasSuffix(arg, "/...") {
+				abs = filepath.Join(dir, strings.TrimSuffix(arg, "/...")) + "/..."
+			}
+			arg = abs
+		}
+		result[i] = arg
+	}
+	return result
+}
+
+// outputArg returns the output file given by the -o flag in the given
+// arguments, or the empty string if there is none.
+func outputArg(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "-o" && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "-o="):
+			return strings.TrimPrefix(arg, "-o=")
+		}
+	}
+	return ""
+}
+
+// runGoPlatforms runs the go tool with the given arguments for each of
+// the platforms given by the -platforms flag. The environment of each
+// platform is prepared in turn, after which the go tool is run for all
+// platforms in parallel, each in its own <platforms-dir>/<os>-<arch>
+// directory.
+func runGoPlatforms(jirix *jiri.X, config *tooldata.Config, args []string) error {
+	platforms, err := parsePlatforms(platformsFlag)
+	if err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	if output := outputArg(args); len(platforms) > 1 && filepath.IsAbs(output) {
+		return jirix.UsageErrorf("the absolute output file %v would be written for each of the -platforms; use a relative one, which is placed in the directory of each platform", output)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	args = absPathArgs(cwd, args)
+	outDir := platformsDirFlag
+	if !filepath.IsAbs(outDir) {
+		outDir = filepath.Join(cwd, outDir)
+	}
+	invs := make([]*goInvocation, len(platforms))
+	for i, p := range platforms {
+		target, err := profiles.NewTarget(p.arch+"-"+p.os, "")
+		if err != nil {
+			return err
+		}
+		inv, err := prepareGo(jirix, config, target, args)
+		if err != nil {
+			return fmt.Errorf("%v: %v", p, err)
+		}
+		if inv == nil {
+			return fmt.Errorf("%v: the go tool has nothing to run for %q", p, strings.Join(args, " "))
+		}
+		// Make sure the go tool targets the platform even if the
+		// profiles do not set GOOS and GOARCH for it.
+		inv.env["GOOS"], inv.env["GOARCH"] = p.os, p.arch
+		if err := jirix.NewSeq().MkdirAll(filepath.Join(outDir, p.String()), os.FileMode(0755)).Done(); err != nil {
+			return err
+		}
+		invs[i] = inv
+	}
+
+	// Run the go tool for all platforms in parallel.
+	outputs := make([]bytes.Buffer, len(platforms))
+	errs := make([]error, len(platforms))
+	var wg sync.WaitGroup
+	for i := range platforms {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dir := filepath.Join(outDir, platforms[i].String())
+			errs[i] = invs[i].run(jirix, dir, &outputs[i], &outputs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	// Report the results in the order the platforms were given.
+	failed := []string{}
+	for i, p := range platforms {
+		status := "OK"
+		if errs[i] != nil {
+			status = "FAILED"
+			failed = append(failed, p.String())
+		}
+		fmt.Fprintf(jirix.Stdout(), "##### %v: %v #####\n", p, status)
+		jirix.Stdout().Write(outputs[i].Bytes())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("go %v failed for: %v", args[0], strings.Join(failed, ", "))
+	}
+	return nil
 }
 
 func main() {
@@ -8,11 +8,13 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"v.io/jiri/jiritest"
+	"v.io/jiri/profiles"
 	"v.io/jiri/profiles/profilesreader"
 	"v.io/jiri/project"
 	"v.io/jiri/tool"
@@ -114,4 +116,96 @@ func TestGoBuildWithMetaData(t *testing.T) {
 	if bi.Time.Before(start.Add(fudge)) {
 		t.Errorf("build time %v < start %v", bi.Time, start)
 	}
+	// Check that the metadata read from the binary file matches.
+	data, err := s.ReadFile(testbin)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if md, err = metadataFromBinary(data); err != nil {
+		t.Fatalf("metadataFromBinary failed: %v", err)
+	}
+	fileBI, err := buildinfo.FromMetaData(md)
+	if err != nil {
+		t.Errorf("DecodeMetaData(%#v) failed: %v", md, err)
+	}
+	if got, want := fileBI, bi; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	got, err := parsePlatforms("linux-amd64,darwin-amd64, linux-arm,linux-amd64")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []goPlatform{{"linux", "amd64"}, {"darwin", "amd64"}, {"linux", "arm"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	for _, value := range []string{"linux", "linux-", "-amd64", "linux-amd64-v7", "linux-amd64,"} {
+		if _, err := parsePlatforms(value); err == nil {
+			t.Errorf("parsePlatforms(%q) did not fail", value)
+		}
+	}
+}
+
+func TestAbsPathArgs(t *testing.T) {
+	got := absPathArgs("/a/b", []string{"build", "-o", "./out", ".", "./...", "../c/...", "v.io/x/ref/..."})
+	want := []string{"build", "-o", "./out", "/a/b", "/a/b/...", "/a/c/...", "v.io/x/ref/..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestOutputArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"build", "./..."}, ""},
+		{[]string{"build", "-o", "./out", "."}, "./out"},
+		{[]string{"build", "-o=/tmp/out", "."}, "/tmp/out"},
+	}
+	for _, test := range tests {
+		if got := outputArg(test.args); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestProfileBuildTags(t *testing.T) {
+	target, err := profiles.NewTarget("amd64-linux", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := map[string][]string{
+		"v23:base":   {"GO_BUILD_TAGS=cgo_sqlite leveldb"},
+		"v23:nodejs": {"NODE_BIN=/usr/bin/node"},
+		"syncbase":   {"GO_BUILD_TAGS=leveldb syncbase"},
+	}
+	lookup := func(installer, profile string, target profiles.Target) *profiles.Target {
+		name := profile
+		if installer != "" {
+			name = installer + ":" + profile
+		}
+		vars, ok := envs[name]
+		if !ok {
+			return nil
+		}
+		target.Env.Vars = vars
+		return &target
+	}
+	tests := []struct {
+		profiles []string
+		want     []string
+	}{
+		{[]string{"v23:base"}, []string{"cgo_sqlite", "leveldb"}},
+		{[]string{"v23:base", "syncbase"}, []string{"cgo_sqlite", "leveldb", "syncbase"}},
+		{[]string{"v23:nodejs", "v23:unknown"}, []string{}},
+	}
+	for _, test := range tests {
+		if got := profileBuildTags(lookup, test.profiles, target); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.profiles, got, test.want)
+		}
+	}
 }
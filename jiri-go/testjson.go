@@ -0,0 +1,117 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// testEventMetadata is the vanadium metadata added to the events emitted
+// by 'go test -json'.
+type testEventMetadata struct {
+	Profiles []string
+	Target   string
+}
+
+// isTestJSON returns whether the given go tool arguments run tests that
+// emit JSON events.
+func isTestJSON(args []string) bool {
+	if len(args) == 0 || args[0] != "test" {
+		return false
+	}
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "-args" || arg == "--args":
+			// The remaining arguments are passed to the test binary.
+			return false
+		case arg == "-json" || arg == "--json":
+			return true
+		case strings.HasPrefix(arg, "-json=") || strings.HasPrefix(arg, "--json="):
+			return strings.SplitN(arg, "=", 2)[1] == "true"
+		}
+	}
+	return false
+}
+
+// testEventWriter is an io.Writer that adds vanadium metadata to the
+// JSON events emitted by 'go test -json' and writes them to an
+// underlying writer. Each event is extended with a "Vanadium" field
+// that holds the metadata. Lines that are not JSON objects, such as
+// build errors, are written unchanged.
+type testEventWriter struct {
+	w     io.Writer
+	field []byte
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func newTestEventWriter(w io.Writer, metadata testEventMetadata) *testEventWriter {
+	// Marshaling a struct of strings does not fail.
+	value, _ := json.Marshal(metadata)
+	return &testEventWriter{
+		w:     w,
+		field: append([]byte(`,"Vanadium":`), value...),
+	}
+}
+
+// Write implements io.Writer. Complete lines are written immediately,
+// while a trailing incomplete line is buffered until it is completed or
+// Flush is called.
+func (tw *testEventWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.buf = append(tw.buf, p...)
+	for {
+		i := bytes.IndexByte(tw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := tw.buf[:i+1]
+		if _, err := tw.w.Write(tw.augment(line)); err != nil {
+			return 0, err
+		}
+		tw.buf = tw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the buffered incomplete line, if any.
+func (tw *testEventWriter) Flush() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if len(tw.buf) == 0 {
+		return nil
+	}
+	_, err := tw.w.Write(tw.augment(tw.buf))
+	tw.buf = nil
+	return err
+}
+
+// augment returns the given line with the metadata field added if the
+// line is a JSON object, or the line itself otherwise.
+func (tw *testEventWriter) augment(line []byte) []byte {
+	obj := bytes.TrimSpace(line)
+	var event map[string]interface{}
+	if json.Unmarshal(obj, &event) != nil || event == nil {
+		return line
+	}
+	field := tw.field
+	if len(event) == 0 {
+		field = field[1:]
+	}
+	result := make([]byte, 0, len(obj)+len(field)+1)
+	result = append(result, obj[:len(obj)-1]...)
+	result = append(result, field...)
+	result = append(result, '}')
+	if bytes.HasSuffix(line, []byte("\n")) {
+		result = append(result, '\n')
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsTestJSON(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"test", "-json", "v.io/x/ref/..."}, true},
+		{[]string{"test", "-run", "TestFoo", "--json", "./..."}, true},
+		{[]string{"test", "./...", "-json=true"}, true},
+		{[]string{"test", "-json=false", "./..."}, false},
+		{[]string{"test", "./...", "-args", "-json"}, false},
+		{[]string{"build", "-json", "./..."}, false},
+		{[]string{"test", "./..."}, false},
+	}
+	for _, test := range tests {
+		if got := isTestJSON(test.args); got != test.want {
+			t.Errorf("isTestJSON(%v): want %v, got %v", test.args, test.want, got)
+		}
+	}
+}
+
+func TestTestEventWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newTestEventWriter(&out, testEventMetadata{
+		Profiles: []string{"v23:base"},
+		Target:   "amd64-linux",
+	})
+	// Write the input in pieces that split lines, to check that
+	// incomplete lines are buffered.
+	input := `{"Action":"run","Test":"TestFoo"}
+# v.io/x/foo
+{}
+{"Action":"pass","Package":"v.io/x/foo"}`
+	for _, piece := range []string{input[:10], input[10:40], input[40:]} {
+		if _, err := w.Write([]byte(piece)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := `{"Action":"run","Test":"TestFoo","Vanadium":{"Profiles":["v23:base"],"Target":"amd64-linux"}}
+# v.io/x/foo
+{"Vanadium":{"Profiles":["v23:base"],"Target":"amd64-linux"}}
+{"Action":"pass","Package":"v.io/x/foo","Vanadium":{"Profiles":["v23:base"],"Target":"amd64-linux"}}`
+	if got := out.String(); got != want {
+		t.Errorf("want\n%v\ngot\n%v", want, got)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"v.io/jiri"
+	jiriTest "v.io/x/devtools/jiri-test/internal/test"
+	"v.io/x/devtools/tooldata"
+	"v.io/x/lib/cmdline"
+)
+
+var (
+	generateArchFlag     string
+	generateDirFlag      string
+	generateOSFlag       string
+	generateTemplateFlag string
+	generateTimeoutFlag  time.Duration
+)
+
+func init() {
+	cmdGenerate.Flags.StringVar(&generateArchFlag, "arch", "amd64", "Comma-separated list of the values of the ARCH axis of multi-configuration jobs.")
+	cmdGenerate.Flags.StringVar(&generateDirFlag, "dir", "", "The directory to write the job configs into, one <test>.xml file per test. If empty, the configs are written to stdout.")
+	cmdGenerate.Flags.StringVar(&generateOSFlag, "os", "linux,darwin", "Comma-separated list of the values of the OS axis of multi-configuration jobs.")
+	cmdGenerate.Flags.StringVar(&generateTemplateFlag, "template", "", "The path to a Go text/template file to render each job with instead of the built-in Jenkins XML template.")
+	cmdGenerate.Flags.DurationVar(&generateTimeoutFlag, "timeout", time.Hour, "The timeout of the jobs of tests whose own timeout is not longer.")
+}
+
+// cmdGenerate represents the "jiri test generate" command.
+var cmdGenerate = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runGenerate),
+	Name:   "generate",
+	Short:  "Generate Jenkins job configs for tests",
+	Long: `
+Generates the configs of the Jenkins jobs that run the given tests, or all the
+tests listed by "jiri test list" if none are given, so that the job definitions
+stay in sync with the tests.
+
+Tests that the tools config lists as Jenkins matrix jobs are rendered as
+multi-configuration jobs with OS and ARCH axes, as configured. Tests that the
+tools config splits into parts get a P axis, whose values identify the parts
+that each configuration runs with "jiri test run -part". The job timeout is the
+timeout of the test, as listed by "jiri test list -json", if it is longer than
+the -timeout flag, or the -timeout flag otherwise.
+
+With -template, each job is rendered with the given Go text/template instead of
+the built-in Jenkins XML template, e.g. to produce Job DSL scripts. The template
+is executed with a value that has the Name, Description, Command, Matrix, Axes
+(each with a Name and Values), Parts and TimeoutMinutes fields of the job, and
+can use the "xml" function to escape text.
+`,
+	ArgsName: "<test ...>",
+	ArgsLong: "<test ...> is a list of tests to generate job configs for.",
+}
+
+// jenkinsAxis is an axis of a multi-configuration Jenkins job.
+type jenkinsAxis struct {
+	Name   string
+	Values []string
+}
+
+// jenkinsJob describes the Jenkins job that runs a test.
+type jenkinsJob struct {
+	Name        string
+	Description string
+	// Command is the shell command that runs the test, which refers
+	// to the values of the axes of the job as environment variables.
+	Command string
+	// Matrix records whether the job is a multi-configuration job.
+	Matrix bool
+	Axes   []jenkinsAxis
+	// Parts is the number of parts the test is split into, or 0 if
+	// the test is not split.
+	Parts          int
+	TimeoutMinutes int
+}
+
+func runGenerate(jirix *jiri.X, args []string) error {
+	jiriTest.ProfilesDBFilename = readerFlags.DBFilename
+	if err := jiriTest.LoadPlugins(jirix); err != nil {
+		return err
+	}
+	tests, err := jiriTest.ListTests()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		known := map[string]bool{}
+		for _, test := range tests {
+			known[test] = true
+		}
+		for _, arg := range args {
+			if !known[arg] {
+				return jirix.UsageErrorf("unknown test %q", arg)
+			}
+		}
+		tests = args
+	}
+	tmpl := jenkinsJobTemplate
+	if generateTemplateFlag != "" {
+		data, err := ioutil.ReadFile(generateTemplateFlag)
+		if err != nil {
+			return fmt.Errorf("ReadFile(%v) failed: %v", generateTemplateFlag, err)
+		}
+		if tmpl, err = parseJobTemplate(filepath.Base(generateTemplateFlag), string(data)); err != nil {
+			return err
+		}
+	}
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return err
+	}
+	jobs, err := jenkinsJobs(config, tests, splitValues(generateOSFlag), splitValues(generateArchFlag), generateTimeoutFlag)
+	if err != nil {
+		return err
+	}
+	s := jirix.NewSeq()
+	if generateDirFlag != "" {
+		if err := s.MkdirAll(generateDirFlag, os.FileMode(0755)).Done(); err != nil {
+			return err
+		}
+	}
+	for _, job := range jobs {
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, job); err != nil {
+			return fmt.Errorf("Execute() failed for test %q: %v", job.Name, err)
+		}
+		if generateDirFlag == "" {
+			fmt.Fprintf(jirix.Stdout(), "%s", out.String())
+			continue
+		}
+		path := filepath.Join(generateDirFlag, job.Name+".xml")
+		if err := s.WriteFile(path, out.Bytes(), os.FileMode(0644)).Done(); err != nil {
+			return err
+		}
+		if jirix.Verbose() {
+			fmt.Fprintf(jirix.Stdout(), "wrote %v\n", path)
+		}
+	}
+	return nil
+}
+
+// splitValues splits the given comma-separated list of axis values.
+func splitValues(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// jenkinsJobs returns the Jenkins jobs that run the given tests, using
+// the given values of the OS and ARCH axes for multi-configuration jobs.
+// The timeout of a job is the timeout of its test, if it is longer than
+// the given timeout, or the given timeout otherwise.
+func jenkinsJobs(config *tooldata.Config, tests, osValues, archValues []string, timeout time.Duration) ([]jenkinsJob, error) {
+	matrixJobs := config.JenkinsMatrixJobs()
+	jobs := []jenkinsJob{}
+	for _, test := range tests {
+		job := jenkinsJob{Name: test}
+		if spec, ok := jiriTest.LookupTestSpec(test); ok {
+			job.Description = spec.Description
+		}
+		testInfo, err := jiriTest.LookupTestInfo(config, test)
+		if err != nil {
+			return nil, err
+		}
+		jobTimeout := timeout
+		if testInfo.Timeout > jobTimeout {
+			jobTimeout = testInfo.Timeout
+		}
+		job.TimeoutMinutes = int((jobTimeout + time.Minute - 1) / time.Minute)
+		info, isMatrix := matrixJobs[test]
+		if isMatrix && info.HasOS {
+			job.Axes = append(job.Axes, jenkinsAxis{Name: "OS", Values: osValues})
+		}
+		if isMatrix && info.HasArch {
+			job.Axes = append(job.Axes, jenkinsAxis{Name: "ARCH", Values: archValues})
+		}
+		command := "jiri test run"
+		if parts := config.TestParts(test); len(parts) > 0 {
+			// Besides the configured parts, the last part runs whatever
+			// the configured parts do not cover.
+			job.Parts = len(parts) + 1
+			values := []string{}
+			for i := 0; i < job.Parts; i++ {
+				values = append(values, fmt.Sprintf("%d", i))
+			}
+			job.Axes = append(job.Axes, jenkinsAxis{Name: "P", Values: values})
+			command += " -part=${P}"
+		}
+		job.Command = command + " " + test
+		job.Matrix = len(job.Axes) > 0
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// xmlEscape returns the given text escaped for XML.
+func xmlEscape(text string) (string, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(text)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseJobTemplate parses the given template of job configs.
+func parseJobTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"xml": xmlEscape}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Parse(%v) failed: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// jenkinsJobTemplate renders a jenkinsJob as the XML config of a
+// freestyle or multi-configuration Jenkins project.
+var jenkinsJobTemplate = template.Must(parseJobTemplate("jenkins", `<?xml version='1.0' encoding='UTF-8'?>
+{{if .Matrix}}<matrix-project>{{else}}<project>{{end}}
+  <description>{{xml .Description}}</description>
+  <concurrentBuild>false</concurrentBuild>{{if .Matrix}}
+  <axes>{{range .Axes}}
+    <hudson.matrix.TextAxis>
+      <name>{{xml .Name}}</name>
+      <values>{{range .Values}}
+        <string>{{xml .}}</string>{{end}}
+      </values>
+    </hudson.matrix.TextAxis>{{end}}
+  </axes>{{end}}
+  <builders>
+    <hudson.tasks.Shell>
+      <command>{{xml .Command}}</command>
+    </hudson.tasks.Shell>
+  </builders>
+  <buildWrappers>
+    <hudson.plugins.build__timeout.BuildTimeoutWrapper>
+      <strategy class="hudson.plugins.build_timeout.impl.AbsoluteTimeOutStrategy">
+        <timeoutMinutes>{{.TimeoutMinutes}}</timeoutMinutes>
+      </strategy>
+      <operationList>
+        <hudson.plugins.build__timeout.operations.AbortOperation/>
+      </operationList>
+    </hudson.plugins.build__timeout.BuildTimeoutWrapper>
+  </buildWrappers>
+{{if .Matrix}}</matrix-project>{{else}}</project>{{end}}
+`))
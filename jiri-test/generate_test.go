@@ -0,0 +1,111 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"v.io/x/devtools/tooldata"
+)
+
+func TestJenkinsJobs(t *testing.T) {
+	config := tooldata.NewConfig(
+		tooldata.JenkinsMatrixJobsOpt(map[string]tooldata.JenkinsMatrixJobInfo{
+			"vanadium-go-race": {HasOS: true, HasParts: true, Name: "vanadium-go-race"},
+			"vanadium-js-test": {HasOS: true, HasArch: true, Name: "vanadium-js-test"},
+		}),
+		tooldata.MakeTestsOpt(map[string]tooldata.MakeTestSettings{
+			"vanadium-js-test": {Name: "vanadium-js-test", Timeout: "90m"},
+		}),
+		tooldata.TestPartsOpt(map[string][]string{
+			"vanadium-go-race": []string{"v.io/x/ref/services/...", "v.io/x/ref/runtime/..."},
+		}),
+	)
+	jobs, err := jenkinsJobs(config, []string{"vanadium-go-build", "vanadium-go-race", "vanadium-js-test"}, []string{"linux", "darwin"}, []string{"amd64"}, 30*time.Second)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []jenkinsJob{
+		{
+			Name:           "vanadium-go-build",
+			Command:        "jiri test run vanadium-go-build",
+			TimeoutMinutes: 1,
+		},
+		{
+			Name:    "vanadium-go-race",
+			Command: "jiri test run -part=${P} vanadium-go-race",
+			Matrix:  true,
+			Axes: []jenkinsAxis{
+				{Name: "OS", Values: []string{"linux", "darwin"}},
+				{Name: "P", Values: []string{"0", "1", "2"}},
+			},
+			Parts:          3,
+			TimeoutMinutes: 30,
+		},
+		{
+			Name:    "vanadium-js-test",
+			Command: "jiri test run vanadium-js-test",
+			Matrix:  true,
+			Axes: []jenkinsAxis{
+				{Name: "OS", Values: []string{"linux", "darwin"}},
+				{Name: "ARCH", Values: []string{"amd64"}},
+			},
+			TimeoutMinutes: 90,
+		},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Fatalf("want %#v, got %#v", want, jobs)
+	}
+
+	// Check that the built-in template renders well-formed XML.
+	for _, job := range jobs {
+		job.Description = "Tests <everything> & more."
+		var out bytes.Buffer
+		if err := jenkinsJobTemplate.Execute(&out, job); err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		var project struct {
+			XMLName     xml.Name
+			Description string   `xml:"description"`
+			Axes        []string `xml:"axes>hudson.matrix.TextAxis>name"`
+			Command     string   `xml:"builders>hudson.tasks.Shell>command"`
+			Timeout     int      `xml:"buildWrappers>hudson.plugins.build__timeout.BuildTimeoutWrapper>strategy>timeoutMinutes"`
+		}
+		if err := xml.Unmarshal(out.Bytes(), &project); err != nil {
+			t.Fatalf("Unmarshal(%v) failed: %v", out.String(), err)
+		}
+		wantRoot := "project"
+		if job.Matrix {
+			wantRoot = "matrix-project"
+		}
+		if got := project.XMLName.Local; got != wantRoot {
+			t.Errorf("%v: want root %v, got %v", job.Name, wantRoot, got)
+		}
+		if project.Description != job.Description || project.Command != job.Command || project.Timeout != job.TimeoutMinutes || len(project.Axes) != len(job.Axes) {
+			t.Errorf("%v: unexpected config:\n%v", job.Name, out.String())
+		}
+	}
+
+	// Check that custom templates can be used.
+	tmpl, err := parseJobTemplate("dsl", `job('{{.Name}}') { steps { shell('{{.Command}}') } }`)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, jobs[0]); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if got, want := out.String(), "job('vanadium-go-build') { steps { shell('jiri test run vanadium-go-build') } }"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if _, err := parseJobTemplate("invalid", "{{.Name"); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("parsing an invalid template did not fail: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/xunit"
+)
+
+// maxInlinedTraceLines is the maximum number of lines of a panic trace
+// that are inlined in the failure element of an xUnit report.
+const maxInlinedTraceLines = 200
+
+// panicTracePrefixes identify the lines of the output of a Go test that
+// start a panic or goroutine dump.
+var panicTracePrefixes = []string{"panic: ", "fatal error: ", "SIGQUIT: ", "SIGABRT: "}
+
+// isTestArtifact returns whether the file with the given name, found in
+// the temporary directory of a failed test, should be attached to the
+// test report. Such files are core files and goroutine dumps.
+func isTestArtifact(name string) bool {
+	return name == "core" || strings.HasPrefix(name, "core.") || strings.Contains(name, "goroutine")
+}
+
+// isCoreFile returns whether the file with the given name, found in the
+// directory of a Go package, is a core file. Such files are named "core"
+// or "core.<pid>", as the tests run in the directory of their package.
+func isCoreFile(name string) bool {
+	if name == "core" {
+		return true
+	}
+	if !strings.HasPrefix(name, "core.") || len(name) == len("core.") {
+		return false
+	}
+	for _, r := range strings.TrimPrefix(name, "core.") {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// moveCoreFiles moves the core files found in the given package
+// directory to the given temporary directory, so that they are collected
+// with the other artifacts and do not pollute the source tree.
+func moveCoreFiles(jirix *jiri.X, pkgDir, tmpDir string) error {
+	fileInfos, err := ioutil.ReadDir(pkgDir)
+	if err != nil {
+		return fmt.Errorf("ReadDir(%v) failed: %v", pkgDir, err)
+	}
+	for _, fi := range fileInfos {
+		if fi.IsDir() || !isCoreFile(fi.Name()) {
+			continue
+		}
+		if err := jirix.NewSeq().Last("mv", filepath.Join(pkgDir, fi.Name()), filepath.Join(tmpDir, fi.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// panicTrace returns the part of the given test output that starts with
+// the first panic or goroutine dump, or the empty string if there is
+// none.
+func panicTrace(output string) string {
+	offset := 0
+	for _, line := range strings.SplitAfter(output, "\n") {
+		for _, prefix := range panicTracePrefixes {
+			if strings.HasPrefix(line, prefix) {
+				return output[offset:]
+			}
+		}
+		offset += len(line)
+	}
+	return ""
+}
+
+// trimTrace returns the first maxInlinedTraceLines lines of the given
+// trace, noting how many lines were omitted.
+func trimTrace(trace string) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(trace, "\n"), "\n")
+	if len(lines) <= maxInlinedTraceLines {
+		return trace
+	}
+	omitted := len(lines) - maxInlinedTraceLines
+	return strings.Join(lines[:maxInlinedTraceLines], "") + fmt.Sprintf("......\n(%d more lines in the attachments)\n", omitted)
+}
+
+// collectTestArtifacts copies the core files and goroutine dumps found
+// in the given temporary directory of the given package to a directory
+// under the given attachments directory, so that they survive the
+// removal of the temporary directory. If the given test output
+// contains a panic trace, the trace is saved there as well. The
+// function returns the paths of the copied files.
+func collectTestArtifacts(jirix *jiri.X, tmpDir, attachmentsDir, pkg, output string) ([]string, error) {
+	dir := filepath.Join(attachmentsDir, strings.Replace(pkg, "/", "_", -1))
+	s := jirix.NewSeq()
+	paths := []string{}
+	if trace := panicTrace(output); trace != "" {
+		path := filepath.Join(dir, "panic.txt")
+		if err := s.MkdirAll(dir, os.FileMode(0755)).WriteFile(path, []byte(trace), os.FileMode(0644)).Done(); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	fileInfos, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadDir(%v) failed: %v", tmpDir, err)
+	}
+	for _, fi := range fileInfos {
+		if fi.IsDir() || !isTestArtifact(fi.Name()) {
+			continue
+		}
+		src, dst := filepath.Join(tmpDir, fi.Name()), filepath.Join(dir, fi.Name())
+		if err := s.MkdirAll(dir, os.FileMode(0755)).Last("cp", src, dst); err != nil {
+			return nil, err
+		}
+		paths = append(paths, dst)
+	}
+	return paths, nil
+}
+
+// attachTestArtifacts attaches the artifacts collected for the given
+// test result to the failed test cases of the given test suite. Failures
+// that have no output, such as time outs, are given the trimmed panic
+// trace found in the test output instead.
+func attachTestArtifacts(s *xunit.TestSuite, result testResult) {
+	if s.Failures == 0 {
+		return
+	}
+	trace := panicTrace(result.output)
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if len(c.Failures) == 0 {
+			continue
+		}
+		for j := range c.Failures {
+			if c.Failures[j].Data == "" && trace != "" {
+				c.Failures[j].Data = trimTrace(trace)
+			}
+		}
+		xunit.Attach(c, result.attachments...)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"v.io/x/devtools/internal/xunit"
+)
+
+func TestPanicTrace(t *testing.T) {
+	tests := []struct {
+		output, trace string
+	}{
+		{"=== RUN TestA\n--- PASS: TestA (0.00s)\nPASS\n", ""},
+		{
+			"=== RUN TestA\npanic: boom\n\ngoroutine 5 [running]:\nmain.f()\n",
+			"panic: boom\n\ngoroutine 5 [running]:\nmain.f()\n",
+		},
+		{
+			"=== RUN TestA\nfatal error: concurrent map writes\n",
+			"fatal error: concurrent map writes\n",
+		},
+		{"=== RUN TestA\n  a panic: is not a trace\n", ""},
+	}
+	for _, test := range tests {
+		if got, want := panicTrace(test.output), test.trace; got != want {
+			t.Errorf("panicTrace(%q): want %q, got %q", test.output, want, got)
+		}
+	}
+}
+
+func TestIsCoreFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"core", true},
+		{"core.1234", true},
+		{"core.go", false},
+		{"core_test.go", false},
+		{"core.", false},
+		{"goroutines.go", false},
+	}
+	for _, test := range tests {
+		if got := isCoreFile(test.name); got != test.want {
+			t.Errorf("isCoreFile(%q): want %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestTrimTrace(t *testing.T) {
+	short := "panic: boom\n"
+	if got := trimTrace(short); got != short {
+		t.Errorf("want %q, got %q", short, got)
+	}
+	lines := []string{}
+	for i := 0; i < maxInlinedTraceLines+10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	got := trimTrace(strings.Join(lines, ""))
+	if !strings.HasPrefix(got, strings.Join(lines[:maxInlinedTraceLines], "")) {
+		t.Errorf("trimmed trace does not start with the first lines: %q", got)
+	}
+	if want := "(10 more lines in the attachments)\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("want suffix %q, got %q", want, got)
+	}
+}
+
+func TestAttachTestArtifacts(t *testing.T) {
+	s := xunit.CreateTestSuiteWithFailure("v.io/x/foo", "Test", "test timed out after 10m", "", 0)
+	s.Cases = append(s.Cases, xunit.TestCase{Name: "TestPassed"})
+	result := testResult{
+		pkg:         "v.io/x/foo",
+		output:      "panic: test timed out after 10m\n",
+		status:      testTimedout,
+		attachments: []string{"/w/attachments/v.io_x_foo/panic.txt", "/w/attachments/v.io_x_foo/core"},
+	}
+	attachTestArtifacts(s, result)
+	failed, passed := s.Cases[0], s.Cases[1]
+	if got, want := failed.Failures[0].Data, result.output; got != want {
+		t.Errorf("want failure data %q, got %q", want, got)
+	}
+	if got, want := failed.SystemOut, "[[ATTACHMENT|/w/attachments/v.io_x_foo/panic.txt]]\n[[ATTACHMENT|/w/attachments/v.io_x_foo/core]]\n"; got != want {
+		t.Errorf("want system out %q, got %q", want, got)
+	}
+	if passed.SystemOut != "" {
+		t.Errorf("want no attachments for passed test case, got %q", passed.SystemOut)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/xunit"
+)
+
+const (
+	// benchBaselineEnvVar identifies the benchmark results, either a
+	// local file or a Google Storage URL, that the results of a
+	// benchmark run are compared against.
+	benchBaselineEnvVar = "V23_BENCH_BASELINE"
+	// benchResultsURLEnvVar identifies the Google Storage location that
+	// the results of each benchmark run are uploaded to.
+	benchResultsURLEnvVar = "V23_BENCH_RESULTS_URL"
+	// benchThresholdEnvVar identifies the percentage by which a
+	// benchmark metric needs to get worse to be reported as a
+	// regression.
+	benchThresholdEnvVar  = "V23_BENCH_THRESHOLD"
+	defaultBenchThreshold = 10.0
+)
+
+// benchLineRE matches the result lines of "go test -bench" output, such
+// as "BenchmarkFoo-8   1000   1234 ns/op   56 B/op   2 allocs/op".
+var benchLineRE = regexp.MustCompile(`^(Benchmark\S*)\s+(\d+)\s+(.*)$`)
+
+// benchMetric is a single measurement of a benchmark run.
+type benchMetric struct {
+	Value float64
+	Unit  string
+}
+
+// benchResult records the result of a benchmark run.
+type benchResult struct {
+	Pkg        string
+	Name       string
+	Iterations int
+	Metrics    []benchMetric
+}
+
+// parseBenchmarks parses the given "go test -bench" output of the given
+// package. The output may also be in the format written by
+// formatBenchmarks, in which case "pkg:" lines identify the package of
+// the results that follow them.
+func parseBenchmarks(pkg, output string) []benchResult {
+	results := []benchResult{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "pkg:") {
+			pkg = strings.TrimSpace(strings.TrimPrefix(line, "pkg:"))
+			continue
+		}
+		matches := benchLineRE.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		iterations, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(matches[3])
+		if len(fields) == 0 || len(fields)%2 != 0 {
+			continue
+		}
+		metrics := []benchMetric{}
+		for i := 0; i < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				metrics = nil
+				break
+			}
+			metrics = append(metrics, benchMetric{Value: value, Unit: fields[i+1]})
+		}
+		if metrics == nil {
+			continue
+		}
+		results = append(results, benchResult{
+			Pkg:        pkg,
+			Name:       matches[1],
+			Iterations: iterations,
+			Metrics:    metrics,
+		})
+	}
+	return results
+}
+
+// formatBenchmarks formats the given results in the format of "go test
+// -bench" output, which can be processed by benchstat.
+func formatBenchmarks(results []benchResult) string {
+	var buf bytes.Buffer
+	pkg := ""
+	for i, r := range results {
+		if i == 0 || r.Pkg != pkg {
+			pkg = r.Pkg
+			fmt.Fprintf(&buf, "pkg: %s\n", pkg)
+		}
+		fmt.Fprintf(&buf, "%s\t%d", r.Name, r.Iterations)
+		for _, m := range r.Metrics {
+			fmt.Fprintf(&buf, "\t%s %s", strconv.FormatFloat(m.Value, 'f', -1, 64), m.Unit)
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+	return buf.String()
+}
+
+// benchResultsPath returns the path to the file that the benchmark
+// results of the given test are written to.
+func benchResultsPath(testName string) string {
+	fileName := fmt.Sprintf("bench_%s.txt", strings.Replace(testName, "-", "_", -1))
+	return filepath.Join(filepath.Dir(xunit.ReportPath(testName)), fileName)
+}
+
+// storeBenchmarks writes the given benchmark results next to the xUnit
+// report of the given test and, if a Google Storage location is set
+// through the V23_BENCH_RESULTS_URL environment variable, uploads them
+// there under the name of the Jenkins build number.
+func storeBenchmarks(jirix *jiri.X, testName string, results []benchResult) error {
+	path := benchResultsPath(testName)
+	s := jirix.NewSeq()
+	if err := s.WriteFile(path, []byte(formatBenchmarks(results)), os.FileMode(0644)).Done(); err != nil {
+		return fmt.Errorf("WriteFile(%v) failed: %v", path, err)
+	}
+	url := os.Getenv(benchResultsURLEnvVar)
+	if url == "" {
+		return nil
+	}
+	name := os.Getenv("BUILD_NUMBER")
+	if name == "" {
+		name = time.Now().UTC().Format("20060102-150405")
+	}
+	dst := strings.TrimSuffix(url, "/") + "/" + name + ".txt"
+	if err := s.Last("gsutil", "-q", "cp", path, dst); err != nil {
+		return err
+	}
+	fmt.Fprintf(jirix.Stdout(), "uploaded benchmark results to %s\n", dst)
+	return nil
+}
+
+// loadBenchmarks loads the benchmark results stored in the given local
+// file or Google Storage URL.
+func loadBenchmarks(jirix *jiri.X, location string) ([]benchResult, error) {
+	s := jirix.NewSeq()
+	if strings.HasPrefix(location, "gs://") {
+		var out bytes.Buffer
+		if err := s.Capture(&out, nil).Last("gsutil", "-q", "cat", location); err != nil {
+			return nil, err
+		}
+		return parseBenchmarks("", out.String()), nil
+	}
+	data, err := s.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile(%v) failed: %v", location, err)
+	}
+	return parseBenchmarks("", string(data)), nil
+}
+
+// benchThreshold returns the regression threshold, in percent, set
+// through the V23_BENCH_THRESHOLD environment variable.
+func benchThreshold() (float64, error) {
+	value := os.Getenv(benchThresholdEnvVar)
+	if value == "" {
+		return defaultBenchThreshold, nil
+	}
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("invalid %s value %q", benchThresholdEnvVar, value)
+	}
+	return threshold, nil
+}
+
+// benchRegression records a benchmark metric that got worse.
+type benchRegression struct {
+	Pkg, Name, Unit string
+	Old, New        float64
+}
+
+// delta returns the change of the metric in percent.
+func (r benchRegression) delta() float64 {
+	return (r.New - r.Old) / r.Old * 100
+}
+
+type benchKey struct {
+	pkg, name, unit string
+}
+
+// benchMeans returns the mean of each metric of the given results, along
+// with the order in which the metrics first appear.
+func benchMeans(results []benchResult) (map[benchKey]float64, []benchKey) {
+	sums, counts, keys := map[benchKey]float64{}, map[benchKey]int{}, []benchKey{}
+	for _, r := range results {
+		for _, m := range r.Metrics {
+			key := benchKey{r.Pkg, r.Name, m.Unit}
+			if _, ok := counts[key]; !ok {
+				keys = append(keys, key)
+			}
+			sums[key] += m.Value
+			counts[key]++
+		}
+	}
+	means := map[benchKey]float64{}
+	for key, sum := range sums {
+		means[key] = sum / float64(counts[key])
+	}
+	return means, keys
+}
+
+// compareBenchmarks compares the given results against the given
+// baseline and returns the metrics that got worse by more than the
+// given percentage. Metrics measured per second, such as MB/s, get
+// worse when they decrease; all other metrics get worse when they
+// increase. When a benchmark ran several times, the mean of its
+// measurements is compared.
+func compareBenchmarks(baseline, results []benchResult, threshold float64) []benchRegression {
+	oldMeans, _ := benchMeans(baseline)
+	newMeans, keys := benchMeans(results)
+	regressions := []benchRegression{}
+	for _, key := range keys {
+		old, ok := oldMeans[key]
+		if !ok || old == 0 {
+			continue
+		}
+		r := benchRegression{Pkg: key.pkg, Name: key.name, Unit: key.unit, Old: old, New: newMeans[key]}
+		delta := r.delta()
+		if strings.HasSuffix(key.unit, "/s") {
+			delta = -delta
+		}
+		if delta > threshold {
+			regressions = append(regressions, r)
+		}
+	}
+	return regressions
+}
+
+// benchRegressionSuites encodes the given regressions as xUnit test
+// suites, one for each package, with a failed test case for each
+// regressed benchmark.
+func benchRegressionSuites(regressions []benchRegression) []xunit.TestSuite {
+	pkgs, suites := []string{}, map[string]*xunit.TestSuite{}
+	cases := map[string]map[string]int{}
+	for _, r := range regressions {
+		s, ok := suites[r.Pkg]
+		if !ok {
+			s = &xunit.TestSuite{Name: r.Pkg}
+			suites[r.Pkg] = s
+			cases[r.Pkg] = map[string]int{}
+			pkgs = append(pkgs, r.Pkg)
+		}
+		i, ok := cases[r.Pkg][r.Name]
+		if !ok {
+			i = len(s.Cases)
+			cases[r.Pkg][r.Name] = i
+			s.Cases = append(s.Cases, xunit.TestCase{Classname: r.Pkg, Name: r.Name, Time: "0.00"})
+			s.Tests++
+			s.Failures++
+		}
+		s.Cases[i].Failures = append(s.Cases[i].Failures, xunit.Failure{
+			Message: fmt.Sprintf("%s changed by %+.2f%%", r.Unit, r.delta()),
+			Data:    fmt.Sprintf("old: %v %s\nnew: %v %s\n", r.Old, r.Unit, r.New, r.Unit),
+		})
+	}
+	sort.Strings(pkgs)
+	result := []xunit.TestSuite{}
+	for _, pkg := range pkgs {
+		result = append(result, *suites[pkg])
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBenchmarks(t *testing.T) {
+	output := `goos: linux
+goarch: amd64
+BenchmarkEncode-8   	  200000	      7415 ns/op	    1024 B/op	      12 allocs/op
+BenchmarkDecode/small-8   	 1000000	      1290.5 ns/op	  96.51 MB/s
+BenchmarkBroken-8   	     100	      abc ns/op
+--- BENCH: BenchmarkDecode
+PASS
+ok  	v.io/x/foo	4.123s
+`
+	want := []benchResult{
+		{
+			Pkg:        "v.io/x/foo",
+			Name:       "BenchmarkEncode-8",
+			Iterations: 200000,
+			Metrics:    []benchMetric{{7415, "ns/op"}, {1024, "B/op"}, {12, "allocs/op"}},
+		},
+		{
+			Pkg:        "v.io/x/foo",
+			Name:       "BenchmarkDecode/small-8",
+			Iterations: 1000000,
+			Metrics:    []benchMetric{{1290.5, "ns/op"}, {96.51, "MB/s"}},
+		},
+	}
+	got := parseBenchmarks("v.io/x/foo", output)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	// Check that formatted results can be parsed back.
+	formatted := formatBenchmarks(got)
+	wantFormatted := `pkg: v.io/x/foo
+BenchmarkEncode-8	200000	7415 ns/op	1024 B/op	12 allocs/op
+BenchmarkDecode/small-8	1000000	1290.5 ns/op	96.51 MB/s
+`
+	if formatted != wantFormatted {
+		t.Fatalf("want\n%s\ngot\n%s", wantFormatted, formatted)
+	}
+	if got := parseBenchmarks("", formatted); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	result := func(pkg, name string, metrics ...benchMetric) benchResult {
+		return benchResult{Pkg: pkg, Name: name, Iterations: 1, Metrics: metrics}
+	}
+	baseline := []benchResult{
+		result("a", "BenchmarkA-8", benchMetric{100, "ns/op"}, benchMetric{10, "allocs/op"}),
+		result("a", "BenchmarkB-8", benchMetric{100, "ns/op"}, benchMetric{50, "MB/s"}),
+		result("b", "BenchmarkA-8", benchMetric{100, "ns/op"}),
+	}
+	results := []benchResult{
+		// Within the threshold.
+		result("a", "BenchmarkA-8", benchMetric{105, "ns/op"}, benchMetric{12, "allocs/op"}),
+		// Slower, with a lower throughput.
+		result("a", "BenchmarkB-8", benchMetric{120, "ns/op"}, benchMetric{40, "MB/s"}),
+		// The mean of several runs is compared.
+		result("b", "BenchmarkA-8", benchMetric{90, "ns/op"}),
+		result("b", "BenchmarkA-8", benchMetric{150, "ns/op"}),
+		// Not in the baseline.
+		result("c", "BenchmarkA-8", benchMetric{1000, "ns/op"}),
+	}
+	got := compareBenchmarks(baseline, results, 10)
+	want := []benchRegression{
+		{Pkg: "a", Name: "BenchmarkA-8", Unit: "allocs/op", Old: 10, New: 12},
+		{Pkg: "a", Name: "BenchmarkB-8", Unit: "ns/op", Old: 100, New: 120},
+		{Pkg: "a", Name: "BenchmarkB-8", Unit: "MB/s", Old: 50, New: 40},
+		{Pkg: "b", Name: "BenchmarkA-8", Unit: "ns/op", Old: 100, New: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	suites := benchRegressionSuites(got)
+	if got, want := len(suites), 2; got != want {
+		t.Fatalf("want %d suites, got %d", want, got)
+	}
+	a := suites[0]
+	if a.Name != "a" || a.Tests != 2 || a.Failures != 2 || len(a.Cases) != 2 {
+		t.Fatalf("unexpected suite %#v", a)
+	}
+	if got, want := len(a.Cases[1].Failures), 2; got != want {
+		t.Fatalf("want %d failures, got %d", want, got)
+	}
+	if got, want := a.Cases[1].Failures[1].Message, "MB/s changed by -20.00%"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/goutil"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/tooldata"
+)
+
+// ChangedFilesOpt is an option that specifies the files changed by the
+// code under test. When set, Go tests whose packages do not depend on
+// any of the changed files are skipped.
+type ChangedFilesOpt []string
+
+func (ChangedFilesOpt) Opt() {}
+
+// goTestPackages maps the names of the Go tests that can be skipped when
+// none of their packages depend on the changed files to the Go package
+// expressions the tests run against. A nil value stands for the
+// packages given by the DefaultPkgsOpt option.
+//
+// Tests that check properties of the source tree that go beyond the Go
+// package graph, such as formatting, generated files or APIs, are not
+// included and always run.
+var goTestPackages = map[string][]string{
+	"madb-go-test":              {"madb/..."},
+	"vanadium-go-asan":          {"v.io/..."},
+	"vanadium-go-bench":         {"v.io/..."},
+	"vanadium-go-build":         {"v.io/..."},
+	"vanadium-go-cover":         {"v.io/..."},
+	"vanadium-go-msan":          {"v.io/..."},
+	"vanadium-go-race":          {"v.io/..."},
+	"vanadium-go-test":          nil,
+	"vanadium-go-vet":           {"v.io/..."},
+	"vanadium-integration-test": {"v.io/..."},
+}
+
+// testPackages returns the Go package expressions the given test runs
+// against, or false if the test cannot be skipped based on the changed
+// files.
+func testPackages(testName string, opts []Opt) ([]string, bool) {
+	pkgs, ok := goTestPackages[testName]
+	if !ok {
+		return nil, false
+	}
+	if pkgs == nil {
+		pkgs = getDefaultPkgsOpt(opts)
+	}
+	for _, opt := range opts {
+		if typedOpt, ok := opt.(PkgsOpt); ok && len(typedOpt) > 0 {
+			pkgs = []string(typedOpt)
+		}
+	}
+	return pkgs, true
+}
+
+// dependsOnAny returns whether any of the given dependencies is one of
+// the given packages.
+func dependsOnAny(deps, pkgs []string) bool {
+	set := map[string]bool{}
+	for _, pkg := range pkgs {
+		set[pkg] = true
+	}
+	for _, dep := range deps {
+		if set[dep] {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnaffected returns whether the given test can be skipped because
+// none of its Go packages depend on the files identified by the
+// ChangedFilesOpt option, i.e. none of them is a changed package or
+// one of their reverse dependencies. If the packages cannot be
+// determined, or files outside of the Go packages changed, the test is
+// considered affected. In verbose mode, the chain of imports that makes
+// an affected test depend on a changed package is printed.
+func isUnaffected(jirix *jiri.X, testName string, opts []Opt) bool {
+	var files []string
+	changed := false
+	for _, opt := range opts {
+		if typedOpt, ok := opt.(ChangedFilesOpt); ok {
+			files, changed = []string(typedOpt), true
+		}
+	}
+	if !changed {
+		return false
+	}
+	pkgs, ok := testPackages(testName, opts)
+	if !ok {
+		return false
+	}
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		return false
+	}
+	changedPkgs, ok := goutil.ChangedPackages(jirix.Root, config.GoWorkspaces(), files)
+	if !ok {
+		return false
+	}
+	if len(changedPkgs) == 0 {
+		return true
+	}
+	testPkgs, err := goutil.List(jirix, goListOpts(opts), pkgs...)
+	if err != nil {
+		fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		return false
+	}
+	importers, err := goutil.ReverseDeps(jirix, goListOpts(opts), changedPkgs, pkgs)
+	if err != nil {
+		fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		return false
+	}
+	affectedPkgs := append(append([]string{}, changedPkgs...), importers...)
+	if !dependsOnAny(testPkgs, affectedPkgs) {
+		return true
+	}
+	if jirix.Verbose() {
+		printAffectedPath(jirix, testName, testPkgs, affectedPkgs, changedPkgs, opts)
+	}
+	return false
+}
+
+// printAffectedPath prints the chain of imports that makes the first of
+// the given packages of the given test that is affected by the changes
+// depend on one of the given changed packages.
+func printAffectedPath(jirix *jiri.X, testName string, testPkgs, affectedPkgs, changedPkgs []string, opts []Opt) {
+	for _, from := range testPkgs {
+		if !dependsOnAny([]string{from}, affectedPkgs) {
+			continue
+		}
+		for _, to := range changedPkgs {
+			path, err := goutil.WhyPath(jirix, goListOpts(opts), from, to)
+			if err != nil {
+				fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+				return
+			}
+			if path != nil {
+				test.NewLogger(jirix.Context).WithTest(testName).Debugf("affected by the changes: %s\n", strings.Join(path, " -> "))
+				return
+			}
+		}
+		return
+	}
+}
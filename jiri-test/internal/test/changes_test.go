@@ -0,0 +1,42 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTestPackages(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Opt
+		pkgs []string
+		ok   bool
+	}{
+		{"vanadium-go-test", nil, []string{"v.io/..."}, true},
+		{"vanadium-go-test", []Opt{DefaultPkgsOpt{"madb/..."}}, []string{"madb/..."}, true},
+		{"vanadium-go-race", []Opt{PkgsOpt{}}, []string{"v.io/..."}, true},
+		{"vanadium-go-race", []Opt{PkgsOpt{"v.io/x/ref/..."}}, []string{"v.io/x/ref/..."}, true},
+		{"vanadium-go-format", nil, nil, false},
+		{"vanadium-js-unit", nil, nil, false},
+	}
+	for _, test := range tests {
+		pkgs, ok := testPackages(test.name, test.opts)
+		if ok != test.ok || !reflect.DeepEqual(pkgs, test.pkgs) {
+			t.Errorf("%v %v: want %v %v, got %v %v", test.name, test.opts, test.pkgs, test.ok, pkgs, ok)
+		}
+	}
+}
+
+func TestDependsOnAny(t *testing.T) {
+	deps := []string{"fmt", "v.io/v23", "v.io/x/ref/lib/flags"}
+	if !dependsOnAny(deps, []string{"madb", "v.io/v23"}) {
+		t.Errorf("want dependency on v.io/v23")
+	}
+	if dependsOnAny(deps, []string{"madb", "v.io/x/ref"}) {
+		t.Errorf("want no dependency")
+	}
+}
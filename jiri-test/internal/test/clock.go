@@ -0,0 +1,180 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+
+	"v.io/jiri"
+	"v.io/jiri/tool"
+	"v.io/x/devtools/tooldata"
+)
+
+// libfaketimePaths lists the locations at which the libfaketime
+// library is looked up, in order.
+var libfaketimePaths = map[string][]string{
+	"darwin": []string{
+		"/usr/local/lib/faketime/libfaketime.1.dylib",
+		"/opt/local/lib/faketime/libfaketime.1.dylib",
+	},
+	"linux": []string{
+		"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+		"/usr/lib/faketime/libfaketime.so.1",
+		"/usr/local/lib/faketime/libfaketime.so.1",
+	},
+}
+
+// clockSchema is the JSON representation of a clock setting listed in
+// the clock settings file referenced by the tools config.
+type clockSchema struct {
+	// Pkg is a regular expression that identifies the packages whose
+	// tests run under this setting.
+	Pkg string
+	// TZ identifies the time zone the tests run in, e.g.
+	// "America/Los_Angeles". An empty value leaves the time zone
+	// unchanged.
+	TZ string
+	// Locale identifies the locale the tests run in, e.g.
+	// "en_US.UTF-8". An empty value leaves the locale unchanged.
+	Locale string
+	// FakeTime is a libfaketime time specification, either an absolute
+	// start time such as "@2016-03-13 01:59:30" or an offset such as
+	// "+2h". An empty value leaves the clock unchanged.
+	//
+	// libfaketime is preloaded into the test binaries and only affects
+	// time lookups that go through the C library. The Go runtime reads
+	// the clock with direct system calls or the vDSO, so the clock of
+	// Go code, including time.Now, is NOT faked, and the setting has
+	// no effect on test binaries that do not use cgo at all. Tests
+	// that need a fake clock in Go code must read the FAKETIME
+	// environment variable, which is set regardless, and adjust their
+	// own clock accordingly.
+	FakeTime string
+}
+
+// clockSetting represents the clock and time zone control applied to
+// the tests of the packages matched by pkgRE.
+type clockSetting struct {
+	pkgRE                *regexp.Regexp
+	tz, locale, fakeTime string
+}
+
+// env returns the environment variables that implement the setting.
+// See clockSchema for the limits of the fake clock.
+func (c clockSetting) env() (map[string]string, error) {
+	env := map[string]string{}
+	if c.tz != "" {
+		env["TZ"] = c.tz
+	}
+	if c.locale != "" {
+		env["LANG"] = c.locale
+		env["LC_ALL"] = c.locale
+	}
+	if c.fakeTime != "" {
+		lib, err := findLibfaketime()
+		if err != nil {
+			return nil, err
+		}
+		env["FAKETIME"] = c.fakeTime
+		switch runtime.GOOS {
+		case "darwin":
+			env["DYLD_INSERT_LIBRARIES"] = lib
+			env["DYLD_FORCE_FLAT_NAMESPACE"] = "1"
+		default:
+			env["LD_PRELOAD"] = lib
+		}
+	}
+	return env, nil
+}
+
+// findLibfaketime returns the path to the libfaketime library.
+func findLibfaketime() (string, error) {
+	for _, path := range libfaketimePaths[runtime.GOOS] {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("libfaketime not found in %v", libfaketimePaths[runtime.GOOS])
+}
+
+// matchClockSetting returns the first of the given clock settings that
+// applies to the given package, or nil if there is no such setting.
+func matchClockSetting(clocks []clockSetting, pkg string) *clockSetting {
+	for i, c := range clocks {
+		if c.pkgRE.MatchString(pkg) {
+			return &clocks[i]
+		}
+	}
+	return nil
+}
+
+// loadClockSettings returns the clock settings loaded from the file
+// referenced by the tools config (if any).
+func loadClockSettings(jirix *jiri.X) ([]clockSetting, error) {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return nil, err
+	}
+	path := config.GoTestClockFile()
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		dataDir, err := tooldata.DataDirPath(jirix, tool.Name)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dataDir, path)
+	}
+	bytes, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	clocks, err := parseClockSettings(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return clocks, nil
+}
+
+// parseClockSettings parses the given JSON-encoded list of clock
+// settings.
+func parseClockSettings(bytes []byte) ([]clockSetting, error) {
+	var schemas []clockSchema
+	if err := json.Unmarshal(bytes, &schemas); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	result := []clockSetting{}
+	for _, schema := range schemas {
+		pkgRE, err := regexp.Compile(schema.Pkg)
+		if err != nil {
+			return nil, fmt.Errorf("Compile(%v) failed: %v", schema.Pkg, err)
+		}
+		result = append(result, clockSetting{
+			pkgRE:    pkgRE,
+			tz:       schema.TZ,
+			locale:   schema.Locale,
+			fakeTime: schema.FakeTime,
+		})
+	}
+	return result, nil
+}
+
+// formatEnv returns the given environment variables as a sorted list
+// of <key>=<value> strings.
+func formatEnv(env map[string]string) []string {
+	result := []string{}
+	for key, value := range env {
+		result = append(result, key+"="+value)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseClockSettings(t *testing.T) {
+	clocks, err := parseClockSettings([]byte(`[
+  {"Pkg": "v.io/x/ref/lib/timekeeper", "TZ": "America/Los_Angeles", "FakeTime": "@2016-03-13 01:59:30"},
+  {"Pkg": "v.io/x/ref/.*", "Locale": "en_US.UTF-8"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(clocks), 2; got != want {
+		t.Fatalf("got %d clock settings, want %d", got, want)
+	}
+	tests := []struct {
+		pkg  string
+		want *clockSetting
+	}{
+		{"v.io/x/ref/lib/timekeeper", &clocks[0]},
+		{"v.io/x/ref/services/device", &clocks[1]},
+		{"v.io/x/lib/cmdline", nil},
+	}
+	for _, test := range tests {
+		if got := matchClockSetting(clocks, test.pkg); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.pkg, got, test.want)
+		}
+	}
+	env, err := clocks[1].env()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := env, map[string]string{"LANG": "en_US.UTF-8", "LC_ALL": "en_US.UTF-8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClockSettingFakeTime(t *testing.T) {
+	lib, err := ioutil.TempFile("", "libfaketime")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	lib.Close()
+	defer os.Remove(lib.Name())
+	oldPaths := libfaketimePaths[runtime.GOOS]
+	libfaketimePaths[runtime.GOOS] = []string{lib.Name()}
+	defer func() { libfaketimePaths[runtime.GOOS] = oldPaths }()
+
+	clock := clockSetting{tz: "UTC", fakeTime: "+2h"}
+	env, err := clock.env()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := env["TZ"], "UTC"; got != want {
+		t.Errorf("got TZ %q, want %q", got, want)
+	}
+	if got, want := env["FAKETIME"], "+2h"; got != want {
+		t.Errorf("got FAKETIME %q, want %q", got, want)
+	}
+	preload := env["LD_PRELOAD"]
+	if runtime.GOOS == "darwin" {
+		preload = env["DYLD_INSERT_LIBRARIES"]
+	}
+	if got, want := preload, lib.Name(); got != want {
+		t.Errorf("got preloaded library %q, want %q", got, want)
+	}
+
+	libfaketimePaths[runtime.GOOS] = nil
+	if _, err := clock.env(); err == nil {
+		t.Errorf("env() did not fail when libfaketime is missing")
+	}
+}
+
+func TestParseClockSettingsErrors(t *testing.T) {
+	for _, data := range []string{
+		`[{"Pkg": "("}]`,
+		`{}`,
+	} {
+		if _, err := parseClockSettings([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
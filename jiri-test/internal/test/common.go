@@ -13,7 +13,10 @@ import (
 	"strings"
 
 	"v.io/jiri"
+	"v.io/jiri/profiles"
 	"v.io/jiri/runutil"
+	"v.io/x/devtools/internal/golib"
+	"v.io/x/devtools/internal/xunit"
 )
 
 var (
@@ -32,6 +35,9 @@ var (
 
 	// Regexp to match common test result files.
 	reTestResult = regexp.MustCompile(`^((tests_.*\.xml)|(status_.*\.json))$`)
+
+	// Regexp to match xUnit report files.
+	reXUnitReport = regexp.MustCompile(`^tests_.*\.xml$`)
 )
 
 // internalTestError represents an internal test error.
@@ -62,12 +68,14 @@ type rootDirOpt string
 func (rootDirOpt) initTestOpt() {}
 
 // binDirPath returns the path to the directory for storing temporary
-// binaries.
-func binDirPath() string {
+// binaries. Binaries built with the given go tool flags, such as -race or
+// -tags, are stored in the subdirectory for their build variant, so that
+// they do not collide with regular binaries.
+func binDirPath(goFlags ...string) string {
 	if len(testTmpDir) == 0 {
 		panic("binDirPath() shouldn't be called before initTest()")
 	}
-	return filepath.Join(testTmpDir, "bin")
+	return filepath.Join(testTmpDir, "bin", golib.BuildVariant(goFlags))
 }
 
 // regTestBinDirPath returns the path to the directory for storing
@@ -126,6 +134,17 @@ func initTestImpl(jirix *jiri.X, needCleanup, updateProfiles, printProfiles bool
 		return nil, err
 	}
 
+	// Resolve the profiles the test requires on the target platform.
+	profileTarget := testTarget
+	if len(target) > 0 {
+		if profileTarget, err = profiles.NewTarget(target, ""); err != nil {
+			return nil, err
+		}
+	}
+	if profileNames, err = platformProfiles(testName, profileNames, profileTarget); err != nil {
+		return nil, err
+	}
+
 	if updateProfiles {
 		insertTarget := func(profile string) []string {
 			if len(target) > 0 {
@@ -231,8 +250,14 @@ func findTestResultFiles(jirix *jiri.X, testName string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	// With unique report names, the xUnit reports of other invocations
+	// are kept.
+	ownReport := filepath.Base(xunit.ReportPath(testName))
 	for _, fileInfo := range fileInfoList {
 		fileName := fileInfo.Name()
+		if xunit.ReportSuffix() != "" && reXUnitReport.MatchString(fileName) && fileName != ownReport {
+			continue
+		}
 		if reTestResult.MatchString(fileName) {
 			result = append(result, filepath.Join(workspaceDir, fileName))
 		}
@@ -0,0 +1,185 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+)
+
+// escapeReportOpt is an option that makes goBuild build the packages
+// with escape analysis diagnostics enabled and write a report of the
+// diagnostics to the given path.
+type escapeReportOpt string
+
+func (escapeReportOpt) goBuildOpt() {}
+
+var (
+	// escapeDiagnosticRE matches the diagnostics printed by the
+	// compiler when building with -gcflags=-m. Older compilers omit
+	// the column.
+	escapeDiagnosticRE = regexp.MustCompile(`^([^:\s][^:]*):(\d+)(?::(\d+))?: (.+)$`)
+	// goPackageLineRE matches the import paths printed by "go build -v".
+	goPackageLineRE = regexp.MustCompile(`^[\w.\-/]+$`)
+)
+
+// escapeDiagnostic is a diagnostic printed by the compiler when
+// building with -gcflags=-m.
+type escapeDiagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// escapePackageReport collects the escape analysis diagnostics of a
+// package.
+type escapePackageReport struct {
+	Package     string             `json:"package"`
+	Counts      map[string]int     `json:"counts"`
+	Diagnostics []escapeDiagnostic `json:"diagnostics"`
+}
+
+// escapeReport is the escape analysis report of a build.
+type escapeReport struct {
+	Counts   map[string]int        `json:"counts"`
+	Packages []escapePackageReport `json:"packages"`
+}
+
+// escapeKind classifies the given compiler diagnostic.
+func escapeKind(message string) string {
+	switch {
+	case strings.Contains(message, "does not escape"):
+		return "no-escape"
+	case strings.Contains(message, "escapes to heap"):
+		return "escape"
+	case strings.HasPrefix(message, "moved to heap"):
+		return "moved-to-heap"
+	case strings.HasPrefix(message, "leaking param"):
+		return "leaking-param"
+	case strings.HasPrefix(message, "can inline"), strings.HasPrefix(message, "inlining call"):
+		return "inline"
+	}
+	return "other"
+}
+
+// parseEscapeAnalysis adds the diagnostics in the given output of a
+// "go build -v -gcflags=-m" invocation for the given package
+// expression to the given report. Diagnostics are attributed to the
+// package named by the preceding "# <package>" line. Lines that are
+// neither diagnostics nor printed by "go build -v" result in an error.
+func parseEscapeAnalysis(pkg, output string, report *escapeReport) error {
+	if report.Counts == nil {
+		report.Counts = map[string]int{}
+	}
+	index := map[string]int{}
+	for i, p := range report.Packages {
+		index[p.Package] = i
+	}
+	curPkg := pkg
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.TrimSpace(line) == "", strings.HasPrefix(line, "link: warning"):
+			continue
+		case strings.HasPrefix(line, "# "):
+			curPkg = line[2:]
+			continue
+		case goPackageLineRE.MatchString(line):
+			continue
+		}
+		m := escapeDiagnosticRE.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("unexpected line in the output for %v: %q", pkg, line)
+		}
+		d := escapeDiagnostic{File: m[1], Kind: escapeKind(m[4]), Message: m[4]}
+		d.Line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			d.Column, _ = strconv.Atoi(m[3])
+		}
+		i, ok := index[curPkg]
+		if !ok {
+			i = len(report.Packages)
+			index[curPkg] = i
+			report.Packages = append(report.Packages, escapePackageReport{Package: curPkg, Counts: map[string]int{}})
+		}
+		report.Packages[i].Diagnostics = append(report.Packages[i].Diagnostics, d)
+		report.Packages[i].Counts[d.Kind]++
+		report.Counts[d.Kind]++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Scan() failed: %v", err)
+	}
+	return nil
+}
+
+// writeEscapeReport writes the given escape analysis report to the
+// given path in the JSON format, sorting the packages by name.
+func writeEscapeReport(jirix *jiri.X, path string, report *escapeReport) error {
+	sort.Sort(escapePackageReports(report.Packages))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent() failed: %v", err)
+	}
+	return jirix.NewSeq().MkdirAll(filepath.Dir(path), os.FileMode(0755)).WriteFile(path, data, os.FileMode(0644)).Done()
+}
+
+type escapePackageReports []escapePackageReport
+
+func (r escapePackageReports) Len() int           { return len(r) }
+func (r escapePackageReports) Less(i, j int) bool { return r[i].Package < r[j].Package }
+func (r escapePackageReports) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// escapeReportPath returns the path to the escape analysis report.
+func escapeReportPath(testName string) string {
+	workspace, fileName := os.Getenv("WORKSPACE"), "escape_analysis_report.json"
+	if workspace == "" {
+		return filepath.Join(os.Getenv("HOME"), "tmp", testName, fileName)
+	} else {
+		return filepath.Join(workspace, fileName)
+	}
+}
+
+// vanadiumGoEscapeAnalysis builds the vanadium Go packages with the
+// escape analysis diagnostics of the compiler enabled and archives a
+// report of the diagnostics. The diagnostics themselves never fail the
+// test.
+func vanadiumGoEscapeAnalysis(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result, e error) {
+	// Initialize the test.
+	cleanup, err := initTest(jirix, testName, []string{"v23:base"})
+	if err != nil {
+		return nil, newInternalError(err, "Init")
+	}
+	defer collect.Error(func() error { return cleanup() }, &e)
+
+	pkgs, err := validateAgainstDefaultPackages(jirix, opts, []string{"v.io/..."})
+	if err != nil {
+		return nil, err
+	}
+	return goBuild(jirix, testName, pkgs, escapeReportOpt(escapeReportPath(testName)))
+}
+
+// escapeReportFailure creates an xUnit report for a failure to parse
+// or write the escape analysis report.
+func escapeReportFailure(jirix *jiri.X, testName string, err error) (*test.Result, error) {
+	if err := xunit.CreateFailureReport(jirix, testName, "EscapeAnalysis", "Report", "failed to create the escape analysis report", err.Error()); err != nil {
+		return nil, err
+	}
+	return &test.Result{Status: test.Failed}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEscapeAnalysis(t *testing.T) {
+	output := `v.io/x/foo
+# v.io/x/foo
+foo/foo.go:10:6: can inline helper
+foo/foo.go:12:9: &x escapes to heap
+foo/foo.go:11:2: moved to heap: x
+foo/foo.go:15: leaking param: s
+# v.io/x/bar
+bar/bar.go:3:14: bar s does not escape
+v.io/x/bar
+link: warning: option -X main.x value; the new syntax is -X main.x=value
+`
+	report := &escapeReport{}
+	if err := parseEscapeAnalysis("v.io/x/...", output, report); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := &escapeReport{
+		Counts: map[string]int{"inline": 1, "escape": 1, "moved-to-heap": 1, "leaking-param": 1, "no-escape": 1},
+		Packages: []escapePackageReport{
+			{
+				Package: "v.io/x/foo",
+				Counts:  map[string]int{"inline": 1, "escape": 1, "moved-to-heap": 1, "leaking-param": 1},
+				Diagnostics: []escapeDiagnostic{
+					{File: "foo/foo.go", Line: 10, Column: 6, Kind: "inline", Message: "can inline helper"},
+					{File: "foo/foo.go", Line: 12, Column: 9, Kind: "escape", Message: "&x escapes to heap"},
+					{File: "foo/foo.go", Line: 11, Column: 2, Kind: "moved-to-heap", Message: "moved to heap: x"},
+					{File: "foo/foo.go", Line: 15, Kind: "leaking-param", Message: "leaking param: s"},
+				},
+			},
+			{
+				Package: "v.io/x/bar",
+				Counts:  map[string]int{"no-escape": 1},
+				Diagnostics: []escapeDiagnostic{
+					{File: "bar/bar.go", Line: 3, Column: 14, Kind: "no-escape", Message: "bar s does not escape"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("unexpected report: got %#v, want %#v", report, want)
+	}
+
+	// Diagnostics of a later build are added to the same report.
+	if err := parseEscapeAnalysis("v.io/x/bar", "# v.io/x/bar\nbar/baz.go:1:1: other thing\n", report); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(report.Packages), 2; got != want {
+		t.Fatalf("unexpected number of packages: got %v, want %v", got, want)
+	}
+	if got, want := report.Packages[1].Counts, map[string]int{"no-escape": 1, "other": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected counts: got %v, want %v", got, want)
+	}
+
+	// Unexpected output results in an error.
+	if err := parseEscapeAnalysis("v.io/x/foo", "# v.io/x/foo\nsomething went wrong\n", report); err == nil {
+		t.Fatalf("parsing unexpected output did not fail")
+	}
+}
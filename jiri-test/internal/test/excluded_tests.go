@@ -24,7 +24,9 @@ import (
 	"os"
 	"runtime"
 
+	"v.io/jiri"
 	"v.io/x/devtools/v23/internal/test"
+	"v.io/x/lib/cmdline"
 )
 
 var integrationFlag = flag.Bool("v23.tests", false, "Additionally display the tests excluded only when running integration tests.")
@@ -37,15 +39,29 @@ func main() {
 	fmt.Printf("GOROOT: %s\n", runtime.GOROOT())
 	fmt.Printf("USER: %q\n", os.Getenv("USER"))
 
+	jirix, err := jiri.NewX(cmdline.EnvFromOS())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
 	fmt.Println("Excluded tests:")
-	excluded := test.ExcludedTests()
+	excluded, err := test.ExcludedTests(jirix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 	for _, t := range excluded {
 		fmt.Printf("%#v\n", t)
 	}
 
 	if *raceFlag {
 		fmt.Println("Excluded race tests:")
-		raceExcluded := test.ExcludedRaceTests()
+		raceExcluded, err := test.ExcludedRaceTests(jirix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 		for _, t := range raceExcluded {
 			fmt.Printf("%#v\n", t)
 		}
@@ -53,7 +69,11 @@ func main() {
 
 	if *integrationFlag {
 		fmt.Println("Excluded integration tests:")
-		integrationExcluded := test.ExcludedIntegrationTests()
+		integrationExcluded, err := test.ExcludedIntegrationTests(jirix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 		for _, t := range integrationExcluded {
 			fmt.Printf("%#v\n", t)
 		}
@@ -0,0 +1,160 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/tool"
+	"v.io/x/devtools/tooldata"
+)
+
+// exclusionKind identifies the kind of Go test runs an exclusion
+// applies to.
+type exclusionKind string
+
+const (
+	goTestExclusionKind        exclusionKind = ""
+	goRaceExclusionKind        exclusionKind = "race"
+	goIntegrationExclusionKind exclusionKind = "integration"
+	goAsanExclusionKind        exclusionKind = "asan"
+	goMsanExclusionKind        exclusionKind = "msan"
+)
+
+// exclusionPredicates maps the names of the platform predicates that
+// can be used in the exclusions file to their implementation.
+var exclusionPredicates = map[string]func() bool{
+	"386":      is386,
+	"ci":       isCI,
+	"darwin":   isDarwin,
+	"yosemite": isYosemite,
+}
+
+// exclusionSchema is the JSON representation of an exclusion listed
+// in the exclusions file referenced by the tools config.
+type exclusionSchema struct {
+	// Pkg and Name are regular expressions that identify the excluded
+	// packages and tests respectively.
+	Pkg  string
+	Name string
+	// Predicates identifies the platform predicates that must all hold
+	// for the exclusion to take effect. A predicate can be negated by
+	// prefixing it with "!". An empty list means the exclusion always
+	// takes effect.
+	Predicates []string
+	// Kind identifies the kind of Go test runs the exclusion applies
+	// to: "race", "integration", "asan", "msan", or empty for regular
+	// Go tests.
+	Kind exclusionKind
+}
+
+// builtinExclusions returns the exclusions hard-coded in this package
+// for the given kind.
+func builtinExclusions(kind exclusionKind) []exclusion {
+	switch kind {
+	case goRaceExclusionKind:
+		return goRaceExclusions
+	case goIntegrationExclusionKind:
+		return goIntegrationExclusions
+	case goAsanExclusionKind, goMsanExclusionKind:
+		return nil
+	default:
+		return goExclusions
+	}
+}
+
+// loadExclusions returns the exclusions of the given kinds, combining
+// the built-in exclusions with the exclusions loaded from the file
+// referenced by the tools config (if any).
+func loadExclusions(jirix *jiri.X, kinds ...exclusionKind) ([]exclusion, error) {
+	result := []exclusion{}
+	for _, kind := range kinds {
+		result = append(result, builtinExclusions(kind)...)
+	}
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return nil, err
+	}
+	path := config.GoTestExclusionsFile()
+	if path == "" {
+		return result, nil
+	}
+	if !filepath.IsAbs(path) {
+		dataDir, err := tooldata.DataDirPath(jirix, tool.Name)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dataDir, path)
+	}
+	bytes, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	configured, err := parseExclusions(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	for _, kind := range kinds {
+		result = append(result, configured[kind]...)
+	}
+	return result, nil
+}
+
+// parseExclusions parses the given JSON-encoded list of exclusions and
+// returns the exclusions grouped by their kind.
+func parseExclusions(bytes []byte) (map[exclusionKind][]exclusion, error) {
+	var schemas []exclusionSchema
+	if err := json.Unmarshal(bytes, &schemas); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	result := map[exclusionKind][]exclusion{}
+	for _, schema := range schemas {
+		switch schema.Kind {
+		case goTestExclusionKind, goRaceExclusionKind, goIntegrationExclusionKind, goAsanExclusionKind, goMsanExclusionKind:
+		default:
+			return nil, fmt.Errorf("unknown exclusion kind %q", schema.Kind)
+		}
+		exclude, err := evalExclusionPredicates(schema.Predicates)
+		if err != nil {
+			return nil, err
+		}
+		pkgRE, err := regexp.Compile(schema.Pkg)
+		if err != nil {
+			return nil, fmt.Errorf("Compile(%v) failed: %v", schema.Pkg, err)
+		}
+		nameRE, err := regexp.Compile(schema.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Compile(%v) failed: %v", schema.Name, err)
+		}
+		result[schema.Kind] = append(result[schema.Kind], exclusion{
+			exclude: exclude,
+			nameRE:  nameRE,
+			pkgRE:   pkgRE,
+		})
+	}
+	return result, nil
+}
+
+// evalExclusionPredicates checks whether all of the given predicates
+// hold on this host.
+func evalExclusionPredicates(predicates []string) (bool, error) {
+	result := true
+	for _, predicate := range predicates {
+		name, negate := strings.TrimPrefix(predicate, "!"), strings.HasPrefix(predicate, "!")
+		fn, ok := exclusionPredicates[name]
+		if !ok {
+			return false, fmt.Errorf("unknown predicate %q", predicate)
+		}
+		if fn() == negate {
+			result = false
+		}
+	}
+	return result, nil
+}
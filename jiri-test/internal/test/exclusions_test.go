@@ -0,0 +1,74 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"testing"
+)
+
+func TestParseExclusions(t *testing.T) {
+	exclusionPredicates["always"] = func() bool { return true }
+	exclusionPredicates["never"] = func() bool { return false }
+	defer func() {
+		delete(exclusionPredicates, "always")
+		delete(exclusionPredicates, "never")
+	}()
+
+	exclusions, err := parseExclusions([]byte(`[
+  {"Pkg": "github.com/foo/bar", "Name": ".*"},
+  {"Pkg": "github.com/foo/baz", "Name": "TestA", "Predicates": ["always", "!never"]},
+  {"Pkg": "github.com/foo/baz", "Name": "TestB", "Predicates": ["always", "never"]},
+  {"Pkg": "github.com/foo/qux", "Name": "TestC", "Kind": "race"},
+  {"Pkg": "v.io/x/ref", "Name": "TestV23D", "Kind": "integration", "Predicates": ["!always"]},
+  {"Pkg": "v.io/x/ref/services/syncbase/.*", "Name": ".*", "Kind": "msan"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	type exclusionResult struct {
+		pkg, name string
+		exclude   bool
+	}
+	expected := map[exclusionKind][]exclusionResult{
+		goTestExclusionKind: []exclusionResult{
+			{"github.com/foo/bar", ".*", true},
+			{"github.com/foo/baz", "TestA", true},
+			{"github.com/foo/baz", "TestB", false},
+		},
+		goRaceExclusionKind: []exclusionResult{
+			{"github.com/foo/qux", "TestC", true},
+		},
+		goIntegrationExclusionKind: []exclusionResult{
+			{"v.io/x/ref", "TestV23D", false},
+		},
+		goMsanExclusionKind: []exclusionResult{
+			{"v.io/x/ref/services/syncbase/.*", ".*", true},
+		},
+	}
+	for kind, want := range expected {
+		got := exclusions[kind]
+		if len(got) != len(want) {
+			t.Fatalf("kind %q: got %d exclusions, want %d", kind, len(got), len(want))
+		}
+		for i, e := range got {
+			if e.pkgRE.String() != want[i].pkg || e.nameRE.String() != want[i].name || e.exclude != want[i].exclude {
+				t.Fatalf("kind %q: got {%v, %v, %v}, want %v", kind, e.pkgRE, e.nameRE, e.exclude, want[i])
+			}
+		}
+	}
+}
+
+func TestParseExclusionsErrors(t *testing.T) {
+	for _, data := range []string{
+		`[{"Pkg": "(", "Name": ".*"}]`,
+		`[{"Pkg": ".*", "Name": ".*", "Predicates": ["unknown"]}]`,
+		`[{"Pkg": ".*", "Name": ".*", "Kind": "unknown"}]`,
+		`{}`,
+	} {
+		if _, err := parseExclusions([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
@@ -41,6 +41,7 @@ import (
 	"v.io/x/devtools/internal/xunit"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/devtools/vbinary/exitcode"
+	"v.io/x/lib/envvar"
 	"v.io/x/lib/host"
 	"v.io/x/lib/set"
 )
@@ -53,6 +54,7 @@ const (
 	testPassed
 	testFailed
 	testTimedout
+	testLimitExceeded
 )
 
 const (
@@ -84,6 +86,8 @@ type goTestOpt interface {
 type funcMatcherOpt struct{ funcMatcher }
 
 type argsOpt []string
+type benchOutputsOpt map[string]string
+type clocksOpt []clockSetting
 type exclusionsOpt []exclusion
 type jiriGoOpt []string
 type nonTestArgsOpt []string
@@ -96,6 +100,8 @@ type timeoutOpt string
 func (argsOpt) goBuildOpt()              {}
 func (argsOpt) goCoverageOpt()           {}
 func (argsOpt) goTestOpt()               {}
+func (benchOutputsOpt) goTestOpt()       {}
+func (clocksOpt) goTestOpt()             {}
 func (exclusionsOpt) goTestOpt()         {}
 func (funcMatcherOpt) goTestOpt()        {}
 func (jiriGoOpt) Opt()                   {}
@@ -151,6 +157,7 @@ func optsFromGoTest(opts []goTestOpt) []Opt {
 // goBuild is a helper function for running Go builds.
 func goBuild(jirix *jiri.X, testName string, opts ...goBuildOpt) (_ *test.Result, e error) {
 	var buildArgs, pkgs, goFlags []string
+	escapePath := ""
 	for _, opt := range opts {
 		switch typedOpt := opt.(type) {
 		case argsOpt:
@@ -159,13 +166,19 @@ func goBuild(jirix *jiri.X, testName string, opts ...goBuildOpt) (_ *test.Result
 			pkgs = []string(typedOpt)
 		case jiriGoOpt:
 			goFlags = []string(typedOpt)
+		case escapeReportOpt:
+			escapePath = string(typedOpt)
 		}
 	}
+	if escapePath != "" {
+		buildArgs = append(buildArgs, "-gcflags=-m")
+	}
 
 	// For better performance, we don't call goutil.List to get all packages and
 	// distribute those packages to build workers. Instead, we use "go build"
 	// to build "top level" packages stored in "pkgs" which is much faster.
 	allPassed, suites := true, []xunit.TestSuite{}
+	report := &escapeReport{}
 	s := jirix.NewSeq()
 	for _, pkg := range pkgs {
 		// Build package.
@@ -181,6 +194,11 @@ func goBuild(jirix *jiri.X, testName string, opts ...goBuildOpt) (_ *test.Result
 		stdout := io.MultiWriter(&out, jirix.Stdout())
 		stderr := io.MultiWriter(&out, jirix.Stdout())
 		if err := s.Capture(stdout, stderr).Last("jiri", args...); err == nil {
+			if escapePath != "" {
+				if err := parseEscapeAnalysis(pkg, out.String(), report); err != nil {
+					return escapeReportFailure(jirix, testName, err)
+				}
+			}
 			continue
 		}
 
@@ -215,6 +233,11 @@ func goBuild(jirix *jiri.X, testName string, opts ...goBuildOpt) (_ *test.Result
 		}
 		return &test.Result{Status: test.Failed}, nil
 	}
+	if escapePath != "" {
+		if err := writeEscapeReport(jirix, escapePath, report); err != nil {
+			return escapeReportFailure(jirix, testName, err)
+		}
+	}
 	return &test.Result{Status: test.Passed}, nil
 }
 
@@ -300,8 +323,9 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 	numPkgs := len(pkgList)
 	tasks := make(chan string, numPkgs)
 	taskResults := make(chan coverageResult, numPkgs)
+	testJSON := supportsTestJSON(jirix)
 	for i := 0; i < runtime.NumCPU(); i++ {
-		go coverageWorker(jirix, timeout, args, tasks, taskResults)
+		go coverageWorker(jirix, timeout, args, testJSON, tasks, taskResults)
 	}
 
 	// Distribute work to workers.
@@ -388,7 +412,7 @@ func goCoverage(jirix *jiri.X, testName string, opts ...goCoverageOpt) (_ *test.
 }
 
 // coverageWorker generates test coverage.
-func coverageWorker(jirix *jiri.X, timeout string, args []string, pkgs <-chan string, results chan<- coverageResult) {
+func coverageWorker(jirix *jiri.X, timeout string, args []string, testJSON bool, pkgs <-chan string, results chan<- coverageResult) {
 	s := jirix.NewSeq()
 	for pkg := range pkgs {
 		// Compute the test coverage.
@@ -400,18 +424,25 @@ func coverageWorker(jirix *jiri.X, timeout string, args []string, pkgs <-chan st
 		args := append([]string{"go", "test", "-tags=leveldb", "-cover", "-coverprofile",
 			coverageFile.Name(), "-timeout", timeout, "-v",
 		}, args...)
+		if testJSON {
+			args = append(args, "-json")
+		}
 		args = append(args, pkg)
 		start := time.Now()
 		err = s.Capture(&out, &out).Verbose(false).Last("jiri", args...)
+		output, events := out.String(), []testEvent(nil)
+		if testJSON {
+			events, output = parseTestEvents(output)
+		}
 		result := coverageResult{
 			pkg:      pkg,
 			coverage: coverageFile,
 			time:     time.Now().Sub(start),
-			output:   out.String(),
+			output:   output,
 		}
 		if err != nil {
 			oe := runutil.GetOriginalError(err)
-			if isBuildFailure(oe, out.String(), pkg) {
+			if isBuildFailure(oe, output, pkg, events) {
 				result.status = buildFailed
 			} else {
 				result.status = testFailed
@@ -577,6 +608,15 @@ type testResult struct {
 	excluded []string
 	status   taskStatus
 	time     time.Duration
+	// attachments lists the files, such as core files and panic
+	// traces, to attach to the failures of the package.
+	attachments []string
+	// leaked lists the processes left running by the tests of the
+	// package.
+	leaked []leakedProcess
+	// limitExceeded identifies the resource limit exceeded by the
+	// tests of the package, if any.
+	limitExceeded string
 }
 
 const defaultTestTimeout = "20m"
@@ -606,22 +646,33 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	timeout := defaultTestTimeout
 	var args, pkgs, goFlags []string
 	var exclusions []exclusion
+	var clocks []clockSetting
+	var limits resourceLimitsOpt
 	var suffix string
 	var matcher funcMatcher
 	matcher = &matchGoTestFunc{testNameRE: goTestNameRE}
 	numWorkers := runtime.GOMAXPROCS(0)
 	var nonTestArgs nonTestArgsOpt
+	var benchOutputs benchOutputsOpt
 	suppressOutput := false
+	leakCheck := os.Getenv(leakCheckEnvVar) != ""
+	untilFailure, testFuncs := 0, ""
 	for _, opt := range opts {
 		switch typedOpt := opt.(type) {
 		case timeoutOpt:
 			timeout = string(typedOpt)
 		case argsOpt:
 			args = []string(typedOpt)
+		case benchOutputsOpt:
+			benchOutputs = typedOpt
 		case suffixOpt:
 			suffix = string(typedOpt)
 		case exclusionsOpt:
 			exclusions = []exclusion(typedOpt)
+		case clocksOpt:
+			clocks = []clockSetting(typedOpt)
+		case resourceLimitsOpt:
+			limits = typedOpt
 		case nonTestArgsOpt:
 			nonTestArgs = typedOpt
 		case funcMatcherOpt:
@@ -637,6 +688,20 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 			}
 		case jiriGoOpt:
 			goFlags = []string(typedOpt)
+		case untilFailureOpt:
+			untilFailure = int(typedOpt)
+		case testFuncsOpt:
+			testFuncs = string(typedOpt)
+		}
+	}
+	if untilFailure > 0 {
+		return goTestUntilFailure(jirix, testName, untilFailure, opts...)
+	}
+	var testFuncsRE *regexp.Regexp
+	if testFuncs != "" {
+		var err error
+		if testFuncsRE, err = regexp.Compile(testFuncs); err != nil {
+			return nil, nil, fmt.Errorf("Compile(%v) failed: %v", testFuncs, err)
 		}
 	}
 
@@ -670,8 +735,24 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 		failureSuite := xunit.CreateTestSuiteWithFailure("goListPackagesAndFuncs", originalTestName, "package pasing failure", err.Error(), 0)
 		return &test.Result{Status: test.Failed}, []xunit.TestSuite{*failureSuite}, nil
 	}
+	if testFuncsRE != nil {
+		pkgList, pkgAndFuncList = filterTestFuncs(pkgList, pkgAndFuncList, testFuncsRE)
+	}
+
+	// Set up the cache of test binaries.
+	var cache *testCache
+	if cacheableTestArgs(args) {
+		if cache, err = newTestCache(jirix); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "failed to set up the test binary cache: %v\n", err)
+		}
+	}
+
+	// Run "go test" with -json if the go tool supports it, so that
+	// build, setup and test failures are classified from its events.
+	testJSON := supportsTestJSON(jirix)
 
 	// Create a pool of workers.
+	attachmentsDir := xunit.AttachmentsDir(testName)
 	numPkgs := len(pkgList)
 	tasks := make(chan goTestTask, numPkgs)
 	taskResults := make(chan testResult, numPkgs)
@@ -684,19 +765,22 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 			fmt.Fprintf(jirix.Stdout(), "staggering start of test worker by %s\n", delay)
 		}
 		time.Sleep(delay)
-		testWorker(jirix, timeout, args, nonTestArgs, tasks, taskResults)
+		testWorker(jirix, timeout, attachmentsDir, args, nonTestArgs, clocks, limits, leakCheck, cache, testJSON, tasks, taskResults)
 	}
 	for i := 0; i < numWorkers; i++ {
 		if numWorkers > 1 {
 			go staggeredWorker()
 		} else {
-			go testWorker(jirix, timeout, args, nonTestArgs, tasks, taskResults)
+			go testWorker(jirix, timeout, attachmentsDir, args, nonTestArgs, clocks, limits, leakCheck, cache, testJSON, tasks, taskResults)
 		}
 	}
 
 	// Distribute work to workers.
 	for _, pkg := range pkgList {
 		testThisPkg, specificTests, excludedTests := filterExcludedTests(pkg, pkgAndFuncList[pkg], exclusions)
+		if testFuncsRE != nil && specificTests == nil {
+			specificTests = pkgAndFuncList[pkg]
+		}
 		if testThisPkg {
 			tasks <- goTestTask{pkg, specificTests, excludedTests}
 		} else {
@@ -726,18 +810,20 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 			ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", "build failure", result.output, result.time))
 		case testTimedout:
 			ss = append(ss, xunit.CreateTestSuiteWithFailure(result.pkg, "Test", fmt.Sprintf("test timed out after %s", timeout), "", result.time))
+		case testLimitExceeded:
+			suite := xunit.CreateTestSuiteWithFailure(result.pkg, "Test", fmt.Sprintf("test exceeded resource limit: %s", result.limitExceeded), result.output, result.time)
+			suite.Cases[0].Failures[0].Type = xunit.ResourceLimitFailure
+			ss = append(ss, suite)
 		case testFailed, testPassed:
 			if strings.Index(result.output, "no test files") == -1 &&
 				strings.Index(result.output, "package excluded") == -1 {
-				if testName == "vanadium-go-bench" {
-					// TODO(jsimsa): The go2xunit tool used for parsing output
-					// of Go tests ignores output of Go benchmarks. We dump
-					// output of benchmarks to stdout to persist this
-					// information in the console logs of our CI. This is a
-					// temporary solution until someone finds the enthusiasm to
-					// implement benchmark output parsing, tracking and
-					// graphing.
-					fmt.Fprintf(jirix.Stdout(), result.output)
+				if benchOutputs != nil {
+					// The go2xunit tool used for parsing output of Go tests
+					// ignores output of Go benchmarks. We record the output
+					// for the caller to parse and dump it to stdout to
+					// persist it in the console logs of our CI.
+					benchOutputs[result.pkg] = result.output
+					fmt.Fprintf(jirix.Stdout(), "%s", result.output)
 				}
 				// Escape test output to make sure go2xunit can process it.
 				var escapedOutput bytes.Buffer
@@ -781,9 +867,14 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 				excludedTests[result.pkg] = result.excluded
 			}
 		}
+		if len(result.leaked) > 0 {
+			test.Warn(jirix.Context, "%s left %d processes running\n", result.pkg, len(result.leaked))
+			ss = addLeakWarnings(ss, result.pkg, result.leaked)
+		}
 		for _, s := range ss {
 			if s.Failures > 0 {
 				allPassed = false
+				attachTestArtifacts(s, result)
 			}
 			// There are times, generally when running tests that fail from
 			// within tests that expect those failures, that we want to
@@ -793,6 +884,8 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 				if s.Failures > 0 {
 					if result.status == testTimedout {
 						test.Fail(jirix.Context, "[TIMED OUT after %s] %s\n", timeout, result.pkg)
+					} else if result.status == testLimitExceeded {
+						test.Fail(jirix.Context, "[RESOURCE LIMIT EXCEEDED: %s] %s\n%v\n", result.limitExceeded, result.pkg, result.output)
 					} else {
 						test.Fail(jirix.Context, "%s\n%v\n", result.pkg, result.output)
 					}
@@ -811,7 +904,8 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 				newCases = append(newCases, c)
 			}
 			s.Cases = newCases
-			suites = append(suites, *s)
+			// Group the cases of subtests and sub-benchmarks.
+			suites = append(suites, xunit.GroupSubtests(*s))
 		}
 		if excluded := excludedTests[result.pkg]; excluded != nil && !suppressOutput {
 			test.Pass(jirix.Context, "%s (excluded tests: %v)\n", result.pkg, excluded)
@@ -836,10 +930,24 @@ func goTest(jirix *jiri.X, testName string, opts ...goTestOpt) (_ *test.Result,
 	return testResult, suites, nil
 }
 
-// testWorker tests packages.
-func testWorker(jirix *jiri.X, timeout string, args, nonTestArgs []string, tasks <-chan goTestTask, results chan<- testResult) {
-	s := jirix.NewSeq()
+// testWorker tests packages. The tests of packages matched by one of
+// the given clock settings run under the time zone, locale and clock
+// identified by the setting; the fake clock only affects the C library,
+// not the clock of Go code. Each package is tested with its own
+// temporary directory; the core files and goroutine dumps found there
+// after a failure, as well as the core files the tests leave in the
+// directory of the package, are copied to the given attachments
+// directory. If leakCheck is set, the processes left running by the
+// tests of each package are recorded in the results. If cache is not
+// nil, the test binaries are cached and the cached binaries of
+// unchanged packages are run directly instead of "go test". The tests
+// are run under the given resource limits, and failures caused by
+// exceeding the limits are reported as such. If testJSON is set, "go
+// test" is run with -json and the failures are classified from its
+// events, while the results record the plain text output.
+func testWorker(jirix *jiri.X, timeout, attachmentsDir string, args, nonTestArgs []string, clocks []clockSetting, limits resourceLimitsOpt, leakCheck bool, cache *testCache, testJSON bool, tasks <-chan goTestTask, results chan<- testResult) {
 	for task := range tasks {
+		s := jirix.NewSeq()
 		// Run the test.
 		//
 		// The "leveldb" tag is needed to compile the levelDB-based
@@ -881,25 +989,162 @@ func testWorker(jirix *jiri.X, timeout string, args, nonTestArgs []string, tasks
 			}
 			continue
 		}
-		err = s.Capture(&out, &out).Timeout(timeoutDuration+time.Minute).Verbose(false).Last("jiri", taskArgs...)
+		tmpDir, err := jirix.NewSeq().TempDir("", "")
+		if err != nil {
+			results <- testResult{
+				status:   testFailed,
+				pkg:      task.pkg,
+				output:   fmt.Sprintf("failed to create temporary directory for %s: %v", task.pkg, err),
+				excluded: task.excludedTests,
+			}
+			continue
+		}
+		env := map[string]string{"TMPDIR": tmpDir}
+		if clock := matchClockSetting(clocks, task.pkg); clock != nil {
+			clockEnv, err := clock.env()
+			if err != nil {
+				jirix.NewSeq().RemoveAll(tmpDir).Done()
+				results <- testResult{
+					status:   testFailed,
+					pkg:      task.pkg,
+					output:   fmt.Sprintf("failed to set up clock for %s: %v", task.pkg, err),
+					excluded: task.excludedTests,
+				}
+				continue
+			}
+			if jirix.Verbose() {
+				fmt.Fprintf(jirix.Stdout(), "testing %s with %v\n", task.pkg, formatEnv(clockEnv))
+			}
+			env = envvar.MergeMaps(env, clockEnv)
+		}
+		var checker *leakChecker
+		if leakCheck {
+			if checker, err = newLeakChecker(jirix, task.pkg); err != nil {
+				fmt.Fprintf(jirix.Stderr(), "failed to set up leak check for %s: %v\n", task.pkg, err)
+			} else {
+				env = envvar.MergeMaps(env, checker.env())
+			}
+		}
+		s = s.Env(envvar.MergeMaps(jirix.Env(), env))
+		// Run the test binary under the CPU time and memory limits, so
+		// that they do not apply to the go tool and the compiler.
+		limitScript := ""
+		if limits.enabled() {
+			if limitScript, err = limits.writeLimitScript(tmpDir); err != nil {
+				jirix.NewSeq().RemoveAll(tmpDir).Done()
+				results <- testResult{
+					status:   testFailed,
+					pkg:      task.pkg,
+					output:   fmt.Sprintf("failed to set up resource limits for %s: %v", task.pkg, err),
+					excluded: task.excludedTests,
+				}
+				continue
+			}
+		}
+		// Run the cached test binary of the package if the package did
+		// not change. Otherwise, have "go test" store the binary it
+		// builds in the cache.
+		cached, cachedBin, pkgDir := false, "", ""
+		if cache != nil {
+			buildFlags := append([]string{"-tags=leveldb"}, args...)
+			if cachedBin, pkgDir, err = cache.lookup(jirix, task.pkg, buildFlags); err != nil {
+				fmt.Fprintf(jirix.Stderr(), "failed to look up the cached test binary of %s: %v\n", task.pkg, err)
+				cachedBin = ""
+			} else if _, err := os.Stat(cachedBin); err == nil {
+				cached = true
+			}
+		}
+		if cached {
+			if jirix.Verbose() {
+				fmt.Fprintf(jirix.Stdout(), "running cached test binary of %s\n", task.pkg)
+			}
+			cmdline := append([]string{cachedBin}, cachedTestArgs(timeout, testsExpr, nonTestArgs)...)
+			if limitScript != "" {
+				cmdline = append([]string{limitScript}, cmdline...)
+			}
+			name, cmdArgs := limits.command(cmdline[0], cmdline[1:]...)
+			// Run the binary in the environment of the profiles, as
+			// "jiri go test" does.
+			s = s.Env(envvar.MergeMaps(jirix.Env(), cache.env, env))
+			err = s.Dir(pkgDir).Capture(&out, &out).Timeout(timeoutDuration+time.Minute).Verbose(false).Last(name, cmdArgs...)
+			out.WriteString(cachedTestSummary(task.pkg, err == nil, time.Now().Sub(start).Seconds()))
+		} else {
+			tmpBin := ""
+			if cachedBin != "" {
+				// Build the binary under a temporary name, so that
+				// concurrent runs never see a partially written binary.
+				tmpBin = fmt.Sprintf("%s.%d", cachedBin, rand.Int63())
+				taskArgs = append([]string{"go", "test", "-o", tmpBin}, taskArgs[2:]...)
+			}
+			if testJSON {
+				taskArgs = append([]string{"go", "test", "-json"}, taskArgs[2:]...)
+			}
+			if limitScript != "" {
+				taskArgs = append([]string{"go", "test", "-exec", limitScript}, taskArgs[2:]...)
+			}
+			name, cmdArgs := limits.command("jiri", taskArgs...)
+			err = s.Capture(&out, &out).Timeout(timeoutDuration+time.Minute).Verbose(false).Last(name, cmdArgs...)
+			if tmpBin != "" {
+				if _, statErr := os.Stat(tmpBin); statErr == nil {
+					if renameErr := os.Rename(tmpBin, cachedBin); renameErr != nil {
+						fmt.Fprintf(jirix.Stderr(), "Rename(%v, %v) failed: %v\n", tmpBin, cachedBin, renameErr)
+						os.Remove(tmpBin)
+					}
+				}
+			}
+		}
+		output, events := out.String(), []testEvent(nil)
+		if testJSON && !cached {
+			events, output = parseTestEvents(output)
+		}
 		result := testResult{
 			pkg:      task.pkg,
 			time:     time.Now().Sub(start),
-			output:   out.String(),
+			output:   output,
 			excluded: task.excludedTests,
 		}
 		if err != nil {
 			oe := runutil.GetOriginalError(err)
-			if isBuildFailure(oe, out.String(), task.pkg) {
+			if !cached && isBuildFailure(oe, output, task.pkg, events) {
 				result.status = buildFailed
 			} else if runutil.IsTimeout(err) {
 				result.status = testTimedout
+			} else if limit := limitExceeded(err, output, limitStatusFile(tmpDir)); limits.enabled() && limit != "" {
+				result.status = testLimitExceeded
+				result.limitExceeded = limit
 			} else {
 				result.status = testFailed
 			}
 		} else {
 			result.status = testPassed
 		}
+		if result.status == testFailed || result.status == testTimedout || result.status == testLimitExceeded {
+			// The tests run in the directory of their package, which
+			// is where the kernel writes their core files.
+			if pkgDir == "" {
+				if dirs, err := goutil.ListDirs(jirix, nil, task.pkg); err == nil && len(dirs) == 1 {
+					pkgDir = dirs[0]
+				}
+			}
+			if pkgDir != "" {
+				if err := moveCoreFiles(jirix, pkgDir, tmpDir); err != nil {
+					fmt.Fprintf(jirix.Stderr(), "failed to collect the core files of %s: %v\n", task.pkg, err)
+				}
+			}
+			attachments, err := collectTestArtifacts(jirix, tmpDir, attachmentsDir, task.pkg, result.output)
+			if err != nil {
+				fmt.Fprintf(jirix.Stderr(), "failed to collect test artifacts for %s: %v\n", task.pkg, err)
+			}
+			result.attachments = attachments
+		}
+		if checker != nil {
+			if result.leaked, err = checker.check(jirix); err != nil {
+				fmt.Fprintf(jirix.Stderr(), "failed to check for processes left running by %s: %v\n", task.pkg, err)
+			}
+		}
+		if err := jirix.NewSeq().RemoveAll(tmpDir).Done(); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
 		results <- result
 	}
 }
@@ -923,7 +1168,13 @@ func buildTestDeps(jirix *jiri.X, pkgs []string, jiriGoFlags []string) error {
 }
 
 // isBuildFailure checks whether the given error and output indicate a build failure for the given package.
-func isBuildFailure(err error, out, pkg string) bool {
+// Setup failures are treated as build failures. If the given events of "go test -json" include the final
+// action of the package, the failure is classified from them. Otherwise, the exit code and the output are
+// checked, which is also how the failures of older versions of Go, which do not support -json, are classified.
+func isBuildFailure(err error, out, pkg string, events []testEvent) bool {
+	if failure, ok := classifyTestEvents(events, pkg); ok {
+		return failure == buildFailure || failure == setupFailure
+	}
 	if exitError, ok := err.(*exec.ExitError); ok {
 		// Try checking err's process state to determine the exit code.
 		// Exit code 2 means build failures.
@@ -954,29 +1205,19 @@ func isBuildFailure(err error, out, pkg string) bool {
 // given port. If no process is listening on the given port (or an
 // error is encountered), the function returns -1.
 func getListenerPID(jirix *jiri.X, port string) (int, error) {
-	// Make sure "lsof" exists.
-	_, err := exec.LookPath("lsof")
+	listeners, err := lsofListeners(jirix, "-iTCP:"+port)
 	if err != nil {
-		return -1, fmt.Errorf(`"lsof" not found in the PATH`)
+		return -1, err
 	}
-
-	// Use "lsof" to find the process ID of the listener.
-	var out bytes.Buffer
-	if err := jirix.NewSeq().Capture(&out, &out).
-		Last("lsof", "-i", ":"+port, "-sTCP:LISTEN", "-F", "p"); err != nil {
-		// When no listener exists, "lsof" exits with non-zero
-		// status.
-		return -1, nil
+	pids := []int{}
+	for pid := range listeners {
+		pids = append(pids, pid)
 	}
-
-	// Parse the port number.
-	pidString := strings.TrimPrefix(strings.TrimSpace(out.String()), "p")
-	pid, err := strconv.Atoi(pidString)
-	if err != nil {
-		return -1, fmt.Errorf("Atoi(%v) failed: %v", pidString, err)
+	if len(pids) == 0 {
+		return -1, nil
 	}
-
-	return pid, nil
+	sort.Ints(pids)
+	return pids[0], nil
 }
 
 type exclusion struct {
@@ -1089,21 +1330,32 @@ func init() {
 }
 
 // ExcludedTests returns the set of tests to be excluded from the
-// tests executed when testing the Vanadium project.
-func ExcludedTests() []string {
-	return excludedTests(goExclusions)
+// tests executed when testing the Vanadium project, including those
+// listed in the exclusions file referenced by the tools config.
+func ExcludedTests(jirix *jiri.X) ([]string, error) {
+	return loadExcludedTests(jirix, goTestExclusionKind)
 }
 
 // ExcludedRaceTests returns the set of race tests to be excluded from
-// the tests executed when testing the Vanadium project.
-func ExcludedRaceTests() []string {
-	return excludedTests(goRaceExclusions)
+// the tests executed when testing the Vanadium project, including those
+// listed in the exclusions file referenced by the tools config.
+func ExcludedRaceTests(jirix *jiri.X) ([]string, error) {
+	return loadExcludedTests(jirix, goRaceExclusionKind)
 }
 
 // ExcludedIntegrationTests returns the set of integration tests to be excluded
-// from the tests executed when testing the Vanadium project.
-func ExcludedIntegrationTests() []string {
-	return excludedTests(goIntegrationExclusions)
+// from the tests executed when testing the Vanadium project, including those
+// listed in the exclusions file referenced by the tools config.
+func ExcludedIntegrationTests(jirix *jiri.X) ([]string, error) {
+	return loadExcludedTests(jirix, goIntegrationExclusionKind)
+}
+
+func loadExcludedTests(jirix *jiri.X, kind exclusionKind) ([]string, error) {
+	exclusions, err := loadExclusions(jirix, kind)
+	if err != nil {
+		return nil, err
+	}
+	return excludedTests(exclusions), nil
 }
 
 func excludedTests(exclusions []exclusion) []string {
@@ -1237,8 +1489,21 @@ func thirdPartyGoTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Resu
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, goTestExclusionKind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
 	suffix := suffixOpt(genTestNameSuffix("GoTest"))
-	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(goExclusions), validatedPkgs)
+	goOpts := []goTestOpt{suffix, exclusionsOpt(exclusions), clocksOpt(clocks), limits, validatedPkgs}
+	return goTestAndReport(jirix, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
 }
 
 // thirdPartyGoRace runs Go data-race tests for third-party projects.
@@ -1264,9 +1529,21 @@ func thirdPartyGoRace(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Resu
 		return nil, err
 	}
 	args := argsOpt([]string{"-race"})
-	exclusions := append(goExclusions, goRaceExclusions...)
+	exclusions, err := loadExclusions(jirix, goTestExclusionKind, goRaceExclusionKind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
 	suffix := suffixOpt(genTestNameSuffix("GoRace"))
-	return goTestAndReport(jirix, testName, suffix, args, timeoutOpt("1h"), exclusionsOpt(exclusions), partPkgs)
+	goOpts := []goTestOpt{suffix, args, timeoutOpt("1h"), exclusionsOpt(exclusions), clocksOpt(clocks), limits.withoutMemoryLimit(), partPkgs}
+	return goTestAndReport(jirix, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
 }
 
 // thirdPartyPkgs returns a list of Go expressions that describe all
@@ -1365,7 +1642,45 @@ func vanadiumGoBench(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Resul
 	args := argsOpt([]string{"-bench", "."})
 	matcher := funcMatcherOpt{&matchGoTestFunc{testNameRE: goBenchNameRE}}
 	timeout := timeoutOpt("1h")
-	return goTestAndReport(jirix, testName, args, matcher, timeout, pkgs)
+	outputs := benchOutputsOpt{}
+	result, suites, err := goTest(jirix, testName, args, matcher, timeout, outputs, pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the benchmark results.
+	benchPkgs := []string{}
+	for pkg := range outputs {
+		benchPkgs = append(benchPkgs, pkg)
+	}
+	sort.Strings(benchPkgs)
+	results := []benchResult{}
+	for _, pkg := range benchPkgs {
+		results = append(results, parseBenchmarks(pkg, outputs[pkg])...)
+	}
+	if err := storeBenchmarks(jirix, testName, results); err != nil {
+		return nil, newInternalError(err, "StoreBenchmarks")
+	}
+
+	// Compare the benchmark results against the baseline, if any.
+	if baseline := os.Getenv(benchBaselineEnvVar); baseline != "" {
+		threshold, err := benchThreshold()
+		if err != nil {
+			return nil, newInternalError(err, "BenchThreshold")
+		}
+		old, err := loadBenchmarks(jirix, baseline)
+		if err != nil {
+			return nil, newInternalError(err, "LoadBenchmarks")
+		}
+		if regressions := compareBenchmarks(old, results, threshold); len(regressions) > 0 {
+			for _, r := range regressions {
+				test.Fail(jirix.Context, "%s %s: %s changed by %+.2f%% (%v -> %v)\n", r.Pkg, r.Name, r.Unit, r.delta(), r.Old, r.New)
+			}
+			suites = append(suites, benchRegressionSuites(regressions)...)
+			result.Status = test.Failed
+		}
+	}
+	return result, xunit.CreateReport(jirix, testName, suites)
 }
 
 // vanadiumGoBuild runs Go build for the vanadium projects.
@@ -1621,11 +1936,32 @@ func vanadiumGoRace(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result
 	if err != nil {
 		return nil, err
 	}
-	exclusions := append(goExclusions, goRaceExclusions...)
+	exclusions, err := loadExclusions(jirix, goTestExclusionKind, goRaceExclusionKind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
 	args := argsOpt([]string{"-race"})
 	timeout := timeoutOpt("30m")
 	suffix := suffixOpt(genTestNameSuffix("GoRace"))
-	return goTestAndReport(jirix, testName, args, timeout, suffix, exclusionsOpt(exclusions), partPkgs)
+	// Binaries built by the tests are instrumented too, so keep them
+	// apart from the regular ones.
+	binDir := binDirPath(args...)
+	if err := jirix.NewSeq().MkdirAll(binDir, os.FileMode(0755)).Done(); err != nil {
+		return nil, err
+	}
+	env := jirix.Env()
+	env["V23_BIN_DIR"] = binDir
+	newCtx := jirix.Clone(tool.ContextOpts{Env: env})
+	goOpts := []goTestOpt{args, timeout, suffix, exclusionsOpt(exclusions), clocksOpt(clocks), limits.withoutMemoryLimit(), partPkgs}
+	return goTestAndReport(newCtx, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
 }
 
 // identifyPackagesToTest returns a slice of packages to test using the
@@ -1752,9 +2088,22 @@ func vanadiumGoTest(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, goTestExclusionKind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
 	args := argsOpt([]string{})
 	suffix := suffixOpt(genTestNameSuffix("GoTest"))
-	return goTestAndReport(jirix, testName, suffix, exclusionsOpt(goExclusions), getNumWorkersOpt(opts), pkgs, args)
+	goOpts := []goTestOpt{suffix, exclusionsOpt(exclusions), clocksOpt(clocks), limits, getNumWorkersOpt(opts), pkgs, args}
+	return goTestAndReport(jirix, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
 }
 
 // vanadiumIntegrationTest runs integration tests for Vanadium
@@ -1774,13 +2123,26 @@ func vanadiumIntegrationTest(jirix *jiri.X, testName string, opts ...Opt) (_ *te
 	if err != nil {
 		return nil, err
 	}
+	exclusions, err := loadExclusions(jirix, goIntegrationExclusionKind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
 	suffix := suffixOpt(genTestNameSuffix("V23Test"))
 	nonTestArgs := nonTestArgsOpt([]string{"-v23.tests"})
 	matcher := funcMatcherOpt{&matchV23TestFunc{testNameRE: integrationTestNameRE}}
 	env := jirix.Env()
 	env["V23_BIN_DIR"] = binDirPath()
 	newCtx := jirix.Clone(tool.ContextOpts{Env: env})
-	return goTestAndReport(newCtx, testName, suffix, getNumWorkersOpt(opts), nonTestArgs, matcher, exclusionsOpt(goIntegrationExclusions), pkgs)
+	goOpts := []goTestOpt{suffix, getNumWorkersOpt(opts), nonTestArgs, matcher, exclusionsOpt(exclusions), clocksOpt(clocks), limits, pkgs}
+	return goTestAndReport(newCtx, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
 }
 
 // binOrder determines if the regression tests use
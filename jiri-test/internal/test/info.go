@@ -0,0 +1,165 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/tooldata"
+)
+
+// TestInfo describes the settings that a test runs with by default.
+type TestInfo struct {
+	// Profiles identifies the profiles the test requires on all
+	// platforms.
+	Profiles []string
+	// PlatformProfiles identifies the profiles the test requires on
+	// some platforms only, or cannot use on some platforms.
+	PlatformProfiles []PlatformProfile
+	// DefaultPackages identifies the Go packages the test runs against
+	// unless other packages are given, if any.
+	DefaultPackages []string
+	// Timeout is the timeout of the test, or of each package of Go
+	// tests, or zero if the test has no timeout of its own.
+	Timeout time.Duration
+}
+
+var (
+	baseProfiles   = []string{"v23:base"}
+	javaProfiles   = []string{"v23:java"}
+	mojoProfiles   = []string{"v23:base", "v23:mojo", "v23:dart"}
+	nodejsProfiles = []string{"v23:base", "v23:nodejs"}
+)
+
+// builtinTestInfo describes the profiles, default packages and timeouts
+// of the tests built into this package, as they pass them to initTest
+// and run their commands with. Tests that are not listed require no
+// profiles and have no timeout of their own. The default packages of
+// the Go tests listed in goTestPackages are taken from there.
+var builtinTestInfo = map[string]TestInfo{
+	"baku-android-build":                              {Profiles: javaProfiles},
+	"baku-java-test":                                  {Profiles: javaProfiles},
+	"madb-go-format":                                  {Profiles: baseProfiles, DefaultPackages: []string{"madb/..."}},
+	"madb-go-generate":                                {Profiles: baseProfiles, DefaultPackages: []string{"madb/..."}},
+	"madb-go-test":                                    {Profiles: baseProfiles, Timeout: 20 * time.Minute},
+	"third_party-go-build":                            {Profiles: baseProfiles},
+	"third_party-go-race":                             {Profiles: baseProfiles, Timeout: time.Hour},
+	"third_party-go-test":                             {Profiles: baseProfiles, Timeout: 20 * time.Minute},
+	"vanadium-android-build":                          {Profiles: javaProfiles},
+	"vanadium-baku-test":                              {Profiles: baseProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-browser-test":                           {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-browser-test-web":                       {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-chat-shell-test":                        {Profiles: baseProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-chat-web-test":                          {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-chat-web-ui-test":                       {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-croupier-unit":                          {Profiles: []string{"v23:base", "v23:dart"}, Timeout: defaultProjectTestTimeout},
+	"vanadium-croupier-unit-go":                       {Profiles: baseProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-diceroller-android-test":                {Profiles: javaProfiles},
+	"vanadium-go-asan":                                {Profiles: baseProfiles, Timeout: 30 * time.Minute},
+	"vanadium-go-bench":                               {Profiles: baseProfiles, Timeout: time.Hour},
+	"vanadium-go-binaries":                            {Profiles: baseProfiles},
+	"vanadium-go-build":                               {Profiles: baseProfiles},
+	"vanadium-go-cover":                               {Profiles: baseProfiles, Timeout: 5 * time.Minute},
+	"vanadium-go-depcop":                              {Profiles: baseProfiles},
+	"vanadium-go-escape-analysis":                     {Profiles: baseProfiles},
+	"vanadium-go-format":                              {Profiles: baseProfiles, DefaultPackages: []string{"v.io/..."}},
+	"vanadium-go-generate":                            {Profiles: baseProfiles, DefaultPackages: []string{"v.io/..."}},
+	"vanadium-go-msan":                                {Profiles: baseProfiles, Timeout: 30 * time.Minute},
+	"vanadium-go-race":                                {Profiles: baseProfiles, Timeout: 30 * time.Minute},
+	"vanadium-go-rpc-load":                            {Profiles: baseProfiles},
+	"vanadium-go-rpc-stress":                          {Profiles: baseProfiles},
+	"vanadium-go-test":                                {Profiles: baseProfiles, Timeout: 20 * time.Minute},
+	"vanadium-go-vdl":                                 {Profiles: baseProfiles},
+	"vanadium-go-vet":                                 {Profiles: baseProfiles},
+	"vanadium-integration-test":                       {Profiles: baseProfiles, Timeout: 20 * time.Minute},
+	"vanadium-java-syncbase-test":                     {Profiles: javaProfiles},
+	"vanadium-java-test":                              {Profiles: javaProfiles},
+	"vanadium-js-browser-integration":                 {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-build-extension":                     {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-doc":                                 {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-doc-deploy":                          {Profiles: []string{"v23:nodejs"}, Timeout: defaultJSTestTimeout},
+	"vanadium-js-doc-syncbase":                        {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-doc-syncbase-deploy":                 {Profiles: []string{"v23:nodejs"}, Timeout: defaultJSTestTimeout},
+	"vanadium-js-node-integration":                    {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-syncbase-browser":                    {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-syncbase-node":                       {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-unit":                                {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-vdl":                                 {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-vdl-audit":                           {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-js-vom":                                 {Profiles: nodejsProfiles, Timeout: defaultJSTestTimeout},
+	"vanadium-mojo-discovery-test":                    {Profiles: mojoProfiles, Timeout: defaultMojoTestTimeout},
+	"vanadium-mojo-syncbase-test":                     {Profiles: mojoProfiles, Timeout: defaultMojoTestTimeout},
+	"vanadium-mojo-v23proxy-go-only-integration-test": {Profiles: []string{"v23:base", "v23:mojo"}, Timeout: defaultMojoTestTimeout},
+	"vanadium-mojo-v23proxy-integration-test":         {Profiles: mojoProfiles, Timeout: defaultMojoTestTimeout},
+	"vanadium-mojo-v23proxy-unit-test":                {Profiles: mojoProfiles, Timeout: defaultMojoTestTimeout},
+	"vanadium-moments-test":                           {Profiles: javaProfiles},
+	"vanadium-pipe2browser-test":                      {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-playground-test":                        {Profiles: nodejsProfiles, Timeout: defaultPlaygroundTestTimeout},
+	"vanadium-postsubmit-poll":                        {Profiles: baseProfiles},
+	"vanadium-prod-services-test":                     {Profiles: baseProfiles},
+	"vanadium-reader-test":                            {Profiles: baseProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-regression-test":                        {Profiles: baseProfiles},
+	"vanadium-release-candidate":                      {Profiles: baseProfiles},
+	"vanadium-release-kube-production":                {Profiles: baseProfiles},
+	"vanadium-release-kube-staging":                   {Profiles: baseProfiles},
+	"vanadium-release-production":                     {Profiles: baseProfiles},
+	"vanadium-signup-discuss-new":                     {Profiles: baseProfiles},
+	"vanadium-signup-github":                          {Profiles: baseProfiles},
+	"vanadium-signup-github-new":                      {Profiles: baseProfiles},
+	"vanadium-signup-group":                           {Profiles: baseProfiles},
+	"vanadium-signup-group-new":                       {Profiles: baseProfiles},
+	"vanadium-signup-proxy":                           {Profiles: baseProfiles},
+	"vanadium-signup-proxy-new":                       {Profiles: baseProfiles},
+	"vanadium-signup-welcome-1-new":                   {Profiles: baseProfiles},
+	"vanadium-signup-welcome-2-new":                   {Profiles: baseProfiles},
+	"vanadium-todos-android-test":                     {Profiles: javaProfiles},
+	"vanadium-travel-test":                            {Profiles: nodejsProfiles, Timeout: defaultProjectTestTimeout},
+	"vanadium-vkube-integration-test":                 {Profiles: baseProfiles},
+	"vanadium-website-deploy":                         {Profiles: nodejsProfiles, Timeout: defaultWebsiteTestTimeout},
+	"vanadium-website-site":                           {Profiles: nodejsProfiles, Timeout: defaultWebsiteTestTimeout},
+	"vanadium-website-tutorials-core":                 {Profiles: nodejsProfiles, Timeout: defaultWebsiteTestTimeout},
+	"vanadium-website-tutorials-external":             {Profiles: nodejsProfiles, Timeout: 60 * time.Minute},
+	"vanadium-website-tutorials-java":                 {Profiles: []string{"v23:base", "v23:nodejs", "java"}, Timeout: defaultWebsiteTestTimeout},
+	"vanadium-website-tutorials-syncbase-android":     {Profiles: nodejsProfiles, Timeout: defaultWebsiteTestTimeout},
+}
+
+// LookupTestInfo returns the settings of the given test. The settings
+// of tests implemented by plugins come from their specs, and those of
+// the tests built into this package from builtinTestInfo. In both
+// cases, the profiles and timeout are extended by the make test
+// settings of the given config.
+func LookupTestInfo(config *tooldata.Config, name string) (TestInfo, error) {
+	info := TestInfo{}
+	if spec, ok := testSpecs[name]; ok {
+		info.Profiles = append(info.Profiles, spec.Profiles...)
+		info.PlatformProfiles = append(info.PlatformProfiles, spec.PlatformProfiles...)
+		info.Timeout = test.DefaultTimeout
+	} else if builtin, ok := builtinTestInfo[name]; ok {
+		for _, profile := range builtin.Profiles {
+			if p, ok := builtinPlatformProfiles[profile]; ok {
+				info.PlatformProfiles = append(info.PlatformProfiles, p)
+			} else {
+				info.Profiles = append(info.Profiles, profile)
+			}
+		}
+		info.DefaultPackages = builtin.DefaultPackages
+		info.Timeout = builtin.Timeout
+	}
+	if pkgs, ok := testPackages(name, nil); ok && info.DefaultPackages == nil {
+		info.DefaultPackages = pkgs
+	}
+	settings := config.MakeTests()[name]
+	info.Profiles = append(info.Profiles, settings.Profiles...)
+	if settings.Timeout != "" {
+		timeout, err := time.ParseDuration(settings.Timeout)
+		if err != nil {
+			return TestInfo{}, fmt.Errorf("invalid timeout of test %q: %v", name, err)
+		}
+		info.Timeout = timeout
+	}
+	return info, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/xunit"
+)
+
+const (
+	// leakCheckEnvVar, if set to a non-empty value, enables checking
+	// for processes, such as servers and daemons, that are left running
+	// after the tests of a package finish.
+	leakCheckEnvVar = "V23_LEAK_CHECK"
+	// leakTagEnvVar is set to a value unique to each package in the
+	// environment of its tests. Processes started by the tests inherit
+	// it, which identifies them after the tests finish even when the
+	// tests of several packages run concurrently.
+	leakTagEnvVar = "V23_LEAK_CHECK_TAG"
+	// leakCheckCaseName is the name of the xUnit test case that records
+	// the processes left running by the tests of a package.
+	leakCheckCaseName = "LeakCheck"
+)
+
+// procDir is the directory that holds the information about running
+// processes, if the platform provides it.
+var procDir = "/proc"
+
+// leakedProcess identifies a process left running by the tests of a
+// package.
+type leakedProcess struct {
+	pid     int
+	command string
+	// ports lists the addresses the process is listening on.
+	ports []string
+}
+
+func (p leakedProcess) String() string {
+	s := fmt.Sprintf("pid %d", p.pid)
+	if p.command != "" {
+		s += fmt.Sprintf(" (%s)", p.command)
+	}
+	if len(p.ports) > 0 {
+		s += " listening on " + strings.Join(p.ports, ", ")
+	}
+	return s
+}
+
+// leakChecker detects the processes left running by the tests of one
+// package.
+type leakChecker struct {
+	tag string
+	// listeners maps the IDs of the processes that listened on TCP ports
+	// before the tests ran to their addresses.
+	listeners map[int][]string
+}
+
+// newLeakChecker records the processes listening on TCP ports before
+// the tests of the given package run.
+func newLeakChecker(jirix *jiri.X, pkg string) (*leakChecker, error) {
+	listeners, err := getListeners(jirix)
+	if err != nil {
+		return nil, err
+	}
+	return &leakChecker{
+		tag:       fmt.Sprintf("%s-%d-%d", pkg, os.Getpid(), time.Now().UnixNano()),
+		listeners: listeners,
+	}, nil
+}
+
+// env returns the environment variables identifying the processes
+// started by the tests.
+func (c *leakChecker) env() map[string]string {
+	return map[string]string{leakTagEnvVar: c.tag}
+}
+
+// check returns the processes started by the tests that are still
+// running. Where the environment of processes can be inspected, these
+// are the processes that inherited the tag of the checker. Elsewhere,
+// they are approximated by the processes that started listening on TCP
+// ports while the tests ran.
+func (c *leakChecker) check(jirix *jiri.X) ([]leakedProcess, error) {
+	listeners, err := getListeners(jirix)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	if _, err := os.Stat(procDir); err == nil {
+		if pids, err = findTaggedProcesses(procDir, leakTagEnvVar+"="+c.tag); err != nil {
+			return nil, err
+		}
+	} else {
+		for pid := range listeners {
+			if _, ok := c.listeners[pid]; !ok {
+				pids = append(pids, pid)
+			}
+		}
+		sort.Ints(pids)
+	}
+	leaked := []leakedProcess{}
+	for _, pid := range pids {
+		leaked = append(leaked, leakedProcess{
+			pid:     pid,
+			command: processCommand(pid),
+			ports:   listeners[pid],
+		})
+	}
+	return leaked, nil
+}
+
+// getListeners returns a map from the IDs of the processes listening on
+// TCP ports to the addresses they listen on.
+func getListeners(jirix *jiri.X) (map[int][]string, error) {
+	return lsofListeners(jirix, "-iTCP")
+}
+
+// lsofListeners returns a map from the IDs of the processes listening on
+// the TCP ports selected by the given "lsof -i" argument to the
+// addresses they listen on.
+func lsofListeners(jirix *jiri.X, selection string) (map[int][]string, error) {
+	// Make sure "lsof" exists.
+	if _, err := exec.LookPath("lsof"); err != nil {
+		return nil, fmt.Errorf(`"lsof" not found in the PATH`)
+	}
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, &out).Verbose(false).
+		Last("lsof", selection, "-sTCP:LISTEN", "-P", "-n", "-F", "pn"); err != nil {
+		// When no listener exists, "lsof" exits with non-zero
+		// status.
+		return map[int][]string{}, nil
+	}
+	return parseListeners(out.String())
+}
+
+// parseListeners parses the output of "lsof -F pn", which lists the ID
+// of each process on a line prefixed with "p", followed by the addresses
+// it listens on, each on a line prefixed with "n".
+func parseListeners(out string) (map[int][]string, error) {
+	listeners := map[int][]string{}
+	pid := -1
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			var err error
+			if pid, err = strconv.Atoi(line[1:]); err != nil {
+				return nil, fmt.Errorf("Atoi(%v) failed: %v", line[1:], err)
+			}
+		case 'n':
+			if pid == -1 {
+				return nil, fmt.Errorf("unexpected lsof output: %q", line)
+			}
+			if !contains(listeners[pid], line[1:]) {
+				listeners[pid] = append(listeners[pid], line[1:])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Scan() failed: %v", err)
+	}
+	return listeners, nil
+}
+
+// findTaggedProcesses returns the sorted IDs of the processes whose
+// environment contains the given KEY=VALUE entry, looking them up in
+// the given proc filesystem. Processes whose environment cannot be read
+// are ignored.
+func findTaggedProcesses(dir, entry string) ([]int, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadDir(%v) failed: %v", dir, err)
+	}
+	pids := []int{}
+	for _, fileInfo := range fileInfos {
+		pid, err := strconv.Atoi(fileInfo.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		environ, err := ioutil.ReadFile(filepath.Join(dir, fileInfo.Name(), "environ"))
+		if err != nil {
+			continue
+		}
+		for _, kv := range strings.Split(string(environ), "\x00") {
+			if kv == entry {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// processCommand returns the command line of the given process, or an
+// empty string if it cannot be determined.
+func processCommand(pid int) string {
+	cmdline, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "cmdline"))
+	if err == nil {
+		return strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+	}
+	out, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// addLeakWarnings records the given processes left running by the tests
+// of the given package in the xUnit test suites of the package. The
+// processes are recorded in the output of a passing test case, so that
+// they show up in the report without failing the tests.
+func addLeakWarnings(suites []*xunit.TestSuite, pkg string, leaked []leakedProcess) []*xunit.TestSuite {
+	if len(leaked) == 0 {
+		return suites
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "The tests left %d processes running:\n", len(leaked))
+	for _, p := range leaked {
+		fmt.Fprintf(&out, "%v\n", p)
+	}
+	c := xunit.TestCase{
+		Name:      leakCheckCaseName,
+		Classname: pkg,
+		Time:      "0.00",
+		SystemOut: out.String(),
+	}
+	if len(suites) == 0 {
+		suites = append(suites, &xunit.TestSuite{Name: pkg})
+	}
+	suites[0].Cases = append(suites[0].Cases, c)
+	suites[0].Tests++
+	return suites
+}
+
+// contains returns whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"v.io/x/devtools/internal/xunit"
+)
+
+func TestParseListeners(t *testing.T) {
+	out := "p123\nn*:8080\nn[::]:8080\np456\nn127.0.0.1:34567\nn127.0.0.1:34567\n"
+	got, err := parseListeners(out)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := map[int][]string{
+		123: []string{"*:8080", "[::]:8080"},
+		456: []string{"127.0.0.1:34567"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if _, err := parseListeners("n*:8080\n"); err == nil {
+		t.Fatalf("parsing an address without a process did not fail")
+	}
+}
+
+func TestFindTaggedProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	environs := map[string]string{
+		"10":   "PATH=/bin\x00V23_LEAK_CHECK_TAG=v.io/x/ref-1\x00",
+		"11":   "V23_LEAK_CHECK_TAG=v.io/x/ref-2\x00",
+		"12":   "HOME=/root\x00",
+		"9":    "V23_LEAK_CHECK_TAG=v.io/x/ref-1\x00",
+		"self": "V23_LEAK_CHECK_TAG=v.io/x/ref-1\x00",
+	}
+	for name, environ := range environs {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatalf("MkdirAll() failed: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name, "environ"), []byte(environ), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+	got, err := findTaggedProcesses(dir, "V23_LEAK_CHECK_TAG=v.io/x/ref-1")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []int{9, 10}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestAddLeakWarnings(t *testing.T) {
+	suites := []*xunit.TestSuite{
+		{Name: "v.io/x/ref", Cases: []xunit.TestCase{{Name: "TestFoo", Classname: "v.io/x/ref", Time: "0.10"}}, Tests: 1},
+	}
+	leaked := []leakedProcess{
+		{pid: 10, command: "mounttabled -v23.tcp.address=127.0.0.1:0", ports: []string{"127.0.0.1:34567"}},
+		{pid: 11},
+	}
+	got := addLeakWarnings(suites, "v.io/x/ref", leaked)
+	want := []*xunit.TestSuite{
+		{
+			Name: "v.io/x/ref",
+			Cases: []xunit.TestCase{
+				{Name: "TestFoo", Classname: "v.io/x/ref", Time: "0.10"},
+				{
+					Name:      "LeakCheck",
+					Classname: "v.io/x/ref",
+					Time:      "0.00",
+					SystemOut: "The tests left 2 processes running:\npid 10 (mounttabled -v23.tcp.address=127.0.0.1:0) listening on 127.0.0.1:34567\npid 11\n",
+				},
+			},
+			Tests: 2,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want[0], got[0])
+	}
+	if got := addLeakWarnings(nil, "v.io/x/ref", nil); got != nil {
+		t.Fatalf("want no suites, got %v", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"v.io/jiri"
+	"v.io/jiri/runutil"
+	"v.io/x/devtools/tooldata"
+)
+
+// resourceLimitsOpt identifies the resource limits of the processes
+// that run the tests of each package.
+type resourceLimitsOpt tooldata.GoTestResourceLimits
+
+func (resourceLimitsOpt) goTestOpt() {}
+
+// loadResourceLimits loads the Go test resource limits from the tools
+// config.
+func loadResourceLimits(jirix *jiri.X) (resourceLimitsOpt, error) {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return resourceLimitsOpt{}, err
+	}
+	return resourceLimitsOpt(config.GoTestResourceLimits()), nil
+}
+
+// enabled returns whether any resource limits are set.
+func (l resourceLimitsOpt) enabled() bool {
+	return tooldata.GoTestResourceLimits(l).Enabled()
+}
+
+// withoutMemoryLimit returns the resource limits without the memory
+// limit. The race detector and the sanitizers reserve terabytes of
+// virtual memory, so their test binaries cannot run under it.
+func (l resourceLimitsOpt) withoutMemoryLimit() resourceLimitsOpt {
+	l.MemoryMB = 0
+	return l
+}
+
+// command returns the command line that runs the given command with the
+// configured wrapper command, if any. The CPU time and memory limits are
+// not applied to the command, so that they do not apply to the go tool
+// and the compiler; they are applied by the script written by
+// writeLimitScript instead.
+func (l resourceLimitsOpt) command(name string, args ...string) (string, []string) {
+	cmdline := append(append([]string{}, l.Wrapper...), name)
+	cmdline = append(cmdline, args...)
+	return cmdline[0], cmdline[1:]
+}
+
+// writeLimitScript writes a script to the given directory that runs its
+// arguments, e.g. a test binary, under the CPU time and memory limits.
+// The limits are set as soft limits with the ulimit shell builtin, so
+// that exceeding the CPU time limit raises SIGXCPU instead of SIGKILL.
+// The script records the exit status of its arguments in the file
+// identified by limitStatusFile, from which limitExceeded classifies
+// the failures. The script is meant to be passed to the -exec flag of
+// "go test".
+func (l resourceLimitsOpt) writeLimitScript(dir string) (string, error) {
+	lines := []string{"#!/bin/sh"}
+	if l.CPUSeconds > 0 {
+		lines = append(lines, fmt.Sprintf("ulimit -S -t %d || exit 1", l.CPUSeconds))
+	}
+	if l.MemoryMB > 0 {
+		lines = append(lines, fmt.Sprintf("ulimit -S -v %d || exit 1", l.MemoryMB*1024))
+	}
+	lines = append(lines, `"$@"`, "status=$?", fmt.Sprintf("echo $status > '%s'", limitStatusFile(dir)), "exit $status", "")
+	path := filepath.Join(dir, "limits.sh")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0755); err != nil {
+		return "", fmt.Errorf("WriteFile(%v) failed: %v", path, err)
+	}
+	return path, nil
+}
+
+// limitStatusFile returns the file in which the script written to the
+// given directory by writeLimitScript records the exit status.
+func limitStatusFile(dir string) string {
+	return filepath.Join(dir, "limits.status")
+}
+
+// limitExceeded identifies the resource limit that was exceeded by the
+// process that failed with the given error and output, whose exit
+// status was recorded in the given file by the script written by
+// writeLimitScript. It returns an empty string if the failure is not
+// caused by a resource limit.
+func limitExceeded(err error, output, statusFile string) string {
+	signal := syscall.Signal(0)
+	if exitError, ok := runutil.GetOriginalError(err).(*exec.ExitError); ok {
+		if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			signal = status.Signal()
+		}
+	}
+	status := -1
+	if data, err := ioutil.ReadFile(statusFile); err == nil {
+		if status, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			status = -1
+		}
+	}
+	// The shell reports processes terminated by a signal with an exit
+	// status of 128 plus the signal number.
+	if signal == 0 && status > 128 {
+		signal = syscall.Signal(status - 128)
+	}
+	switch {
+	case signal == syscall.SIGXCPU:
+		return "CPU time"
+	case signal == syscall.SIGKILL:
+		// The wrapper command, e.g. a cgroup out of memory killer,
+		// killed the process.
+		return "killed"
+	case status == 2 && strings.Contains(output, "fatal error: runtime: out of memory"):
+		// The Go runtime exits with status 2 when it fails to
+		// allocate memory under the memory limit.
+		return "memory"
+	}
+	return ""
+}
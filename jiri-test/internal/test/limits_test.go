@@ -0,0 +1,105 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResourceLimitsCommand(t *testing.T) {
+	tests := []struct {
+		limits resourceLimitsOpt
+		want   []string
+	}{
+		{
+			resourceLimitsOpt{},
+			[]string{"jiri", "go", "test"},
+		},
+		{
+			resourceLimitsOpt{CPUSeconds: 60, MemoryMB: 2},
+			[]string{"jiri", "go", "test"},
+		},
+		{
+			resourceLimitsOpt{Wrapper: []string{"cgexec", "-g", "memory:tests"}},
+			[]string{"cgexec", "-g", "memory:tests", "jiri", "go", "test"},
+		},
+	}
+	for _, test := range tests {
+		name, args := test.limits.command("jiri", "go", "test")
+		if got := append([]string{name}, args...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.limits, got, test.want)
+		}
+	}
+	if got := (resourceLimitsOpt{CPUSeconds: 60, MemoryMB: 2}).withoutMemoryLimit(); got.CPUSeconds != 60 || got.MemoryMB != 0 {
+		t.Errorf("got %v, want only the CPU time limit", got)
+	}
+}
+
+func TestWriteLimitScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	script, err := resourceLimitsOpt{CPUSeconds: 60, MemoryMB: 2}.writeLimitScript(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	data, err := ioutil.ReadFile(script)
+	if err != nil {
+		t.Fatalf("ReadFile(%v) failed: %v", script, err)
+	}
+	for _, want := range []string{"ulimit -S -t 60 ", "ulimit -S -v 2048 ", filepath.Join(dir, "limits.status")} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("script %q does not contain %q", string(data), want)
+		}
+	}
+}
+
+func TestLimitExceeded(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	script, err := resourceLimitsOpt{CPUSeconds: 1}.writeLimitScript(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The script runs the process that exceeds the limit as a child,
+	// like "go test -exec" runs the test binary, so the limit is
+	// identified from the recorded exit status.
+	err = exec.Command(script, "sh", "-c", "while :; do :; done").Run()
+	if got, want := limitExceeded(err, "", limitStatusFile(dir)), "CPU time"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		status, output, want string
+	}{
+		{"1", "--- FAIL: TestA (0.00s)\nopen /tmp/x: cannot allocate memory\n", ""},
+		{"1", "=== RUN   TestA\nsignal: killed\nFAIL\tv.io/x/foo\t1.010s\n", ""},
+		{"2", "fatal error: runtime: out of memory\n", "memory"},
+		{"137", "", "killed"},
+		{"152", "", "CPU time"},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(limitStatusFile(dir), []byte(test.status+"\n"), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+		if got := limitExceeded(exec.Command("false").Run(), test.output, limitStatusFile(dir)); got != test.want {
+			t.Errorf("%v %q: got %q, want %q", test.status, test.output, got, test.want)
+		}
+	}
+}
@@ -5,22 +5,50 @@
 package test
 
 import (
+	"bytes"
+	"fmt"
+	"path/filepath"
 	"time"
 
 	"v.io/jiri"
 	"v.io/jiri/collect"
 	"v.io/jiri/runutil"
 	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/envvar"
 )
 
+// makeTestOutputEnvVar identifies the file that make targets write their
+// structured test results to.
+const makeTestOutputEnvVar = "XUNIT_OUTPUT_FILE"
+
 // runMakefileTest is a helper for running tests through make commands.
 func runMakefileTest(jirix *jiri.X, testName, testDir, target string, env map[string]string, profiles []string, timeout time.Duration) (_ *test.Result, e error) {
 	return runMakefileTestWithExtraDeps(jirix, testName, testDir, target, env, profiles, nil, timeout)
 }
 
 // runMakefileTestWithExtraDeps is a helper for running tests through make commands with extra dependencies.
+// The environment, profiles and timeout of the test can be extended by
+// the settings of the test in the tools config, which can also request
+// the test results written by the make target to be merged into the
+// xUnit report of the test.
 func runMakefileTestWithExtraDeps(jirix *jiri.X, testName, testDir, target string, env map[string]string, profiles []string, initExtraDeps func() (func() error, error), timeout time.Duration) (_ *test.Result, e error) {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadConfig")
+	}
+	settings := config.MakeTests()[testName]
+	env = envvar.MergeMaps(env, envvar.SliceToMap(settings.Env))
+	// Copy the profiles before extending them, so that the slice of the
+	// caller is never modified.
+	profiles = append(append([]string{}, profiles...), settings.Profiles...)
+	if settings.Timeout != "" {
+		if timeout, err = time.ParseDuration(settings.Timeout); err != nil {
+			return nil, newInternalError(fmt.Errorf("invalid timeout %q: %v", settings.Timeout, err), "LoadConfig")
+		}
+	}
+
 	// Install base profile first, before any test-specific profiles.
 	profiles = append([]string{"v23:base"}, profiles...)
 
@@ -42,6 +70,16 @@ func runMakefileTestWithExtraDeps(jirix *jiri.X, testName, testDir, target strin
 
 	// Set up the environment
 	merged := envvar.MergeMaps(jirix.Env(), env)
+	outputFile := ""
+	if settings.Output != "" {
+		tmpDir, err := s.TempDir("", "make-test")
+		if err != nil {
+			return nil, newInternalError(err, "TempDir")
+		}
+		defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(tmpDir).Done() }, &e)
+		outputFile = filepath.Join(tmpDir, "output")
+		merged[makeTestOutputEnvVar] = outputFile
+	}
 
 	// Navigate to project directory, run make clean and make target.
 	err = s.Pushd(testDir).
@@ -49,16 +87,67 @@ func runMakefileTestWithExtraDeps(jirix *jiri.X, testName, testDir, target strin
 		Run("make", "clean").
 		Verbose(true).
 		Timeout(timeout).Env(merged).Last("make", target)
-	if err != nil {
-		if runutil.IsTimeout(err) {
-			return &test.Result{
-				Status:       test.TimedOut,
-				TimeoutValue: timeout,
-			}, nil
-		} else {
-			return nil, newInternalError(err, "Make "+target)
+	if err != nil && runutil.IsTimeout(err) {
+		return &test.Result{
+			Status:       test.TimedOut,
+			TimeoutValue: timeout,
+		}, nil
+	}
+
+	// Report the structured test results, if any.
+	if outputFile != "" {
+		if _, statErr := s.Stat(outputFile); statErr == nil {
+			passed, reportErr := reportMakeTestOutput(jirix, testName, settings.Output, outputFile)
+			if reportErr != nil {
+				return nil, newInternalError(reportErr, "Report")
+			}
+			if !passed {
+				return &test.Result{Status: test.Failed}, nil
+			}
+		} else if !runutil.IsNotExist(statErr) {
+			return nil, newInternalError(statErr, "Report")
 		}
 	}
+	if err != nil {
+		return nil, newInternalError(err, "Make "+target)
+	}
 
 	return &test.Result{Status: test.Passed}, nil
 }
+
+// reportMakeTestOutput merges the test results in the given format,
+// either "tap" or "xunit", that a make target wrote to the given file
+// into the xUnit report of the given test. The function returns whether
+// all the reported tests passed.
+func reportMakeTestOutput(jirix *jiri.X, testName, format, path string) (bool, error) {
+	var suites []xunit.TestSuite
+	switch format {
+	case "tap":
+		data, err := jirix.NewSeq().ReadFile(path)
+		if err != nil {
+			return false, fmt.Errorf("ReadFile(%v) failed: %v", path, err)
+		}
+		suite, err := xunit.TestSuiteFromTAP(testName, bytes.NewReader(data))
+		if err != nil {
+			return false, err
+		}
+		suites = []xunit.TestSuite{*suite}
+	case "xunit":
+		report, err := xunit.ReadReport(jirix, path)
+		if err != nil {
+			return false, err
+		}
+		suites = report.Suites
+	default:
+		return false, fmt.Errorf("unsupported test output format %q", format)
+	}
+	passed := true
+	for _, suite := range suites {
+		for _, c := range suite.AllCases() {
+			if len(c.Failures) > 0 || len(c.Errors) > 0 {
+				passed = false
+			}
+		}
+	}
+	return passed, xunit.AppendReport(jirix, testName, suites)
+}
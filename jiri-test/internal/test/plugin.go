@@ -0,0 +1,256 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/jiri/project"
+	"v.io/jiri/runutil"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+)
+
+// pluginManifestFile is the name of the file, located at the root of a
+// project, that lists the test plugins provided by the project.
+const pluginManifestFile = ".jiri_test_plugins"
+
+// pluginSchema is the JSON representation of a test plugin listed in a
+// plugin manifest.
+type pluginSchema struct {
+	// Name is the name of the test implemented by the plugin.
+	Name string
+	// Description is a short description of the test.
+	Description string
+	// Binary is the path of the plugin binary. Relative paths are
+	// interpreted relative to the root of the project that provides
+	// the plugin.
+	Binary string
+	// Profiles identifies the profiles the test requires.
+	Profiles []string
+	// PlatformProfiles identifies the profiles the test requires on
+	// some platforms only, or cannot use on some platforms.
+	PlatformProfiles []PlatformProfile `json:",omitempty"`
+}
+
+// pluginRequest is the JSON request that is written to the standard
+// input of a plugin binary when its test is run.
+type pluginRequest struct {
+	// Test is the name of the test to run.
+	Test string
+	// Root is the JIRI_ROOT.
+	Root string
+	// XUnitReport is the path the plugin should write its xUnit report
+	// to. If the plugin writes no report, a report with a single test
+	// case that has the status of the response is generated.
+	XUnitReport string
+	// Part identifies the part of the test to run, or -1 to run all of
+	// the test.
+	Part int
+	// Pkgs identifies the subset of the test to run; its meaning is
+	// plugin-specific.
+	Pkgs []string
+	// Exclusions lists the tests that are excluded on this host.
+	Exclusions []pluginExclusion
+}
+
+// pluginExclusion is the JSON representation of an exclusion passed to
+// a plugin; Pkg and Name are regular expressions.
+type pluginExclusion struct {
+	Kind exclusionKind
+	Pkg  string
+	Name string
+}
+
+// pluginResponse is the JSON response that a plugin binary writes to
+// its standard output once its test completes. Progress output must be
+// written to the standard error instead.
+type pluginResponse struct {
+	// Status is the status of the test, as printed by test.Status (e.g.
+	// "PASSED" or "FAILED").
+	Status string
+	// Error describes why the test failed to run, if it did.
+	Error string
+}
+
+// LoadPlugins registers the tests implemented by the plugins listed in
+// the plugin manifests of the local projects.
+func LoadPlugins(jirix *jiri.X) error {
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		path := filepath.Join(p.Path, pluginManifestFile)
+		bytes, err := jirix.NewSeq().ReadFile(path)
+		if err != nil {
+			if runutil.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		plugins, err := parsePluginManifest(bytes)
+		if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		for _, plugin := range plugins {
+			binary := plugin.Binary
+			if !filepath.IsAbs(binary) {
+				binary = filepath.Join(p.Path, binary)
+			}
+			spec := TestSpec{
+				Description:      plugin.Description,
+				Plugin:           binary,
+				Profiles:         plugin.Profiles,
+				PlatformProfiles: plugin.PlatformProfiles,
+			}
+			if err := RegisterTest(plugin.Name, spec, pluginTest(binary, plugin.Profiles)); err != nil {
+				return fmt.Errorf("%v: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parsePluginManifest parses the given JSON-encoded list of plugins.
+func parsePluginManifest(bytes []byte) ([]pluginSchema, error) {
+	var plugins []pluginSchema
+	if err := json.Unmarshal(bytes, &plugins); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	for _, plugin := range plugins {
+		if plugin.Name == "" || plugin.Binary == "" {
+			return nil, fmt.Errorf("plugin %+v must have a name and a binary", plugin)
+		}
+	}
+	return plugins, nil
+}
+
+// pluginTest returns a test function that runs the given plugin binary.
+func pluginTest(binary string, profiles []string) TestFunc {
+	return func(jirix *jiri.X, testName string, opts ...Opt) (_ *test.Result, e error) {
+		// Initialize the test.
+		cleanup, err := initTest(jirix, testName, profiles)
+		if err != nil {
+			return nil, newInternalError(err, "Init")
+		}
+		defer collect.Error(func() error { return cleanup() }, &e)
+
+		request, err := newPluginRequest(jirix, testName, opts...)
+		if err != nil {
+			return nil, newInternalError(err, "Request")
+		}
+		in, err := json.Marshal(request)
+		if err != nil {
+			return nil, newInternalError(fmt.Errorf("Marshal(%v) failed: %v", request, err), "Request")
+		}
+		var out bytes.Buffer
+		if err := jirix.NewSeq().Read(bytes.NewReader(in)).Capture(&out, jirix.Stderr()).
+			Timeout(test.DefaultTimeout).Last(binary); err != nil {
+			if runutil.IsTimeout(err) {
+				return &test.Result{
+					Status:       test.TimedOut,
+					TimeoutValue: test.DefaultTimeout,
+				}, nil
+			}
+			return nil, newInternalError(err, "Run")
+		}
+		result, err := parsePluginResponse(out.Bytes())
+		if err != nil {
+			return nil, newInternalError(err, "Response")
+		}
+		if err := ensurePluginReport(jirix, testName, result); err != nil {
+			return nil, newInternalError(err, "Report")
+		}
+		return result, nil
+	}
+}
+
+// ensurePluginReport generates the xUnit report of the given test from
+// the given result of its plugin, unless the plugin wrote a report.
+func ensurePluginReport(jirix *jiri.X, testName string, result *test.Result) error {
+	if _, err := jirix.NewSeq().Stat(xunit.ReportPath(testName)); err == nil {
+		return nil
+	} else if !runutil.IsNotExist(err) {
+		return err
+	}
+	return xunit.CreateReport(jirix, testName, []xunit.TestSuite{pluginReportSuite(testName, result)})
+}
+
+// pluginReportSuite returns a test suite with a single test case that
+// represents the given result of the given plugin test.
+func pluginReportSuite(testName string, result *test.Result) xunit.TestSuite {
+	s := xunit.TestSuite{Name: testName, Tests: 1}
+	c := xunit.TestCase{Classname: testName, Name: testName, Time: "0.00"}
+	switch result.Status {
+	case test.Failed, test.TimedOut:
+		c.Failures = append(c.Failures, xunit.Failure{Message: result.Status.String()})
+		s.Failures = 1
+	case test.Skipped:
+		c.Skipped = append(c.Skipped, result.Status.String())
+		s.Skip = 1
+	}
+	s.Cases = append(s.Cases, c)
+	return s
+}
+
+// newPluginRequest returns the request for running the given test with
+// the given options.
+func newPluginRequest(jirix *jiri.X, testName string, opts ...Opt) (*pluginRequest, error) {
+	request := &pluginRequest{
+		Test:        testName,
+		Root:        jirix.Root,
+		XUnitReport: xunit.ReportPath(testName),
+		Part:        -1,
+		Exclusions:  []pluginExclusion{},
+	}
+	for _, opt := range opts {
+		switch typedOpt := opt.(type) {
+		case PartOpt:
+			request.Part = int(typedOpt)
+		case PkgsOpt:
+			request.Pkgs = []string(typedOpt)
+		}
+	}
+	for _, kind := range []exclusionKind{goTestExclusionKind, goRaceExclusionKind, goIntegrationExclusionKind, goAsanExclusionKind, goMsanExclusionKind} {
+		exclusions, err := loadExclusions(jirix, kind)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range exclusions {
+			if !e.exclude {
+				continue
+			}
+			request.Exclusions = append(request.Exclusions, pluginExclusion{
+				Kind: kind,
+				Pkg:  e.pkgRE.String(),
+				Name: e.nameRE.String(),
+			})
+		}
+	}
+	return request, nil
+}
+
+// parsePluginResponse parses the given JSON-encoded plugin response.
+func parsePluginResponse(bytes []byte) (*test.Result, error) {
+	var response pluginResponse
+	if err := json.Unmarshal(bytes, &response); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	for _, status := range []test.Status{test.Passed, test.Failed, test.Skipped, test.TimedOut} {
+		if status.String() == response.Status {
+			return &test.Result{Status: status}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown test status %q", response.Status)
+}
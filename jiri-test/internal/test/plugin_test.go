@@ -0,0 +1,195 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/jiri/profiles"
+	"v.io/x/devtools/internal/test"
+)
+
+func TestRegisterTest(t *testing.T) {
+	const name = "test-register-test"
+	defer delete(testFunctions, name)
+	defer delete(testSpecs, name)
+
+	spec := TestSpec{Description: "A registered test."}
+	if err := RegisterTest(name, spec, testMock); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, ok := LookupTestSpec(name); !ok || !reflect.DeepEqual(got, spec) {
+		t.Fatalf("got %v, %v, want %v, true", got, ok, spec)
+	}
+	tests, err := ListTests()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	found := false
+	for _, test := range tests {
+		found = found || test == name
+	}
+	if !found {
+		t.Fatalf("%v not found in %v", name, tests)
+	}
+	if err := RegisterTest(name, spec, testMock); err == nil {
+		t.Fatalf("registering %v twice did not fail", name)
+	}
+	if err := RegisterTest("vanadium-go-test", spec, testMock); err == nil {
+		t.Fatalf("registering a built-in test did not fail")
+	}
+}
+
+func TestResolvePlatformProfiles(t *testing.T) {
+	profiles := []PlatformProfile{
+		{Profile: "v23:base"},
+		{Profile: "v23:android", Platforms: []string{"linux/amd64"}},
+		{Profile: "v23:syncbase", Unsupported: []string{"darwin/arm64", "windows"}},
+	}
+	tests := []struct {
+		goos, goarch string
+		want         []string
+		err          string
+	}{
+		{"linux", "amd64", []string{"v23:base", "v23:android", "v23:syncbase"}, ""},
+		{"darwin", "amd64", []string{"v23:base", "v23:syncbase"}, ""},
+		{"darwin", "arm64", nil, "profile v23:syncbase unsupported on darwin/arm64"},
+		{"windows", "386", nil, "profile v23:syncbase unsupported on windows/386"},
+	}
+	for _, test := range tests {
+		got, err := resolvePlatformProfiles(profiles, test.goos, test.goarch)
+		if test.err != "" {
+			if _, ok := err.(unsupportedProfileError); !ok || err.Error() != test.err {
+				t.Errorf("%v/%v: got error %v, want %v", test.goos, test.goarch, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v/%v: %v", test.goos, test.goarch, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v/%v: got %v, want %v", test.goos, test.goarch, got, test.want)
+		}
+	}
+}
+
+func TestPlatformProfiles(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+		err    string
+	}{
+		{"amd64-linux", []string{"v23:base", "v23:nodejs"}, ""},
+		{"arm-linux", nil, "profile v23:nodejs unsupported on linux/arm"},
+	}
+	for _, test := range tests {
+		target, err := profiles.NewTarget(test.target, "")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		// The built-in test is set up with the given profiles, whose
+		// platforms are described by the built-in registry.
+		got, err := platformProfiles("vanadium-playground-test", []string{"v23:base", "v23:nodejs"}, target)
+		if test.err != "" {
+			if _, ok := err.(unsupportedProfileError); !ok || err.Error() != test.err {
+				t.Errorf("%v: got error %v, want %v", test.target, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.target, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.target, got, test.want)
+		}
+	}
+}
+
+func TestParsePluginManifest(t *testing.T) {
+	plugins, err := parsePluginManifest([]byte(`[
+  {"Name": "foo-test", "Description": "Tests foo.", "Binary": "bin/foo-test", "Profiles": ["v23:base"],
+   "PlatformProfiles": [{"Profile": "v23:syncbase", "Unsupported": ["darwin/arm64"]}]}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []pluginSchema{
+		{
+			Name:        "foo-test",
+			Description: "Tests foo.",
+			Binary:      "bin/foo-test",
+			Profiles:    []string{"v23:base"},
+			PlatformProfiles: []PlatformProfile{
+				{Profile: "v23:syncbase", Unsupported: []string{"darwin/arm64"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(plugins, want) {
+		t.Fatalf("got %v, want %v", plugins, want)
+	}
+	for _, data := range []string{
+		`[{"Name": "foo-test"}]`,
+		`[{"Binary": "bin/foo-test"}]`,
+		`{}`,
+	} {
+		if _, err := parsePluginManifest([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
+
+func TestParsePluginResponse(t *testing.T) {
+	tests := []struct {
+		data   string
+		status test.Status
+	}{
+		{`{"Status": "PASSED"}`, test.Passed},
+		{`{"Status": "FAILED"}`, test.Failed},
+		{`{"Status": "SKIPPED"}`, test.Skipped},
+		{`{"Status": "TIMED OUT"}`, test.TimedOut},
+	}
+	for _, test := range tests {
+		result, err := parsePluginResponse([]byte(test.data))
+		if err != nil {
+			t.Fatalf("%v: %v", test.data, err)
+		}
+		if got, want := result.Status, test.status; got != want {
+			t.Errorf("%v: got %v, want %v", test.data, got, want)
+		}
+	}
+	for _, data := range []string{
+		`{"Status": "UNKNOWN"}`,
+		`{"Error": "cannot connect"}`,
+		`PASSED`,
+	} {
+		if _, err := parsePluginResponse([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
+
+func TestPluginReportSuite(t *testing.T) {
+	tests := []struct {
+		status                       test.Status
+		failures, skip, caseFailures int
+	}{
+		{test.Passed, 0, 0, 0},
+		{test.Failed, 1, 0, 1},
+		{test.TimedOut, 1, 0, 1},
+		{test.Skipped, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		s := pluginReportSuite("test-plugin", &test.Result{Status: tt.status})
+		if s.Tests != 1 || len(s.Cases) != 1 {
+			t.Fatalf("%v: got %d tests and %d cases, want 1 and 1", tt.status, s.Tests, len(s.Cases))
+		}
+		if s.Failures != tt.failures || s.Skip != tt.skip || len(s.Cases[0].Failures) != tt.caseFailures {
+			t.Errorf("%v: got %+v", tt.status, s)
+		}
+	}
+}
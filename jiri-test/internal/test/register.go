@@ -0,0 +1,178 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/profiles"
+	"v.io/x/devtools/internal/test"
+)
+
+// TestFunc is the type of the functions that implement tests. The
+// function is given the name the test was invoked with and the options
+// the tests are run with.
+type TestFunc func(*jiri.X, string, ...Opt) (*test.Result, error)
+
+// TestSpec describes a test registered through RegisterTest.
+type TestSpec struct {
+	// Description is a short description of the test.
+	Description string
+	// Plugin is the path of the plugin binary that implements the test,
+	// or empty if the test is implemented in-process.
+	Plugin string
+	// Profiles identifies the profiles the test requires, if known.
+	Profiles []string
+	// PlatformProfiles identifies the profiles the test requires on
+	// some platforms only, or cannot use on some platforms.
+	PlatformProfiles []PlatformProfile
+}
+
+// PlatformProfile describes the platforms on which a test requires a
+// profile. Platforms are identified as "<goos>" or "<goos>/<goarch>".
+type PlatformProfile struct {
+	// Profile is the name of the profile.
+	Profile string
+	// Platforms lists the platforms on which the profile is required,
+	// or is empty if the profile is required on all platforms.
+	Platforms []string `json:",omitempty"`
+	// Unsupported lists the platforms on which the profile cannot be
+	// installed. The test is skipped on these platforms, instead of
+	// failing to set up.
+	Unsupported []string `json:",omitempty"`
+}
+
+// testSpecs records the specs of the registered tests.
+var testSpecs = map[string]TestSpec{}
+
+// RegisterTest registers the given test runner under the given name, so
+// that the test can be run and scheduled like the tests built into this
+// package. It returns an error if a test with the given name already
+// exists.
+func RegisterTest(name string, spec TestSpec, runner TestFunc) error {
+	if name == "" {
+		return fmt.Errorf("test name must not be empty")
+	}
+	if runner == nil {
+		return fmt.Errorf("test %q has no runner", name)
+	}
+	if _, ok := testFunctions[name]; ok {
+		return fmt.Errorf("test %q already exists", name)
+	}
+	testFunctions[name] = runner
+	testSpecs[name] = spec
+	return nil
+}
+
+// LookupTestSpec returns the spec of the given registered test.
+func LookupTestSpec(name string) (TestSpec, bool) {
+	spec, ok := testSpecs[name]
+	return spec, ok
+}
+
+// unsupportedProfileError is returned when a test requires a profile
+// that is not supported on the target platform.
+type unsupportedProfileError struct {
+	profile string
+	goos    string
+	goarch  string
+}
+
+func (e unsupportedProfileError) Error() string {
+	return fmt.Sprintf("profile %v unsupported on %v/%v", e.profile, e.goos, e.goarch)
+}
+
+// matchesPlatform returns whether the given "<goos>" or
+// "<goos>/<goarch>" platform matches the given GOOS and GOARCH.
+func matchesPlatform(platform, goos, goarch string) bool {
+	if i := strings.Index(platform, "/"); i != -1 {
+		return platform[:i] == goos && platform[i+1:] == goarch
+	}
+	return platform == goos
+}
+
+// matchesAnyPlatform returns whether any of the given platforms matches
+// the given GOOS and GOARCH.
+func matchesAnyPlatform(platforms []string, goos, goarch string) bool {
+	for _, platform := range platforms {
+		if matchesPlatform(platform, goos, goarch) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePlatformProfiles returns the profiles among the given ones that
+// are required on the given GOOS and GOARCH. It returns an
+// unsupportedProfileError if one of them is not supported there.
+func resolvePlatformProfiles(profiles []PlatformProfile, goos, goarch string) ([]string, error) {
+	result := []string{}
+	for _, p := range profiles {
+		if len(p.Platforms) > 0 && !matchesAnyPlatform(p.Platforms, goos, goarch) {
+			continue
+		}
+		if matchesAnyPlatform(p.Unsupported, goos, goarch) {
+			return nil, unsupportedProfileError{profile: p.Profile, goos: goos, goarch: goarch}
+		}
+		result = append(result, p.Profile)
+	}
+	return result, nil
+}
+
+// builtinPlatformProfiles describes the platforms of the profiles that
+// the tests built into this package pass to initTest. Profiles that are
+// not listed are required on all platforms.
+var builtinPlatformProfiles = map[string]PlatformProfile{
+	"v23:android": {Profile: "v23:android", Unsupported: []string{"darwin/arm64", "linux/arm64", "windows"}},
+	"v23:java":    {Profile: "v23:java", Unsupported: []string{"windows"}},
+	"v23:nodejs":  {Profile: "v23:nodejs", Unsupported: []string{"darwin/arm64", "linux/386", "linux/arm", "linux/arm64", "windows"}},
+}
+
+// testTarget is the profile target the tests are run for, as given by
+// the TargetOpt option.
+var testTarget profiles.Target
+
+// targetPlatform returns the GOOS and GOARCH of the given profile
+// target. If the target does not identify them, they are taken from the
+// GOOS and GOARCH environment variables or, if these are not set
+// either, from the host platform.
+func targetPlatform(target profiles.Target) (string, string) {
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if env := os.Getenv("GOOS"); env != "" {
+		goos = env
+	}
+	if env := os.Getenv("GOARCH"); env != "" {
+		goarch = env
+	}
+	if target.OS() != "" {
+		goos = target.OS()
+	}
+	if target.Arch() != "" {
+		goarch = target.Arch()
+	}
+	return goos, goarch
+}
+
+// platformProfiles returns the profiles that the given test requires on
+// the platform of the given target, which are the given profiles the
+// test is set up with and the platform-specific profiles of its spec,
+// resolved as described by builtinPlatformProfiles and the spec.
+func platformProfiles(testName string, profileNames []string, target profiles.Target) ([]string, error) {
+	all := []PlatformProfile{}
+	for _, name := range profileNames {
+		p, ok := builtinPlatformProfiles[name]
+		if !ok {
+			p = PlatformProfile{Profile: name}
+		}
+		all = append(all, p)
+	}
+	all = append(all, testSpecs[testName].PlatformProfiles...)
+	goos, goarch := targetPlatform(target)
+	return resolvePlatformProfiles(all, goos, goarch)
+}
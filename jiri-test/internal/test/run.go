@@ -19,9 +19,11 @@ import (
 
 	"v.io/jiri"
 	"v.io/jiri/collect"
+	"v.io/jiri/profiles"
 	"v.io/jiri/profiles/profilesreader"
 	"v.io/jiri/runutil"
 	"v.io/jiri/tool"
+	"v.io/x/devtools/internal/golib"
 	"v.io/x/devtools/internal/test"
 	"v.io/x/devtools/internal/xunit"
 	"v.io/x/devtools/tooldata"
@@ -67,7 +69,7 @@ var testMock = func(*jiri.X, string, ...Opt) (*test.Result, error) {
 	return &test.Result{Status: test.Passed}, nil
 }
 
-var testFunctions = map[string]func(*jiri.X, string, ...Opt) (*test.Result, error){
+var testFunctions = map[string]TestFunc{
 	// TODO(jsimsa,cnicolaou): consider getting rid of the vanadium- prefix.
 	"ignore-this":                                     testMock,
 	"baku-android-build":                              bakuAndroidBuild,
@@ -93,13 +95,16 @@ var testFunctions = map[string]func(*jiri.X, string, ...Opt) (*test.Result, erro
 	"vanadium-diceroller-android-test":                vanadiumDicerollerAndroidTest,
 	"vanadium-github-mirror":                          vanadiumGitHubMirror,
 	"vanadium-go-api":                                 vanadiumGoAPI,
+	"vanadium-go-asan":                                vanadiumGoAsan,
 	"vanadium-go-bench":                               vanadiumGoBench,
 	"vanadium-go-binaries":                            vanadiumGoBinaries,
 	"vanadium-go-build":                               vanadiumGoBuild,
 	"vanadium-go-cover":                               vanadiumGoCoverage,
 	"vanadium-go-depcop":                              vanadiumGoDepcop,
+	"vanadium-go-escape-analysis":                     vanadiumGoEscapeAnalysis,
 	"vanadium-go-format":                              vanadiumGoFormat,
 	"vanadium-go-generate":                            vanadiumGoGenerate,
+	"vanadium-go-msan":                                vanadiumGoMsan,
 	"vanadium-go-race":                                vanadiumGoRace,
 	"vanadium-go-snapshot":                            vanadiumGoSnapshot,
 	"vanadium-go-test":                                vanadiumGoTest,
@@ -173,6 +178,9 @@ func newTestContext(jirix *jiri.X, env map[string]string) *jiri.X {
 	for key, value := range env {
 		tmpEnv[key] = value
 	}
+	// Fail the tests instead of letting "jiri go" skip the VDL
+	// generation when the vdl tool is missing.
+	tmpEnv[golib.RequireVDLEnv] = "1"
 	return jirix.Clone(tool.ContextOpts{
 		Env: tmpEnv,
 	})
@@ -228,6 +236,13 @@ type MergePoliciesOpt profilesreader.MergePolicies
 
 func (MergePoliciesOpt) Opt() {}
 
+// TargetOpt is an option that specifies the profile target the tests
+// are run for, which determines the platform-specific profiles they
+// require.
+type TargetOpt profiles.Target
+
+func (TargetOpt) Opt() {}
+
 // DefaultPkgsOpt is an option that specifies which default packages
 // should be used to validate the test packages against.
 type DefaultPkgsOpt []string
@@ -283,6 +298,11 @@ run:
 			}
 			ready := true
 			for _, dep := range node.deps {
+				if results[dep].Unaffected {
+					// Tests skipped because they do not depend on
+					// the changed files do not block their dependents.
+					continue
+				}
 				switch results[dep].Status {
 				case test.Skipped, test.Failed, test.TimedOut:
 					results[t].Status = test.Skipped
@@ -339,6 +359,8 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 			outputDir = string(typedOpt)
 		case CleanGoOpt:
 			cleanGo = bool(typedOpt)
+		case TargetOpt:
+			testTarget = profiles.Target(typedOpt)
 		}
 	}
 
@@ -363,7 +385,24 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 
 	for _, t := range tests {
 		testFn := testFunctions[t]
-		fmt.Fprintf(jirix.Stdout(), "##### Running test %q #####\n", t)
+		logger := test.NewLogger(jirix.Context).WithTest(t)
+		logger.Infof("##### Running test #####\n")
+		if isUnaffected(jirix, t, opts) {
+			logger.Infof("does not depend on the changed files\n")
+			results[t] = &test.Result{Status: test.Skipped, Unaffected: true}
+			logger.Infof("##### %s #####\n", results[t].Status)
+			continue
+		}
+		if _, err := platformProfiles(t, nil, testTarget); err != nil {
+			if _, ok := err.(unsupportedProfileError); !ok {
+				return err
+			}
+			logger.Infof("skipped: %v\n", err)
+			writeUnsupportedProfileTestReport(jirix, t, err)
+			results[t] = &test.Result{Status: test.Skipped}
+			logger.Infof("##### %s #####\n", results[t].Status)
+			continue
+		}
 
 		// Create a 1MB buffer to capture the test function output.
 		var out bytes.Buffer
@@ -376,6 +415,13 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 
 		// Run the test and collect the test results.
 		result, err := testFn(newX, t, opts...)
+		if unsupportedErr, ok := asUnsupportedProfileError(err); ok {
+			// The test requires a profile, e.g. one it passes to
+			// initTest, that is not supported on the target platform.
+			logger.Infof("skipped: %v\n", unsupportedErr)
+			writeUnsupportedProfileTestReport(newX, t, unsupportedErr)
+			result, err = &test.Result{Status: test.Skipped}, nil
+		}
 		if result != nil && result.Status == test.TimedOut {
 			writeTimedOutTestReport(newX, t, *result)
 		}
@@ -383,7 +429,7 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 			err = checkTestReportFile(newX, t)
 		}
 		if err != nil {
-			fmt.Fprintf(newX.Stderr(), "%v\n", err)
+			test.NewLogger(newX.Context).WithTest(t).Errorf("%v\n", err)
 			r, err := generateXUnitReportForError(newX, t, err, out.String())
 			if err != nil {
 				return err
@@ -394,7 +440,11 @@ func runTests(jirix *jiri.X, tests []string, results map[string]*test.Result, op
 		if _, err := outputFile.Write(out.Bytes()); err != nil {
 			return err
 		}
-		fmt.Fprintf(jirix.Stdout(), "##### %s #####\n", results[t].Status)
+		if results[t].Status == test.Passed || results[t].Status == test.Skipped {
+			logger.Infof("##### %s #####\n", results[t].Status)
+		} else {
+			logger.Errorf("##### %s #####\n", results[t].Status)
+		}
 	}
 
 	if outputDir != "" {
@@ -424,6 +474,39 @@ func writeTimedOutTestReport(jirix *jiri.X, testName string, result test.Result)
 	}
 }
 
+// asUnsupportedProfileError returns the unsupportedProfileError that
+// the given error of a test, which can be an internal test error, stems
+// from, if any.
+func asUnsupportedProfileError(err error) (unsupportedProfileError, bool) {
+	if internalErr, ok := err.(internalTestError); ok {
+		err = internalErr.err
+	}
+	unsupportedErr, ok := err.(unsupportedProfileError)
+	return unsupportedErr, ok
+}
+
+// writeUnsupportedProfileTestReport writes a xUnit test report for the
+// given test, which is skipped because it requires a profile that is not
+// supported on the target platform.
+func writeUnsupportedProfileTestReport(jirix *jiri.X, testName string, err error) {
+	s := xunit.TestSuite{
+		Name:  testName,
+		Tests: 1,
+		Skip:  1,
+		Cases: []xunit.TestCase{
+			{
+				Classname: testName,
+				Name:      "Profiles",
+				Time:      "0.00",
+				Skipped:   []string{"skipped: " + err.Error()},
+			},
+		},
+	}
+	if err := xunit.CreateReport(jirix, testName, []xunit.TestSuite{s}); err != nil {
+		fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+	}
+}
+
 // checkTestReportFile checks that the test report file exists, contains a
 // valid xUnit test report, and the set of test cases is non-empty. If any of
 // these is not true, the function generates a dummy test report file that
@@ -465,7 +548,7 @@ func checkTestReportFile(jirix *jiri.X, testName string) error {
 	// No test cases.
 	numTestCases := 0
 	for _, suite := range suites.Suites {
-		numTestCases += len(suite.Cases)
+		numTestCases += len(suite.AllCases())
 	}
 	if numTestCases == 0 {
 		s.RemoveAll(xUnitReportFile)
@@ -0,0 +1,150 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/lib/lookpath"
+)
+
+// goSanitizer describes a sanitizer that 'go test' can instrument the
+// tested code with.
+type goSanitizer struct {
+	// flag is the 'go test' flag that enables the sanitizer.
+	flag string
+	// suffix is the base suffix of the names of the test cases.
+	suffix string
+	// kind identifies the exclusions that apply to the sanitizer runs.
+	kind exclusionKind
+	// platforms lists the <os>/<arch> platforms the sanitizer
+	// supports.
+	platforms []string
+}
+
+var (
+	goAsan = goSanitizer{
+		flag:      "-asan",
+		suffix:    "GoAsan",
+		kind:      goAsanExclusionKind,
+		platforms: []string{"linux/amd64", "linux/arm64"},
+	}
+	goMsan = goSanitizer{
+		flag:      "-msan",
+		suffix:    "GoMsan",
+		kind:      goMsanExclusionKind,
+		platforms: []string{"linux/amd64", "linux/arm64"},
+	}
+)
+
+// supports returns whether the sanitizer supports the given platform.
+func (s goSanitizer) supports(platform string) bool {
+	for _, p := range s.platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// vanadiumGoAsan runs Go tests for vanadium projects with the address
+// sanitizer enabled for their cgo code.
+func vanadiumGoAsan(jirix *jiri.X, testName string, opts ...Opt) (*test.Result, error) {
+	return vanadiumGoSanitizer(jirix, testName, goAsan, opts...)
+}
+
+// vanadiumGoMsan runs Go tests for vanadium projects with the memory
+// sanitizer enabled for their cgo code.
+func vanadiumGoMsan(jirix *jiri.X, testName string, opts ...Opt) (*test.Result, error) {
+	return vanadiumGoSanitizer(jirix, testName, goMsan, opts...)
+}
+
+// vanadiumGoSanitizer runs Go tests for vanadium projects with the
+// given sanitizer enabled.
+func vanadiumGoSanitizer(jirix *jiri.X, testName string, sanitizer goSanitizer, opts ...Opt) (_ *test.Result, e error) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	if !sanitizer.supports(platform) {
+		return nil, newInternalError(fmt.Errorf("go test %s is not supported on %s", sanitizer.flag, platform), "Init")
+	}
+
+	// Initialize the test.
+	cleanup, err := initTest(jirix, testName, []string{"v23:base"})
+	if err != nil {
+		return nil, newInternalError(err, "Init")
+	}
+	defer collect.Error(func() error { return cleanup() }, &e)
+
+	// The sanitizers require cgo code to be compiled with clang.
+	cc, cxx, err := clangToolchain(jirix.Env())
+	if err != nil {
+		return nil, newInternalError(err, "Toolchain")
+	}
+	fmt.Fprintf(jirix.Stdout(), "CC = %q, CXX = %q\n", cc, cxx)
+
+	pkgs, err := validateAgainstDefaultPackages(jirix, opts, []string{"v.io/..."})
+	if err != nil {
+		return nil, err
+	}
+	partPkgs, err := identifyPackagesToTest(jirix, testName, opts, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	exclusions, err := loadExclusions(jirix, goTestExclusionKind, sanitizer.kind)
+	if err != nil {
+		return nil, newInternalError(err, "LoadExclusions")
+	}
+	clocks, err := loadClockSettings(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadClockSettings")
+	}
+	limits, err := loadResourceLimits(jirix)
+	if err != nil {
+		return nil, newInternalError(err, "LoadResourceLimits")
+	}
+	args := argsOpt([]string{sanitizer.flag})
+	timeout := timeoutOpt("30m")
+	suffix := suffixOpt(genTestNameSuffix(sanitizer.suffix))
+	clangX := newTestContext(jirix, map[string]string{"CC": cc, "CXX": cxx})
+	goOpts := []goTestOpt{args, timeout, suffix, exclusionsOpt(exclusions), clocksOpt(clocks), limits.withoutMemoryLimit(), getNumWorkersOpt(opts), partPkgs}
+	return goTestAndReport(clangX, testName, append(goOpts, stressOptsFromOpts(opts)...)...)
+}
+
+// isClang returns whether the given compiler command is clang.
+func isClang(compiler string) bool {
+	return strings.HasPrefix(filepath.Base(compiler), "clang")
+}
+
+// clangToolchain returns the clang C and C++ compilers to build cgo code
+// with. The compilers identified by the CC and CXX variables of the
+// given environment are used if they are clang, otherwise clang and
+// clang++ are looked up in the PATH.
+func clangToolchain(env map[string]string) (string, string, error) {
+	compilers := []struct{ variable, fallback string }{
+		{"CC", "clang"},
+		{"CXX", "clang++"},
+	}
+	result := []string{}
+	for _, c := range compilers {
+		compiler := env[c.variable]
+		if !isClang(compiler) {
+			compiler = c.fallback
+		}
+		if !filepath.IsAbs(compiler) {
+			path, err := lookpath.Look(env, compiler)
+			if err != nil {
+				return "", "", fmt.Errorf("%s is required to run Go tests with sanitizers: %v", compiler, err)
+			}
+			compiler = path
+		}
+		result = append(result, compiler)
+	}
+	return result[0], result[1], nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoSanitizerSupports(t *testing.T) {
+	if !goMsan.supports("linux/amd64") {
+		t.Errorf("msan should support linux/amd64")
+	}
+	if goAsan.supports("darwin/amd64") {
+		t.Errorf("asan should not support darwin/amd64")
+	}
+}
+
+func TestClangToolchain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"clang", "clang++", "clang-3.8", "gcc"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, os.FileMode(0755)); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+	tests := []struct {
+		env     map[string]string
+		cc, cxx string
+	}{
+		{
+			map[string]string{"PATH": dir},
+			filepath.Join(dir, "clang"), filepath.Join(dir, "clang++"),
+		},
+		{
+			map[string]string{"PATH": dir, "CC": "gcc", "CXX": "g++"},
+			filepath.Join(dir, "clang"), filepath.Join(dir, "clang++"),
+		},
+		{
+			map[string]string{"PATH": dir, "CC": "clang-3.8", "CXX": "/opt/bin/clang++"},
+			filepath.Join(dir, "clang-3.8"), "/opt/bin/clang++",
+		},
+	}
+	for _, test := range tests {
+		cc, cxx, err := clangToolchain(test.env)
+		if err != nil {
+			t.Errorf("%v: %v", test.env, err)
+			continue
+		}
+		if cc != test.cc || cxx != test.cxx {
+			t.Errorf("%v: want %q %q, got %q %q", test.env, test.cc, test.cxx, cc, cxx)
+		}
+	}
+	if _, _, err := clangToolchain(map[string]string{"PATH": filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("clangToolchain() did not fail without clang")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+)
+
+// UntilFailureOpt is an option that specifies the maximum number of
+// times the Go tests are run in a row, stopping at the first run with a
+// failure, to reproduce flaky tests.
+type UntilFailureOpt int
+
+func (UntilFailureOpt) Opt() {}
+
+// TestFuncsOpt is an option that specifies a regular expression that
+// selects the Go test functions to run.
+type TestFuncsOpt string
+
+func (TestFuncsOpt) Opt() {}
+
+type untilFailureOpt int
+type testFuncsOpt string
+
+func (untilFailureOpt) goTestOpt() {}
+func (testFuncsOpt) goTestOpt()    {}
+
+// stressOptsFromOpts returns the Go test options that correspond to the
+// stress mode options among the given ones.
+func stressOptsFromOpts(opts []Opt) []goTestOpt {
+	result := []goTestOpt{}
+	for _, opt := range opts {
+		switch typedOpt := opt.(type) {
+		case UntilFailureOpt:
+			result = append(result, untilFailureOpt(typedOpt))
+		case TestFuncsOpt:
+			result = append(result, testFuncsOpt(typedOpt))
+		}
+	}
+	return result
+}
+
+// filterTestFuncs returns the given map from packages to test functions
+// restricted to the functions matched by the given regular expression,
+// along with the packages that have any such functions.
+func filterTestFuncs(pkgs []string, funcs map[string][]string, re *regexp.Regexp) ([]string, map[string][]string) {
+	filteredPkgs, filteredFuncs := []string{}, map[string][]string{}
+	for _, pkg := range pkgs {
+		for _, fn := range funcs[pkg] {
+			if re.MatchString(fn) {
+				filteredFuncs[pkg] = append(filteredFuncs[pkg], fn)
+			}
+		}
+		if len(filteredFuncs[pkg]) > 0 {
+			filteredPkgs = append(filteredPkgs, pkg)
+		}
+	}
+	return filteredPkgs, filteredFuncs
+}
+
+// stressFailure records the output of a test case that failed in a run
+// of the stress mode.
+type stressFailure struct {
+	iteration int
+	name      string
+	output    string
+}
+
+// stressFailures returns the failures of the test cases of the given
+// suites.
+func stressFailures(iteration int, suites []xunit.TestSuite) []stressFailure {
+	failures := []stressFailure{}
+	for _, suite := range suites {
+		for _, c := range suite.AllCases() {
+			output := ""
+			for _, f := range c.Failures {
+				output += f.Message + "\n" + f.Data
+			}
+			for _, e := range c.Errors {
+				output += e.Message + "\n" + e.Data
+			}
+			if output != "" {
+				failures = append(failures, stressFailure{iteration: iteration, name: c.Classname + "." + c.Name, output: output})
+			}
+		}
+	}
+	return failures
+}
+
+// printStressSummary prints the failure rate of the given number of runs
+// and the outputs of the given failures to w.
+func printStressSummary(w io.Writer, runs, failedRuns int, failures []stressFailure) {
+	fmt.Fprintf(w, "##### Stress summary: %d of %d runs failed (%.1f%%) #####\n", failedRuns, runs, 100*float64(failedRuns)/float64(runs))
+	for _, f := range failures {
+		fmt.Fprintf(w, "--- %s failed in run %d:\n%s\n", f.name, f.iteration, f.output)
+	}
+}
+
+// goTestUntilFailure runs goTest with the given options up to the given
+// number of times, stopping at the first run with a failure, and prints
+// a summary of the failure rate and the failures. It returns the results
+// of the last run.
+func goTestUntilFailure(jirix *jiri.X, testName string, iterations int, opts ...goTestOpt) (*test.Result, []xunit.TestSuite, error) {
+	runOpts := []goTestOpt{}
+	for _, opt := range opts {
+		if _, ok := opt.(untilFailureOpt); !ok {
+			runOpts = append(runOpts, opt)
+		}
+	}
+	var result *test.Result
+	var suites []xunit.TestSuite
+	runs, failedRuns, failures := 0, 0, []stressFailure{}
+	for runs < iterations {
+		runs++
+		fmt.Fprintf(jirix.Stdout(), "##### Stress run %d of %d #####\n", runs, iterations)
+		var err error
+		if result, suites, err = goTest(jirix, testName, runOpts...); err != nil {
+			return nil, nil, err
+		}
+		if result.Status != test.Passed {
+			failedRuns++
+			failures = append(failures, stressFailures(runs, suites)...)
+			break
+		}
+	}
+	printStressSummary(jirix.Stdout(), runs, failedRuns, failures)
+	return result, suites, nil
+}
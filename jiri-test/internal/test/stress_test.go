@@ -0,0 +1,74 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"v.io/x/devtools/internal/xunit"
+)
+
+func TestFilterTestFuncs(t *testing.T) {
+	pkgs := []string{"v.io/x/a", "v.io/x/b", "v.io/x/c"}
+	funcs := map[string][]string{
+		"v.io/x/a": []string{"TestMount", "TestMountTable", "TestGlob"},
+		"v.io/x/b": []string{"TestGlob"},
+		"v.io/x/c": []string{"TestMountOptions"},
+	}
+	gotPkgs, gotFuncs := filterTestFuncs(pkgs, funcs, regexp.MustCompile("^TestMount"))
+	if want := []string{"v.io/x/a", "v.io/x/c"}; !reflect.DeepEqual(gotPkgs, want) {
+		t.Fatalf("want %v, got %v", want, gotPkgs)
+	}
+	want := map[string][]string{
+		"v.io/x/a": []string{"TestMount", "TestMountTable"},
+		"v.io/x/c": []string{"TestMountOptions"},
+	}
+	if !reflect.DeepEqual(gotFuncs, want) {
+		t.Fatalf("want %v, got %v", want, gotFuncs)
+	}
+}
+
+func TestStressSummary(t *testing.T) {
+	suites := []xunit.TestSuite{
+		{
+			Name: "v.io/x/a",
+			Cases: []xunit.TestCase{
+				{Classname: "v.io/x/a", Name: "TestMount"},
+				{Classname: "v.io/x/a", Name: "TestGlob", Failures: []xunit.Failure{{Message: "failed", Data: "glob_test.go:10: bad"}}},
+			},
+		},
+		{
+			Name: "v.io/x/b",
+			Cases: []xunit.TestCase{
+				{Classname: "v.io/x/b", Name: "TestMain", Errors: []xunit.Error{{Message: "error", Data: "panic: oops"}}},
+			},
+		},
+	}
+	failures := stressFailures(4, suites)
+	want := []stressFailure{
+		{iteration: 4, name: "v.io/x/a.TestGlob", output: "failed\nglob_test.go:10: bad"},
+		{iteration: 4, name: "v.io/x/b.TestMain", output: "error\npanic: oops"},
+	}
+	if !reflect.DeepEqual(failures, want) {
+		t.Fatalf("want %v, got %v", want, failures)
+	}
+
+	var out bytes.Buffer
+	printStressSummary(&out, 4, 1, failures)
+	wantOut := `##### Stress summary: 1 of 4 runs failed (25.0%) #####
+--- v.io/x/a.TestGlob failed in run 4:
+failed
+glob_test.go:10: bad
+--- v.io/x/b.TestMain failed in run 4:
+error
+panic: oops
+`
+	if got := out.String(); got != wantOut {
+		t.Fatalf("want %q, got %q", wantOut, got)
+	}
+}
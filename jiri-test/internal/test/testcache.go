@@ -0,0 +1,191 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"v.io/jiri"
+)
+
+const (
+	// testCacheDirName is the name of the directory under V23_BIN_DIR
+	// that holds the cached test binaries.
+	testCacheDirName = "testcache"
+
+	// testCacheListFormat is the "go list" format that prints, for each
+	// package, its import path, whether it is a standard package, its
+	// directory and the names of its source files, separated by tabs.
+	testCacheListFormat = "{{.ImportPath}}\t{{.Standard}}\t{{.Dir}}\t" +
+		`{{join .GoFiles " "}} {{join .CgoFiles " "}} {{join .CFiles " "}} {{join .HFiles " "}} {{join .SFiles " "}} ` +
+		`{{join .TestGoFiles " "}} {{join .XTestGoFiles " "}}`
+)
+
+// testCache caches the compiled test binaries of packages, keyed by a
+// hash of the sources of the packages and their dependencies, so that
+// unchanged packages do not need to be rebuilt to be tested again.
+type testCache struct {
+	dir string
+	// toolchain identifies the version of the go tool, the platform it
+	// builds for, and the profiles, automatic build tags and environment
+	// variables that "jiri go" builds with.
+	toolchain string
+	// env holds the environment that "jiri go" runs the go tool in,
+	// which the cached test binaries are run in as well, so that they
+	// find the libraries of the profiles.
+	env map[string]string
+}
+
+// newTestCache returns the test binary cache under the directory
+// identified by the V23_BIN_DIR environment variable, or nil if the
+// variable is not set.
+func newTestCache(jirix *jiri.X) (*testCache, error) {
+	binDir := jirix.Env()["V23_BIN_DIR"]
+	if binDir == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(binDir, testCacheDirName)
+	var runEnv, out bytes.Buffer
+	if err := jirix.NewSeq().MkdirAll(dir, 0755).
+		Capture(&runEnv, nil).Verbose(false).Run("jiri", "go", "-print-run-env", "version").
+		Capture(&out, nil).Verbose(false).Last("jiri", "go", "env", "GOOS", "GOARCH", "CGO_ENABLED"); err != nil {
+		return nil, err
+	}
+	env, toolchain := parseRunEnv(runEnv.String())
+	return &testCache{dir: dir, toolchain: toolchain + out.String(), env: env}, nil
+}
+
+// runEnvRE matches the environment variables printed by
+// "jiri go -print-run-env".
+var runEnvRE = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// buildEnvVar returns whether the given environment variable affects
+// the test binaries that the go tool builds.
+func buildEnvVar(name string) bool {
+	switch name {
+	case "AR", "CC", "CXX", "DYLD_LIBRARY_PATH", "LD_LIBRARY_PATH", "PKG_CONFIG_PATH":
+		return true
+	}
+	return strings.HasPrefix(name, "CGO_") || strings.HasPrefix(name, "GO")
+}
+
+// parseRunEnv parses the output of "jiri go -print-run-env version". It
+// returns the environment the go tool is run in, and the part of the
+// output that identifies how test binaries are built, which consists of
+// the merged profiles, the automatic build tags, the version of the go
+// tool and the environment variables that affect the build. Other
+// environment variables, which can differ between runs, are left out.
+func parseRunEnv(output string) (map[string]string, string) {
+	env, toolchain := map[string]string{}, []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if match := runEnvRE.FindStringSubmatch(line); match != nil {
+			env[match[1]] = match[2]
+			if !buildEnvVar(match[1]) {
+				continue
+			}
+		}
+		if line != "" {
+			toolchain = append(toolchain, line)
+		}
+	}
+	sort.Strings(toolchain)
+	return env, strings.Join(toolchain, "\n") + "\n"
+}
+
+// cacheableTestArgs returns whether the test binaries built with the
+// given arguments of "go test" can be cached. These are the arguments
+// that only affect how the binaries are built.
+func cacheableTestArgs(args []string) bool {
+	for _, arg := range args {
+		if arg != "-msan" && arg != "-race" {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the path of the cached test binary of the given package
+// built with the given build flags, and the directory of the package, in
+// which the binary is to be run. The binary does not exist yet if it has
+// not been cached before.
+func (c *testCache) lookup(jirix *jiri.X, pkg string, buildFlags []string) (string, string, error) {
+	args := []string{"go", "list", "-deps", "-test", "-f", testCacheListFormat}
+	args = append(args, buildFlags...)
+	args = append(args, pkg)
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, nil).Verbose(false).Last("jiri", args...); err != nil {
+		return "", "", err
+	}
+	key, dir, err := testCacheKey(pkg, c.toolchain, buildFlags, out.String(), ioutil.ReadFile)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(c.dir, key+".test"), dir, nil
+}
+
+// testCacheKey computes the cache key of the test binary of the given
+// package from the toolchain, the build flags and the output of "go list"
+// in the testCacheListFormat for the package and its dependencies. The
+// key covers the contents of the source files of all packages except the
+// standard ones, which are identified by the toolchain. It also returns
+// the directory of the package.
+func testCacheKey(pkg, toolchain string, buildFlags []string, listOutput string, readFile func(string) ([]byte, error)) (string, string, error) {
+	lines := strings.Split(strings.TrimSpace(listOutput), "\n")
+	sort.Strings(lines)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", pkg, toolchain, strings.Join(buildFlags, " "))
+	dir := ""
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return "", "", fmt.Errorf("unexpected go list output: %q", line)
+		}
+		importPath, standard, pkgDir, files := fields[0], fields[1], fields[2], strings.Fields(fields[3])
+		if importPath == pkg {
+			dir = pkgDir
+		}
+		fmt.Fprintf(h, "%s\n", importPath)
+		if standard == "true" {
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			data, err := readFile(filepath.Join(pkgDir, file))
+			if err != nil {
+				return "", "", err
+			}
+			fmt.Fprintf(h, "%s %x\n", file, sha256.Sum256(data))
+		}
+	}
+	if dir == "" {
+		return "", "", fmt.Errorf("package %s not found in go list output", pkg)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), dir, nil
+}
+
+// cachedTestArgs returns the arguments for running a cached test binary
+// directly that correspond to running "go test -v" with the given timeout
+// and -run expression, followed by the given arguments for the binary.
+func cachedTestArgs(timeout, testsExpr string, nonTestArgs []string) []string {
+	args := []string{"-test.v", "-test.timeout", timeout, "-test.run", testsExpr}
+	return append(args, nonTestArgs...)
+}
+
+// cachedTestSummary returns the line that "go test" prints after running
+// the tests of a package, which a directly run test binary does not. The
+// line identifies the package to go2xunit.
+func cachedTestSummary(pkg string, passed bool, seconds float64) string {
+	if passed {
+		return fmt.Sprintf("ok  \t%s\t%.3fs\n", pkg, seconds)
+	}
+	return fmt.Sprintf("FAIL\t%s\t%.3fs\n", pkg, seconds)
+}
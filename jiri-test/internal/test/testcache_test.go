@@ -0,0 +1,119 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestCacheKey(t *testing.T) {
+	files := map[string]string{
+		"/src/v.io/x/foo/foo.go":      "package foo",
+		"/src/v.io/x/foo/foo_test.go": "package foo",
+		"/src/v.io/x/bar/bar.go":      "package bar",
+	}
+	readFile := func(path string) ([]byte, error) {
+		data, ok := files[filepath.ToSlash(path)]
+		if !ok {
+			return nil, fmt.Errorf("%v not found", path)
+		}
+		return []byte(data), nil
+	}
+	output := "v.io/x/foo\tfalse\t/src/v.io/x/foo\tfoo.go      foo_test.go \n" +
+		"fmt\ttrue\t/goroot/src/fmt\tformat.go print.go      \n" +
+		"v.io/x/bar\tfalse\t/src/v.io/x/bar\tbar.go      \n"
+	key := func(buildFlags ...string) string {
+		k, dir, err := testCacheKey("v.io/x/foo", "go version go1.6 linux/amd64", buildFlags, output, readFile)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if got, want := dir, "/src/v.io/x/foo"; got != want {
+			t.Fatalf("want dir %q, got %q", want, got)
+		}
+		return k
+	}
+
+	// The key does not depend on the order of the packages, but changes
+	// when the build flags or the sources of a dependency change.
+	orig := key("-tags=leveldb")
+	output = "v.io/x/bar\tfalse\t/src/v.io/x/bar\tbar.go      \n" +
+		"v.io/x/foo\tfalse\t/src/v.io/x/foo\tfoo.go      foo_test.go \n" +
+		"fmt\ttrue\t/goroot/src/fmt\tformat.go print.go      \n"
+	if got := key("-tags=leveldb"); got != orig {
+		t.Errorf("key changed after reordering packages")
+	}
+	if got := key("-tags=leveldb", "-race"); got == orig {
+		t.Errorf("key did not change after adding -race")
+	}
+	files["/src/v.io/x/bar/bar.go"] = "package bar // changed"
+	if got := key("-tags=leveldb"); got == orig {
+		t.Errorf("key did not change after changing a dependency")
+	}
+
+	if _, _, err := testCacheKey("v.io/x/baz", "", nil, output, readFile); err == nil {
+		t.Errorf("computing the key of a missing package did not fail")
+	}
+}
+
+func TestCacheableTestArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"-race"}, true},
+		{[]string{"-race", "-bench", "."}, false},
+		{[]string{"-run", "TestFoo"}, false},
+	}
+	for _, test := range tests {
+		if got := cacheableTestArgs(test.args); got != test.want {
+			t.Errorf("cacheableTestArgs(%v): want %v, got %v", test.args, test.want, got)
+		}
+	}
+	if got, want := cachedTestSummary("v.io/x/foo", true, 1.5), "ok  \tv.io/x/foo\t1.500s\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := cachedTestSummary("v.io/x/foo", false, 0.25), "FAIL\tv.io/x/foo\t0.250s\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestParseRunEnv(t *testing.T) {
+	output := func(cgoFlags, buildNumber, tags string) string {
+		return "Merged profiles: [v23:base jiri]\n" +
+			"Merge policies: +CCFLAGS,+CGO_CFLAGS,:GOPATH\n" +
+			"BUILD_NUMBER=" + buildNumber + "\n" +
+			"CGO_CFLAGS=" + cgoFlags + "\n" +
+			"LD_LIBRARY_PATH=/profiles/lib\n" +
+			"Automatic build tags: " + tags + "\n" +
+			"\n/goroot/bin/go version\n" +
+			"go version go1.6 linux/amd64\n"
+	}
+	env, orig := parseRunEnv(output("-I/profiles/include", "1", "leveldb"))
+	for name, want := range map[string]string{
+		"BUILD_NUMBER":    "1",
+		"CGO_CFLAGS":      "-I/profiles/include",
+		"LD_LIBRARY_PATH": "/profiles/lib",
+	} {
+		if got := env[name]; got != want {
+			t.Errorf("%v: got %q, want %q", name, got, want)
+		}
+	}
+
+	// The toolchain does not depend on the environment variables that
+	// do not affect the build, but changes when the CGO flags or the
+	// automatic build tags change.
+	if _, got := parseRunEnv(output("-I/profiles/include", "2", "leveldb")); got != orig {
+		t.Errorf("toolchain changed after changing the build number")
+	}
+	if _, got := parseRunEnv(output("-I/other/include", "1", "leveldb")); got == orig {
+		t.Errorf("toolchain did not change after changing the CGO flags")
+	}
+	if _, got := parseRunEnv(output("-I/profiles/include", "1", "leveldb mojo")); got == orig {
+		t.Errorf("toolchain did not change after changing the build tags")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"v.io/jiri"
+)
+
+// minTestJSONGoVersion is the minor version of the first Go release
+// whose "go test" supports the -json flag.
+const minTestJSONGoVersion = 10
+
+var goVersionRE = regexp.MustCompile(`go version go1\.(\d+)`)
+
+// testEvent records an action of the stream of JSON events emitted by
+// "go test -json". ImportPath and FailedBuild identify the packages
+// whose build failed, and are only set by newer versions of Go.
+type testEvent struct {
+	Action      string
+	Package     string
+	ImportPath  string
+	Test        string
+	Output      string
+	FailedBuild string
+}
+
+// goTestFailure classifies the failures of "go test".
+type goTestFailure int
+
+const (
+	noFailure goTestFailure = iota
+	buildFailure
+	setupFailure
+	testFailure
+)
+
+// supportsTestJSON returns whether the go tool used by jiri supports
+// the -json flag of "go test". Development versions of Go are assumed
+// to support it.
+func supportsTestJSON(jirix *jiri.X) bool {
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, nil).Verbose(false).Last("jiri", "go", "version"); err != nil {
+		return false
+	}
+	return goVersionSupportsTestJSON(out.String())
+}
+
+// goVersionSupportsTestJSON returns whether the go tool that printed
+// the given output of "go version" supports the -json flag of
+// "go test".
+func goVersionSupportsTestJSON(version string) bool {
+	if strings.Contains(version, "go version devel") {
+		return true
+	}
+	matches := goVersionRE.FindStringSubmatch(version)
+	if matches == nil {
+		return false
+	}
+	minor, err := strconv.Atoi(matches[1])
+	return err == nil && minor >= minTestJSONGoVersion
+}
+
+// parseTestEvents parses the given output of "go test -json" and
+// returns its events, along with the plain text output the events
+// record. Lines that are not JSON events, such as the build errors
+// printed by older versions of Go, are kept in the text output
+// unchanged, so that the text output matches the output of "go test -v".
+func parseTestEvents(out string) ([]testEvent, string) {
+	events := []testEvent{}
+	var text bytes.Buffer
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if line == "" {
+			continue
+		}
+		var event testEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil || event.Action == "" {
+			text.WriteString(line)
+			continue
+		}
+		events = append(events, event)
+		if event.Action == "output" || event.Action == "build-output" {
+			text.WriteString(event.Output)
+		}
+	}
+	return events, text.String()
+}
+
+// classifyTestEvents classifies the failure of "go test" for the given
+// package from the given events. It returns false if the events do not
+// include the final action of the package, in which case the failure
+// cannot be classified from them.
+func classifyTestEvents(events []testEvent, pkg string) (goTestFailure, bool) {
+	built, setup := true, true
+	for _, event := range events {
+		// Dependencies of the package report their build failures
+		// under their own import paths.
+		if event.Action == "build-fail" {
+			built = false
+		}
+		if event.Package != pkg || event.Test != "" {
+			continue
+		}
+		switch event.Action {
+		case "output":
+			switch {
+			case strings.HasSuffix(event.Output, "[build failed]\n"):
+				built = false
+			case strings.HasSuffix(event.Output, "[setup failed]\n"):
+				setup = false
+			}
+		case "pass", "skip":
+			return noFailure, true
+		case "fail":
+			switch {
+			case !built || event.FailedBuild != "":
+				return buildFailure, true
+			case !setup:
+				return setupFailure, true
+			default:
+				return testFailure, true
+			}
+		}
+	}
+	return noFailure, false
+}
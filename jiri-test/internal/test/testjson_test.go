@@ -0,0 +1,98 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGoVersionSupportsTestJSON(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"go version go1.9.2 linux/amd64\n", false},
+		{"go version go1.10 darwin/amd64\n", true},
+		{"go version go1.24.1 linux/arm64\n", true},
+		{"go version devel +5a2f4f3 Fri Jan 5 10:00:00 2018 +0000 linux/amd64\n", true},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := goVersionSupportsTestJSON(test.version); got != test.want {
+			t.Errorf("goVersionSupportsTestJSON(%q): want %v, got %v", test.version, test.want, got)
+		}
+	}
+}
+
+func TestParseTestEvents(t *testing.T) {
+	out := `# v.io/x/foo
+foo.go:3: undefined: bar
+{"Action":"run","Package":"v.io/x/foo","Test":"TestFoo"}
+{"Action":"output","Package":"v.io/x/foo","Test":"TestFoo","Output":"=== RUN   TestFoo\n"}
+{"Action":"output","Package":"v.io/x/foo","Test":"TestFoo","Output":"--- PASS: TestFoo (0.00s)\n"}
+{"Action":"pass","Package":"v.io/x/foo","Test":"TestFoo"}
+{"Action":"output","Package":"v.io/x/foo","Output":"ok  \tv.io/x/foo\t0.01s\n"}
+{"Action":"pass","Package":"v.io/x/foo"}
+`
+	events, text := parseTestEvents(out)
+	if got, want := len(events), 6; got != want {
+		t.Fatalf("want %v events, got %v", want, got)
+	}
+	want := "# v.io/x/foo\nfoo.go:3: undefined: bar\n=== RUN   TestFoo\n--- PASS: TestFoo (0.00s)\nok  \tv.io/x/foo\t0.01s\n"
+	if text != want {
+		t.Fatalf("want %q, got %q", want, text)
+	}
+}
+
+func TestClassifyTestEvents(t *testing.T) {
+	const pkg = "v.io/x/foo"
+	tests := []struct {
+		out     string
+		failure goTestFailure
+		ok      bool
+	}{
+		// A passing package.
+		{`{"Action":"pass","Package":"v.io/x/foo"}`, noFailure, true},
+		// A failing test.
+		{`{"Action":"fail","Package":"v.io/x/foo","Test":"TestFoo"}
+{"Action":"output","Package":"v.io/x/foo","Output":"FAIL\tv.io/x/foo\t0.01s\n"}
+{"Action":"fail","Package":"v.io/x/foo"}`, testFailure, true},
+		// A build failure reported by older versions of Go, whose build
+		// errors are not JSON events.
+		{`# v.io/x/foo
+foo.go:3: undefined: bar
+{"Action":"output","Package":"v.io/x/foo","Output":"FAIL\tv.io/x/foo [build failed]\n"}
+{"Action":"fail","Package":"v.io/x/foo"}`, buildFailure, true},
+		// A build failure of a dependency reported by newer versions of Go.
+		{`{"ImportPath":"v.io/x/bar","Action":"build-output","Output":"# v.io/x/bar\n"}
+{"ImportPath":"v.io/x/bar","Action":"build-fail"}
+{"Action":"fail","Package":"v.io/x/foo","FailedBuild":"v.io/x/bar"}`, buildFailure, true},
+		// A setup failure.
+		{`{"Action":"output","Package":"v.io/x/foo","Output":"FAIL\tv.io/x/foo [setup failed]\n"}
+{"Action":"fail","Package":"v.io/x/foo"}`, setupFailure, true},
+		// The final action of the package is missing.
+		{"go: cannot find main module\n", noFailure, false},
+	}
+	for _, test := range tests {
+		events, _ := parseTestEvents(test.out)
+		failure, ok := classifyTestEvents(events, pkg)
+		if failure != test.failure || ok != test.ok {
+			t.Errorf("classifyTestEvents(%q): want (%v, %v), got (%v, %v)", test.out, test.failure, test.ok, failure, ok)
+		}
+	}
+
+	// The output heuristic mistakes test failures whose output starts
+	// with the name of the package for build failures.
+	events, text := parseTestEvents(`{"Action":"output","Package":"v.io/x/foo","Output":"# v.io/x/foo/internal\n"}
+{"Action":"fail","Package":"v.io/x/foo"}
+`)
+	if isBuildFailure(errors.New("exit status 1"), text, pkg, events) {
+		t.Errorf("test failure classified as a build failure")
+	}
+	if !isBuildFailure(errors.New("exit status 1"), "# v.io/x/foo\nfoo.go:3: undefined: bar\n", pkg, nil) {
+		t.Errorf("build failure not classified as a build failure")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
+	"v.io/x/devtools/tooldata"
+)
+
+func init() {
+	// The test is added here rather than in the initializer of
+	// testFunctions, because ValidateConfig lists the tests, which would
+	// make the initialization of testFunctions depend on itself.
+	testFunctions["vanadium-tools-config"] = vanadiumToolsConfig
+}
+
+// ValidateConfig checks that the tools config conforms to its schema
+// and only refers to known tests. The tests implemented by plugins are
+// only known if the plugins have been loaded.
+func ValidateConfig(jirix *jiri.X) error {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return err
+	}
+	tests, err := ListTests()
+	if err != nil {
+		return err
+	}
+	return config.CheckTests(tests)
+}
+
+// vanadiumToolsConfig validates the tools config, so that mistakes in
+// changes to the config are caught by presubmit.
+func vanadiumToolsConfig(jirix *jiri.X, testName string, _ ...Opt) (_ *test.Result, e error) {
+	// Initialize the test.
+	cleanup, err := initTest(jirix, testName, nil)
+	if err != nil {
+		return nil, newInternalError(err, "Init")
+	}
+	defer collect.Error(func() error { return cleanup() }, &e)
+
+	if err := ValidateConfig(jirix); err != nil {
+		report := fmt.Sprintf("%v\n", err)
+		if err := xunit.CreateFailureReport(jirix, testName, "ValidateConfig", "CheckToolsConfig", "tools config validation failure", report); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(jirix.Stderr(), "%v", report)
+		return &test.Result{Status: test.Failed}, nil
+	}
+	return &test.Result{Status: test.Passed}, nil
+}
@@ -18,6 +18,7 @@ import (
 	"v.io/jiri/project"
 	"v.io/jiri/tool"
 	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/internal/xunit"
 	jiriTest "v.io/x/devtools/jiri-test/internal/test"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
@@ -25,8 +26,12 @@ import (
 )
 
 var (
+	bigQueryKeyFileFlag  string
+	bigQueryTableFlag    string
 	blessingsRootFlag    string
+	changedFilesFlag     string
 	cleanGoFlag          bool
+	jsonFlag             bool
 	mockTestFilePaths    string
 	mockTestFileContents string
 	namespaceRootFlag    string
@@ -37,21 +42,34 @@ var (
 	oauthBlesserFlag     string
 	adminRoleFlag        string
 	publisherRoleFlag    string
+	testFuncsFlag        string
+	uniqueReportsFlag    bool
+	untilFailureFlag     int
 	readerFlags          profilescmdline.ReaderFlagValues
 )
 
 func init() {
+	for _, cmd := range []*cmdline.Command{cmdTestProject, cmdTestRun} {
+		cmd.Flags.StringVar(&bigQueryKeyFileFlag, "bigquery-key-file", "", "The JSON key file of the service account used to export test results to BigQuery.")
+		cmd.Flags.StringVar(&bigQueryTableFlag, "bigquery-table", "", "The BigQuery table, identified as <project>:<dataset>.<table>, to stream the results of each test case into. If not set, the results are not exported.")
+		cmd.Flags.BoolVar(&uniqueReportsFlag, "unique-reports", false, "Add a suffix that identifies this invocation to the names of the xUnit reports, so that multiple invocations on the same host do not overwrite each other's reports.")
+	}
 	cmdTestRun.Flags.StringVar(&blessingsRootFlag, "blessings-root", "dev.v.io", "The blessings root.")
+	cmdTestRun.Flags.StringVar(&changedFilesFlag, "changed-files", "", "Comma-separated list of the files changed by the code under test, either absolute or relative to JIRI_ROOT. When set, Go tests whose packages do not depend on any of the changed files are skipped.")
 	cmdTestRun.Flags.StringVar(&namespaceRootFlag, "v23.namespace.root", "/ns.dev.v.io:8101", "The namespace root.")
 	cmdTestRun.Flags.IntVar(&numWorkersFlag, "num-test-workers", runtime.NumCPU(), "Set the number of test workers to use; use 1 to serialize all tests.")
 	cmdTestRun.Flags.Lookup("num-test-workers").DefValue = "<runtime.NumCPU()>"
 	cmdTestRun.Flags.StringVar(&outputDirFlag, "output-dir", "", "Directory to output test results into.")
 	cmdTestRun.Flags.IntVar(&partFlag, "part", -1, "Specify which part of the test to run.")
 	cmdTestRun.Flags.StringVar(&pkgsFlag, "pkgs", "", "Comma-separated list of Go package expressions that identify a subset of tests to run; only relevant for Go-based tests. Example usage: jiri test run -pkgs v.io/x/ref vanadium-go-test")
+	cmdTestRun.Flags.StringVar(&testFuncsFlag, "test-funcs", "", "Regular expression that identifies the Go test functions to run; only relevant for Go-based tests. Example usage: jiri test run -pkgs v.io/x/ref/services/... -test-funcs TestMount vanadium-go-test")
+	cmdTestRun.Flags.IntVar(&untilFailureFlag, "until-failure", 0, "Run the Go tests up to the given number of times, stopping at the first run with a failure, and print a summary of the failure rate and the failing outputs; only relevant for Go-based tests.")
 	cmdTestRun.Flags.BoolVar(&cleanGoFlag, "clean-go", true, "Specify whether to remove Go object files and binaries before running the tests. Setting this flag to 'false' may lead to faster Go builds, but it may also result in some source code changes not being reflected in the tests (e.g., if the change was made in a different Go workspace).")
 	cmdTestRun.Flags.StringVar(&mockTestFilePaths, "mock-file-paths", "", "Colon-separated file paths to read when testing presubmit test. This flag is only used when running presubmit end-to-end test.")
 	cmdTestRun.Flags.StringVar(&mockTestFileContents, "mock-file-contents", "", "Colon-separated file contents to check when testing presubmit test. This flag is only used when running presubmit end-to-end test.")
+	cmdTestList.Flags.BoolVar(&jsonFlag, "json", false, "Print the tests and their metadata as JSON.")
 	tool.InitializeRunFlags(&cmdTest.Flags)
+	test.InitializeLogFlags(&cmdTest.Flags, "jiri-test")
 	tool.InitializeProjectFlags(&cmdProjectPoll.Flags)
 	profilescmdline.RegisterReaderFlags(&cmdTest.Flags, &readerFlags, "v23:base", jiri.ProfilesDBDir)
 }
@@ -61,7 +79,7 @@ var cmdTest = &cmdline.Command{
 	Name:     "test",
 	Short:    "Manage vanadium tests",
 	Long:     "Manage vanadium tests.",
-	Children: []*cmdline.Command{cmdProjectPoll, cmdTestProject, cmdTestRun, cmdTestList},
+	Children: []*cmdline.Command{cmdProjectPoll, cmdTestProject, cmdTestRun, cmdTestList, cmdConfigValidate, cmdGenerate, cmdXUnit},
 }
 
 // cmdTestProject represents the "jiri test project" command.
@@ -85,13 +103,17 @@ func runTestProject(jirix *jiri.X, args []string) error {
 		return jirix.UsageErrorf("unexpected number of arguments")
 	}
 	project := args[0]
+	if err := jiriTest.LoadPlugins(jirix); err != nil {
+		return err
+	}
+	configureReports()
 	results, err := jiriTest.RunProjectTests(jirix, nil, []string{project}, optsFromFlags()...)
 	if err != nil {
 		return err
 	}
 	printSummary(jirix, results)
 	for _, result := range results {
-		if result.Status != test.Passed {
+		if result.Status != test.Passed && !result.Unaffected {
 			return cmdline.ErrExitCode(test.FailedExitCode)
 		}
 	}
@@ -113,13 +135,17 @@ func runTestRun(jirix *jiri.X, args []string) error {
 	if len(args) == 0 {
 		return jirix.UsageErrorf("unexpected number of arguments")
 	}
+	if err := jiriTest.LoadPlugins(jirix); err != nil {
+		return err
+	}
+	configureReports()
 	results, err := jiriTest.RunTests(jirix, nil, args, optsFromFlags()...)
 	if err != nil {
 		return err
 	}
 	printSummary(jirix, results)
 	for _, result := range results {
-		if result.Status != test.Passed {
+		if result.Status != test.Passed && !result.Unaffected {
 			return cmdline.ErrExitCode(test.FailedExitCode)
 		}
 	}
@@ -200,6 +226,15 @@ func optsFromFlags() (opts []jiriTest.Opt) {
 		}
 	}
 	opts = append(opts, jiriTest.PkgsOpt(pkgs))
+	if changedFilesFlag != "" {
+		files := []string{}
+		for _, file := range strings.Split(changedFilesFlag, ",") {
+			if len(file) > 0 {
+				files = append(files, file)
+			}
+		}
+		opts = append(opts, jiriTest.ChangedFilesOpt(files))
+	}
 	opts = append(opts,
 		jiriTest.BlessingsRootOpt(blessingsRootFlag),
 		jiriTest.NamespaceRootOpt(namespaceRootFlag),
@@ -207,7 +242,14 @@ func optsFromFlags() (opts []jiriTest.Opt) {
 		jiriTest.OutputDirOpt(outputDirFlag),
 		jiriTest.CleanGoOpt(cleanGoFlag),
 		jiriTest.MergePoliciesOpt(readerFlags.MergePolicies),
+		jiriTest.TargetOpt(readerFlags.Target),
 	)
+	if testFuncsFlag != "" {
+		opts = append(opts, jiriTest.TestFuncsOpt(testFuncsFlag))
+	}
+	if untilFailureFlag > 0 {
+		opts = append(opts, jiriTest.UntilFailureOpt(untilFailureFlag))
+	}
 	if mockTestFilePaths != "" && mockTestFileContents != "" {
 		opts = append(opts, jiriTest.TestPresubmitTestOpt{
 			FilePaths:            strings.Split(mockTestFilePaths, ":"),
@@ -217,6 +259,18 @@ func optsFromFlags() (opts []jiriTest.Opt) {
 	return
 }
 
+// configureReports configures the xUnit reports and registers the report
+// hooks that export the test results as requested by the command-line
+// flags.
+func configureReports() {
+	if uniqueReportsFlag {
+		xunit.SetReportSuffix(xunit.UniqueReportSuffix())
+	}
+	if bigQueryTableFlag != "" {
+		xunit.RegisterReportHook(xunit.BigQueryExporter(bigQueryTableFlag, bigQueryKeyFileFlag))
+	}
+}
+
 func printSummary(jirix *jiri.X, results map[string]*test.Result) {
 	fmt.Fprintf(jirix.Stdout(), "SUMMARY:\n")
 	for name, result := range results {
@@ -239,19 +293,135 @@ var cmdTestList = &cmdline.Command{
 	Runner: jiri.RunnerFunc(runTestList),
 	Name:   "list",
 	Short:  "List vanadium tests",
-	Long:   "List vanadium tests.",
+	Long: `
+List vanadium tests.
+
+Besides the tests built into jiri-test, the list includes the tests implemented
+by the plugins listed in the .jiri_test_plugins files at the roots of the local
+projects. With -v, the descriptions of registered tests and the binaries of
+plugin tests are printed as well.
+
+With -json, the tests are printed as a JSON list that includes, for each test,
+its description and plugin binary, its required profiles, default Go packages
+and timeout, if known, as well as the parts, dependencies and projects that the
+tools config associates with it. The profiles and timeout include those that
+the tools config sets for make tests.
+`,
 }
 
 func runTestList(jirix *jiri.X, _ []string) error {
 	jiriTest.ProfilesDBFilename = readerFlags.DBFilename
+	if err := jiriTest.LoadPlugins(jirix); err != nil {
+		return err
+	}
 	testList, err := jiriTest.ListTests()
 	if err != nil {
 		fmt.Fprintf(jirix.Stderr(), "%v\n", err)
 		return err
 	}
+	if jsonFlag {
+		config, err := tooldata.LoadConfig(jirix)
+		if err != nil {
+			return err
+		}
+		entries, err := testListEntries(config, testList)
+		if err != nil {
+			return err
+		}
+		bytes, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent() failed: %v", err)
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", bytes)
+		return nil
+	}
 	for _, test := range testList {
 		fmt.Fprintf(jirix.Stdout(), "%v\n", test)
+		if spec, ok := jiriTest.LookupTestSpec(test); ok && jirix.Verbose() {
+			fmt.Fprintf(jirix.Stdout(), "  %v\n", spec.Description)
+			if spec.Plugin != "" {
+				fmt.Fprintf(jirix.Stdout(), "  plugin: %v\n", spec.Plugin)
+			}
+		}
+	}
+	return nil
+}
+
+// testListEntry is the JSON representation of a test printed by
+// "jiri test list -json".
+type testListEntry struct {
+	Name             string
+	Description      string                     `json:",omitempty"`
+	Plugin           string                     `json:",omitempty"`
+	Profiles         []string                   `json:",omitempty"`
+	PlatformProfiles []jiriTest.PlatformProfile `json:",omitempty"`
+	DefaultPackages  []string                   `json:",omitempty"`
+	Timeout          string                     `json:",omitempty"`
+	Parts            []string                   `json:",omitempty"`
+	Dependencies     []string                   `json:",omitempty"`
+	Projects         []string                   `json:",omitempty"`
+}
+
+// testListEntries returns the entries describing the given tests.
+func testListEntries(config *tooldata.Config, tests []string) ([]testListEntry, error) {
+	testProjects := map[string][]string{}
+	for _, project := range config.Projects() {
+		for _, test := range config.ProjectTests([]string{project}) {
+			testProjects[test] = append(testProjects[test], project)
+		}
+	}
+	entries := []testListEntry{}
+	for _, test := range tests {
+		entry := testListEntry{
+			Name:         test,
+			Parts:        config.TestParts(test),
+			Dependencies: config.TestDependencies(test),
+			Projects:     testProjects[test],
+		}
+		if spec, ok := jiriTest.LookupTestSpec(test); ok {
+			entry.Description = spec.Description
+			entry.Plugin = spec.Plugin
+		}
+		info, err := jiriTest.LookupTestInfo(config, test)
+		if err != nil {
+			return nil, err
+		}
+		entry.Profiles = info.Profiles
+		entry.PlatformProfiles = info.PlatformProfiles
+		entry.DefaultPackages = info.DefaultPackages
+		if info.Timeout != 0 {
+			entry.Timeout = info.Timeout.String()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// cmdConfigValidate represents the "jiri test config-validate" command.
+var cmdConfigValidate = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runConfigValidate),
+	Name:   "config-validate",
+	Short:  "Validate the tools config",
+	Long: `
+Validate the tools config.
+
+The config is checked for elements and attributes that are not part of its
+schema, such as misspelled ones, and for references to unknown tests in the
+tests of projects, test groups, test dependencies, test parts and make tests.
+The tests implemented by plugins are known as well. The same checks are run by
+the vanadium-tools-config test.
+`,
+}
+
+func runConfigValidate(jirix *jiri.X, _ []string) error {
+	jiriTest.ProfilesDBFilename = readerFlags.DBFilename
+	if err := jiriTest.LoadPlugins(jirix); err != nil {
+		return err
+	}
+	if err := jiriTest.ValidateConfig(jirix); err != nil {
+		return err
 	}
+	fmt.Fprintf(jirix.Stdout(), "The tools config is valid.\n")
 	return nil
 }
 
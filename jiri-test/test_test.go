@@ -6,7 +6,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -102,3 +104,84 @@ func TestTestList(t *testing.T) {
 		t.Fatalf("unexpected output:\ngot\n%v\nwant\n%v", got, want)
 	}
 }
+
+func TestTestListJSON(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	// Point the WORKSPACE environment variable to the fake.
+	oldWorkspace := os.Getenv("WORKSPACE")
+	if err := os.Setenv("WORKSPACE", fake.X.Root); err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.Setenv("WORKSPACE", oldWorkspace)
+
+	// Setup a fake config.
+	config := tooldata.NewConfig(
+		tooldata.ProjectTestsOpt(map[string][]string{
+			"release.go.core":  []string{"vanadium-go-test"},
+			"release.go.x.ref": []string{"vanadium-go-race", "vanadium-go-test"},
+		}),
+		tooldata.TestPartsOpt(map[string][]string{
+			"vanadium-go-race": []string{"v.io/x/ref/services/..."},
+		}),
+		tooldata.MakeTestsOpt(map[string]tooldata.MakeTestSettings{
+			"vanadium-travel-test": {Name: "vanadium-travel-test", Profiles: []string{"v23:extra"}, Timeout: "45m"},
+		}),
+	)
+	if err := tooldata.SaveConfig(fake.X, config); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Check that the tests associated with projects by the config are
+	// listed with their metadata.
+	jsonFlag = true
+	defer func() { jsonFlag = false }()
+	var out bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &out, Stderr: &out})
+	if err := runTestList(fake.X, []string{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var entries []testListEntry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("Unmarshal(%v) failed: %v", out.String(), err)
+	}
+	testList, err := test.ListTests()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(entries), len(testList); got != want {
+		t.Fatalf("want %v entries, got %v", want, got)
+	}
+	for _, entry := range entries {
+		switch entry.Name {
+		case "vanadium-go-race":
+			want := testListEntry{
+				Name:            "vanadium-go-race",
+				Profiles:        []string{"v23:base"},
+				DefaultPackages: []string{"v.io/..."},
+				Timeout:         "30m0s",
+				Parts:           []string{"v.io/x/ref/services/..."},
+				Projects:        []string{"release.go.x.ref"},
+			}
+			if !reflect.DeepEqual(entry, want) {
+				t.Errorf("want %#v, got %#v", want, entry)
+			}
+		case "vanadium-java-test":
+			if got, want := entry.PlatformProfiles, []test.PlatformProfile{{Profile: "v23:java", Unsupported: []string{"windows"}}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		case "vanadium-travel-test":
+			if got, want := entry.Profiles, []string{"v23:base", "v23:nodejs", "v23:extra"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+			if got, want := entry.Timeout, "45m0s"; got != want {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		case "vanadium-go-test":
+			if got, want := entry.Projects, []string{"release.go.core", "release.go.x.ref"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		}
+	}
+}
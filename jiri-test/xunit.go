@@ -0,0 +1,122 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/xunit"
+	"v.io/x/lib/cmdline"
+)
+
+var (
+	xunitOutputFlag string
+)
+
+func init() {
+	cmdXUnitMerge.Flags.StringVar(&xunitOutputFlag, "o", "", "The file to write the merged report to. The report is written to stdout if empty.")
+}
+
+// cmdXUnit represents the "jiri test xunit" command.
+var cmdXUnit = &cmdline.Command{
+	Name:     "xunit",
+	Short:    "Manipulate xUnit test reports",
+	Long:     "Manipulate xUnit test reports.",
+	Children: []*cmdline.Command{cmdXUnitMerge, cmdXUnitDiff},
+}
+
+// cmdXUnitMerge represents the "jiri test xunit merge" command.
+var cmdXUnitMerge = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runXUnitMerge),
+	Name:   "merge",
+	Short:  "Merge xUnit test reports",
+	Long: `
+Merges the given xUnit test reports into a single report. Test suites with the
+same name are merged into a single test suite and, if a test case appears in
+multiple reports, the occurrence from the report that comes last wins.
+`,
+	ArgsName: "<report ...>",
+	ArgsLong: "<report ...> is a list of xUnit report files to merge.",
+}
+
+func runXUnitMerge(jirix *jiri.X, args []string) error {
+	if len(args) == 0 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	reports := []*xunit.TestSuites{}
+	for _, arg := range args {
+		report, err := xunit.ReadReport(jirix, arg)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	merged := xunit.MergeReports(reports...)
+	bytes, err := xml.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", merged, err)
+	}
+	if xunitOutputFlag == "" {
+		fmt.Fprintf(jirix.Stdout(), "%s\n", bytes)
+		return nil
+	}
+	return jirix.NewSeq().WriteFile(xunitOutputFlag, bytes, os.FileMode(0644)).Done()
+}
+
+// cmdXUnitDiff represents the "jiri test xunit diff" command.
+var cmdXUnitDiff = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runXUnitDiff),
+	Name:   "diff",
+	Short:  "Compare two xUnit test reports",
+	Long: `
+Compares two xUnit test reports and prints the test cases that are newly
+failing, newly passing, and newly skipped in the new report.
+`,
+	ArgsName: "<old report> <new report>",
+	ArgsLong: "<old report> and <new report> identify the xUnit report files to compare.",
+}
+
+func runXUnitDiff(jirix *jiri.X, args []string) error {
+	if len(args) != 2 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	oldReport, err := xunit.ReadReport(jirix, args[0])
+	if err != nil {
+		return err
+	}
+	newReport, err := xunit.ReadReport(jirix, args[1])
+	if err != nil {
+		return err
+	}
+	printReportDiff(jirix.Stdout(), xunit.DiffReports(oldReport, newReport))
+	return nil
+}
+
+func printReportDiff(w io.Writer, diff xunit.ReportDiff) {
+	if diff.Empty() {
+		fmt.Fprintf(w, "No differences.\n")
+		return
+	}
+	for _, group := range []struct {
+		title string
+		ids   []xunit.CaseID
+	}{
+		{"NEWLY FAILING", diff.NewFailures},
+		{"NEWLY PASSING", diff.NewPasses},
+		{"NEWLY SKIPPED", diff.NewSkips},
+	} {
+		if len(group.ids) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", group.title)
+		for _, id := range group.ids {
+			fmt.Fprintf(w, "  %v\n", id)
+		}
+	}
+}
@@ -1130,7 +1130,41 @@ Jiri go - Execute the go tool using the vanadium environment
 Wrapper around the 'go' tool that can be used for compilation of vanadium Go
 sources. It takes care of vanadium-specific setup, such as setting up the Go
 specific environment variables or making sure that VDL generated files are
-regenerated before compilation.
+regenerated before compilation. VDL generation is skipped if neither the vdl
+tool nor the VDL files changed since the last generation; use the -force-vdl
+flag to regenerate the files regardless. If the vdl tool cannot be found in
+PATH, VDL generation is skipped with a warning, so that plain Go code can be
+built without the full profile setup; use the -require-vdl flag to fail instead.
+
+The build tags that the requested profiles record for the target in the
+GO_BUILD_TAGS variable of the profiles database are automatically added to
+build, install, run and test commands; use the -no-auto-tags flag to disable
+this.
+
+The binaries built by 'jiri go build' and 'jiri go install' embed metadata about
+the build, including the revisions of the projects used. 'jiri go buildinfo
+<binary>' prints the metadata of the given binary, which is read from the binary
+file without running it, so that binaries built for other platforms can be
+inspected too.
+
+The -platforms flag runs the go tool for several platforms in parallel, using
+the environment that the profiles provide for each platform. The go tool is run
+in a per-platform <platforms-dir>/<os>-<arch> directory, so that 'go build'
+leaves the binaries of each platform in their own directory; 'go install' places
+cross-compiled binaries in per-platform directories of the Go workspace. The
+output file of a relative -o flag is placed in the directory of each platform as
+well, whereas an absolute one is rejected for several platforms.
+
+The -bin-dir flag places the binaries built by 'jiri go build' and 'jiri go
+install' in the given directory, such as the one identified by V23_BIN_DIR.
+Binaries built with the -race, -msan or -tags flags are placed in a subdirectory
+named after the build variant, e.g. <bin-dir>/race or <bin-dir>/tags-foo, so
+that they do not overwrite the regular binaries. The build tags include those
+added automatically for the requested profiles.
+
+The -test-json-metadata flag adds a "Vanadium" field, which holds the profiles
+and target used, to each of the JSON events emitted by 'go test -json', so that
+tools consuming these events can tell runs for different configurations apart.
 
 Usage:
    jiri go [flags] <arg ...>
@@ -1604,16 +1638,24 @@ Usage:
    jiri test [flags] <command>
 
 The jiri test commands are:
-   poll        Poll existing jiri projects
-   project     Run tests for a vanadium project
-   run         Run vanadium tests
-   list        List vanadium tests
+   poll            Poll existing jiri projects
+   project         Run tests for a vanadium project
+   run             Run vanadium tests
+   list            List vanadium tests
+   config-validate Validate the tools config
+   generate        Generate Jenkins job configs for tests
+   xunit           Manipulate xUnit test reports
 
 The jiri test flags are:
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -profiles=v23:base
@@ -1646,6 +1688,11 @@ The jiri test poll flags are:
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -profiles=v23:base
@@ -1672,10 +1719,26 @@ Usage:
 <project> identifies the project for which to run tests.
 
 The jiri test project flags are:
+ -bigquery-key-file=
+   The JSON key file of the service account used to export test results to
+   BigQuery.
+ -bigquery-table=
+   The BigQuery table, identified as <project>:<dataset>.<table>, to stream the
+   results of each test case into. If not set, the results are not exported.
+ -unique-reports=false
+   Add a suffix that identifies this invocation to the names of the xUnit
+   reports, so that multiple invocations on the same host do not overwrite each
+   other's reports.
+
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -profiles=v23:base
@@ -1699,8 +1762,18 @@ Usage:
 <name...> is a list names identifying the tests to run.
 
 The jiri test run flags are:
+ -bigquery-key-file=
+   The JSON key file of the service account used to export test results to
+   BigQuery.
+ -bigquery-table=
+   The BigQuery table, identified as <project>:<dataset>.<table>, to stream the
+   results of each test case into. If not set, the results are not exported.
  -blessings-root=dev.v.io
    The blessings root.
+ -changed-files=
+   Comma-separated list of the files changed by the code under test, either
+   absolute or relative to JIRI_ROOT. When set, Go tests whose packages do not
+   depend on any of the changed files are skipped.
  -clean-go=true
    Specify whether to remove Go object files and binaries before running the
    tests. Setting this flag to 'false' may lead to faster Go builds, but it may
@@ -1722,6 +1795,18 @@ The jiri test run flags are:
    Comma-separated list of Go package expressions that identify a subset of
    tests to run; only relevant for Go-based tests. Example usage: jiri test run
    -pkgs v.io/x/ref vanadium-go-test
+ -test-funcs=
+   Regular expression that identifies the Go test functions to run; only
+   relevant for Go-based tests. Example usage: jiri test run -pkgs
+   v.io/x/ref/services/... -test-funcs TestMount vanadium-go-test
+ -unique-reports=false
+   Add a suffix that identifies this invocation to the names of the xUnit
+   reports, so that multiple invocations on the same host do not overwrite each
+   other's reports.
+ -until-failure=0
+   Run the Go tests up to the given number of times, stopping at the first run
+   with a failure, and print a summary of the failure rate and the failing
+   outputs; only relevant for Go-based tests.
  -v23.namespace.root=/ns.dev.v.io:8101
    The namespace root.
 
@@ -1729,6 +1814,11 @@ The jiri test run flags are:
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -profiles=v23:base
@@ -1746,14 +1836,236 @@ Jiri test list - List vanadium tests
 
 List vanadium tests.
 
+Besides the tests built into jiri-test, the list includes the tests implemented
+by the plugins listed in the .jiri_test_plugins files at the roots of the local
+projects. With -v, the descriptions of registered tests and the binaries of
+plugin tests are printed as well.
+
+With -json, the tests are printed as a JSON list that includes, for each test,
+its description and plugin binary, its required profiles, default Go packages
+and timeout, if known, as well as the parts, dependencies and projects that the
+tools config associates with it. The profiles and timeout include those that
+the tools config sets for make tests.
+
 Usage:
    jiri test list [flags]
 
 The jiri test list flags are:
+ -json=false
+   Print the tests and their metadata as JSON.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test config-validate - Validate the tools config
+
+Validate the tools config.
+
+The config is checked for elements and attributes that are not part of its
+schema, such as misspelled ones, and for references to unknown tests in the
+tests of projects, test groups, test dependencies, test parts and make tests.
+The tests implemented by plugins are known as well. The same checks are run by
+the vanadium-tools-config test.
+
+Usage:
+   jiri test config-validate [flags]
+
+The jiri test config-validate flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test generate - Generate Jenkins job configs for tests
+
+Generates the configs of the Jenkins jobs that run the given tests, or all the
+tests listed by "jiri test list" if none are given, so that the job definitions
+stay in sync with the tests.
+
+Tests that the tools config lists as Jenkins matrix jobs are rendered as
+multi-configuration jobs with OS and ARCH axes, as configured. Tests that the
+tools config splits into parts get a P axis, whose values identify the parts
+that each configuration runs with "jiri test run -part". The job timeout is the
+timeout of the test, as listed by "jiri test list -json", if it is longer than
+the -timeout flag, or the -timeout flag otherwise.
+
+With -template, each job is rendered with the given Go text/template instead of
+the built-in Jenkins XML template, e.g. to produce Job DSL scripts. The template
+is executed with a value that has the Name, Description, Command, Matrix, Axes
+(each with a Name and Values), Parts and TimeoutMinutes fields of the job, and
+can use the "xml" function to escape text.
+
+Usage:
+   jiri test generate [flags] <test ...>
+
+<test ...> is a list of tests to generate job configs for.
+
+The jiri test generate flags are:
+ -arch=amd64
+   Comma-separated list of the values of the ARCH axis of multi-configuration
+   jobs.
+ -dir=
+   The directory to write the job configs into, one <test>.xml file per test. If
+   empty, the configs are written to stdout.
+ -os=linux,darwin
+   Comma-separated list of the values of the OS axis of multi-configuration
+   jobs.
+ -template=
+   The path to a Go text/template file to render each job with instead of the
+   built-in Jenkins XML template.
+ -timeout=1h0m0s
+   The timeout of the jobs of tests whose own timeout is not longer.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test xunit - Manipulate xUnit test reports
+
+Manipulate xUnit test reports.
+
+Usage:
+   jiri test xunit [flags] <command>
+
+The jiri test xunit commands are:
+   merge       Merge xUnit test reports
+   diff        Compare two xUnit test reports
+
+The jiri test xunit flags are:
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test xunit merge - Merge xUnit test reports
+
+Merges the given xUnit test reports into a single report. Test suites with the
+same name are merged into a single test suite and, if a test case appears in
+multiple reports, the occurrence from the report that comes last wins.
+
+Usage:
+   jiri test xunit merge [flags] <report ...>
+
+<report ...> is a list of xUnit report files to merge.
+
+The jiri test xunit merge flags are:
+ -o=
+   The file to write the merged report to. The report is written to stdout if
+   empty.
+
+ -color=true
+   Use color to format output.
+ -env=
+   specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
+   specify policies for merging environment variables
+ -profiles=v23:base
+   a comma separated list of profiles to use
+ -profiles-db=$JIRI_ROOT/.jiri_root/profile_db
+   the path, relative to JIRI_ROOT, that contains the profiles database.
+ -skip-profiles=false
+   if set, no profiles will be used
+ -target=<runtime.GOARCH>-<runtime.GOOS>
+   specifies a profile target in the following form: <arch>-<os>[@<version>]
+ -v=false
+   Print verbose output.
+
+Jiri test xunit diff - Compare two xUnit test reports
+
+Compares two xUnit test reports and prints the test cases that are newly
+failing, newly passing, and newly skipped in the new report.
+
+Usage:
+   jiri test xunit diff [flags] <old report> <new report>
+
+<old report> and <new report> identify the xUnit report files to compare.
+
+The jiri test xunit diff flags are:
  -color=true
    Use color to format output.
  -env=
    specify an environment variable in the form: <var>=[<val>],...
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
    specify policies for merging environment variables
  -profiles=v23:base
@@ -0,0 +1,219 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	alertStateFiring = "FIRING"
+	alertStateNoData = "NO DATA"
+	alertStateOK     = "OK"
+)
+
+// alertThresholdSchema is the JSON representation of an alert threshold
+// in the alerts config file.
+type alertThresholdSchema struct {
+	// Name identifies the alert.
+	Name string
+	// Type is the type of the metric the threshold applies to: one of
+	// "latency", "qps", "counters" and "metadata".
+	Type string
+	// Metric is the name of the metric the threshold applies to, or
+	// empty if the threshold applies to all metrics of the given type.
+	Metric string
+	// Op is the comparison that a value must satisfy to violate the
+	// threshold: either ">" or "<".
+	Op string
+	// Value is the value the metric values are compared against.
+	Value float64
+	// Duration is how long the threshold must be continuously violated
+	// for the alert to fire, e.g. "10m".
+	Duration string
+}
+
+// alertThreshold is a parsed alertThresholdSchema.
+type alertThreshold struct {
+	alertThresholdSchema
+	resultType string
+	duration   time.Duration
+}
+
+// alertState records the state of an alert for one metric instance.
+type alertState struct {
+	Name       string
+	MetricName string
+	Instance   string
+	Zone       string
+	Project    string
+	State      string
+	// Value is the current value of the metric.
+	Value float64
+	// Since is the timestamp since when the threshold has been
+	// continuously violated, or 0 if it is not violated.
+	Since int64
+}
+
+var resultTypesByAlertType = map[string]string{
+	"latency":  resultTypeServiceLatency,
+	"qps":      resultTypeServiceQPS,
+	"counters": resultTypeServiceCounters,
+	"metadata": resultTypeServiceMetadata,
+}
+
+// parseAlertThresholds parses the given JSON-encoded list of alert
+// thresholds.
+func parseAlertThresholds(bytes []byte) ([]alertThreshold, error) {
+	var schemas []alertThresholdSchema
+	if err := json.Unmarshal(bytes, &schemas); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	thresholds := []alertThreshold{}
+	for _, schema := range schemas {
+		resultType, ok := resultTypesByAlertType[schema.Type]
+		if !ok {
+			return nil, fmt.Errorf("alert %q has unknown type %q", schema.Name, schema.Type)
+		}
+		if schema.Op != ">" && schema.Op != "<" {
+			return nil, fmt.Errorf("alert %q has unknown op %q", schema.Name, schema.Op)
+		}
+		var duration time.Duration
+		if schema.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(schema.Duration); err != nil {
+				return nil, fmt.Errorf("alert %q: %v", schema.Name, err)
+			}
+		}
+		thresholds = append(thresholds, alertThreshold{
+			alertThresholdSchema: schema,
+			resultType:           resultType,
+			duration:             duration,
+		})
+	}
+	return thresholds, nil
+}
+
+// violated returns whether the given value violates the threshold.
+func (t alertThreshold) violated(value float64) bool {
+	if t.Op == ">" {
+		return value > t.Value
+	}
+	return value < t.Value
+}
+
+// evaluate returns the state of the alert for the given metric result.
+// The alert fires if the latest values of the metric have violated the
+// threshold for at least the duration of the threshold.
+func (t alertThreshold) evaluate(r getMetricResult) alertState {
+	state := alertState{
+		Name:       t.Name,
+		MetricName: r.MetricName,
+		Instance:   r.Instance,
+		Zone:       r.Zone,
+		Project:    r.Project,
+		State:      alertStateNoData,
+		Value:      r.CurrentValue,
+	}
+	n := len(r.HistoryValues)
+	if r.ErrMsg != "" || n == 0 || n != len(r.HistoryTimestamps) {
+		return state
+	}
+	state.State = alertStateOK
+	// Find the earliest point of the trailing run of violating points.
+	first := n
+	for first > 0 && t.violated(r.HistoryValues[first-1]) {
+		first--
+	}
+	if first == n {
+		return state
+	}
+	state.Since = r.HistoryTimestamps[first]
+	if time.Duration(r.HistoryTimestamps[n-1]-state.Since)*time.Second >= t.duration {
+		state.State = alertStateFiring
+	}
+	return state
+}
+
+// evaluateAlerts returns the states of the alerts of the given thresholds
+// for the given data, sorted by alert name and instance.
+func evaluateAlerts(thresholds []alertThreshold, data *getDataResult) []alertState {
+	resultsByType := map[string]map[string]getMetricResults{
+		resultTypeServiceLatency:  data.ServiceLatency,
+		resultTypeServiceQPS:      data.ServiceQPS,
+		resultTypeServiceCounters: data.ServiceCounters,
+		resultTypeServiceMetadata: data.ServiceMetadata,
+	}
+	states := []alertState{}
+	for _, t := range thresholds {
+		for metricName, results := range resultsByType[t.resultType] {
+			if t.Metric != "" && t.Metric != metricName {
+				continue
+			}
+			for _, r := range results {
+				states = append(states, t.evaluate(r))
+			}
+		}
+	}
+	sort.Sort(alertStates(states))
+	return states
+}
+
+type alertStates []alertState
+
+func (s alertStates) Len() int      { return len(s) }
+func (s alertStates) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s alertStates) Less(i, j int) bool {
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+	if s[i].MetricName != s[j].MetricName {
+		return s[i].MetricName < s[j].MetricName
+	}
+	return s[i].Instance < s[j].Instance
+}
+
+// alertConfig holds the alert thresholds read from a config file. The
+// file is re-read whenever it changes, so that thresholds can be updated
+// without restarting the server.
+type alertConfig struct {
+	path       string
+	mu         sync.Mutex
+	modTime    time.Time
+	thresholds []alertThreshold
+}
+
+// Thresholds returns the current alert thresholds, reloading the config
+// file if it changed since it was last read.
+func (c *alertConfig) Thresholds() ([]alertThreshold, error) {
+	if c.path == "" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if c.thresholds != nil && fi.ModTime().Equal(c.modTime) {
+		return c.thresholds, nil
+	}
+	bytes, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	thresholds, err := parseAlertThresholds(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", c.path, err)
+	}
+	c.thresholds, c.modTime = thresholds, fi.ModTime()
+	return c.thresholds, nil
+}
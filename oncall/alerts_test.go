@@ -0,0 +1,135 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAlertThresholds(t *testing.T) {
+	thresholds, err := parseAlertThresholds([]byte(`[
+  {"Name": "high latency", "Type": "latency", "Metric": "mounttable", "Op": ">", "Value": 500, "Duration": "10m"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(thresholds), 1; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := thresholds[0].resultType, resultTypeServiceLatency; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := thresholds[0].duration, 10*time.Minute; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for _, data := range []string{
+		`[{"Name": "a", "Type": "unknown", "Op": ">"}]`,
+		`[{"Name": "a", "Type": "qps", "Op": ">="}]`,
+		`[{"Name": "a", "Type": "qps", "Op": ">", "Duration": "10"}]`,
+		`{}`,
+	} {
+		if _, err := parseAlertThresholds([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	thresholds, err := parseAlertThresholds([]byte(`[
+  {"Name": "high latency", "Type": "latency", "Metric": "mounttable", "Op": ">", "Value": 500, "Duration": "10m"},
+  {"Name": "low qps", "Type": "qps", "Op": "<", "Value": 1, "Duration": "5m"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	data := &getDataResult{
+		ServiceLatency: map[string]getMetricResults{
+			"mounttable": {
+				{
+					MetricName:        "mounttable",
+					Instance:          "mt-1",
+					CurrentValue:      600,
+					HistoryTimestamps: []int64{0, 300, 600, 900},
+					HistoryValues:     []float64{400, 600, 700, 600},
+				},
+				{
+					MetricName:        "mounttable",
+					Instance:          "mt-2",
+					CurrentValue:      600,
+					HistoryTimestamps: []int64{0, 300, 600, 900},
+					HistoryValues:     []float64{400, 400, 600, 600},
+				},
+			},
+			"proxy": {
+				{
+					MetricName:        "proxy",
+					Instance:          "proxy-1",
+					CurrentValue:      1000,
+					HistoryTimestamps: []int64{0},
+					HistoryValues:     []float64{1000},
+				},
+			},
+		},
+		ServiceQPS: map[string]getMetricResults{
+			"proxy": {
+				{
+					MetricName:   "proxy",
+					Instance:     "proxy-1",
+					CurrentValue: -1,
+					ErrMsg:       "no data",
+				},
+			},
+		},
+	}
+	got := evaluateAlerts(thresholds, data)
+	want := []alertState{
+		{Name: "high latency", MetricName: "mounttable", Instance: "mt-1", State: alertStateFiring, Value: 600, Since: 300},
+		{Name: "high latency", MetricName: "mounttable", Instance: "mt-2", State: alertStateOK, Value: 600, Since: 600},
+		{Name: "low qps", MetricName: "proxy", Instance: "proxy-1", State: alertStateNoData, Value: -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestAlertConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oncall-alerts")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alerts.json")
+	write := func(data string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), os.FileMode(0644)); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	config := &alertConfig{path: path}
+	now := time.Now()
+
+	write(`[{"Name": "a", "Type": "qps", "Op": "<", "Value": 1}]`, now)
+	thresholds, err := config.Thresholds()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := thresholds[0].Name, "a"; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	write(`[{"Name": "b", "Type": "qps", "Op": "<", "Value": 1}]`, now.Add(time.Second))
+	if thresholds, err = config.Thresholds(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := thresholds[0].Name, "b"; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
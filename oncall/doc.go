@@ -12,6 +12,8 @@ Usage:
    oncall [flags] <command>
 
 The oncall commands are:
+   collect     Compute and store SLO compliance and burn rates
+   incident    Open, close and list incidents
    serve       Serve oncall dashboard data from Google Storage
    help        Display help for commands or topics
 
@@ -27,20 +29,153 @@ The global flags are:
  -time=false
    Dump timing information to stderr before exiting the program.
 
+Oncall collect - Compute and store SLO compliance and burn rates
+
+Compute the compliance of the latency checks of production services with service
+level objectives from the data in GCM, and store the compliance along with the
+error budget burn-rate series in gs://vanadium-oncall/data/slo.json, from which
+"oncall serve" serves them at /data/slo.
+
+The -slos file contains a JSON list of objectives, e.g.:
+  [{"Name": "mounttable latency", "Metric": "mounttable", "Threshold": 500,
+    "Target": 0.99, "Window": "672h"}]
+The objective requires that the fraction Target of the checks within the rolling
+window have latencies of at most Threshold milliseconds.  The window defaults to
+28 days.  The burn rate of each -step period is the fraction of the checks
+violating the objective divided by 1-Target, so that a burn rate of 1 consumes
+exactly the whole error budget over the window.
+
+Usage:
+   oncall collect [flags]
+
+The oncall collect flags are:
+ -key=
+   The path to the service account's JSON credentials file.
+ -slos=
+   The path to a JSON file with service level objectives.
+ -step=1h0m0s
+   The period of the points of the burn-rate series.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Oncall incident - Open, close and list incidents
+
+Open, close and list incidents affecting production services.
+
+Incidents are stored in gs://vanadium-oncall/incidents.json, and open incidents
+are included in the data served by "oncall serve".
+
+Usage:
+   oncall incident [flags] <command>
+
+The oncall incident commands are:
+   open        Open a new incident
+   close       Close an open incident
+   list        List incidents
+
+The oncall incident flags are:
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Oncall incident open - Open a new incident
+
+Open a new incident and print its ID.
+
+Usage:
+   oncall incident open [flags]
+
+The oncall incident open flags are:
+ -notes=
+   Notes to record with the incident.
+ -services=
+   The affected services, separated by ','. Valid services are: mounttable,
+   identity service, macaroon service, binary discharger, role service, proxy
+   service, benchmark service, syncbase allocator.
+ -severity=major
+   The severity of the incident, one of critical, major, minor.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Oncall incident close - Close an open incident
+
+Close an open incident.
+
+Usage:
+   oncall incident close [flags] <id>
+
+<id> is the ID of the incident to close.
+
+The oncall incident close flags are:
+ -notes=
+   Notes to record with the incident.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Oncall incident list - List incidents
+
+List open incidents, or all incidents if -all is specified.
+
+Usage:
+   oncall incident list [flags]
+
+The oncall incident list flags are:
+ -all=false
+   List closed incidents too.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
 Oncall serve - Serve oncall dashboard data from Google Storage
 
 Serve oncall dashboard data from Google Storage.
 
+Files read from Google Storage are cached in memory and are re-downloaded only
+when their generation changes, which is checked every -refresh period. The
+least recently used files are evicted when the cache is full.
+Responses carry ETag and Last-Modified headers, so that clients can avoid
+re-downloading unchanged data using If-None-Match and If-Modified-Since.
+
+If -alerts is specified, the served data also includes the states of the alerts
+defined by the alert thresholds in the given file, and the current alert states
+are served at /data/alerts.  The file contains a JSON list of thresholds, e.g.:
+  [{"Name": "high latency", "Type": "latency", "Metric": "mounttable",
+    "Op": ">", "Value": 500, "Duration": "10m"}]
+The alert fires if the values of the matching metrics have been continuously
+greater than (or less than, for "<") the value for at least the duration.  Type
+is one of "latency", "qps", "counters" and "metadata", and an empty Metric
+matches all metrics of the type.  The file is reloaded whenever it changes.
+
+The served data also includes the open incidents recorded by "oncall incident".
+The SLO compliance and burn-rate series stored by "oncall collect" are served
+at /data/slo.
+
 Usage:
    oncall serve [flags]
 
 The oncall serve flags are:
  -address=:8000
    Listening address for the server.
+ -alerts=
+   The path to a JSON file with alert thresholds.
  -cache=
-   Directory to use for caching files.
+   Deprecated and ignored, as files are cached in memory.
  -key=
    The path to the service account's JSON credentials file.
+ -refresh=5m0s
+   How often to check Google Storage for updates of the cached files.
  -static=
    Directory to use for serving static files.
 
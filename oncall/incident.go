@@ -0,0 +1,353 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/monitoring"
+	"v.io/x/lib/cmdline"
+)
+
+const (
+	// incidentsFile is the Google Storage location of the JSON file that
+	// stores all incidents.
+	incidentsFile = "gs://vanadium-oncall/incidents.json"
+
+	incidentTimeFormat = "2006-01-02 15:04 MST"
+)
+
+var (
+	allFlag      bool
+	notesFlag    string
+	servicesFlag string
+	severityFlag string
+)
+
+// prodServices lists the production services incidents can affect.
+var prodServices = []string{
+	monitoring.SNMounttable,
+	monitoring.SNIdentity,
+	monitoring.SNMacaroon,
+	monitoring.SNBinaryDischarger,
+	monitoring.SNRole,
+	monitoring.SNProxy,
+	monitoring.SNBenchmark,
+	monitoring.SNAllocator,
+}
+
+// incidentSeverities lists the valid incident severities, from the most
+// to the least severe.
+var incidentSeverities = []string{"critical", "major", "minor"}
+
+func init() {
+	cmdIncidentOpen.Flags.StringVar(&severityFlag, "severity", "major", fmt.Sprintf("The severity of the incident, one of %s.", strings.Join(incidentSeverities, ", ")))
+	cmdIncidentOpen.Flags.StringVar(&servicesFlag, "services", "", fmt.Sprintf("The affected services, separated by ','. Valid services are: %s.", strings.Join(prodServices, ", ")))
+	for _, cmd := range []*cmdline.Command{cmdIncidentOpen, cmdIncidentClose} {
+		cmd.Flags.StringVar(&notesFlag, "notes", "", "Notes to record with the incident.")
+	}
+	cmdIncidentList.Flags.BoolVar(&allFlag, "all", false, "List closed incidents too.")
+}
+
+// incident records an outage or a degradation of production services.
+type incident struct {
+	// ID identifies the incident.
+	ID int
+	// Severity is one of incidentSeverities.
+	Severity string
+	// Services lists the affected production services.
+	Services []string
+	// Start is the Unix timestamp of when the incident was opened.
+	Start int64
+	// End is the Unix timestamp of when the incident was closed, or 0
+	// if the incident is still open.
+	End int64
+	// Notes holds the notes recorded when the incident was opened and
+	// closed.
+	Notes []string
+}
+
+// cmdIncident represents the 'incident' command of the oncall tool.
+var cmdIncident = &cmdline.Command{
+	Name:  "incident",
+	Short: "Open, close and list incidents",
+	Long: `
+Open, close and list incidents affecting production services.
+
+Incidents are stored in ` + incidentsFile + `, and open incidents are
+included in the data served by "oncall serve".
+`,
+	Children: []*cmdline.Command{cmdIncidentOpen, cmdIncidentClose, cmdIncidentList},
+}
+
+// cmdIncidentOpen represents the 'incident open' command of the oncall
+// tool.
+var cmdIncidentOpen = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runIncidentOpen),
+	Name:   "open",
+	Short:  "Open a new incident",
+	Long:   "Open a new incident and print its ID.",
+}
+
+func runIncidentOpen(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	services, err := parseServices(servicesFlag)
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return err
+	}
+	incidents, generation, err := loadIncidents(jirix)
+	if err != nil {
+		return err
+	}
+	incidents, opened, err := openIncident(incidents, severityFlag, services, notesFlag, time.Now())
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	if err := saveIncidents(jirix, incidents, generation); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "%d\n", opened.ID)
+	return nil
+}
+
+// cmdIncidentClose represents the 'incident close' command of the oncall
+// tool.
+var cmdIncidentClose = &cmdline.Command{
+	Runner:   cmdline.RunnerFunc(runIncidentClose),
+	Name:     "close",
+	Short:    "Close an open incident",
+	Long:     "Close an open incident.",
+	ArgsName: "<id>",
+	ArgsLong: "<id> is the ID of the incident to close.",
+}
+
+func runIncidentClose(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("expected one argument, got %v", args)
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return env.UsageErrorf("invalid incident ID %q", args[0])
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return err
+	}
+	incidents, generation, err := loadIncidents(jirix)
+	if err != nil {
+		return err
+	}
+	if err := closeIncident(incidents, id, notesFlag, time.Now()); err != nil {
+		return err
+	}
+	return saveIncidents(jirix, incidents, generation)
+}
+
+// cmdIncidentList represents the 'incident list' command of the oncall
+// tool.
+var cmdIncidentList = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runIncidentList),
+	Name:   "list",
+	Short:  "List incidents",
+	Long:   "List open incidents, or all incidents if -all is specified.",
+}
+
+func runIncidentList(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return err
+	}
+	incidents, _, err := loadIncidents(jirix)
+	if err != nil {
+		return err
+	}
+	if !allFlag {
+		incidents = openIncidents(incidents)
+	}
+	return printIncidents(env.Stdout, incidents)
+}
+
+// parseServices parses the given comma-separated list of affected
+// services, checking that they are production services.
+func parseServices(value string) ([]string, error) {
+	services := []string{}
+	for _, service := range strings.Split(value, ",") {
+		if service = strings.TrimSpace(service); service == "" {
+			continue
+		}
+		if !contains(prodServices, service) {
+			return nil, fmt.Errorf("unknown service %q", service)
+		}
+		services = append(services, service)
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no affected services specified")
+	}
+	return services, nil
+}
+
+// openIncident adds a new incident to the given incidents and returns
+// the resulting incidents and the new incident.
+func openIncident(incidents []incident, severity string, services []string, notes string, now time.Time) ([]incident, incident, error) {
+	if !contains(incidentSeverities, severity) {
+		return nil, incident{}, fmt.Errorf("unknown severity %q", severity)
+	}
+	id := 1
+	for _, i := range incidents {
+		if i.ID >= id {
+			id = i.ID + 1
+		}
+	}
+	opened := incident{
+		ID:       id,
+		Severity: severity,
+		Services: services,
+		Start:    now.Unix(),
+	}
+	if notes != "" {
+		opened.Notes = []string{notes}
+	}
+	return append(incidents, opened), opened, nil
+}
+
+// closeIncident closes the open incident with the given ID.
+func closeIncident(incidents []incident, id int, notes string, now time.Time) error {
+	for i := range incidents {
+		if incidents[i].ID != id {
+			continue
+		}
+		if incidents[i].End != 0 {
+			return fmt.Errorf("incident %d is already closed", id)
+		}
+		incidents[i].End = now.Unix()
+		if notes != "" {
+			incidents[i].Notes = append(incidents[i].Notes, notes)
+		}
+		return nil
+	}
+	return fmt.Errorf("incident %d not found", id)
+}
+
+// openIncidents returns the incidents that are still open.
+func openIncidents(incidents []incident) []incident {
+	ret := []incident{}
+	for _, i := range incidents {
+		if i.End == 0 {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// printIncidents prints the given incidents as a table to w.
+func printIncidents(w io.Writer, incidents []incident) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSEVERITY\tSTART\tEND\tSERVICES\tNOTES")
+	for _, i := range incidents {
+		end := "-"
+		if i.End != 0 {
+			end = time.Unix(i.End, 0).UTC().Format(incidentTimeFormat)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i.ID, i.Severity,
+			time.Unix(i.Start, 0).UTC().Format(incidentTimeFormat), end,
+			strings.Join(i.Services, ", "), strings.Join(i.Notes, "; "))
+	}
+	return tw.Flush()
+}
+
+// loadIncidents reads all incidents from Google Storage. It also returns
+// the generation of the incidents file, which is "0" if the file does not
+// exist yet.
+func loadIncidents(jirix *jiri.X) ([]incident, string, error) {
+	var out, stderr bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, &stderr).Last("gsutil", "stat", incidentsFile); err != nil {
+		if isNotFoundStat(stderr.Bytes()) {
+			// The file does not exist yet.
+			return []incident{}, "0", nil
+		}
+		return nil, "", fmt.Errorf("%v: %v\n%s", incidentsFile, err, stderr.String())
+	}
+	generation, _, err := parseStat(out.Bytes())
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %v", incidentsFile, err)
+	}
+	out.Reset()
+	if err := jirix.NewSeq().Capture(&out, nil).Last("gsutil", "-q", "cat", incidentsFile+"#"+generation); err != nil {
+		return nil, "", err
+	}
+	incidents := []incident{}
+	if err := json.Unmarshal(out.Bytes(), &incidents); err != nil {
+		return nil, "", fmt.Errorf("Unmarshal(%v) failed: %v", out.String(), err)
+	}
+	return incidents, generation, nil
+}
+
+// saveIncidents writes the given incidents to Google Storage. The write
+// fails if the incidents file changed since the given generation was
+// read, so that concurrent updates are not lost.
+func saveIncidents(jirix *jiri.X, incidents []incident, generation string) error {
+	bytes, err := json.MarshalIndent(incidents, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", incidents, err)
+	}
+	f, err := ioutil.TempFile("", "incidents")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return jirix.NewSeq().Last("gsutil", "-q", "-h", "x-goog-if-generation-match:"+generation, "cp", f.Name(), incidentsFile)
+}
+
+// getOpenIncidents returns the open incidents, reading the incidents file
+// through the given cache. It returns no incidents if the file does not
+// exist.
+func getOpenIncidents(objects *objectCache) []incident {
+	obj, err := objects.get(incidentsFile)
+	if err != nil {
+		return []incident{}
+	}
+	var incidents []incident
+	if err := json.Unmarshal(obj.data, &incidents); err != nil {
+		fmt.Fprintf(objects.jirix.Stderr(), "Unmarshal(%v) failed: %v\n", string(obj.data), err)
+		return []incident{}
+	}
+	return openIncidents(incidents)
+}
+
+// contains returns whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncidents(t *testing.T) {
+	if _, err := parseServices("mounttable, foo"); err == nil {
+		t.Fatalf("parsing an unknown service did not fail")
+	}
+	if _, err := parseServices(""); err == nil {
+		t.Fatalf("parsing no services did not fail")
+	}
+	services, err := parseServices("mounttable, proxy service")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{"mounttable", "proxy service"}; !reflect.DeepEqual(services, want) {
+		t.Fatalf("want %v, got %v", want, services)
+	}
+
+	start := time.Unix(1460000000, 0)
+	incidents, _, err := openIncident(nil, "critical", services, "mounttable unreachable", start)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	incidents, opened, err := openIncident(incidents, "minor", []string{"role service"}, "", start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := opened.ID, 2; got != want {
+		t.Fatalf("want ID %d, got %d", want, got)
+	}
+	if _, _, err := openIncident(incidents, "catastrophic", services, "", start); err == nil {
+		t.Fatalf("opening an incident with an unknown severity did not fail")
+	}
+
+	if err := closeIncident(incidents, 1, "restarted mounttable", start.Add(2*time.Hour)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := closeIncident(incidents, 1, "", start.Add(3*time.Hour)); err == nil {
+		t.Fatalf("closing a closed incident did not fail")
+	}
+	if err := closeIncident(incidents, 3, "", start.Add(3*time.Hour)); err == nil {
+		t.Fatalf("closing an unknown incident did not fail")
+	}
+	want := []incident{
+		{
+			ID:       1,
+			Severity: "critical",
+			Services: []string{"mounttable", "proxy service"},
+			Start:    1460000000,
+			End:      1460007200,
+			Notes:    []string{"mounttable unreachable", "restarted mounttable"},
+		},
+		{
+			ID:       2,
+			Severity: "minor",
+			Services: []string{"role service"},
+			Start:    1460003600,
+		},
+	}
+	if !reflect.DeepEqual(incidents, want) {
+		t.Fatalf("want %#v, got %#v", want, incidents)
+	}
+	if got := openIncidents(incidents); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("want %#v, got %#v", want[1:], got)
+	}
+
+	var out bytes.Buffer
+	if err := printIncidents(&out, incidents); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wantOut := strings.Join([]string{
+		"ID  SEVERITY  START                 END                   SERVICES                   NOTES",
+		"1   critical  2016-04-07 03:33 UTC  2016-04-07 05:33 UTC  mounttable, proxy service  mounttable unreachable; restarted mounttable",
+		"2   minor     2016-04-07 04:33 UTC  -                     role service               ",
+		"",
+	}, "\n")
+	if got := out.String(); got != wantOut {
+		t.Fatalf("want\n%q\ngot\n%q", wantOut, got)
+	}
+}
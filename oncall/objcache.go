@@ -0,0 +1,193 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"v.io/jiri"
+)
+
+// gsObject is a Google Storage object held in memory.
+type gsObject struct {
+	data       []byte
+	generation string
+	modTime    time.Time
+}
+
+// maxCachedObjects is the maximum number of objects that objectCache
+// holds in memory.
+const maxCachedObjects = 256
+
+// objectCache is an in-memory cache of Google Storage objects, keyed by
+// object URL. An object is only re-downloaded when its generation
+// changes. When the cache is full, the least recently used object is
+// evicted to make room for a new one.
+type objectCache struct {
+	jirix      *jiri.X
+	maxObjects int
+	mu         sync.Mutex
+	objects    map[string]*gsObject
+	// used records when the cached objects were last returned by get.
+	used map[string]time.Time
+}
+
+func newObjectCache(jirix *jiri.X, maxObjects int) *objectCache {
+	return &objectCache{
+		jirix:      jirix,
+		maxObjects: maxObjects,
+		objects:    map[string]*gsObject{},
+		used:       map[string]time.Time{},
+	}
+}
+
+// get returns the object with the given URL, downloading it if it is not
+// cached yet.
+func (c *objectCache) get(url string) (*gsObject, error) {
+	c.mu.Lock()
+	obj, ok := c.objects[url]
+	if ok {
+		c.used[url] = time.Now()
+	}
+	c.mu.Unlock()
+	if ok {
+		return obj, nil
+	}
+	return c.refresh(url)
+}
+
+// add adds the given object to the cache, evicting the least recently
+// used object if the cache is full.
+func (c *objectCache) add(url string, obj *gsObject) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.objects[url]; !ok && len(c.objects) >= c.maxObjects {
+		oldest := ""
+		for cached, used := range c.used {
+			if oldest == "" || used.Before(c.used[oldest]) {
+				oldest = cached
+			}
+		}
+		delete(c.objects, oldest)
+		delete(c.used, oldest)
+	}
+	c.objects[url] = obj
+	if _, ok := c.used[url]; !ok {
+		c.used[url] = time.Now()
+	}
+}
+
+// refresh checks the generation of the object with the given URL, and
+// downloads the object if its generation differs from the cached one.
+func (c *objectCache) refresh(url string) (*gsObject, error) {
+	var out bytes.Buffer
+	if err := c.jirix.NewSeq().Capture(&out, nil).Last("gsutil", "stat", url); err != nil {
+		return nil, err
+	}
+	generation, modTime, err := parseStat(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", url, err)
+	}
+	c.mu.Lock()
+	obj, ok := c.objects[url]
+	c.mu.Unlock()
+	if ok && obj.generation == generation {
+		return obj, nil
+	}
+	out.Reset()
+	if err := c.jirix.NewSeq().Capture(&out, nil).Last("gsutil", "-q", "cat", url+"#"+generation); err != nil {
+		return nil, err
+	}
+	obj = &gsObject{
+		data:       out.Bytes(),
+		generation: generation,
+		modTime:    modTime,
+	}
+	c.add(url, obj)
+	return obj, nil
+}
+
+// refreshLoop refreshes all cached objects with the given period. It
+// never returns.
+func (c *objectCache) refreshLoop(period time.Duration) {
+	for range time.Tick(period) {
+		c.mu.Lock()
+		urls := make([]string, 0, len(c.objects))
+		for url := range c.objects {
+			urls = append(urls, url)
+		}
+		c.mu.Unlock()
+		for _, url := range urls {
+			c.mu.Lock()
+			_, ok := c.objects[url]
+			c.mu.Unlock()
+			if !ok {
+				// The object has been evicted in the meantime.
+				continue
+			}
+			if _, err := c.refresh(url); err != nil {
+				fmt.Fprintf(c.jirix.Stderr(), "%v\n", err)
+			}
+		}
+	}
+}
+
+// parseStat parses the generation and update time of an object from the
+// output of "gsutil stat".
+func parseStat(out []byte) (string, time.Time, error) {
+	generation, modTime := "", time.Time{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "Generation":
+			generation = value
+		case "Update time":
+			t, err := time.Parse(time.RFC1123, value)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			modTime = t
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+	if generation == "" {
+		return "", time.Time{}, fmt.Errorf("no generation found in:\n%s", out)
+	}
+	return generation, modTime, nil
+}
+
+// isNotFoundStat returns whether the given error output of a failed
+// "gsutil stat" reports that the object does not exist.
+func isNotFoundStat(stderr []byte) bool {
+	return bytes.Contains(stderr, []byte("No URLs matched"))
+}
+
+// serveContent writes the given content to w, setting the ETag and
+// Last-Modified headers so that requests with a matching If-None-Match
+// or If-Modified-Since header are answered with 304 Not Modified.
+func serveContent(w http.ResponseWriter, r *http.Request, contentType, etag string, modTime time.Time, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf("%q", etag))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(content))
+}
+
+// contentETag returns an ETag identifying the given content.
+func contentETag(content []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(content))
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseStat(t *testing.T) {
+	out := `gs://vanadium-oncall-pics/jsimsa.png:
+	Creation time:		Tue, 01 Mar 2016 18:00:00 GMT
+	Update time:		Wed, 02 Mar 2016 18:00:00 GMT
+	Storage class:		STANDARD
+	Content-Length:		1234
+	Content-Type:		image/png
+	Generation:		1456941600000000
+	Metageneration:		1
+`
+	generation, modTime, err := parseStat([]byte(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := generation, "1456941600000000"; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := modTime, time.Date(2016, 3, 2, 18, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if _, _, err := parseStat([]byte("gs://bucket/object:\n")); err == nil {
+		t.Fatalf("parsing output without a generation did not fail")
+	}
+}
+
+func TestIsNotFoundStat(t *testing.T) {
+	if !isNotFoundStat([]byte("No URLs matched: gs://vanadium-oncall/incidents.json\n")) {
+		t.Fatalf("missing object not detected")
+	}
+	if isNotFoundStat([]byte("AccessDeniedException: 403 Forbidden\n")) {
+		t.Fatalf("access error reported as a missing object")
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	content := []byte(`{"Oncalls": ["jsimsa"]}`)
+	etag, modTime := contentETag(content), time.Date(2016, 3, 2, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header, value string
+		status        int
+	}{
+		{"", "", http.StatusOK},
+		{"If-None-Match", `"` + etag + `"`, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", modTime.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", modTime.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", "/data", nil)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		w := httptest.NewRecorder()
+		serveContent(w, r, "application/json", etag, modTime, content)
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%v: %v: want %v, got %v", test.header, test.value, want, got)
+		}
+		if w.Code == http.StatusOK {
+			if got, want := w.Body.String(), string(content); got != want {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		}
+	}
+}
+
+func TestObjectCacheEviction(t *testing.T) {
+	c := newObjectCache(nil, 2)
+	c.add("gs://bucket/a", &gsObject{generation: "1"})
+	c.add("gs://bucket/b", &gsObject{generation: "1"})
+	// Make "a" the most recently used object.
+	c.used["gs://bucket/a"] = time.Now().Add(time.Hour)
+	if _, err := c.get("gs://bucket/a"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	c.add("gs://bucket/c", &gsObject{generation: "1"})
+	if got, want := len(c.objects), 2; got != want {
+		t.Fatalf("want %v objects, got %v", want, got)
+	}
+	if _, ok := c.objects["gs://bucket/b"]; ok {
+		t.Errorf("least recently used object was not evicted")
+	}
+	// Replacing a cached object does not evict another one.
+	c.add("gs://bucket/c", &gsObject{generation: "2"})
+	if _, ok := c.objects["gs://bucket/a"]; !ok {
+		t.Errorf("object was evicted when replacing another one")
+	}
+}
@@ -20,9 +20,7 @@ import (
 	cloudmonitoring "google.golang.org/api/monitoring/v3"
 
 	"v.io/jiri"
-	"v.io/jiri/collect"
 	"v.io/jiri/tool"
-	"v.io/x/devtools/internal/cache"
 	"v.io/x/devtools/internal/monitoring"
 	"v.io/x/lib/cmdline"
 	"v.io/x/lib/gcm"
@@ -43,8 +41,10 @@ const (
 
 var (
 	addressFlag   string
+	alertsFlag    string
 	cacheFlag     string
 	keyFileFlag   string
+	refreshFlag   time.Duration
 	staticDirFlag string
 )
 
@@ -141,6 +141,8 @@ type getDataResult struct {
 	ServiceCounters map[string]getMetricResults
 	ServiceMetadata map[string]getMetricResults
 
+	Alerts    []alertState
+	Incidents []incident        // open incidents
 	Instances map[string]string // instances -> external ids
 	Oncalls   []string
 	MinTime   int64
@@ -165,8 +167,10 @@ var locations = []clusterLocation{
 
 func init() {
 	cmdServe.Flags.StringVar(&addressFlag, "address", ":8000", "Listening address for the server.")
-	cmdServe.Flags.StringVar(&cacheFlag, "cache", "", "Directory to use for caching files.")
+	cmdServe.Flags.StringVar(&alertsFlag, "alerts", "", "The path to a JSON file with alert thresholds.")
+	cmdServe.Flags.StringVar(&cacheFlag, "cache", "", "Deprecated and ignored, as files are cached in memory.")
 	cmdServe.Flags.StringVar(&keyFileFlag, "key", "", "The path to the service account's JSON credentials file.")
+	cmdServe.Flags.DurationVar(&refreshFlag, "refresh", 5*time.Minute, "How often to check Google Storage for updates of the cached files.")
 	cmdServe.Flags.StringVar(&staticDirFlag, "static", "", "Directory to use for serving static files.")
 }
 
@@ -175,38 +179,66 @@ var cmdServe = &cmdline.Command{
 	Runner: cmdline.RunnerFunc(runServe),
 	Name:   "serve",
 	Short:  "Serve oncall dashboard data from Google Storage",
-	Long:   "Serve oncall dashboard data from Google Storage.",
+	Long: `
+Serve oncall dashboard data from Google Storage.
+
+Files read from Google Storage are cached in memory and are re-downloaded only
+when their generation changes, which is checked every -refresh period. The
+least recently used files are evicted when the cache is full.
+Responses carry ETag and Last-Modified headers, so that clients can avoid
+re-downloading unchanged data using If-None-Match and If-Modified-Since.
+
+If -alerts is specified, the served data also includes the states of the alerts
+defined by the alert thresholds in the given file, and the current alert states
+are served at /data/alerts.  The file contains a JSON list of thresholds, e.g.:
+  [{"Name": "high latency", "Type": "latency", "Metric": "mounttable",
+    "Op": ">", "Value": 500, "Duration": "10m"}]
+The alert fires if the values of the matching metrics have been continuously
+greater than (or less than, for "<") the value for at least the duration.  Type
+is one of "latency", "qps", "counters" and "metadata", and an empty Metric
+matches all metrics of the type.  The file is reloaded whenever it changes.
+
+The served data also includes the open incidents recorded by "oncall incident".
+The SLO compliance and burn-rate series stored by "oncall collect" are served
+at /data/slo.
+`,
 }
 
-func runServe(env *cmdline.Env, _ []string) (e error) {
+func runServe(env *cmdline.Env, _ []string) error {
 	jirix, err := jiri.NewX(env)
 	if err != nil {
 		return err
 	}
 
-	// Set up the root/cache directory.
-	root := cacheFlag
-	if root == "" {
-		tmpDir, err := jirix.NewSeq().TempDir("", "")
-		if err != nil {
-			return err
-		}
-		defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(tmpDir).Done() }, &e)
-		root = tmpDir
+	// Set up the in-memory cache of Google Storage files.
+	if cacheFlag != "" {
+		fmt.Fprintf(jirix.Stderr(), "WARNING: -cache is deprecated and ignored, as files are cached in memory.\n")
 	}
+	objects := newObjectCache(jirix, maxCachedObjects)
+	go objects.refreshLoop(refreshFlag)
 
 	// Start server.
+	alerts := &alertConfig{path: alertsFlag}
+	if _, err := alerts.Thresholds(); err != nil {
+		return err
+	}
 	http.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
-		dataHandler(jirix, root, w, r)
+		dataHandler(jirix, alerts, objects, w, r)
+	})
+	http.HandleFunc("/data/alerts", func(w http.ResponseWriter, r *http.Request) {
+		alertsHandler(jirix, alerts, w, r)
+	})
+	http.HandleFunc("/data/slo", func(w http.ResponseWriter, r *http.Request) {
+		sloHandler(jirix, objects, w, r)
 	})
 	http.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
-		logsHandler(jirix, root, w, r)
+		logsHandler(jirix, w, r)
 	})
 	http.HandleFunc("/cfg", func(w http.ResponseWriter, r *http.Request) {
-		cfgHandler(jirix, root, w, r)
+		cfgHandler(jirix, w, r)
 	})
 	http.HandleFunc("/pic", func(w http.ResponseWriter, r *http.Request) {
-		picHandler(jirix, root, w, r)
+		picHandler(jirix, objects, w, r)
 	})
 	staticHandler := http.FileServer(http.Dir(staticDirFlag))
 	http.Handle("/", staticHandler)
@@ -217,13 +249,7 @@ func runServe(env *cmdline.Env, _ []string) (e error) {
 	return nil
 }
 
-func dataHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Request) {
-	s, err := gcm.Authenticate(keyFileFlag)
-	if err != nil {
-		respondWithError(jirix, err, w)
-		return
-	}
-
+func dataHandler(jirix *jiri.X, alerts *alertConfig, objects *objectCache, w http.ResponseWriter, r *http.Request) {
 	// Get start and end timestamps.
 	if err := r.ParseForm(); err != nil {
 		respondWithError(jirix, err, w)
@@ -251,34 +277,78 @@ func dataHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	// Get currently running pods and nodes from vanadium production clusters.
-	podsByServices, nodes, err := getKubeData(jirix)
+	result, err := getData(jirix, startTimestamp, endTimestamp)
 	if err != nil {
 		respondWithError(jirix, err, w)
 		return
 	}
 
-	// Create tasks of getting metrics from GCM.
-	allTasks := []getMetricTask{}
-	mdServiceLatency, err := gcm.GetMetric("service-latency", "vanadium-production")
+	// Evaluate alerts.
+	thresholds, err := alerts.Thresholds()
 	if err != nil {
 		respondWithError(jirix, err, w)
 		return
 	}
-	mdServiceQPS, err := gcm.GetMetric("service-qps-total", "vanadium-production")
+	result.Alerts = evaluateAlerts(thresholds, result)
+	result.Incidents = getOpenIncidents(objects)
+
+	respondWithJSON(jirix, result, w, r)
+}
+
+func alertsHandler(jirix *jiri.X, alerts *alertConfig, w http.ResponseWriter, r *http.Request) {
+	thresholds, err := alerts.Thresholds()
 	if err != nil {
 		respondWithError(jirix, err, w)
 		return
 	}
-	mdServiceCounters, err := gcm.GetMetric("service-counters", "vanadium-production")
+
+	// Evaluate alerts against the data of the last hour, or the longest
+	// threshold duration if that is longer.
+	window := time.Hour
+	for _, t := range thresholds {
+		if t.duration > window {
+			window = t.duration
+		}
+	}
+	now := time.Now()
+	result, err := getData(jirix, now.Add(-window).Unix(), now.Unix())
 	if err != nil {
 		respondWithError(jirix, err, w)
 		return
 	}
+	respondWithJSON(jirix, evaluateAlerts(thresholds, result), w, r)
+}
+
+// getData gets the dashboard data for the given time range.
+func getData(jirix *jiri.X, startTimestamp, endTimestamp int64) (*getDataResult, error) {
+	s, err := gcm.Authenticate(keyFileFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get currently running pods and nodes from vanadium production clusters.
+	podsByServices, nodes, err := getKubeData(jirix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create tasks of getting metrics from GCM.
+	allTasks := []getMetricTask{}
+	mdServiceLatency, err := gcm.GetMetric("service-latency", "vanadium-production")
+	if err != nil {
+		return nil, err
+	}
+	mdServiceQPS, err := gcm.GetMetric("service-qps-total", "vanadium-production")
+	if err != nil {
+		return nil, err
+	}
+	mdServiceCounters, err := gcm.GetMetric("service-counters", "vanadium-production")
+	if err != nil {
+		return nil, err
+	}
 	mdServiceMetadata, err := gcm.GetMetric("service-metadata", "vanadium-production")
 	if err != nil {
-		respondWithError(jirix, err, w)
-		return
+		return nil, err
 	}
 	for serviceName, pods := range podsByServices {
 		for _, pod := range pods {
@@ -345,7 +415,7 @@ func dataHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Requ
 	tasks := make(chan getMetricTask, numTasks)
 	taskResults := make(chan getMetricResult, numTasks)
 	for i := 0; i < numWorkers; i++ {
-		go getMetricWorker(jirix, s, time.Unix(startTimestamp, 0), time.Unix(endTimestamp, 0), tasks, taskResults)
+		go getMetricWorker(jirix, monitoring.NewGCMLister(s), time.Unix(startTimestamp, 0), time.Unix(endTimestamp, 0), tasks, taskResults)
 	}
 	for _, task := range allTasks {
 		tasks <- task
@@ -391,19 +461,11 @@ func dataHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Requ
 	// Get oncalls.
 	oncalls, err := getOncalls(jirix)
 	if err != nil {
-		respondWithError(jirix, err, w)
-		return
+		return nil, err
 	}
 	result.Oncalls = oncalls
 
-	// Convert results to json and return it.
-	b, err := json.MarshalIndent(&result, "", "  ")
-	if err != nil {
-		respondWithError(jirix, err, w)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(b)
+	return &result, nil
 }
 
 func dataHandler2(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Request) {
@@ -412,7 +474,7 @@ func dataHandler2(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Req
 	w.Write(b)
 }
 
-func getMetricWorker(jirix *jiri.X, s *cloudmonitoring.Service, startTime, endTime time.Time, tasks <-chan getMetricTask, results chan<- getMetricResult) {
+func getMetricWorker(jirix *jiri.X, l monitoring.TimeSeriesLister, startTime, endTime time.Time, tasks <-chan getMetricTask, results chan<- getMetricResult) {
 	for task := range tasks {
 		result := getMetricResult{
 			ResultType:     task.resultType,
@@ -431,55 +493,36 @@ func getMetricWorker(jirix *jiri.X, s *cloudmonitoring.Service, startTime, endTi
 			MainContainer:  task.pod.Spec.Containers[0].Name,
 			ServiceVersion: task.pod.Metadata.Labels.Version,
 		}
-		filters := []string{
-			fmt.Sprintf("metric.type=%q", task.md.Type),
-			fmt.Sprintf("metric.label.metric_name=%q", task.metricName),
-			fmt.Sprintf("metric.label.gce_instance=%q", task.pod.Metadata.Name),
-			fmt.Sprintf("metric.label.gce_zone=%q", task.pod.zone),
+		labels := map[string]string{
+			"metric_name":  task.metricName,
+			"gce_instance": task.pod.Metadata.Name,
+			"gce_zone":     task.pod.zone,
 		}
 		for labelKey, labelValue := range task.extraLabels {
-			filters = append(filters, fmt.Sprintf("metric.label.%s=%q", labelKey, labelValue))
+			labels[labelKey] = labelValue
 		}
-		nextPageToken := ""
-		points := []*cloudmonitoring.Point{}
 		timestamps := []int64{}
 		values := []float64{}
-		for {
-			resp, err := s.Projects.TimeSeries.List("projects/vanadium-production").
-				IntervalStartTime(startTime.UTC().Format(time.RFC3339)).
-				IntervalEndTime(endTime.UTC().Format(time.RFC3339)).
-				Filter(strings.Join(filters, " AND ")).
-				PageToken(nextPageToken).Do()
+		series, err := monitoring.ListTimeSeries(l, monitoring.Query{
+			Project:    "vanadium-production",
+			MetricType: task.md.Type,
+			Labels:     labels,
+			Start:      startTime,
+			End:        endTime,
+		})
+		if err != nil {
+			result.ErrMsg = err.Error()
+		} else {
+			points, err := monitoring.AllPoints(series)
 			if err != nil {
-				result.ErrMsg = fmt.Sprintf("List() failed: %v", err)
-				break
+				result.ErrMsg = err.Error()
 			}
-			if len(resp.TimeSeries) > 0 {
-				// We should only get one timeseries.
-				ts := resp.TimeSeries[0]
-				points = append(points, ts.Points...)
-			}
-			if result.ErrMsg != "" {
-				break
-			}
-			nextPageToken = resp.NextPageToken
-			if nextPageToken == "" {
-				break
-			}
-		}
-		for i := len(points) - 1; i >= 0; i-- {
-			pt := points[i]
-			epochTime, err := time.Parse(time.RFC3339, pt.Interval.EndTime)
-			if err != nil {
-				result.ErrMsg = fmt.Sprintf("Parse(%s) failed: %v", pt.Interval.EndTime)
-				break
+			for _, pt := range points {
+				timestamps = append(timestamps, pt.Time.Unix())
+				values = append(values, pt.Value)
+				result.MaxValue = math.Max(result.MaxValue, pt.Value)
+				result.MinValue = math.Min(result.MinValue, pt.Value)
 			}
-			timestamp := epochTime.Unix()
-			timestamps = append(timestamps, timestamp)
-			value := pt.Value.DoubleValue
-			values = append(values, value)
-			result.MaxValue = math.Max(result.MaxValue, value)
-			result.MinValue = math.Min(result.MinValue, value)
 		}
 		result.HistoryTimestamps = timestamps
 		result.HistoryValues = values
@@ -640,7 +683,7 @@ func getOncalls(jirix *jiri.X) ([]string, error) {
 	return strings.Split(strings.TrimSpace(out.String()), ","), nil
 }
 
-func logsHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Request) {
+func logsHandler(jirix *jiri.X, w http.ResponseWriter, r *http.Request) {
 	// Parse project, zone, pod name, and container.
 	f, err := parseForm(r, "p", "z", "d", "c")
 	if err != nil {
@@ -662,7 +705,7 @@ func logsHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Requ
 	w.Write([]byte(content))
 }
 
-func cfgHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Request) {
+func cfgHandler(jirix *jiri.X, w http.ResponseWriter, r *http.Request) {
 	// Parse project, zone, and pod name.
 	f, err := parseForm(r, "p", "z", "d")
 	if err != nil {
@@ -684,7 +727,7 @@ func cfgHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Reque
 	w.Write([]byte(content))
 }
 
-func picHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Request) {
+func picHandler(jirix *jiri.X, objects *objectCache, w http.ResponseWriter, r *http.Request) {
 	// Parameter "id" specifies the id of the pic.
 	f, err := parseForm(r, "id")
 	if err != nil {
@@ -694,23 +737,17 @@ func picHandler(jirix *jiri.X, root string, w http.ResponseWriter, r *http.Reque
 	id := f["id"]
 
 	// Read picture file from Google Storage.
-	cachedFile, err := cache.StoreGoogleStorageFile(jirix, root, bucketPics, id+".png")
+	obj, err := objects.get(bucketPics + "/" + id + ".png")
 	if err != nil {
 		// Read "_unknown.jpg" as fallback.
-		cachedFile, err = cache.StoreGoogleStorageFile(jirix, root, bucketPics, "_unknown.jpg")
+		obj, err = objects.get(bucketPics + "/_unknown.jpg")
 		if err != nil {
 			respondWithError(jirix, err, w)
 			return
 		}
 	}
-	bytes, err := jirix.NewSeq().ReadFile(cachedFile)
-	if err != nil {
-		respondWithError(jirix, err, w)
-		return
-	}
-	w.Header().Set("Content-Type", "image/jpeg")
 	w.Header().Set("Cache-control", "public, max-age=2592000")
-	w.Write(bytes)
+	serveContent(w, r, "image/jpeg", obj.generation, obj.modTime, obj.data)
 }
 
 func parseForm(r *http.Request, fields ...string) (map[string]string, error) {
@@ -726,6 +763,17 @@ func parseForm(r *http.Request, fields ...string) (map[string]string, error) {
 	return m, nil
 }
 
+// respondWithJSON writes v as JSON to w, answering requests for JSON
+// identical to the one the client already has with 304 Not Modified.
+func respondWithJSON(jirix *jiri.X, v interface{}, w http.ResponseWriter, r *http.Request) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		respondWithError(jirix, err, w)
+		return
+	}
+	serveContent(w, r, "application/json", contentETag(b), time.Time{}, b)
+}
+
 func respondWithError(jirix *jiri.X, err error, w http.ResponseWriter) {
 	fmt.Fprintf(jirix.Stderr(), "%v\n", err)
 	http.Error(w, fmt.Sprintf("500 internal server error\n\n%v", err), http.StatusInternalServerError)
@@ -0,0 +1,309 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"v.io/jiri"
+	"v.io/x/devtools/internal/monitoring"
+	"v.io/x/lib/cmdline"
+	"v.io/x/lib/gcm"
+)
+
+const (
+	// sloFile is the Google Storage location of the JSON file that
+	// stores the SLO compliance and burn-rate series computed by
+	// "oncall collect".
+	sloFile = bucketData + "/slo.json"
+
+	defaultSLOWindow = 28 * 24 * time.Hour
+)
+
+var (
+	slosFlag string
+	stepFlag time.Duration
+)
+
+func init() {
+	cmdCollect.Flags.StringVar(&keyFileFlag, "key", "", "The path to the service account's JSON credentials file.")
+	cmdCollect.Flags.StringVar(&slosFlag, "slos", "", "The path to a JSON file with service level objectives.")
+	cmdCollect.Flags.DurationVar(&stepFlag, "step", time.Hour, "The period of the points of the burn-rate series.")
+}
+
+// sloSchema is the JSON representation of a service level objective in
+// the SLOs config file.
+type sloSchema struct {
+	// Name identifies the objective.
+	Name string
+	// Metric is the name of the latency metric the objective applies
+	// to, e.g. "mounttable".
+	Metric string
+	// Threshold is the latency, in milliseconds, that a check must not
+	// exceed to comply with the objective.
+	Threshold float64
+	// Target is the fraction of the checks that must comply with the
+	// objective, e.g. 0.99.
+	Target float64
+	// Window is the rolling window over which the compliance is
+	// computed, e.g. "672h". The default window is 28 days.
+	Window string
+}
+
+// slo is a parsed sloSchema.
+type slo struct {
+	sloSchema
+	window time.Duration
+}
+
+// sloResult records the compliance of the checks of a metric with a
+// service level objective over its window.
+type sloResult struct {
+	Name      string
+	Metric    string
+	Threshold float64
+	Target    float64
+	// Window is the length of the window in seconds.
+	Window int64
+	// Checks is the number of checks within the window.
+	Checks int
+	// Compliance is the fraction of the checks within the window that
+	// comply with the objective, or -1 if there are no checks.
+	Compliance float64
+	// ErrorBudgetRemaining is the fraction of the error budget, i.e. of
+	// the 1-Target fraction of the checks allowed to violate the
+	// objective, that is not consumed within the window.
+	ErrorBudgetRemaining float64
+	// Timestamps holds the end timestamps of the steps the window is
+	// divided into.
+	Timestamps []int64
+	// BurnRates holds the rate at which the error budget was consumed
+	// in each step, relative to the rate that consumes exactly the
+	// whole budget over the window.
+	BurnRates []float64
+	// BudgetRemaining holds the fraction of the error budget remaining
+	// at the end of each step.
+	BudgetRemaining []float64
+}
+
+// sloData is the content of the SLO file.
+type sloData struct {
+	// Timestamp is the Unix timestamp of the collection.
+	Timestamp int64
+	SLOs      []sloResult
+}
+
+// cmdCollect represents the 'collect' command of the oncall tool.
+var cmdCollect = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runCollect),
+	Name:   "collect",
+	Short:  "Compute and store SLO compliance and burn rates",
+	Long: `
+Compute the compliance of the latency checks of production services with
+service level objectives from the data in GCM, and store the compliance along
+with the error budget burn-rate series in ` + sloFile + `, from which
+"oncall serve" serves them at /data/slo.
+
+The -slos file contains a JSON list of objectives, e.g.:
+  [{"Name": "mounttable latency", "Metric": "mounttable", "Threshold": 500,
+    "Target": 0.99, "Window": "672h"}]
+The objective requires that the fraction Target of the checks within the
+rolling window have latencies of at most Threshold milliseconds.  The window
+defaults to 28 days.  The burn rate of each -step period is the fraction of
+the checks violating the objective divided by 1-Target, so that a burn rate of
+1 consumes exactly the whole error budget over the window.
+`,
+}
+
+func runCollect(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	if slosFlag == "" {
+		return env.UsageErrorf("-slos must be specified")
+	}
+	if stepFlag <= 0 {
+		return env.UsageErrorf("-step must be positive")
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return err
+	}
+	bytes, err := ioutil.ReadFile(slosFlag)
+	if err != nil {
+		return err
+	}
+	slos, err := parseSLOs(bytes)
+	if err != nil {
+		return fmt.Errorf("%v: %v", slosFlag, err)
+	}
+	s, err := gcm.Authenticate(keyFileFlag)
+	if err != nil {
+		return err
+	}
+	md, err := gcm.GetMetric("service-latency", "vanadium-production")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	data := sloData{Timestamp: now.Unix(), SLOs: []sloResult{}}
+	for _, o := range slos {
+		points, err := getLatencyPoints(monitoring.NewGCMLister(s), md.Type, o.Metric, now.Add(-o.window), now)
+		if err != nil {
+			return err
+		}
+		data.SLOs = append(data.SLOs, computeSLO(o, points, now, stepFlag))
+	}
+	return saveSLOData(jirix, data)
+}
+
+// parseSLOs parses the given JSON-encoded list of service level
+// objectives.
+func parseSLOs(bytes []byte) ([]slo, error) {
+	var schemas []sloSchema
+	if err := json.Unmarshal(bytes, &schemas); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(bytes), err)
+	}
+	slos := []slo{}
+	for _, schema := range schemas {
+		if schema.Metric == "" {
+			return nil, fmt.Errorf("SLO %q has no metric", schema.Name)
+		}
+		if schema.Target <= 0 || schema.Target >= 1 {
+			return nil, fmt.Errorf("SLO %q has target %v outside of (0, 1)", schema.Name, schema.Target)
+		}
+		window := defaultSLOWindow
+		if schema.Window != "" {
+			var err error
+			if window, err = time.ParseDuration(schema.Window); err != nil {
+				return nil, fmt.Errorf("SLO %q: %v", schema.Name, err)
+			}
+			if window <= 0 {
+				return nil, fmt.Errorf("SLO %q has non-positive window %v", schema.Name, window)
+			}
+		}
+		slos = append(slos, slo{sloSchema: schema, window: window})
+	}
+	return slos, nil
+}
+
+// getLatencyPoints returns the points of the latency timeseries of all
+// instances of the given metric within the given time window.
+func getLatencyPoints(l monitoring.TimeSeriesLister, metricType, metricName string, start, end time.Time) ([]monitoring.Point, error) {
+	series, err := monitoring.ListTimeSeries(l, monitoring.Query{
+		Project:    "vanadium-production",
+		MetricType: metricType,
+		Labels:     map[string]string{"metric_name": metricName},
+		Start:      start,
+		End:        end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	points := []monitoring.Point{}
+	for _, ts := range series {
+		tsPoints, err := monitoring.Points(ts)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, tsPoints...)
+	}
+	return points, nil
+}
+
+// computeSLO computes the compliance of the given latency points with
+// the given objective over the window of the objective ending at the
+// given time. The window is divided into steps of the given period,
+// the last of which may be shorter; points outside of the window are
+// ignored.
+func computeSLO(o slo, points []monitoring.Point, end time.Time, step time.Duration) sloResult {
+	start := end.Add(-o.window)
+	numSteps := int((o.window + step - 1) / step)
+	good, bad := make([]int, numSteps), make([]int, numSteps)
+	result := sloResult{
+		Name:            o.Name,
+		Metric:          o.Metric,
+		Threshold:       o.Threshold,
+		Target:          o.Target,
+		Window:          int64(o.window / time.Second),
+		Compliance:      -1,
+		Timestamps:      make([]int64, numSteps),
+		BurnRates:       make([]float64, numSteps),
+		BudgetRemaining: make([]float64, numSteps),
+	}
+	for _, pt := range points {
+		if !pt.Time.After(start) || pt.Time.After(end) {
+			continue
+		}
+		i := int((pt.Time.Sub(start) - 1) / step)
+		if pt.Value <= o.Threshold {
+			good[i]++
+		} else {
+			bad[i]++
+		}
+		result.Checks++
+	}
+	// The error budget is the number of checks within the window that
+	// are allowed to violate the objective.
+	budget := (1 - o.Target) * float64(result.Checks)
+	consumed := 0
+	for i := 0; i < numSteps; i++ {
+		stepEnd := start.Add(time.Duration(i+1) * step)
+		if stepEnd.After(end) {
+			stepEnd = end
+		}
+		result.Timestamps[i] = stepEnd.Unix()
+		if total := good[i] + bad[i]; total > 0 {
+			result.BurnRates[i] = float64(bad[i]) / float64(total) / (1 - o.Target)
+		}
+		consumed += bad[i]
+		result.BudgetRemaining[i] = 1
+		if budget > 0 {
+			result.BudgetRemaining[i] = 1 - float64(consumed)/budget
+		}
+	}
+	result.ErrorBudgetRemaining = 1
+	if result.Checks > 0 {
+		result.Compliance = float64(result.Checks-consumed) / float64(result.Checks)
+		result.ErrorBudgetRemaining = result.BudgetRemaining[numSteps-1]
+	}
+	return result
+}
+
+// saveSLOData writes the given SLO data to Google Storage.
+func saveSLOData(jirix *jiri.X, data sloData) error {
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", data, err)
+	}
+	f, err := ioutil.TempFile("", "slo")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return jirix.NewSeq().Last("gsutil", "-q", "cp", f.Name(), sloFile)
+}
+
+// sloHandler serves the SLO data stored by "oncall collect".
+func sloHandler(jirix *jiri.X, objects *objectCache, w http.ResponseWriter, r *http.Request) {
+	obj, err := objects.get(sloFile)
+	if err != nil {
+		respondWithError(jirix, err, w)
+		return
+	}
+	serveContent(w, r, "application/json", obj.generation, obj.modTime, obj.data)
+}
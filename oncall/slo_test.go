@@ -0,0 +1,91 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/x/devtools/internal/monitoring"
+)
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := parseSLOs([]byte(`[
+  {"Name": "mounttable latency", "Metric": "mounttable", "Threshold": 500, "Target": 0.99},
+  {"Name": "proxy latency", "Metric": "proxy service", "Threshold": 100, "Target": 0.9, "Window": "24h"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := len(slos), 2; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := slos[0].window, defaultSLOWindow; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got, want := slos[1].window, 24*time.Hour; got != want {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for _, data := range []string{
+		`[{"Name": "a", "Threshold": 1, "Target": 0.9}]`,
+		`[{"Name": "a", "Metric": "m", "Threshold": 1, "Target": 1}]`,
+		`[{"Name": "a", "Metric": "m", "Threshold": 1}]`,
+		`[{"Name": "a", "Metric": "m", "Threshold": 1, "Target": 0.9, "Window": "28d"}]`,
+		`[{"Name": "a", "Metric": "m", "Threshold": 1, "Target": 0.9, "Window": "-1h"}]`,
+		`{}`,
+	} {
+		if _, err := parseSLOs([]byte(data)); err == nil {
+			t.Fatalf("parsing %v did not fail", data)
+		}
+	}
+}
+
+func TestComputeSLO(t *testing.T) {
+	o := slo{
+		sloSchema: sloSchema{Name: "latency", Metric: "mounttable", Threshold: 500, Target: 0.75},
+		window:    4 * time.Hour,
+	}
+	end := time.Unix(100000, 0)
+	start := end.Add(-o.window)
+	points := []monitoring.Point{
+		// Outside of the window.
+		{Time: start, Value: 1000},
+		{Time: end.Add(time.Second), Value: 1000},
+		// First step.
+		{Time: start.Add(time.Minute), Value: 100},
+		{Time: start.Add(time.Hour), Value: 600},
+		// Second step.
+		{Time: start.Add(2*time.Hour + time.Minute), Value: 500},
+		{Time: start.Add(2*time.Hour + 2*time.Minute), Value: 501},
+		// Third step.
+		{Time: start.Add(3*time.Hour + time.Minute), Value: 1},
+		{Time: start.Add(3*time.Hour + 2*time.Minute), Value: 2},
+		{Time: start.Add(3*time.Hour + 3*time.Minute), Value: 3},
+		{Time: end, Value: 4},
+	}
+	got := computeSLO(o, points, end, 90*time.Minute)
+	want := sloResult{
+		Name:                 "latency",
+		Metric:               "mounttable",
+		Threshold:            500,
+		Target:               0.75,
+		Window:               4 * 3600,
+		Checks:               8,
+		Compliance:           0.75,
+		ErrorBudgetRemaining: 0,
+		Timestamps:           []int64{start.Unix() + 5400, start.Unix() + 10800, end.Unix()},
+		BurnRates:            []float64{2, 2, 0},
+		BudgetRemaining:      []float64{0.5, 0, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	got = computeSLO(o, nil, end, time.Hour)
+	if got.Compliance != -1 || got.ErrorBudgetRemaining != 1 || len(got.BurnRates) != 4 {
+		t.Fatalf("unexpected result without checks: %#v", got)
+	}
+}
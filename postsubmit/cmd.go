@@ -9,24 +9,32 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	"v.io/jiri"
-	"v.io/jiri/gitutil"
 	"v.io/jiri/project"
 	"v.io/jiri/tool"
+	"v.io/x/devtools/internal/revisions"
 	"v.io/x/devtools/internal/test"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
 )
 
 var (
-	jenkinsHostFlag string
+	jenkinsHostFlag        string
+	parameterizedTestsFlag string
+	snapshotLabelFlag      string
+	stateFileFlag          string
 )
 
 func init() {
 	cmdRoot.Flags.StringVar(&jenkinsHostFlag, "host", "", "The Jenkins host. Presubmit will not send any CLs to an empty host.")
 
+	cmdPoll.Flags.StringVar(&parameterizedTestsFlag, "parameterized-tests", "", "Comma-separated list of Jenkins tests that accept build parameters describing the changes that triggered the build.")
+	cmdPoll.Flags.StringVar(&snapshotLabelFlag, "snapshot-label", "", "The label of the snapshot the changes belong to, passed to parameterized builds.")
+	cmdPoll.Flags.StringVar(&stateFileFlag, "state-file", "", "The file that records the project revisions processed by the last poll. Defaults to $JIRI_ROOT/"+defaultStateFileName+".")
+
 	tool.InitializeProjectFlags(&cmdPoll.Flags)
 	tool.InitializeRunFlags(&cmdRoot.Flags)
 }
@@ -42,7 +50,7 @@ var cmdRoot = &cmdline.Command{
 	Long: `
 Command postsubmit performs Vanadium postsubmit related functions.
 `,
-	Children: []*cmdline.Command{cmdPoll},
+	Children: []*cmdline.Command{cmdPoll, cmdStatus},
 }
 
 // cmdPoll represents the "poll" command of the postsubmit tool.
@@ -50,17 +58,51 @@ var cmdPoll = &cmdline.Command{
 	Runner: jiri.RunnerFunc(runPoll),
 	Name:   "poll",
 	Short:  "Poll changes and start corresponding builds on Jenkins",
-	Long:   "Poll changes and start corresponding builds on Jenkins.",
+	Long: `
+Poll changes and start corresponding builds on Jenkins.
+
+The revisions of the main branches of all local git and mercurial projects are
+compared against the revisions recorded in the state file by the last poll;
+builds are started for the tests of the projects that have new changes. The
+state file is updated after the builds have been started. If the state file
+does not exist, the current revisions are recorded and no builds are started.
+
+Builds of the tests listed by the -parameterized-tests flag are started with the
+following parameters, so that they can record what they tested and skip work
+when unrelated projects changed:
+  PROJECTS:  the ':'-separated names of the changed projects
+  REVISIONS: the ':'-separated <project>=<old>..<new> revision ranges of the
+             changed projects; <old> is empty for new projects
+  SNAPSHOT:  the value of the -snapshot-label flag
+`,
 }
 
 func runPoll(jirix *jiri.X, _ []string) error {
-	projects, err := getChangedProjectsFromSnapshot(jirix, jirix.UpdateHistorySecondLatestLink())
+	statePath := stateFilePath(jirix)
+	state, err := loadPollState(jirix, statePath)
+	if err != nil {
+		return err
+	}
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	revisions, err := getProjectRevisions(jirix, localProjects)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		fmt.Fprintf(jirix.Stdout(), "No previous poll state. Recording current revisions in %s.\n", statePath)
+		return savePollState(jirix, statePath, &pollState{Revisions: revisions})
+	}
+	projects, err := filterChangedProjects(jirix, localProjects, state.changedProjects(revisions), state.Revisions)
 	if err != nil {
 		return err
 	}
 	if len(projects) == 0 {
 		fmt.Fprintf(jirix.Stdout(), "No changes.\n")
-		return nil
+		state.Revisions = revisions
+		return savePollState(jirix, statePath, state)
 	}
 	fmt.Fprintf(jirix.Stdout(), "Projects with new changes:\n%s\n", strings.Join(projects, "\n"))
 
@@ -73,39 +115,14 @@ func runPoll(jirix *jiri.X, _ []string) error {
 
 	// Start Jenkins tests.
 	fmt.Fprintf(jirix.Stdout(), "\nStarting new builds:\n")
-	if err := startJenkinsTests(jirix, jenkinsTests); err != nil {
+	params := buildParameters(projects, state.Revisions, revisions, snapshotLabelFlag)
+	if err := startJenkinsTests(jirix, jenkinsTests, params); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// getChangedProjectsFromSnapshot returns a slice of projects that have changes
-// by comparing the revisions in the given snapshot with master branches.
-func getChangedProjectsFromSnapshot(jirix *jiri.X, snapshotFile string) ([]string, error) {
-	projects, _, err := project.LoadSnapshotFile(jirix, snapshotFile)
-	if err != nil {
-		return nil, err
-	}
-
-	// Use "git log" to detect changes for each project.
-	//
-	// TODO(jingjin, jsimsa): Add support for non-git projects.
-	changedProjects := []string{}
-	for _, project := range projects {
-		switch project.Protocol {
-		case "git":
-			git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path))
-			commits, err := git.Log("master", project.Revision, "")
-			if err != nil {
-				return nil, err
-			}
-			if len(commits) != 0 {
-				changedProjects = append(changedProjects, project.Name)
-			}
-		}
-	}
-	return changedProjects, nil
+	// Record the processed revisions.
+	state.Revisions = revisions
+	return savePollState(jirix, statePath, state)
 }
 
 // jenkinsTestsToStart returns a list of jenkins tests that need to be
@@ -121,17 +138,50 @@ func jenkinsTestsToStart(jirix *jiri.X, projects []string) ([]string, error) {
 	return config.ProjectTests(projects), nil
 }
 
+// buildParameters returns the parameters describing the changes of the
+// given projects between the given old and new revisions.
+func buildParameters(projects []string, oldRevisions, newRevisions map[string]string, snapshotLabel string) url.Values {
+	ranges := []revisions.Range{}
+	for _, project := range projects {
+		ranges = append(ranges, revisions.Range{Project: project, Old: oldRevisions[project], New: newRevisions[project]})
+	}
+	return url.Values{
+		"PROJECTS":  {strings.Join(projects, ":")},
+		"REVISIONS": {revisions.Format(ranges)},
+		"SNAPSHOT":  {snapshotLabel},
+	}
+}
+
+// parameterizedTests returns the set of Jenkins tests identified by the
+// -parameterized-tests flag.
+func parameterizedTests() map[string]bool {
+	result := map[string]bool{}
+	for _, t := range strings.Split(parameterizedTestsFlag, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			result[t] = true
+		}
+	}
+	return result
+}
+
 // startJenkinsTests uses Jenkins API to start a build to each of the
-// given Jenkins tests.
-func startJenkinsTests(jirix *jiri.X, tests []string) error {
+// given Jenkins tests. Builds of parameterized tests are started with
+// the given parameters.
+func startJenkinsTests(jirix *jiri.X, tests []string, params url.Values) error {
 	jenkins, err := jirix.Jenkins(jenkinsHostFlag)
 	if err != nil {
 		return err
 	}
 
+	parameterized := parameterizedTests()
 	for _, t := range tests {
 		msg := fmt.Sprintf("add build to %q\n", t)
-		if err := jenkins.AddBuild(t); err == nil {
+		if parameterized[t] {
+			err = jenkins.AddBuildWithParameter(t, params)
+		} else {
+			err = jenkins.AddBuild(t)
+		}
+		if err == nil {
 			test.Pass(jirix.Context, "%s", msg)
 		} else {
 			test.Fail(jirix.Context, "%s", msg)
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"net/url"
 	"reflect"
 	"testing"
 
@@ -64,3 +65,24 @@ func TestJenkinsTestsToStart(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildParameters(t *testing.T) {
+	oldRevisions := map[string]string{
+		"release.go.core": "rev1",
+		"release.js.core": "rev2",
+	}
+	newRevisions := map[string]string{
+		"release.go.core": "rev3",
+		"release.js.core": "rev2",
+		"release.go.x":    "rev4",
+	}
+	got := buildParameters([]string{"release.go.core", "release.go.x"}, oldRevisions, newRevisions, "stable-go")
+	want := url.Values{
+		"PROJECTS":  {"release.go.core:release.go.x"},
+		"REVISIONS": {"release.go.core=rev1..rev3:release.go.x=..rev4"},
+		"SNAPSHOT":  {"stable-go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
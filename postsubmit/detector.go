@@ -0,0 +1,130 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/gitutil"
+	"v.io/jiri/project"
+)
+
+// changeDetector detects changes of projects that use a particular
+// version control system.
+type changeDetector interface {
+	// CurrentRevision returns the current revision of the main branch
+	// of the given project.
+	CurrentRevision(jirix *jiri.X, p project.Project) (string, error)
+	// HasChanges checks whether the main branch of the given project
+	// has changes that are not reachable from the given revision.
+	HasChanges(jirix *jiri.X, p project.Project, sinceRev string) (bool, error)
+}
+
+// changeDetectors maps project protocols to their change detectors.
+// Projects whose protocol has no change detector are not polled.
+var changeDetectors = map[string]changeDetector{
+	"git": gitChangeDetector{},
+	"hg":  hgChangeDetector{},
+}
+
+// gitChangeDetector detects changes of the master branch of git
+// projects.
+type gitChangeDetector struct{}
+
+func (gitChangeDetector) CurrentRevision(jirix *jiri.X, p project.Project) (string, error) {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(p.Path))
+	return git.CurrentRevisionOfBranch("master")
+}
+
+func (gitChangeDetector) HasChanges(jirix *jiri.X, p project.Project, sinceRev string) (bool, error) {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(p.Path))
+	commits, err := git.Log("master", sinceRev, "")
+	if err != nil {
+		return false, err
+	}
+	return len(commits) != 0, nil
+}
+
+// hgChangeDetector detects changes of the default branch of mercurial
+// projects.
+type hgChangeDetector struct{}
+
+func (hgChangeDetector) CurrentRevision(jirix *jiri.X, p project.Project) (string, error) {
+	return hgLog(jirix, p, "default")
+}
+
+func (hgChangeDetector) HasChanges(jirix *jiri.X, p project.Project, sinceRev string) (bool, error) {
+	revisions, err := hgLog(jirix, p, fmt.Sprintf("only(default, %s)", sinceRev))
+	if err != nil {
+		return false, err
+	}
+	return revisions != "", nil
+}
+
+// hgLog returns the newline-separated revisions of the given mercurial
+// project that match the given revision set.
+func hgLog(jirix *jiri.X, p project.Project, revset string) (string, error) {
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Pushd(p.Path).Capture(&out, nil).
+		Last("hg", "log", "--rev", revset, "--template", "{node}\n"); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// getProjectRevisions returns a map from the names of the given projects
+// to the current revisions of their main branches.
+func getProjectRevisions(jirix *jiri.X, projects project.Projects) (map[string]string, error) {
+	revisions := map[string]string{}
+	for _, p := range projects {
+		detector, ok := changeDetectors[p.Protocol]
+		if !ok {
+			continue
+		}
+		revision, err := detector.CurrentRevision(jirix, p)
+		if err != nil {
+			return nil, err
+		}
+		revisions[p.Name] = revision
+	}
+	return revisions, nil
+}
+
+// filterChangedProjects returns the subset of the given project names
+// whose main branches have changes since the given revisions. Projects
+// that have no revision are considered changed.
+func filterChangedProjects(jirix *jiri.X, projects project.Projects, names []string, sinceRevisions map[string]string) ([]string, error) {
+	byName := map[string]project.Project{}
+	for _, p := range projects {
+		byName[p.Name] = p
+	}
+	result := []string{}
+	for _, name := range names {
+		p, ok := byName[name]
+		if !ok {
+			continue
+		}
+		sinceRev, ok := sinceRevisions[name]
+		if !ok {
+			result = append(result, name)
+			continue
+		}
+		detector, ok := changeDetectors[p.Protocol]
+		if !ok {
+			continue
+		}
+		changed, err := detector.HasChanges(jirix, p, sinceRev)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
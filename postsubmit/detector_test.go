@@ -0,0 +1,86 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/jiri"
+	"v.io/jiri/jiritest"
+	"v.io/jiri/project"
+)
+
+// fakeChangeDetector is a change detector whose projects have the
+// revisions recorded in its history, oldest first.
+type fakeChangeDetector struct {
+	history map[string][]string
+}
+
+func (d fakeChangeDetector) CurrentRevision(_ *jiri.X, p project.Project) (string, error) {
+	revisions := d.history[p.Name]
+	return revisions[len(revisions)-1], nil
+}
+
+func (d fakeChangeDetector) HasChanges(_ *jiri.X, p project.Project, sinceRev string) (bool, error) {
+	revisions := d.history[p.Name]
+	for i, revision := range revisions {
+		if revision == sinceRev {
+			return i != len(revisions)-1, nil
+		}
+	}
+	return true, nil
+}
+
+func TestFilterChangedProjects(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	changeDetectors["fake"] = fakeChangeDetector{
+		history: map[string][]string{
+			"changed":   []string{"rev1", "rev2"},
+			"unchanged": []string{"rev1"},
+			"rewound":   []string{"rev1", "rev2"},
+			"new":       []string{"rev1"},
+		},
+	}
+	defer delete(changeDetectors, "fake")
+
+	projects := project.Projects{}
+	for _, name := range []string{"changed", "unchanged", "rewound", "new"} {
+		p := project.Project{Name: name, Protocol: "fake"}
+		projects[p.Key()] = p
+	}
+	unsupported := project.Project{Name: "unsupported", Protocol: "svn"}
+	projects[unsupported.Key()] = unsupported
+
+	revisions, err := getProjectRevisions(fake.X, projects)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wantRevisions := map[string]string{
+		"changed":   "rev2",
+		"unchanged": "rev1",
+		"rewound":   "rev2",
+		"new":       "rev1",
+	}
+	if !reflect.DeepEqual(revisions, wantRevisions) {
+		t.Fatalf("want %v, got %v", wantRevisions, revisions)
+	}
+
+	sinceRevisions := map[string]string{
+		"changed":   "rev1",
+		"unchanged": "rev1",
+		"rewound":   "rev2",
+	}
+	names := []string{"changed", "new", "rewound", "unchanged", "unsupported"}
+	got, err := filterChangedProjects(fake.X, projects, names, sinceRevisions)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{"changed", "new"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
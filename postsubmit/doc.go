@@ -13,6 +13,7 @@ Usage:
 
 The postsubmit commands are:
    poll        Poll changes and start corresponding builds on Jenkins
+   status      Summarize the last build results of the postsubmit jobs
    help        Display help for commands or topics
 
 The postsubmit flags are:
@@ -33,12 +34,59 @@ Postsubmit poll - Poll changes and start corresponding builds on Jenkins
 
 Poll changes and start corresponding builds on Jenkins.
 
+The revisions of the main branches of all local git and mercurial projects are
+compared against the revisions recorded in the state file by the last poll;
+builds are started for the tests of the projects that have new changes. The
+state file is updated after the builds have been started. If the state file
+does not exist, the current revisions are recorded and no builds are started.
+
+Builds of the tests listed by the -parameterized-tests flag are started with the
+following parameters, so that they can record what they tested and skip work
+when unrelated projects changed:
+  PROJECTS:  the ':'-separated names of the changed projects
+  REVISIONS: the ':'-separated <project>=<old>..<new> revision ranges of the
+             changed projects; <old> is empty for new projects
+  SNAPSHOT:  the value of the -snapshot-label flag
+
 Usage:
    postsubmit poll [flags]
 
 The postsubmit poll flags are:
  -manifest=
    Name of the project manifest.
+ -parameterized-tests=
+   Comma-separated list of Jenkins tests that accept build parameters describing
+   the changes that triggered the build.
+ -snapshot-label=
+   The label of the snapshot the changes belong to, passed to parameterized
+   builds.
+ -state-file=
+   The file that records the project revisions processed by the last poll.
+   Defaults to $JIRI_ROOT/.postsubmit_state.json.
+
+ -color=true
+   Use color to format output.
+ -host=
+   The Jenkins host. Presubmit will not send any CLs to an empty host.
+ -v=false
+   Print verbose output.
+
+Postsubmit status - Summarize the last build results of the postsubmit jobs
+
+Summarize the last build results of the postsubmit jobs.
+
+For each Jenkins job that the tools config maps to a project, the last completed
+build is looked up and its result, start time (in UTC) and the revision ranges
+of the changes it tested are printed. The revision ranges come from the
+REVISIONS parameter of the builds started by "postsubmit poll" and identify the
+candidate culprits of failures.
+
+Usage:
+   postsubmit status [flags]
+
+The postsubmit status flags are:
+ -json=false
+   Print the build results in the JSON format.
 
  -color=true
    Use color to format output.
@@ -0,0 +1,81 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"v.io/jiri"
+	"v.io/jiri/runutil"
+)
+
+const defaultStateFileName = ".postsubmit_state.json"
+
+// pollState records the revisions of the projects that were processed
+// by the last successful poll.
+type pollState struct {
+	// Revisions maps project names to the last processed revision.
+	Revisions map[string]string `json:"revisions"`
+}
+
+// stateFilePath returns the path of the file that stores the poll state.
+func stateFilePath(jirix *jiri.X) string {
+	if stateFileFlag != "" {
+		return stateFileFlag
+	}
+	return filepath.Join(jirix.Root, defaultStateFileName)
+}
+
+// loadPollState loads the poll state from the given file. It returns
+// nil if the file does not exist.
+func loadPollState(jirix *jiri.X, path string) (*pollState, error) {
+	data, err := jirix.NewSeq().ReadFile(path)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state pollState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(data), err)
+	}
+	if state.Revisions == nil {
+		state.Revisions = map[string]string{}
+	}
+	return &state, nil
+}
+
+// savePollState atomically writes the given poll state to the given
+// file, by writing to a temporary file first and then renaming it.
+func savePollState(jirix *jiri.X, path string, state *pollState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", state, err)
+	}
+	tmpPath := path + ".tmp"
+	return jirix.NewSeq().
+		MkdirAll(filepath.Dir(path), os.FileMode(0755)).
+		WriteFile(tmpPath, data, os.FileMode(0644)).
+		Rename(tmpPath, path).Done()
+}
+
+// changedProjects returns the sorted names of the projects whose
+// current revisions differ from the revisions recorded in the state.
+// Projects that have no recorded revision are considered changed.
+func (state *pollState) changedProjects(revisions map[string]string) []string {
+	changed := []string{}
+	for name, revision := range revisions {
+		if state.Revisions[name] != revision {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"v.io/jiri/jiritest"
+)
+
+func TestPollStateSerialization(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	path := filepath.Join(fake.X.Root, "state", defaultStateFileName)
+	state, err := loadPollState(fake.X, path)
+	if err != nil {
+		t.Fatalf("want no errors, got: %v", err)
+	}
+	if state != nil {
+		t.Fatalf("want no state, got %v", state)
+	}
+	want := &pollState{Revisions: map[string]string{
+		"release.go.core": "abc",
+		"release.js.core": "def",
+	}}
+	if err := savePollState(fake.X, path, want); err != nil {
+		t.Fatalf("want no errors, got: %v", err)
+	}
+	got, err := loadPollState(fake.X, path)
+	if err != nil {
+		t.Fatalf("want no errors, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestChangedProjects(t *testing.T) {
+	state := &pollState{Revisions: map[string]string{
+		"release.go.core": "abc",
+		"release.js.core": "def",
+		"release.removed": "ghi",
+	}}
+	testCases := []struct {
+		revisions map[string]string
+		want      []string
+	}{
+		{
+			revisions: map[string]string{
+				"release.go.core": "abc",
+				"release.js.core": "def",
+			},
+			want: []string{},
+		},
+		{
+			revisions: map[string]string{
+				"release.go.core": "xyz",
+				"release.js.core": "def",
+			},
+			want: []string{"release.go.core"},
+		},
+		{
+			revisions: map[string]string{
+				"release.go.core": "abc",
+				"release.js.core": "xyz",
+				"release.new":     "abc",
+			},
+			want: []string{"release.js.core", "release.new"},
+		},
+	}
+	for _, test := range testCases {
+		if got := state.changedProjects(test.revisions); !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("want %v, got %v", test.want, got)
+		}
+	}
+}
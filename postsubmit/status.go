@@ -0,0 +1,175 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/jenkins"
+	"v.io/x/devtools/internal/revisions"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/tooldata"
+	"v.io/x/lib/cmdline"
+)
+
+var jsonFlag bool
+
+func init() {
+	cmdStatus.Flags.BoolVar(&jsonFlag, "json", false, "Print the build results in the JSON format.")
+}
+
+// cmdStatus represents the "status" command of the postsubmit tool.
+var cmdStatus = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runStatus),
+	Name:   "status",
+	Short:  "Summarize the last build results of the postsubmit jobs",
+	Long: `
+Summarize the last build results of the postsubmit jobs.
+
+For each Jenkins job that the tools config maps to a project, the last completed
+build is looked up and its result, start time (in UTC) and the revision ranges
+of the changes it tested are printed. The revision ranges come from the
+REVISIONS parameter of the builds started by "postsubmit poll" and identify the
+candidate culprits of failures.
+`,
+}
+
+// jobStatus summarizes the last completed build of a Jenkins job.
+type jobStatus struct {
+	Job    string `json:"job"`
+	Number int    `json:"number"`
+	Result string `json:"result"`
+	// Timestamp is the start time of the build, in milliseconds since
+	// the epoch.
+	Timestamp int64 `json:"timestamp"`
+	// Revisions holds the <project>=<old>..<new> revision ranges of the
+	// changes tested by the build, separated by ':'.
+	Revisions string `json:"revisions,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runStatus(jirix *jiri.X, _ []string) error {
+	if jenkinsHostFlag == "" {
+		return jirix.UsageErrorf("-host flag is required")
+	}
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return err
+	}
+	jenkinsObj, err := jirix.Jenkins(jenkinsHostFlag)
+	if err != nil {
+		return err
+	}
+	statuses := []jobStatus{}
+	for _, job := range config.ProjectTests(config.Projects()) {
+		status, err := lastBuildStatus(jenkinsObj, job)
+		if err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+			status = jobStatus{Job: job, Error: err.Error()}
+		}
+		statuses = append(statuses, status)
+	}
+	if jsonFlag {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent(%v) failed: %v", statuses, err)
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+		return nil
+	}
+	printJobStatuses(jirix.Stdout(), statuses, jirix.Color())
+	return nil
+}
+
+// lastBuildStatus looks up the last completed build of the given job.
+func lastBuildStatus(jenkinsObj *jenkins.Jenkins, job string) (jobStatus, error) {
+	info, err := jenkinsObj.LastCompletedBuildStatus(job, nil)
+	if err != nil {
+		return jobStatus{}, err
+	}
+	return newJobStatus(job, info), nil
+}
+
+// newJobStatus summarizes the given build of the given job.
+func newJobStatus(job string, info *jenkins.BuildInfo) jobStatus {
+	return jobStatus{
+		Job:       job,
+		Number:    info.Number,
+		Result:    info.Result,
+		Timestamp: info.Timestamp,
+		Revisions: revisions.FromBuild(info),
+	}
+}
+
+// shortRevisions abbreviates the revisions in the given revision ranges.
+func shortRevisions(revs string) string {
+	short := func(revision string) string {
+		if len(revision) > 7 {
+			return revision[:7]
+		}
+		return revision
+	}
+	ranges := []string{}
+	for _, r := range revisions.Parse(revs) {
+		r.Old, r.New = short(r.Old), short(r.New)
+		ranges = append(ranges, r.String())
+	}
+	return strings.Join(ranges, " ")
+}
+
+// resultColors maps the results of Jenkins builds to the colors they
+// are printed in.
+var resultColors = map[string]test.Color{
+	"ABORTED":  test.Magenta,
+	"FAILURE":  test.Red,
+	"SUCCESS":  test.Green,
+	"UNSTABLE": test.Yellow,
+}
+
+// printJobStatuses prints the given job statuses as a table, coloring
+// the results if requested.
+func printJobStatuses(w io.Writer, statuses []jobStatus, color bool) {
+	rows := [][]string{{"JOB", "BUILD", "RESULT", "STARTED", "CHANGES"}}
+	for _, s := range statuses {
+		if s.Error != "" {
+			rows = append(rows, []string{s.Job, "-", "UNKNOWN", "-", "-"})
+			continue
+		}
+		started := time.Unix(0, s.Timestamp*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04")
+		changes := "-"
+		if s.Revisions != "" {
+			changes = shortRevisions(s.Revisions)
+		}
+		rows = append(rows, []string{s.Job, fmt.Sprintf("%d", s.Number), s.Result, started, changes})
+	}
+	// The columns are padded before coloring, which would otherwise
+	// throw off the alignment.
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for n, row := range rows {
+		cells := []string{}
+		for i, cell := range row {
+			if i < len(row)-1 {
+				cell = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+			if c, ok := resultColors[row[2]]; ok && color && n > 0 && i == 2 {
+				cell = test.ColorString(cell, c)
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(cells, "  "))
+	}
+}
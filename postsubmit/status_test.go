@@ -0,0 +1,57 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"v.io/jiri/jenkins"
+)
+
+func TestJobStatus(t *testing.T) {
+	info := &jenkins.BuildInfo{
+		Actions: []jenkins.BuildInfoAction{
+			{},
+			{Parameters: []jenkins.BuildInfoParameter{
+				{Name: "PROJECTS", Value: "release.go.core"},
+				{Name: "REVISIONS", Value: "release.go.core=0123456789abcdef..fedcba9876543210"},
+			}},
+		},
+		Number:    42,
+		Result:    "FAILURE",
+		Timestamp: 1460000000000,
+	}
+	status := newJobStatus("vanadium-go-test", info)
+	want := jobStatus{
+		Job:       "vanadium-go-test",
+		Number:    42,
+		Result:    "FAILURE",
+		Timestamp: 1460000000000,
+		Revisions: "release.go.core=0123456789abcdef..fedcba9876543210",
+	}
+	if status != want {
+		t.Fatalf("want %#v, got %#v", want, status)
+	}
+
+	statuses := []jobStatus{
+		{Job: "vanadium-go-build", Number: 7, Result: "SUCCESS", Timestamp: 1460000061000},
+		status,
+		{Job: "vanadium-js-unit", Error: "not found"},
+	}
+	var out bytes.Buffer
+	printJobStatuses(&out, statuses, false)
+	wantOut := strings.Join([]string{
+		"JOB                BUILD  RESULT   STARTED           CHANGES",
+		"vanadium-go-build  7      SUCCESS  2016-04-07 03:34  -",
+		"vanadium-go-test   42     FAILURE  2016-04-07 03:33  release.go.core=0123456..fedcba9",
+		"vanadium-js-unit   -      UNKNOWN  -                 -",
+		"",
+	}, "\n")
+	if got := out.String(); got != wantOut {
+		t.Fatalf("want\n%s\ngot\n%s", wantOut, got)
+	}
+}
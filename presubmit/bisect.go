@@ -0,0 +1,269 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/jenkins"
+	"v.io/jiri/project"
+	"v.io/x/devtools/internal/revisions"
+	"v.io/x/lib/cmdline"
+)
+
+var (
+	bisectAxesFlag      string
+	bisectCaseFlag      string
+	bisectMaxBuildsFlag int
+	bisectTestFlag      string
+)
+
+func init() {
+	cmdBisect.Flags.StringVar(&bisectAxesFlag, "axes", "", "Comma-separated list of <axis>=<value> pairs that select the configuration of a multi-configuration job, e.g. ARCH=amd64,OS=linux.")
+	cmdBisect.Flags.StringVar(&bisectCaseFlag, "case", "", "The failed test case, as <class>.<name>.")
+	cmdBisect.Flags.IntVar(&bisectMaxBuildsFlag, "max-builds", 50, "The maximum number of postsubmit builds to examine.")
+	cmdBisect.Flags.StringVar(&bisectTestFlag, "test", "", "The name of the postsubmit test, which is also the name of its Jenkins job.")
+}
+
+// cmdBisect represents the 'bisect' command of the presubmit tool.
+var cmdBisect = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runBisect),
+	Name:   "bisect",
+	Short:  "Find the postsubmit build that a test case started failing in",
+	Long: `
+This subcommand walks backwards through the completed builds of the postsubmit
+job of the given test, starting with the last one, until it finds a build in
+which the given test case did not fail. It then prints the first build in which
+the test case failed, and the revision ranges of the changes tested by that
+build, which come from the REVISIONS parameter of the builds started by
+"postsubmit poll". For each revision range of a project present locally, the
+commits it covers are printed as the candidate culprits.
+
+Builds without test results, for example because the build itself failed, are
+skipped, and the revision ranges of the skipped builds are printed as well.
+
+The presubmit report suggests this subcommand for new failures of tests whose
+postsubmit build is failing as well.
+`,
+}
+
+// bisectBuild records whether a test case failed in a postsubmit build.
+type bisectBuild struct {
+	number int
+	// known records whether the build has test results.
+	known   bool
+	failing bool
+}
+
+// bisectBuilds inputs the builds of a postsubmit test ordered from the
+// newest to the oldest, and returns the oldest build that the test case
+// failed in, followed by the builds without test results that ran
+// between it and the newest older build in which the test case did not
+// fail, if any. The second result records whether such a build exists.
+func bisectBuilds(builds []bisectBuild) ([]bisectBuild, bool, error) {
+	var culprits []bisectBuild
+	for _, build := range builds {
+		switch {
+		case !build.known:
+			if len(culprits) > 0 {
+				culprits = append(culprits, build)
+			}
+		case build.failing:
+			culprits = []bisectBuild{build}
+		case len(culprits) == 0:
+			return nil, false, fmt.Errorf("the test case did not fail in build %d", build.number)
+		default:
+			return culprits, true, nil
+		}
+	}
+	if len(culprits) == 0 {
+		return nil, false, fmt.Errorf("the test case did not fail in any of the examined builds")
+	}
+	return culprits, false, nil
+}
+
+// parseAxes parses the given comma-separated list of <axis>=<value>
+// pairs. It returns nil for an empty list.
+func parseAxes(axes string) (map[string]string, error) {
+	if axes == "" {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, pair := range strings.Split(axes, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid axis %q, want <axis>=<value>", pair)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+// splitTestCase splits the given <class>.<name> test case into its
+// class name and name. Class names can contain periods, but names are
+// assumed not to.
+func splitTestCase(testCase string) (string, string, error) {
+	i := strings.LastIndex(testCase, ".")
+	if i <= 0 || i == len(testCase)-1 {
+		return "", "", fmt.Errorf("invalid test case %q, want <class>.<name>", testCase)
+	}
+	return testCase[:i], testCase[i+1:], nil
+}
+
+// bisectCommand returns the command line that runs the bisect
+// subcommand for the given failed test case.
+func bisectCommand(testName string, axes map[string]string, className, testCaseName string) string {
+	args := []string{"presubmit", "bisect", "-test=" + testName, "-case=" + className + "." + testCaseName}
+	if len(axes) > 0 {
+		pairs := []string{}
+		for axis, value := range axes {
+			pairs = append(pairs, axis+"="+value)
+		}
+		sort.Strings(pairs)
+		args = append(args, "-axes="+strings.Join(pairs, ","))
+	}
+	return strings.Join(args, " ")
+}
+
+// runBisect implements the "bisect" subcommand.
+func runBisect(jirix *jiri.X, args []string) error {
+	if jenkinsHostFlag == "" {
+		return jirix.UsageErrorf("-host flag is required")
+	}
+	if bisectTestFlag == "" {
+		return jirix.UsageErrorf("-test flag is required")
+	}
+	className, testCaseName, err := splitTestCase(bisectCaseFlag)
+	if err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	axes, err := parseAxes(bisectAxesFlag)
+	if err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	jenkinsObj, err := jirix.Jenkins(jenkinsHostFlag)
+	if err != nil {
+		return err
+	}
+	lastBuildInfo, err := jenkinsObj.LastCompletedBuildStatus(bisectTestFlag, axes)
+	if err != nil {
+		return err
+	}
+	lastID, err := strconv.Atoi(lastBuildInfo.Id)
+	if err != nil {
+		return fmt.Errorf("Atoi(%v) failed: %v", lastBuildInfo.Id, err)
+	}
+
+	// Collect the results of the test case, from the newest build to
+	// the oldest one, until a build in which it did not fail.
+	builds := []bisectBuild{}
+	for id := lastID; id > 0 && id > lastID-bisectMaxBuildsFlag; id-- {
+		buildSpec := jenkins.GenBuildSpec(bisectTestFlag, axes, fmt.Sprintf("%d", id))
+		build := bisectBuild{number: id}
+		if cases, err := jenkinsObj.FailedTestCasesForBuildSpec(buildSpec); err == nil {
+			build.known = true
+			for _, c := range cases {
+				if c.ClassName == className && c.Name == testCaseName {
+					build.failing = true
+					break
+				}
+			}
+		}
+		printf(jirix.Stdout(), "Build %d: %s\n", id, bisectOutcome(build))
+		builds = append(builds, build)
+		if build.known && !build.failing {
+			break
+		}
+	}
+	culprits, found, err := bisectBuilds(builds)
+	if err != nil {
+		return err
+	}
+	if !found {
+		printf(jirix.Stdout(), "No build without the failure in the last %d builds; the failure may predate them.\n", len(builds))
+	}
+	printf(jirix.Stdout(), "### First failing build: %d\n", culprits[0].number)
+
+	// Print the changes tested by the first failing build, and by any
+	// builds without test results that ran before it.
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	byName := map[string]project.Project{}
+	for _, p := range localProjects {
+		byName[p.Name] = p
+	}
+	for _, build := range culprits {
+		buildSpec := jenkins.GenBuildSpec(bisectTestFlag, axes, fmt.Sprintf("%d", build.number))
+		info, err := jenkinsObj.BuildInfoForSpec(buildSpec)
+		if err != nil {
+			return err
+		}
+		revs := revisions.FromBuild(info)
+		if revs == "" {
+			printf(jirix.Stdout(), "Build %d has no %s parameter.\n", build.number, revisions.Parameter)
+			continue
+		}
+		printf(jirix.Stdout(), "Changes tested by build %d:\n", build.number)
+		for _, r := range revisions.Parse(revs) {
+			fmt.Fprintf(jirix.Stdout(), "%v\n", r)
+			p, ok := byName[r.Project]
+			if !ok || p.Protocol != "git" {
+				continue
+			}
+			commits, err := gitCommits(jirix, p, r)
+			if err != nil {
+				fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+				continue
+			}
+			for _, commit := range commits {
+				fmt.Fprintf(jirix.Stdout(), "  %s\n", commit)
+			}
+		}
+	}
+	return nil
+}
+
+// bisectOutcome describes the result of the test case in the given
+// build.
+func bisectOutcome(build bisectBuild) string {
+	switch {
+	case !build.known:
+		return "no test results"
+	case build.failing:
+		return "FAILED"
+	default:
+		return "did not fail"
+	}
+}
+
+// gitCommits returns the one-line summaries of the commits of the given
+// git project in the given revision range. A range without an old
+// revision covers the new revision only.
+func gitCommits(jirix *jiri.X, p project.Project, r revisions.Range) ([]string, error) {
+	args := []string{"log", "--format=%h %s"}
+	if r.Old == "" {
+		args = append(args, "-1", r.New)
+	} else {
+		args = append(args, r.Old+".."+r.New)
+	}
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Pushd(p.Path).Capture(&out, nil).Last("git", args...); err != nil {
+		return nil, err
+	}
+	commits := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line != "" {
+			commits = append(commits, line)
+		}
+	}
+	return commits, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"v.io/x/devtools/tooldata"
+)
+
+func TestBisectBuilds(t *testing.T) {
+	fail := func(n int) bisectBuild { return bisectBuild{number: n, known: true, failing: true} }
+	pass := func(n int) bisectBuild { return bisectBuild{number: n, known: true} }
+	unknown := func(n int) bisectBuild { return bisectBuild{number: n} }
+	testCases := []struct {
+		builds   []bisectBuild
+		culprits []bisectBuild
+		found    bool
+		err      bool
+	}{
+		{
+			builds:   []bisectBuild{fail(10), fail(9), pass(8)},
+			culprits: []bisectBuild{fail(9)},
+			found:    true,
+		},
+		{
+			// Builds without results before the first known failure
+			// are candidates, unlike the ones between failures.
+			builds:   []bisectBuild{unknown(10), fail(9), unknown(8), fail(7), unknown(6), unknown(5), pass(4)},
+			culprits: []bisectBuild{fail(7), unknown(6), unknown(5)},
+			found:    true,
+		},
+		{
+			builds:   []bisectBuild{fail(10), fail(9)},
+			culprits: []bisectBuild{fail(9)},
+		},
+		{
+			builds: []bisectBuild{unknown(10), pass(9)},
+			err:    true,
+		},
+		{
+			builds: []bisectBuild{unknown(10)},
+			err:    true,
+		},
+	}
+	for _, test := range testCases {
+		culprits, found, err := bisectBuilds(test.builds)
+		if test.err {
+			if err == nil {
+				t.Errorf("bisecting %v did not fail", test.builds)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if !reflect.DeepEqual(culprits, test.culprits) || found != test.found {
+			t.Errorf("want %v, %v, got %v, %v", test.culprits, test.found, culprits, found)
+		}
+	}
+}
+
+func TestSplitTestCase(t *testing.T) {
+	className, name, err := splitTestCase("v.io/x/ref/services/mounttable.TestMount")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := className, "v.io/x/ref/services/mounttable"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := name, "TestMount"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	for _, testCase := range []string{"", "TestMount", ".TestMount", "mounttable."} {
+		if _, _, err := splitTestCase(testCase); err == nil {
+			t.Errorf("splitting %q did not fail", testCase)
+		}
+	}
+}
+
+func TestParseAxes(t *testing.T) {
+	axes, err := parseAxes("ARCH=amd64,OS=linux")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := map[string]string{"ARCH": "amd64", "OS": "linux"}; !reflect.DeepEqual(axes, want) {
+		t.Errorf("want %v, got %v", want, axes)
+	}
+	if axes, err := parseAxes(""); err != nil || axes != nil {
+		t.Errorf("want nil, got %v, %v", axes, err)
+	}
+	if _, err := parseAxes("OS"); err == nil {
+		t.Errorf("parsing an axis without a value did not fail")
+	}
+}
+
+func TestReportBisectCommands(t *testing.T) {
+	goTest := testResultInfo{TestName: "vanadium-go-test", AxisValues: axisValuesInfo{Arch: "amd64", OS: "linux", PartIndex: -1}}
+	jsTest := testResultInfo{TestName: "vanadium-js-unit", AxisValues: axisValuesInfo{Arch: "amd64", OS: "linux", PartIndex: -1}}
+	reporter := testReporter{
+		matrixJobsConf: map[string]tooldata.JenkinsMatrixJobInfo{
+			"vanadium-go-test": {HasArch: true, HasOS: true},
+		},
+		postSubmitResults: map[string]*postSubmitBuildData{
+			goTest.key(): {result: "FAILURE"},
+			jsTest.key(): {result: "SUCCESS"},
+		},
+		report: &bytes.Buffer{},
+	}
+	reporter.reportBisectCommands([]failedTestCaseInfo{
+		{className: "v.io/x/ref/services/mounttable", testCaseName: "TestMount", testName: goTest.TestName, axisValues: goTest.AxisValues},
+		{className: "js.unit", testCaseName: "test", testName: jsTest.TestName, axisValues: jsTest.AxisValues},
+	})
+	want := "The postsubmit builds of these tests are failing too. To find the postsubmit builds that the new failures started in, run:\npresubmit bisect -test=vanadium-go-test -case=v.io/x/ref/services/mounttable.TestMount -axes=ARCH=amd64,OS=linux\n\n"
+	if got := reporter.report.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
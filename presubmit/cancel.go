@@ -0,0 +1,66 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/gerrit"
+	"v.io/x/lib/cmdline"
+)
+
+func init() {
+	cmdCancel.Flags.StringVar(&reviewTargetRefsFlag, "refs", "", "The review references separated by ':'.")
+}
+
+// cmdCancel represents the 'cancel' command of the presubmit tool.
+var cmdCancel = &cmdline.Command{
+	Name:  "cancel",
+	Short: "Cancel presubmit builds for superseded patchsets",
+	Long: `
+This subcommand cancels all the queued and ongoing presubmit-test builds for the
+CLs identified by the given review references whose patchsets are equal to or
+smaller than the given ones. It is meant to be used when a CL is abandoned or a
+new patchset of a CL is pushed, so that stale builds stop consuming resources.
+`,
+	Runner: jiri.RunnerFunc(runCancel),
+}
+
+// runCancel implements the "cancel" subcommand.
+func runCancel(jirix *jiri.X, args []string) error {
+	if jenkinsHostFlag == "" {
+		return jirix.UsageErrorf("-host flag is required")
+	}
+	cls, err := parseRefsFlag(reviewTargetRefsFlag)
+	if err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	if errs := removeOutdatedBuilds(jirix, cls); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
+		return fmt.Errorf("failed to cancel builds for %q", reviewTargetRefsFlag)
+	}
+	return nil
+}
+
+// parseRefsFlag parses the given review references separated by ':' into a
+// map from CL numbers to patchsets.
+func parseRefsFlag(refs string) (clNumberToPatchsetMap, error) {
+	if refs == "" {
+		return nil, fmt.Errorf("-refs flag is required")
+	}
+	cls := clNumberToPatchsetMap{}
+	for _, ref := range strings.Split(refs, ":") {
+		cl, patchset, err := gerrit.ParseRefString(ref)
+		if err != nil {
+			return nil, err
+		}
+		cls[cl] = patchset
+	}
+	return cls, nil
+}
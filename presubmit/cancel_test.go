@@ -0,0 +1,51 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRefsFlag(t *testing.T) {
+	testCases := []struct {
+		refs      string
+		want      clNumberToPatchsetMap
+		expectErr bool
+	}{
+		{
+			refs: "refs/changes/00/1000/2",
+			want: clNumberToPatchsetMap{1000: 2},
+		},
+		{
+			refs: "refs/changes/00/1000/2:refs/changes/00/2000/1",
+			want: clNumberToPatchsetMap{1000: 2, 2000: 1},
+		},
+		{
+			refs:      "",
+			expectErr: true,
+		},
+		{
+			refs:      "refs/changes/00/abc/1",
+			expectErr: true,
+		},
+	}
+	for _, test := range testCases {
+		got, err := parseRefsFlag(test.refs)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("refs %q: want error, got none", test.refs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("refs %q: %v", test.refs, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("refs %q: want %v, got %v", test.refs, test.want, got)
+		}
+	}
+}
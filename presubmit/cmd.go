@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"v.io/jiri/tool"
+	"v.io/x/devtools/internal/test"
 	"v.io/x/lib/cmdline"
 )
 
@@ -38,6 +39,7 @@ func init() {
 	cmdRoot.Flags.StringVar(&presubmitTestJobFlag, "job", defaultPresubmitTestJob, "The name of the Jenkins job to add presubmit-test builds to.")
 
 	tool.InitializeRunFlags(&cmdRoot.Flags)
+	test.InitializeLogFlags(&cmdRoot.Flags, "presubmit")
 }
 
 var (
@@ -50,8 +52,14 @@ func main() {
 }
 
 // printf outputs the given message prefixed by outputPrefix, adding a
-// blank line before any messages that start with "###".
+// blank line before any messages that start with "###". With -log-json,
+// the message is printed as a JSON log record instead.
 func printf(out io.Writer, format string, args ...interface{}) {
+	if test.LogJSON() {
+		ctx := tool.NewContext(tool.ContextOpts{Stdout: out, Stderr: out})
+		test.NewLogger(ctx).Infof(format, args...)
+		return
+	}
 	if strings.HasPrefix(format, "###") {
 		fmt.Fprintln(out)
 	}
@@ -66,5 +74,5 @@ var cmdRoot = &cmdline.Command{
 	Long: `
 Command presubmit performs Vanadium presubmit related functions.
 `,
-	Children: []*cmdline.Command{cmdQuery, cmdResult, cmdTest},
+	Children: []*cmdline.Command{cmdBisect, cmdCancel, cmdQuery, cmdResult, cmdTest},
 }
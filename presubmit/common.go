@@ -31,31 +31,24 @@ func genStartPresubmitBuildLink(strRefs, strProjects, strTests string) string {
 		url.QueryEscape(strTests))
 }
 
-// postMessage posts the given message to Gerrit.
+// postMessage posts the given message to the changes identified by the
+// given refs, using the review backend of each change.
 func postMessage(jirix *jiri.X, message string, refs []string, success bool) error {
-	refsUsingVerifiedLabel, err := getRefsUsingVerifiedLabel(jirix)
-	if err != nil {
-		return err
-	}
-	value := "1"
-	if !success {
-		value = "-" + value
-	}
+	gerritRefs := []string{}
 	for _, ref := range refs {
-		labels := map[string]string{}
-		if _, ok := refsUsingVerifiedLabel[ref]; ok {
-			labels["Verified"] = value
+		backend := backendForRef(ref)
+		if _, ok := backend.(gerritBackend); ok {
+			gerritRefs = append(gerritRefs, ref)
+			continue
 		}
-		gUrl, err := gerritBaseUrl()
-		if err != nil {
+		if err := backend.postResult(jirix, message, []string{ref}, success); err != nil {
 			return err
 		}
-		if err := jirix.Gerrit(gUrl).PostReview(ref, message, labels); err != nil {
-			return err
-		}
-		test.Pass(jirix.Context, "review posted for %q with labels %v.\n", ref, labels)
 	}
-	return nil
+	if len(gerritRefs) == 0 {
+		return nil
+	}
+	return gerritBackend{}.postResult(jirix, message, gerritRefs, success)
 }
 
 func getRefsUsingVerifiedLabel(jirix *jiri.X) (map[string]struct{}, error) {
@@ -0,0 +1,195 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/jiri/gerrit"
+)
+
+const (
+	dependencyMessageTmpl        = "Note: %s was tested along with the CLs that depend on it."
+	dependencyFailureMessageTmpl = "%s depends on %s, which is %s.\nPresubmit tests will be executed after a new patchset that no longer depends on it is submitted."
+)
+
+// changeDependency identifies a change that another change depends on.
+type changeDependency struct {
+	clNumber int
+	patchset int
+	// status is the status of the change in the code review system,
+	// e.g. "NEW", "MERGED" or "ABANDONED".
+	status string
+}
+
+// ref returns the Gerrit ref of the patchset of the change.
+func (d changeDependency) ref() string {
+	return fmt.Sprintf("refs/changes/%02d/%d/%d", d.clNumber%100, d.clNumber, d.patchset)
+}
+
+// dependencyError is returned when a CL depends on a change that cannot
+// be tested with it, because it is already merged or abandoned.
+type dependencyError struct {
+	cl         cl
+	dependency cl
+	status     string
+}
+
+func (e dependencyError) Error() string {
+	return fmt.Sprintf(dependencyFailureMessageTmpl, e.cl.String(), e.dependency.String(), strings.ToLower(e.status))
+}
+
+// addDependencies returns the given CLs, preceded by the open changes
+// they depend on, in the order in which the changes should be pulled.
+// The changes a CL depends on are pulled before the CL, from the oldest
+// ancestor to the parent of the CL, and each change is pulled once.
+// The dependencies that were not among the given CLs are returned as
+// well. A dependencyError is returned if a CL depends on a change that
+// is not open anymore.
+func addDependencies(jirix *jiri.X, cls []cl) ([]cl, []cl, error) {
+	given := map[int]cl{}
+	for _, curCL := range cls {
+		given[curCL.clNumber] = curCL
+	}
+	result, added, seen := []cl{}, []cl{}, map[int]bool{}
+	for _, curCL := range cls {
+		deps, err := backendForRef(curCL.ref).dependencies(jirix, curCL.ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dep := range deps {
+			depCL, ok := given[dep.clNumber]
+			if !ok {
+				depCL = cl{clNumber: dep.clNumber, patchset: dep.patchset, ref: dep.ref(), project: curCL.project}
+			}
+			if dep.status != "NEW" {
+				return nil, nil, dependencyError{cl: curCL, dependency: depCL, status: dep.status}
+			}
+			if seen[dep.clNumber] {
+				continue
+			}
+			seen[dep.clNumber] = true
+			result = append(result, depCL)
+			if !ok {
+				added = append(added, depCL)
+			}
+		}
+		if !seen[curCL.clNumber] {
+			seen[curCL.clNumber] = true
+			result = append(result, curCL)
+		}
+	}
+	return result, added, nil
+}
+
+// dependencies implements the reviewBackend interface. The changes are
+// identified from the related changes of the patchset, by walking the
+// parents of its commit.
+func (gerritBackend) dependencies(jirix *jiri.X, ref string) (_ []changeDependency, e error) {
+	clNumber, patchset, err := gerrit.ParseRefString(ref)
+	if err != nil {
+		return nil, err
+	}
+	gUrl, err := gerritBaseUrl()
+	if err != nil {
+		return nil, err
+	}
+	relatedUrl := fmt.Sprintf("%s/changes/%d/revisions/%d/related", strings.TrimSuffix(gUrl.String(), "/"), clNumber, patchset)
+	res, err := http.Get(relatedUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Get(%v) failed: %v", relatedUrl, err)
+	}
+	defer collect.Error(res.Body.Close, &e)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Get(%v) failed: %v", relatedUrl, res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ReadAll() failed: %v", err)
+	}
+	return parseGerritRelatedChanges(data, clNumber)
+}
+
+// parseGerritRelatedChanges parses the given RelatedChangesInfo entity
+// returned by the Gerrit REST API for a patchset of the given change,
+// and returns the changes that the patchset depends on, ordered from
+// the oldest ancestor to the parent of the patchset.
+func parseGerritRelatedChanges(data []byte, clNumber int) ([]changeDependency, error) {
+	data = bytes.TrimPrefix(data, []byte(gerritResponsePrefix))
+	type relatedChange struct {
+		Commit struct {
+			Commit  string `json:"commit"`
+			Parents []struct {
+				Commit string `json:"commit"`
+			} `json:"parents"`
+		} `json:"commit"`
+		ChangeNumber   int    `json:"_change_number"`
+		RevisionNumber int    `json:"_revision_number"`
+		Status         string `json:"status"`
+	}
+	var related struct {
+		Changes []relatedChange `json:"changes"`
+	}
+	if err := json.Unmarshal(data, &related); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(data), err)
+	}
+	byCommit := map[string]relatedChange{}
+	var cur *relatedChange
+	for i, change := range related.Changes {
+		byCommit[change.Commit.Commit] = change
+		if change.ChangeNumber == clNumber {
+			cur = &related.Changes[i]
+		}
+	}
+	if cur == nil {
+		return nil, nil
+	}
+	deps, seen := []changeDependency{}, map[string]bool{cur.Commit.Commit: true}
+	for len(cur.Commit.Parents) > 0 {
+		parent, ok := byCommit[cur.Commit.Parents[0].Commit]
+		if !ok || seen[parent.Commit.Commit] {
+			break
+		}
+		seen[parent.Commit.Commit] = true
+		deps = append([]changeDependency{{
+			clNumber: parent.ChangeNumber,
+			patchset: parent.RevisionNumber,
+			status:   parent.Status,
+		}}, deps...)
+		cur = &parent
+	}
+	return deps, nil
+}
+
+// dependencies implements the reviewBackend interface. Pull requests
+// do not depend on each other.
+func (b githubBackend) dependencies(jirix *jiri.X, ref string) ([]changeDependency, error) {
+	return nil, nil
+}
+
+// reportDependencies reports the changes that were tested along with
+// the CLs because the CLs depend on them.
+func (r *testReporter) reportDependencies() {
+	seen := map[string]bool{}
+	for _, resultInfo := range r.testResults {
+		for _, dependencyCL := range resultInfo.Result.DependencyCLs {
+			if seen[dependencyCL] {
+				continue
+			}
+			seen[dependencyCL] = true
+			fmt.Fprintf(r.report, dependencyMessageTmpl+"\n", dependencyCL)
+		}
+	}
+	if len(seen) != 0 {
+		fmt.Fprintf(r.report, "\n")
+	}
+}
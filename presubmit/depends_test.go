@@ -0,0 +1,82 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"v.io/x/devtools/internal/test"
+)
+
+func TestParseGerritRelatedChanges(t *testing.T) {
+	// The related changes of a stack of four changes, the third of which
+	// is tested, listed from the newest to the oldest.
+	data := `)]}'
+{"changes": [
+  {"commit": {"commit": "d4", "parents": [{"commit": "c3"}]}, "_change_number": 1004, "_revision_number": 1, "status": "NEW"},
+  {"commit": {"commit": "c3", "parents": [{"commit": "b2"}]}, "_change_number": 1003, "_revision_number": 2, "status": "NEW"},
+  {"commit": {"commit": "b2", "parents": [{"commit": "a1"}]}, "_change_number": 1002, "_revision_number": 3, "status": "NEW"},
+  {"commit": {"commit": "a1", "parents": [{"commit": "master"}]}, "_change_number": 1001, "_revision_number": 1, "status": "MERGED"}
+]}`
+	deps, err := parseGerritRelatedChanges([]byte(data), 1003)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []changeDependency{
+		{clNumber: 1001, patchset: 1, status: "MERGED"},
+		{clNumber: 1002, patchset: 3, status: "NEW"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("want %v, got %v", want, deps)
+	}
+	if got, want := want[1].ref(), "refs/changes/02/1002/3"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	// Changes without related changes have no dependencies.
+	deps, err = parseGerritRelatedChanges([]byte(`)]}'
+{}`), 1003)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(deps) != 0 {
+		t.Fatalf("want no dependencies, got %v", deps)
+	}
+}
+
+func TestDependencyError(t *testing.T) {
+	err := dependencyError{
+		cl:         cl{clNumber: 1003, patchset: 2},
+		dependency: cl{clNumber: 1001, patchset: 1},
+		status:     "ABANDONED",
+	}
+	want := "http://go/vcl/1003/2 depends on http://go/vcl/1001/1, which is abandoned.\nPresubmit tests will be executed after a new patchset that no longer depends on it is submitted."
+	if got := err.Error(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestReportDependencies(t *testing.T) {
+	reporter := testReporter{
+		testResults: []testResultInfo{
+			testResultInfo{
+				TestName: "vanadium-go-test",
+				Result:   test.Result{DependencyCLs: []string{"http://go/vcl/1001/1", "http://go/vcl/1002/3"}},
+			},
+			testResultInfo{
+				TestName: "vanadium-go-race",
+				Result:   test.Result{DependencyCLs: []string{"http://go/vcl/1002/3"}},
+			},
+		},
+		report: &bytes.Buffer{},
+	}
+	reporter.reportDependencies()
+	want := "Note: http://go/vcl/1001/1 was tested along with the CLs that depend on it.\nNote: http://go/vcl/1002/3 was tested along with the CLs that depend on it.\n\n"
+	if got := reporter.report.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
@@ -12,6 +12,8 @@ Usage:
    presubmit [flags] <command>
 
 The presubmit commands are:
+   bisect      Find the postsubmit build that a test case started failing in
+   cancel      Cancel presubmit builds for superseded patchsets
    query       Query open CLs from Gerrit
    result      Process and post test results
    test        Run tests for a CL
@@ -24,6 +26,11 @@ The presubmit flags are:
    The Jenkins host. Presubmit will not send any CLs to an empty host.
  -job=vanadium-presubmit-test
    The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -url=https://vanadium-review.googlesource.com
    The base url of the gerrit instance.
  -v=false
@@ -35,6 +42,82 @@ The global flags are:
  -time=false
    Dump timing information to stderr before exiting the program.
 
+Presubmit bisect - Find the postsubmit build that a test case started failing in
+
+This subcommand walks backwards through the completed builds of the postsubmit
+job of the given test, starting with the last one, until it finds a build in
+which the given test case did not fail. It then prints the first build in which
+the test case failed, and the revision ranges of the changes tested by that
+build, which come from the REVISIONS parameter of the builds started by
+"postsubmit poll". For each revision range of a project present locally, the
+commits it covers are printed as the candidate culprits.
+
+Builds without test results, for example because the build itself failed, are
+skipped, and the revision ranges of the skipped builds are printed as well.
+
+The presubmit report suggests this subcommand for new failures of tests whose
+postsubmit build is failing as well.
+
+Usage:
+   presubmit bisect [flags]
+
+The presubmit bisect flags are:
+ -axes=
+   Comma-separated list of <axis>=<value> pairs that select the configuration
+   of a multi-configuration job, e.g. ARCH=amd64,OS=linux.
+ -case=
+   The failed test case, as <class>.<name>.
+ -max-builds=50
+   The maximum number of postsubmit builds to examine.
+ -test=
+   The name of the postsubmit test, which is also the name of its Jenkins job.
+
+ -color=true
+   Use color to format output.
+ -host=
+   The Jenkins host. Presubmit will not send any CLs to an empty host.
+ -job=vanadium-presubmit-test
+   The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -url=https://vanadium-review.googlesource.com
+   The base url of the gerrit instance.
+ -v=false
+   Print verbose output.
+
+Presubmit cancel - Cancel presubmit builds for superseded patchsets
+
+This subcommand cancels all the queued and ongoing presubmit-test builds for the
+CLs identified by the given review references whose patchsets are equal to or
+smaller than the given ones. It is meant to be used when a CL is abandoned or a
+new patchset of a CL is pushed, so that stale builds stop consuming resources.
+
+Usage:
+   presubmit cancel [flags]
+
+The presubmit cancel flags are:
+ -refs=
+   The review references separated by ':'.
+
+ -color=true
+   Use color to format output.
+ -host=
+   The Jenkins host. Presubmit will not send any CLs to an empty host.
+ -job=vanadium-presubmit-test
+   The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
+ -url=https://vanadium-review.googlesource.com
+   The base url of the gerrit instance.
+ -v=false
+   Print verbose output.
+
 Presubmit query - Query open CLs from Gerrit
 
 This subcommand queries open CLs from Gerrit, calculates diffs from the previous
@@ -42,10 +125,30 @@ query results, and sends each one with related metadata (ref, project, changeId)
 to a Jenkins job which will run tests against the corresponding CL and post
 review with test results.
 
+If -group-by-topic is set, CLs that share a Gerrit topic are tested together,
+as if they were parts of a multi-part CL. When one of them changes, the test
+run also includes the other open CLs of the topic.
+
+Projects that the tools config assigns to GitHub are queried for open pull
+requests instead, which are tested one at a time. Test results are posted as
+comments on the pull requests, and the pull requests are marked as verified
+using commit statuses. The GitHub API is accessed with the OAuth token in the
+GITHUB_TOKEN environment variable.
+
+The tests selected by the tools config for a change can be overridden with a
+review comment line, a Gerrit hashtag or a GitHub label such as "PresubmitTest:
+vanadium-go-race,vanadium-integration-test". A list that starts with "+" adds
+the tests to the selected ones instead of replacing them. Tests that are not
+registered with jiri-test are ignored, and changes whose selected tests were
+replaced are not marked as verified. The most recent review comment takes
+precedence, and the override is mentioned in the test report.
+
 Usage:
    presubmit query [flags]
 
 The presubmit query flags are:
+ -group-by-topic=false
+   Test the open CLs that share a Gerrit topic together.
  -log-file=${HOME}/tmp/presubmit_log
    The file that stores the refs from the previous Gerrit query.
  -manifest=
@@ -59,6 +162,11 @@ The presubmit query flags are:
    The Jenkins host. Presubmit will not send any CLs to an empty host.
  -job=vanadium-presubmit-test
    The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -url=https://vanadium-review.googlesource.com
    The base url of the gerrit instance.
  -v=false
@@ -70,14 +178,43 @@ Result processes all the test statuses and results files collected from all the
 presubmit test configuration builds, creates a result summary, and posts the
 summary back to the corresponding Gerrit review thread.
 
+If the presubmit build failed outright or found more new failures than the
+escalation threshold, the current oncalls are notified via email and/or a chat
+webhook, as configured by the -escalation-* flags.
+
 Usage:
    presubmit result [flags]
 
 The presubmit result flags are:
+ -archive-bucket=
+   The Google Storage location to archive the test results of the build to,
+   e.g. gs://vanadium-presubmit-archive. Test results are not archived if empty.
  -build-number=-1
    The number of the Jenkins build.
  -dashboard-host=https://dashboard.v.io
    The host of the dashboard server.
+ -escalation-email-domain=google.com
+   The domain of the email addresses of the oncalls, which are formed from their
+   usernames.
+ -escalation-email-from=vanadium-presubmit@google.com
+   The sender of the escalation emails.
+ -escalation-smtp-server=
+   The <host>:<port> address of the SMTP server used to email escalations to the
+   oncalls. Escalations are not emailed if empty.
+ -escalation-threshold=10
+   The number of new failures above which the oncalls are notified. Use a
+   negative value to only notify them of failed presubmit builds.
+ -escalation-webhook=
+   The URL of a chat webhook, such as a Slack or Hangouts Chat incoming webhook,
+   to post escalations to. Escalations are not posted if empty.
+ -flake-confidence=0.9
+   The minimum confidence of a known flaky signature for a failure to be
+   considered a flake.
+ -flake-rerun-of=-1
+   The number of the Jenkins build whose flaky failures this build re-runs.
+ -flakes-db=
+   The local path or Google Storage location of the JSON file that lists known
+   flaky signatures. Failures are not matched against known flakes if empty.
  -manifest=
    Name of the project manifest.
  -projects=
@@ -92,6 +229,11 @@ The presubmit result flags are:
    The Jenkins host. Presubmit will not send any CLs to an empty host.
  -job=vanadium-presubmit-test
    The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -url=https://vanadium-review.googlesource.com
    The base url of the gerrit instance.
  -v=false
@@ -102,12 +244,28 @@ Presubmit test - Run tests for a CL
 This subcommand pulls the open CLs from Gerrit, runs tests specified in a config
 file, and posts test results back to the corresponding Gerrit review thread.
 
+The open changes that a CL depends on, such as the ancestors of a CL in a stack
+of Gerrit changes, are pulled before the CL, from the oldest ancestor to the
+parent of the CL, and are listed in the report. If a CL depends on a change
+that is already merged or abandoned, the CL is not tested, and a message that
+explains why is posted instead.
+
 Usage:
    presubmit test [flags]
 
 The presubmit test flags are:
+ -auto-rebase=false
+   Attempt to automatically rebase CLs that cannot be merged onto master, and
+   test the rebased CLs if the rebase is clean.
  -build-number=-1
    The number of the Jenkins build.
+ -isolate=false
+   Test the CLs in a throwaway workspace keyed by the build number instead of
+   the shared checkout. The projects changed by the CLs are checked out in the
+   workspace as git worktrees at the revisions identified by the manifest, and
+   the rest of the jiri root is symlinked. Build outputs, such as the pkg and
+   bin directories of the Go workspaces and the tools built by jiri, are kept in
+   the workspace. The workspace is removed after the test.
  -manifest=
    Name of the project manifest.
  -num-test-workers=<runtime.NumCPU()>
@@ -117,6 +275,9 @@ The presubmit test flags are:
    separated by ':'.
  -refs=
    The review references separated by ':'.
+ -skip-unaffected=false
+   Skip the Go tests whose packages do not depend on any of the files changed by
+   the CLs.
  -test=
    The name of a single test to run.
 
@@ -126,6 +287,11 @@ The presubmit test flags are:
    The Jenkins host. Presubmit will not send any CLs to an empty host.
  -job=vanadium-presubmit-test
    The name of the Jenkins job to add presubmit-test builds to.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -url=https://vanadium-review.googlesource.com
    The base url of the gerrit instance.
  -v=false
@@ -0,0 +1,178 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/x/devtools/tooldata"
+)
+
+// maxEscalatedFailures is the maximum number of new failures listed in
+// an escalation.
+const maxEscalatedFailures = 10
+
+var (
+	escalationEmailDomainFlag string
+	escalationEmailFromFlag   string
+	escalationSMTPServerFlag  string
+	escalationThresholdFlag   int
+	escalationWebhookFlag     string
+
+	// sendMail is used to send escalation emails. It is a variable so
+	// that tests can replace it.
+	sendMail = smtp.SendMail
+)
+
+func init() {
+	cmdResult.Flags.StringVar(&escalationEmailDomainFlag, "escalation-email-domain", "google.com", "The domain of the email addresses of the oncalls, which are formed from their usernames.")
+	cmdResult.Flags.StringVar(&escalationEmailFromFlag, "escalation-email-from", "vanadium-presubmit@google.com", "The sender of the escalation emails.")
+	cmdResult.Flags.StringVar(&escalationSMTPServerFlag, "escalation-smtp-server", "", "The <host>:<port> address of the SMTP server used to email escalations to the oncalls. Escalations are not emailed if empty.")
+	cmdResult.Flags.IntVar(&escalationThresholdFlag, "escalation-threshold", 10, "The number of new failures above which the oncalls are notified. Use a negative value to only notify them of failed presubmit builds.")
+	cmdResult.Flags.StringVar(&escalationWebhookFlag, "escalation-webhook", "", "The URL of a chat webhook, such as a Slack or Hangouts Chat incoming webhook, to post escalations to. Escalations are not posted if empty.")
+}
+
+// escalation describes a presubmit problem that the oncalls are
+// notified of.
+type escalation struct {
+	subject string
+	body    string
+}
+
+// escalationEnabled returns whether the oncalls are notified of
+// presubmit problems.
+func escalationEnabled() bool {
+	return escalationWebhookFlag != "" || escalationSMTPServerFlag != ""
+}
+
+// failedBuildEscalation returns the escalation of a presubmit build
+// that failed outright.
+func (r *testReporter) failedBuildEscalation() escalation {
+	return escalation{
+		subject: fmt.Sprintf("Presubmit build %d failed", jenkinsBuildNumberFlag),
+		body:    r.escalationBody("Some tests of the presubmit build failed to run.\n"),
+	}
+}
+
+// newFailuresEscalation returns the escalation of the given new
+// failures, which exceed the escalation threshold.
+func (r *testReporter) newFailuresEscalation(newFailures []failedTestCaseInfo) escalation {
+	var details bytes.Buffer
+	fmt.Fprintf(&details, "The presubmit build found %d new failures, more than the threshold of %d:\n", len(newFailures), escalationThresholdFlag)
+	for i, failure := range newFailures {
+		if i == maxEscalatedFailures {
+			fmt.Fprintf(&details, "- ... and %d more\n", len(newFailures)-maxEscalatedFailures)
+			break
+		}
+		fmt.Fprintf(&details, "- %s.%s (%s)\n", failure.className, failure.testCaseName, failure.testName)
+	}
+	return escalation{
+		subject: fmt.Sprintf("Presubmit build %d found %d new failures", jenkinsBuildNumberFlag, len(newFailures)),
+		body:    r.escalationBody(details.String()),
+	}
+}
+
+// escalationBody returns the body of an escalation with the given
+// details, followed by the links to the build and the CLs under test.
+func (r *testReporter) escalationBody(details string) string {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "%s\n", details)
+	fmt.Fprintf(&body, "Dashboard: %s/?type=presubmit&n=%d\n", dashboardHostFlag, jenkinsBuildNumberFlag)
+	fmt.Fprintf(&body, "Jenkins: %s/job/%s/%d/\n", strings.TrimSuffix(jenkinsHostFlag, "/"), presubmitTestJobFlag, jenkinsBuildNumberFlag)
+	if r.archiveURL != "" {
+		fmt.Fprintf(&body, "Archived results: %s\n", strings.Replace(r.archiveURL, "gs://", "https://storage.cloud.google.com/", 1))
+	}
+	fmt.Fprintf(&body, "\nCLs under test:\n")
+	if cls, err := parseCLs(); err == nil {
+		for _, cl := range cls {
+			fmt.Fprintf(&body, "- %v\n", cl)
+		}
+	} else {
+		for _, ref := range r.refs {
+			fmt.Fprintf(&body, "- %s\n", ref)
+		}
+	}
+	return body.String()
+}
+
+// escalate notifies the current oncalls of the given escalation using
+// the configured email and webhook. Failures to notify the oncalls are
+// reported, but do not fail the presubmit result processing.
+func (r *testReporter) escalate(jirix *jiri.X, e escalation) {
+	if !escalationEnabled() {
+		return
+	}
+	printf(jirix.Stdout(), "### Escalating to the oncalls: %s\n", e.subject)
+	if escalationWebhookFlag != "" {
+		if err := postEscalationWebhook(escalationWebhookFlag, e); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
+	}
+	if escalationSMTPServerFlag != "" {
+		shift, err := tooldata.Oncall(jirix, time.Now())
+		if err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+			return
+		}
+		if shift == nil {
+			fmt.Fprintf(jirix.Stderr(), "no oncall shift found\n")
+			return
+		}
+		to := []string{}
+		for _, user := range []string{shift.Primary, shift.Secondary} {
+			if user != "" {
+				to = append(to, user+"@"+escalationEmailDomainFlag)
+			}
+		}
+		if err := emailEscalation(escalationSMTPServerFlag, escalationEmailFromFlag, to, e); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
+	}
+}
+
+// postEscalationWebhook posts the given escalation to the given chat
+// webhook. The message is encoded in the {"text": ...} format accepted
+// by both Slack and Hangouts Chat.
+func postEscalationWebhook(webhook string, e escalation) (err error) {
+	data, err := json.Marshal(map[string]string{"text": e.subject + "\n\n" + e.body})
+	if err != nil {
+		return fmt.Errorf("Marshal() failed: %v", err)
+	}
+	res, err := http.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Post(%v) failed: %v", webhook, err)
+	}
+	defer collect.Error(res.Body.Close, &err)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Post(%v) failed: %v", webhook, res.Status)
+	}
+	return nil
+}
+
+// emailEscalation emails the given escalation to the given recipients
+// using the given SMTP server.
+func emailEscalation(server, from string, to []string, e escalation) error {
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients for escalation %q", e.subject)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", e.subject)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s", strings.Replace(e.body, "\n", "\r\n", -1))
+	if err := sendMail(server, nil, from, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("SendMail(%v) failed: %v", server, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscalation(t *testing.T) {
+	saved := []interface{}{reviewTargetRefsFlag, projectsFlag, jenkinsBuildNumberFlag, escalationThresholdFlag}
+	defer func() {
+		reviewTargetRefsFlag = saved[0].(string)
+		projectsFlag = saved[1].(string)
+		jenkinsBuildNumberFlag = saved[2].(int)
+		escalationThresholdFlag = saved[3].(int)
+	}()
+	reviewTargetRefsFlag, projectsFlag = "refs/changes/10/1000/2", "release.go.core"
+	jenkinsBuildNumberFlag, escalationThresholdFlag = 42, 2
+
+	failures := []failedTestCaseInfo{}
+	for i := 0; i < maxEscalatedFailures+2; i++ {
+		failures = append(failures, failedTestCaseInfo{className: "v.io/x/foo", testCaseName: fmt.Sprintf("Test%d", i), testName: "vanadium-go-test"})
+	}
+	r := testReporter{refs: []string{reviewTargetRefsFlag}}
+	e := r.newFailuresEscalation(failures)
+	if got, want := e.subject, "Presubmit build 42 found 12 new failures"; got != want {
+		t.Fatalf("want subject %q, got %q", want, got)
+	}
+	for _, want := range []string{
+		"12 new failures, more than the threshold of 2",
+		"- v.io/x/foo.Test0 (vanadium-go-test)\n",
+		"- ... and 2 more\n",
+		"/?type=presubmit&n=42\n",
+		"- http://go/vcl/1000/2\n",
+	} {
+		if !strings.Contains(e.body, want) {
+			t.Errorf("body %q does not contain %q", e.body, want)
+		}
+	}
+	if strings.Contains(e.body, "Test10") {
+		t.Errorf("body %q lists more than %d failures", e.body, maxEscalatedFailures)
+	}
+
+	// Post the escalation to a webhook.
+	texts := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Decode() failed: %v", err)
+		}
+		texts = append(texts, message["text"])
+	}))
+	defer server.Close()
+	if err := postEscalationWebhook(server.URL, e); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{e.subject + "\n\n" + e.body}; !reflect.DeepEqual(texts, want) {
+		t.Fatalf("want %q, got %q", want, texts)
+	}
+
+	// Email the escalation.
+	savedSendMail := sendMail
+	defer func() { sendMail = savedSendMail }()
+	var gotTo []string
+	var gotMsg string
+	sendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		gotTo, gotMsg = to, string(msg)
+		return nil
+	}
+	to := []string{"jane@example.com", "joe@example.com"}
+	if err := emailEscalation("localhost:25", "presubmit@example.com", to, e); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(gotTo, to) {
+		t.Errorf("want recipients %v, got %v", to, gotTo)
+	}
+	if want := "Subject: " + e.subject + "\r\n"; !strings.Contains(gotMsg, want) {
+		t.Errorf("message %q does not contain %q", gotMsg, want)
+	}
+	if err := emailEscalation("localhost:25", "presubmit@example.com", nil, e); err == nil {
+		t.Errorf("emailing an escalation without recipients did not fail")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/x/lib/set"
+)
+
+// flakeSignature identifies a family of test case failures that are
+// known to be caused by flakiness rather than by the change under test.
+type flakeSignature struct {
+	// Class and Name are regular expressions that are matched against
+	// the class name and the name of a failed test case respectively.
+	// An empty expression matches everything.
+	Class string
+	Name  string
+	// Confidence is the probability (in the [0, 1] interval) that a
+	// failure matching this signature is a flake.
+	Confidence float64
+
+	classRE, nameRE *regexp.Regexp
+}
+
+// flakesDB represents the collection of known flaky signatures.
+type flakesDB []*flakeSignature
+
+// parseFlakesDB parses the given JSON-encoded list of flaky signatures.
+func parseFlakesDB(data []byte) (flakesDB, error) {
+	var db flakesDB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(data), err)
+	}
+	for _, sig := range db {
+		var err error
+		if sig.classRE, err = regexp.Compile(sig.Class); err != nil {
+			return nil, fmt.Errorf("Compile(%v) failed: %v", sig.Class, err)
+		}
+		if sig.nameRE, err = regexp.Compile(sig.Name); err != nil {
+			return nil, fmt.Errorf("Compile(%v) failed: %v", sig.Name, err)
+		}
+	}
+	return db, nil
+}
+
+// loadFlakesDB loads the flakiness database from the given location,
+// which is either a local file or a Google Storage object.
+func loadFlakesDB(jirix *jiri.X, location string) (flakesDB, error) {
+	s := jirix.NewSeq()
+	if strings.HasPrefix(location, "gs://") {
+		var out bytes.Buffer
+		if err := s.Capture(&out, nil).Last("gsutil", "-q", "cat", location); err != nil {
+			return nil, err
+		}
+		return parseFlakesDB(out.Bytes())
+	}
+	data, err := s.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	return parseFlakesDB(data)
+}
+
+// match returns the signature with the highest confidence that matches
+// the given test case and whose confidence is at least minConfidence,
+// or nil if there is no such signature.
+func (db flakesDB) match(className, testCaseName string, minConfidence float64) *flakeSignature {
+	var best *flakeSignature
+	for _, sig := range db {
+		if sig.Confidence < minConfidence {
+			continue
+		}
+		if !sig.classRE.MatchString(className) || !sig.nameRE.MatchString(testCaseName) {
+			continue
+		}
+		if best == nil || sig.Confidence > best.Confidence {
+			best = sig
+		}
+	}
+	return best
+}
+
+// matchAll checks whether all the given failed test cases match a known
+// flaky signature. If so, it returns the (sorted) full names of the
+// matched test cases.
+func (db flakesDB) matchAll(cases []failedTestCaseInfo, minConfidence float64) ([]string, bool) {
+	if len(cases) == 0 {
+		return nil, false
+	}
+	names := []string{}
+	for _, c := range cases {
+		if db.match(c.className, c.testCaseName, minConfidence) == nil {
+			return nil, false
+		}
+		names = append(names, genTestFullName(c.className, c.testCaseName))
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// reportFlakes checks the given new failures against the flakiness
+// database and reports the outcome. It returns whether the presubmit
+// test should be marked as verified.
+//
+// If all new failures match high-confidence flaky signatures, the
+// failed tests are scheduled to be re-run in the background and the
+// CLs are verified in the meantime. If the re-run reproduces the
+// failures, the verification is revoked by the re-run's own report.
+func (r *testReporter) reportFlakes(jirix *jiri.X, newFailures []failedTestCaseInfo, failedTestNames map[string]struct{}) bool {
+	if flakeRerunOfFlag >= 0 {
+		if len(newFailures) == 0 {
+			fmt.Fprintf(r.report, "\nRe-run of build %d passed, confirming that its failures were flakes.\n", flakeRerunOfFlag)
+			return true
+		}
+		fmt.Fprintf(r.report, "\nRe-run of build %d reproduced failures previously matched to known flakes.\n", flakeRerunOfFlag)
+		return false
+	}
+	if len(newFailures) == 0 {
+		return true
+	}
+	if flakesDBFlag == "" {
+		return false
+	}
+	db, err := loadFlakesDB(jirix, flakesDBFlag)
+	if err != nil {
+		printf(jirix.Stderr(), "%v\n", err)
+		return false
+	}
+	names, ok := db.matchAll(newFailures, flakeConfidenceFlag)
+	if !ok {
+		return false
+	}
+	// Only verify the CLs if the re-run that keeps the verification
+	// honest has been scheduled.
+	if err := addFlakeRerunBuild(jirix, set.String.ToSlice(failedTestNames)); err != nil {
+		printf(jirix.Stderr(), "%v\n", err)
+		return false
+	}
+	fmt.Fprintf(r.report, "\nAll failures matched known flakes: %s\nThe failed tests have been scheduled to re-run.\n", strings.Join(names, ", "))
+	return true
+}
+
+// addFlakeRerunBuild adds a presubmit-test build that re-runs the given
+// tests of the current presubmit build.
+func addFlakeRerunBuild(jirix *jiri.X, tests []string) error {
+	jenkins, err := jirix.Jenkins(jenkinsHostFlag)
+	if err != nil {
+		return err
+	}
+	sort.Strings(tests)
+	return jenkins.AddBuildWithParameter(presubmitTestJobFlag, url.Values{
+		"REFS":     {reviewTargetRefsFlag},
+		"PROJECTS": {projectsFlag},
+		"TESTS":    {strings.Join(tests, " ")},
+		// The re-run build passes this value to "presubmit result" via the
+		// -flake-rerun-of flag.
+		"FLAKE_RERUN_OF": {fmt.Sprintf("%d", jenkinsBuildNumberFlag)},
+	})
+}
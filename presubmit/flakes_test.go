@@ -0,0 +1,90 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlakesDBMatchAll(t *testing.T) {
+	db, err := parseFlakesDB([]byte(`[
+  {"Class": "^v\\.io/x/ref/runtime", "Name": "TestTimeout.*", "Confidence": 0.95},
+  {"Class": "", "Name": "^TestNetwork$", "Confidence": 0.5}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	type testCase struct {
+		cases         []failedTestCaseInfo
+		minConfidence float64
+		expectedNames []string
+		expectedOK    bool
+	}
+	testCases := []testCase{
+		// No failures.
+		testCase{
+			cases:         []failedTestCaseInfo{},
+			minConfidence: 0.9,
+			expectedNames: nil,
+			expectedOK:    false,
+		},
+		// All failures match high-confidence signatures.
+		testCase{
+			cases: []failedTestCaseInfo{
+				failedTestCaseInfo{className: "v.io/x/ref/runtime/rpc", testCaseName: "TestTimeoutCall"},
+				failedTestCaseInfo{className: "v.io/x/ref/runtime/flow", testCaseName: "TestTimeout"},
+			},
+			minConfidence: 0.9,
+			expectedNames: []string{"v::io/x/ref/runtime/flow::TestTimeout", "v::io/x/ref/runtime/rpc::TestTimeoutCall"},
+			expectedOK:    true,
+		},
+		// One of the failures only matches a low-confidence signature.
+		testCase{
+			cases: []failedTestCaseInfo{
+				failedTestCaseInfo{className: "v.io/x/ref/runtime/rpc", testCaseName: "TestTimeoutCall"},
+				failedTestCaseInfo{className: "v.io/x/ref/lib", testCaseName: "TestNetwork"},
+			},
+			minConfidence: 0.9,
+			expectedNames: nil,
+			expectedOK:    false,
+		},
+		// Same as above, with a lower confidence threshold.
+		testCase{
+			cases: []failedTestCaseInfo{
+				failedTestCaseInfo{className: "v.io/x/ref/runtime/rpc", testCaseName: "TestTimeoutCall"},
+				failedTestCaseInfo{className: "v.io/x/ref/lib", testCaseName: "TestNetwork"},
+			},
+			minConfidence: 0.5,
+			expectedNames: []string{"v::io/x/ref/lib::TestNetwork", "v::io/x/ref/runtime/rpc::TestTimeoutCall"},
+			expectedOK:    true,
+		},
+		// One of the failures doesn't match any signature.
+		testCase{
+			cases: []failedTestCaseInfo{
+				failedTestCaseInfo{className: "v.io/x/ref/services", testCaseName: "TestTimeoutCall"},
+			},
+			minConfidence: 0.1,
+			expectedNames: nil,
+			expectedOK:    false,
+		},
+	}
+	for _, test := range testCases {
+		gotNames, gotOK := db.matchAll(test.cases, test.minConfidence)
+		if gotOK != test.expectedOK {
+			t.Fatalf("want %v, got %v", test.expectedOK, gotOK)
+		}
+		if !reflect.DeepEqual(gotNames, test.expectedNames) {
+			t.Fatalf("want %v, got %v", test.expectedNames, gotNames)
+		}
+	}
+}
+
+func TestParseFlakesDBInvalid(t *testing.T) {
+	if _, err := parseFlakesDB([]byte(`[{"Class": "(", "Name": ""}]`)); err == nil {
+		t.Fatalf("expected parsing to fail")
+	}
+}
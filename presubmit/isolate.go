@@ -0,0 +1,290 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/project"
+	"v.io/x/devtools/tooldata"
+)
+
+// isolatedWorkspacesDir is the directory of the jiri root that holds
+// the isolated workspaces.
+const isolatedWorkspacesDir = ".presubmit"
+
+var isolateFlag bool
+
+func init() {
+	cmdTest.Flags.BoolVar(&isolateFlag, "isolate", false, "Test the CLs in a throwaway workspace keyed by the build number instead of the shared checkout. The projects changed by the CLs are checked out in the workspace as git worktrees at the revisions identified by the manifest, and the rest of the jiri root is symlinked. Build outputs, such as the pkg and bin directories of the Go workspaces and the tools built by jiri, are kept in the workspace. The workspace is removed after the test.")
+}
+
+// isolatedWorkspace is a throwaway copy of the jiri root, in which CLs
+// are tested without modifying the shared checkout.
+type isolatedWorkspace struct {
+	// root is the root directory of the workspace.
+	root string
+	// projects holds the projects of the jiri root, with the paths of
+	// the isolated projects pointing to their worktrees.
+	projects project.Projects
+	// sources holds the paths of the projects that the worktrees of the
+	// workspace are created from.
+	sources []string
+	// revisions maps the paths of the isolated projects to the
+	// revisions their worktrees are checked out at.
+	revisions map[string]string
+	// projectPaths holds the paths of all the projects of the jiri root.
+	projectPaths map[string]bool
+	// mirrored holds the directories of the jiri root that are recreated
+	// in the workspace rather than symlinked, so that the files written
+	// to them stay in the workspace.
+	mirrored map[string]bool
+	// fresh holds the build output directories of the jiri root, which
+	// are created empty in the workspace.
+	fresh map[string]bool
+}
+
+// isolatedWorkspaceRoot returns the root directory of the isolated
+// workspace of the current build.
+func isolatedWorkspaceRoot(jirix *jiri.X) string {
+	return filepath.Join(jirix.Root, isolatedWorkspacesDir, fmt.Sprintf("%d", jenkinsBuildNumberFlag))
+}
+
+// isolatedProjects returns the projects changed by the given CLs, keyed
+// by their paths, which are checked out as worktrees in an isolated
+// workspace.
+func isolatedProjects(cls []cl, projects project.Projects) (map[string]project.Project, error) {
+	isolated := map[string]project.Project{}
+	for _, cl := range cls {
+		p, err := projects.FindUnique(cl.project)
+		if err != nil {
+			return nil, fmt.Errorf("error finding project %q: %v", cl.project, err)
+		}
+		isolated[filepath.Clean(p.Path)] = p
+	}
+	return isolated, nil
+}
+
+// manifestRevision returns the revision of the given project that the
+// manifest identifies, which is the head of the remote branch of the
+// project unless the manifest pins a revision. The current HEAD of the
+// shared checkout is not used, as it can be a stale presubmit test
+// branch.
+func manifestRevision(jirix *jiri.X, p project.Project) (string, error) {
+	if p.Revision != "" && p.Revision != "HEAD" {
+		return p.Revision, nil
+	}
+	branch := p.RemoteBranch
+	if branch == "" {
+		branch = "master"
+	}
+	s := jirix.NewSeq()
+	var stdout bytes.Buffer
+	if err := s.Capture(&stdout, nil).Pushd(p.Path).Last("git", "ls-remote", p.Remote, "refs/heads/"+branch); err != nil {
+		return "", err
+	}
+	fields := strings.Fields(stdout.String())
+	if len(fields) == 0 {
+		return "", fmt.Errorf("branch %q not found in %v", branch, p.Remote)
+	}
+	// Fetch the branch to make the revision available locally. The
+	// revision is taken from ls-remote rather than FETCH_HEAD, which
+	// concurrent builds sharing the checkout overwrite.
+	if err := s.Pushd(p.Path).Last("git", "fetch", p.Remote, branch); err != nil {
+		return "", err
+	}
+	return fields[0], nil
+}
+
+// containsPath returns whether any of the given paths is under the
+// given directory.
+func containsPath(dir string, paths map[string]bool) bool {
+	for path := range paths {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newIsolatedWorkspace creates the isolated workspace of the current
+// build for testing the given CLs. Any workspace left behind by an
+// earlier run or attempt of the build is removed first.
+func newIsolatedWorkspace(jirix *jiri.X, cls []cl, projects project.Projects) (_ *isolatedWorkspace, e error) {
+	isolated, err := isolatedProjects(cls, projects)
+	if err != nil {
+		return nil, err
+	}
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return nil, err
+	}
+	w := &isolatedWorkspace{
+		root:         isolatedWorkspaceRoot(jirix),
+		projects:     project.Projects{},
+		revisions:    map[string]string{},
+		projectPaths: map[string]bool{},
+		mirrored:     map[string]bool{jirix.RootMetaDir(): true, jirix.BinDir(): true},
+		fresh:        map[string]bool{},
+	}
+	for path, p := range isolated {
+		w.sources = append(w.sources, path)
+		if w.revisions[path], err = manifestRevision(jirix, p); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range projects {
+		w.projectPaths[filepath.Clean(p.Path)] = true
+	}
+	for _, workspace := range config.GoWorkspaces() {
+		dir := filepath.Join(jirix.Root, workspace)
+		w.mirrored[dir] = true
+		w.fresh[filepath.Join(dir, "bin")] = true
+		w.fresh[filepath.Join(dir, "pkg")] = true
+	}
+	if err := w.remove(jirix); err != nil {
+		return nil, err
+	}
+	printf(jirix.Stdout(), "### Creating isolated workspace %s\n", w.root)
+	s := jirix.NewSeq()
+	if err := s.MkdirAll(w.root, os.FileMode(0755)).Done(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e != nil {
+			if err := w.remove(jirix); err != nil {
+				fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+			}
+		}
+	}()
+	if err := w.mirror(jirix, jirix.Root, w.root, false); err != nil {
+		return nil, err
+	}
+	// Create the build output directories that do not exist in the
+	// shared checkout yet, so that the builds do not create them there
+	// through a symlinked parent.
+	for dir := range w.fresh {
+		if _, err := os.Stat(filepath.Dir(dir)); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(jirix.Root, dir)
+		if err != nil {
+			return nil, fmt.Errorf("Rel(%v, %v) failed: %v", jirix.Root, dir, err)
+		}
+		if err := s.MkdirAll(filepath.Join(w.root, rel), os.FileMode(0755)).Done(); err != nil {
+			return nil, err
+		}
+	}
+	for key, p := range projects {
+		if _, ok := isolated[filepath.Clean(p.Path)]; ok {
+			rel, err := filepath.Rel(jirix.Root, p.Path)
+			if err != nil {
+				return nil, fmt.Errorf("Rel(%v, %v) failed: %v", jirix.Root, p.Path, err)
+			}
+			p.Path = filepath.Join(w.root, rel)
+		}
+		w.projects[key] = p
+	}
+	return w, nil
+}
+
+// mirror populates the directory dst of the workspace with the
+// contents of the directory src of the jiri root. The isolated
+// projects are checked out as worktrees at their manifest revisions,
+// the build output directories are created empty, the directories that
+// contain isolated projects or build output directories, or that are
+// mirrored themselves, are mirrored recursively, and everything else is
+// symlinked. Within the worktrees, whose src directories are given by
+// inWorktree, only the nested projects are mirrored, as the untracked
+// files of the shared checkout, e.g. build outputs, must not be shared.
+// Files tracked by the worktrees are not replaced.
+func (w *isolatedWorkspace) mirror(jirix *jiri.X, src, dst string, inWorktree bool) error {
+	s := jirix.NewSeq()
+	fileInfos, err := s.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if name == ".git" || (src == jirix.Root && name == isolatedWorkspacesDir) {
+			continue
+		}
+		srcPath, dstPath := filepath.Join(src, name), filepath.Join(dst, name)
+		_, statErr := os.Lstat(dstPath)
+		exists := statErr == nil
+		revision, isolated := w.revisions[srcPath]
+		switch {
+		case isolated && !exists:
+			if err := s.Pushd(srcPath).Last("git", "worktree", "add", "--detach", dstPath, revision); err != nil {
+				return err
+			}
+			if err := w.mirror(jirix, srcPath, dstPath, true); err != nil {
+				return err
+			}
+		case w.fresh[srcPath]:
+			if !exists {
+				if err := s.MkdirAll(dstPath, fileInfo.Mode().Perm()).Done(); err != nil {
+					return err
+				}
+			}
+		case fileInfo.IsDir() && w.mirrorsDir(srcPath, inWorktree):
+			if !exists {
+				if err := s.MkdirAll(dstPath, fileInfo.Mode().Perm()).Done(); err != nil {
+					return err
+				}
+			}
+			if err := w.mirror(jirix, srcPath, dstPath, inWorktree); err != nil {
+				return err
+			}
+		case inWorktree && !w.projectPaths[srcPath]:
+			// Untracked files of the shared checkout are left out.
+		case !exists:
+			if err := s.Symlink(srcPath, dstPath).Done(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mirrorsDir returns whether the given directory of the jiri root is
+// mirrored recursively rather than symlinked.
+func (w *isolatedWorkspace) mirrorsDir(dir string, inWorktree bool) bool {
+	if w.mirrored[dir] || containsPath(dir, w.mirrored) || containsPath(dir, w.fresh) {
+		return true
+	}
+	for path := range w.revisions {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return inWorktree && containsPath(dir, w.projectPaths)
+}
+
+// env returns the environment variables that make jiri tools use the
+// workspace as the jiri root.
+func (w *isolatedWorkspace) env() map[string]string {
+	return map[string]string{"JIRI_ROOT": w.root}
+}
+
+// remove removes the workspace, if it exists, and prunes the worktrees
+// of the projects it is created from.
+func (w *isolatedWorkspace) remove(jirix *jiri.X) error {
+	s := jirix.NewSeq()
+	if err := s.RemoveAll(w.root).Done(); err != nil {
+		return err
+	}
+	for _, src := range w.sources {
+		if err := s.Pushd(src).Last("git", "worktree", "prune"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"v.io/jiri/jiritest"
+	"v.io/jiri/project"
+	"v.io/x/devtools/tooldata"
+)
+
+func TestIsolatedWorkspace(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	root := fake.X.Root
+
+	// Create a Go workspace with build outputs, a project in it with a
+	// tracked file and a nested untracked directory, and a directory
+	// that is not part of any project.
+	if err := tooldata.SaveConfig(fake.X, tooldata.NewConfig(tooldata.GoWorkspacesOpt{"go"})); err != nil {
+		t.Fatal(err)
+	}
+	projectPath := filepath.Join(root, "go", "src", "changed")
+	for _, dir := range []string{filepath.Join(projectPath, "untracked"), filepath.Join(root, "go", "pkg"), filepath.Join(root, "other")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(projectPath, "tracked"):           "tracked",
+		filepath.Join(projectPath, "untracked", "file"): "untracked",
+		filepath.Join(root, "go", "pkg", "file"):        "pkg",
+		filepath.Join(root, "other", "file"):            "other",
+	}
+	for file, content := range files {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = projectPath
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init")
+	git("add", "tracked")
+	git("commit", "-m", "initial")
+	branch := git("rev-parse", "--abbrev-ref", "HEAD")
+	// Leave the shared checkout on a stale presubmit test branch.
+	git("checkout", "-b", "presubmit_stale")
+	if err := ioutil.WriteFile(filepath.Join(projectPath, "stale"), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "stale")
+	git("commit", "-m", "stale")
+
+	savedBuildNumber := jenkinsBuildNumberFlag
+	defer func() { jenkinsBuildNumberFlag = savedBuildNumber }()
+	jenkinsBuildNumberFlag = 42
+	projects := project.Projects{
+		project.ProjectKey("changed"): project.Project{Name: "changed", Path: projectPath, Remote: projectPath, RemoteBranch: branch},
+	}
+	w, err := newIsolatedWorkspace(fake.X, []cl{{project: "changed"}}, projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.root, filepath.Join(root, isolatedWorkspacesDir, "42"); got != want {
+		t.Fatalf("got root %v, want %v", got, want)
+	}
+	worktree := filepath.Join(w.root, "go", "src", "changed")
+	if got, want := w.projects[project.ProjectKey("changed")].Path, worktree; got != want {
+		t.Fatalf("got project path %v, want %v", got, want)
+	}
+
+	// The changed project is a worktree at the revision of the remote
+	// branch, the build outputs are not shared and the rest is
+	// symlinked.
+	for path, symlink := range map[string]bool{
+		filepath.Join(w.root, "go"):        false,
+		filepath.Join(w.root, "go", "src"): false,
+		filepath.Join(w.root, "go", "pkg"): false,
+		filepath.Join(w.root, "go", "bin"): false,
+		worktree:                           false,
+		filepath.Join(worktree, "tracked"): false,
+		filepath.Join(w.root, "other"):     true,
+	} {
+		fileInfo, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fileInfo.Mode()&os.ModeSymlink != 0; got != symlink {
+			t.Errorf("%v: got symlink %v, want %v", path, got, symlink)
+		}
+	}
+	for _, path := range []string{
+		filepath.Join(worktree, "stale"),
+		filepath.Join(worktree, "untracked"),
+		filepath.Join(w.root, "go", "pkg", "file"),
+	} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%v is shared with the workspace: %v", path, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(w.root, isolatedWorkspacesDir)); !os.IsNotExist(err) {
+		t.Errorf("the workspaces directory is mirrored into the workspace: %v", err)
+	}
+
+	// Changes in the worktree do not affect the shared checkout.
+	if err := ioutil.WriteFile(filepath.Join(worktree, "tracked"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(projectPath, "tracked")); err != nil || string(data) != "tracked" {
+		t.Errorf("shared checkout changed: %q, %v", data, err)
+	}
+
+	if err := w.remove(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(w.root); !os.IsNotExist(err) {
+		t.Errorf("workspace not removed: %v", err)
+	}
+	cmd := exec.Command("git", "worktree", "list", "--porcelain")
+	cmd.Dir = projectPath
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(string(out), "worktree "), 1; got != want {
+		t.Errorf("got %d worktrees, want %d:\n%s", got, want, out)
+	}
+}
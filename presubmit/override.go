@@ -0,0 +1,207 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"v.io/jiri"
+)
+
+const (
+	// testOverridePrefix starts the review comment lines and the
+	// hashtags that override the tests selected for a change.
+	testOverridePrefix = "PresubmitTest:"
+	// testOverrideParam names the parameter of the presubmit-test
+	// Jenkins job that records the test override of a build.
+	testOverrideParam = "TEST_OVERRIDE"
+)
+
+// testOverride records the tests that a developer asked presubmit to
+// run for a change, using a review comment line or a hashtag such as
+// "PresubmitTest: vanadium-go-race,vanadium-integration-test". The tests
+// replace the tests selected by the tools config, unless the
+// list starts with "+", in which case they are added to them. Since any
+// commenter can request an override, only registered tests can be
+// requested, and changes whose tests were replaced are not marked as
+// verified.
+type testOverride struct {
+	tests   []string
+	augment bool
+}
+
+// String returns the representation of the override used in review
+// comments and hashtags, without the prefix.
+func (o *testOverride) String() string {
+	s := strings.Join(o.tests, ",")
+	if o.augment {
+		s = "+" + s
+	}
+	return s
+}
+
+// apply returns the given tests with the override applied.
+func (o *testOverride) apply(tests []string) []string {
+	if o == nil {
+		return tests
+	}
+	result := map[string]bool{}
+	if o.augment {
+		for _, test := range tests {
+			result[test] = true
+		}
+	}
+	for _, test := range o.tests {
+		result[test] = true
+	}
+	sorted := []string{}
+	for test := range result {
+		sorted = append(sorted, test)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// restrict returns the override restricted to the given registered
+// tests, along with the requested tests that are not registered. It
+// returns nil if none of the requested tests is registered.
+func (o *testOverride) restrict(registered []string) (*testOverride, []string) {
+	known := map[string]bool{}
+	for _, test := range registered {
+		known[test] = true
+	}
+	result, unknown := &testOverride{augment: o.augment}, []string{}
+	for _, test := range o.tests {
+		if known[test] {
+			result.tests = append(result.tests, test)
+		} else {
+			unknown = append(unknown, test)
+		}
+	}
+	if len(result.tests) == 0 {
+		return nil, unknown
+	}
+	return result, unknown
+}
+
+// listRegisteredTests returns the tests that "jiri-test run" can run.
+func listRegisteredTests(jirix *jiri.X) ([]string, error) {
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, nil).Verbose(false).Last("jiri-test", "list"); err != nil {
+		return nil, err
+	}
+	tests := []string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") {
+			tests = append(tests, line)
+		}
+	}
+	return tests, nil
+}
+
+// parseTestOverride parses the given directive, without the prefix. It
+// returns nil if the directive lists no tests.
+func parseTestOverride(directive string) *testOverride {
+	directive = strings.TrimSpace(directive)
+	o := &testOverride{}
+	if strings.HasPrefix(directive, "+") {
+		o.augment = true
+		directive = directive[1:]
+	}
+	for _, test := range strings.FieldsFunc(directive, func(r rune) bool { return r == ',' || r == ' ' }) {
+		o.tests = append(o.tests, test)
+	}
+	if len(o.tests) == 0 {
+		return nil
+	}
+	return o
+}
+
+// findTestOverride returns the test override requested by the given
+// hashtags and review comments, which are ordered from the oldest to
+// the newest. Review comments take precedence over hashtags, and the
+// newest directive wins. It returns nil if no override is requested.
+func findTestOverride(hashtags, comments []string) *testOverride {
+	var result *testOverride
+	for _, hashtag := range hashtags {
+		if strings.HasPrefix(hashtag, testOverridePrefix) {
+			if o := parseTestOverride(strings.TrimPrefix(hashtag, testOverridePrefix)); o != nil {
+				result = o
+			}
+		}
+	}
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, testOverridePrefix) {
+				if o := parseTestOverride(strings.TrimPrefix(line, testOverridePrefix)); o != nil {
+					result = o
+				}
+			}
+		}
+	}
+	return result
+}
+
+// queryTestOverride returns the test override requested by the review
+// comments and hashtags of the changes identified by the given refs.
+// When several changes request an override, the tests of all of them
+// are run. Requested tests that are not registered are ignored. It
+// returns nil if no override is requested.
+func queryTestOverride(jirix *jiri.X, refs []string) (*testOverride, error) {
+	var result *testOverride
+	for _, ref := range refs {
+		comments, hashtags, err := backendForRef(ref).comments(jirix, ref)
+		if err != nil {
+			return nil, err
+		}
+		o := findTestOverride(hashtags, comments)
+		switch {
+		case o == nil:
+		case result == nil:
+			result = o
+		default:
+			result = &testOverride{
+				tests:   append(append([]string{}, result.tests...), o.tests...),
+				augment: result.augment && o.augment,
+			}
+		}
+	}
+	if result == nil {
+		return nil, nil
+	}
+	registered, err := listRegisteredTests(jirix)
+	if err != nil {
+		return nil, err
+	}
+	result, unknown := result.restrict(registered)
+	if len(unknown) != 0 {
+		printf(jirix.Stderr(), "Ignoring unknown tests in the test override: %s\n", strings.Join(unknown, ","))
+	}
+	return result, nil
+}
+
+// reportTestOverride reports the test override requested for the CLs,
+// which the presubmit-test Jenkins job records in the TEST_OVERRIDE
+// environment variable. It returns whether the override replaced the
+// tests selected by the tools config, in which case the CLs must not be
+// marked as verified.
+func (r *testReporter) reportTestOverride() bool {
+	override := os.Getenv(testOverrideParam)
+	if override == "" {
+		return false
+	}
+	fmt.Fprintf(r.report, "Test selection overridden by review comment: %s\n", override)
+	if strings.HasPrefix(override, "+") {
+		fmt.Fprintf(r.report, "\n")
+		return false
+	}
+	fmt.Fprintf(r.report, "The CLs are not marked as verified because the selected tests were replaced.\n\n")
+	return true
+}
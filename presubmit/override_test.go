@@ -0,0 +1,111 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindTestOverride(t *testing.T) {
+	tests := []struct {
+		hashtags, comments []string
+		want               *testOverride
+	}{
+		{nil, nil, nil},
+		{
+			nil,
+			[]string{"Patch Set 1:\n\nLGTM"},
+			nil,
+		},
+		{
+			nil,
+			[]string{"Patch Set 1:\n\nPresubmitTest: vanadium-go-race,vanadium-integration-test"},
+			&testOverride{tests: []string{"vanadium-go-race", "vanadium-integration-test"}},
+		},
+		{
+			[]string{"PresubmitTest:+vanadium-go-race"},
+			nil,
+			&testOverride{tests: []string{"vanadium-go-race"}, augment: true},
+		},
+		{
+			// Comments take precedence over hashtags, and the newest
+			// comment wins.
+			[]string{"PresubmitTest:vanadium-go-race"},
+			[]string{"PresubmitTest: vanadium-go-test", "PresubmitTest: + vanadium-js-test, vanadium-go-test\nThanks!"},
+			&testOverride{tests: []string{"vanadium-js-test", "vanadium-go-test"}, augment: true},
+		},
+		{
+			// Directives without tests are ignored.
+			[]string{"PresubmitTest:vanadium-go-race"},
+			[]string{"PresubmitTest:"},
+			&testOverride{tests: []string{"vanadium-go-race"}},
+		},
+	}
+	for _, test := range tests {
+		if got := findTestOverride(test.hashtags, test.comments); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %v: want %v, got %v", test.hashtags, test.comments, test.want, got)
+		}
+	}
+}
+
+func TestApplyTestOverride(t *testing.T) {
+	tests := []string{"vanadium-go-build", "vanadium-go-test"}
+	var none *testOverride
+	if got := none.apply(tests); !reflect.DeepEqual(got, tests) {
+		t.Errorf("want %v, got %v", tests, got)
+	}
+	replace := &testOverride{tests: []string{"vanadium-go-race"}}
+	if got, want := replace.apply(tests), []string{"vanadium-go-race"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	augment := &testOverride{tests: []string{"vanadium-go-test", "vanadium-go-race"}, augment: true}
+	if got, want := augment.apply(tests), []string{"vanadium-go-build", "vanadium-go-race", "vanadium-go-test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got, want := augment.String(), "+vanadium-go-test,vanadium-go-race"; got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestRestrictTestOverride(t *testing.T) {
+	registered := []string{"vanadium-go-race", "vanadium-go-test"}
+	o := &testOverride{tests: []string{"vanadium-go-race", "trivial-test"}, augment: true}
+	got, unknown := o.restrict(registered)
+	if want := (&testOverride{tests: []string{"vanadium-go-race"}, augment: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want := []string{"trivial-test"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("want %v, got %v", want, unknown)
+	}
+	if got, _ := (&testOverride{tests: []string{"trivial-test"}}).restrict(registered); got != nil {
+		t.Errorf("want nil, got %v", got)
+	}
+}
+
+func TestReportTestOverride(t *testing.T) {
+	defer os.Setenv(testOverrideParam, os.Getenv(testOverrideParam))
+	tests := []struct {
+		override string
+		replaced bool
+	}{
+		{"", false},
+		{"+vanadium-go-race", false},
+		{"vanadium-go-race", true},
+	}
+	for _, test := range tests {
+		os.Setenv(testOverrideParam, test.override)
+		r := testReporter{report: &bytes.Buffer{}}
+		if got := r.reportTestOverride(); got != test.replaced {
+			t.Errorf("%q: want %v, got %v", test.override, test.replaced, got)
+		}
+		if got := strings.Contains(r.report.String(), "not marked as verified"); got != test.replaced {
+			t.Errorf("%q: unexpected report %q", test.override, r.report.String())
+		}
+	}
+}
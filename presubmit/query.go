@@ -5,7 +5,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"reflect"
@@ -19,15 +21,21 @@ import (
 	"v.io/jiri/tool"
 	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
+	"v.io/x/lib/set"
 )
 
 const (
 	defaultLogFilePath = "${HOME}/tmp/presubmit_log"
+	// reviewBackendLogSuffix is appended to the path of the log file
+	// to name the file that logs the open changes of the review
+	// backends other than Gerrit.
+	reviewBackendLogSuffix = "review-backends"
 )
 
 var (
-	queryStringFlag string
-	logFilePathFlag string
+	queryStringFlag  string
+	logFilePathFlag  string
+	groupByTopicFlag bool
 
 	emailWhitelist = []string{
 		"aaron@azinman.com",
@@ -39,6 +47,7 @@ func init() {
 	cmdQuery.Flags.StringVar(&queryStringFlag, "query", defaultQueryString, "The string used to query Gerrit for open CLs.")
 	cmdQuery.Flags.StringVar(&logFilePathFlag, "log-file", os.ExpandEnv(defaultLogFilePath), "The file that stores the refs from the previous Gerrit query.")
 	cmdQuery.Flags.Lookup("log-file").DefValue = defaultLogFilePath
+	cmdQuery.Flags.BoolVar(&groupByTopicFlag, "group-by-topic", false, "Test the open CLs that share a Gerrit topic together.")
 
 	tool.InitializeProjectFlags(&cmdQuery.Flags)
 }
@@ -55,6 +64,24 @@ This subcommand queries open CLs from Gerrit, calculates diffs from the previous
 query results, and sends each one with related metadata (ref, project, changeId)
 to a Jenkins job which will run tests against the corresponding CL and post
 review with test results.
+
+If -group-by-topic is set, CLs that share a Gerrit topic are tested together,
+as if they were parts of a multi-part CL. When one of them changes, the test
+run also includes the other open CLs of the topic.
+
+Projects that the tools config assigns to GitHub are queried for open pull
+requests instead, which are tested one at a time. Test results are posted as
+comments on the pull requests, and the pull requests are marked as verified
+using commit statuses. The GitHub API is accessed with the OAuth token in the
+GITHUB_TOKEN environment variable.
+
+The tests selected by the tools config for a change can be overridden with a
+review comment line, a Gerrit hashtag or a GitHub label such as "PresubmitTest:
+vanadium-go-race,vanadium-integration-test". A list that starts with "+" adds
+the tests to the selected ones instead of replacing them. Tests that are not
+registered with jiri-test are ignored, and changes whose selected tests were
+replaced are not marked as verified. The most recent review comment takes
+precedence, and the override is mentioned in the test report.
 `,
 	Runner: jiri.RunnerFunc(runQuery),
 }
@@ -82,6 +109,14 @@ func runQuery(jirix *jiri.X, args []string) error {
 		}
 	}
 
+	// Send the changes of the projects whose changes are reviewed
+	// outside of Gerrit.
+	numSent, err := sendReviewBackendChanges(jirix)
+	if err != nil {
+		return err
+	}
+	numSentCLs += numSent
+
 	// Read previous CLs from the log file.
 	prevCLsMap, err := gerrit.ReadLog(logFilePathFlag)
 	if err != nil {
@@ -131,6 +166,10 @@ func runQuery(jirix *jiri.X, args []string) error {
 		}
 	}
 
+	if groupByTopicFlag {
+		newCLLists = groupCLListsByTopic(newCLLists, curCLs)
+	}
+
 	// Send the new open CLs one by one to the given Jenkins
 	// project to run presubmit-test builds.
 	projects, _, err := project.LoadManifest(jirix)
@@ -144,6 +183,7 @@ func runQuery(jirix *jiri.X, args []string) error {
 		removeOutdatedFn: removeOutdatedBuilds,
 		addPresubmitFn:   addPresubmitTestBuild,
 		postMessageFn:    postMessage,
+		testOverrideFn:   queryTestOverride,
 	}
 	if err := sender.sendCLListsToPresubmitTest(jirix); err != nil {
 		return err
@@ -164,13 +204,191 @@ func runQuery(jirix *jiri.X, args []string) error {
 	return nil
 }
 
+// sendReviewBackendChanges sends the open changes of the projects that
+// the tools config assigns to a review backend other than Gerrit to the
+// presubmit-test Jenkins job, one change at a time. As for Gerrit, the
+// refs of the open changes are logged, and only the changes that were
+// not open during the previous query are sent. It returns how many
+// changes have been sent.
+func sendReviewBackendChanges(jirix *jiri.X) (int, error) {
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return 0, err
+	}
+	projectNames := []string{}
+	for name, settings := range config.ReviewBackends() {
+		if settings.Type != tooldata.ReviewBackendGerrit {
+			projectNames = append(projectNames, name)
+		}
+	}
+	if len(projectNames) == 0 {
+		return 0, nil
+	}
+	sort.Strings(projectNames)
+	changes := []reviewChange{}
+	for _, name := range projectNames {
+		backend, err := backendForProject(config.ReviewBackend(name))
+		if err != nil {
+			return 0, err
+		}
+		projectChanges, err := backend.openChanges(jirix)
+		if err != nil {
+			return 0, err
+		}
+		changes = append(changes, projectChanges...)
+	}
+
+	// Log the refs of the current changes, and read the refs of the
+	// previous ones.
+	logFile := logFilePathFlag + "-" + reviewBackendLogSuffix
+	prevRefs, err := readReviewBackendLog(logFile)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeReviewBackendLog(logFile, changes); err != nil {
+		return 0, err
+	}
+	if jenkinsHostFlag == "" || prevRefs == nil {
+		return 0, nil
+	}
+
+	sender := clsSender{postMessageFn: postMessage, testOverrideFn: queryTestOverride}
+	numSent := 0
+	for _, change := range newReviewChanges(prevRefs, changes) {
+		refs, projects := []string{change.ref}, []string{change.project}
+		override := sender.getTestOverride(jirix, refs)
+		tests, err := sender.getTestsToRun(jirix, projects, override)
+		if err != nil {
+			return numSent, err
+		}
+		if len(tests) == 0 {
+			if err := postMessage(jirix, "No tests found.\n", refs, true); err != nil {
+				return numSent, err
+			}
+			printf(jirix.Stdout(), "SKIP: Add %s (no tests found)\n", change.ref)
+			continue
+		}
+		if !change.trusted {
+			if err := sender.handleNonGoogleOwner(jirix, refs, projects, tests); err != nil {
+				return numSent, err
+			}
+			printf(jirix.Stdout(), "SKIP: Add %s (untrusted author)\n", change.ref)
+			continue
+		}
+		if err := addPresubmitTestBuildForRefs(jirix, refs, projects, tests, override); err != nil {
+			printf(jirix.Stdout(), "FAIL: Add %s\n", change.ref)
+			printf(jirix.Stderr(), "addPresubmitTestBuild failed: %v\n", err)
+		} else {
+			printf(jirix.Stdout(), "PASS: Add %s\n", change.ref)
+			numSent++
+		}
+	}
+	return numSent, nil
+}
+
+// readReviewBackendLog reads the refs logged by writeReviewBackendLog. It
+// returns nil if the log does not exist.
+func readReviewBackendLog(path string) (map[string]struct{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ReadFile(%v) failed: %v", path, err)
+	}
+	var refs []string
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(data), err)
+	}
+	return set.String.FromSlice(refs), nil
+}
+
+// writeReviewBackendLog logs the refs of the given changes.
+func writeReviewBackendLog(path string, changes []reviewChange) error {
+	refs := []string{}
+	for _, change := range changes {
+		refs = append(refs, change.ref)
+	}
+	data, err := json.MarshalIndent(refs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent(%v) failed: %v", refs, err)
+	}
+	if err := ioutil.WriteFile(path, data, os.FileMode(0644)); err != nil {
+		return fmt.Errorf("WriteFile(%v) failed: %v", path, err)
+	}
+	return nil
+}
+
+// newReviewChanges returns the given changes whose refs are not in the
+// given set of previous refs.
+func newReviewChanges(prevRefs map[string]struct{}, changes []reviewChange) []reviewChange {
+	result := []reviewChange{}
+	for _, change := range changes {
+		if _, ok := prevRefs[change.ref]; !ok {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// groupCLListsByTopic merges the given CL lists that consist of a single
+// CL with a Gerrit topic into one CL list per topic, which also includes
+// the other open CLs with that topic. Multi-part CL lists, which are
+// grouped already, and CLs without a topic are kept as they are. CL lists
+// whose CLs are all included in earlier CL lists are dropped.
+func groupCLListsByTopic(clLists []gerrit.CLList, openCLs gerrit.CLList) []gerrit.CLList {
+	result := []gerrit.CLList{}
+	topicIndex := map[string]int{}
+	seen := map[string]bool{}
+	for _, clList := range clLists {
+		if len(clList) == 1 && clList[0].MultiPart == nil && clList[0].Topic != "" {
+			cl := clList[0]
+			if seen[cl.Reference()] {
+				continue
+			}
+			seen[cl.Reference()] = true
+			if i, ok := topicIndex[cl.Topic]; ok {
+				result[i] = append(result[i], cl)
+			} else {
+				topicIndex[cl.Topic] = len(result)
+				result = append(result, gerrit.CLList{cl})
+			}
+			continue
+		}
+		allSeen := true
+		for _, cl := range clList {
+			if !seen[cl.Reference()] {
+				allSeen = false
+			}
+			seen[cl.Reference()] = true
+		}
+		if !allSeen {
+			result = append(result, clList)
+		}
+	}
+	// Add the open CLs of each topic that did not change.
+	for _, cl := range openCLs {
+		i, ok := topicIndex[cl.Topic]
+		if !ok || cl.MultiPart != nil || seen[cl.Reference()] {
+			continue
+		}
+		seen[cl.Reference()] = true
+		result[i] = append(result[i], cl)
+	}
+	return result
+}
+
 type clsSender struct {
 	clLists          []gerrit.CLList
 	projects         project.Projects
 	clsSent          int
 	removeOutdatedFn func(*jiri.X, clNumberToPatchsetMap) []error
-	addPresubmitFn   func(*jiri.X, gerrit.CLList, []string) error
+	addPresubmitFn   func(*jiri.X, gerrit.CLList, []string, *testOverride) error
 	postMessageFn    func(*jiri.X, string, []string, bool) error
+	// testOverrideFn returns the test override requested for the
+	// changes identified by the given refs. If it is nil, the tests
+	// selected by the tools config are run.
+	testOverrideFn func(*jiri.X, []string) (*testOverride, error)
 }
 
 // sendCLListsToPresubmitTest sends the given clLists to presubmit-test Jenkins
@@ -197,7 +415,8 @@ func (s *clsSender) sendCLListsToPresubmitTest(jirix *jiri.X) error {
 		}
 
 		// Skip if there is no tests to run.
-		tests, err := s.getTestsToRun(jirix, clListInfo.projects)
+		override := s.getTestOverride(jirix, clListInfo.refs)
+		tests, err := s.getTestsToRun(jirix, clListInfo.projects, override)
 		if err != nil {
 			return err
 		}
@@ -230,7 +449,7 @@ func (s *clsSender) sendCLListsToPresubmitTest(jirix *jiri.X) error {
 
 		// Send curCLList to presubmit-test.
 		strCLs := fmt.Sprintf("Add %s", clListInfo.clString)
-		if err := s.addPresubmitFn(jirix, curCLList, tests); err != nil {
+		if err := s.addPresubmitFn(jirix, curCLList, tests, override); err != nil {
 			printf(jirix.Stdout(), "FAIL: %s\n", strCLs)
 			printf(jirix.Stderr(), "addPresubmitTestBuild failed: %v\n", err)
 		} else {
@@ -296,12 +515,33 @@ func (s *clsSender) processCLList(jirix *jiri.X, curCLList gerrit.CLList) *proce
 	}
 }
 
-func (s *clsSender) getTestsToRun(jirix *jiri.X, projects []string) ([]string, error) {
+// getTestOverride returns the test override requested for the changes
+// identified by the given refs, or nil if there is none. Since the
+// override is optional, failures to retrieve it are only reported.
+func (s *clsSender) getTestOverride(jirix *jiri.X, refs []string) *testOverride {
+	if s.testOverrideFn == nil {
+		return nil
+	}
+	override, err := s.testOverrideFn(jirix, refs)
+	if err != nil {
+		printf(jirix.Stderr(), "%v\n", err)
+		return nil
+	}
+	if override != nil {
+		printf(jirix.Stdout(), "Test selection of %s overridden: %s\n", strings.Join(refs, ":"), override)
+	}
+	return override
+}
+
+// getTestsToRun returns the tests to run for changes of the given
+// projects, which are the tests the tools config selects for the
+// projects with the given override, if any, applied.
+func (s *clsSender) getTestsToRun(jirix *jiri.X, projects []string, override *testOverride) ([]string, error) {
 	config, err := tooldata.LoadConfig(jirix)
 	if err != nil {
 		return nil, err
 	}
-	tmpTests := config.ProjectTests(projects)
+	tmpTests := override.apply(config.ProjectTests(projects))
 	tests := []string{}
 	// Append the part suffix to tests that have multiple parts specified in the config file.
 	for _, test := range tmpTests {
@@ -468,24 +708,35 @@ func sortedKeys(cls clNumberToPatchsetMap) []int {
 
 // addPresubmitTestBuild uses Jenkins' remote access API to add a build for
 // a set of open CLs to run presubmit tests.
-func addPresubmitTestBuild(jirix *jiri.X, cls gerrit.CLList, tests []string) error {
-	jenkins, err := jirix.Jenkins(jenkinsHostFlag)
-	if err != nil {
-		return err
-	}
-
+func addPresubmitTestBuild(jirix *jiri.X, cls gerrit.CLList, tests []string, override *testOverride) error {
 	refs, projects := []string{}, []string{}
 	for _, cl := range cls {
 		refs = append(refs, cl.Reference())
 		projects = append(projects, cl.Project)
 	}
-	if err := jenkins.AddBuildWithParameter(presubmitTestJobFlag, url.Values{
+	return addPresubmitTestBuildForRefs(jirix, refs, projects, tests, override)
+}
+
+// addPresubmitTestBuildForRefs uses Jenkins' remote access API to add a
+// build for the changes identified by the given refs and projects to run
+// presubmit tests. The given test override, if any, is recorded in the
+// parameters of the build so that the test report can mention it.
+func addPresubmitTestBuildForRefs(jirix *jiri.X, refs, projects, tests []string, override *testOverride) error {
+	jenkins, err := jirix.Jenkins(jenkinsHostFlag)
+	if err != nil {
+		return err
+	}
+	params := url.Values{
 		"REFS":     {strings.Join(refs, ":")},
 		"PROJECTS": {strings.Join(projects, ":")},
 		// Separating by spaces is required by the Dynamic Axis plugin used in the
 		// new presubmit test target.
 		"TESTS": {strings.Join(tests, " ")},
-	}); err != nil {
+	}
+	if override != nil {
+		params.Set(testOverrideParam, override.String())
+	}
+	if err := jenkins.AddBuildWithParameter(presubmitTestJobFlag, params); err != nil {
 		return err
 	}
 	return nil
@@ -79,7 +79,7 @@ func TestSendCLListsToPresubmitTest(t *testing.T) {
 
 		// Mock out the addPresubmitTestBuild function.
 		// It will return error for the first clList.
-		addPresubmitFn: func(jirix *jiri.X, cls gerrit.CLList, tests []string) error {
+		addPresubmitFn: func(jirix *jiri.X, cls gerrit.CLList, tests []string, override *testOverride) error {
 			if reflect.DeepEqual(cls, clLists[0]) {
 				return fmt.Errorf("err")
 			} else {
@@ -151,7 +151,23 @@ func TestGetTestsToRun(t *testing.T) {
 		"vanadium-go-test",
 	}
 	sender := clsSender{}
-	got, err := sender.getTestsToRun(fake.X, []string{"release.go.core"})
+	got, err := sender.getTestsToRun(fake.X, []string{"release.go.core"}, nil)
+	if err != nil {
+		t.Fatalf("want no errors, got: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("want %v, got %v", expected, got)
+	}
+
+	// Check that overrides are applied before the part suffixes are
+	// appended.
+	expected = []string{
+		"vanadium-go-race-part0",
+		"vanadium-go-race-part1",
+		"vanadium-go-race-part2",
+		"vanadium-integration-test",
+	}
+	got, err = sender.getTestsToRun(fake.X, []string{"release.go.core"}, &testOverride{tests: []string{"vanadium-go-race", "vanadium-integration-test"}})
 	if err != nil {
 		t.Fatalf("want no errors, got: %v", err)
 	}
@@ -229,3 +245,43 @@ func TestIsBuildOutdated(t *testing.T) {
 		}
 	}
 }
+
+func TestGroupCLListsByTopic(t *testing.T) {
+	genCLWithTopic := func(clNumber, patchset int, project, topic string) gerrit.Change {
+		cl := gerrit.GenCL(clNumber, patchset, project)
+		cl.Topic = topic
+		return cl
+	}
+	cls := gerrit.CLList{
+		// cls[0]: CL without a topic.
+		gerrit.GenCL(1000, 1, "release.go.core"),
+		// cls[1], cls[2], cls[3]: CLs with topic "a".
+		genCLWithTopic(1001, 1, "release.go.core", "a"),
+		genCLWithTopic(1002, 2, "release.js.core", "a"),
+		genCLWithTopic(1003, 1, "release.go.x.ref", "a"),
+		// cls[4]: CL with topic "b".
+		genCLWithTopic(1004, 1, "release.go.core", "b"),
+		// cls[5], cls[6]: multi-part CL.
+		gerrit.GenMultiPartCL(1005, 1, "release.js.core", "t", 1, 2),
+		gerrit.GenMultiPartCL(1006, 1, "release.go.core", "t", 2, 2),
+	}
+	clLists := []gerrit.CLList{
+		gerrit.CLList{cls[0]},
+		gerrit.CLList{cls[1]},
+		gerrit.CLList{cls[4]},
+		gerrit.CLList{cls[5], cls[6]},
+		gerrit.CLList{cls[2]},
+		gerrit.CLList{cls[2]},
+	}
+	// The unchanged CL cls[3] of topic "a" is added from the open CLs.
+	got := groupCLListsByTopic(clLists, cls)
+	want := []gerrit.CLList{
+		gerrit.CLList{cls[0]},
+		gerrit.CLList{cls[1], cls[2], cls[3]},
+		gerrit.CLList{cls[4]},
+		gerrit.CLList{cls[5], cls[6]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want:\n%#v\n\ngot:\n%#v", want, got)
+	}
+}
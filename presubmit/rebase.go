@@ -0,0 +1,72 @@
+// Copyright 2015 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/jiri/gitutil"
+	"v.io/jiri/project"
+)
+
+const autoRebaseMessageTmpl = "Note: %s could not be merged and was tested after clean auto-rebase onto master."
+
+// autoRebaseCL attempts to rebase the given CL onto the current head of
+// the presubmit test branch of the given project, after pulling the CL
+// into that branch failed. The rebase is performed in a scratch worktree
+// so that the project is left untouched if the rebase fails. If the
+// rebase is clean, the presubmit test branch is fast-forwarded to the
+// rebased CL.
+func autoRebaseCL(jirix *jiri.X, localProject project.Project, curCL cl) (e error) {
+	s := jirix.NewSeq()
+	git := gitutil.New(s, gitutil.RootDirOpt(localProject.Path))
+
+	// Discard the state left behind by the failed pull, including the
+	// conflict markers and MERGE_HEAD, so that the project is clean
+	// whether or not the rebase succeeds. The pull did not move HEAD.
+	if err := s.Pushd(localProject.Path).Last("git", "reset", "--hard", "HEAD"); err != nil {
+		return err
+	}
+	base, err := git.CurrentRevision()
+	if err != nil {
+		return err
+	}
+	remote, refspec, err := backendForRef(curCL.ref).pullSource(localProject, curCL.ref)
+	if err != nil {
+		return err
+	}
+	if err := git.FetchRefspec(remote, refspec); err != nil {
+		return err
+	}
+
+	// Rebase the CL in a scratch worktree.
+	worktreeDir, err := s.TempDir("", "presubmit-rebase")
+	if err != nil {
+		return err
+	}
+	if err := s.RemoveAll(worktreeDir).Pushd(localProject.Path).
+		Last("git", "worktree", "add", "--detach", worktreeDir, "FETCH_HEAD"); err != nil {
+		return err
+	}
+	defer collect.Error(func() error {
+		return jirix.NewSeq().RemoveAll(worktreeDir).Pushd(localProject.Path).
+			Last("git", "worktree", "prune")
+	}, &e)
+	if err := s.Pushd(worktreeDir).Last("git", "rebase", base); err != nil {
+		if abortErr := s.Pushd(worktreeDir).Last("git", "rebase", "--abort"); abortErr != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", abortErr)
+		}
+		return fmt.Errorf("rebase of %s onto %s failed: %v", curCL.String(), base, err)
+	}
+	rebased, err := gitutil.New(s, gitutil.RootDirOpt(worktreeDir)).CurrentRevision()
+	if err != nil {
+		return err
+	}
+
+	// Move the presubmit test branch to the rebased CL.
+	return s.Pushd(localProject.Path).Last("git", "merge", "--ff-only", rebased)
+}
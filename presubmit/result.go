@@ -63,22 +63,32 @@ const (
 // testResultSummary stores data for generating summary for a test.
 type testResultSummary struct {
 	testNameWithLabels string // labels include os and architecture.
+	testName           string
+	label              string // label of the sub-job, e.g. "linux,amd64".
 	lastStatus         testStatus
 	curStatus          testStatus
 	timeoutValue       time.Duration
 }
 
 var (
-	dashboardHostFlag string
-	projectsFlag      string
-	reviewMessageFlag string
+	archiveBucketFlag   string
+	dashboardHostFlag   string
+	flakeConfidenceFlag float64
+	flakeRerunOfFlag    int
+	flakesDBFlag        string
+	projectsFlag        string
+	reviewMessageFlag   string
 
 	unknownStatusString = "UNKNOWN"
 	successStatusString = "SUCCESS"
 )
 
 func init() {
+	cmdResult.Flags.StringVar(&archiveBucketFlag, "archive-bucket", "", "The Google Storage location to archive the test results of the build to, e.g. gs://vanadium-presubmit-archive. Test results are not archived if empty.")
 	cmdResult.Flags.StringVar(&dashboardHostFlag, "dashboard-host", "https://dashboard.v.io", "The host of the dashboard server.")
+	cmdResult.Flags.Float64Var(&flakeConfidenceFlag, "flake-confidence", 0.9, "The minimum confidence of a known flaky signature for a failure to be considered a flake.")
+	cmdResult.Flags.IntVar(&flakeRerunOfFlag, "flake-rerun-of", -1, "The number of the Jenkins build whose flaky failures this build re-runs.")
+	cmdResult.Flags.StringVar(&flakesDBFlag, "flakes-db", "", "The local path or Google Storage location of the JSON file that lists known flaky signatures. Failures are not matched against known flakes if empty.")
 	cmdResult.Flags.StringVar(&projectsFlag, "projects", "", "The base names of the remote projects containing the CLs pointed by the refs, separated by ':'.")
 	cmdResult.Flags.StringVar(&reviewTargetRefsFlag, "refs", "", "The review references separated by ':'.")
 	cmdResult.Flags.IntVar(&jenkinsBuildNumberFlag, "build-number", -1, "The number of the Jenkins build.")
@@ -94,6 +104,10 @@ var cmdResult = &cmdline.Command{
 Result processes all the test statuses and results files collected from all the
 presubmit test configuration builds, creates a result summary, and posts the
 summary back to the corresponding Gerrit review thread.
+
+If the presubmit build failed outright or found more new failures than the
+escalation threshold, the current oncalls are notified via email and/or a chat
+webhook, as configured by the -escalation-* flags.
 `,
 	Runner: jiri.RunnerFunc(runResult),
 }
@@ -104,6 +118,7 @@ type testResultInfo struct {
 	Timestamp        int64
 	PostSubmitResult string
 	AxisValues       axisValuesInfo
+	Changes          *changeSummary // Summary of the changes made by the CLs, if available.
 }
 
 type axisValuesInfo struct {
@@ -220,13 +235,22 @@ func runResult(jirix *jiri.X, args []string) (e error) {
 		testResults = append(testResults, curResult)
 	}
 
+	// Archive the test results before the Jenkins workspace gets wiped.
+	// Failing to do so does not fail the "result" phase.
+	archiveURL := ""
+	if archiveBucketFlag != "" {
+		if archiveURL, err = archiveTestResults(jirix, curTestResultsDir); err != nil {
+			fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+		}
+	}
+
 	// Post results.
 	refs := strings.Split(reviewTargetRefsFlag, ":")
 	postSubmitResults, err := getPostSubmitBuildData(jirix, testResults, matrixJobsConf)
 	if err != nil {
 		return err
 	}
-	reporter := testReporter{matrixJobsConf, testResults, postSubmitResults, refs, &bytes.Buffer{}}
+	reporter := testReporter{matrixJobsConf, testResults, postSubmitResults, refs, archiveURL, &bytes.Buffer{}}
 	if allTestsPassed, err := reporter.postReport(jirix); err != nil {
 		return err
 	} else if allTestsPassed {
@@ -304,6 +328,9 @@ type testReporter struct {
 	postSubmitResults map[string]*postSubmitBuildData
 	// refs identifies the references to post the report to.
 	refs []string
+	// archiveURL identifies the Google Storage location of the archived
+	// test results, if any.
+	archiveURL string
 	// report stores the report content.
 	report *bytes.Buffer
 }
@@ -324,6 +351,7 @@ func (r *testReporter) postReport(jirix *jiri.X) (bool, error) {
 	printf(jirix.Stdout(), "### Preparing report\n")
 
 	if r.reportFailedPresubmitBuild(jirix) {
+		r.escalate(jirix, r.failedBuildEscalation())
 		return false, nil
 	}
 
@@ -334,25 +362,35 @@ func (r *testReporter) postReport(jirix *jiri.X) (bool, error) {
 	}
 
 	r.reportOncall(jirix)
+	r.reportAutoRebase()
+	r.reportDependencies()
+	replacedTests := r.reportTestOverride()
+	r.reportChangeSummary()
 
 	failedTestNames := map[string]struct{}{}
-	numNewFailures := 0
+	newFailures := []failedTestCaseInfo{}
 	if failedTestNames = r.reportTestResultsSummary(jirix); len(failedTestNames) != 0 {
 		// Report failed test cases grouped by failure types.
 		var err error
-		if numNewFailures, err = r.reportFailedTestCases(jirix); err != nil {
+		if newFailures, err = r.reportFailedTestCases(jirix); err != nil {
 			return false, err
 		}
+		if escalationThresholdFlag >= 0 && len(newFailures) > escalationThresholdFlag {
+			r.escalate(jirix, r.newFailuresEscalation(newFailures))
+		}
 	}
 
+	// Failures that all match known flakes still verify the CLs, but
+	// they don't make the CLs eligible for auto-submission.
+	verified := r.reportFlakes(jirix, newFailures, failedTestNames) && !replacedTests
+
 	r.reportUsefulLinks(failedTestNames)
 
 	printf(jirix.Stdout(), "### Posting test results to Gerrit\n")
-	success := numNewFailures == 0
-	if err := postMessage(jirix, r.report.String(), r.refs, success); err != nil {
+	if err := postMessage(jirix, r.report.String(), r.refs, verified); err != nil {
 		return false, err
 	}
-	return success, nil
+	return len(newFailures) == 0, nil
 }
 
 // reportFailedPresubmitBuild reports a failed presubmit build.
@@ -387,9 +425,11 @@ func (r *testReporter) reportPresubmitFailure(jirix *jiri.X) bool {
 		message := ""
 		switch resultInfo.Result.Status {
 		case test.MergeConflict:
-			message = fmt.Sprintf(mergeConflictMessageTmpl, resultInfo.Result.MergeConflictCL)
+			message = mergeConflictMessage(resultInfo.Result.MergeConflictCL, resultInfo.Result.MergeConflictFiles)
 		case test.ToolsBuildFailure:
 			message = fmt.Sprintf(toolsBuildFailureMessageTmpl, resultInfo.Result.ToolsBuildFailureMsg)
+		case test.DependencyFailure:
+			message = resultInfo.Result.DependencyFailureMsg
 		}
 
 		if message != "" {
@@ -412,6 +452,70 @@ func (r *testReporter) reportOncall(jirix *jiri.X) {
 	}
 }
 
+// reportAutoRebase reports the CLs that were tested after a clean
+// automatic rebase.
+func (r *testReporter) reportAutoRebase() {
+	seen := map[string]bool{}
+	for _, resultInfo := range r.testResults {
+		for _, rebasedCL := range resultInfo.Result.AutoRebasedCLs {
+			if seen[rebasedCL] {
+				continue
+			}
+			seen[rebasedCL] = true
+			fmt.Fprintf(r.report, autoRebaseMessageTmpl+"\n", rebasedCL)
+		}
+	}
+	if len(seen) != 0 {
+		fmt.Fprintf(r.report, "\n")
+	}
+}
+
+// maxReportedPackages is the maximum number of changed Go packages
+// listed in the change summary.
+const maxReportedPackages = 10
+
+// reportChangeSummary populates the report with a summary of the changes
+// made by the CLs and of the tests that were selected because of them.
+func (r *testReporter) reportChangeSummary() {
+	var changes *changeSummary
+	selected, unaffected := map[string]bool{}, map[string]bool{}
+	for _, resultInfo := range r.testResults {
+		if changes == nil {
+			changes = resultInfo.Changes
+		}
+		if resultInfo.Result.Unaffected {
+			unaffected[resultInfo.TestName] = true
+		} else {
+			selected[resultInfo.TestName] = true
+		}
+	}
+	if changes == nil {
+		return
+	}
+	fmt.Fprintf(r.report, "Change summary:\n")
+	fmt.Fprintf(r.report, "- Projects changed (%d): %s\n", len(changes.Projects), strings.Join(changes.Projects, ", "))
+	if pkgs := changes.Packages; len(pkgs) > 0 {
+		more := ""
+		if len(pkgs) > maxReportedPackages {
+			more = fmt.Sprintf(" and %d more", len(pkgs)-maxReportedPackages)
+			pkgs = pkgs[:maxReportedPackages]
+		}
+		fmt.Fprintf(r.report, "- Go packages changed (%d): %s%s\n", len(changes.Packages), strings.Join(pkgs, ", "), more)
+	}
+	list := func(tests map[string]bool) string {
+		names := set.StringBool.ToSlice(tests)
+		sort.Strings(names)
+		return strings.Join(names, ", ")
+	}
+	if len(selected) > 0 {
+		fmt.Fprintf(r.report, "- Tests selected: %s\n", list(selected))
+	}
+	if len(unaffected) > 0 {
+		fmt.Fprintf(r.report, "- Tests skipped as unaffected: %s\n", list(unaffected))
+	}
+	fmt.Fprintf(r.report, "\n")
+}
+
 // reportTestResultsSummary populates the given buffer with a test
 // results summary (one transition for each test) and returns a list of
 // failed tests.
@@ -447,6 +551,8 @@ func (r *testReporter) reportTestResultsSummary(jirix *jiri.X) map[string]struct
 			}
 			summary = &testResultSummary{
 				testNameWithLabels: nameString,
+				testName:           name,
+				label:              subJobLabel,
 				timeoutValue:       -1,
 			}
 			testResultSummaries[testKey] = summary
@@ -456,6 +562,17 @@ func (r *testReporter) reportTestResultsSummary(jirix *jiri.X) map[string]struct
 		}
 	}
 
+	// Render a matrix of the tests and the labels of their sub-jobs
+	// if the tests ran in more than one configuration.
+	summaries := []*testResultSummary{}
+	for _, summary := range testResultSummaries {
+		summaries = append(summaries, summary)
+	}
+	if matrix := renderResultMatrix(summaries); matrix != "" {
+		fmt.Fprintf(r.report, "%s", matrix)
+		return failedTests
+	}
+
 	// Generate one summary line for each aggregated test.
 	nameStrings := []string{}
 	nameStringToSummaryLine := map[string]string{}
@@ -550,13 +667,14 @@ func (t failureType) String() string {
 // failedTestLinks maps from failure type to links.
 type failedTestLinksMap map[failureType][]string
 
-// reportFailedTestCasesByFailureTypes reports failed test cases grouped by
-// failure types: new failures, known failures, and fixed failures.
-func (r *testReporter) reportFailedTestCases(jirix *jiri.X) (int, error) {
+// reportFailedTestCases reports failed test cases grouped by failure
+// types: new failures, known failures, and fixed failures. It returns
+// the new failures.
+func (r *testReporter) reportFailedTestCases(jirix *jiri.X) ([]failedTestCaseInfo, error) {
 	// Get groups.
 	groups, err := r.genFailedTestCasesGroupsForAllTests(jirix)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	// Generate links for all groups.
@@ -576,8 +694,35 @@ func (r *testReporter) reportFailedTestCases(jirix *jiri.X) (int, error) {
 		}
 		fmt.Fprintf(r.report, "\n%s:\n%s\n\n", failureTypeStr, strings.Join(curLinks, "\n"))
 	}
+	r.reportBisectCommands(groups[newFailure])
 
-	return len(groups[newFailure]), nil
+	return groups[newFailure], nil
+}
+
+// reportBisectCommands suggests running "presubmit bisect" for the
+// given new failures of tests whose postsubmit build is failing as
+// well, which finds the postsubmit build that they started failing in.
+func (r *testReporter) reportBisectCommands(newFailures []failedTestCaseInfo) {
+	commands := []string{}
+	for _, c := range newFailures {
+		info := testResultInfo{TestName: c.testName, AxisValues: c.axisValues}
+		data := r.postSubmitResults[info.key()]
+		if data == nil || stringToTestStatus(data.result) != statusFail {
+			continue
+		}
+		var axes map[string]string
+		if jobInfo, ok := r.matrixJobsConf[c.testName]; ok {
+			axes = c.axisValues.AsMap(jobInfo)
+		}
+		className := c.className
+		if className == "" {
+			className = c.suiteName
+		}
+		commands = append(commands, bisectCommand(c.testName, axes, className, c.testCaseName))
+	}
+	if len(commands) > 0 {
+		fmt.Fprintf(r.report, "The postsubmit builds of these tests are failing too. To find the postsubmit builds that the new failures started in, run:\n%s\n\n", strings.Join(commands, "\n"))
+	}
 }
 
 type failedTestCaseInfo struct {
@@ -653,7 +798,7 @@ func (r *testReporter) genFailedTestCasesGroupsForOneTest(jirix *jiri.X, testRes
 	groups := failedTestCasesGroups{}
 	curFailedTestCases := []jenkins.TestCase{}
 	for _, curTestSuite := range suites.Suites {
-		for _, curTestCase := range curTestSuite.Cases {
+		for _, curTestCase := range curTestSuite.AllCases() {
 			// Unescape test name and class name.
 			curTestCase.Classname = html.UnescapeString(curTestCase.Classname)
 			curTestCase.Name = html.UnescapeString(curTestCase.Name)
@@ -747,8 +892,13 @@ func genTestFullName(className, testName string) string {
 // - Current presubmit-test master status page.
 // - Retry failed tests only.
 // - Retry current build.
+// - Archived test results.
 func (r *testReporter) reportUsefulLinks(failedTestNames map[string]struct{}) {
 	fmt.Fprintf(r.report, "\nMore details at:\n%s/?type=presubmit&n=%d\n", dashboardHostFlag, jenkinsBuildNumberFlag)
+	if r.archiveURL != "" {
+		link := strings.Replace(r.archiveURL, "gs://", "https://storage.cloud.google.com/", 1)
+		fmt.Fprintf(r.report, "\nTest results archived at:\n%s\n", link)
+	}
 	if len(failedTestNames) > 0 {
 		// Generate link to retry failed tests only.
 		names := set.String.ToSlice(failedTestNames)
@@ -799,6 +949,26 @@ func submitPresubmitCLs(jirix *jiri.X, refs []string) error {
 	return nil
 }
 
+// archiveLocation returns the Google Storage location of the test results
+// archive of the given build in the given bucket.
+func archiveLocation(bucket string, buildNumber int) string {
+	return fmt.Sprintf("%s/presubmit/%d.tar.gz", strings.TrimSuffix(bucket, "/"), buildNumber)
+}
+
+// archiveTestResults compresses the given test results directory of the
+// current build and uploads the tar file to the bucket identified by the
+// -archive-bucket flag. It returns the location of the uploaded file.
+func archiveTestResults(jirix *jiri.X, resultsDir string) (string, error) {
+	if _, err := jirix.NewSeq().Stat(resultsDir); err != nil {
+		return "", err
+	}
+	location := archiveLocation(archiveBucketFlag, jenkinsBuildNumberFlag)
+	if err := uploadTarball(jirix, resultsDir, location); err != nil {
+		return "", err
+	}
+	return location, nil
+}
+
 // processRemoteTestResults copies result files to a local tmp dir, compress
 // them, and upload the tar file.
 func processRemoteTestResults(jirix *jiri.X) error {
@@ -817,11 +987,25 @@ func processRemoteTestResults(jirix *jiri.X) error {
 		return err
 	}
 	defer os.RemoveAll(tmp)
-	tarFile := "results.tar.gz"
+	if err := s.Last("gsutil", "-m", "cp", "-r", remoteResultsPath, tmp); err != nil {
+		return err
+	}
+	resultsDir := filepath.Join(tmp, fmt.Sprintf("%d", jenkinsBuildNumberFlag))
+	return uploadTarball(jirix, resultsDir, remoteResultsPath+"/results.tar.gz")
+}
+
+// uploadTarball compresses the given directory into a tar file, whose
+// entries are relative to the parent of the directory, and uploads the
+// tar file to the given Google Storage location.
+func uploadTarball(jirix *jiri.X, dir, location string) error {
+	s := jirix.NewSeq()
+	tmp, err := s.TempDir("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	tarFile := filepath.Join(tmp, "results.tar.gz")
 	return s.
-		MkdirAll(tmp, 0755).
-		Chdir(tmp).
-		Run("gsutil", "-m", "cp", "-r", remoteResultsPath, ".").
-		Run("tar", "-zcf", tarFile, fmt.Sprintf("%d", jenkinsBuildNumberFlag)).
-		Last("gsutil", "cp", tarFile, remoteResultsPath)
+		Run("tar", "-C", filepath.Dir(dir), "-zcf", tarFile, filepath.Base(dir)).
+		Last("gsutil", "-q", "cp", tarFile, location)
 }
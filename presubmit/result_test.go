@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -190,3 +191,93 @@ func TestGenTestFullName(t *testing.T) {
 		}
 	}
 }
+
+func TestReportAutoRebase(t *testing.T) {
+	reporter := testReporter{
+		testResults: []testResultInfo{
+			testResultInfo{
+				TestName: "vanadium-go-test",
+				Result:   test.Result{AutoRebasedCLs: []string{"http://go/vcl/1000/2"}},
+			},
+			testResultInfo{
+				TestName: "vanadium-go-race",
+				Result:   test.Result{AutoRebasedCLs: []string{"http://go/vcl/1000/2"}},
+			},
+			testResultInfo{
+				TestName: "vanadium-js-unit",
+			},
+		},
+		report: &bytes.Buffer{},
+	}
+	reporter.reportAutoRebase()
+	want := "Note: http://go/vcl/1000/2 could not be merged and was tested after clean auto-rebase onto master.\n\n"
+	if got := reporter.report.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	reporter.testResults = reporter.testResults[2:]
+	reporter.report.Reset()
+	reporter.reportAutoRebase()
+	if got := reporter.report.String(); got != "" {
+		t.Fatalf("want empty report, got %q", got)
+	}
+}
+
+func TestReportChangeSummary(t *testing.T) {
+	changes := &changeSummary{
+		Projects: []string{"release.go.core", "release.go.x.devtools"},
+		Packages: []string{"v.io/x/devtools/presubmit", "v.io/x/ref/lib/flags"},
+	}
+	reporter := testReporter{
+		testResults: []testResultInfo{
+			testResultInfo{TestName: "vanadium-go-test", Changes: changes},
+			testResultInfo{TestName: "vanadium-go-build", Changes: changes},
+			testResultInfo{TestName: "vanadium-go-race", Changes: changes, Result: test.Result{Status: test.Skipped, Unaffected: true}},
+		},
+		report: &bytes.Buffer{},
+	}
+	reporter.reportChangeSummary()
+	want := `Change summary:
+- Projects changed (2): release.go.core, release.go.x.devtools
+- Go packages changed (2): v.io/x/devtools/presubmit, v.io/x/ref/lib/flags
+- Tests selected: vanadium-go-build, vanadium-go-test
+- Tests skipped as unaffected: vanadium-go-race
+
+`
+	if got := reporter.report.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	// Results recorded without a change summary are not reported.
+	for i := range reporter.testResults {
+		reporter.testResults[i].Changes = nil
+	}
+	reporter.report.Reset()
+	reporter.reportChangeSummary()
+	if got := reporter.report.String(); got != "" {
+		t.Fatalf("want empty report, got %q", got)
+	}
+}
+
+func TestReportArchiveURL(t *testing.T) {
+	defer func(host string, n int) {
+		dashboardHostFlag, jenkinsBuildNumberFlag = host, n
+	}(dashboardHostFlag, jenkinsBuildNumberFlag)
+	dashboardHostFlag, jenkinsBuildNumberFlag = "https://dashboard.v.io", 45
+
+	reporter := testReporter{
+		archiveURL: archiveLocation("gs://vanadium-presubmit-archive/", jenkinsBuildNumberFlag),
+		report:     &bytes.Buffer{},
+	}
+	reporter.reportUsefulLinks(nil)
+	want := `
+More details at:
+https://dashboard.v.io/?type=presubmit&n=45
+
+Test results archived at:
+https://storage.cloud.google.com/vanadium-presubmit-archive/presubmit/45.tar.gz
+`
+	if got := reporter.report.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// noLabelColumn is the header of the matrix column of the tests that
+// are not multi-configuration jobs.
+const noLabelColumn = "-"
+
+// renderResultMatrix renders the given test summaries as a matrix whose
+// rows are the tests and whose columns are the labels of their
+// sub-jobs, such as "linux,amd64", with the status of the postsubmit
+// build and the current status of each sub-job in the cells, in the
+// same "<last> ➔ <current>" format as the summary lines. Each line is indented, so that Gerrit renders
+// the matrix in a fixed-width font. The timed-out sub-jobs are listed
+// after the matrix. It returns an empty string if the summaries have
+// fewer than two distinct labels, in which case the summaries are
+// better reported one per line.
+func renderResultMatrix(summaries []*testResultSummary) string {
+	labels, tests := map[string]bool{}, map[string]bool{}
+	cells := map[string]*testResultSummary{}
+	for _, summary := range summaries {
+		label := summary.label
+		if label == "" {
+			label = noLabelColumn
+		}
+		labels[label] = true
+		tests[summary.testName] = true
+		cells[summary.testName+"\x00"+label] = summary
+	}
+	if len(labels) < 2 {
+		return ""
+	}
+	sortedLabels, sortedTests := []string{}, []string{}
+	for label := range labels {
+		sortedLabels = append(sortedLabels, label)
+	}
+	for test := range tests {
+		sortedTests = append(sortedTests, test)
+	}
+	sort.Strings(sortedLabels)
+	sort.Strings(sortedTests)
+
+	rows := [][]string{append([]string{"test"}, sortedLabels...)}
+	timedOut := []string{}
+	for _, test := range sortedTests {
+		row := []string{test}
+		for _, label := range sortedLabels {
+			summary, ok := cells[test+"\x00"+label]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, fmt.Sprintf("%s ➔ %s", summary.lastStatus.String(), summary.curStatus.String()))
+			if summary.timeoutValue > 0 {
+				timedOut = append(timedOut, fmt.Sprintf("%s [TIMED OUT after %s]", summary.testNameWithLabels, summary.timeoutValue))
+			}
+		}
+		rows = append(rows, row)
+	}
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %s\t\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	var buf bytes.Buffer
+	for _, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		// Drop the padding of the trailing cells.
+		fmt.Fprintf(&buf, "%s\n", strings.TrimRight(line, " "))
+	}
+	for _, line := range timedOut {
+		fmt.Fprintf(&buf, "%s\n", line)
+	}
+	return buf.String()
+}
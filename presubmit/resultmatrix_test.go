@@ -0,0 +1,36 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderResultMatrix(t *testing.T) {
+	summaries := []*testResultSummary{
+		{testNameWithLabels: "vanadium-go-test [linux,amd64]", testName: "vanadium-go-test", label: "linux,amd64", lastStatus: statusFail, curStatus: statusSuccess},
+		{testNameWithLabels: "vanadium-go-test [mac,amd64]", testName: "vanadium-go-test", label: "mac,amd64", lastStatus: statusSuccess, curStatus: statusFail},
+		{testNameWithLabels: "vanadium-go-race [linux,amd64]", testName: "vanadium-go-race", label: "linux,amd64", lastStatus: statusUnknown, curStatus: statusFail, timeoutValue: 20 * time.Minute},
+		{testNameWithLabels: "vanadium-go-build", testName: "vanadium-go-build", lastStatus: statusSuccess, curStatus: statusUnknown},
+	}
+	want := `  test               -      linux,amd64  mac,amd64
+  vanadium-go-build  ✔ ➔ ?
+  vanadium-go-race          ? ➔ ✖
+  vanadium-go-test          ✖ ➔ ✔        ✔ ➔ ✖
+vanadium-go-race [linux,amd64] [TIMED OUT after 20m0s]
+`
+	if got := renderResultMatrix(summaries); got != want {
+		t.Fatalf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	// A single label is reported one test per line instead.
+	if got := renderResultMatrix(summaries[:1]); got != "" {
+		t.Fatalf("want no matrix, got:\n%s", got)
+	}
+	if got := renderResultMatrix(summaries[3:]); got != "" {
+		t.Fatalf("want no matrix, got:\n%s", got)
+	}
+}
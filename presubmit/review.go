@@ -0,0 +1,429 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"v.io/jiri"
+	"v.io/jiri/collect"
+	"v.io/jiri/gerrit"
+	"v.io/jiri/project"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/devtools/tooldata"
+)
+
+const (
+	githubAPIURL = "https://api.github.com"
+	// githubPageSize is the number of items requested per page from the
+	// GitHub API, which is the maximum it allows.
+	githubPageSize = 100
+	// githubRefPrefix prefixes the refs that identify GitHub pull
+	// requests, which have the github/<owner>/<name>/<number>/<sha>
+	// format.
+	githubRefPrefix = "github/"
+	// githubStatusContext identifies the statuses set by presubmit on
+	// the commits of pull requests.
+	githubStatusContext = "vanadium-presubmit"
+	// githubTokenEnvVar names the environment variable that holds the
+	// OAuth token used to access the GitHub API.
+	githubTokenEnvVar = "GITHUB_TOKEN"
+	// gerritResponsePrefix prefixes the JSON responses of the Gerrit
+	// REST API to prevent cross-site script inclusion.
+	gerritResponsePrefix = ")]}'"
+)
+
+// reviewChange identifies an open change of a code review system.
+type reviewChange struct {
+	ref     string
+	project string
+	// trusted records whether the author of the change is allowed to
+	// trigger presubmit tests automatically.
+	trusted bool
+}
+
+// reviewBackend abstracts the interaction of presubmit with the code
+// review system that changes are sent to.
+type reviewBackend interface {
+	// openChanges returns the open changes.
+	openChanges(jirix *jiri.X) ([]reviewChange, error)
+	// pullSource returns the remote and the refspec to pull the change
+	// identified by the given ref from into the given local project.
+	pullSource(localProject project.Project, ref string) (string, string, error)
+	// changedFiles returns the paths, relative to the root of the
+	// project, of the files modified by the change identified by the
+	// given ref.
+	changedFiles(jirix *jiri.X, ref string) ([]string, error)
+	// comments returns the review comments, ordered from the oldest to
+	// the newest, and the hashtags or labels of the change identified by
+	// the given ref.
+	comments(jirix *jiri.X, ref string) ([]string, []string, error)
+	// dependencies returns the changes that the change identified by the
+	// given ref depends on, ordered from the oldest ancestor to the
+	// parent of the change.
+	dependencies(jirix *jiri.X, ref string) ([]changeDependency, error)
+	// postResult posts the given message to the changes identified by
+	// the given refs and marks them as verified or not.
+	postResult(jirix *jiri.X, message string, refs []string, success bool) error
+}
+
+// backendForProject returns the review backend of the given project
+// settings from the tools config.
+func backendForProject(settings tooldata.ReviewBackendSettings) (reviewBackend, error) {
+	switch settings.Type {
+	case "", tooldata.ReviewBackendGerrit:
+		return gerritBackend{}, nil
+	case tooldata.ReviewBackendGitHub:
+		if settings.Repo == "" {
+			return nil, fmt.Errorf("no GitHub repository configured for project %q", settings.Project)
+		}
+		return githubBackend{apiURL: githubAPIURL, project: settings.Project, repo: settings.Repo}, nil
+	default:
+		return nil, fmt.Errorf("unknown review backend %q of project %q", settings.Type, settings.Project)
+	}
+}
+
+// backendForRef returns the review backend of the change identified by
+// the given ref.
+func backendForRef(ref string) reviewBackend {
+	if repo, _, _, err := parseGitHubRef(ref); err == nil {
+		return githubBackend{apiURL: githubAPIURL, repo: repo}
+	}
+	return gerritBackend{}
+}
+
+// gerritBackend implements reviewBackend for Gerrit.
+type gerritBackend struct{}
+
+func (gerritBackend) openChanges(jirix *jiri.X) ([]reviewChange, error) {
+	gUrl, err := gerritBaseUrl()
+	if err != nil {
+		return nil, err
+	}
+	cls, err := jirix.Gerrit(gUrl).Query(defaultQueryString)
+	if err != nil {
+		return nil, err
+	}
+	changes := []reviewChange{}
+	for _, cl := range cls {
+		changes = append(changes, reviewChange{
+			ref:     cl.Reference(),
+			project: cl.Project,
+			trusted: checkEmailAddress(cl.OwnerEmail()),
+		})
+	}
+	return changes, nil
+}
+
+func (gerritBackend) pullSource(localProject project.Project, ref string) (string, string, error) {
+	return localProject.Remote, ref, nil
+}
+
+func (gerritBackend) changedFiles(jirix *jiri.X, ref string) ([]string, error) {
+	clNumber, _, err := gerrit.ParseRefString(ref)
+	if err != nil {
+		return nil, err
+	}
+	gUrl, err := gerritBaseUrl()
+	if err != nil {
+		return nil, err
+	}
+	results, err := jirix.Gerrit(gUrl).Query(fmt.Sprintf("change:%d", clNumber))
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, result := range results {
+		for _, revision := range result.Revisions {
+			for filename := range revision.Files {
+				files = append(files, filename)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (gerritBackend) comments(jirix *jiri.X, ref string) (_ []string, _ []string, e error) {
+	clNumber, _, err := gerrit.ParseRefString(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	gUrl, err := gerritBaseUrl()
+	if err != nil {
+		return nil, nil, err
+	}
+	changeUrl := fmt.Sprintf("%s/changes/%d?o=MESSAGES", strings.TrimSuffix(gUrl.String(), "/"), clNumber)
+	res, err := http.Get(changeUrl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Get(%v) failed: %v", changeUrl, err)
+	}
+	defer collect.Error(res.Body.Close, &e)
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("Get(%v) failed: %v", changeUrl, res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ReadAll() failed: %v", err)
+	}
+	return parseGerritChangeInfo(data)
+}
+
+// parseGerritChangeInfo parses the given ChangeInfo entity returned by
+// the Gerrit REST API into the messages and the hashtags of the change.
+func parseGerritChangeInfo(data []byte) ([]string, []string, error) {
+	data = bytes.TrimPrefix(data, []byte(gerritResponsePrefix))
+	var change struct {
+		Messages []struct {
+			Message string `json:"message"`
+		} `json:"messages"`
+		Hashtags []string `json:"hashtags"`
+	}
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(data), err)
+	}
+	messages := []string{}
+	for _, message := range change.Messages {
+		messages = append(messages, message.Message)
+	}
+	return messages, change.Hashtags, nil
+}
+
+func (gerritBackend) postResult(jirix *jiri.X, message string, refs []string, success bool) error {
+	refsUsingVerifiedLabel, err := getRefsUsingVerifiedLabel(jirix)
+	if err != nil {
+		return err
+	}
+	value := "1"
+	if !success {
+		value = "-" + value
+	}
+	for _, ref := range refs {
+		labels := map[string]string{}
+		if _, ok := refsUsingVerifiedLabel[ref]; ok {
+			labels["Verified"] = value
+		}
+		gUrl, err := gerritBaseUrl()
+		if err != nil {
+			return err
+		}
+		if err := jirix.Gerrit(gUrl).PostReview(ref, message, labels); err != nil {
+			return err
+		}
+		test.Pass(jirix.Context, "review posted for %q with labels %v.\n", ref, labels)
+	}
+	return nil
+}
+
+// githubBackend implements reviewBackend for the pull requests of a
+// GitHub repository, using the statuses of their commits to mark them
+// as verified or not.
+type githubBackend struct {
+	apiURL  string
+	project string
+	// repo identifies the repository in the <owner>/<name> format.
+	repo string
+}
+
+// githubRef returns the ref that identifies the given commit of the
+// given pull request.
+func githubRef(repo string, number int, sha string) string {
+	return fmt.Sprintf("%s%s/%d/%s", githubRefPrefix, repo, number, sha)
+}
+
+// parseGitHubRef parses the given ref of a GitHub pull request into the
+// repository, the number of the pull request and the commit.
+func parseGitHubRef(ref string) (string, int, string, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, githubRefPrefix), "/")
+	if !strings.HasPrefix(ref, githubRefPrefix) || len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[3] == "" {
+		return "", 0, "", fmt.Errorf("invalid GitHub ref %q", ref)
+	}
+	number, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("Atoi(%v) failed: %v", parts[2], err)
+	}
+	return parts[0] + "/" + parts[1], number, parts[3], nil
+}
+
+// githubStatus returns the commit status that marks a pull request as
+// verified or not.
+func githubStatus(success bool) map[string]string {
+	status := map[string]string{
+		"context":     githubStatusContext,
+		"description": "Presubmit tests passed.",
+		"state":       "success",
+	}
+	if !success {
+		status["description"] = "Presubmit tests failed."
+		status["state"] = "failure"
+	}
+	return status
+}
+
+// isTrustedAuthor returns whether the given association of the author
+// of a pull request with the repository allows the pull request to
+// trigger presubmit tests automatically.
+func isTrustedAuthor(association string) bool {
+	switch association {
+	case "COLLABORATOR", "MEMBER", "OWNER":
+		return true
+	}
+	return false
+}
+
+// request sends a request with the given method and JSON body to the
+// given path of the GitHub API and decodes the JSON response into the
+// given result, unless it is nil.
+func (b githubBackend) request(jirix *jiri.X, method, path string, body, result interface{}) (e error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Marshal(%v) failed: %v", body, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, b.apiURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("NewRequest(%v, %v) failed: %v", method, path, err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token := jirix.Env()[githubTokenEnvVar]; token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Do(%v %v) failed: %v", method, path, err)
+	}
+	defer collect.Error(res.Body.Close, &e)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%v %v failed: %v", method, path, res.Status)
+	}
+	if result != nil {
+		if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+			return fmt.Errorf("Decode() failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func (b githubBackend) openChanges(jirix *jiri.X) ([]reviewChange, error) {
+	changes := []reviewChange{}
+	for page := 1; ; page++ {
+		var pulls []struct {
+			Number            int    `json:"number"`
+			AuthorAssociation string `json:"author_association"`
+			Head              struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls?state=open&per_page=%d&page=%d", b.repo, githubPageSize, page)
+		if err := b.request(jirix, "GET", path, nil, &pulls); err != nil {
+			return nil, err
+		}
+		for _, pull := range pulls {
+			changes = append(changes, reviewChange{
+				ref:     githubRef(b.repo, pull.Number, pull.Head.SHA),
+				project: b.project,
+				trusted: isTrustedAuthor(pull.AuthorAssociation),
+			})
+		}
+		if len(pulls) < githubPageSize {
+			return changes, nil
+		}
+	}
+}
+
+func (b githubBackend) pullSource(localProject project.Project, ref string) (string, string, error) {
+	repo, number, _, err := parseGitHubRef(ref)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("https://github.com/%s.git", repo), fmt.Sprintf("refs/pull/%d/head", number), nil
+}
+
+func (b githubBackend) changedFiles(jirix *jiri.X, ref string) ([]string, error) {
+	_, number, _, err := parseGitHubRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for page := 1; ; page++ {
+		var pullFiles []struct {
+			Filename string `json:"filename"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=%d&page=%d", b.repo, number, githubPageSize, page)
+		if err := b.request(jirix, "GET", path, nil, &pullFiles); err != nil {
+			return nil, err
+		}
+		for _, file := range pullFiles {
+			files = append(files, file.Filename)
+		}
+		if len(pullFiles) < githubPageSize {
+			return files, nil
+		}
+	}
+}
+
+func (b githubBackend) comments(jirix *jiri.X, ref string) ([]string, []string, error) {
+	_, number, _, err := parseGitHubRef(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	comments := []string{}
+	for page := 1; ; page++ {
+		var issueComments []struct {
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=%d&page=%d", b.repo, number, githubPageSize, page)
+		if err := b.request(jirix, "GET", path, nil, &issueComments); err != nil {
+			return nil, nil, err
+		}
+		for _, comment := range issueComments {
+			comments = append(comments, comment.Body)
+		}
+		if len(issueComments) < githubPageSize {
+			break
+		}
+	}
+	var issue struct {
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := b.request(jirix, "GET", fmt.Sprintf("/repos/%s/issues/%d", b.repo, number), nil, &issue); err != nil {
+		return nil, nil, err
+	}
+	labels := []string{}
+	for _, label := range issue.Labels {
+		labels = append(labels, label.Name)
+	}
+	return comments, labels, nil
+}
+
+func (b githubBackend) postResult(jirix *jiri.X, message string, refs []string, success bool) error {
+	for _, ref := range refs {
+		_, number, sha, err := parseGitHubRef(ref)
+		if err != nil {
+			return err
+		}
+		comment := map[string]string{"body": message}
+		if err := b.request(jirix, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", b.repo, number), comment, nil); err != nil {
+			return err
+		}
+		status := githubStatus(success)
+		if err := b.request(jirix, "POST", fmt.Sprintf("/repos/%s/statuses/%s", b.repo, sha), status, nil); err != nil {
+			return err
+		}
+		test.Pass(jirix.Context, "review posted for %q with status %q.\n", ref, status["state"])
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"v.io/jiri/jiritest"
+	"v.io/jiri/project"
+	"v.io/x/devtools/tooldata"
+)
+
+func TestGitHubRef(t *testing.T) {
+	ref := githubRef("vanadium/go.jiri", 12, "abc123")
+	if got, want := ref, "github/vanadium/go.jiri/12/abc123"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	repo, number, sha, err := parseGitHubRef(ref)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if repo != "vanadium/go.jiri" || number != 12 || sha != "abc123" {
+		t.Fatalf("unexpected result: %v %v %v", repo, number, sha)
+	}
+	for _, invalid := range []string{"refs/changes/10/1000/1", "github/vanadium/12/abc123", "github/vanadium/go.jiri/x/abc123"} {
+		if _, _, _, err := parseGitHubRef(invalid); err == nil {
+			t.Errorf("parsing %q did not fail", invalid)
+		}
+	}
+	if _, ok := backendForRef(ref).(githubBackend); !ok {
+		t.Errorf("backend of %q is not GitHub", ref)
+	}
+	if _, ok := backendForRef("refs/changes/10/1000/1").(gerritBackend); !ok {
+		t.Errorf("backend of a Gerrit ref is not Gerrit")
+	}
+	if got, want := (cl{ref: ref, clNumber: 12}).String(), "https://github.com/vanadium/go.jiri/pull/12"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if _, err := backendForProject(tooldata.ReviewBackendSettings{Project: "go.jiri", Type: tooldata.ReviewBackendGitHub}); err == nil {
+		t.Errorf("creating a GitHub backend without a repository did not fail")
+	}
+
+	remote, refspec, err := backendForRef(ref).pullSource(project.Project{Remote: "https://vanadium.googlesource.com/release.go.jiri"}, ref)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := remote, "https://github.com/vanadium/go.jiri.git"; got != want {
+		t.Errorf("want remote %q, got %q", want, got)
+	}
+	if got, want := refspec, "refs/pull/12/head"; got != want {
+		t.Errorf("want refspec %q, got %q", want, got)
+	}
+}
+
+func TestGitHubBackend(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	requests := []string{}
+	bodies := []map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `[{"number": 3, "author_association": "MEMBER", "head": {"sha": "abc"}},
+				{"number": 4, "author_association": "NONE", "head": {"sha": "def"}}]`)
+		case "POST":
+			body := map[string]string{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Decode() failed: %v", err)
+			}
+			bodies = append(bodies, body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	backend := githubBackend{apiURL: server.URL, project: "go.jiri", repo: "vanadium/go.jiri"}
+	changes, err := backend.openChanges(fake.X)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wantChanges := []reviewChange{
+		{ref: "github/vanadium/go.jiri/3/abc", project: "go.jiri", trusted: true},
+		{ref: "github/vanadium/go.jiri/4/def", project: "go.jiri", trusted: false},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Fatalf("want %v, got %v", wantChanges, changes)
+	}
+	if got := newReviewChanges(map[string]struct{}{wantChanges[0].ref: struct{}{}}, changes); !reflect.DeepEqual(got, wantChanges[1:]) {
+		t.Fatalf("want %v, got %v", wantChanges[1:], got)
+	}
+
+	if err := backend.postResult(fake.X, "Presubmit tests failed.\n", []string{changes[0].ref}, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wantRequests := []string{
+		"GET /repos/vanadium/go.jiri/pulls?state=open&per_page=100&page=1",
+		"POST /repos/vanadium/go.jiri/issues/3/comments",
+		"POST /repos/vanadium/go.jiri/statuses/abc",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Fatalf("want %v, got %v", wantRequests, requests)
+	}
+	wantBodies := []map[string]string{
+		{"body": "Presubmit tests failed.\n"},
+		{"context": githubStatusContext, "description": "Presubmit tests failed.", "state": "failure"},
+	}
+	if !reflect.DeepEqual(bodies, wantBodies) {
+		t.Fatalf("want %v, got %v", wantBodies, bodies)
+	}
+}
+
+func TestGitHubComments(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		switch r.URL.Path {
+		case "/repos/vanadium/go.jiri/issues/3/comments":
+			fmt.Fprint(w, `[{"body": "Looks good."}, {"body": "PresubmitTest: vanadium-go-race"}]`)
+		case "/repos/vanadium/go.jiri/issues/3":
+			fmt.Fprint(w, `{"number": 3, "labels": [{"name": "bug"}, {"name": "PresubmitTest:+vanadium-go-test"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	backend := githubBackend{apiURL: server.URL, project: "go.jiri", repo: "vanadium/go.jiri"}
+	comments, labels, err := backend.comments(fake.X, "github/vanadium/go.jiri/3/abc")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{"Looks good.", "PresubmitTest: vanadium-go-race"}; !reflect.DeepEqual(comments, want) {
+		t.Fatalf("want %v, got %v", want, comments)
+	}
+	if want := []string{"bug", "PresubmitTest:+vanadium-go-test"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("want %v, got %v", want, labels)
+	}
+	wantRequests := []string{
+		"GET /repos/vanadium/go.jiri/issues/3/comments?per_page=100&page=1",
+		"GET /repos/vanadium/go.jiri/issues/3",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Fatalf("want %v, got %v", wantRequests, requests)
+	}
+}
+
+func TestParseGerritChangeInfo(t *testing.T) {
+	data := `)]}'
+{
+  "id": "release.go.core~master~I1234",
+  "hashtags": ["PresubmitTest:vanadium-go-race"],
+  "messages": [
+    {"id": "1", "message": "Uploaded patch set 1."},
+    {"id": "2", "message": "Patch Set 1:\n\nPresubmitTest: +vanadium-integration-test"}
+  ]
+}`
+	messages, hashtags, err := parseGerritChangeInfo([]byte(data))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{"Uploaded patch set 1.", "Patch Set 1:\n\nPresubmitTest: +vanadium-integration-test"}; !reflect.DeepEqual(messages, want) {
+		t.Fatalf("want %v, got %v", want, messages)
+	}
+	if want := []string{"PresubmitTest:vanadium-go-race"}; !reflect.DeepEqual(hashtags, want) {
+		t.Fatalf("want %v, got %v", want, hashtags)
+	}
+	if _, _, err := parseGerritChangeInfo([]byte(")]}'\nnot json")); err == nil {
+		t.Fatalf("parsing invalid data did not fail")
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -28,8 +29,10 @@ import (
 	"v.io/jiri/project"
 	"v.io/jiri/runutil"
 	"v.io/jiri/tool"
+	"v.io/x/devtools/internal/goutil"
 	"v.io/x/devtools/internal/test"
 	"v.io/x/devtools/internal/xunit"
+	"v.io/x/devtools/tooldata"
 	"v.io/x/lib/cmdline"
 )
 
@@ -46,10 +49,18 @@ const (
 )
 
 var (
+	autoRebaseFlag       bool
 	numWorkersFlag       int
 	reviewTargetRefsFlag string
+	skipUnaffectedFlag   bool
 	testFlag             string
 	testPartRE           = regexp.MustCompile(`(.*)-part(\d)$`)
+	// mergeConflictREs match the lines of "git pull" output that
+	// identify conflicting files.
+	mergeConflictREs = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^CONFLICT \([^)]*\): Merge conflict in (.+)$`),
+		regexp.MustCompile(`(?m)^CONFLICT \((?:modify|rename)/delete\): (.+?) deleted in `),
+	}
 
 	// The variables below are used for testing presubmit only.
 	testMode                 = false
@@ -58,11 +69,13 @@ var (
 )
 
 func init() {
+	cmdTest.Flags.BoolVar(&autoRebaseFlag, "auto-rebase", false, "Attempt to automatically rebase CLs that cannot be merged onto master, and test the rebased CLs if the rebase is clean.")
 	cmdTest.Flags.IntVar(&jenkinsBuildNumberFlag, "build-number", -1, "The number of the Jenkins build.")
 	cmdTest.Flags.IntVar(&numWorkersFlag, "num-test-workers", runtime.NumCPU(), "Set the number of test workers to use when running sub-tests.")
 	cmdTest.Flags.Lookup("num-test-workers").DefValue = "<runtime.NumCPU()>"
 	cmdTest.Flags.StringVar(&projectsFlag, "projects", "", "The base names of the remote projects containing the CLs pointed by the refs, separated by ':'.")
 	cmdTest.Flags.StringVar(&reviewTargetRefsFlag, "refs", "", "The review references separated by ':'.")
+	cmdTest.Flags.BoolVar(&skipUnaffectedFlag, "skip-unaffected", false, "Skip the Go tests whose packages do not depend on any of the files changed by the CLs.")
 	cmdTest.Flags.StringVar(&testFlag, "test", "", "The name of a single test to run.")
 
 	tool.InitializeProjectFlags(&cmdTest.Flags)
@@ -75,6 +88,12 @@ var cmdTest = &cmdline.Command{
 	Long: `
 This subcommand pulls the open CLs from Gerrit, runs tests specified in a config
 file, and posts test results back to the corresponding Gerrit review thread.
+
+The open changes that a CL depends on, such as the ancestors of a CL in a stack
+of Gerrit changes, are pulled before the CL, from the oldest ancestor to the
+parent of the CL, and are listed in the report. If a CL depends on a change
+that is already merged or abandoned, the CL is not tested, and a message that
+explains why is posted instead.
 `,
 	Runner: jiri.RunnerFunc(runTest),
 }
@@ -94,6 +113,9 @@ type cl struct {
 }
 
 func (c cl) String() string {
+	if repo, number, _, err := parseGitHubRef(c.ref); err == nil {
+		return fmt.Sprintf("https://github.com/%s/pull/%d", repo, number)
+	}
 	return fmt.Sprintf("http://go/vcl/%d/%d", c.clNumber, c.patchset)
 }
 
@@ -111,8 +133,9 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	}
 
 	// Warn users that presubmit will delete all non-master branches when
-	// running on their local machines.
-	if !testMode && os.Getenv("USER") != "veyron" {
+	// running on their local machines, unless the CLs are tested in an
+	// isolated workspace.
+	if !testMode && !isolateFlag && os.Getenv("USER") != "veyron" {
 		fmt.Printf("WARNING: Presubmit will delete all non-master branches.\nContinue? y/N:")
 		var response string
 		if _, err := fmt.Scanf("%s\n", &response); err != nil || response != "y" {
@@ -136,12 +159,24 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	}
 
 	// tmpBinDir is where developer tools are built after changes are
-	// pulled from the target CLs.
+	// pulled from the target CLs. With -isolate, it is in the isolated
+	// workspace instead.
 	tmpBinDir := filepath.Join(jirix.Root, "tmpBin")
 
+	// With -isolate, the CLs are tested in an isolated workspace whose
+	// projects are used instead of the projects of the shared checkout.
+	var workspace *isolatedWorkspace
+	testProjects := projects
+
 	// Setup cleanup function for cleaning up presubmit test branch.
 	cleanupFn := func() error {
 		os.RemoveAll(tmpBinDir)
+		if isolateFlag {
+			if workspace == nil {
+				return nil
+			}
+			return workspace.remove(jirix)
+		}
 		return cleanupAllPresubmitTestBranches(jirix, projects)
 	}
 	defer collect.Error(func() error { return cleanupFn() }, &e)
@@ -167,15 +202,45 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	if err != nil {
 		return err
 	}
+	logger := test.NewLogger(jirix.Context).WithTest(testName)
+
+	// Add the open changes the CLs depend on, so that stacks of CLs are
+	// tested as a whole rather than only their tip.
+	var dependencyCLs []cl
+	if !testMode {
+		if cls, dependencyCLs, err = addDependencies(jirix, cls); err != nil {
+			depErr, ok := err.(dependencyError)
+			if !ok {
+				return err
+			}
+			result := test.Result{
+				Status:               test.DependencyFailure,
+				DependencyFailureMsg: depErr.Error(),
+			}
+			if err := recordPresubmitFailure(jirix, "Dependencies", "Unusable dependency", depErr.Error(), testName, -1, result); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
 
 	// Prepare presubmit test branch.
+	var rebasedCLs []cl
 	for i := 1; i <= prepareTestBranchAttempts; i++ {
-		if failedCL, err := preparePresubmitTestBranch(jirix, cls, projects); err != nil {
+		var failedCL *cl
+		if isolateFlag {
+			if workspace, err = newIsolatedWorkspace(jirix, cls, projects); err != nil {
+				return err
+			}
+			testProjects = workspace.projects
+			tmpBinDir = filepath.Join(workspace.root, "tmpBin")
+		}
+		if rebasedCLs, failedCL, err = preparePresubmitTestBranch(jirix, cls, testProjects); err != nil {
 			if i > 1 {
-				fmt.Fprintf(jirix.Stdout(), "Attempt #%d:\n", i)
+				logger.Infof("Attempt #%d:\n", i)
 			}
 			if failedCL != nil {
-				fmt.Fprintf(jirix.Stderr(), "%s: %v\n", failedCL.String(), err)
+				logger.Errorf("%s: %v\n", failedCL.String(), err)
 			}
 			errMsg := err.Error()
 			if strings.Contains(errMsg, "unable to access") {
@@ -188,10 +253,12 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 			}
 			if strings.Contains(errMsg, "git pull") {
 				// Possible merge conflict.
-				message := fmt.Sprintf(mergeConflictMessageTmpl, failedCL.String())
+				files := mergeConflictFiles(errMsg)
+				message := mergeConflictMessage(failedCL.String(), files)
 				result := test.Result{
-					Status:          test.MergeConflict,
-					MergeConflictCL: failedCL.String(),
+					Status:             test.MergeConflict,
+					MergeConflictCL:    failedCL.String(),
+					MergeConflictFiles: files,
 				}
 				if err := recordPresubmitFailure(jirix, "MergeConflict", "Merge conflict detected", message, testName, -1, result); err != nil {
 					return err
@@ -203,11 +270,27 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 		break
 	}
 
+	// Look up the files changed by the CLs, which are summarized in the
+	// test report and, with -skip-unaffected, used to skip the tests the
+	// changes cannot affect.
+	var files []string
+	if !testMode {
+		if files, err = changedFiles(jirix, cls, testProjects); err != nil {
+			// Run all tests if the changed files cannot be determined.
+			logger.Warnf("%v\n", err)
+		}
+	}
+	changes, err := summarizeChanges(jirix, cls, files)
+	if err != nil {
+		// The summary is informational only.
+		logger.Warnf("%v\n", err)
+	}
+
 	// Rebuild developer tools and override PATH to point there.
 	env := map[string]string{}
 	if !testMode {
 		var err error
-		env, err = rebuildDeveloperTools(jirix, tools, projects, tmpBinDir)
+		env, err = rebuildDeveloperTools(jirix, tools, testProjects, tmpBinDir)
 		if err != nil {
 			message := fmt.Sprintf(toolsBuildFailureMessageTmpl, err.Error())
 			result := test.Result{
@@ -217,10 +300,15 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 			if err := recordPresubmitFailure(jirix, "BuildTools", "Failed to build tools", message, testName, -1, result); err != nil {
 				return err
 			}
-			fmt.Fprintf(jirix.Stderr(), "failed to build tools:\n%s\n", err.Error())
+			logger.Errorf("failed to build tools:\n%s\n", err.Error())
 			return nil
 		}
 	}
+	if workspace != nil {
+		for key, value := range workspace.env() {
+			env[key] = value
+		}
+	}
 
 	// Run the tests via "jiri test run" and collect the test results.
 	printf(jirix.Stdout(), "### Running the presubmit test\n")
@@ -231,11 +319,13 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	}
 	defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(outputDir).Done() }, &e)
 
-	jiriArgs := []string{
+	// The log flags are passed on, so that the log messages of jiri-test
+	// match those of presubmit.
+	jiriArgs := append(test.LogFlags(),
 		"run",
 		"-output-dir", outputDir,
 		"-num-test-workers", fmt.Sprintf("%d", numWorkersFlag),
-	}
+	)
 	if testMode {
 		jiriArgs = append(jiriArgs,
 			"-mock-file-paths", testFilePaths, "-mock-file-contents", testFileExpectedContents)
@@ -243,6 +333,9 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	if partIndex != -1 {
 		jiriArgs = append(jiriArgs, "-part", fmt.Sprintf("%d", partIndex))
 	}
+	if skipUnaffectedFlag && files != nil {
+		jiriArgs = append(jiriArgs, "-changed-files", strings.Join(files, ","))
+	}
 	jiriArgs = append(jiriArgs, testName)
 
 	var out bytes.Buffer
@@ -254,11 +347,11 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 		// Clean up profiles if any of the CLs modified profile related files.
 		profilesModified, pmErr := profileFilesModified(jirix, cls)
 		if pmErr != nil {
-			fmt.Fprintf(jirix.Stderr(), "%v\n", pmErr)
+			logger.Errorf("%v\n", pmErr)
 		}
 		if (pmErr == nil && profilesModified) || pmErr != nil {
 			if err := cleanupProfiles(jirix, env, cls); err != nil {
-				fmt.Fprintf(jirix.Stderr(), "%v\n", err)
+				logger.Errorf("%v\n", err)
 			}
 		}
 		// jiri-test command times out.
@@ -300,48 +393,97 @@ func runTest(jirix *jiri.X, args []string) (e error) {
 	if !ok {
 		return fmt.Errorf("no test result found for %q", testName)
 	}
+	for _, rebasedCL := range rebasedCLs {
+		result.AutoRebasedCLs = append(result.AutoRebasedCLs, rebasedCL.String())
+	}
+	for _, dependencyCL := range dependencyCLs {
+		result.DependencyCLs = append(result.DependencyCLs, dependencyCL.String())
+	}
 
 	// Upload the test results to Google Storage.
 	path := gsPrefix + fmt.Sprintf("presubmit/%d/%s/%s", jenkinsBuildNumberFlag, os.Getenv("OS"), os.Getenv("ARCH"))
 	if err := persistTestData(jirix, outputDir, testName, partIndex, path); err != nil {
-		fmt.Fprintf(jirix.Stderr(), "failed to store test results: %v\n", err)
+		logger.Errorf("failed to store test results: %v\n", err)
 	}
 
-	return writeTestStatusFile(jirix, *result, curTimestamp, testName, partIndex)
+	return writeTestStatusFile(jirix, *result, changes, curTimestamp, testName, partIndex)
 }
 
 // profileFilesModified checks any of the given CLs modified files under the
 // "jiri-v23-profile/" or "jiri-profile-v23/" directories in the
 // "release.go.x.devtools" project.
 func profileFilesModified(jirix *jiri.X, cls []cl) (bool, error) {
-	gUrl, err := gerritBaseUrl()
-	if err != nil {
-		return false, err
-	}
 	for _, curCL := range cls {
-		results, err := jirix.Gerrit(gUrl).Query(fmt.Sprintf("change:%d", curCL.clNumber))
+		if curCL.project != "release.go.x.devtools" {
+			continue
+		}
+		files, err := backendForRef(curCL.ref).changedFiles(jirix, curCL.ref)
 		if err != nil {
 			return false, err
 		}
-		for _, result := range results {
-			if result.Project != "release.go.x.devtools" {
-				continue
+		for _, filename := range files {
+			if strings.HasPrefix(filename, "jiri-v23-profile/") {
+				return true, nil
 			}
-			for _, revision := range result.Revisions {
-				for filename := range revision.Files {
-					if strings.HasPrefix(filename, "jiri-v23-profile/") {
-						return true, nil
-					}
-					if strings.HasPrefix(filename, "jiri-profile-v23/") {
-						return true, nil
-					}
-				}
+			if strings.HasPrefix(filename, "jiri-profile-v23/") {
+				return true, nil
 			}
 		}
 	}
 	return false, nil
 }
 
+// changedFiles returns the absolute paths of the files modified by the
+// given CLs.
+func changedFiles(jirix *jiri.X, cls []cl, projects project.Projects) ([]string, error) {
+	files := []string{}
+	for _, curCL := range cls {
+		localProject, err := projects.FindUnique(curCL.project)
+		if err != nil {
+			return nil, fmt.Errorf("error finding project %q: %v", curCL.project, err)
+		}
+		clFiles, err := backendForRef(curCL.ref).changedFiles(jirix, curCL.ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, filename := range clFiles {
+			files = append(files, filepath.Join(localProject.Path, filename))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// changeSummary summarizes the changes made by the CLs under test.
+type changeSummary struct {
+	// Projects lists the names of the projects changed by the CLs.
+	Projects []string
+	// Packages lists the Go packages changed by the CLs.
+	Packages []string
+}
+
+// summarizeChanges summarizes the changes made by the given CLs, given
+// the files they change as returned by changedFiles. The files are
+// mapped to the Go packages that contain them.
+func summarizeChanges(jirix *jiri.X, cls []cl, files []string) (*changeSummary, error) {
+	summary := &changeSummary{Projects: []string{}, Packages: []string{}}
+	seen := map[string]bool{}
+	for _, curCL := range cls {
+		if !seen[curCL.project] {
+			seen[curCL.project] = true
+			summary.Projects = append(summary.Projects, curCL.project)
+		}
+	}
+	sort.Strings(summary.Projects)
+	config, err := tooldata.LoadConfig(jirix)
+	if err != nil {
+		return summary, err
+	}
+	summary.Packages, _ = goutil.ChangedPackages(jirix.Root, config.GoWorkspaces(), files)
+	sort.Strings(summary.Packages)
+	return summary, nil
+}
+
 func cleanupProfiles(jirix *jiri.X, env map[string]string, cls []cl) error {
 	fmt.Fprintf(jirix.Stdout(), "### Cleanning up profiles ###")
 	return jirix.NewSeq().Env(env).Timeout(jiriProfileTimeout).
@@ -410,7 +552,13 @@ func parseCLs() ([]cl, error) {
 	cls := []cl{}
 	for i, ref := range refs {
 		project := projects[i]
-		clNumber, patchset, err := gerrit.ParseRefString(ref)
+		var clNumber, patchset int
+		var err error
+		if strings.HasPrefix(ref, githubRefPrefix) {
+			_, clNumber, _, err = parseGitHubRef(ref)
+		} else {
+			clNumber, patchset, err = gerrit.ParseRefString(ref)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -431,22 +579,31 @@ func presubmitTestBranchName(ref string) string {
 }
 
 // preparePresubmitTestBranch creates and checks out the presubmit
-// test branch and pulls the CL there.
-func preparePresubmitTestBranch(jirix *jiri.X, cls []cl, projects project.Projects) (_ *cl, e error) {
+// test branch and pulls the CL there. If the -isolate flag is set, the
+// CL is pulled directly into the worktree of the isolated workspace
+// instead, leaving the branches of the shared checkout untouched. If
+// the -auto-rebase flag is set,
+// CLs that cannot be pulled are rebased onto the presubmit test branch
+// instead; the CLs that were rebased this way are returned. If a CL
+// cannot be pulled nor rebased, it is returned along with the error.
+func preparePresubmitTestBranch(jirix *jiri.X, cls []cl, projects project.Projects) (_ []cl, _ *cl, e error) {
 	strCLs := []string{}
 	for _, cl := range cls {
 		strCLs = append(strCLs, cl.String())
 	}
 	wd, err := os.Getwd()
 	if err != nil {
-		return nil, fmt.Errorf("Getwd() failed: %v", err)
+		return nil, nil, fmt.Errorf("Getwd() failed: %v", err)
 	}
 	defer collect.Error(func() error { return jirix.NewSeq().Chdir(wd).Done() }, &e)
-	if err := cleanupAllPresubmitTestBranches(jirix, projects); err != nil {
-		return nil, fmt.Errorf("%v\n", err)
+	if !isolateFlag {
+		if err := cleanupAllPresubmitTestBranches(jirix, projects); err != nil {
+			return nil, nil, fmt.Errorf("%v\n", err)
+		}
 	}
 	// Pull changes for each cl.
 	printf(jirix.Stdout(), "### Preparing to test %s\n", strings.Join(strCLs, ", "))
+	rebasedCLs := []cl{}
 	prepareFn := func(curCL cl) error {
 		localProject, err := projects.FindUnique(curCL.project)
 		if err != nil {
@@ -457,23 +614,63 @@ func preparePresubmitTestBranch(jirix *jiri.X, cls []cl, projects project.Projec
 			return fmt.Errorf("Chdir(%v) failed: %v", localProject.Path, err)
 		}
 		git := gitutil.New(s)
-		branchName := presubmitTestBranchName(curCL.ref)
-		if err := git.CreateAndCheckoutBranch(branchName); err != nil {
-			return err
+		if !isolateFlag {
+			branchName := presubmitTestBranchName(curCL.ref)
+			if err := git.CreateAndCheckoutBranch(branchName); err != nil {
+				return err
+			}
 		}
-		if err := git.Pull(localProject.Remote, curCL.ref); err != nil {
+		remote, refspec, err := backendForRef(curCL.ref).pullSource(localProject, curCL.ref)
+		if err != nil {
 			return err
 		}
+		if err := git.Pull(remote, refspec); err != nil {
+			if !autoRebaseFlag {
+				return err
+			}
+			if rebaseErr := autoRebaseCL(jirix, localProject, curCL); rebaseErr != nil {
+				fmt.Fprintf(jirix.Stderr(), "%v\n", rebaseErr)
+				return err
+			}
+			rebasedCLs = append(rebasedCLs, curCL)
+			test.Warn(jirix.Context, "merge of %s failed; rebased cleanly onto master\n", curCL.String())
+		}
 		return nil
 	}
 	for _, cl := range cls {
 		if err := prepareFn(cl); err != nil {
 			test.Fail(jirix.Context, "pull changes from %s\n", cl.String())
-			return &cl, err
+			return nil, &cl, err
 		}
 		test.Pass(jirix.Context, "pull changes from %s\n", cl.String())
 	}
-	return nil, nil
+	return rebasedCLs, nil, nil
+}
+
+// mergeConflictFiles returns the sorted conflicting files reported in
+// the given "git pull" output.
+func mergeConflictFiles(output string) []string {
+	seen, files := map[string]bool{}, []string{}
+	for _, re := range mergeConflictREs {
+		for _, match := range re.FindAllStringSubmatch(output, -1) {
+			if file := strings.TrimSpace(match[1]); !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// mergeConflictMessage returns the message reporting a merge conflict
+// of the given CL in the given files.
+func mergeConflictMessage(cl string, files []string) string {
+	message := fmt.Sprintf(mergeConflictMessageTmpl, cl)
+	if len(files) > 0 {
+		message += "\nConflicting files:\n  " + strings.Join(files, "\n  ")
+	}
+	return message
 }
 
 // recordPresubmitFailure records failure from presubmit binary itself
@@ -484,7 +681,7 @@ func recordPresubmitFailure(jirix *jiri.X, testCaseName, failureMessage, failure
 	}
 	// We use math.MaxInt64 here so that the logic that tries to find the newest
 	// build before the given timestamp terminates after the first iteration.
-	if err := writeTestStatusFile(jirix, result, math.MaxInt64, testName, partIndex); err != nil {
+	if err := writeTestStatusFile(jirix, result, nil, math.MaxInt64, testName, partIndex); err != nil {
 		return err
 	}
 	return nil
@@ -545,7 +742,7 @@ func cleanupAllPresubmitTestBranches(jirix *jiri.X, projects project.Projects) (
 // "master" presubmit project for generating final test results message.
 //
 // For more details, see comments in result.go.
-func writeTestStatusFile(jirix *jiri.X, result test.Result, curTimestamp int64, testName string, partIndex int) error {
+func writeTestStatusFile(jirix *jiri.X, result test.Result, changes *changeSummary, curTimestamp int64, testName string, partIndex int) error {
 	// Get the file path.
 	workspace, fileName := os.Getenv("WORKSPACE"), fmt.Sprintf("status_%s.json", strings.Replace(testName, "-", "_", -1))
 	statusFilePath := ""
@@ -560,6 +757,7 @@ func writeTestStatusFile(jirix *jiri.X, result test.Result, curTimestamp int64,
 		Result:    result,
 		TestName:  testName,
 		Timestamp: curTimestamp,
+		Changes:   changes,
 		AxisValues: axisValuesInfo{
 			Arch:      os.Getenv("ARCH"), // Architecture is stored in environment variable "ARCH"
 			OS:        os.Getenv("OS"),   // OS is stored in environment variable "OS"
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"v.io/jiri/gitutil"
@@ -92,6 +93,29 @@ func TestParseCLs(t *testing.T) {
 	}
 }
 
+func TestMergeConflictFiles(t *testing.T) {
+	output := `'git pull https://vanadium.googlesource.com/release.go.core refs/changes/10/1000/1' failed:
+stdout:
+Auto-merging lib/b.go
+CONFLICT (content): Merge conflict in lib/b.go
+CONFLICT (add/add): Merge conflict in a.go
+CONFLICT (modify/delete): c/c.go deleted in HEAD and modified in FETCH_HEAD. Version FETCH_HEAD of c/c.go left in tree.
+Automatic merge failed; fix conflicts and then commit the result.
+stderr:
+`
+	want := []string{"a.go", "c/c.go", "lib/b.go"}
+	if got := mergeConflictFiles(output); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got := mergeConflictFiles("fatal: unable to access remote"); len(got) != 0 {
+		t.Fatalf("want no files, got %v", got)
+	}
+	message := mergeConflictMessage("http://go/vcl/1000/1", want)
+	if wantSuffix := "\nConflicting files:\n  a.go\n  c/c.go\n  lib/b.go"; !strings.HasSuffix(message, wantSuffix) {
+		t.Fatalf("want suffix %q, got %q", wantSuffix, message)
+	}
+}
+
 // TestPresubmitTest is an end-to-end test for the "test" phase of presubmit.
 // It follows the steps below:
 //
@@ -26,15 +26,31 @@ type Config struct {
 	// copyrightCheckProjects identifies the set of project names for
 	// which the copyright check is required.
 	copyrightCheckProjects map[string]struct{}
+	// goTestClockFile identifies the file that lists the clock and
+	// time zone settings for Go tests.
+	goTestClockFile string
+	// goTestExclusionsFile identifies the file that lists Go tests to
+	// be excluded in addition to the built-in exclusions.
+	goTestExclusionsFile string
+	// goTestResourceLimits identifies the resource limits of the
+	// processes that run Go tests.
+	goTestResourceLimits GoTestResourceLimits
 	// goWorkspaces identifies JIRI_ROOT subdirectories that contain a
 	// Go workspace.
 	goWorkspaces []string
 	// jenkinsMatrixJobs identifies the set of matrix (multi-configutation) jobs
 	// in Jenkins.
 	jenkinsMatrixJobs map[string]JenkinsMatrixJobInfo
+	// makeTests maps the tests that run make targets to their extra
+	// settings.
+	makeTests map[string]MakeTestSettings
 	// projectTests maps jiri projects to sets of tests that should be
 	// executed to test changes in the given project.
 	projectTests map[string][]string
+	// reviewBackends maps jiri projects to the settings of the code
+	// review system their changes are sent to. Projects that are not
+	// listed use Gerrit.
+	reviewBackends map[string]ReviewBackendSettings
 	// testDependencies maps tests to sets of tests that the given test
 	// depends on.
 	testDependencies map[string][]string
@@ -68,6 +84,24 @@ type CopyrightCheckProjectsOpt map[string]struct{}
 
 func (CopyrightCheckProjectsOpt) configOpt() {}
 
+// GoTestClockFileOpt is the type that can be used to pass the Config
+// factory a Go test clock settings file option.
+type GoTestClockFileOpt string
+
+func (GoTestClockFileOpt) configOpt() {}
+
+// GoTestExclusionsFileOpt is the type that can be used to pass the
+// Config factory a Go test exclusions file option.
+type GoTestExclusionsFileOpt string
+
+func (GoTestExclusionsFileOpt) configOpt() {}
+
+// GoTestResourceLimitsOpt is the type that can be used to pass the
+// Config factory a Go test resource limits option.
+type GoTestResourceLimitsOpt GoTestResourceLimits
+
+func (GoTestResourceLimitsOpt) configOpt() {}
+
 // GoWorkspacesOpt is the type that can be used to pass the Config
 // factory a Go workspace option.
 type GoWorkspacesOpt []string
@@ -80,12 +114,24 @@ type JenkinsMatrixJobsOpt map[string]JenkinsMatrixJobInfo
 
 func (JenkinsMatrixJobsOpt) configOpt() {}
 
+// MakeTestsOpt is the type that can be used to pass the Config factory
+// a make tests option.
+type MakeTestsOpt map[string]MakeTestSettings
+
+func (MakeTestsOpt) configOpt() {}
+
 // ProjectTestsOpt is the type that can be used to pass the Config
 // factory a project tests option.
 type ProjectTestsOpt map[string][]string
 
 func (ProjectTestsOpt) configOpt() {}
 
+// ReviewBackendsOpt is the type that can be used to pass the Config
+// factory a review backends option.
+type ReviewBackendsOpt map[string]ReviewBackendSettings
+
+func (ReviewBackendsOpt) configOpt() {}
+
 // TestDependenciesOpt is the type that can be used to pass the Config
 // factory a test dependencies option.
 type TestDependenciesOpt map[string][]string
@@ -119,12 +165,22 @@ func NewConfig(opts ...ConfigOpt) *Config {
 			c.apiCheckProjects = map[string]struct{}(typedOpt)
 		case CopyrightCheckProjectsOpt:
 			c.copyrightCheckProjects = map[string]struct{}(typedOpt)
+		case GoTestClockFileOpt:
+			c.goTestClockFile = string(typedOpt)
+		case GoTestExclusionsFileOpt:
+			c.goTestExclusionsFile = string(typedOpt)
+		case GoTestResourceLimitsOpt:
+			c.goTestResourceLimits = GoTestResourceLimits(typedOpt)
 		case GoWorkspacesOpt:
 			c.goWorkspaces = []string(typedOpt)
 		case JenkinsMatrixJobsOpt:
 			c.jenkinsMatrixJobs = map[string]JenkinsMatrixJobInfo(typedOpt)
+		case MakeTestsOpt:
+			c.makeTests = map[string]MakeTestSettings(typedOpt)
 		case ProjectTestsOpt:
 			c.projectTests = map[string][]string(typedOpt)
+		case ReviewBackendsOpt:
+			c.reviewBackends = map[string]ReviewBackendSettings(typedOpt)
 		case TestDependenciesOpt:
 			c.testDependencies = map[string][]string(typedOpt)
 		case TestGroupsOpt:
@@ -165,6 +221,28 @@ func (c Config) GroupTests(groups []string) []string {
 	return tests
 }
 
+// GoTestClockFile returns the path to the file that lists the clock
+// and time zone settings for Go tests. Relative paths are relative to
+// the tools data directory. An empty string means that no such file
+// exists. Note that the fake clock of a setting is implemented with
+// libfaketime, which does not affect the clock of Go code.
+func (c Config) GoTestClockFile() string {
+	return c.goTestClockFile
+}
+
+// GoTestExclusionsFile returns the path to the file that lists
+// additional Go test exclusions. Relative paths are relative to the
+// tools data directory. An empty string means that no such file exists.
+func (c Config) GoTestExclusionsFile() string {
+	return c.goTestExclusionsFile
+}
+
+// GoTestResourceLimits returns the resource limits of the processes
+// that run Go tests.
+func (c Config) GoTestResourceLimits() GoTestResourceLimits {
+	return c.goTestResourceLimits
+}
+
 // GoWorkspaces returns the Go workspaces included in the config.
 func (c Config) GoWorkspaces() []string {
 	return c.goWorkspaces
@@ -175,6 +253,12 @@ func (c Config) JenkinsMatrixJobs() map[string]JenkinsMatrixJobInfo {
 	return c.jenkinsMatrixJobs
 }
 
+// MakeTests returns the extra settings of the tests that run make
+// targets.
+func (c Config) MakeTests() map[string]MakeTestSettings {
+	return c.makeTests
+}
+
 // Projects returns a list of projects included in the config.
 func (c Config) Projects() []string {
 	var projects []string
@@ -204,6 +288,21 @@ func (c Config) ProjectTests(projects []string) []string {
 	return tests
 }
 
+// ReviewBackend returns the settings of the code review system that
+// changes in the given project are sent to.
+func (c Config) ReviewBackend(project string) ReviewBackendSettings {
+	if settings, ok := c.reviewBackends[project]; ok {
+		return settings
+	}
+	return ReviewBackendSettings{Project: project, Type: ReviewBackendGerrit}
+}
+
+// ReviewBackends returns the settings of the code review systems of the
+// projects that do not use Gerrit.
+func (c Config) ReviewBackends() map[string]ReviewBackendSettings {
+	return c.reviewBackends
+}
+
 // TestDependencies returns a list of dependencies for the given test.
 func (c Config) TestDependencies(test string) []string {
 	return c.testDependencies[test]
@@ -270,9 +369,14 @@ func pathHelper(jirix *jiri.X, projects project.Projects, workspaces []string, s
 type configSchema struct {
 	APICheckProjects       []string                `xml:"apiCheckProjects>project"`
 	CopyrightCheckProjects []string                `xml:"copyrightCheckProjects>project"`
+	GoTestClockFile        string                  `xml:"goTestClockFile,omitempty"`
+	GoTestExclusionsFile   string                  `xml:"goTestExclusionsFile,omitempty"`
+	GoTestResourceLimits   *GoTestResourceLimits   `xml:"goTestResourceLimits,omitempty"`
 	GoWorkspaces           []string                `xml:"goWorkspaces>workspace"`
 	JenkinsMatrixJobs      jenkinsMatrixJobsSchema `xml:"jenkinsMatrixJobs>job"`
+	MakeTests              makeTestsSchema         `xml:"makeTests>test"`
 	ProjectTests           testGroupSchemas        `xml:"projectTests>project"`
+	ReviewBackends         reviewBackendsSchema    `xml:"reviewBackends>project"`
 	TestDependencies       dependencyGroupSchemas  `xml:"testDependencies>test"`
 	TestGroups             testGroupSchemas        `xml:"testGroups>group"`
 	TestParts              partGroupSchemas        `xml:"testParts>test"`
@@ -291,6 +395,29 @@ func (d dependencyGroupSchemas) Len() int           { return len(d) }
 func (d dependencyGroupSchemas) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 func (d dependencyGroupSchemas) Less(i, j int) bool { return d[i].Name < d[j].Name }
 
+// GoTestResourceLimits holds the resource limits of the processes that
+// run Go tests, which keep a runaway test from starving the other tests
+// run on the same machine.
+type GoTestResourceLimits struct {
+	// CPUSeconds limits the CPU time of each test binary, in seconds.
+	// Zero means no limit.
+	CPUSeconds int `xml:"cpuSeconds,attr,omitempty"`
+	// MemoryMB limits the virtual memory of each test binary, in
+	// megabytes. Zero means no limit. The limit does not apply to the
+	// race detector and sanitizer tests, whose binaries reserve far
+	// more virtual memory than they use.
+	MemoryMB int `xml:"memoryMB,attr,omitempty"`
+	// Wrapper is the command line, such as a command that runs its
+	// arguments in a cgroup, that the "go test" processes are run with.
+	// The command line of each process is appended to it.
+	Wrapper []string `xml:"wrapper>arg"`
+}
+
+// Enabled returns whether any resource limits are set.
+func (l GoTestResourceLimits) Enabled() bool {
+	return l.CPUSeconds > 0 || l.MemoryMB > 0 || len(l.Wrapper) > 0
+}
+
 type JenkinsMatrixJobInfo struct {
 	HasArch  bool `xml:"arch,attr"`
 	HasOS    bool `xml:"OS,attr"`
@@ -309,6 +436,54 @@ func (jobs jenkinsMatrixJobsSchema) Len() int           { return len(jobs) }
 func (jobs jenkinsMatrixJobsSchema) Swap(i, j int)      { jobs[i], jobs[j] = jobs[j], jobs[i] }
 func (jobs jenkinsMatrixJobsSchema) Less(i, j int) bool { return jobs[i].Name < jobs[j].Name }
 
+// MakeTestSettings holds the settings of a test that runs a make
+// target, which supplement the settings built into jiri-test.
+type MakeTestSettings struct {
+	Name string `xml:"name,attr"`
+	// Env lists extra environment variables, in the <key>=<value>
+	// format, to run make with.
+	Env []string `xml:"env"`
+	// Profiles lists extra profiles that the test requires.
+	Profiles []string `xml:"profile"`
+	// Timeout overrides the timeout of the test, such as "30m".
+	Timeout string `xml:"timeout,attr,omitempty"`
+	// Output identifies the format, either "tap" or "xunit", of the
+	// test results that the make target writes to the file named by
+	// the XUNIT_OUTPUT_FILE environment variable. If empty, only the
+	// exit status of make is reported.
+	Output string `xml:"output,attr,omitempty"`
+}
+
+type makeTestsSchema []MakeTestSettings
+
+func (tests makeTestsSchema) Len() int           { return len(tests) }
+func (tests makeTestsSchema) Swap(i, j int)      { tests[i], tests[j] = tests[j], tests[i] }
+func (tests makeTestsSchema) Less(i, j int) bool { return tests[i].Name < tests[j].Name }
+
+const (
+	// ReviewBackendGerrit identifies the Gerrit code review system.
+	ReviewBackendGerrit = "gerrit"
+	// ReviewBackendGitHub identifies GitHub pull requests.
+	ReviewBackendGitHub = "github"
+)
+
+// ReviewBackendSettings identifies the code review system that changes
+// in a project are sent to.
+type ReviewBackendSettings struct {
+	Project string `xml:"name,attr"`
+	// Type is either ReviewBackendGerrit or ReviewBackendGitHub.
+	Type string `xml:"type,attr"`
+	// Repo identifies the GitHub repository of the project, in the
+	// <owner>/<name> format.
+	Repo string `xml:"repo,attr,omitempty"`
+}
+
+type reviewBackendsSchema []ReviewBackendSettings
+
+func (b reviewBackendsSchema) Len() int           { return len(b) }
+func (b reviewBackendsSchema) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b reviewBackendsSchema) Less(i, j int) bool { return b[i].Project < b[j].Project }
+
 type partGroupSchema struct {
 	Name  string   `xml:"name,attr"`
 	Parts []string `xml:"part"`
@@ -346,6 +521,9 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkConfigSchema(configBytes); err != nil {
+		return nil, fmt.Errorf("invalid tools config %v: %v", path, err)
+	}
 	var data configSchema
 	if err := xml.Unmarshal(configBytes, &data); err != nil {
 		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", string(configBytes), err)
@@ -355,7 +533,9 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 		copyrightCheckProjects: map[string]struct{}{},
 		goWorkspaces:           []string{},
 		jenkinsMatrixJobs:      map[string]JenkinsMatrixJobInfo{},
+		makeTests:              map[string]MakeTestSettings{},
 		projectTests:           map[string][]string{},
+		reviewBackends:         map[string]ReviewBackendSettings{},
 		testDependencies:       map[string][]string{},
 		testGroups:             map[string][]string{},
 		testParts:              map[string][]string{},
@@ -363,6 +543,11 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 	}
 	config.apiCheckProjects = set.String.FromSlice(data.APICheckProjects)
 	config.copyrightCheckProjects = set.String.FromSlice(data.CopyrightCheckProjects)
+	config.goTestClockFile = data.GoTestClockFile
+	config.goTestExclusionsFile = data.GoTestExclusionsFile
+	if data.GoTestResourceLimits != nil {
+		config.goTestResourceLimits = *data.GoTestResourceLimits
+	}
 	for _, workspace := range data.GoWorkspaces {
 		config.goWorkspaces = append(config.goWorkspaces, workspace)
 	}
@@ -370,9 +555,15 @@ func loadConfig(jirix *jiri.X, path string) (*Config, error) {
 	for _, job := range data.JenkinsMatrixJobs {
 		config.jenkinsMatrixJobs[job.Name] = job
 	}
+	for _, test := range data.MakeTests {
+		config.makeTests[test.Name] = test
+	}
 	for _, project := range data.ProjectTests {
 		config.projectTests[project.Name] = project.Tests
 	}
+	for _, backend := range data.ReviewBackends {
+		config.reviewBackends[backend.Project] = backend
+	}
 	for _, test := range data.TestDependencies {
 		config.testDependencies[test.Name] = test.Dependencies
 	}
@@ -405,6 +596,11 @@ func saveConfig(jirix *jiri.X, config *Config, path string) error {
 	sort.Strings(data.APICheckProjects)
 	data.CopyrightCheckProjects = set.String.ToSlice(config.copyrightCheckProjects)
 	sort.Strings(data.CopyrightCheckProjects)
+	data.GoTestClockFile = config.goTestClockFile
+	data.GoTestExclusionsFile = config.goTestExclusionsFile
+	if limits := config.goTestResourceLimits; limits.Enabled() {
+		data.GoTestResourceLimits = &limits
+	}
 	for _, workspace := range config.goWorkspaces {
 		data.GoWorkspaces = append(data.GoWorkspaces, workspace)
 	}
@@ -413,6 +609,10 @@ func saveConfig(jirix *jiri.X, config *Config, path string) error {
 		data.JenkinsMatrixJobs = append(data.JenkinsMatrixJobs, job)
 	}
 	sort.Sort(data.JenkinsMatrixJobs)
+	for _, test := range config.makeTests {
+		data.MakeTests = append(data.MakeTests, test)
+	}
+	sort.Sort(data.MakeTests)
 	for name, tests := range config.projectTests {
 		data.ProjectTests = append(data.ProjectTests, testGroupSchema{
 			Name:  name,
@@ -420,6 +620,10 @@ func saveConfig(jirix *jiri.X, config *Config, path string) error {
 		})
 	}
 	sort.Sort(data.ProjectTests)
+	for _, backend := range config.reviewBackends {
+		data.ReviewBackends = append(data.ReviewBackends, backend)
+	}
+	sort.Sort(data.ReviewBackends)
 	for name, dependencies := range config.testDependencies {
 		data.TestDependencies = append(data.TestDependencies, dependencyGroupSchema{
 			Name:         name,
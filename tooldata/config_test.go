@@ -23,6 +23,13 @@ var (
 		"projectC": struct{}{},
 		"projectD": struct{}{},
 	}
+	goTestClockFile      = "test-clocks.json"
+	goTestExclusionsFile = "test-exclusions.json"
+	goTestResourceLimits = tooldata.GoTestResourceLimits{
+		CPUSeconds: 600,
+		MemoryMB:   4096,
+		Wrapper:    []string{"cgexec", "-g", "memory:tests"},
+	}
 	goWorkspaces      = []string{"test-go-workspace"}
 	jenkinsMatrixJobs = map[string]tooldata.JenkinsMatrixJobInfo{
 		"test-job-A": {
@@ -40,10 +47,26 @@ var (
 			Name:     "test-job-B",
 		},
 	}
+	makeTests = map[string]tooldata.MakeTestSettings{
+		"test-test-E": {
+			Name:     "test-test-E",
+			Env:      []string{"BROWSER=firefox"},
+			Profiles: []string{"v23:nodejs"},
+			Timeout:  "30m",
+			Output:   "tap",
+		},
+	}
 	projectTests = map[string][]string{
 		"test-project":  []string{"test-test-A", "test-test-group"},
 		"test-project2": []string{"test-test-D"},
 	}
+	reviewBackends = map[string]tooldata.ReviewBackendSettings{
+		"test-project2": {
+			Project: "test-project2",
+			Type:    tooldata.ReviewBackendGitHub,
+			Repo:    "vanadium/test-project2",
+		},
+	}
 	testDependencies = map[string][]string{
 		"test-test-A": []string{"test-test-B"},
 		"test-test-B": []string{"test-test-C"},
@@ -64,6 +87,15 @@ func testConfigAPI(t *testing.T, c *tooldata.Config) {
 	if got, want := c.CopyrightCheckProjects(), copyrightCheckProjects; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected results: got %v, want %v", got, want)
 	}
+	if got, want := c.GoTestClockFile(), goTestClockFile; got != want {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
+	if got, want := c.GoTestExclusionsFile(), goTestExclusionsFile; got != want {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
+	if got, want := c.GoTestResourceLimits(), goTestResourceLimits; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
 	if got, want := c.GoWorkspaces(), goWorkspaces; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
@@ -73,6 +105,9 @@ func testConfigAPI(t *testing.T, c *tooldata.Config) {
 	if got, want := c.JenkinsMatrixJobs(), jenkinsMatrixJobs; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
+	if got, want := c.MakeTests(), makeTests; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
 	if got, want := c.Projects(), []string{"test-project", "test-project2"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
@@ -82,6 +117,12 @@ func testConfigAPI(t *testing.T, c *tooldata.Config) {
 	if got, want := c.ProjectTests([]string{"test-project", "test-project2"}), []string{"test-test-A", "test-test-B", "test-test-C", "test-test-D"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
+	if got, want := c.ReviewBackends(), reviewBackends; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
+	if got, want := c.ReviewBackend("test-project"), (tooldata.ReviewBackendSettings{Project: "test-project", Type: tooldata.ReviewBackendGerrit}); got != want {
+		t.Fatalf("unexpected result: got %v, want %v", got, want)
+	}
 	if got, want := c.TestDependencies("test-test-A"), []string{"test-test-B"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("unexpected result: got %v, want %v", got, want)
 	}
@@ -100,9 +141,14 @@ func TestConfigAPI(t *testing.T) {
 	config := tooldata.NewConfig(
 		tooldata.APICheckProjectsOpt(apiCheckProjects),
 		tooldata.CopyrightCheckProjectsOpt(copyrightCheckProjects),
+		tooldata.GoTestClockFileOpt(goTestClockFile),
+		tooldata.GoTestExclusionsFileOpt(goTestExclusionsFile),
+		tooldata.GoTestResourceLimitsOpt(goTestResourceLimits),
 		tooldata.GoWorkspacesOpt(goWorkspaces),
 		tooldata.JenkinsMatrixJobsOpt(jenkinsMatrixJobs),
+		tooldata.MakeTestsOpt(makeTests),
 		tooldata.ProjectTestsOpt(projectTests),
+		tooldata.ReviewBackendsOpt(reviewBackends),
 		tooldata.TestDependenciesOpt(testDependencies),
 		tooldata.TestGroupsOpt(testGroups),
 		tooldata.TestPartsOpt(testParts),
@@ -119,9 +165,14 @@ func TestConfigSerialization(t *testing.T) {
 	config := tooldata.NewConfig(
 		tooldata.APICheckProjectsOpt(apiCheckProjects),
 		tooldata.CopyrightCheckProjectsOpt(copyrightCheckProjects),
+		tooldata.GoTestClockFileOpt(goTestClockFile),
+		tooldata.GoTestExclusionsFileOpt(goTestExclusionsFile),
+		tooldata.GoTestResourceLimitsOpt(goTestResourceLimits),
 		tooldata.GoWorkspacesOpt(goWorkspaces),
 		tooldata.JenkinsMatrixJobsOpt(jenkinsMatrixJobs),
+		tooldata.MakeTestsOpt(makeTests),
 		tooldata.ProjectTestsOpt(projectTests),
+		tooldata.ReviewBackendsOpt(reviewBackends),
 		tooldata.TestDependenciesOpt(testDependencies),
 		tooldata.TestGroupsOpt(testGroups),
 		tooldata.TestPartsOpt(testParts),
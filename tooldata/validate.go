@@ -0,0 +1,202 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tooldata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// schemaNode describes the attributes and the child elements that an
+// element of the tools config accepts.
+type schemaNode struct {
+	attrs    map[string]bool
+	children map[string]*schemaNode
+}
+
+// newSchemaNode returns the schema node of the elements whose content is
+// decoded into values of the given type.
+func newSchemaNode(t reflect.Type) *schemaNode {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	node := &schemaNode{attrs: map[string]bool{}, children: map[string]*schemaNode{}}
+	if t.Kind() != reflect.Struct {
+		return node
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if field.Name == "XMLName" || tag == "-" {
+			continue
+		}
+		name, flags := tag, ""
+		if i := strings.Index(tag, ","); i != -1 {
+			name, flags = tag[:i], tag[i:]
+		}
+		switch {
+		case strings.Contains(flags, ",chardata"), strings.Contains(flags, ",comment"):
+			continue
+		case name == "":
+			name = field.Name
+		}
+		if strings.Contains(flags, ",attr") {
+			node.attrs[name] = true
+			continue
+		}
+		// A path such as "a>b" identifies <b> elements nested in an <a>
+		// element.
+		parent, path := node, strings.Split(name, ">")
+		for _, elem := range path[:len(path)-1] {
+			if parent.children[elem] == nil {
+				parent.children[elem] = &schemaNode{attrs: map[string]bool{}, children: map[string]*schemaNode{}}
+			}
+			parent = parent.children[elem]
+		}
+		parent.children[path[len(path)-1]] = newSchemaNode(field.Type)
+	}
+	return node
+}
+
+// checkConfigSchema checks that the given tools config only consists of
+// the elements and attributes that the config schema defines, which the
+// XML decoder would otherwise silently ignore.
+func checkConfigSchema(configBytes []byte) error {
+	root := &schemaNode{children: map[string]*schemaNode{"config": newSchemaNode(reflect.TypeOf(configSchema{}))}}
+	stack := []*schemaNode{root}
+	decoder := xml.NewDecoder(bytes.NewReader(configBytes))
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Token() failed: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			line := bytes.Count(configBytes[:offset], []byte("\n")) + 1
+			parent := stack[len(stack)-1]
+			node, ok := parent.children[t.Name.Local]
+			if !ok {
+				return fmt.Errorf("line %d: unknown element <%s>%s", line, t.Name.Local, suggestion(t.Name.Local, parent.children, "<", ">"))
+			}
+			for _, attr := range t.Attr {
+				if !node.attrs[attr.Name.Local] {
+					return fmt.Errorf("line %d: unknown attribute %q of <%s>%s", line, attr.Name.Local, t.Name.Local, suggestion(attr.Name.Local, node.attrs, `"`, `"`))
+				}
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// suggestion returns a hint naming the key of the given map that is
+// closest to the given misspelled name, if any key is close enough. The
+// hint encloses the key in the given delimiters.
+func suggestion(name string, candidates interface{}, open, close string) string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(candidates).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	best, bestDistance := "", len(name)/2+1
+	for _, key := range keys {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = key, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s%s%s?)", open, best, close)
+}
+
+// editDistance returns the Levenshtein distance of the given strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// CheckTests checks that the tests the config refers to are among the
+// given tests, which are the tests known to jiri-test. The tests of
+// projects may also name test groups. All problems found are reported
+// in the returned error.
+func (c Config) CheckTests(tests []string) error {
+	known := map[string]bool{}
+	for _, test := range tests {
+		known[test] = true
+	}
+	problems := []string{}
+	check := func(test, where string, groupAllowed bool) {
+		if known[test] {
+			return
+		}
+		if _, ok := c.testGroups[test]; ok && groupAllowed {
+			return
+		}
+		problems = append(problems, fmt.Sprintf("unknown test %q in %s", test, where))
+	}
+	for project, tests := range c.projectTests {
+		for _, test := range tests {
+			check(test, fmt.Sprintf("the tests of project %q", project), true)
+		}
+	}
+	for group, tests := range c.testGroups {
+		for _, test := range tests {
+			check(test, fmt.Sprintf("test group %q", group), false)
+		}
+	}
+	for test, dependencies := range c.testDependencies {
+		check(test, "testDependencies", false)
+		for _, dependency := range dependencies {
+			check(dependency, fmt.Sprintf("the dependencies of test %q", test), false)
+		}
+	}
+	for test := range c.testParts {
+		check(test, "testParts", false)
+	}
+	for test := range c.makeTests {
+		check(test, "makeTests", false)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid tools config:\n%s", strings.Join(problems, "\n"))
+}
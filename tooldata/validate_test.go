@@ -0,0 +1,96 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tooldata_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"v.io/jiri/jiritest"
+	"v.io/x/devtools/tooldata"
+)
+
+func TestConfigSchemaValidation(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	configPath, err := tooldata.ConfigFilePath(fake.X)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{
+			config: "<config>\n  <projectTests>\n    <project name=\"p\"><test>t</test></project>\n  </projectTests>\n</config>\n",
+		},
+		{
+			config: "<config>\n  <projectTest>\n  </projectTest>\n</config>\n",
+			want:   "line 2: unknown element <projectTest> (did you mean <projectTests>?)",
+		},
+		{
+			config: "<config>\n  <projectTests>\n    <project nme=\"p\"></project>\n  </projectTests>\n</config>\n",
+			want:   `line 3: unknown attribute "nme" of <project> (did you mean "name"?)`,
+		},
+		{
+			config: "<config>\n  <foo/>\n</config>\n",
+			want:   "line 2: unknown element <foo>",
+		},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(configPath, []byte(test.config), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+		_, err := tooldata.LoadConfig(fake.X)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%v", err)
+		case test.want != "" && (err == nil || !strings.HasSuffix(err.Error(), test.want)):
+			t.Errorf("want error ending with %q, got %v", test.want, err)
+		}
+	}
+}
+
+func TestConfigCheckTests(t *testing.T) {
+	tests := []string{"test-A", "test-B", "test-C", "test-D", "test-make"}
+	config := tooldata.NewConfig(
+		tooldata.MakeTestsOpt(map[string]tooldata.MakeTestSettings{"test-make": {Name: "test-make"}}),
+		tooldata.ProjectTestsOpt(map[string][]string{"test-project": []string{"test-A", "test-group"}}),
+		tooldata.TestDependenciesOpt(map[string][]string{"test-A": []string{"test-B"}}),
+		tooldata.TestGroupsOpt(map[string][]string{"test-group": []string{"test-B", "test-C"}}),
+		tooldata.TestPartsOpt(map[string][]string{"test-D": []string{"p1"}}),
+	)
+	if err := config.CheckTests(tests); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	config = tooldata.NewConfig(
+		tooldata.MakeTestsOpt(map[string]tooldata.MakeTestSettings{"test-make": {Name: "test-make"}}),
+		tooldata.ProjectTestsOpt(map[string][]string{"test-project": []string{"test-A", "test-typo"}}),
+		tooldata.TestDependenciesOpt(map[string][]string{"test-A": []string{"test-group"}}),
+		tooldata.TestGroupsOpt(map[string][]string{"test-group": []string{"test-B", "test-E"}}),
+		tooldata.TestPartsOpt(map[string][]string{"test-D": []string{"p1"}}),
+	)
+	err := config.CheckTests(tests[:3])
+	if err == nil {
+		t.Fatalf("checking unknown tests did not fail")
+	}
+	want := `invalid tools config:
+unknown test "test-D" in testParts
+unknown test "test-E" in test group "test-group"
+unknown test "test-group" in the dependencies of test "test-A"
+unknown test "test-make" in makeTests
+unknown test "test-typo" in the tests of project "test-project"`
+	if got := err.Error(); got != want {
+		t.Fatalf("want\n%v\ngot\n%v", want, got)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"v.io/jiri/tool"
+)
+
+// copiedFile describes a file copied by 'vcloud cp' that is verified.
+type copiedFile struct {
+	// src is the path of the source file, with the ':' prefix if it is
+	// remote.
+	src string
+	// dsts holds the candidate paths of the copy of the file, in order of
+	// preference.  The copy may end up at any of them depending on whether
+	// the destination directory existed before the copy.
+	dsts []string
+	// sum is the SHA-256 checksum of the source file.
+	sum string
+}
+
+// copyDsts returns the candidate paths of the copy of file, which is src itself
+// or a file under the directory src, when copying to dst.  If src is copied
+// into an existing directory dst, the copy is under dst/<base of src>;
+// otherwise, if src is the only source, the copy is dst itself.
+func copyDsts(src, file, dst string, onlySrc bool) []string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(file, src), "/")
+	dsts := []string{path.Join(dst, path.Base(src), rel)}
+	if onlySrc {
+		dsts = append(dsts, path.Join(dst, rel))
+	}
+	return dsts
+}
+
+// checksumLine matches the lines of the output of sha256sum.
+var checksumLine = regexp.MustCompile(`^([0-9a-f]{64}) [ *](.+?)\r?$`)
+
+// parseChecksums returns the checksums listed in the given output of
+// sha256sum, indexed by file path.  Lines that don't list a checksum, e.g.
+// messages from gcloud, are ignored.
+func parseChecksums(out string) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if m := checksumLine.FindStringSubmatch(line); m != nil {
+			sums[path.Clean(m[2])] = m[1]
+		}
+	}
+	return sums
+}
+
+// checksumCommand returns the cmdline that prints the checksums of the
+// regular files under the given paths on a node.  The paths are shell-quoted,
+// and the cmdline succeeds even if some of them are missing.
+func checksumCommand(paths []string) []string {
+	cmdline := []string{"find"}
+	for _, p := range paths {
+		cmdline = append(cmdline, shellQuote(p))
+	}
+	return append(cmdline, "-type", "f", "-exec", "sha256sum", "{}", "+", "2>/dev/null", ";", "true")
+}
+
+// remoteChecksums returns the checksums of the regular files under the given
+// remote paths on node n, indexed by file path.  Missing paths are ignored.
+func (n nodeInfo) remoteChecksums(ctx *tool.Context, paths []string) (map[string]string, runResult) {
+	result := n.RunCommand(ctx, *flagUser, checksumCommand(paths))
+	sums := parseChecksums(result.out)
+	result.out = ""
+	return sums, result
+}
+
+// localChecksums returns the checksums of the regular files under the given
+// local paths, indexed by file path.  Missing paths are ignored.
+func localChecksums(paths []string) (map[string]string, error) {
+	sums := map[string]string{}
+	for _, p := range paths {
+		err := filepath.Walk(p, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			sum, err := fileChecksum(file)
+			if err != nil {
+				return err
+			}
+			sums[filepath.Clean(file)] = sum
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sums, nil
+}
+
+// fileChecksum returns the SHA-256 checksum of the given local file.
+func fileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("Copy(%v) failed: %v", file, err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// mismatchedFiles returns the files whose copies are missing or don't match
+// the checksums of the sources, given the checksums of the destination files.
+// The dsts of the returned files are narrowed down to the path the file should
+// be copied to again.
+func mismatchedFiles(files []copiedFile, dstSums map[string]string) []copiedFile {
+	var mismatched []copiedFile
+	for _, file := range files {
+		dst := file.dsts[0]
+		found := false
+		for _, candidate := range file.dsts {
+			if sum, ok := dstSums[candidate]; ok {
+				dst, found = candidate, sum == file.sum
+				break
+			}
+		}
+		if !found {
+			mismatched = append(mismatched, copiedFile{src: file.src, dsts: []string{dst}, sum: file.sum})
+		}
+	}
+	return mismatched
+}
+
+// copiedFiles returns the files copied from srcs to dst on node n, along with
+// their checksums.  Remote paths have the ':' prefix.
+func (n nodeInfo) copiedFiles(ctx *tool.Context, srcs []string, dst string) ([]copiedFile, runResult) {
+	result := runResult{node: n}
+	var sums map[string]string
+	remoteSrcs := !strings.HasPrefix(dst, ":")
+	if remoteSrcs {
+		paths := make([]string, len(srcs))
+		for i, src := range srcs {
+			paths[i] = strings.TrimPrefix(src, ":")
+		}
+		sums, result = n.remoteChecksums(ctx, paths)
+	} else {
+		sums, result.err = localChecksums(srcs)
+	}
+	if result.err != nil {
+		return nil, result
+	}
+	var files []copiedFile
+	for _, src := range srcs {
+		srcPath := path.Clean(strings.TrimPrefix(src, ":"))
+		for file, sum := range sums {
+			if file != srcPath && !strings.HasPrefix(file, srcPath+"/") {
+				continue
+			}
+			dsts := copyDsts(srcPath, file, strings.TrimPrefix(dst, ":"), len(srcs) == 1)
+			if remoteSrcs {
+				file = ":" + file
+			}
+			files = append(files, copiedFile{src: file, dsts: dsts, sum: sum})
+		}
+	}
+	if len(files) == 0 {
+		result.err = fmt.Errorf("no files to verify in %v", srcs)
+	}
+	return files, result
+}
+
+// verifyCopy verifies the checksums of the copies of srcs in dst on node n, and
+// copies mismatched files again up to flagCopyRetries times.
+func (n nodeInfo) verifyCopy(ctx *tool.Context, srcs []string, dst string) runResult {
+	result := runResult{node: n}
+	files, r := n.copiedFiles(ctx, srcs, dst)
+	result.Merge(r, "[cp] compute checksums of %d sources", len(srcs))
+	if result.err != nil {
+		return result
+	}
+	for attempt := 0; ; attempt++ {
+		dstPaths := []string{}
+		for _, file := range files {
+			dstPaths = append(dstPaths, file.dsts...)
+		}
+		var dstSums map[string]string
+		if strings.HasPrefix(dst, ":") {
+			var r runResult
+			dstSums, r = n.remoteChecksums(ctx, dstPaths)
+			result.Merge(r, "[cp] compute checksums of %d copies", len(files))
+		} else {
+			var err error
+			dstSums, err = localChecksums(dstPaths)
+			result.Merge(runResult{node: n, err: err}, "[cp] compute checksums of %d copies", len(files))
+		}
+		if result.err != nil {
+			return result
+		}
+		if files = mismatchedFiles(files, dstSums); len(files) == 0 {
+			return result
+		}
+		if attempt == flagCopyRetries {
+			result.err = fmt.Errorf("%d copies don't match their sources: %v", len(files), files[0].dsts[0])
+			return result
+		}
+		for _, file := range files {
+			copyDst := file.dsts[0]
+			if strings.HasPrefix(dst, ":") {
+				copyDst = ":" + copyDst
+			}
+			result.Merge(n.RunCopy(ctx, []string{file.src}, copyDst, false), "[cp] copy mismatched file %v again", file.src)
+			if result.err != nil {
+				return result
+			}
+		}
+	}
+}
+
+// hasRsync returns true iff rsync is available both locally and on node n.
+func (n nodeInfo) hasRsync(ctx *tool.Context) bool {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return false
+	}
+	return n.ExternalIP != "" && n.RunCommand(ctx, *flagUser, []string{"command", "-v", "rsync"}).err == nil
+}
+
+// RunRsync is like RunCopy, but copies with rsync over ssh, keeping partially
+// copied files so that an interrupted copy resumes where it left off.  It uses
+// the ssh key set up by gcloud to connect to the external IP of node n.
+func (n nodeInfo) RunRsync(ctx *tool.Context, srcs []string, dst string, makeSubdir bool) runResult {
+	remote := addUser(*flagUser, n.ExternalIP) + ":"
+	if strings.HasPrefix(dst, ":") {
+		dst = remote + strings.TrimPrefix(dst, ":")
+	} else {
+		rsyncSrcs := make([]string, len(srcs))
+		for i, src := range srcs {
+			rsyncSrcs[i] = remote + strings.TrimPrefix(src, ":")
+		}
+		srcs = rsyncSrcs
+		if makeSubdir {
+			dst = path.Join(dst, n.Name)
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				return runResult{node: n, err: err}
+			}
+		}
+	}
+	ssh := fmt.Sprintf("ssh -i %s -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null", filepath.Join(os.Getenv("HOME"), ".ssh", "google_compute_engine"))
+	args := []string{"-rtz", "--partial", "-e", ssh}
+	args = append(args, srcs...)
+	args = append(args, dst)
+	var stdouterr bytes.Buffer
+	err := ctx.NewSeq().Read(nil).Capture(&stdouterr, &stdouterr).Last("rsync", args...)
+	return runResult{node: n, out: stdouterr.String(), err: err}
+}
+
+// RunVerifiedCopy runs the copy from srcs to dst on node n like RunCopy.  If
+// flagResume is true and rsync is available, the copy is run with RunRsync.
+// If flagVerify is true, the checksums of the copies are verified afterwards.
+func (n nodeInfo) RunVerifiedCopy(ctx *tool.Context, srcs []string, dst string, makeSubdir bool) runResult {
+	result := runResult{node: n}
+	if flagResume && n.hasRsync(ctx) {
+		result.Merge(n.RunRsync(ctx, srcs, dst, makeSubdir), "[cp] rsync %d files", len(srcs))
+	} else {
+		result.Merge(n.RunCopy(ctx, srcs, dst, makeSubdir), "[cp] copy %d files", len(srcs))
+	}
+	if result.err != nil || !flagVerify {
+		return result
+	}
+	if makeSubdir && !strings.HasPrefix(dst, ":") {
+		dst = path.Join(dst, n.Name)
+	}
+	result.Merge(n.verifyCopy(ctx, srcs, dst), "[cp] verify checksums")
+	return result
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	sumA, sumB := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	out := "Warning: Permanently added 'compute.123' (ECDSA) to the list of known hosts.\r\n" +
+		sumA + "  dir//a.txt\r\n" +
+		sumB + " *b bin\n" +
+		"find: 'missing': No such file or directory\n"
+	got := parseChecksums(out)
+	want := map[string]string{"dir/a.txt": sumA, "b bin": sumB}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCopyDsts(t *testing.T) {
+	tests := []struct {
+		src, file, dst string
+		onlySrc        bool
+		want           []string
+	}{
+		{"a.txt", "a.txt", "tmp", true, []string{"tmp/a.txt", "tmp"}},
+		{"a.txt", "a.txt", "tmp", false, []string{"tmp/a.txt"}},
+		{"out/logs", "out/logs/x/y.log", "tmp", true, []string{"tmp/logs/x/y.log", "tmp/x/y.log"}},
+		{"out/logs", "out/logs/x/y.log", "tmp", false, []string{"tmp/logs/x/y.log"}},
+	}
+	for _, test := range tests {
+		if got := copyDsts(test.src, test.file, test.dst, test.onlySrc); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("copyDsts(%q, %q, %q, %v): got %v, want %v", test.src, test.file, test.dst, test.onlySrc, got, test.want)
+		}
+	}
+}
+
+func TestMismatchedFiles(t *testing.T) {
+	files := []copiedFile{
+		{src: "a", dsts: []string{"tmp/a", "tmp"}, sum: "1"},
+		{src: "b", dsts: []string{"tmp/b"}, sum: "2"},
+		{src: "c", dsts: []string{"tmp/c", "tmp2"}, sum: "3"},
+		{src: "d", dsts: []string{"tmp/d"}, sum: "4"},
+	}
+	dstSums := map[string]string{
+		// a was copied to its second candidate.
+		"tmp": "1",
+		// b was truncated.
+		"tmp/b": "0",
+		// c was copied to its second candidate, but truncated.
+		"tmp2": "0",
+		// d is missing.
+	}
+	got := mismatchedFiles(files, dstSums)
+	want := []copiedFile{
+		{src: "b", dsts: []string{"tmp/b"}, sum: "2"},
+		{src: "c", dsts: []string{"tmp2"}, sum: "3"},
+		{src: "d", dsts: []string{"tmp/d"}, sum: "4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLocalChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcloud-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "hello"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := localChecksums([]string{dir, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		filepath.Join(dir, "sub", "hello"): "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestChecksumCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcloud-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "it's $HOME; a dir")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sub, "hello"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("sh", "-c", quoteForCommand(checksumCommand([]string{sub, filepath.Join(dir, "missing")}))).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	want := map[string]string{
+		filepath.Join(sub, "hello"): "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+	}
+	if got := parseChecksums(string(out)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
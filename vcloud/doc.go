@@ -13,12 +13,16 @@ Usage:
    vcloud [flags] <command>
 
 The vcloud commands are:
-   list        List GCE node information
-   cp          Copy files to or from GCE nodes
-   node        Manage GCE nodes
-   run         Copy files to GCE nodes and run
-   sh          Start a shell or run a command on GCE nodes
-   help        Display help for commands or topics
+   list            List GCE node information
+   cp              Copy files to or from GCE nodes
+   fetch           Fetch files matching a glob from GCE nodes
+   node            Manage GCE nodes
+   run             Copy files to GCE nodes and run
+   sh              Start a shell or run a command on GCE nodes
+   status          Run health checks on GCE nodes
+   reboot          Reboot GCE nodes
+   restart-service Restart services on GCE nodes
+   help            Display help for commands or topics
 
 The vcloud flags are:
  -color=true
@@ -40,6 +44,10 @@ Vcloud list - List GCE node information
 
 List GCE node information.  Runs 'gcloud compute instances list'.
 
+The information is printed as a table by default, or as a JSON array of objects
+if -format=json is specified.  The JSON objects contain all node information,
+including labels and creation time, regardless of -fields.
+
 Usage:
    vcloud list [flags] [nodes]
 
@@ -47,15 +55,28 @@ Usage:
 expression, with matches performed on the full node name.  We select nodes that
 match any of the regexps.  The comma-separated list allows you to easily specify
 a list of specific node names, without using regexp alternation.  We assume node
-names do not have embedded commas.
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
 
 If [nodes] is not provided, lists information for all nodes.
 
 The vcloud list flags are:
  -fields=
    Only display these fields, specified as comma-separated column header names.
+ -format=table
+   Output format, either 'table' or 'json'.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
  -noheader=false
    Don't print list table header.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
 
  -color=true
    Use color to format output.
@@ -67,6 +88,14 @@ Vcloud cp - Copy files to or from GCE nodes
 Copy files to GCE node(s).  Runs 'gcloud compute copy-files'.  The default is to
 copy to/from all nodes in parallel.
 
+If -resume is specified and rsync is available both locally and on a node, the
+files are copied with rsync over ssh instead, keeping partially copied files so
+that running the same copy again resumes where it left off.
+
+If -verify is specified, the SHA-256 checksums of the copied files are compared
+with those of the source files, computed with sha256sum on the nodes, and files
+that are missing or don't match are copied again.
+
 Usage:
    vcloud cp [flags] <nodes> <src...> <dst>
 
@@ -74,7 +103,8 @@ Usage:
 expression, with matches performed on the full node name.  We select nodes that
 match any of the regexps.  The comma-separated list allows you to easily specify
 a list of specific node names, without using regexp alternation.  We assume node
-names do not have embedded commas.
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
 
 <src...> are the source file argument(s) to 'gcloud compute copy-files', and
 <dst> is the destination.  The syntax for each file is:
@@ -98,12 +128,85 @@ E.g. if <nodes> matches A, B and C:
 The vcloud cp flags are:
  -failfast=false
    Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
  -p=-1
    Copy to/from this many nodes in parallel.
      <0   means all nodes in parallel
       0,1 means sequentially
       2+  means at most this many nodes in parallel
 
+ -resume=false
+   Copy with rsync, if it is available locally and on the node, so that
+   interrupted copies resume where they left off.
+ -verify=false
+   Verify the SHA-256 checksums of the copied files, and copy mismatched files
+   again.
+ -verify-retries=2
+   Copy mismatched files again this many times before failing; only relevant
+   with -verify.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Vcloud fetch - Fetch files matching a glob from GCE nodes
+
+Fetch the files matching a glob from GCE node(s).  The glob is expanded by the
+shell on each node, and the matching files are copied with 'gcloud compute
+copy-files' into a sub directory of <localdir> named after the node.  Nodes
+without any matching files are reported, but are not considered failures.  The
+default is to fetch from all nodes in parallel.
+
+Usage:
+   vcloud fetch [flags] <nodes> <remote-glob> <localdir>
+
+<nodes> is a comma-separated list of node name(s).  Each node name is a regular
+expression, with matches performed on the full node name.  We select nodes that
+match any of the regexps.  The comma-separated list allows you to easily specify
+a list of specific node names, without using regexp alternation.  We assume node
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
+
+<remote-glob> is a shell glob identifying the remote files to fetch, e.g.
+/tmp/test-logs/*.log.  Quote the glob to prevent the local shell from expanding
+it.
+
+<localdir> is the local directory to fetch the files into.
+
+E.g. if <nodes> matches A and B:
+  // Copies {A,B}:/tmp/test-logs/*.log to logs/{A,B} respectively.
+  vcloud fetch A,B '/tmp/test-logs/*.log' logs
+
+The vcloud fetch flags are:
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -p=-1
+   Fetch from this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
+
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
  -color=true
    Use color to format output.
  -v=false
@@ -166,7 +269,10 @@ The vcloud node deauthorize flags are:
 
 Vcloud node create - Create GCE nodes
 
-Create GCE nodes. Runs 'gcloud compute instances create'.
+Create GCE nodes.  Runs 'gcloud compute instances create' for each node, waits
+until all the nodes are RUNNING and accept SSH connections, and then runs the
+-setup-script (if any) on each node.  The default is to create all nodes in
+parallel.
 
 Usage:
    vcloud node create [flags] <names>
@@ -176,10 +282,19 @@ Usage:
 The vcloud node create flags are:
  -boot-disk-size=500GB
    Size of the machine boot disk.
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
  -image=ubuntu-14-04
    Image to create the machine from.
  -machine-type=n1-standard-8
    Machine type to create.
+ -metadata=
+   Metadata to set on the machine, specified as comma-separated KEY=VALUE pairs.
+ -p=-1
+   Create this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
  -scopes=storage-full,logging-write
    Scopes of the machine.
  -setup-script=
@@ -194,7 +309,8 @@ The vcloud node create flags are:
 
 Vcloud node delete - Delete GCE nodes
 
-Delete GCE nodes. Runs 'gcloud compute instances delete'.
+Delete GCE nodes.  Runs 'gcloud compute instances delete' for each node.  The
+default is to delete all nodes in parallel.
 
 Usage:
    vcloud node delete [flags] <names>
@@ -202,6 +318,13 @@ Usage:
 <names> is a list of names identifying nodes to be deleted.
 
 The vcloud node delete flags are:
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
+ -p=-1
+   Delete this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
  -zone=us-central1-f
    Zone to delete the machine in.
 
@@ -221,7 +344,8 @@ Usage:
 expression, with matches performed on the full node name.  We select nodes that
 match any of the regexps.  The comma-separated list allows you to easily specify
 a list of specific node names, without using regexp alternation.  We assume node
-names do not have embedded commas.
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
 
 <files...> are the local source file argument(s) to copy to each matching node.
 
@@ -239,9 +363,26 @@ We run the following logic on each matching node, in parallel by default:
      the node to the local -outdir.
   6) Delete TMPDIR.
 
+The output of each node is printed once the node is done, unless -stream is
+specified, in which case the output of the command in step 4 is printed as it is
+produced, with each line prefixed by the node name.  If -logdir is specified,
+the output of the command in step 4 is also written to <logdir>/<node>.log.
+
 The vcloud run flags are:
  -failfast=false
    Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -logdir=
+   Local directory to also write the output of the command on each node to, in
+   files named <node>.log.
+ -multiplex=true
+   Share a persistent SSH connection to each node across commands.
  -outdir=
    Output directory to store results from each node.
  -p=-1
@@ -250,6 +391,16 @@ The vcloud run flags are:
       0,1 means sequentially
       2+  means at most this many nodes in parallel
 
+ -retries=0
+   Retry commands this many times, with exponential backoff, if the SSH
+   connection to a node fails.
+ -stream=false
+   Stream the output of the command on each node as it is produced, prefixing
+   each line with the node name.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
  -color=true
    Use color to format output.
  -v=false
@@ -266,7 +417,8 @@ Usage:
 expression, with matches performed on the full node name.  We select nodes that
 match any of the regexps.  The comma-separated list allows you to easily specify
 a list of specific node names, without using regexp alternation.  We assume node
-names do not have embedded commas.
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
 
 [command...] is the shell command line to run on each node.  Specify the entire
 command line without extra quoting, e.g. like this:
@@ -284,12 +436,187 @@ The default is to run on all nodes in parallel.
 The vcloud sh flags are:
  -failfast=false
    Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -multiplex=true
+   Share a persistent SSH connection to each node across commands.
  -p=-1
    Run command on this many nodes in parallel.
      <0   means all nodes in parallel
       0,1 means sequentially
       2+  means at most this many nodes in parallel
 
+ -retries=0
+   Retry commands this many times, with exponential backoff, if the SSH
+   connection to a node fails.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Vcloud status - Run health checks on GCE nodes
+
+Run quick health checks on GCE node(s) and print a PASS/FAIL table with a row
+per node, followed by the details of the failed checks.  A JSON array with the
+results of all checks on each node is printed instead if -format=json is
+specified.  The default is to check all nodes in parallel.
+
+The available checks are:
+  uptime  - the uptime of the node can be read
+  disk    - the root file system has at least -min-disk-free percent free
+  jenkins - a process matching -jenkins-agent is running
+  clock   - the clock of the node is off by at most -max-clock-skew
+
+Usage:
+   vcloud status [flags] <nodes>
+
+<nodes> is a comma-separated list of node name(s).  Each node name is a regular
+expression, with matches performed on the full node name.  We select nodes that
+match any of the regexps.  The comma-separated list allows you to easily specify
+a list of specific node names, without using regexp alternation.  We assume node
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
+
+The vcloud status flags are:
+ -checks=uptime,disk,jenkins,clock
+   Comma-separated list of the checks to run.
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
+ -format=table
+   Output format, either 'table' or 'json'.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -jenkins-agent=slave.jar
+   Pattern that identifies the command line of the Jenkins agent process.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -max-clock-skew=5s
+   Maximum allowed difference between the clocks of the nodes and the local
+   clock.
+ -min-disk-free=10
+   Minimum percentage of free space on the root file system of the nodes.
+ -p=-1
+   Check this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
+
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Vcloud reboot - Reboot GCE nodes
+
+Reboot GCE node(s).  Runs 'gcloud compute instances reset' for each node, and
+then waits until the node accepts SSH connections again and reports an uptime
+that is shorter than the time since the reset, which shows that the node came
+back.  The -verify=false flag skips the wait.  The default is to reboot all
+nodes in parallel.
+
+Usage:
+   vcloud reboot [flags] <nodes>
+
+<nodes> is a comma-separated list of node name(s).  Each node name is a regular
+expression, with matches performed on the full node name.  We select nodes that
+match any of the regexps.  The comma-separated list allows you to easily specify
+a list of specific node names, without using regexp alternation.  We assume node
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
+
+The vcloud reboot flags are:
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -p=-1
+   Reboot this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
+
+ -verify=true
+   Wait until the nodes are healthy again, and fail if they are not within
+   -verify-timeout.
+ -verify-timeout=5m0s
+   How long to wait for the nodes to be healthy again; only relevant with
+   -verify.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
+ -color=true
+   Use color to format output.
+ -v=false
+   Print verbose output.
+
+Vcloud restart-service - Restart services on GCE nodes
+
+Restart systemd service(s) on GCE node(s).  Runs 'sudo systemctl restart' on
+each node, and then waits until 'systemctl is-active' reports that all the
+services are active.  The -verify=false flag skips the wait.  The default is to
+restart the services on all nodes in parallel.
+
+Usage:
+   vcloud restart-service [flags] <nodes> <services...>
+
+<nodes> is a comma-separated list of node name(s).  Each node name is a regular
+expression, with matches performed on the full node name.  We select nodes that
+match any of the regexps.  The comma-separated list allows you to easily specify
+a list of specific node names, without using regexp alternation.  We assume node
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
+
+<services...> are the names of the systemd services to restart, e.g.
+jenkins-agent or nginx.
+
+The vcloud restart-service flags are:
+ -failfast=false
+   Skip unstarted nodes after the first failing node.
+ -group=
+   Only select nodes that are members of any of these managed instance groups,
+   specified as a comma-separated list of group names.
+ -label=
+   Only select nodes with all of these labels, specified as comma-separated
+   KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label,
+   regardless of its value.
+ -p=-1
+   Restart services on this many nodes in parallel.
+     <0   means all nodes in parallel
+      0,1 means sequentially
+      2+  means at most this many nodes in parallel
+
+ -verify=true
+   Wait until the nodes are healthy again, and fail if they are not within
+   -verify-timeout.
+ -verify-timeout=5m0s
+   How long to wait for the nodes to be healthy again; only relevant with
+   -verify.
+ -zone=
+   Only select nodes in these zones, specified as comma-separated glob patterns,
+   e.g. us-central1-*.
+
  -color=true
    Use color to format output.
  -v=false
@@ -0,0 +1,233 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"v.io/jiri/tool"
+	"v.io/x/lib/cmdline"
+)
+
+var (
+	flagFilterZones  string
+	flagFilterLabels string
+	flagFilterGroups string
+)
+
+func init() {
+	for _, cmd := range []*cmdline.Command{cmdList, cmdCP, cmdFetch, cmdSH, cmdCopyAndRun, cmdStatus, cmdReboot, cmdRestartService} {
+		cmd.Flags.StringVar(&flagFilterZones, "zone", "", "Only select nodes in these zones, specified as comma-separated glob patterns, e.g. us-central1-*.")
+		cmd.Flags.StringVar(&flagFilterLabels, "label", "", "Only select nodes with all of these labels, specified as comma-separated KEY=VALUE pairs.  A KEY without a VALUE selects nodes that have the label, regardless of its value.")
+		cmd.Flags.StringVar(&flagFilterGroups, "group", "", "Only select nodes that are members of any of these managed instance groups, specified as a comma-separated list of group names.")
+	}
+}
+
+// nodeFilter selects nodes by zone, label and managed instance group.
+type nodeFilter struct {
+	// zones holds glob patterns that match the zones of the selected
+	// nodes.
+	zones []string
+	// labels holds the labels of the selected nodes.  An empty value
+	// matches any value of the label.
+	labels map[string]string
+	// members holds the names of the members of the selected managed
+	// instance groups, or nil if nodes are not selected by group.
+	members map[string]bool
+}
+
+// parseNodeFilter parses the given comma-separated lists of zone
+// patterns and labels into a nodeFilter.
+func parseNodeFilter(zones, labels string) (nodeFilter, error) {
+	var filter nodeFilter
+	for _, zone := range strings.Split(zones, ",") {
+		if zone = strings.TrimSpace(zone); zone == "" {
+			continue
+		}
+		if _, err := path.Match(zone, ""); err != nil {
+			return nodeFilter{}, fmt.Errorf("invalid zone pattern %q: %v", zone, err)
+		}
+		filter.zones = append(filter.zones, zone)
+	}
+	for _, label := range strings.Split(labels, ",") {
+		if label = strings.TrimSpace(label); label == "" {
+			continue
+		}
+		if filter.labels == nil {
+			filter.labels = map[string]string{}
+		}
+		kv := strings.SplitN(label, "=", 2)
+		if kv[0] == "" {
+			return nodeFilter{}, fmt.Errorf("invalid label %q", label)
+		}
+		if len(kv) == 1 {
+			filter.labels[kv[0]] = ""
+		} else {
+			filter.labels[kv[0]] = kv[1]
+		}
+	}
+	return filter, nil
+}
+
+// empty returns true iff the filter selects all nodes.
+func (f nodeFilter) empty() bool {
+	return len(f.zones) == 0 && len(f.labels) == 0 && f.members == nil
+}
+
+// match returns true iff the filter selects node n.
+func (f nodeFilter) match(n nodeInfo) bool {
+	if len(f.zones) > 0 {
+		matched := false
+		for _, zone := range f.zones {
+			if ok, _ := path.Match(zone, n.Zone); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for key, value := range f.labels {
+		if nodeValue, ok := n.Labels[key]; !ok || (value != "" && nodeValue != value) {
+			return false
+		}
+	}
+	if f.members != nil && !f.members[n.Name] {
+		return false
+	}
+	return true
+}
+
+// Filter returns all nodes in x that are selected by f.
+func (x nodeInfos) Filter(f nodeFilter) nodeInfos {
+	var ret nodeInfos
+	for _, node := range x {
+		if f.match(node) {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+// filterNodes returns the nodes in x that are selected by the -zone,
+// -label and -group flags.  Managed instance groups are resolved with
+// extra gcloud queries.
+func filterNodes(ctx *tool.Context, x nodeInfos) (nodeInfos, error) {
+	filter, err := parseNodeFilter(flagFilterZones, flagFilterLabels)
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	for _, group := range strings.Split(flagFilterGroups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) > 0 {
+		if filter.members, err = listGroupMembers(ctx, groups); err != nil {
+			return nil, err
+		}
+	}
+	if filter.empty() {
+		return x, nil
+	}
+	ret := x.Filter(filter)
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no nodes match the -zone, -label and -group flags")
+	}
+	return ret, nil
+}
+
+// instanceGroup identifies a managed instance group, which is either zonal
+// or regional.
+type instanceGroup struct {
+	Name   string
+	Zone   string
+	Region string
+}
+
+// parseInstanceGroups parses the JSON output of 'gcloud compute
+// instance-groups managed list --format=json'.  Zones and regions are
+// reported by their names rather than their resource URLs.
+func parseInstanceGroups(data []byte) (map[string]instanceGroup, error) {
+	var groups []instanceGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("Unmarshal() failed: %v", err)
+	}
+	ret := map[string]instanceGroup{}
+	for _, group := range groups {
+		if group.Zone != "" {
+			group.Zone = path.Base(group.Zone)
+		}
+		if group.Region != "" {
+			group.Region = path.Base(group.Region)
+		}
+		ret[group.Name] = group
+	}
+	return ret, nil
+}
+
+// parseGroupInstances parses the JSON output of 'gcloud compute
+// instance-groups managed list-instances --format=json' into the names of
+// the instances.
+func parseGroupInstances(data []byte) ([]string, error) {
+	var instances []struct {
+		Instance string
+	}
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return nil, fmt.Errorf("Unmarshal() failed: %v", err)
+	}
+	var names []string
+	for _, instance := range instances {
+		names = append(names, path.Base(instance.Instance))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// listGroupMembers returns the names of the members of the given managed
+// instance groups.
+func listGroupMembers(ctx *tool.Context, groups []string) (map[string]bool, error) {
+	var stdout bytes.Buffer
+	if err := ctx.NewSeq().Read(nil).Capture(&stdout, ctx.Stderr()).
+		Last("gcloud", "-q", "compute", "instance-groups", "managed", "list", "--project", *flagProject, "--format=json"); err != nil {
+		return nil, err
+	}
+	all, err := parseInstanceGroups(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	members := map[string]bool{}
+	for _, name := range groups {
+		group, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown managed instance group %q", name)
+		}
+		args := []string{"-q", "compute", "instance-groups", "managed", "list-instances", name, "--project", *flagProject, "--format=json"}
+		if group.Zone != "" {
+			args = append(args, "--zone", group.Zone)
+		} else {
+			args = append(args, "--region", group.Region)
+		}
+		stdout.Reset()
+		if err := ctx.NewSeq().Read(nil).Capture(&stdout, ctx.Stderr()).Last("gcloud", args...); err != nil {
+			return nil, err
+		}
+		names, err := parseGroupInstances(stdout.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			members[name] = true
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeFilter(t *testing.T) {
+	nodes := nodeInfos{
+		{Name: "jenkins-node01", Zone: "us-central1-f", Labels: map[string]string{"role": "jenkins-slave", "os": "linux"}},
+		{Name: "jenkins-node02", Zone: "us-east1-b", Labels: map[string]string{"role": "jenkins-slave"}},
+		{Name: "jenkins-master", Zone: "us-central1-c", Labels: map[string]string{"role": "jenkins-master"}},
+		{Name: "internal-node", Zone: "us-central1-f"},
+	}
+	tests := []struct {
+		zones, labels string
+		members       map[string]bool
+		want          []string
+	}{
+		{"", "", nil, []string{"jenkins-node01", "jenkins-node02", "jenkins-master", "internal-node"}},
+		{"us-central1-*", "", nil, []string{"jenkins-node01", "jenkins-master", "internal-node"}},
+		{"us-east1-*,us-central1-c", "", nil, []string{"jenkins-node02", "jenkins-master"}},
+		{"", "role=jenkins-slave", nil, []string{"jenkins-node01", "jenkins-node02"}},
+		{"", "role", nil, []string{"jenkins-node01", "jenkins-node02", "jenkins-master"}},
+		{"", "role=jenkins-slave,os=linux", nil, []string{"jenkins-node01"}},
+		{"us-central1-*", "role", map[string]bool{"jenkins-master": true, "jenkins-node02": true}, []string{"jenkins-master"}},
+		{"europe-*", "", nil, nil},
+	}
+	for _, test := range tests {
+		filter, err := parseNodeFilter(test.zones, test.labels)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		filter.members = test.members
+		if got := nodes.Filter(filter).Names(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("zones %q, labels %q, members %v: want %v, got %v", test.zones, test.labels, test.members, test.want, got)
+		}
+	}
+	for _, test := range []struct{ zones, labels string }{{"us-[", ""}, {"", "=jenkins"}} {
+		if _, err := parseNodeFilter(test.zones, test.labels); err == nil {
+			t.Errorf("zones %q, labels %q: parsing did not fail", test.zones, test.labels)
+		}
+	}
+}
+
+func TestParseInstanceGroups(t *testing.T) {
+	groups, err := parseInstanceGroups([]byte(`[
+  {"name": "jenkins-slaves", "zone": "https://www.googleapis.com/compute/v1/projects/vanadium-internal/zones/us-central1-f"},
+  {"name": "benchmarks", "region": "us-east1"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := map[string]instanceGroup{
+		"jenkins-slaves": {Name: "jenkins-slaves", Zone: "us-central1-f"},
+		"benchmarks":     {Name: "benchmarks", Region: "us-east1"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("want %v, got %v", want, groups)
+	}
+	names, err := parseGroupInstances([]byte(`[
+  {"instance": "https://www.googleapis.com/compute/v1/projects/vanadium-internal/zones/us-central1-f/instances/jenkins-node02", "instanceStatus": "RUNNING"},
+  {"instance": "https://www.googleapis.com/compute/v1/projects/vanadium-internal/zones/us-central1-f/instances/jenkins-node01", "instanceStatus": "RUNNING"}
+]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []string{"jenkins-node01", "jenkins-node02"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("want %v, got %v", want, names)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"v.io/jiri/tool"
 	"v.io/x/lib/cmdline"
 )
 
@@ -174,7 +175,10 @@ var cmdNodeCreate = &cmdline.Command{
 	Name:   "create",
 	Short:  "Create GCE nodes",
 	Long: `
-Create GCE nodes. Runs 'gcloud compute instances create'.
+Create GCE nodes.  Runs 'gcloud compute instances create' for each node, waits
+until all the nodes are RUNNING and accept SSH connections, and then runs the
+-setup-script (if any) on each node.  The default is to create all nodes in
+parallel.
 `,
 	ArgsName: "<names>",
 	ArgsLong: "<names> is a list of names identifying nodes to be created.",
@@ -185,64 +189,136 @@ var cmdNodeDelete = &cmdline.Command{
 	Name:   "delete",
 	Short:  "Delete GCE nodes",
 	Long: `
-Delete GCE nodes. Runs 'gcloud compute instances delete'.
+Delete GCE nodes.  Runs 'gcloud compute instances delete' for each node.  The
+default is to delete all nodes in parallel.
 `,
 	ArgsName: "<names>",
 	ArgsLong: "<names> is a list of names identifying nodes to be deleted.",
 }
 
-func runNodeCreate(env *cmdline.Env, args []string) error {
-	ctx := newContext(env)
+const (
+	// numCreateRetries and createRetryPeriod determine how long to wait for
+	// created nodes to be RUNNING and to accept SSH connections.
+	numCreateRetries  = 20
+	createRetryPeriod = 5 * time.Second
+)
 
-	// Create the GCE node(s).
-	createArgs := []string{
+// newNodeInfos returns the nodes with the given names in the given zone.
+func newNodeInfos(names []string, zone string) nodeInfos {
+	var ret nodeInfos
+	for _, name := range names {
+		ret = append(ret, nodeInfo{Name: name, Zone: zone})
+	}
+	return ret
+}
+
+// createArgs returns the 'gcloud' arguments that create node n.
+func (n nodeInfo) createArgs() []string {
+	args := []string{
 		"compute",
 		"--project", *flagProject,
 		"instances",
 		"create",
-	}
-	createArgs = append(createArgs, args...)
-	createArgs = append(createArgs,
+		n.Name,
 		"--boot-disk-size", flagBootDiskSize,
 		"--image", flagImage,
 		"--machine-type", flagMachineType,
-		"--zone", flagZone,
+		"--zone", n.Zone,
 		"--scopes", flagScopes,
-	)
-	if err := ctx.NewSeq().Last("gcloud", createArgs...); err != nil {
-		return err
 	}
+	if flagMetadata != "" {
+		args = append(args, "--metadata", flagMetadata)
+	}
+	return args
+}
 
-	// Create in-memory representation of node information.
-	allNodes, err := listAll(ctx)
-	if err != nil {
-		return err
+// Create creates node n.
+func (n nodeInfo) Create(ctx *tool.Context) runResult {
+	var stdouterr bytes.Buffer
+	err := ctx.NewSeq().Read(nil).Capture(&stdouterr, &stdouterr).
+		Last("gcloud", n.createArgs()...)
+	return runResult{node: n, out: stdouterr.String(), err: err}
+}
+
+// Delete deletes node n.
+func (n nodeInfo) Delete(ctx *tool.Context) runResult {
+	var stdouterr bytes.Buffer
+	err := ctx.NewSeq().Read(nil).Capture(&stdouterr, &stdouterr).
+		Last("gcloud", "-q",
+			"compute",
+			"--project", *flagProject,
+			"instances",
+			"delete",
+			n.Name,
+			"--zone", n.Zone,
+		)
+	return runResult{node: n, out: stdouterr.String(), err: err}
+}
+
+// WaitForSSH waits until node n accepts SSH connections.
+func (n nodeInfo) WaitForSSH(ctx *tool.Context, user string) runResult {
+	result := runResult{node: n}
+	for i := 0; i < numCreateRetries; i++ {
+		r := n.RunCommand(ctx, user, []string{"echo"})
+		if r.err == nil {
+			result.err = nil
+			return result
+		}
+		result.Merge(r, "[create] attempt #%d to connect failed", i+1)
+		time.Sleep(createRetryPeriod)
 	}
-	nodes, err := allNodes.MatchNames(strings.Join(args, ","))
-	if err != nil {
+	return result
+}
+
+// waitUntilRunning waits until all nodes with the given names are listed
+// with the RUNNING status, and returns the listed nodes.
+func waitUntilRunning(ctx *tool.Context, names []string) (nodeInfos, error) {
+	for i := 0; i < numCreateRetries; i++ {
+		allNodes, err := listAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err := allNodes.MatchNames(strings.Join(names, ","))
+		if err == nil && len(nodes) == len(names) {
+			running := true
+			for _, node := range nodes {
+				running = running && node.Status == "RUNNING"
+			}
+			if running {
+				return nodes, nil
+			}
+		}
+		fmt.Fprintf(ctx.Stdout(), "waiting for nodes %v to be RUNNING\n", names)
+		time.Sleep(createRetryPeriod)
+	}
+	return nil, fmt.Errorf("timed out waiting for nodes %v to be RUNNING", names)
+}
+
+func runNodeCreate(env *cmdline.Env, args []string) error {
+	if len(args) == 0 {
+		return env.UsageErrorf("no node name(s) specified")
+	}
+	ctx := newContext(env)
+
+	// Create the GCE node(s).
+	fn := func(node nodeInfo) runResult { return node.Create(ctx) }
+	if err := newNodeInfos(args, flagZone).run(ctx.Stdout(), fn); err != nil {
 		return err
 	}
 
-	// Wait for the SSH server on all nodes to start up.
-	const numRetries = 10
-	const retryPeriod = 5 * time.Second
-	ready := false
-	for i := 0; i < numRetries; i++ {
-		if err := nodes.RunCommand(ctx, *flagUser, []string{"echo"}); err != nil {
-			fmt.Fprintf(ctx.Stdout(), "attempt #%d to connect failed, will try again later\n", i+1)
-			time.Sleep(retryPeriod)
-			continue
-		}
-		ready = true
-		break
+	// Wait for all nodes to be RUNNING and for their SSH servers to start up.
+	nodes, err := waitUntilRunning(ctx, args)
+	if err != nil {
+		return err
 	}
-	if !ready {
-		return fmt.Errorf("timed out waiting for nodes to start")
+	fn = func(node nodeInfo) runResult { return node.WaitForSSH(ctx, *flagUser) }
+	if err := nodes.run(ctx.Stdout(), fn); err != nil {
+		return err
 	}
 
 	// Execute the setup script.
 	if flagSetupScript != "" {
-		if err := nodes.RunCopyAndRun(ctx, *flagUser, []string{flagSetupScript}, nil, ""); err != nil {
+		if err := nodes.RunCopyAndRun(ctx, *flagUser, []string{flagSetupScript}, nil, "", false, ""); err != nil {
 			return err
 		}
 	}
@@ -251,17 +327,12 @@ func runNodeCreate(env *cmdline.Env, args []string) error {
 }
 
 func runNodeDelete(env *cmdline.Env, args []string) error {
+	if len(args) == 0 {
+		return env.UsageErrorf("no node name(s) specified")
+	}
 	ctx := newContext(env)
+
 	// Delete the GCE node(s).
-	var in bytes.Buffer
-	in.WriteString("Y\n") // answers the [Y/n] prompt
-	deleteArgs := []string{
-		"compute",
-		"--project", *flagProject,
-		"instances",
-		"delete",
-	}
-	deleteArgs = append(deleteArgs, args...)
-	deleteArgs = append(deleteArgs, "--zone", flagZone)
-	return ctx.NewSeq().Read(&in).Last("gcloud", deleteArgs...)
+	fn := func(node nodeInfo) runResult { return node.Delete(ctx) }
+	return newNodeInfos(args, flagZone).run(ctx.Stdout(), fn)
 }
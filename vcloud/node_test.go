@@ -6,6 +6,7 @@ package main
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -94,3 +95,29 @@ func TestParseUserAndHost(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateArgs(t *testing.T) {
+	defer func(metadata string) { flagMetadata = metadata }(flagMetadata)
+	node := newNodeInfos([]string{"jenkins-node01"}, "us-central1-c")[0]
+	want := []string{
+		"compute",
+		"--project", *flagProject,
+		"instances",
+		"create",
+		"jenkins-node01",
+		"--boot-disk-size", flagBootDiskSize,
+		"--image", flagImage,
+		"--machine-type", flagMachineType,
+		"--zone", "us-central1-c",
+		"--scopes", flagScopes,
+	}
+	flagMetadata = ""
+	if got := node.createArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	flagMetadata = "role=jenkins,owner=vanadium"
+	want = append(want, "--metadata", "role=jenkins,owner=vanadium")
+	if got := node.createArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
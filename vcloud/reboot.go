@@ -0,0 +1,226 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"v.io/jiri/tool"
+	"v.io/x/lib/cmdline"
+)
+
+var cmdReboot = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runReboot),
+	Name:   "reboot",
+	Short:  "Reboot GCE nodes",
+	Long: `
+Reboot GCE node(s).  Runs 'gcloud compute instances reset' for each node, and
+then waits until the node accepts SSH connections again and reports an uptime
+that is shorter than the time since the reset, which shows that the node came
+back.  The -verify=false flag skips the wait.  The default is to reboot all
+nodes in parallel.
+`,
+	ArgsName: "<nodes>",
+	ArgsLong: "<nodes> " + nodesDesc,
+}
+
+var cmdRestartService = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runRestartService),
+	Name:   "restart-service",
+	Short:  "Restart services on GCE nodes",
+	Long: `
+Restart systemd service(s) on GCE node(s).  Runs 'sudo systemctl restart' on
+each node, and then waits until 'systemctl is-active' reports that all the
+services are active.  The -verify=false flag skips the wait.  The default is to
+restart the services on all nodes in parallel.
+`,
+	ArgsName: "<nodes> <services...>",
+	ArgsLong: "<nodes> " + nodesDesc + `
+<services...> are the names of the systemd services to restart, e.g.
+jenkins-agent or nginx.
+`,
+}
+
+var (
+	flagVerifyHealth  bool
+	flagVerifyTimeout time.Duration
+)
+
+func init() {
+	cmdReboot.Flags.IntVar(&flagP, "p", -1, "Reboot this many nodes in parallel."+parallelDesc)
+	cmdRestartService.Flags.IntVar(&flagP, "p", -1, "Restart services on this many nodes in parallel."+parallelDesc)
+	for _, cmd := range []*cmdline.Command{cmdReboot, cmdRestartService} {
+		cmd.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+		cmd.Flags.BoolVar(&flagVerifyHealth, "verify", true, "Wait until the nodes are healthy again, and fail if they are not within -verify-timeout.")
+		cmd.Flags.DurationVar(&flagVerifyTimeout, "verify-timeout", 5*time.Minute, "How long to wait for the nodes to be healthy again; only relevant with -verify.")
+	}
+}
+
+// verifyRetryPeriod is how long to wait between the health checks of a
+// node that was rebooted or whose services were restarted.
+const verifyRetryPeriod = 5 * time.Second
+
+// Reset resets node n, which reboots it.
+func (n nodeInfo) Reset(ctx *tool.Context) runResult {
+	var stdouterr bytes.Buffer
+	err := ctx.NewSeq().Read(nil).Capture(&stdouterr, &stdouterr).
+		Last("gcloud", n.resetArgs()...)
+	return runResult{node: n, out: stdouterr.String(), err: err}
+}
+
+// resetArgs returns the 'gcloud' arguments that reset node n.
+func (n nodeInfo) resetArgs() []string {
+	return []string{"-q",
+		"compute",
+		"--project", *flagProject,
+		"instances",
+		"reset",
+		n.Name,
+		"--zone", n.Zone,
+	}
+}
+
+// Reboot reboots node n and, if verify is true, waits until it comes
+// back.
+func (n nodeInfo) Reboot(ctx *tool.Context, user string, verify bool, timeout time.Duration) runResult {
+	result := runResult{node: n}
+	start := time.Now()
+	result.Merge(n.Reset(ctx), "[reboot] reset node")
+	if result.err != nil || !verify {
+		return result
+	}
+	check := func() (string, error) {
+		r := n.RunCommand(ctx, user, []string{"cat", "/proc/uptime"})
+		if r.err != nil {
+			return "", r.err
+		}
+		return evalRebooted(r.out, time.Since(start))
+	}
+	result.Merge(n.waitUntilHealthy(check, timeout), "[reboot] wait for node to come back")
+	return result
+}
+
+// evalRebooted evaluates the output of 'cat /proc/uptime' on a node that
+// was reset the given duration ago, and returns an error unless the node
+// rebooted since.
+func evalRebooted(out string, sinceReset time.Duration) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	uptime := time.Duration(seconds) * time.Second
+	if uptime > sinceReset {
+		return "", fmt.Errorf("up %v, but reset %v ago", uptime, sinceReset)
+	}
+	return fmt.Sprintf("up %v", uptime), nil
+}
+
+// serviceNameRE matches the names of systemd services, which are passed
+// to the shell of the nodes unquoted.
+var serviceNameRE = regexp.MustCompile(`^[A-Za-z0-9@._:-]+$`)
+
+// RestartServices restarts the given services on node n and, if verify
+// is true, waits until they are active.
+func (n nodeInfo) RestartServices(ctx *tool.Context, user string, services []string, verify bool, timeout time.Duration) runResult {
+	result := runResult{node: n}
+	restartCmd := append([]string{"sudo", "systemctl", "restart"}, services...)
+	result.Merge(n.RunCommand(ctx, user, restartCmd), "[restart-service] restart %v", services)
+	if result.err != nil || !verify {
+		return result
+	}
+	// 'systemctl is-active' fails unless all the services are active,
+	// which is reported by evalServicesActive instead.
+	activeCmd := append(append([]string{"systemctl", "is-active"}, services...), "||", "true")
+	check := func() (string, error) {
+		r := n.RunCommand(ctx, user, activeCmd)
+		if r.err != nil {
+			return "", r.err
+		}
+		return evalServicesActive(r.out, services)
+	}
+	result.Merge(n.waitUntilHealthy(check, timeout), "[restart-service] wait for %v to be active", services)
+	return result
+}
+
+// evalServicesActive evaluates the output of 'systemctl is-active' for
+// the given services, and returns an error unless all of them are
+// active.
+func evalServicesActive(out string, services []string) (string, error) {
+	states := strings.Fields(out)
+	if len(states) != len(services) {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	var inactive []string
+	for i, state := range states {
+		if state != "active" {
+			inactive = append(inactive, fmt.Sprintf("%s is %s", services[i], state))
+		}
+	}
+	if len(inactive) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(inactive, ", "))
+	}
+	return fmt.Sprintf("%s active", strings.Join(services, ", ")), nil
+}
+
+// waitUntilHealthy runs check on node n until it succeeds or the given
+// timeout elapses, and reports the outcome of the last check.
+func (n nodeInfo) waitUntilHealthy(check func() (string, error), timeout time.Duration) runResult {
+	deadline := time.Now().Add(timeout)
+	for {
+		detail, err := check()
+		if err == nil {
+			return runResult{node: n, out: detail + "\n"}
+		}
+		if time.Now().Add(verifyRetryPeriod).After(deadline) {
+			return runResult{node: n, err: fmt.Errorf("not healthy after %v: %v", timeout, err)}
+		}
+		time.Sleep(verifyRetryPeriod)
+	}
+}
+
+func runReboot(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("need exactly one arg")
+	}
+	ctx := newContext(env)
+	nodes, err := listMatching(ctx, args[0])
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	fn := func(node nodeInfo) runResult {
+		return node.Reboot(ctx, *flagUser, flagVerifyHealth, flagVerifyTimeout)
+	}
+	return nodes.run(ctx.Stdout(), fn)
+}
+
+func runRestartService(env *cmdline.Env, args []string) error {
+	if len(args) < 2 {
+		return env.UsageErrorf("need at least two args")
+	}
+	services := args[1:]
+	for _, service := range services {
+		if !serviceNameRE.MatchString(service) {
+			return env.UsageErrorf("invalid service name %q", service)
+		}
+	}
+	ctx := newContext(env)
+	nodes, err := listMatching(ctx, args[0])
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	fn := func(node nodeInfo) runResult {
+		return node.RestartServices(ctx, *flagUser, services, flagVerifyHealth, flagVerifyTimeout)
+	}
+	return nodes.run(ctx.Stdout(), fn)
+}
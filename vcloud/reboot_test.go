@@ -0,0 +1,62 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResetArgs(t *testing.T) {
+	node := nodeInfo{Name: "jenkins-node01", Zone: "us-central1-c"}
+	want := []string{"-q",
+		"compute",
+		"--project", *flagProject,
+		"instances",
+		"reset",
+		"jenkins-node01",
+		"--zone", "us-central1-c",
+	}
+	if got := node.resetArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestEvalRebooted(t *testing.T) {
+	detail, err := evalRebooted("42.50 160.00\n", time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := detail, "up 42s"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	for _, out := range []string{"90061.50 350000.00\n", "", "up\n"} {
+		if _, err := evalRebooted(out, time.Minute); err == nil {
+			t.Errorf("evaluating %q did not fail", out)
+		}
+	}
+}
+
+func TestEvalServicesActive(t *testing.T) {
+	services := []string{"jenkins-agent", "nginx"}
+	detail, err := evalServicesActive("active\nactive\n", services)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if got, want := detail, "jenkins-agent, nginx active"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	_, err = evalServicesActive("active\nactivating\n", services)
+	if err == nil {
+		t.Fatalf("evaluating an activating service did not fail")
+	}
+	if got, want := err.Error(), "nginx is activating"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if _, err := evalServicesActive("active\n", services); err == nil {
+		t.Errorf("evaluating incomplete output did not fail")
+	}
+}
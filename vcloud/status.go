@@ -0,0 +1,369 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"v.io/jiri/tool"
+	"v.io/x/lib/cmdline"
+)
+
+var cmdStatus = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runStatus),
+	Name:   "status",
+	Short:  "Run health checks on GCE nodes",
+	Long: `
+Run quick health checks on GCE node(s) and print a PASS/FAIL table with a row
+per node, followed by the details of the failed checks.  A JSON array with the
+results of all checks on each node is printed instead if -format=json is
+specified.  The default is to check all nodes in parallel.
+
+The available checks are:
+  uptime  - the uptime of the node can be read
+  disk    - the root file system has at least -min-disk-free percent free
+  jenkins - a process matching -jenkins-agent is running
+  clock   - the clock of the node is off by at most -max-clock-skew
+`,
+	ArgsName: "<nodes>",
+	ArgsLong: "<nodes> " + nodesDesc,
+}
+
+var (
+	flagStatusChecks string
+	flagStatusFormat string
+	flagJenkinsAgent string
+	flagMaxClockSkew time.Duration
+	flagMinDiskFree  int
+)
+
+func init() {
+	cmdStatus.Flags.IntVar(&flagP, "p", -1, "Check this many nodes in parallel."+parallelDesc)
+	cmdStatus.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+	cmdStatus.Flags.StringVar(&flagStatusChecks, "checks", "uptime,disk,jenkins,clock", "Comma-separated list of the checks to run.")
+	cmdStatus.Flags.StringVar(&flagStatusFormat, "format", "table", "Output format, either 'table' or 'json'.")
+	cmdStatus.Flags.StringVar(&flagJenkinsAgent, "jenkins-agent", "slave.jar", "Pattern that identifies the command line of the Jenkins agent process.")
+	cmdStatus.Flags.DurationVar(&flagMaxClockSkew, "max-clock-skew", 5*time.Second, "Maximum allowed difference between the clocks of the nodes and the local clock.")
+	cmdStatus.Flags.IntVar(&flagMinDiskFree, "min-disk-free", 10, "Minimum percentage of free space on the root file system of the nodes.")
+}
+
+// statusCheck describes a health check of a node.
+type statusCheck struct {
+	name string
+	// command is the command line that prints the information checked
+	// on the node.
+	command []string
+	// eval evaluates the output of the command, which ran between start
+	// and end, and returns the details of the outcome, or an error if
+	// the check failed.
+	eval func(out string, start, end time.Time) (string, error)
+}
+
+// statusChecks returns the available health checks.
+func statusChecks() []statusCheck {
+	return []statusCheck{
+		{"uptime", []string{"cat", "/proc/uptime"}, evalUptime},
+		{"disk", []string{"df", "-P", "/"}, evalDiskFree},
+		{"jenkins", []string{"pgrep", "-c", "-f", selfExcludingPattern(flagJenkinsAgent), "||", "true"}, evalJenkinsAgent},
+		{"clock", []string{"date", "+%s.%N"}, evalClockSkew},
+	}
+}
+
+// selfExcludingPattern returns a quoted pgrep pattern that matches the
+// same processes as the given pattern, except for the shell that runs
+// pgrep, whose command line contains the returned pattern.
+func selfExcludingPattern(pattern string) string {
+	if pattern == "" {
+		return pattern
+	}
+	return "'[" + pattern[:1] + "]" + pattern[1:] + "'"
+}
+
+// selectStatusChecks returns the health checks identified by the given
+// comma-separated list of names.
+func selectStatusChecks(names string) ([]statusCheck, error) {
+	available := map[string]statusCheck{}
+	for _, check := range statusChecks() {
+		available[check.name] = check
+	}
+	var checks []statusCheck
+	for _, name := range strings.Split(names, ",") {
+		if name == "" {
+			continue
+		}
+		check, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown check %q", name)
+		}
+		checks = append(checks, check)
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("no checks specified")
+	}
+	return checks, nil
+}
+
+func evalUptime(out string, _, _ time.Time) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	return fmt.Sprintf("up %v", time.Duration(seconds)*time.Second), nil
+}
+
+func evalDiskFree(out string, _, _ time.Time) (string, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 5 {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	used, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err != nil {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	if free := 100 - used; free < flagMinDiskFree {
+		return "", fmt.Errorf("%d%% free on /, want at least %d%%", free, flagMinDiskFree)
+	}
+	return fmt.Sprintf("%d%% free on /", 100-used), nil
+}
+
+func evalJenkinsAgent(out string, _, _ time.Time) (string, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	if count == 0 {
+		return "", fmt.Errorf("no process matching %q", flagJenkinsAgent)
+	}
+	return fmt.Sprintf("%d processes matching %q", count, flagJenkinsAgent), nil
+}
+
+// evalClockSkew compares the time printed by the node with the local
+// time in the middle of the command. Since the time the node printed
+// is only known to be between the start and the end of the command,
+// half of the duration of the command is tolerated in addition to
+// flagMaxClockSkew.
+func evalClockSkew(out string, start, end time.Time) (string, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected output %q", out)
+	}
+	margin := end.Sub(start) / 2
+	remote := time.Unix(0, int64(seconds*float64(time.Second)))
+	skew := remote.Sub(start.Add(margin))
+	detail := fmt.Sprintf("skew %.3fs (+/- %.3fs)", skew.Seconds(), margin.Seconds())
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew-margin > flagMaxClockSkew {
+		return "", fmt.Errorf("%s, want at most %v", detail, flagMaxClockSkew)
+	}
+	return detail, nil
+}
+
+// statusMarker precedes the output of each check in the output of the
+// command run on the nodes.
+const statusMarker = "@@vcloud-status:"
+
+// statusCommand returns the command line that runs the given checks on
+// a node.
+func statusCommand(checks []statusCheck) []string {
+	var cmdline []string
+	for _, check := range checks {
+		cmdline = append(cmdline, "echo", statusMarker+check.name, ";")
+		cmdline = append(cmdline, check.command...)
+		cmdline = append(cmdline, "2>&1", ";")
+	}
+	return cmdline
+}
+
+// parseStatusOutput splits the output of the command returned by
+// statusCommand into the outputs of the individual checks.
+func parseStatusOutput(out string) map[string]string {
+	outputs, name := map[string]string{}, ""
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if strings.HasPrefix(line, statusMarker) {
+			name = strings.TrimSpace(strings.TrimPrefix(line, statusMarker))
+			outputs[name] = ""
+			continue
+		}
+		if name != "" {
+			outputs[name] += line
+		}
+	}
+	return outputs
+}
+
+// checkResult records the outcome of a health check.
+type checkResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// nodeStatus records the outcome of the health checks of a node.
+type nodeStatus struct {
+	Node    string        `json:"node"`
+	Passed  bool          `json:"passed"`
+	Skipped bool          `json:"skipped,omitempty"`
+	Checks  []checkResult `json:"checks"`
+}
+
+// evalStatus evaluates the given checks against the output of the
+// command returned by statusCommand, which ran between start and end.
+// If the command failed, all checks fail with the given error.
+func evalStatus(node string, checks []statusCheck, out string, err error, start, end time.Time) nodeStatus {
+	status := nodeStatus{Node: node, Passed: true}
+	outputs := parseStatusOutput(out)
+	for _, check := range checks {
+		result := checkResult{Name: check.name, Passed: true}
+		checkOut, ok := outputs[check.name]
+		switch {
+		case err != nil:
+			result.Passed, result.Detail = false, err.Error()
+		case !ok:
+			result.Passed, result.Detail = false, "no output"
+		default:
+			if detail, err := check.eval(checkOut, start, end); err != nil {
+				result.Passed, result.Detail = false, err.Error()
+			} else {
+				result.Detail = detail
+			}
+		}
+		status.Passed = status.Passed && result.Passed
+		status.Checks = append(status.Checks, result)
+	}
+	return status
+}
+
+// Status runs the given health checks on all nodes in x, and returns
+// their outcome ordered by node name.
+func (x nodeInfos) Status(ctx *tool.Context, user string, checks []statusCheck) []nodeStatus {
+	var mu sync.Mutex
+	statuses := map[string]nodeStatus{}
+	cmdline := statusCommand(checks)
+	fn := func(node nodeInfo) runResult {
+		start := time.Now()
+		result := node.RunCommand(ctx, user, cmdline)
+		status := evalStatus(node.Name, checks, result.out, result.err, start, time.Now())
+		mu.Lock()
+		statuses[node.Name] = status
+		mu.Unlock()
+		if !status.Passed && result.err == nil {
+			result.err = errors.New("health checks failed")
+		}
+		return result
+	}
+	// The outcome of the checks is reported by the caller, and the
+	// nodes that failed the checks are identified by the statuses.
+	x.run(ioutil.Discard, fn)
+	var result []nodeStatus
+	for _, node := range x {
+		status, ok := statuses[node.Name]
+		if !ok {
+			status = nodeStatus{Node: node.Name, Skipped: true}
+		}
+		result = append(result, status)
+	}
+	sort.Sort(nodeStatuses(result))
+	return result
+}
+
+type nodeStatuses []nodeStatus
+
+func (s nodeStatuses) Len() int           { return len(s) }
+func (s nodeStatuses) Less(i, j int) bool { return s[i].Node < s[j].Node }
+func (s nodeStatuses) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// printStatuses prints the given statuses of the nodes, which ran the
+// given checks, to w in the given format.
+func printStatuses(w io.Writer, format string, checks []statusCheck, statuses []nodeStatus) error {
+	switch format {
+	case "table":
+		fmt.Fprintf(w, "%-18s", infoHeader.Name)
+		for _, check := range checks {
+			fmt.Fprintf(w, " %-8s", check.name)
+		}
+		fmt.Fprintln(w)
+		var details []string
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%-18s", status.Node)
+			if status.Skipped {
+				for range checks {
+					fmt.Fprintf(w, " %-8s", "SKIP")
+				}
+			}
+			for _, result := range status.Checks {
+				outcome := "PASS"
+				if !result.Passed {
+					outcome = "FAIL"
+					details = append(details, fmt.Sprintf("%s %s: %s", status.Node, result.Name, result.Detail))
+				}
+				fmt.Fprintf(w, " %-8s", outcome)
+			}
+			fmt.Fprintln(w)
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(w, "\n%s\n", strings.Join(details, "\n"))
+		}
+		return nil
+	case "json":
+		if statuses == nil {
+			statuses = []nodeStatus{}
+		}
+		bytes, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent() failed: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", bytes)
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func runStatus(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("need exactly one arg")
+	}
+	if flagStatusFormat != "table" && flagStatusFormat != "json" {
+		return env.UsageErrorf("unknown format %q", flagStatusFormat)
+	}
+	checks, err := selectStatusChecks(flagStatusChecks)
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	ctx := newContext(env)
+	nodes, err := listMatching(ctx, args[0])
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	statuses := nodes.Status(ctx, *flagUser, checks)
+	if err := printStatuses(env.Stdout, flagStatusFormat, checks, statuses); err != nil {
+		return err
+	}
+	var failed []string
+	for _, status := range statuses {
+		if !status.Passed {
+			failed = append(failed, status.Node)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d/%d nodes failed health checks: %v", len(failed), len(statuses), failed)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusCommand(t *testing.T) {
+	defer func(agent string) { flagJenkinsAgent = agent }(flagJenkinsAgent)
+	flagJenkinsAgent = "slave.jar"
+	checks, err := selectStatusChecks("disk,jenkins")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := "echo @@vcloud-status:disk ; df -P / 2>&1 ; echo @@vcloud-status:jenkins ; pgrep -c -f '[s]lave.jar' || true 2>&1 ;"
+	if got := quoteForCommand(statusCommand(checks)); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if _, err := selectStatusChecks("disk,memory"); err == nil {
+		t.Errorf("selecting an unknown check did not fail")
+	}
+}
+
+func TestEvalStatus(t *testing.T) {
+	defer func(agent string, skew time.Duration, free int) {
+		flagJenkinsAgent, flagMaxClockSkew, flagMinDiskFree = agent, skew, free
+	}(flagJenkinsAgent, flagMaxClockSkew, flagMinDiskFree)
+	flagJenkinsAgent, flagMaxClockSkew, flagMinDiskFree = "slave.jar", 5*time.Second, 10
+	checks, err := selectStatusChecks("uptime,disk,jenkins,clock")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	start := time.Unix(1460000000, 0)
+	end := start.Add(2 * time.Second)
+	out := `@@vcloud-status:uptime
+90061.50 350000.00
+@@vcloud-status:disk
+Filesystem     1024-blocks      Used Available Capacity Mounted on
+/dev/sda1        507745152 482357888  25387264      95% /
+@@vcloud-status:jenkins
+0
+@@vcloud-status:clock
+1460000004.000000000
+`
+	got := evalStatus("jenkins-node01", checks, out, nil, start, end)
+	want := nodeStatus{
+		Node: "jenkins-node01",
+		Checks: []checkResult{
+			{Name: "uptime", Passed: true, Detail: "up 25h1m1s"},
+			{Name: "disk", Detail: "5% free on /, want at least 10%"},
+			{Name: "jenkins", Detail: `no process matching "slave.jar"`},
+			{Name: "clock", Passed: true, Detail: "skew 3.000s (+/- 1.000s)"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+
+	// Check that all checks fail if the command fails.
+	got = evalStatus("jenkins-node01", checks[:1], "", errors.New("exit status 255"), start, end)
+	want = nodeStatus{
+		Node:   "jenkins-node01",
+		Checks: []checkResult{{Name: "uptime", Detail: "exit status 255"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %#v, got %#v", want, got)
+	}
+}
+
+func TestPrintStatuses(t *testing.T) {
+	checks, err := selectStatusChecks("uptime,jenkins")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	statuses := []nodeStatus{
+		{
+			Node:   "jenkins-node01",
+			Passed: true,
+			Checks: []checkResult{{"uptime", true, "up 1h0m0s"}, {"jenkins", true, `1 processes matching "slave.jar"`}},
+		},
+		{
+			Node:   "jenkins-node02",
+			Checks: []checkResult{{"uptime", true, "up 2h0m0s"}, {"jenkins", false, `no process matching "slave.jar"`}},
+		},
+		{Node: "jenkins-node03", Skipped: true},
+	}
+	var out bytes.Buffer
+	if err := printStatuses(&out, "table", checks, statuses); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := strings.Join([]string{
+		"NAME               uptime   jenkins ",
+		"jenkins-node01     PASS     PASS    ",
+		"jenkins-node02     PASS     FAIL    ",
+		"jenkins-node03     SKIP     SKIP    ",
+		"",
+		`jenkins-node02 jenkins: no process matching "slave.jar"`,
+		"",
+	}, "\n")
+	if got := out.String(); got != want {
+		t.Errorf("want\n%q\ngot\n%q", want, got)
+	}
+
+	out.Reset()
+	if err := printStatuses(&out, "json", checks, statuses); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var got []nodeStatus
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, statuses) {
+		t.Fatalf("want %v, got %v", statuses, got)
+	}
+}
@@ -16,13 +16,17 @@ import (
 	"io"
 	"math/rand"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"v.io/jiri/runutil"
 	"v.io/jiri/tool"
 	"v.io/x/lib/cmdline"
 	"v.io/x/lib/set"
@@ -41,7 +45,7 @@ var cmdVCloud = &cmdline.Command{
 Command vcloud is a wrapper over the Google Compute Engine gcloud tool.  It
 simplifies common usage scenarios and provides some Vanadium-specific support.
 `,
-	Children: []*cmdline.Command{cmdList, cmdCP, cmdNode, cmdCopyAndRun, cmdSH},
+	Children: []*cmdline.Command{cmdList, cmdCP, cmdFetch, cmdNode, cmdCopyAndRun, cmdSH, cmdStatus, cmdReboot, cmdRestartService},
 }
 
 var cmdList = &cmdline.Command{
@@ -50,6 +54,10 @@ var cmdList = &cmdline.Command{
 	Short:  "List GCE node information",
 	Long: `
 List GCE node information.  Runs 'gcloud compute instances list'.
+
+The information is printed as a table by default, or as a JSON array of objects
+if -format=json is specified.  The JSON objects contain all node information,
+including labels and creation time, regardless of -fields.
 `,
 	ArgsName: "[nodes]",
 	ArgsLong: "[nodes] " + nodesDesc + `
@@ -64,6 +72,14 @@ var cmdCP = &cmdline.Command{
 	Long: `
 Copy files to GCE node(s).  Runs 'gcloud compute copy-files'.  The default is to
 copy to/from all nodes in parallel.
+
+If -resume is specified and rsync is available both locally and on a node, the
+files are copied with rsync over ssh instead, keeping partially copied files so
+that running the same copy again resumes where it left off.
+
+If -verify is specified, the SHA-256 checksums of the copied files are compared
+with those of the source files, computed with sha256sum on the nodes, and files
+that are missing or don't match are copied again.
 `,
 	ArgsName: "<nodes> <src...> <dst>",
 	ArgsLong: "<nodes> " + nodesDesc + `
@@ -88,6 +104,31 @@ E.g. if <nodes> matches A, B and C:
 `,
 }
 
+var cmdFetch = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runFetch),
+	Name:   "fetch",
+	Short:  "Fetch files matching a glob from GCE nodes",
+	Long: `
+Fetch the files matching a glob from GCE node(s).  The glob is expanded by the
+shell on each node, and the matching files are copied with 'gcloud compute
+copy-files' into a sub directory of <localdir> named after the node.  Nodes
+without any matching files are reported, but are not considered failures.  The
+default is to fetch from all nodes in parallel.
+`,
+	ArgsName: "<nodes> <remote-glob> <localdir>",
+	ArgsLong: "<nodes> " + nodesDesc + `
+<remote-glob> is a shell glob identifying the remote files to fetch, e.g.
+/tmp/test-logs/*.log.  Quote the glob to prevent the local shell from expanding
+it.
+
+<localdir> is the local directory to fetch the files into.
+
+E.g. if <nodes> matches A and B:
+  // Copies {A,B}:/tmp/test-logs/*.log to logs/{A,B} respectively.
+  vcloud fetch A,B '/tmp/test-logs/*.log' logs
+`,
+}
+
 var cmdSH = &cmdline.Command{
 	Runner: cmdline.RunnerFunc(runSH),
 	Name:   "sh",
@@ -136,6 +177,11 @@ We run the following logic on each matching node, in parallel by default:
   5) If -outdir is specified, remove run files from TMPDIR, and copy TMPDIR from
      the node to the local -outdir.
   6) Delete TMPDIR.
+
+The output of each node is printed once the node is done, unless -stream is
+specified, in which case the output of the command in step 4 is printed as it is
+produced, with each line prefixed by the node name.  If -logdir is specified,
+the output of the command in step 4 is also written to <logdir>/<node>.log.
 `,
 }
 
@@ -145,7 +191,8 @@ is a comma-separated list of node name(s).  Each node name is a regular
 expression, with matches performed on the full node name.  We select nodes that
 match any of the regexps.  The comma-separated list allows you to easily specify
 a list of specific node names, without using regexp alternation.  We assume node
-names do not have embedded commas.
+names do not have embedded commas.  The -zone, -label and -group flags further
+restrict the selected nodes.
 `
 	parallelDesc = `
   <0   means all nodes in parallel
@@ -177,31 +224,57 @@ var (
 	flagUser    = flag.String("user", "veyron", "Run operations as the given user on each node.")
 	// Command-specific flags.
 	flagListNoHeader bool
+	flagListFormat   string
 	flagP            int
 	flagFailFast     bool
 	flagOutDir       string
+	flagLogDir       string
+	flagStream       bool
 	flagZone         string
 	flagImage        string
 	flagBootDiskSize string
 	flagMachineType  string
+	flagMetadata     string
 	flagSetupScript  string
 	flagScopes       string
 	flagFields       fieldsFlag
+	flagRetries      int
+	flagMultiplex    bool
+	flagVerify       bool
+	flagCopyRetries  int
+	flagResume       bool
 )
 
 func init() {
 	cmdList.Flags.BoolVar(&flagListNoHeader, "noheader", false, "Don't print list table header.")
 	cmdList.Flags.Var(&flagFields, "fields", "Only display these fields, specified as comma-separated column header names.")
+	cmdList.Flags.StringVar(&flagListFormat, "format", "table", "Output format, either 'table' or 'json'.")
 	cmdCP.Flags.IntVar(&flagP, "p", -1, "Copy to/from this many nodes in parallel."+parallelDesc)
+	cmdFetch.Flags.IntVar(&flagP, "p", -1, "Fetch from this many nodes in parallel."+parallelDesc)
 	cmdSH.Flags.IntVar(&flagP, "p", -1, "Run command on this many nodes in parallel."+parallelDesc)
 	cmdCopyAndRun.Flags.IntVar(&flagP, "p", -1, "Copy/run on this many nodes in parallel."+parallelDesc)
+	cmdNodeCreate.Flags.IntVar(&flagP, "p", -1, "Create this many nodes in parallel."+parallelDesc)
+	cmdNodeDelete.Flags.IntVar(&flagP, "p", -1, "Delete this many nodes in parallel."+parallelDesc)
 	cmdCP.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+	cmdCP.Flags.BoolVar(&flagVerify, "verify", false, "Verify the SHA-256 checksums of the copied files, and copy mismatched files again.")
+	cmdCP.Flags.IntVar(&flagCopyRetries, "verify-retries", 2, "Copy mismatched files again this many times before failing; only relevant with -verify.")
+	cmdCP.Flags.BoolVar(&flagResume, "resume", false, "Copy with rsync, if it is available locally and on the node, so that interrupted copies resume where they left off.")
+	cmdFetch.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
 	cmdSH.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
 	cmdCopyAndRun.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+	cmdNodeCreate.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+	cmdNodeDelete.Flags.BoolVar(&flagFailFast, "failfast", false, "Skip unstarted nodes after the first failing node.")
+	cmdSH.Flags.IntVar(&flagRetries, "retries", 0, "Retry commands this many times, with exponential backoff, if the SSH connection to a node fails.")
+	cmdCopyAndRun.Flags.IntVar(&flagRetries, "retries", 0, "Retry commands this many times, with exponential backoff, if the SSH connection to a node fails.")
+	cmdSH.Flags.BoolVar(&flagMultiplex, "multiplex", true, "Share a persistent SSH connection to each node across commands.")
+	cmdCopyAndRun.Flags.BoolVar(&flagMultiplex, "multiplex", true, "Share a persistent SSH connection to each node across commands.")
 	cmdCopyAndRun.Flags.StringVar(&flagOutDir, "outdir", "", "Output directory to store results from each node.")
+	cmdCopyAndRun.Flags.BoolVar(&flagStream, "stream", false, "Stream the output of the command on each node as it is produced, prefixing each line with the node name.")
+	cmdCopyAndRun.Flags.StringVar(&flagLogDir, "logdir", "", "Local directory to also write the output of the command on each node to, in files named <node>.log.")
 	cmdNodeCreate.Flags.StringVar(&flagBootDiskSize, "boot-disk-size", "500GB", "Size of the machine boot disk.")
 	cmdNodeCreate.Flags.StringVar(&flagImage, "image", "ubuntu-14-04", "Image to create the machine from.")
 	cmdNodeCreate.Flags.StringVar(&flagMachineType, "machine-type", "n1-standard-8", "Machine type to create.")
+	cmdNodeCreate.Flags.StringVar(&flagMetadata, "metadata", "", "Metadata to set on the machine, specified as comma-separated KEY=VALUE pairs.")
 	cmdNodeCreate.Flags.StringVar(&flagZone, "zone", "us-central1-f", "Zone to create the machine in.")
 	cmdNodeCreate.Flags.StringVar(&flagSetupScript, "setup-script", "", "Script to set up the machine.")
 	cmdNodeCreate.Flags.StringVar(&flagScopes, "scopes", "storage-full,logging-write", "Scopes of the machine.")
@@ -212,12 +285,14 @@ func init() {
 
 // nodeInfo represents the node info returned by 'gcloud compute instances list'
 type nodeInfo struct {
-	Name        string
-	Zone        string
-	MachineType string
-	InternalIP  string
-	ExternalIP  string
-	Status      string
+	Name         string            `json:"name"`
+	Zone         string            `json:"zone"`
+	MachineType  string            `json:"machineType"`
+	InternalIP   string            `json:"internalIP"`
+	ExternalIP   string            `json:"externalIP"`
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	CreationTime string            `json:"creationTime,omitempty"`
 }
 
 func (n nodeInfo) String() string {
@@ -289,7 +364,7 @@ func (n nodeInfo) RunCopy(ctx *tool.Context, srcs []string, dst string, makeSubd
 			// We're copying into a local dst, and we have more than one copy running,
 			// so we need to make subdirs to keep each copy separate.
 			dst = path.Join(dst, n.Name)
-			if err := os.Mkdir(dst, os.ModePerm); err != nil {
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
 				return runResult{node: n, err: err}
 			}
 		}
@@ -304,17 +379,141 @@ func (n nodeInfo) RunCopy(ctx *tool.Context, srcs []string, dst string, makeSubd
 	return runResult{node: n, out: stdouterr.String(), err: err}
 }
 
-// RunCommand runs cmdline on node n.
+// fetchMarker prefixes the lines that list the files matching a glob in the
+// output of fetchCommand, to distinguish them from other output of gcloud.
+const fetchMarker = "vcloud-fetch:"
+
+// fetchCommand returns the cmdline that lists the files matching glob on a
+// node.  The cmdline succeeds even if no files match.
+func fetchCommand(glob string) []string {
+	return []string{
+		"for", "f", "in", glob, ";",
+		"do", "[", "-e", `"$f"`, "]", "&&", "echo", `"` + fetchMarker + `$f"`, ";",
+		"done", ";", "true",
+	}
+}
+
+// parseFetchOutput returns the files listed in the given output of
+// fetchCommand.
+func parseFetchOutput(out string) []string {
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, fetchMarker) {
+			files = append(files, strings.TrimSuffix(strings.TrimPrefix(line, fetchMarker), "\r"))
+		}
+	}
+	return files
+}
+
+// Fetch copies the files matching glob on node n into the sub directory of the
+// local dst directory named after the node.
+func (n nodeInfo) Fetch(ctx *tool.Context, user, glob, dst string) runResult {
+	result := n.RunCommand(ctx, user, fetchCommand(glob))
+	if result.err != nil {
+		return result
+	}
+	files := parseFetchOutput(result.out)
+	if len(files) == 0 {
+		return runResult{node: n, out: fmt.Sprintf("no files match %q\n", glob), retries: result.retries}
+	}
+	srcs := make([]string, len(files))
+	for i, file := range files {
+		srcs[i] = ":" + file
+	}
+	copyResult := runResult{node: n, retries: result.retries}
+	copyResult.Merge(n.RunCopy(ctx, srcs, dst, true), "[fetch] copy %d files", len(files))
+	return copyResult
+}
+
+// RunCommand runs cmdline on node n.  If the SSH connection to the node fails,
+// the command is retried up to flagRetries times with exponential backoff.
 func (n nodeInfo) RunCommand(ctx *tool.Context, user string, cmdline []string) runResult {
 	var stdouterr bytes.Buffer
-	err := ctx.NewSeq().Read(nil).Capture(&stdouterr, &stdouterr).
-		Last("gcloud", "compute", "ssh",
-			addUser(user, n.Name),
-			"--project", *flagProject,
-			"--zone", n.Zone,
-			"--command", quoteForCommand(cmdline),
-		)
-	return runResult{node: n, out: stdouterr.String(), err: err}
+	result := n.RunCommandTo(ctx, user, cmdline, &stdouterr)
+	result.out = stdouterr.String()
+	return result
+}
+
+// RunCommandTo is like RunCommand, but writes the output of cmdline to w as it
+// is produced, rather than collecting it in the result.
+func (n nodeInfo) RunCommandTo(ctx *tool.Context, user string, cmdline []string, w io.Writer) runResult {
+	args := []string{"compute", "ssh",
+		addUser(user, n.Name),
+		"--project", *flagProject,
+		"--zone", n.Zone,
+		"--command", quoteForCommand(cmdline),
+	}
+	sshFlags, err := multiplexSSHFlags()
+	if err != nil {
+		return runResult{node: n, err: err}
+	}
+	for _, sshFlag := range sshFlags {
+		args = append(args, "--ssh-flag", sshFlag)
+	}
+	result := runResult{node: n}
+	backoff := retryInitialBackoff
+	for {
+		err := ctx.NewSeq().Read(nil).Capture(w, w).Last("gcloud", args...)
+		result.err = err
+		if err == nil || result.retries >= flagRetries || !isSSHConnectionError(err) {
+			return result
+		}
+		result.retries++
+		fmt.Fprintf(w, "[ssh] connection failed: %v; retry #%d in %v\n", err, result.retries, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
+
+const (
+	// sshConnectionErrorExitCode is the exit code of ssh, and thus of 'gcloud
+	// compute ssh', when the connection to the node fails.
+	sshConnectionErrorExitCode = 255
+	// retryInitialBackoff and retryMaxBackoff bound the time to wait before
+	// retrying a command after a connection error.
+	retryInitialBackoff = time.Second
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// isSSHConnectionError returns true iff err indicates that the SSH connection
+// failed, as opposed to the command failing on the node.
+func isSSHConnectionError(err error) bool {
+	exitErr, ok := runutil.GetOriginalError(err).(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.ExitStatus() == sshConnectionErrorExitCode
+}
+
+// multiplexSSHFlags returns the ssh flags that make ssh share a single
+// persistent connection to each node across commands, or nil if flagMultiplex
+// is false.  The control sockets are created in a per-user temporary directory.
+func multiplexSSHFlags() ([]string, error) {
+	if !flagMultiplex {
+		return nil, nil
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("vcloud-ssh-%d", os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return []string{
+		"-o ControlMaster=auto",
+		"-o ControlPath=" + filepath.Join(dir, "%r@%h:%p"),
+		"-o ControlPersist=" + sshControlPersist,
+	}, nil
+}
+
+// sshControlPersist is how long a multiplexed SSH connection stays open after
+// the last command that used it.
+const sshControlPersist = "60s"
+
+// shellQuote quotes s as a single word for the shell of a node.  The result is
+// passed through quoteForCommand unchanged.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
 }
 
 func quoteForCommand(cmdline []string) string {
@@ -322,10 +521,11 @@ func quoteForCommand(cmdline []string) string {
 	// complicated because there are multiple levels of escaping, from the input
 	// shell, runutil.Run, gcloud, the node itself, etc.
 	//
-	// For more complicated scripts, use 'vcloud run'.
+	// For more complicated scripts, use 'vcloud run'.  Arguments that are
+	// already quoted by shellQuote are left alone.
 	ret := ""
 	for i, arg := range cmdline {
-		if strings.ContainsAny(arg, " ") {
+		if strings.ContainsAny(arg, " ") && !(len(arg) > 1 && strings.HasPrefix(arg, "'") && strings.HasSuffix(arg, "'")) {
 			arg = `"` + arg + `"`
 		}
 		if i > 0 {
@@ -342,6 +542,7 @@ type runResult struct {
 	out     string
 	err     error
 	skipped bool
+	retries int // number of times commands were retried after connection errors
 }
 
 // Merge merges the results from r2 into r.
@@ -356,6 +557,7 @@ func (r *runResult) Merge(r2 runResult, format string, v ...interface{}) {
 	}
 	r.out += msg + "\n"
 	r.out += r2.out
+	r.retries += r2.retries
 }
 
 func (r runResult) String() string {
@@ -363,13 +565,17 @@ func (r runResult) String() string {
 	if r.out != "" {
 		ret += prefixLines(r.node.Name+": ", r.out) + "\n"
 	}
+	retries := ""
+	if r.retries > 0 {
+		retries = fmt.Sprintf(" (%d retries)", r.retries)
+	}
 	switch {
 	case r.skipped:
 		ret += fmt.Sprintf("%s SKIP\n", r.node.Name)
 	case r.err != nil:
-		ret += fmt.Sprintf("%s FAIL: %v\n", r.node.Name, r.err)
+		ret += fmt.Sprintf("%s FAIL%s: %v\n", r.node.Name, retries, r.err)
 	default:
-		ret += fmt.Sprintf("%s DONE\n", r.node.Name)
+		ret += fmt.Sprintf("%s DONE%s\n", r.node.Name, retries)
 	}
 	return ret
 }
@@ -411,7 +617,7 @@ func (x nodeInfos) run(w io.Writer, fn func(node nodeInfo) runResult) error {
 			case <-failFast:
 				// Skip all remaining nodes once we get the failFast signal.
 				for j := i; j < len(x); j++ {
-					results <- runResult{x[j], "", nil, true}
+					results <- runResult{node: x[j], skipped: true}
 				}
 				return
 			}
@@ -457,7 +663,7 @@ func (x nodeInfos) RunCopy(ctx *tool.Context, srcs []string, dst string) error {
 		// into the same dst dir; the remote copies would overwrite each other.
 		makeSubdir = true
 	}
-	fn := func(node nodeInfo) runResult { return node.RunCopy(ctx, srcs, dst, makeSubdir) }
+	fn := func(node nodeInfo) runResult { return node.RunVerifiedCopy(ctx, srcs, dst, makeSubdir) }
 	return x.run(ctx.Stdout(), fn)
 }
 
@@ -467,8 +673,11 @@ func (x nodeInfos) RunCommand(ctx *tool.Context, user string, cmdline []string)
 	return x.run(ctx.Stdout(), fn)
 }
 
-// RunCopyAndRun implements the 'vcloud run' command.
-func (x nodeInfos) RunCopyAndRun(ctx *tool.Context, user string, files, cmds []string, outdir string) error {
+// RunCopyAndRun implements the 'vcloud run' command.  If stream is true, the
+// output of the cmdline on each node is written to stdout as it is produced,
+// with each line prefixed by the node name.  If logdir is not empty, the output
+// of the cmdline on each node is also written to the file <logdir>/<node>.log.
+func (x nodeInfos) RunCopyAndRun(ctx *tool.Context, user string, files, cmds []string, outdir string, stream bool, logdir string) error {
 	// Check if the run file has execution permissions.
 	if len(cmds) == 0 {
 		info, err := ctx.NewSeq().Stat(files[0])
@@ -479,6 +688,13 @@ func (x nodeInfos) RunCopyAndRun(ctx *tool.Context, user string, files, cmds []s
 			return fmt.Errorf("file %v doesn't have executable permissions", files[0])
 		}
 	}
+	if logdir != "" {
+		if err := ctx.NewSeq().MkdirAll(logdir, os.ModePerm).Done(); err != nil {
+			return err
+		}
+	}
+	// Serializes the streamed output of all nodes.
+	var streamMu sync.Mutex
 	// 0) Pick a random number so that we use the same tmpdir on each node.
 	rand.Seed(time.Now().UnixNano())
 	tmpdir := fmt.Sprintf("./tmp_%X", rand.Int63())
@@ -500,7 +716,7 @@ func (x nodeInfos) RunCopyAndRun(ctx *tool.Context, user string, files, cmds []s
 			} else {
 				cmdline = append(cmdline, cmds...)
 			}
-			result.Merge(node.RunCommand(ctx, user, cmdline), "[run] run cmdline %v", cmdline)
+			result.Merge(node.runStreamed(ctx, user, cmdline, &streamMu, stream, logdir), "[run] run cmdline %v", cmdline)
 			// 5) If outdir is specified, remove the run files from TMPDIR, and copy
 			// TMPDIR from the node to the local outdir.
 			if outdir != "" {
@@ -522,6 +738,83 @@ func (x nodeInfos) RunCopyAndRun(ctx *tool.Context, user string, files, cmds []s
 	return x.run(ctx.Stdout(), fn)
 }
 
+// runStreamed runs cmdline on node n.  If stream is true, the output is written
+// to stdout line by line, prefixed by the node name and serialized by mu;
+// otherwise it is collected in the result.  If logdir is not empty, the output
+// is also written to the file <logdir>/<node>.log.
+func (n nodeInfo) runStreamed(ctx *tool.Context, user string, cmdline []string, mu *sync.Mutex, stream bool, logdir string) runResult {
+	var out bytes.Buffer
+	var w io.Writer = &out
+	var pw *prefixWriter
+	if stream {
+		pw = newPrefixWriter(ctx.Stdout(), n.Name+": ", mu)
+		w = pw
+	}
+	if logdir != "" {
+		logFile := filepath.Join(logdir, n.Name+".log")
+		file, err := os.Create(logFile)
+		if err != nil {
+			return runResult{node: n, err: fmt.Errorf("Create(%v) failed: %v", logFile, err)}
+		}
+		defer file.Close()
+		w = io.MultiWriter(w, file)
+	}
+	result := n.RunCommandTo(ctx, user, cmdline, w)
+	if pw != nil {
+		pw.Flush()
+	}
+	result.out = out.String()
+	return result
+}
+
+// prefixWriter is an io.Writer that writes complete lines to an underlying
+// writer, prefixing each line.  Lines are written while holding a mutex, which
+// allows multiple prefixWriters to share the underlying writer without
+// interleaving their lines.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, mu: mu}
+}
+
+// Write implements io.Writer.  Incomplete lines are buffered until they are
+// completed by a subsequent Write, or until Flush is called.
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	end := bytes.LastIndexByte(p.buf, '\n')
+	if end < 0 {
+		return len(data), nil
+	}
+	lines := string(p.buf[:end+1])
+	p.buf = p.buf[end+1:]
+	if err := p.writeLines(lines); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush writes any buffered incomplete line.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := string(p.buf) + "\n"
+	p.buf = nil
+	return p.writeLines(line)
+}
+
+func (p *prefixWriter) writeLines(lines string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, prefixLines(p.prefix, lines)+"\n")
+	return err
+}
+
 func (x nodeInfos) String() string {
 	var ret string
 	if !flagListNoHeader {
@@ -613,43 +906,94 @@ func listAll(ctx *tool.Context) (nodeInfos, error) {
 	if ctx.Verbose() {
 		fmt.Fprintln(ctx.Stdout(), stdout.String())
 	}
-	var instances []struct {
-		Name              string
-		Zone              string
-		NetworkInterfaces []struct {
-			AccessConfigs []struct {
-				NatIP string
-			}
-			NetworkIP string
+	all, err := parseInstances(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	all.Sort()
+	return all, nil
+}
+
+// gcloudInstance represents the subset of the JSON output of 'gcloud compute
+// instances list --format=json' that describes a node.
+type gcloudInstance struct {
+	Name              string
+	Zone              string
+	MachineType       string
+	Status            string
+	Labels            map[string]string
+	CreationTimestamp string
+	NetworkInterfaces []struct {
+		AccessConfigs []struct {
+			NatIP string
 		}
-		MachineType string
-		Status      string
+		NetworkIP string
 	}
-	if err := json.Unmarshal(stdout.Bytes(), &instances); err != nil {
+}
+
+// parseInstances parses the JSON output of 'gcloud compute instances list
+// --format=json' into nodeInfos.  Zones and machine types are reported by their
+// names rather than their resource URLs, and missing network information is
+// left empty.
+func parseInstances(data []byte) (nodeInfos, error) {
+	var instances []gcloudInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
 		return nil, fmt.Errorf("Unmarshal() failed: %v", err)
 	}
 	var all nodeInfos
 	for _, instance := range instances {
-		all = append(all, nodeInfo{
-			Name:        instance.Name,
-			Zone:        instance.Zone,
-			MachineType: instance.MachineType,
-			InternalIP:  instance.NetworkInterfaces[0].NetworkIP,
-			ExternalIP:  instance.NetworkInterfaces[0].AccessConfigs[0].NatIP,
-			Status:      instance.Status,
-		})
+		node := nodeInfo{
+			Name:         instance.Name,
+			Zone:         path.Base(instance.Zone),
+			MachineType:  path.Base(instance.MachineType),
+			Status:       instance.Status,
+			Labels:       instance.Labels,
+			CreationTime: instance.CreationTimestamp,
+		}
+		if len(instance.NetworkInterfaces) > 0 {
+			iface := instance.NetworkInterfaces[0]
+			node.InternalIP = iface.NetworkIP
+			if len(iface.AccessConfigs) > 0 {
+				node.ExternalIP = iface.AccessConfigs[0].NatIP
+			}
+		}
+		all = append(all, node)
 	}
-	all.Sort()
 	return all, nil
 }
 
+// printNodes prints the given nodes to w in the format given by
+// flagListFormat.
+func printNodes(w io.Writer, nodes nodeInfos) error {
+	switch flagListFormat {
+	case "table":
+		fmt.Fprint(w, nodes)
+		return nil
+	case "json":
+		if nodes == nil {
+			nodes = nodeInfos{}
+		}
+		bytes, err := json.MarshalIndent(nodes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("MarshalIndent() failed: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", bytes)
+		return err
+	}
+	return fmt.Errorf("unknown format %q", flagListFormat)
+}
+
 // listMatching runs listAll and matches the resulting nodes against exprlist, a
-// comma-separated list of regular expressions.
+// comma-separated list of regular expressions, and the -zone, -label and -group
+// flags.
 func listMatching(ctx *tool.Context, exprlist string) (nodeInfos, error) {
 	all, err := listAll(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if all, err = filterNodes(ctx, all); err != nil {
+		return nil, err
+	}
 	match, err := all.MatchNames(exprlist)
 	if err != nil {
 		return nil, err
@@ -658,22 +1002,26 @@ func listMatching(ctx *tool.Context, exprlist string) (nodeInfos, error) {
 }
 
 func runList(env *cmdline.Env, args []string) error {
+	if flagListFormat != "table" && flagListFormat != "json" {
+		return env.UsageErrorf("unknown format %q", flagListFormat)
+	}
 	ctx := newContext(env)
 	all, err := listAll(ctx)
 	if err != nil {
 		return err
 	}
+	if all, err = filterNodes(ctx, all); err != nil {
+		return err
+	}
 	switch {
 	case len(args) == 0:
-		fmt.Fprint(env.Stdout, all)
-		return nil
+		return printNodes(env.Stdout, all)
 	case len(args) == 1:
 		matches, err := all.MatchNames(args[0])
 		if err != nil {
 			return env.UsageErrorf("%v", err)
 		}
-		fmt.Fprint(env.Stdout, matches)
-		return nil
+		return printNodes(env.Stdout, matches)
 	}
 	return env.UsageErrorf("too many args")
 }
@@ -707,6 +1055,26 @@ func runCP(env *cmdline.Env, args []string) error {
 	return nodes.RunCopy(ctx, srcs, dst)
 }
 
+func runFetch(env *cmdline.Env, args []string) error {
+	if len(args) != 3 {
+		return env.UsageErrorf("need exactly three args")
+	}
+	glob, dst := args[1], args[2]
+	if strings.HasPrefix(dst, ":") {
+		return env.UsageErrorf("<localdir> must be local")
+	}
+	ctx := newContext(env)
+	nodes, err := listMatching(ctx, args[0])
+	if err != nil {
+		return env.UsageErrorf("%v", err)
+	}
+	if err := ctx.NewSeq().MkdirAll(dst, os.ModePerm).Done(); err != nil {
+		return err
+	}
+	fn := func(node nodeInfo) runResult { return node.Fetch(ctx, *flagUser, glob, dst) }
+	return nodes.run(ctx.Stdout(), fn)
+}
+
 func runSH(env *cmdline.Env, args []string) error {
 	if len(args) == 0 {
 		return env.UsageErrorf("no node(s) specified")
@@ -736,12 +1104,15 @@ func runCopyAndRun(env *cmdline.Env, args []string) error {
 	if strings.HasPrefix(flagOutDir, ":") {
 		return env.UsageErrorf("-outdir must be local")
 	}
+	if strings.HasPrefix(flagLogDir, ":") {
+		return env.UsageErrorf("-logdir must be local")
+	}
 	ctx := newContext(env)
 	nodes, err := listMatching(ctx, args[0])
 	if err != nil {
 		return env.UsageErrorf("%v", err)
 	}
-	return nodes.RunCopyAndRun(ctx, *flagUser, files, cmdline, flagOutDir)
+	return nodes.RunCopyAndRun(ctx, *flagUser, files, cmdline, flagOutDir, flagStream, flagLogDir)
 }
 
 func splitCopyAndRunArgs(args []string) (files, cmdline []string, _ error) {
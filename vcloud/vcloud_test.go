@@ -0,0 +1,182 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const instancesJSON = `[
+  {
+    "name": "jenkins-node01",
+    "zone": "https://www.googleapis.com/compute/v1/projects/vanadium-internal/zones/us-central1-f",
+    "machineType": "https://www.googleapis.com/compute/v1/projects/vanadium-internal/zones/us-central1-f/machineTypes/n1-standard-8",
+    "status": "RUNNING",
+    "labels": {"role": "jenkins"},
+    "creationTimestamp": "2016-03-01T10:00:00.000-08:00",
+    "networkInterfaces": [
+      {"networkIP": "10.240.0.2", "accessConfigs": [{"natIP": "104.154.0.1"}]}
+    ]
+  },
+  {
+    "name": "internal-node",
+    "zone": "us-central1-c",
+    "machineType": "n1-standard-1",
+    "status": "TERMINATED",
+    "networkInterfaces": [
+      {"networkIP": "10.240.0.3"}
+    ]
+  }
+]`
+
+func TestParseInstances(t *testing.T) {
+	got, err := parseInstances([]byte(instancesJSON))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := nodeInfos{
+		nodeInfo{
+			Name:         "jenkins-node01",
+			Zone:         "us-central1-f",
+			MachineType:  "n1-standard-8",
+			InternalIP:   "10.240.0.2",
+			ExternalIP:   "104.154.0.1",
+			Status:       "RUNNING",
+			Labels:       map[string]string{"role": "jenkins"},
+			CreationTime: "2016-03-01T10:00:00.000-08:00",
+		},
+		nodeInfo{
+			Name:        "internal-node",
+			Zone:        "us-central1-c",
+			MachineType: "n1-standard-1",
+			InternalIP:  "10.240.0.3",
+			Status:      "TERMINATED",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if _, err := parseInstances([]byte("NAME ZONE")); err == nil {
+		t.Fatalf("parsing a table did not fail")
+	}
+}
+
+func TestPrintNodesJSON(t *testing.T) {
+	defer func(format string) { flagListFormat = format }(flagListFormat)
+	flagListFormat = "json"
+	nodes, err := parseInstances([]byte(instancesJSON))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bytes.Buffer
+	if err := printNodes(&out, nodes); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var got nodeInfos
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, nodes) {
+		t.Fatalf("want %v, got %v", nodes, got)
+	}
+
+	flagListFormat = "yaml"
+	if err := printNodes(&out, nodes); err == nil {
+		t.Fatalf("printing in an unknown format did not fail")
+	}
+}
+
+func TestRunResultRetries(t *testing.T) {
+	node := nodeInfo{Name: "jenkins-node01"}
+	result := runResult{node: node}
+	result.Merge(runResult{node: node, retries: 2}, "[run] create tmpdir")
+	result.Merge(runResult{node: node, retries: 1}, "[run] delete tmpdir")
+	if got, want := result.retries, 3; got != want {
+		t.Errorf("got %d retries, want %d", got, want)
+	}
+	want := "jenkins-node01: [run] create tmpdir\njenkins-node01: [run] delete tmpdir\njenkins-node01 DONE (3 retries)\n"
+	if got := result.String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestIsSSHConnectionError(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{1, false},
+		{255, true},
+	}
+	for _, test := range tests {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", test.code)).Run()
+		if got := isSSHConnectionError(err); got != test.want {
+			t.Errorf("exit code %d: got %v, want %v", test.code, got, test.want)
+		}
+	}
+	if isSSHConnectionError(errors.New("gcloud not found")) {
+		t.Errorf("non-exit error considered an SSH connection error")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w1 := newPrefixWriter(&out, "node1: ", &mu)
+	w2 := newPrefixWriter(&out, "node2: ", &mu)
+	fmt.Fprint(w1, "first ")
+	fmt.Fprint(w2, "one\ntwo\nthr")
+	fmt.Fprint(w1, "line\nsecond line\n")
+	fmt.Fprint(w2, "ee")
+	if err := w1.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := w2.Flush(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := "node2: one\nnode2: two\nnode1: first line\nnode1: second line\nnode2: three\n"
+	if got := out.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestFetchCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcloud-fetch")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.log", "b.log", "c.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, os.ModePerm); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	tests := []struct {
+		glob string
+		want []string
+	}{
+		{filepath.Join(dir, "*.log"), []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}},
+		{filepath.Join(dir, "*.out"), nil},
+	}
+	for _, test := range tests {
+		out, err := exec.Command("sh", "-c", quoteForCommand(fetchCommand(test.glob))).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", test.glob, err, out)
+		}
+		if got := parseFetchOutput("Warning: unrelated gcloud output\n" + string(out)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: want %v, got %v", test.glob, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"v.io/jiri/collect"
+	"v.io/x/lib/cmdline"
+)
+
+const (
+	// auditUnhealthyExitCode is the exit code of the audit command when
+	// the Jenkins master is unhealthy, which tells it apart from
+	// failures to run the audit.
+	auditUnhealthyExitCode = 2
+
+	defaultUpdateCenterURL = "https://updates.jenkins.io/update-center.actual.json"
+)
+
+var (
+	flagMaxExecutorUtilization float64
+	flagMaxQueueLength         int
+	flagUpdateCenter           string
+)
+
+func init() {
+	cmdAudit.Flags.Float64Var(&flagMaxExecutorUtilization, "max-executor-utilization", 0.9, "The maximum fraction of the executors of the online nodes that can be busy.")
+	cmdAudit.Flags.IntVar(&flagMaxQueueLength, "max-queue-length", 20, "The maximum number of builds that can wait in the build queue.")
+	cmdAudit.Flags.StringVar(&flagUpdateCenter, "update-center", defaultUpdateCenterURL, "The URL of the update center data that lists the security warnings of the plugins. If empty, the security warnings are not checked.")
+}
+
+var cmdAudit = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runAudit),
+	Name:   "audit",
+	Short:  "Audit the health of the Jenkins master",
+	Long: `
+Audit the health of the Jenkins master. Uses the Jenkins REST API to collect the
+versions of the installed plugins and whether updates are available for them,
+the length of the build queue, the utilization of the executors of the online
+nodes, and the nodes that are disconnected. The security warnings published by
+the update center are matched against the installed plugin versions.
+
+The result of the audit is printed as JSON. If the master is unhealthy, because
+plugins are affected by security warnings, the queue or the executor
+utilization exceed the given limits, or nodes are disconnected, the problems are
+listed in the result and the command exits with code 2, so that it can be run
+by a periodic monitoring job.
+`,
+}
+
+// plugin records the information about a Jenkins plugin returned by the
+// pluginManager/api/json endpoint of the Jenkins REST API.
+type plugin struct {
+	ShortName string `json:"name"`
+	Version   string `json:"version"`
+	Active    bool   `json:"active"`
+	HasUpdate bool   `json:"hasUpdate"`
+}
+
+// securityWarning records a security warning published by the update
+// center, along with the version of the affected plugin.
+type securityWarning struct {
+	ID      string `json:"id"`
+	Plugin  string `json:"plugin"`
+	Version string `json:"version"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+}
+
+// auditResult records the result of the audit of a Jenkins master.
+type auditResult struct {
+	Healthy             bool              `json:"healthy"`
+	Problems            []string          `json:"problems"`
+	Plugins             []plugin          `json:"plugins"`
+	PendingUpdates      []string          `json:"pendingUpdates"`
+	SecurityWarnings    []securityWarning `json:"securityWarnings"`
+	QueueLength         int               `json:"queueLength"`
+	BusyExecutors       int               `json:"busyExecutors"`
+	TotalExecutors      int               `json:"totalExecutors"`
+	ExecutorUtilization float64           `json:"executorUtilization"`
+	DisconnectedNodes   []string          `json:"disconnectedNodes"`
+}
+
+// updateCenterWarning records a security warning of the update center
+// data. Versions holds the regular expressions that match the affected
+// versions.
+type updateCenterWarning struct {
+	ID       string
+	Message  string
+	Name     string
+	Type     string
+	URL      string
+	Versions []struct {
+		Pattern string
+	}
+}
+
+// listPlugins returns the plugins installed on the given Jenkins master.
+func listPlugins(host string) ([]plugin, error) {
+	var response struct {
+		Plugins []struct {
+			ShortName string
+			Version   string
+			Active    bool
+			HasUpdate bool
+		}
+	}
+	if err := getJenkinsJSON(host, "pluginManager/api/json?depth=1", &response); err != nil {
+		return nil, err
+	}
+	plugins := []plugin{}
+	for _, p := range response.Plugins {
+		plugins = append(plugins, plugin{ShortName: p.ShortName, Version: p.Version, Active: p.Active, HasUpdate: p.HasUpdate})
+	}
+	return plugins, nil
+}
+
+// queueLength returns the number of builds waiting in the build queue of
+// the given Jenkins master.
+func queueLength(host string) (int, error) {
+	var response struct {
+		Items []struct{}
+	}
+	if err := getJenkinsJSON(host, "queue/api/json", &response); err != nil {
+		return 0, err
+	}
+	return len(response.Items), nil
+}
+
+// readUpdateCenterWarnings returns the security warnings listed in the
+// update center data served at the given URL.
+func readUpdateCenterWarnings(url string) (_ []updateCenterWarning, e error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Get(%v) failed: %v", url, err)
+	}
+	defer collect.Error(func() error { return res.Body.Close() }, &e)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Get(%v) failed: %v", url, res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ReadAll() failed: %v", err)
+	}
+	var data struct {
+		Warnings []updateCenterWarning
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("Unmarshal(%v) failed: %v", url, err)
+	}
+	return data.Warnings, nil
+}
+
+// matchSecurityWarnings returns the given security warnings that affect
+// the installed versions of the given plugins.
+func matchSecurityWarnings(plugins []plugin, warnings []updateCenterWarning) ([]securityWarning, error) {
+	versions := map[string]string{}
+	for _, p := range plugins {
+		versions[p.ShortName] = p.Version
+	}
+	result := []securityWarning{}
+	for _, w := range warnings {
+		version, ok := versions[w.Name]
+		if w.Type != "plugin" || !ok {
+			continue
+		}
+		for _, v := range w.Versions {
+			re, err := regexp.Compile("^(?:" + v.Pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("Compile(%v) failed: %v", v.Pattern, err)
+			}
+			if re.MatchString(version) {
+				result = append(result, securityWarning{ID: w.ID, Plugin: w.Name, Version: version, Message: w.Message, URL: w.URL})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// auditMaster evaluates the health of a Jenkins master from the given
+// information about its plugins, security warnings, build queue and
+// nodes.
+func auditMaster(plugins []plugin, warnings []securityWarning, queueLength int, computers []computer) auditResult {
+	result := auditResult{
+		Problems:          []string{},
+		Plugins:           plugins,
+		PendingUpdates:    []string{},
+		SecurityWarnings:  warnings,
+		QueueLength:       queueLength,
+		DisconnectedNodes: []string{},
+	}
+	for _, p := range plugins {
+		if p.HasUpdate {
+			result.PendingUpdates = append(result.PendingUpdates, p.ShortName)
+		}
+	}
+	for _, w := range warnings {
+		result.Problems = append(result.Problems, fmt.Sprintf("plugin %v %v is affected by %v", w.Plugin, w.Version, w.ID))
+	}
+	if queueLength > flagMaxQueueLength {
+		result.Problems = append(result.Problems, fmt.Sprintf("%d builds are queued, more than %d", queueLength, flagMaxQueueLength))
+	}
+	for _, c := range computers {
+		switch {
+		case c.Offline && !c.TemporarilyOffline:
+			result.DisconnectedNodes = append(result.DisconnectedNodes, c.DisplayName)
+			result.Problems = append(result.Problems, fmt.Sprintf("node %v is disconnected", c.DisplayName))
+		case !c.Offline:
+			result.BusyExecutors += c.BusyExecutors()
+			result.TotalExecutors += c.NumExecutors
+		}
+	}
+	if result.TotalExecutors > 0 {
+		result.ExecutorUtilization = float64(result.BusyExecutors) / float64(result.TotalExecutors)
+	}
+	if result.ExecutorUtilization > flagMaxExecutorUtilization {
+		result.Problems = append(result.Problems, fmt.Sprintf("%d of %d executors are busy, more than %v of them", result.BusyExecutors, result.TotalExecutors, flagMaxExecutorUtilization))
+	}
+	result.Healthy = len(result.Problems) == 0
+	return result
+}
+
+// printAuditResult prints the given audit result as JSON to w.
+func printAuditResult(w io.Writer, result auditResult) error {
+	bytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent() failed: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", bytes)
+	return err
+}
+
+// runAudit audits the health of the Jenkins master.
+func runAudit(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
+	plugins, err := listPlugins(flagJenkinsHost)
+	if err != nil {
+		return err
+	}
+	warnings := []securityWarning{}
+	if flagUpdateCenter != "" {
+		updateCenterWarnings, err := readUpdateCenterWarnings(flagUpdateCenter)
+		if err != nil {
+			return err
+		}
+		if warnings, err = matchSecurityWarnings(plugins, updateCenterWarnings); err != nil {
+			return err
+		}
+	}
+	length, err := queueLength(flagJenkinsHost)
+	if err != nil {
+		return err
+	}
+	computers, err := listComputers(flagJenkinsHost)
+	if err != nil {
+		return err
+	}
+	result := auditMaster(plugins, warnings, length, computers)
+	if err := printAuditResult(env.Stdout, result); err != nil {
+		return err
+	}
+	if !result.Healthy {
+		return cmdline.ErrExitCode(auditUnhealthyExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const pluginsJSON = `{
+  "plugins": [
+    {"shortName": "git", "version": "2.4.4", "active": true, "hasUpdate": true},
+    {"shortName": "xunit", "version": "1.102", "active": true, "hasUpdate": false}
+  ]
+}`
+
+const updateCenterJSON = `{
+  "warnings": [
+    {"id": "SECURITY-275", "message": "Arbitrary code execution", "name": "git", "type": "plugin", "url": "https://jenkins.io/security/advisory/2016-05-11/", "versions": [{"pattern": "2[.][0-4]([.-].*)?"}]},
+    {"id": "SECURITY-300", "message": "XSS", "name": "xunit", "type": "plugin", "url": "https://jenkins.io/security/advisory/2016-06-20/", "versions": [{"pattern": "1[.]9.*"}]},
+    {"id": "SECURITY-2", "message": "CSRF", "name": "core", "type": "core", "url": "https://jenkins.io/security/advisory/2016-02-24/", "versions": [{"pattern": ".*"}]}
+  ]
+}`
+
+func TestAudit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pluginManager/api/json":
+			fmt.Fprint(w, pluginsJSON)
+		case "/queue/api/json":
+			fmt.Fprint(w, `{"items": [{"id": 1}, {"id": 2}]}`)
+		case "/update-center.actual.json":
+			fmt.Fprint(w, updateCenterJSON)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	plugins, err := listPlugins(server.URL)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []plugin{
+		{ShortName: "git", Version: "2.4.4", Active: true, HasUpdate: true},
+		{ShortName: "xunit", Version: "1.102", Active: true},
+	}; !reflect.DeepEqual(plugins, want) {
+		t.Fatalf("want %v, got %v", want, plugins)
+	}
+	updateCenterWarnings, err := readUpdateCenterWarnings(server.URL + "/update-center.actual.json")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	warnings, err := matchSecurityWarnings(plugins, updateCenterWarnings)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if want := []securityWarning{
+		{ID: "SECURITY-275", Plugin: "git", Version: "2.4.4", Message: "Arbitrary code execution", URL: "https://jenkins.io/security/advisory/2016-05-11/"},
+	}; !reflect.DeepEqual(warnings, want) {
+		t.Fatalf("want %v, got %v", want, warnings)
+	}
+	length, err := queueLength(server.URL)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if length != 2 {
+		t.Fatalf("want 2 queued builds, got %v", length)
+	}
+}
+
+func TestAuditMaster(t *testing.T) {
+	busy := computer{DisplayName: "jenkins-node01", NumExecutors: 2}
+	busy.Executors = make([]struct {
+		Idle              bool
+		CurrentExecutable *struct {
+			URL string
+		}
+	}, 2)
+	idle := computer{DisplayName: "jenkins-node02", NumExecutors: 2, Idle: true}
+	disconnected := computer{DisplayName: "jenkins-node03", NumExecutors: 2, Offline: true}
+	drained := computer{DisplayName: "jenkins-node04", NumExecutors: 2, Offline: true, TemporarilyOffline: true}
+	plugins := []plugin{{ShortName: "git", Version: "2.4.4", HasUpdate: true}}
+
+	result := auditMaster(plugins, []securityWarning{}, 3, []computer{busy, idle, drained})
+	if !result.Healthy || result.BusyExecutors != 2 || result.TotalExecutors != 4 || result.ExecutorUtilization != 0.5 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if want := []string{"git"}; !reflect.DeepEqual(result.PendingUpdates, want) {
+		t.Fatalf("want %v, got %v", want, result.PendingUpdates)
+	}
+
+	warnings := []securityWarning{{ID: "SECURITY-275", Plugin: "git", Version: "2.4.4"}}
+	result = auditMaster(plugins, warnings, 30, []computer{busy, disconnected})
+	want := []string{
+		"plugin git 2.4.4 is affected by SECURITY-275",
+		"30 builds are queued, more than 20",
+		"node jenkins-node03 is disconnected",
+		"2 of 2 executors are busy, more than 0.9 of them",
+	}
+	if result.Healthy || !reflect.DeepEqual(result.Problems, want) {
+		t.Fatalf("want unhealthy with %v, got %+v", want, result)
+	}
+	if want := []string{"jenkins-node03"}; !reflect.DeepEqual(result.DisconnectedNodes, want) {
+		t.Fatalf("want %v, got %v", want, result.DisconnectedNodes)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"v.io/jiri/collect"
+)
+
+// computer records the information about a Jenkins node returned by the
+// computer/api/json endpoint of the Jenkins REST API.
+type computer struct {
+	DisplayName        string
+	Description        string
+	Idle               bool
+	NumExecutors       int
+	Offline            bool
+	OfflineCauseReason string
+	TemporarilyOffline bool
+	AssignedLabels     []struct {
+		Name string
+	}
+	Executors []struct {
+		Idle              bool
+		CurrentExecutable *struct {
+			URL string
+		}
+	}
+}
+
+// Labels returns the sorted names of the labels assigned to the node,
+// excluding the label that matches the node name.
+func (c computer) Labels() []string {
+	var labels []string
+	for _, label := range c.AssignedLabels {
+		if label.Name != c.DisplayName {
+			labels = append(labels, label.Name)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Status returns a short description of the connection state of the node.
+func (c computer) Status() string {
+	switch {
+	case c.TemporarilyOffline:
+		return "temporarily offline"
+	case c.Offline:
+		return "offline"
+	default:
+		return "online"
+	}
+}
+
+// BusyExecutors returns the number of executors that are running a
+// build.
+func (c computer) BusyExecutors() int {
+	busy := 0
+	for _, executor := range c.Executors {
+		if !executor.Idle {
+			busy++
+		}
+	}
+	return busy
+}
+
+// statusError is returned when the Jenkins REST API responds with a
+// status other than OK.
+type statusError struct {
+	url        string
+	status     string
+	statusCode int
+	body       []byte
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%v failed with status %v:\n%s", e.url, e.status, e.body)
+}
+
+// isNotFound returns whether err indicates that the requested Jenkins
+// resource does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(statusError)
+	return ok && e.statusCode == http.StatusNotFound
+}
+
+// invokeJenkins invokes the given suffix of the Jenkins REST API with the
+// given method and request body, which can be nil, and returns the response
+// body.
+func invokeJenkins(method, host, suffix string, body io.Reader) (_ []byte, e error) {
+	u := strings.TrimSuffix(host, "/") + "/" + suffix
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest(%v, %v) failed: %v", method, u, err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v %v failed: %v", method, u, err)
+	}
+	defer collect.Error(func() error { return res.Body.Close() }, &e)
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ReadAll() failed: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, statusError{method + " " + u, res.Status, res.StatusCode, resBody}
+	}
+	return resBody, nil
+}
+
+// getJenkinsJSON fetches the given suffix of the Jenkins REST API and
+// decodes the JSON response into v.
+func getJenkinsJSON(host, suffix string, v interface{}) error {
+	body, err := invokeJenkins("GET", host, suffix, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("Unmarshal(%v) failed: %v", string(body), err)
+	}
+	return nil
+}
+
+// listComputers returns the information about all nodes of the given
+// Jenkins master.
+func listComputers(host string) ([]computer, error) {
+	var response struct {
+		Computer []computer
+	}
+	if err := getJenkinsJSON(host, "computer/api/json?depth=1", &response); err != nil {
+		return nil, err
+	}
+	return response.Computer, nil
+}
+
+// getComputer returns the information about the given node of the given
+// Jenkins master.
+func getComputer(host, name string) (computer, error) {
+	var c computer
+	suffix := fmt.Sprintf("computer/%s/api/json?depth=1", url.PathEscape(name))
+	if err := getJenkinsJSON(host, suffix, &c); err != nil {
+		return computer{}, err
+	}
+	return c, nil
+}
+
+// markComputerOffline marks the given node of the given Jenkins master
+// temporarily offline with the given message, so that no new builds are
+// scheduled on it. Nodes that are already temporarily offline are left
+// untouched.
+func markComputerOffline(host string, c computer, message string) error {
+	if c.TemporarilyOffline {
+		return nil
+	}
+	suffix := fmt.Sprintf("computer/%s/toggleOffline?offlineMessage=%s",
+		url.PathEscape(c.DisplayName), url.QueryEscape(message))
+	_, err := invokeJenkins("POST", host, suffix, nil)
+	return err
+}
+
+// labelElement matches the label element of the config.xml of a node.
+var labelElement = regexp.MustCompile(`<label>[^<]*</label>|<label/>`)
+
+// replaceConfigLabels returns the given config.xml of a node with its
+// labels replaced by the given ones.
+func replaceConfigLabels(config []byte, labels []string) ([]byte, error) {
+	if !labelElement.Match(config) {
+		return nil, fmt.Errorf("no label element found in:\n%s", config)
+	}
+	var escaped bytes.Buffer
+	if err := xml.EscapeText(&escaped, []byte(strings.Join(labels, " "))); err != nil {
+		return nil, fmt.Errorf("EscapeText() failed: %v", err)
+	}
+	element := []byte("<label>" + escaped.String() + "</label>")
+	return labelElement.ReplaceAllLiteral(config, element), nil
+}
+
+// setComputerLabels sets the labels of the given node of the given Jenkins
+// master by updating the config.xml of the node.
+func setComputerLabels(host, name string, labels []string) error {
+	suffix := fmt.Sprintf("computer/%s/config.xml", url.PathEscape(name))
+	config, err := invokeJenkins("GET", host, suffix, nil)
+	if err != nil {
+		return err
+	}
+	if config, err = replaceConfigLabels(config, labels); err != nil {
+		return err
+	}
+	_, err = invokeJenkins("POST", host, suffix, bytes.NewReader(config))
+	return err
+}
+
+// printComputers prints a table of the given nodes to w.
+func printComputers(w io.Writer, computers []computer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tIDLE\tEXECUTORS\tLABELS")
+	for _, c := range computers {
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%d/%d\t%s\n", c.DisplayName, c.Status(), c.Idle,
+			c.BusyExecutors(), c.NumExecutors, formatLabels(c.Labels()))
+	}
+	return tw.Flush()
+}
+
+// printComputerStatus prints the detailed status of the given node to w.
+func printComputerStatus(w io.Writer, c computer) {
+	fmt.Fprintf(w, "%s:\n", c.DisplayName)
+	fmt.Fprintf(w, "  Status:      %s\n", c.Status())
+	if c.Offline && c.OfflineCauseReason != "" {
+		fmt.Fprintf(w, "  Reason:      %s\n", c.OfflineCauseReason)
+	}
+	if c.Description != "" {
+		fmt.Fprintf(w, "  Description: %s\n", c.Description)
+	}
+	fmt.Fprintf(w, "  Idle:        %v\n", c.Idle)
+	fmt.Fprintf(w, "  Executors:   %d (%d busy)\n", c.NumExecutors, c.BusyExecutors())
+	for _, executor := range c.Executors {
+		if executor.CurrentExecutable != nil {
+			fmt.Fprintf(w, "    running %s\n", executor.CurrentExecutable.URL)
+		}
+	}
+	fmt.Fprintf(w, "  Labels:      %s\n", formatLabels(c.Labels()))
+}
+
+// formatLabels returns a comma-separated list of the given labels, or "-"
+// if there are none.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	return strings.Join(labels, ",")
+}
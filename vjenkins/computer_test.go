@@ -0,0 +1,160 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const computersJSON = `{
+  "busyExecutors": 1,
+  "computer": [
+    {
+      "displayName": "jenkins-node01",
+      "idle": false,
+      "numExecutors": 2,
+      "offline": false,
+      "temporarilyOffline": false,
+      "assignedLabels": [{"name": "linux"}, {"name": "jenkins-node01"}, {"name": "gce"}],
+      "executors": [
+        {"idle": false, "currentExecutable": {"url": "http://jenkins/job/vanadium-go-test/10/"}},
+        {"idle": true, "currentExecutable": null}
+      ]
+    },
+    {
+      "displayName": "jenkins-node02",
+      "idle": true,
+      "numExecutors": 1,
+      "offline": true,
+      "offlineCauseReason": "disk full",
+      "temporarilyOffline": true,
+      "assignedLabels": [{"name": "jenkins-node02"}],
+      "executors": [{"idle": true}]
+    }
+  ]
+}`
+
+func newFakeJenkins() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computer/api/json":
+			fmt.Fprint(w, computersJSON)
+		case "/computer/jenkins-node02/api/json":
+			fmt.Fprint(w, `{"displayName": "jenkins-node02", "idle": true, "numExecutors": 1, "offline": true, "offlineCauseReason": "disk full", "executors": [{"idle": true}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNodeList(t *testing.T) {
+	server := newFakeJenkins()
+	defer server.Close()
+
+	computers, err := listComputers(server.URL)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bytes.Buffer
+	if err := printComputers(&out, computers); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := `NAME            STATUS               IDLE   EXECUTORS  LABELS
+jenkins-node01  online               false  1/2        gce,linux
+jenkins-node02  temporarily offline  true   0/1        -
+`
+	if got := out.String(); got != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, got)
+	}
+}
+
+func TestNodeStatus(t *testing.T) {
+	server := newFakeJenkins()
+	defer server.Close()
+
+	c, err := getComputer(server.URL, "jenkins-node02")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bytes.Buffer
+	printComputerStatus(&out, c)
+	want := `jenkins-node02:
+  Status:      offline
+  Reason:      disk full
+  Idle:        true
+  Executors:   1 (0 busy)
+  Labels:      -
+`
+	if got := out.String(); got != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, got)
+	}
+	if _, err := getComputer(server.URL, "jenkins-node03"); !isNotFound(err) {
+		t.Fatalf("want a not found error, got %v", err)
+	}
+}
+
+func TestMarkComputerOffline(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("offlineMessage"))
+	}))
+	defer server.Close()
+
+	online := computer{DisplayName: "jenkins-node01"}
+	if err := markComputerOffline(server.URL, online, "Recreating node"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	offline := computer{DisplayName: "jenkins-node02", Offline: true, TemporarilyOffline: true}
+	if err := markComputerOffline(server.URL, offline, "Recreating node"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []string{"POST /computer/jenkins-node01/toggleOffline Recreating node"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Fatalf("want %v, got %v", want, requests)
+	}
+}
+
+func TestSetComputerLabels(t *testing.T) {
+	config := `<?xml version="1.0" encoding="UTF-8"?>
+<slave>
+  <name>jenkins-node01</name>
+  <label>jenkins-node01</label>
+</slave>`
+	var posted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/computer/jenkins-node01/config.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "POST" {
+			body, _ := ioutil.ReadAll(r.Body)
+			posted = string(body)
+			return
+		}
+		fmt.Fprint(w, config)
+	}))
+	defer server.Close()
+
+	if err := setComputerLabels(server.URL, "jenkins-node01", []string{"gce", "linux&mac"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := strings.Replace(config, "<label>jenkins-node01</label>", "<label>gce linux&amp;mac</label>", 1)
+	if posted != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, posted)
+	}
+	if _, err := replaceConfigLabels([]byte("<slave></slave>"), []string{"gce"}); err == nil {
+		t.Fatalf("replacing the labels of a config without a label element did not fail")
+	}
+	if got, err := replaceConfigLabels([]byte("<slave><label/></slave>"), []string{"gce"}); err != nil || string(got) != "<slave><label>gce</label></slave>" {
+		t.Fatalf("got %s, %v", got, err)
+	}
+}
@@ -9,18 +9,31 @@
 Command vjenkins implements Vanadium-specific utilities for interacting with
 Jenkins.
 
+The defaults of the -host and -credentials-id flags can be overridden with the
+VJENKINS_HOST and VJENKINS_CREDENTIALS_ID environment variables, so that the
+tool can be pointed at another Jenkins master without passing the flags to each
+command. Flags given on the command line take precedence. The host is checked
+to be an http or https URL regardless of where it comes from.
+
 Usage:
    vjenkins [flags] <command>
 
 The vjenkins commands are:
+   audit       Audit the health of the Jenkins master
    node        Manage Jenkins slave nodes
    help        Display help for commands or topics
 
 The vjenkins flags are:
  -color=true
    Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
  -jenkins=http://localhost:8080/jenkins
-   The host of the Jenkins master.
+   Deprecated alias of -host.
  -v=false
    Print verbose output.
 
@@ -30,6 +43,45 @@ The global flags are:
  -time=false
    Dump timing information to stderr before exiting the program.
 
+Vjenkins audit - Audit the health of the Jenkins master
+
+Audit the health of the Jenkins master. Uses the Jenkins REST API to collect the
+versions of the installed plugins and whether updates are available for them,
+the length of the build queue, the utilization of the executors of the online
+nodes, and the nodes that are disconnected. The security warnings published by
+the update center are matched against the installed plugin versions.
+
+The result of the audit is printed as JSON. If the master is unhealthy, because
+plugins are affected by security warnings, the queue or the executor
+utilization exceed the given limits, or nodes are disconnected, the problems are
+listed in the result and the command exits with code 2, so that it can be run
+by a periodic monitoring job.
+
+Usage:
+   vjenkins audit [flags]
+
+The vjenkins audit flags are:
+ -max-executor-utilization=0.9
+   The maximum fraction of the executors of the online nodes that can be busy.
+ -max-queue-length=20
+   The maximum number of builds that can wait in the build queue.
+ -update-center=https://updates.jenkins.io/update-center.actual.json
+   The URL of the update center data that lists the security warnings of the
+   plugins. If empty, the security warnings are not checked.
+
+ -color=true
+   Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
+ -jenkins=http://localhost:8080/jenkins
+   Deprecated alias of -host.
+ -v=false
+   Print verbose output.
+
 Vjenkins node - Manage Jenkins slave nodes
 
 Manage Jenkins slave nodes.
@@ -40,12 +92,21 @@ Usage:
 The vjenkins node commands are:
    create      Create Jenkins slave nodes
    delete      Delete Jenkins slave nodes
+   list        List Jenkins slave nodes
+   recreate    Recreate Jenkins slave nodes
+   status      Show the status of Jenkins slave nodes
 
 The vjenkins node flags are:
  -color=true
    Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
  -jenkins=http://localhost:8080/jenkins
-   The host of the Jenkins master.
+   Deprecated alias of -host.
  -v=false
    Print verbose output.
 
@@ -59,8 +120,6 @@ Usage:
 <names> is a list of names identifying nodes to be created.
 
 The vjenkins node create flags are:
- -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
-   The credentials ID used to connect the master to the node.
  -description=
    Node description.
  -project=vanadium-internal
@@ -70,8 +129,14 @@ The vjenkins node create flags are:
 
  -color=true
    Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
  -jenkins=http://localhost:8080/jenkins
-   The host of the Jenkins master.
+   Deprecated alias of -host.
  -v=false
    Print verbose output.
 
@@ -87,8 +152,94 @@ Usage:
 The vjenkins node delete flags are:
  -color=true
    Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
+ -jenkins=http://localhost:8080/jenkins
+   Deprecated alias of -host.
+ -v=false
+   Print verbose output.
+
+Vjenkins node list - List Jenkins slave nodes
+
+List Jenkins nodes. Uses the Jenkins REST API to print a table of all nodes,
+showing whether each node is online, whether it is idle, the number of its busy
+and total executors, and its labels.
+
+Usage:
+   vjenkins node list [flags]
+
+The vjenkins node list flags are:
+ -color=true
+   Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
+ -jenkins=http://localhost:8080/jenkins
+   Deprecated alias of -host.
+ -v=false
+   Print verbose output.
+
+Vjenkins node recreate - Recreate Jenkins slave nodes
+
+Recreate Jenkins nodes. Uses the Jenkins REST API to re-provision existing slave
+nodes. Each node is marked temporarily offline so that no new builds are
+scheduled on it, and once its running builds finish, it is deleted and created
+again using the current IP address of the GCE machine and the previous node
+description and labels. Nodes that do not exist are created.
+
+Usage:
+   vjenkins node recreate [flags] <names>
+
+<names> is a list of names identifying nodes to be recreated.
+
+The vjenkins node recreate flags are:
+ -project=vanadium-internal
+   GCE project of the machine.
+ -zone=us-central1-f
+   GCE zone of the machine.
+
+ -color=true
+   Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
+ -jenkins=http://localhost:8080/jenkins
+   Deprecated alias of -host.
+ -v=false
+   Print verbose output.
+
+Vjenkins node status - Show the status of Jenkins slave nodes
+
+Show the status of Jenkins nodes. Uses the Jenkins REST API to print detailed
+information about the given nodes, including the reason why a node is offline
+and the builds running on the node.
+
+Usage:
+   vjenkins node status [flags] <names>
+
+<names> is a list of names identifying nodes to show the status of.
+
+The vjenkins node status flags are:
+ -color=true
+   Use color to format output.
+ -credentials-id=73f76f53-8332-4259-bc08-d6f0b8521a5b
+   The credentials ID used to connect the master to the node. Defaults to
+   $VJENKINS_CREDENTIALS_ID if set.
+ -host=http://localhost:8080/jenkins
+   The http or https URL of the Jenkins master. Defaults to $VJENKINS_HOST if
+   set.
  -jenkins=http://localhost:8080/jenkins
-   The host of the Jenkins master.
+   Deprecated alias of -host.
  -v=false
    Print verbose output.
 
@@ -11,9 +11,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
 	"regexp"
 	"time"
 
+	"v.io/jiri/jenkins"
 	"v.io/jiri/tool"
 	"v.io/x/lib/cmdline"
 )
@@ -32,15 +35,21 @@ var cmdVJenkins = &cmdline.Command{
 	Long: `
 Command vjenkins implements Vanadium-specific utilities for interacting with
 Jenkins.
+
+The defaults of the -host and -credentials-id flags can be overridden with the
+VJENKINS_HOST and VJENKINS_CREDENTIALS_ID environment variables, so that the
+tool can be pointed at another Jenkins master without passing the flags to each
+command. Flags given on the command line take precedence. The host is checked
+to be an http or https URL regardless of where it comes from.
 `,
-	Children: []*cmdline.Command{cmdNode},
+	Children: []*cmdline.Command{cmdAudit, cmdNode},
 }
 
 var cmdNode = &cmdline.Command{
 	Name:     "node",
 	Short:    "Manage Jenkins slave nodes",
 	Long:     "Manage Jenkins slave nodes.",
-	Children: []*cmdline.Command{cmdNodeCreate, cmdNodeDelete},
+	Children: []*cmdline.Command{cmdNodeCreate, cmdNodeDelete, cmdNodeList, cmdNodeRecreate, cmdNodeStatus},
 }
 
 var cmdNodeCreate = &cmdline.Command{
@@ -65,6 +74,45 @@ Delete Jenkins nodes. Uses the Jenkins REST API to delete existing slave nodes.
 	ArgsLong: "<names> is a list of names identifying nodes to be deleted.",
 }
 
+var cmdNodeList = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runNodeList),
+	Name:   "list",
+	Short:  "List Jenkins slave nodes",
+	Long: `
+List Jenkins nodes. Uses the Jenkins REST API to print a table of all nodes,
+showing whether each node is online, whether it is idle, the number of its busy
+and total executors, and its labels.
+`,
+}
+
+var cmdNodeRecreate = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runNodeRecreate),
+	Name:   "recreate",
+	Short:  "Recreate Jenkins slave nodes",
+	Long: `
+Recreate Jenkins nodes. Uses the Jenkins REST API to re-provision existing slave
+nodes. Each node is marked temporarily offline so that no new builds are
+scheduled on it, and once its running builds finish, it is deleted and created
+again using the current IP address of the GCE machine and the previous node
+description and labels. Nodes that do not exist are created.
+`,
+	ArgsName: "<names>",
+	ArgsLong: "<names> is a list of names identifying nodes to be recreated.",
+}
+
+var cmdNodeStatus = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runNodeStatus),
+	Name:   "status",
+	Short:  "Show the status of Jenkins slave nodes",
+	Long: `
+Show the status of Jenkins nodes. Uses the Jenkins REST API to print detailed
+information about the given nodes, including the reason why a node is offline
+and the builds running on the node.
+`,
+	ArgsName: "<names>",
+	ArgsLong: "<names> is a list of names identifying nodes to show the status of.",
+}
+
 var (
 	flagCredentialsId string
 	flagDescription   string
@@ -75,12 +123,58 @@ var (
 	ipAddressRE = regexp.MustCompile(`^(\S*)\s*(\S*)\s(\S*)\s(\S*)\s(\S*)\s(\S*)$`)
 )
 
+const (
+	// credentialsIdEnv is the environment variable that overrides the
+	// default of the -credentials-id flag.
+	credentialsIdEnv = "VJENKINS_CREDENTIALS_ID"
+	// jenkinsHostEnv is the environment variable that overrides the
+	// default of the -host flag.
+	jenkinsHostEnv = "VJENKINS_HOST"
+
+	defaultCredentialsId = "73f76f53-8332-4259-bc08-d6f0b8521a5b"
+	defaultJenkinsHost   = "http://localhost:8080/jenkins"
+)
+
+// checkHost checks that the given host of the Jenkins master, which can come
+// from the -host flag or its environment override, is an http or https URL.
+func checkHost(host string) error {
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid Jenkins host %q: want an http or https URL, set with -host or $%s", host, jenkinsHostEnv)
+	}
+	return nil
+}
+
+// checkCredentialsId checks that the credentials ID used to connect the
+// master to nodes, which can come from the -credentials-id flag or its
+// environment override, is set.
+func checkCredentialsId(id string) error {
+	if id == "" {
+		return fmt.Errorf("no credentials ID: set it with -credentials-id or $%s", credentialsIdEnv)
+	}
+	return nil
+}
+
+// envOrDefault returns the value of the given environment variable, or
+// the given default if the variable is not set.
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func init() {
-	cmdVJenkins.Flags.StringVar(&flagJenkinsHost, "jenkins", "http://localhost:8080/jenkins", "The host of the Jenkins master.")
-	cmdNodeCreate.Flags.StringVar(&flagCredentialsId, "credentials-id", "73f76f53-8332-4259-bc08-d6f0b8521a5b", "The credentials ID used to connect the master to the node.")
+	jenkinsHost := envOrDefault(jenkinsHostEnv, defaultJenkinsHost)
+	credentialsId := envOrDefault(credentialsIdEnv, defaultCredentialsId)
+	cmdVJenkins.Flags.StringVar(&flagJenkinsHost, "host", jenkinsHost, "The http or https URL of the Jenkins master. Defaults to $"+jenkinsHostEnv+" if set.")
+	cmdVJenkins.Flags.StringVar(&flagJenkinsHost, "jenkins", jenkinsHost, "Deprecated alias of -host.")
+	cmdVJenkins.Flags.StringVar(&flagCredentialsId, "credentials-id", credentialsId, "The credentials ID used to connect the master to the node. Defaults to $"+credentialsIdEnv+" if set.")
 	cmdNodeCreate.Flags.StringVar(&flagDescription, "description", "", "Node description.")
 	cmdNodeCreate.Flags.StringVar(&flagZone, "zone", "us-central1-f", "GCE zone of the machine.")
 	cmdNodeCreate.Flags.StringVar(&flagProject, "project", "vanadium-internal", "GCE project of the machine.")
+	cmdNodeRecreate.Flags.StringVar(&flagZone, "zone", "us-central1-f", "GCE zone of the machine.")
+	cmdNodeRecreate.Flags.StringVar(&flagProject, "project", "vanadium-internal", "GCE project of the machine.")
 
 	tool.InitializeRunFlags(&cmdVJenkins.Flags)
 }
@@ -117,6 +211,12 @@ func lookupIPAddress(ctx *tool.Context, node string) (string, error) {
 
 // runNodeCreate adds slave node(s) to Jenkins configuration.
 func runNodeCreate(env *cmdline.Env, args []string) error {
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
+	if err := checkCredentialsId(flagCredentialsId); err != nil {
+		return err
+	}
 	ctx := newContext(env)
 	jenkins, err := ctx.Jenkins(flagJenkinsHost)
 	if err != nil {
@@ -124,20 +224,78 @@ func runNodeCreate(env *cmdline.Env, args []string) error {
 	}
 
 	for _, name := range args {
-		ipAddress, err := lookupIPAddress(ctx, name)
-		if err != nil {
+		if err := addNode(ctx, jenkins, name, flagDescription); err != nil {
 			return err
 		}
-		fmt.Println(ipAddress)
-		if err := jenkins.AddNodeToJenkins(name, ipAddress, flagDescription, flagCredentialsId); err != nil {
+	}
+	return nil
+}
+
+// addNode adds the given GCE node to Jenkins configuration.
+func addNode(ctx *tool.Context, jenkins *jenkins.Jenkins, name, description string) error {
+	ipAddress, err := lookupIPAddress(ctx, name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.Stdout(), ipAddress)
+	return jenkins.AddNodeToJenkins(name, ipAddress, description, flagCredentialsId)
+}
+
+// removeNode waits for the given node to become idle and then removes it
+// from Jenkins configuration.
+func removeNode(jenkins *jenkins.Jenkins, node string) error {
+	// Wait for the node to become idle.
+	const numRetries = 60
+	const retryPeriod = time.Minute
+	for i := 0; i < numRetries; i++ {
+		if ok, err := jenkins.IsNodeIdle(node); err != nil {
 			return err
+		} else if ok {
+			break
 		}
+		time.Sleep(retryPeriod)
+	}
+	return jenkins.RemoveNodeFromJenkins(node)
+}
+
+// runNodeList lists the slave nodes of the Jenkins master.
+func runNodeList(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
+	computers, err := listComputers(flagJenkinsHost)
+	if err != nil {
+		return err
+	}
+	return printComputers(env.Stdout, computers)
+}
+
+// runNodeStatus prints the status of slave node(s) of the Jenkins master.
+func runNodeStatus(env *cmdline.Env, args []string) error {
+	if len(args) == 0 {
+		return env.UsageErrorf("no nodes specified")
+	}
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
+	for _, name := range args {
+		c, err := getComputer(flagJenkinsHost, name)
+		if err != nil {
+			return err
+		}
+		printComputerStatus(env.Stdout, c)
 	}
 	return nil
 }
 
 // runNodeDelete removes slave node(s) from Jenkins configuration.
 func runNodeDelete(env *cmdline.Env, args []string) error {
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
 	ctx := newContext(env)
 	jenkins, err := ctx.Jenkins(flagJenkinsHost)
 	if err != nil {
@@ -145,21 +303,51 @@ func runNodeDelete(env *cmdline.Env, args []string) error {
 	}
 
 	for _, node := range args {
-		// Wait for the node to become idle.
-		const numRetries = 60
-		const retryPeriod = time.Minute
-		for i := 0; i < numRetries; i++ {
-			if ok, err := jenkins.IsNodeIdle(node); err != nil {
+		if err := removeNode(jenkins, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runNodeRecreate drains, removes and re-adds slave node(s) to Jenkins
+// configuration.
+func runNodeRecreate(env *cmdline.Env, args []string) error {
+	if err := checkHost(flagJenkinsHost); err != nil {
+		return err
+	}
+	if err := checkCredentialsId(flagCredentialsId); err != nil {
+		return err
+	}
+	ctx := newContext(env)
+	jenkins, err := ctx.Jenkins(flagJenkinsHost)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		c, err := getComputer(flagJenkinsHost, name)
+		switch {
+		case isNotFound(err):
+			fmt.Fprintf(ctx.Stdout(), "node %v does not exist, creating it\n", name)
+		case err != nil:
+			return err
+		default:
+			if err := markComputerOffline(flagJenkinsHost, c, "Recreating node"); err != nil {
+				return err
+			}
+			if err := removeNode(jenkins, name); err != nil {
 				return err
-			} else if ok {
-				break
 			}
-			time.Sleep(retryPeriod)
 		}
-		err := jenkins.RemoveNodeFromJenkins(node)
-		if err != nil {
+		if err := addNode(ctx, jenkins, name, c.Description); err != nil {
 			return err
 		}
+		if labels := c.Labels(); len(labels) > 0 {
+			if err := setComputerLabels(flagJenkinsHost, name, labels); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
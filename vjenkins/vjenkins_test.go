@@ -0,0 +1,27 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"http://localhost:8080/jenkins", true},
+		{"https://jenkins.example.com", true},
+		{"localhost:8080", false},
+		{"jenkins", false},
+		{"ftp://jenkins.example.com", false},
+		{"http://", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if err := checkHost(test.host); (err == nil) != test.ok {
+			t.Errorf("checkHost(%q) returned %v, want ok=%v", test.host, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"v.io/jiri/tool"
+	"v.io/v23/context"
+	"v.io/x/devtools/internal/test"
+	"v.io/x/lib/gcm"
+)
+
+const (
+	// certMetadataName is the metadata name under which the days until
+	// the certificates of a TLS endpoint expire are sent to GCM.
+	certMetadataName = "cert expiry days"
+
+	defaultCertEndpoints = "identity service=dev.v.io:443"
+)
+
+// certEndpoint is a TLS endpoint of a production service.
+type certEndpoint struct {
+	serviceName string
+	address     string
+}
+
+// parseCertEndpoints parses the given comma-separated list of
+// <service>=<host:port> TLS endpoints.
+func parseCertEndpoints(value string) ([]certEndpoint, error) {
+	endpoints := []certEndpoint{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid TLS endpoint %q, want <service>=<host:port>", item)
+		}
+		if _, _, err := net.SplitHostPort(parts[1]); err != nil {
+			return nil, fmt.Errorf("invalid TLS endpoint %q: %v", item, err)
+		}
+		endpoints = append(endpoints, certEndpoint{serviceName: parts[0], address: parts[1]})
+	}
+	return endpoints, nil
+}
+
+// checkCertExpiry checks the certificates of the TLS endpoints of
+// production services and adds the days until they expire to GCM.
+func checkCertExpiry(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
+	endpoints, err := parseCertEndpoints(certEndpointsFlag)
+	if err != nil {
+		return err
+	}
+	mdMetadata, err := gcm.GetMetric("service-metadata", projectFlag)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	strNow := now.UTC().Format(time.RFC3339)
+	hasError := false
+	for _, e := range endpoints {
+		label := fmt.Sprintf("%s (%s)", e.serviceName, e.address)
+		cert, err := getCertificateChain(e.address, timeout)
+		if err != nil {
+			test.Fail(ctx, "%s\n", label)
+			fmt.Fprintf(ctx.Stderr(), "%v\n", err)
+			hasError = true
+			continue
+		}
+		expiring := earliestExpiry(cert)
+		days := expiring.NotAfter.Sub(now).Hours() / 24
+
+		// Send data to GCM.
+		if err := sendDataToGCM(s, mdMetadata, days, strNow, e.address, "global", e.serviceName, certMetadataName); err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf("%s: %q expires in %.1f days\n", label, expiring.Subject.CommonName, days)
+		if days < float64(certExpiryDaysFlag) {
+			test.Fail(ctx, msg)
+			hasError = true
+		} else {
+			test.Pass(ctx, msg)
+		}
+	}
+	if hasError {
+		return fmt.Errorf("Failed to check some certificates or some certificates expire in less than %d days.", certExpiryDaysFlag)
+	}
+	return nil
+}
+
+// getCertificateChain connects to the TLS endpoint with the given
+// address and returns the certificate chain it presents. The chain is
+// not verified, so that the expiry of invalid or expired certificates
+// can be reported too.
+func getCertificateChain(address string, timeout time.Duration) ([]*x509.Certificate, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Dial(%v) failed: %v", address, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%v presented no certificates", address)
+	}
+	return certs, nil
+}
+
+// earliestExpiry returns the certificate of the given chain that
+// expires first, which determines when the chain expires.
+func earliestExpiry(chain []*x509.Certificate) *x509.Certificate {
+	earliest := chain[0]
+	for _, cert := range chain[1:] {
+		if cert.NotAfter.Before(earliest.NotAfter) {
+			earliest = cert
+		}
+	}
+	return earliest
+}
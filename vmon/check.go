@@ -9,26 +9,47 @@ import (
 	"sort"
 	"strings"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
+	"v.io/jiri/collect"
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/lib/cmdline"
-	"v.io/x/lib/gcm"
 	"v.io/x/ref/lib/v23cmd"
 )
 
-// checkFunctions is a map from check names to the corresponding check functions.
-var checkFunctions = map[string]func(*context.T, *tool.Context, *cloudmonitoring.Service) error{
-	"cloud-syncbase":            checkCloudSyncbaseInstances,
-	"jenkins":                   checkJenkins,
-	"service-latency":           checkServiceLatency,
-	"service-permethod-latency": checkServicePerMethodLatency,
-	"service-counters":          checkServiceCounters,
-	"service-metadata":          checkServiceMetadata,
-	"service-qps":               checkServiceQPS,
-	"gce-instance":              checkGCEInstances,
-	"rpc-load-test":             checkRPCLoadTest,
+// checkFunc is the type of the functions that implement checks.
+type checkFunc func(*context.T, *tool.Context, metricSink) error
+
+// checkSpec describes a registered check.
+type checkSpec struct {
+	// description is a short description of the check.
+	description string
+	// fn implements the check.
+	fn checkFunc
+}
+
+// checks is the registry of known checks, indexed by check names.
+var checks = map[string]checkSpec{}
+
+// registerCheck registers the given check function under the given name.
+func registerCheck(name, description string, fn checkFunc) {
+	if _, ok := checks[name]; ok {
+		panic(fmt.Sprintf("check %q registered twice", name))
+	}
+	checks[name] = checkSpec{description: description, fn: fn}
+}
+
+func init() {
+	registerCheck("cert-expiry", "Checks the expiry of the certificates of the TLS endpoints of production services.", checkCertExpiry)
+	registerCheck("cloud-syncbase", "Checks the stats of cloud syncbase instances.", checkCloudSyncbaseInstances)
+	registerCheck("gce-instance", "Checks the ping latency, machine stats and nginx health of GCE instances.", checkGCEInstances)
+	registerCheck("jenkins", "Checks the age of the last vanadium-go-build run.", checkJenkins)
+	registerCheck("nginx", "Checks the health of the nginx workers.", checkNginx)
+	registerCheck("rpc-load-test", "Checks the results of the RPC load test.", checkRPCLoadTest)
+	registerCheck("service-counters", "Checks the counters of production services.", checkServiceCounters)
+	registerCheck("service-latency", "Checks the latency of production services.", checkServiceLatency)
+	registerCheck("service-metadata", "Checks the metadata of production services.", checkServiceMetadata)
+	registerCheck("service-permethod-latency", "Checks the per-method latency of production services.", checkServicePerMethodLatency)
+	registerCheck("service-qps", "Checks the QPS of production services.", checkServiceQPS)
 }
 
 // cmdCheck represents the "check" command of the vmon tool.
@@ -47,17 +68,19 @@ var cmdCheckList = &cmdline.Command{
 	Runner: v23cmd.RunnerFunc(runCheckList),
 	Name:   "list",
 	Short:  "List known checks",
-	Long:   "List known checks.",
+	Long:   "List known checks and their descriptions.",
 }
 
 func runCheckList(_ *context.T, env *cmdline.Env, _ []string) error {
-	checks := []string{}
-	for name := range checkFunctions {
-		checks = append(checks, name)
+	names := knownCheckNames()
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
 	}
-	sort.Strings(checks)
-	for _, check := range checks {
-		fmt.Fprintf(env.Stdout, "%v\n", check)
+	for _, name := range names {
+		fmt.Fprintf(env.Stdout, "%-*s  %s\n", width, name, checks[name].description)
 	}
 	return nil
 }
@@ -67,36 +90,34 @@ var cmdCheckRun = &cmdline.Command{
 	Runner:   v23cmd.RunnerFunc(runCheckRun),
 	Name:     "run",
 	Short:    "Run the given checks",
-	Long:     "Run the given checks.",
-	ArgsName: "<names>",
-	ArgsLong: "<names> is a list of names identifying the checks to run. Available: " + strings.Join(knownCheckNames(), ", "),
+	Long:     "Run the given checks, identified by the arguments and the -checks flag.",
+	ArgsName: "[names]",
+	ArgsLong: "[names] is a list of names identifying the checks to run. Use 'vmon check list' to list the available checks.",
 }
 
-func runCheckRun(v23ctx *context.T, env *cmdline.Env, args []string) error {
+func runCheckRun(v23ctx *context.T, env *cmdline.Env, args []string) (e error) {
 	// Check args.
-	for _, arg := range args {
-		if _, ok := checkFunctions[arg]; !ok {
-			return env.UsageErrorf("check %v does not exist", arg)
-		}
-	}
-	if len(args) == 0 {
-		return env.UsageErrorf("no checks provided")
+	names, err := selectChecks(checksFlag, args)
+	if err != nil {
+		return env.UsageErrorf("%v", err)
 	}
 	ctx := tool.NewContextFromEnv(env)
 
-	// Authenticate monitoring APIs.
-	s, err := gcm.Authenticate(keyFileFlag)
+	// Set up the sink the checks write their data to.
+	s, closeSink, err := newSink(sinkFlag, ctx.Stdout())
 	if err != nil {
 		return err
 	}
+	defer collect.Error(closeSink, &e)
 
 	// Run checks.
 	hasError := false
-	for _, check := range args {
-		// We already checked the given checks all exist.
-		checkFn, _ := checkFunctions[check]
-		fmt.Fprintf(ctx.Stdout(), "##### Running check %q #####\n", check)
-		err := checkFn(v23ctx, ctx, s)
+	for _, name := range names {
+		fmt.Fprintf(ctx.Stdout(), "##### Running check %q #####\n", name)
+		err := checks[name].fn(v23ctx, ctx, s)
+		if flushErr := s.Flush(); err == nil {
+			err = flushErr
+		}
 		if err != nil {
 			fmt.Fprintf(ctx.Stderr(), "%v\n", err)
 			fmt.Fprintf(ctx.Stdout(), "##### FAIL #####\n")
@@ -112,9 +133,31 @@ func runCheckRun(v23ctx *context.T, env *cmdline.Env, args []string) error {
 	return nil
 }
 
+// selectChecks returns the names of the checks identified by the given
+// comma-separated list and arguments, without duplicates.
+func selectChecks(list string, args []string) ([]string, error) {
+	names, seen := []string{}, map[string]bool{}
+	if list != "" {
+		args = append(strings.Split(list, ","), args...)
+	}
+	for _, name := range args {
+		if _, ok := checks[name]; !ok {
+			return nil, fmt.Errorf("check %v does not exist", name)
+		}
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no checks provided")
+	}
+	return names, nil
+}
+
 func knownCheckNames() []string {
 	names := []string{}
-	for n := range checkFunctions {
+	for n := range checks {
 		names = append(names, n)
 	}
 	sort.Strings(names)
@@ -59,7 +59,7 @@ type metricData struct {
 	value       float64
 }
 
-func checkCloudSyncbaseInstances(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkCloudSyncbaseInstances(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	v23ctx, cancel := context.WithTimeout(v23ctx, cloudSyncbaseTimeout)
 	defer cancel()
 
@@ -137,7 +137,7 @@ func checkCloudSyncbaseInstances(v23ctx *context.T, ctx *tool.Context, s *cloudm
 // statsWorker queries stats based on the task type, sends the results to GCM,
 // and updates the corresponding aggregator.
 func statsWorker(
-	v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service,
+	v23ctx *context.T, ctx *tool.Context, s metricSink,
 	now string, aggsMu *sync.Mutex, aggs map[string]*aggregator, md *cloudmonitoring.MetricDescriptor,
 	tasks <-chan cloudSyncbaseStatsTask, results chan<- cloudSyncbaseStatsResult) {
 	for t := range tasks {
@@ -8,20 +8,29 @@
 package main
 
 import (
+	"time"
+
 	"v.io/jiri/tool"
+	"v.io/x/devtools/internal/test"
 	"v.io/x/lib/cmdline"
 
 	_ "v.io/x/ref/runtime/factories/roaming"
 )
 
 var (
-	binDirFlag        string
-	blessingsRootFlag string
-	credentialsFlag   string
-	keyFileFlag       string
-	namespaceRootFlag string
-	queryFilterFlag   string
-	projectFlag       string
+	binDirFlag         string
+	blessingsRootFlag  string
+	certEndpointsFlag  string
+	certExpiryDaysFlag int
+	checksFlag         string
+	credentialsFlag    string
+	intervalFlag       time.Duration
+	keyFileFlag        string
+	namespaceRootFlag  string
+	queryFilterFlag    string
+	projectFlag        string
+	samplesFlag        int
+	sinkFlag           string
 
 	defaultQueryFilter = `metric.type=starts_with("custom.googleapis.com")`
 )
@@ -32,10 +41,17 @@ func init() {
 	cmdMetricDescriptorQuery.Flags.StringVar(&queryFilterFlag, "filter", defaultQueryFilter, "The filter used for query. Default to only query custom metrics.")
 	cmdCheck.Flags.StringVar(&binDirFlag, "bin-dir", "", "The path where all binaries are downloaded.")
 	cmdCheck.Flags.StringVar(&blessingsRootFlag, "root", "dev.v.io", "The blessings root.")
+	cmdCheck.Flags.StringVar(&certEndpointsFlag, "cert-endpoints", defaultCertEndpoints, "Comma-separated list of <service>=<host:port> TLS endpoints of production services whose certificates are checked by the cert-expiry check.")
+	cmdCheck.Flags.IntVar(&certExpiryDaysFlag, "cert-expiry-days", 14, "The number of days until a certificate expires below which the cert-expiry check fails.")
+	cmdCheck.Flags.DurationVar(&intervalFlag, "interval", time.Second, "The interval between the probes of each service instance by the service-latency check.")
+	cmdCheck.Flags.IntVar(&samplesFlag, "samples", 1, "The number of times the service-latency check probes each service instance per run.")
 	cmdCheck.Flags.StringVar(&namespaceRootFlag, "v23.namespace.root", "/ns.dev.v.io:8101", "The namespace root.")
 	cmdCheck.Flags.StringVar(&credentialsFlag, "v23.credentials", "", "The path to v23 credentials.")
+	cmdCheckRun.Flags.StringVar(&sinkFlag, "sink", "gcm", "Where to write the check data: 'gcm' for Google Cloud Monitoring, 'stdout' for JSON lines on the standard output, or 'file:<path>' for JSON lines in the given file.")
+	cmdCheckRun.Flags.StringVar(&checksFlag, "checks", "", "Comma-separated list of checks to run, in addition to the checks given as arguments.")
 
 	tool.InitializeRunFlags(&cmdRoot.Flags)
+	test.InitializeLogFlags(&cmdRoot.Flags, "vmon")
 }
 
 func main() {
@@ -21,6 +21,11 @@ The vmon flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -103,6 +108,11 @@ The vmon md flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -127,6 +137,11 @@ The vmon md create flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -151,6 +166,11 @@ The vmon md delete flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -168,6 +188,11 @@ The vmon md list flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -188,6 +213,11 @@ The vmon md query flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -207,8 +237,20 @@ The vmon check commands are:
 The vmon check flags are:
  -bin-dir=
    The path where all binaries are downloaded.
+ -cert-endpoints=identity service=dev.v.io:443
+   Comma-separated list of <service>=<host:port> TLS endpoints of production
+   services whose certificates are checked by the cert-expiry check.
+ -cert-expiry-days=14
+   The number of days until a certificate expires below which the cert-expiry
+   check fails.
+ -interval=1s
+   The interval between the probes of each service instance by the
+   service-latency check.
  -root=dev.v.io
    The blessings root.
+ -samples=1
+   The number of times the service-latency check probes each service instance
+   per run.
  -v23.credentials=
    The path to v23 credentials.
  -v23.namespace.root=/ns.dev.v.io:8101
@@ -218,6 +260,11 @@ The vmon check flags are:
    Use color to format output.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -v=false
@@ -225,7 +272,7 @@ The vmon check flags are:
 
 Vmon check list - List known checks
 
-List known checks.
+List known checks and their descriptions.
 
 Usage:
    vmon check list [flags]
@@ -233,14 +280,31 @@ Usage:
 The vmon check list flags are:
  -bin-dir=
    The path where all binaries are downloaded.
+ -cert-endpoints=identity service=dev.v.io:443
+   Comma-separated list of <service>=<host:port> TLS endpoints of production
+   services whose certificates are checked by the cert-expiry check.
+ -cert-expiry-days=14
+   The number of days until a certificate expires below which the cert-expiry
+   check fails.
  -color=true
    Use color to format output.
+ -interval=1s
+   The interval between the probes of each service instance by the
+   service-latency check.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -root=dev.v.io
    The blessings root.
+ -samples=1
+   The number of times the service-latency check probes each service instance
+   per run.
  -v=false
    Print verbose output.
  -v23.credentials=
@@ -250,26 +314,50 @@ The vmon check list flags are:
 
 Vmon check run - Run the given checks
 
-Run the given checks.
+Run the given checks, identified by the arguments and the -checks flag.
 
 Usage:
-   vmon check run [flags] <names>
+   vmon check run [flags] [names]
 
-<names> is a list of names identifying the checks to run. Available:
-cloud-syncbase, gce-instance, jenkins, rpc-load-test, service-counters,
-service-latency, service-metadata, service-permethod-latency, service-qps
+[names] is a list of names identifying the checks to run. Use 'vmon check list'
+to list the available checks.
 
 The vmon check run flags are:
+ -checks=
+   Comma-separated list of checks to run, in addition to the checks given as
+   arguments.
+ -sink=gcm
+   Where to write the check data: 'gcm' for Google Cloud Monitoring, 'stdout'
+   for JSON lines on the standard output, or 'file:<path>' for JSON lines in the
+   given file.
+
  -bin-dir=
    The path where all binaries are downloaded.
+ -cert-endpoints=identity service=dev.v.io:443
+   Comma-separated list of <service>=<host:port> TLS endpoints of production
+   services whose certificates are checked by the cert-expiry check.
+ -cert-expiry-days=14
+   The number of days until a certificate expires below which the cert-expiry
+   check fails.
  -color=true
    Use color to format output.
+ -interval=1s
+   The interval between the probes of each service instance by the
+   service-latency check.
  -key=
    The path to the service account's JSON credentials file.
+ -log-json=false
+   Print log messages as JSON objects, one per line.
+ -log-level=info
+   Minimum level of the log messages to print, one of debug, info, warn or
+   error.
  -project=
    The GCM's corresponding GCE project ID.
  -root=dev.v.io
    The blessings root.
+ -samples=1
+   The number of times the service-latency check probes each service instance
+   per run.
  -v=false
    Print verbose output.
  -v23.credentials=
@@ -18,8 +18,6 @@ import (
 	"strings"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/collect"
 	"v.io/jiri/tool"
 	"v.io/v23/context"
@@ -112,7 +110,7 @@ type nginxStat struct {
 }
 
 // checkGCEInstances checks all GCE instances in a GCE project.
-func checkGCEInstances(_ *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkGCEInstances(_ *context.T, ctx *tool.Context, s metricSink) error {
 	msg := "Getting instance list\n"
 	instances, err := getInstances(ctx)
 	if err != nil {
@@ -133,13 +131,55 @@ func checkGCEInstances(_ *context.T, ctx *tool.Context, s *cloudmonitoring.Servi
 		return err
 	}
 
-	if err := invoker(ctx, "Send data to GCM\n", instances, sendToGCM); err != nil {
+	sendFn := func(ctx *tool.Context, instances []*gceInstanceData) error {
+		return sendToGCM(ctx, s, instances)
+	}
+	if err := invoker(ctx, "Send data to GCM\n", instances, sendFn); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// checkNginx checks the health of the nginx workers in a GCE project.
+func checkNginx(_ *context.T, ctx *tool.Context, s metricSink) error {
+	msg := "Getting instance list\n"
+	instances, err := getInstances(ctx)
+	if err != nil {
+		test.Fail(ctx, msg)
+		return err
+	}
+	test.Pass(ctx, msg)
+
+	workers := []*gceInstanceData{}
+	for _, instance := range instances {
+		if strings.HasPrefix(instance.name, "nginx-worker") {
+			workers = append(workers, instance)
+		}
+	}
+	if err := invoker(ctx, "Check nginx health\n", workers, checkNginxHealth); err != nil {
+		return err
+	}
+
+	timeStr := time.Now().UTC().Format(time.RFC3339)
+	for _, worker := range workers {
+		msg := fmt.Sprintf("Send nginx health data for %s (%s)\n", worker.name, worker.zone)
+		if err := sendInstanceDataToGCM(s, "nginx", "healthCheckLatency", timeStr, worker, worker.nginxStat.healthCheckLatency); err != nil {
+			test.Fail(ctx, msg)
+			return fmt.Errorf("failed to add %q to GCM: %v\n", "healthCheckLatency", err)
+		}
+	}
+	// The sink can buffer the data, so it is only known to be sent
+	// once the sink is flushed.
+	msg = "Send nginx health data to GCM\n"
+	if err := s.Flush(); err != nil {
+		test.Fail(ctx, msg)
+		return err
+	}
+	test.Pass(ctx, msg)
+	return nil
+}
+
 func invoker(ctx *tool.Context, msg string, instances []*gceInstanceData, fn func(*tool.Context, []*gceInstanceData) error) error {
 	if err := fn(ctx, instances); err != nil {
 		test.Fail(ctx, msg)
@@ -380,12 +420,8 @@ func checkNginxHealth(ctx *tool.Context, instances []*gceInstanceData) error {
 	return nil
 }
 
-// sendToGCM sends instance stats data to GCM.
-func sendToGCM(ctx *tool.Context, instances []*gceInstanceData) error {
-	s, err := gcm.Authenticate(keyFileFlag)
-	if err != nil {
-		return err
-	}
+// sendToGCM sends instance stats data to the given sink and flushes it.
+func sendToGCM(ctx *tool.Context, s metricSink, instances []*gceInstanceData) error {
 	timeStr := time.Now().UTC().Format(time.RFC3339)
 	for _, instance := range instances {
 		msg := fmt.Sprintf("Send gce instance data for %s (%s)\n", instance.name, instance.zone)
@@ -445,14 +481,14 @@ func sendToGCM(ctx *tool.Context, instances []*gceInstanceData) error {
 				return fmt.Errorf("failed to add %q to GCM: %v\n", metricName, err)
 			}
 		}
-
-		test.Pass(ctx, msg)
 	}
-	return nil
+	// The sink can buffer the data, so it is only known to be sent
+	// once the sink is flushed.
+	return s.Flush()
 }
 
 // sendInstanceDataToGCM sends a single instance's stat to GCM.
-func sendInstanceDataToGCM(s *cloudmonitoring.Service, metricType, metricName, timeStr string, instance *gceInstanceData, value float64) error {
+func sendInstanceDataToGCM(s metricSink, metricType, metricName, timeStr string, instance *gceInstanceData, value float64) error {
 	md, err := gcm.GetMetric(metricType, projectFlag)
 	if err != nil {
 		return err
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/devtools/internal/test"
@@ -19,7 +18,7 @@ const (
 	jenkinsHost = "http://127.0.0.1/jenkins"
 )
 
-func checkJenkins(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkJenkins(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	// Query Jenkins for the last vanadium-go-build run.
 	j, err := ctx.Jenkins(jenkinsHost)
 	if err != nil {
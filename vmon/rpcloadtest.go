@@ -11,8 +11,6 @@ import (
 	"path/filepath"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/devtools/internal/test"
@@ -20,7 +18,7 @@ import (
 )
 
 // checkRPCLoadTest checks the result of RPC load test and sends the result to GCM.
-func checkRPCLoadTest(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkRPCLoadTest(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	// Parse result file.
 	seq := ctx.NewSeq()
 	resultFile := filepath.Join(os.Getenv("WORKSPACE"), "load_stats.json")
@@ -68,8 +68,9 @@ func (sv *statValue) getFloat64Value() (float64, error) {
 	}
 }
 
-// sendDataToGCM sends the given metric to Google Cloud Monitoring.
-func sendDataToGCM(s *cloudmonitoring.Service, md *cloudmonitoring.MetricDescriptor, value float64, now, instance, zone string, extraLabelKeys ...string) error {
+// sendDataToGCM sends the given metric to the given sink, which is Google
+// Cloud Monitoring unless -sink says otherwise.
+func sendDataToGCM(s metricSink, md *cloudmonitoring.MetricDescriptor, value float64, now, instance, zone string, extraLabelKeys ...string) error {
 	// Sending value 0 will cause error.
 	if math.Abs(value) < 1e-7 {
 		return nil
@@ -92,32 +93,29 @@ func sendDataToGCM(s *cloudmonitoring.Service, md *cloudmonitoring.MetricDescrip
 	for i := range labels {
 		labelsMap[md.Labels[i].Key] = labels[i]
 	}
-	if _, err := s.Projects.TimeSeries.Create(fmt.Sprintf("projects/%s", projectFlag), &cloudmonitoring.CreateTimeSeriesRequest{
-		TimeSeries: []*cloudmonitoring.TimeSeries{
-			&cloudmonitoring.TimeSeries{
-				Metric: &cloudmonitoring.Metric{
-					Type:   md.Type,
-					Labels: labelsMap,
+	if err := s.Write(&cloudmonitoring.TimeSeries{
+		Metric: &cloudmonitoring.Metric{
+			Type:   md.Type,
+			Labels: labelsMap,
+		},
+		Points: []*cloudmonitoring.Point{
+			&cloudmonitoring.Point{
+				Value: &cloudmonitoring.TypedValue{
+					DoubleValue: value,
 				},
-				Points: []*cloudmonitoring.Point{
-					&cloudmonitoring.Point{
-						Value: &cloudmonitoring.TypedValue{
-							DoubleValue: value,
-						},
-						Interval: &cloudmonitoring.TimeInterval{
-							StartTime: now,
-							EndTime:   now,
-						},
-					},
+				Interval: &cloudmonitoring.TimeInterval{
+					StartTime: now,
+					EndTime:   now,
 				},
 			},
-		}}).Do(); err != nil {
+		},
+	}); err != nil {
 		return fmt.Errorf("Timeseries Write failed for metric %q with value %f and labels %v: %v", md.Name, value, labels, err)
 	}
 	return nil
 }
 
-func sendAggregatedDataToGCM(ctx *tool.Context, s *cloudmonitoring.Service, md *cloudmonitoring.MetricDescriptor, agg *aggregator, now string, extraLabelKeys ...string) error {
+func sendAggregatedDataToGCM(ctx *tool.Context, s metricSink, md *cloudmonitoring.MetricDescriptor, agg *aggregator, now string, extraLabelKeys ...string) error {
 	labels := []string{}
 	for _, l := range extraLabelKeys {
 		labels = append(labels, l)
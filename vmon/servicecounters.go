@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/devtools/internal/monitoring"
@@ -28,7 +26,7 @@ type counterData struct {
 }
 
 // checkServiceCounters checks all service counters and adds the results to GCM.
-func checkServiceCounters(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkServiceCounters(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	counters := map[string][]prodServiceCounter{
 		monitoring.SNMounttable: []prodServiceCounter{
 			prodServiceCounter{
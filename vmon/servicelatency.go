@@ -6,10 +6,10 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/v23/naming"
@@ -21,6 +21,12 @@ import (
 	"v.io/x/lib/gcm"
 )
 
+const (
+	// numLatencyWorkers is the maximum number of services whose latency is
+	// checked concurrently.
+	numLatencyWorkers = 4
+)
+
 var (
 	// Empirically, running "debug stats read -json
 	// /ns.dev.v.io:8101/binaries/__debug/stats/rpc/server/routing-id/*/methods/*/latency-ms/delta1m
@@ -31,11 +37,54 @@ var (
 
 type latencyData struct {
 	location *monitoring.ServiceLocation
-	latency  time.Duration
+	// samples holds the sorted latencies of the probes of the service
+	// instance. Probes that timed out are recorded with the timeout as
+	// their latency.
+	samples []time.Duration
+	// timeouts is the number of probes that timed out.
+	timeouts int
+}
+
+// percentile returns the given percentile, between 0 and 1, of the
+// latency samples using the nearest-rank method.
+func (d latencyData) percentile(p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(d.samples)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return d.samples[i]
+}
+
+// max returns the maximum of the latency samples.
+func (d latencyData) max() time.Duration {
+	return d.samples[len(d.samples)-1]
+}
+
+// toMs converts the given duration to milliseconds.
+func toMs(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1000000.0
+}
+
+// Task and result for checkServiceLatencyWorker.
+type latencyTask struct {
+	index       int
+	serviceName string
+}
+type latencyResult struct {
+	index int
+	lats  []latencyData
+	err   error
 }
 
 // checkServiceLatency checks all services and adds their check latency to GCM.
-func checkServiceLatency(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+// Each service instance is probed -samples times, and the median latency
+// is sent to GCM. If there are multiple samples, the 95th percentile and
+// the maximum latency are sent as well, under the metric names of the
+// services followed by " p95" and " max".
+func checkServiceLatency(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
+	if samplesFlag < 1 {
+		return fmt.Errorf("-samples must be positive, got %d", samplesFlag)
+	}
 	serviceNames := []string{
 		monitoring.SNMounttable,
 		monitoring.SNMacaroon,
@@ -52,8 +101,27 @@ func checkServiceLatency(v23ctx *context.T, ctx *tool.Context, s *cloudmonitorin
 		return err
 	}
 	now := time.Now().UTC().Format(time.RFC3339)
-	for _, serviceName := range serviceNames {
-		lats, err := checkSingleServiceLatency(v23ctx, ctx, serviceName)
+
+	// Check services concurrently, and then process the results in the
+	// order of serviceNames so that the output is deterministic.
+	numTasks := len(serviceNames)
+	tasks := make(chan latencyTask, numTasks)
+	taskResults := make(chan latencyResult, numTasks)
+	for i := 0; i < numLatencyWorkers; i++ {
+		go checkServiceLatencyWorker(v23ctx, ctx, tasks, taskResults)
+	}
+	for i, serviceName := range serviceNames {
+		tasks <- latencyTask{index: i, serviceName: serviceName}
+	}
+	close(tasks)
+	results := make([]latencyResult, numTasks)
+	for i := 0; i < numTasks; i++ {
+		r := <-taskResults
+		results[r.index] = r
+	}
+
+	for i, serviceName := range serviceNames {
+		lats, err := results[i].lats, results[i].err
 		if err != nil {
 			test.Fail(ctx, "%s\n", serviceName)
 			fmt.Fprintf(ctx.Stderr(), "%v\n", err)
@@ -64,19 +132,33 @@ func checkServiceLatency(v23ctx *context.T, ctx *tool.Context, s *cloudmonitorin
 		for _, lat := range lats {
 			instance := lat.location.Instance
 			zone := lat.location.Zone
-			latMs := float64(lat.latency.Nanoseconds()) / 1000000.0
+			latMs := toMs(lat.percentile(0.5))
 			agg.add(latMs)
 
 			// Send data to GCM.
 			if err := sendDataToGCM(s, mdLat, latMs, now, instance, zone, serviceName); err != nil {
 				return err
 			}
+			msg := fmt.Sprintf("%fms", latMs)
+			if len(lat.samples) > 1 {
+				p95Ms, maxMs := toMs(lat.percentile(0.95)), toMs(lat.max())
+				if err := sendDataToGCM(s, mdLat, p95Ms, now, instance, zone, serviceName+" p95"); err != nil {
+					return err
+				}
+				if err := sendDataToGCM(s, mdLat, maxMs, now, instance, zone, serviceName+" max"); err != nil {
+					return err
+				}
+				msg = fmt.Sprintf("p50: %fms, p95: %fms, max: %fms", latMs, p95Ms, maxMs)
+			}
 
 			label := fmt.Sprintf("%s (%s, %s)", serviceName, instance, zone)
-			if lat.latency == timeout {
-				test.Warn(ctx, "%s: %fms [TIMEOUT]\n", label, latMs)
-			} else {
-				test.Pass(ctx, "%s: %fms\n", label, latMs)
+			switch {
+			case lat.timeouts == len(lat.samples):
+				test.Warn(ctx, "%s: %s [TIMEOUT]\n", label, msg)
+			case lat.timeouts > 0:
+				test.Warn(ctx, "%s: %s [%d/%d samples timed out]\n", label, msg, lat.timeouts, len(lat.samples))
+			default:
+				test.Pass(ctx, "%s: %s\n", label, msg)
 			}
 		}
 
@@ -95,6 +177,17 @@ func checkServiceLatency(v23ctx *context.T, ctx *tool.Context, s *cloudmonitorin
 	return nil
 }
 
+func checkServiceLatencyWorker(v23ctx *context.T, ctx *tool.Context, tasks <-chan latencyTask, results chan<- latencyResult) {
+	for task := range tasks {
+		lats, err := checkSingleServiceLatency(v23ctx, ctx, task.serviceName)
+		results <- latencyResult{
+			index: task.index,
+			lats:  lats,
+			err:   err,
+		}
+	}
+}
+
 func checkSingleServiceLatency(v23ctx *context.T, ctx *tool.Context, serviceName string) ([]latencyData, error) {
 	// Get service's mounted name.
 	serviceMountedName, err := monitoring.GetServiceMountedName(namespaceRootFlag, serviceName)
@@ -111,26 +204,25 @@ func checkSingleServiceLatency(v23ctx *context.T, ctx *tool.Context, serviceName
 		return nil, err
 	}
 
-	// For each group, get the latency from the first available name.
+	// For each group, probe the latency of the first available name
+	// -samples times.
 	latencies := []latencyData{}
 	errors := []error{}
 	for _, group := range groups {
-		v23ctx, cancel := context.WithTimeout(v23ctx, timeout)
-		defer cancel()
-		latency, err := getLatency(v23ctx, &group)
+		data, err := sampleLatency(v23ctx, &group)
 		if err != nil {
 			errors = append(errors, err)
 			continue
 		}
+		v23ctx, cancel := context.WithTimeout(v23ctx, timeout)
+		defer cancel()
 		location, err := monitoring.GetServiceLocation(v23ctx, ctx, group)
 		if err != nil {
 			errors = append(errors, err)
 			continue
 		}
-		latencies = append(latencies, latencyData{
-			location: location,
-			latency:  latency,
-		})
+		data.location = location
+		latencies = append(latencies, data)
 	}
 	if len(errors) == len(groups) {
 		return latencies, fmt.Errorf("%v", errors)
@@ -139,6 +231,52 @@ func checkSingleServiceLatency(v23ctx *context.T, ctx *tool.Context, serviceName
 	return latencies, nil
 }
 
+// sampleLatency probes the latency of the given mount entry -samples
+// times, waiting -interval between the probes. Probes that fail for
+// reasons other than timeouts are not recorded; an error is returned if
+// all probes fail.
+func sampleLatency(v23ctx *context.T, me *naming.MountEntry) (latencyData, error) {
+	return collectSamples(samplesFlag, intervalFlag, func() (time.Duration, error) {
+		probeCtx, cancel := context.WithTimeout(v23ctx, timeout)
+		defer cancel()
+		return getLatency(probeCtx, me)
+	})
+}
+
+// collectSamples calls the given probe n times, waiting the given
+// interval between the calls, and returns the latencies it reports as
+// described by sampleLatency.
+func collectSamples(n int, interval time.Duration, probe func() (time.Duration, error)) (latencyData, error) {
+	data := latencyData{}
+	var lastErr error
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		latency, err := probe()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if latency == timeout {
+			data.timeouts++
+		}
+		data.samples = append(data.samples, latency)
+	}
+	if len(data.samples) == 0 {
+		return data, lastErr
+	}
+	sort.Sort(durations(data.samples))
+	return data, nil
+}
+
+// durations implements sort.Interface for a slice of durations.
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
 func getLatency(v23ctx *context.T, me *naming.MountEntry) (time.Duration, error) {
 	latency := timeout
 	start := time.Now()
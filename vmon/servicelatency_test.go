@@ -0,0 +1,84 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		samples []time.Duration
+		p       float64
+		want    time.Duration
+	}{
+		{[]time.Duration{5 * ms}, 0.5, 5 * ms},
+		{[]time.Duration{5 * ms}, 0.95, 5 * ms},
+		{[]time.Duration{1 * ms, 2 * ms}, 0.5, 1 * ms},
+		{[]time.Duration{1 * ms, 2 * ms, 3 * ms}, 0.5, 2 * ms},
+		{[]time.Duration{1 * ms, 2 * ms, 3 * ms, 4 * ms}, 0.5, 2 * ms},
+		{[]time.Duration{1 * ms, 2 * ms, 3 * ms, 4 * ms}, 0.95, 4 * ms},
+		{[]time.Duration{1 * ms, 2 * ms, 3 * ms, 4 * ms}, 0, 1 * ms},
+		{[]time.Duration{1 * ms, 2 * ms, 3 * ms, 4 * ms}, 1, 4 * ms},
+	}
+	for _, test := range tests {
+		d := latencyData{samples: test.samples}
+		if got := d.percentile(test.p); got != test.want {
+			t.Errorf("%v: want p%v %v, got %v", test.samples, test.p*100, test.want, got)
+		}
+	}
+}
+
+func TestCollectSamples(t *testing.T) {
+	ms := time.Millisecond
+	errProbe := fmt.Errorf("probe failed")
+	type probeResult struct {
+		latency time.Duration
+		err     error
+	}
+	tests := []struct {
+		probes   []probeResult
+		samples  []time.Duration
+		timeouts int
+		err      error
+	}{
+		{
+			probes:  []probeResult{{3 * ms, nil}, {1 * ms, nil}, {2 * ms, nil}},
+			samples: []time.Duration{1 * ms, 2 * ms, 3 * ms},
+		},
+		{
+			probes:   []probeResult{{timeout, nil}, {1 * ms, nil}, {-1, errProbe}},
+			samples:  []time.Duration{1 * ms, timeout},
+			timeouts: 1,
+		},
+		{
+			probes:   []probeResult{{timeout, nil}, {timeout, nil}},
+			samples:  []time.Duration{timeout, timeout},
+			timeouts: 2,
+		},
+		{
+			probes: []probeResult{{-1, errProbe}, {-1, errProbe}},
+			err:    errProbe,
+		},
+	}
+	for _, test := range tests {
+		i := 0
+		data, err := collectSamples(len(test.probes), 0, func() (time.Duration, error) {
+			r := test.probes[i]
+			i++
+			return r.latency, r.err
+		})
+		if err != test.err {
+			t.Errorf("%v: want error %v, got %v", test.probes, test.err, err)
+		}
+		if !reflect.DeepEqual(data.samples, test.samples) || data.timeouts != test.timeouts {
+			t.Errorf("%v: want samples %v with %d timeouts, got %v with %d", test.probes, test.samples, test.timeouts, data.samples, data.timeouts)
+		}
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/devtools/internal/monitoring"
@@ -27,7 +25,7 @@ type metadataData struct {
 }
 
 // checkServiceMetadata checks all service metadata and adds the results to GCM.
-func checkServiceMetadata(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkServiceMetadata(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	serviceNames := []string{
 		monitoring.SNMounttable,
 		monitoring.SNIdentity,
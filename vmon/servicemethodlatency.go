@@ -11,8 +11,6 @@ import (
 	"sort"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/x/devtools/internal/monitoring"
@@ -33,7 +31,7 @@ type perMethodLatencyData struct {
 
 // checkServicePerMethodLatency checks service per-method RPC latency and
 // adds the results to GCM.
-func checkServicePerMethodLatency(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkServicePerMethodLatency(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	serviceNames := []string{
 		monitoring.SNMounttable,
 		monitoring.SNIdentity,
@@ -10,8 +10,6 @@ import (
 	"sort"
 	"time"
 
-	cloudmonitoring "google.golang.org/api/monitoring/v3"
-
 	"v.io/jiri/tool"
 	"v.io/v23/context"
 	"v.io/v23/naming"
@@ -37,7 +35,7 @@ type qpsData struct {
 
 // checkServiceQPS checks service RPC QPS (per-method and total) and adds
 // the results to GCM.
-func checkServiceQPS(v23ctx *context.T, ctx *tool.Context, s *cloudmonitoring.Service) error {
+func checkServiceQPS(v23ctx *context.T, ctx *tool.Context, s metricSink) error {
 	serviceNames := []string{
 		monitoring.SNMounttable,
 		monitoring.SNIdentity,
@@ -0,0 +1,74 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	cloudmonitoring "google.golang.org/api/monitoring/v3"
+
+	"v.io/x/devtools/internal/monitoring"
+	"v.io/x/lib/gcm"
+)
+
+// metricSink is the destination of the timeseries written by checks.
+type metricSink interface {
+	// Write writes the given timeseries. The sink may buffer the
+	// timeseries until Flush is called.
+	Write(ts *cloudmonitoring.TimeSeries) error
+	// Flush writes any buffered timeseries.
+	Flush() error
+}
+
+// jsonSink writes timeseries to a writer as JSON, one timeseries per line.
+type jsonSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sink *jsonSink) Flush() error {
+	return nil
+}
+
+func (sink *jsonSink) Write(ts *cloudmonitoring.TimeSeries) error {
+	bytes, err := json.Marshal(ts)
+	if err != nil {
+		return fmt.Errorf("Marshal(%v) failed: %v", ts, err)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	_, err = fmt.Fprintf(sink.w, "%s\n", bytes)
+	return err
+}
+
+// newSink returns the sink identified by the given -sink flag value,
+// together with a function that releases the resources of the sink.
+func newSink(value string, stdout io.Writer) (metricSink, func() error, error) {
+	nop := func() error { return nil }
+	switch {
+	case value == "gcm":
+		s, err := gcm.Authenticate(keyFileFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		w := monitoring.NewBatchWriter(monitoring.NewGCMCreator(s), projectFlag, 0)
+		return w, w.Close, nil
+	case value == "stdout":
+		return &jsonSink{w: stdout}, nop, nil
+	case strings.HasPrefix(value, "file:"):
+		f, err := os.Create(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return &jsonSink{w: f}, f.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown sink %q", value)
+	}
+}